@@ -1,16 +1,22 @@
 package main
 
 import (
-	"bufio"
-	"encoding/json"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"time"
 
+	"github.com/willibrandon/ChronoGo/pkg/bench"
+	"github.com/willibrandon/ChronoGo/pkg/chrono"
 	"github.com/willibrandon/ChronoGo/pkg/debugger"
+	"github.com/willibrandon/ChronoGo/pkg/export"
+	"github.com/willibrandon/ChronoGo/pkg/importer"
 	"github.com/willibrandon/ChronoGo/pkg/instrumentation"
 	"github.com/willibrandon/ChronoGo/pkg/recorder"
 	"github.com/willibrandon/ChronoGo/pkg/replay"
@@ -27,15 +33,78 @@ func printUsage() {
 	fmt.Println("\nOptions:")
 	fmt.Println("  -events <file>    Specify events file path (default: chronogo.events)")
 	fmt.Println("  -replay           Run in replay mode only (no execution)")
+	fmt.Println("                    -events may name a flight recorder segment directory")
+	fmt.Println("                    With -replay, -events - reads the recording from stdin, and a named pipe is read as a stream")
+	fmt.Println("  -session <name>   Restore a saved debugging session")
+	fmt.Println("  -start-at-end     Start replay at the last recorded event")
+	fmt.Println("  -from <n|time>    Replay only from this event index or RFC 3339 time")
+	fmt.Println("  -to <n|time>      Replay only up to this event index or time, to bound memory")
+	fmt.Println("                    on huge recordings; indices stay those of the whole recording")
+	fmt.Println("  -attach <pid>     Attach Delve to a running instrumented process and replay its -events")
+	fmt.Println("                    recording alongside; quitting detaches and leaves the process running")
+	fmt.Println("  -collect <addr>   Collect events from remote TCP recorders into the events file")
+	fmt.Println("  -follow           With -collect, print events as they are collected")
+	fmt.Println("  -key-file <file>  Decrypt and verify a secure recording and its sessions with this key")
+	fmt.Println("  -force            Replay a secure recording even if over 10% of its events can't be read")
+	fmt.Println("  -keep-order       Replay events in file order instead of ordering them by timestamp")
+	fmt.Println("  -no-color         Disable colored output (also set by NO_COLOR)")
 	fmt.Println("  -help             Show this help message")
+	fmt.Println("\nSubcommands:")
+	fmt.Println("  rekey -events <file> -old-key <file> -new-key <file>")
+	fmt.Println("                    Re-encrypt a secure recording with a new key")
+	fmt.Println("  bench             Measure recording overhead on this machine")
+	fmt.Println("  compact -events <file> -o <file> [-keep-types <types>] [-dedupe-loops]")
+	fmt.Println("                    Shrink a recording by dropping event types and collapsing loops")
+	fmt.Println("  transcode -events <file> -o <file> [-compression zstd|gzip|none] [-key-file <file>] [-out-key-file <file>]")
+	fmt.Println("                    Rewrite a recording with another compression, sealing or opening it with keys")
+	fmt.Println("  merge -events <file> -o <file>")
+	fmt.Println("                    Stitch the recordings of child processes into their parent's, tagged pid:<pid>")
+	fmt.Println("  stats -events <file> [-format csv|json] [-o <file>] [-bucket <width>]")
+	fmt.Println("                    Export per-function and per-event-type aggregates")
+	fmt.Println("  info -events <file> [-key-file <file>]")
+	fmt.Println("                    Show the Go version, platform, build and host a recording was made with")
+	fmt.Println("  bundle -events <file> -root <dir> -o <file>")
+	fmt.Println("                    Package a recording and its source files into one zip for -replay")
+	fmt.Println("  export -format mermaid-sequence|flamegraph -events <file> -o <file> [-from <n>] [-to <n>]")
+	fmt.Println("                    Draw goroutines, channel messages and mutex operations as a sequence diagram")
+	fmt.Println("  coverage -events <file> -o <file>")
+	fmt.Println("                    Write the lines a recording executed as a profile for go tool cover")
+	fmt.Println("  verify -events <file> [-max <n>] [-batch <n>] <binary> [args...]")
+	fmt.Println("                    Check under Delve that the binary enters the recorded functions in order")
+	fmt.Println("  import-logs [-format json-lines] [-map <field>=<path>,...] -o <file> <log>")
+	fmt.Println("                    Convert structured log records into a recording to replay")
 	fmt.Println("\nExamples:")
 	fmt.Println("  chrono myapp                        # Debug myapp with default settings")
 	fmt.Println("  chrono -events custom.log myapp     # Debug with custom events file")
 	fmt.Println("  chrono -replay -events saved.log    # Replay events from saved.log")
+	fmt.Println("  chrono -replay -events saved.log -session bug42  # Resume session bug42")
+	fmt.Println("  chrono -replay -events /var/log/flight          # Replay flight recorder segments")
+	fmt.Println("  chrono -replay -events bug42.zip                # Replay a bundle, with its code")
+	fmt.Println("  chrono -replay -events huge.log -from 100000 -to 150000  # Replay a window")
+	fmt.Println("  ssh prod 'cat app.events' | chrono -replay -events -    # Replay a recording from stdin")
+	fmt.Println("  chrono -attach 4242 -events app.log             # Debug a running process with its recording")
+	fmt.Println("  chrono -collect :7070 -events fleet.log         # Collect remote recordings")
+	fmt.Println("  chrono import-logs -map funcName=caller,timestamp=ts -o app.events app.log")
 	fmt.Println("\nReplay Mode Commands:")
 	fmt.Println("  c, continue       Continue execution until the next breakpoint")
 	fmt.Println("  s, step           Step forward one event")
 	fmt.Println("  b, backstep       Step backward one event")
+	fmt.Println("  end               Jump to the last recorded event")
+	fmt.Println("  r, restart        Start the replay over from the beginning")
+	fmt.Println("  next-error        Jump to the next recorded error (errors lists them all)")
+	fmt.Println("  bt, stack         Show the call stack at the current event, deferred calls marked")
+	fmt.Println("  bisect <predicate> Jump to the first event where e.g. var total > 1000 holds")
+	fmt.Println("  gr, goroutines    List the recorded goroutines and the OS thread each last ran on")
+	fmt.Println("  nextiter, iter <n> Jump to the next or nth iteration of the current loop")
+	fmt.Println("  check             Check mutex and channel invariants over the recording")
+	fmt.Println("  verify-sync       Check that Delve is stopped where the current event was recorded")
+	fmt.Println("  stats [width]     Show per-function counts and event types over time")
+	fmt.Println("  io [name]         Summarize traced file and network I/O, or jump to the last write")
+	fmt.Println("  traces            List recorded requests; trace <id> jumps to one")
+	fmt.Println("  l, list [n]       Show the code around the current event")
+	fmt.Println("  inspect [index]   Show every field of an event; --raw prints its line from the file")
+	fmt.Println("  format [template] Set the text/template for printing events (or CHRONOGO_EVENT_FORMAT)")
+	fmt.Println("  checkpoint        Remember the position; checkpoints lists them, restore <id> returns")
 	fmt.Println("  q, quit           Exit the debugger")
 	fmt.Println("  show              Show the current execution state")
 	fmt.Println("  help              Display available commands")
@@ -61,43 +130,636 @@ func testFunction() int {
 	return y
 }
 
-func loadEventsFromFile(filePath string) ([]recorder.Event, error) {
-	file, err := os.Open(filePath)
+// maxDroppedFraction is the fraction of a secure recording's events that may
+// be unreadable before replay is refused without -force
+const maxDroppedFraction = 0.1
+
+// openSession opens a replay session for the events file, reporting how many
+// events were parsed and which of a secure recording's events were skipped.
+// Unless force is set, a recording with too many skipped events is refused,
+// since its replay would be misleading.
+func openSession(filePath string, force bool, opts ...chrono.Option) (*chrono.Session, error) {
+	session, err := chrono.Open(filePath, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if filePath == "-" {
+		reopenTerminal()
+	}
+	if report := session.ReadReport(); report != nil && report.Dropped() > 0 {
+		fmt.Printf("Warning: %s\n", report)
+		if report.DroppedFraction() > maxDroppedFraction && !force {
+			session.Close()
+			return nil, fmt.Errorf("%d of %d events could not be read, check the key or use -force to replay the rest",
+				report.Dropped(), report.Events)
+		}
+	}
+	fmt.Printf("Successfully parsed %d events from file\n", len(session.Events()))
+	warnGaps(replay.DetectGaps(session.Events()))
+	return session, nil
+}
+
+// reopenTerminal points standard input back at the terminal once the
+// recording has been read from it, so the CLI can read commands
+func reopenTerminal() {
+	name := "/dev/tty"
+	if runtime.GOOS == "windows" {
+		name = "CONIN$"
+	}
+	tty, err := os.Open(name)
+	if err != nil {
+		fmt.Printf("Warning: No terminal to read commands from: %v\n", err)
+		return
+	}
+	os.Stdin = tty
+}
+
+// setEventsSource tells the CLI where the recording it replays was read
+// from, the events file at path or the session's stream
+func setEventsSource(cli *debugger.CLI, session *chrono.Session, path string) {
+	if stream := session.Stream(); stream != "" {
+		cli.SetEventsStream(stream)
+		return
+	}
+	cli.SetEventsFile(path)
+}
+
+// parseWindow returns the option that loads the window of events between
+// the -from and -to flags, either of which may be empty
+func parseWindow(from, to string) (chrono.Option, error) {
+	fromBound, toBound := replay.WindowBound{}, replay.Unbounded
+	var err error
+	if from != "" {
+		if fromBound, err = replay.ParseWindowBound(from); err != nil {
+			return nil, fmt.Errorf("invalid -from: %v", err)
+		}
+	}
+	if to != "" {
+		if toBound, err = replay.ParseWindowBound(to); err != nil {
+			return nil, fmt.Errorf("invalid -to: %v", err)
+		}
+	}
+	return chrono.WithWindow(fromBound, toBound), nil
+}
+
+// warnClockSkew prints a single warning if the recording's timestamps went backward
+func warnClockSkew(skew replay.ClockSkew, opts replay.ReplayOptions) {
+	if skew.Events == 0 {
+		return
+	}
+	action := "reordered by timestamp for replay"
+	if !opts.NormalizeTimestamps {
+		action = "replayed in file order"
+	}
+	fmt.Printf("Warning: %d events have timestamps before earlier events (clock skew up to %v); %s\n",
+		skew.Events, skew.Max, action)
+}
+
+// warnGaps prints a single warning if event IDs skip values, listing the
+// first few gaps
+func warnGaps(gaps []replay.Gap) {
+	if len(gaps) == 0 {
+		return
+	}
+	const shown = 3
+	var ranges []string
+	for i, g := range gaps {
+		if i == shown {
+			ranges = append(ranges, fmt.Sprintf("and %d more", len(gaps)-shown))
+			break
+		}
+		ranges = append(ranges, g.String())
+	}
+	fmt.Printf("Warning: %d events appear to be missing from the recording (%s); the recorder may have dropped them\n",
+		replay.MissingEvents(gaps), strings.Join(ranges, ", "))
+}
+
+// loadSecurityOptions reads the master key at path and returns security
+// options that encrypt and check integrity with keys derived from it. The
+// master key itself is kept as a previous key for both, to read recordings
+// sealed with it before keys were derived.
+func loadSecurityOptions(path string) (recorder.SecurityOptions, error) {
+	key, err := recorder.ReadKeyFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("error opening events file: %v", err)
+		return recorder.SecurityOptions{}, err
 	}
-	defer file.Close()
 
-	var events []recorder.Event
-	scanner := bufio.NewScanner(file)
+	opts := recorder.DefaultSecurityOptions()
+	recorder.WithKey(key)(&opts)
+	if err := opts.Validate(); err != nil {
+		return recorder.SecurityOptions{}, err
+	}
+	recorder.WithDecryptionKeys(key)(&opts)
+	recorder.WithPreviousIntegrityKeys(key)(&opts)
+	return opts, nil
+}
+
+// runRekey re-encrypts a secure events file with a new key
+func runRekey(args []string) error {
+	fs := flag.NewFlagSet("rekey", flag.ExitOnError)
+	eventsFile := fs.String("events", "chronogo.events", "Path to the secure events file")
+	oldKeyFile := fs.String("old-key", "", "Path to the key the recording is currently encrypted with")
+	newKeyFile := fs.String("new-key", "", "Path to the key to re-encrypt the recording with")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *oldKeyFile == "" || *newKeyFile == "" {
+		return fmt.Errorf("both -old-key and -new-key are required")
+	}
+
+	oldOpts, err := loadSecurityOptions(*oldKeyFile)
+	if err != nil {
+		return err
+	}
+	opts, err := loadSecurityOptions(*newKeyFile)
+	if err != nil {
+		return err
+	}
+	recorder.WithDecryptionKeys(append([][]byte{oldOpts.EncryptionKey}, oldOpts.DecryptionKeys...)...)(&opts)
+	recorder.WithPreviousIntegrityKeys(append([][]byte{oldOpts.IntegrityKey}, oldOpts.PreviousIntegrityKeys...)...)(&opts)
 
-	// Increase scanner buffer size for larger JSON lines
-	const maxCapacity = 512 * 1024 // 512KB
-	buf := make([]byte, maxCapacity)
-	scanner.Buffer(buf, maxCapacity)
+	rekeyed, err := recorder.RekeyEventsFile(*eventsFile, opts)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Re-encrypted %d events in %s\n", rekeyed, *eventsFile)
+	return nil
+}
 
-	lineNum := 0
-	for scanner.Scan() {
-		lineNum++
-		line := scanner.Text()
-		if len(line) == 0 {
-			continue // Skip empty lines
+// runInfo prints the build and environment a recording was made in
+func runInfo(args []string) error {
+	fs := flag.NewFlagSet("info", flag.ExitOnError)
+	eventsFile := fs.String("events", "chronogo.events", "Path to the events file or bundle")
+	keyFile := fs.String("key-file", "", "Key of a secure recording, needed to read its metadata")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var md *recorder.RecordingMetadata
+	var err error
+	switch {
+	case recorder.IsBundle(*eventsFile):
+		var bundle *recorder.RecordingBundle
+		if bundle, err = recorder.OpenBundle(*eventsFile); err == nil {
+			md = bundle.Metadata
+		}
+	case *keyFile != "":
+		var opts recorder.SecurityOptions
+		if opts, err = loadSecurityOptions(*keyFile); err == nil {
+			md, err = recorder.ReadSecureMetadata(*eventsFile, opts)
 		}
+	default:
+		md, err = recorder.ReadMetadata(*eventsFile)
+	}
+	if err != nil {
+		return err
+	}
+	if md == nil {
+		fmt.Printf("%s has no metadata\n", *eventsFile)
+		return nil
+	}
+	fmt.Println(md)
+	return nil
+}
 
-		var event recorder.Event
-		if err := json.Unmarshal([]byte(line), &event); err != nil {
-			fmt.Printf("Warning: Could not parse event on line %d: %v\n", lineNum, err)
-			continue
+// runCompact rewrites a recording without the events the user doesn't need
+func runCompact(args []string) error {
+	fs := flag.NewFlagSet("compact", flag.ExitOnError)
+	eventsFile := fs.String("events", "chronogo.events", "Path to the events file to compact")
+	outFile := fs.String("o", "", "Path to write the compacted events file to")
+	keepTypes := fs.String("keep-types", "", "Comma-separated event types to keep, e.g. FunctionEntry,FunctionExit (default all)")
+	dedupeLoops := fs.Bool("dedupe-loops", false, "Collapse consecutive executions of the same statement into one event")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *outFile == "" {
+		return fmt.Errorf("-o is required")
+	}
+
+	opts := recorder.CompactOptions{DedupeLoops: *dedupeLoops}
+	if *keepTypes != "" {
+		for _, name := range strings.Split(*keepTypes, ",") {
+			t, ok := recorder.ParseEventType(strings.TrimSpace(name))
+			if !ok {
+				return fmt.Errorf("unknown event type: %s", name)
+			}
+			opts.KeepTypes = append(opts.KeepTypes, t)
 		}
-		events = append(events, event)
 	}
 
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error reading events file: %v", err)
+	before, after, err := recorder.CompactFile(*eventsFile, *outFile, opts)
+	if err != nil {
+		return err
 	}
+	fmt.Printf("Compacted %d events to %d in %s\n", before, after, *outFile)
+	return nil
+}
 
-	fmt.Printf("Successfully parsed %d events from file\n", len(events))
-	return events, nil
+// runCollector collects events from remote TCP recorders into eventsFile,
+// printing them as they arrive if follow is set
+func runCollector(addr, eventsFile string, follow bool) error {
+	c, err := recorder.NewCollector(addr, eventsFile, recorder.DefaultFileRecorderOptions())
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if follow {
+		// The subscription ends when the collector is closed
+		live := c.Subscribe(context.Background())
+		go func() {
+			for e := range live {
+				fmt.Printf("[%s] %s %s %s\n", e.Timestamp.Format("15:04:05.000"), e.Type, e.FuncName, e.Details)
+			}
+		}()
+	}
+	return c.Serve()
+}
+
+// runTranscode rewrites a recording with another compression, or between
+// the plain and secure formats
+func runTranscode(args []string) error {
+	fs := flag.NewFlagSet("transcode", flag.ExitOnError)
+	eventsFile := fs.String("events", "chronogo.events", "Path to the events file to transcode")
+	outFile := fs.String("o", "", "Path to write the transcoded events file to")
+	compression := fs.String("compression", "zstd", "Compression of the output, zstd, gzip or none")
+	keyFile := fs.String("key-file", "", "Key of the recording, if it is secure")
+	outKeyFile := fs.String("out-key-file", "", "Key to seal the output with (default a plain recording)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *outFile == "" {
+		return fmt.Errorf("-o is required")
+	}
+
+	var srcOpts, dstOpts recorder.FileRecorderOptions
+	switch *compression {
+	case "zstd":
+		dstOpts.CompressionType = recorder.ZstdCompression
+	case "gzip":
+		dstOpts.CompressionType = recorder.GzipCompression
+	case "none":
+		dstOpts.CompressionType = recorder.NoCompression
+	default:
+		return fmt.Errorf("unknown compression %q, want zstd, gzip or none", *compression)
+	}
+	if *keyFile != "" {
+		opts, err := loadSecurityOptions(*keyFile)
+		if err != nil {
+			return err
+		}
+		srcOpts.Security = &opts
+	}
+	if *outKeyFile != "" {
+		opts, err := loadSecurityOptions(*outKeyFile)
+		if err != nil {
+			return err
+		}
+		dstOpts.Security = &opts
+	}
+
+	if err := recorder.Transcode(*eventsFile, *outFile, srcOpts, dstOpts); err != nil {
+		return err
+	}
+	fmt.Printf("Transcoded %s to %s\n", *eventsFile, *outFile)
+	return nil
+}
+
+// runMerge writes a recording with the recordings of the child processes
+// it started stitched in
+func runMerge(args []string) error {
+	fs := flag.NewFlagSet("merge", flag.ExitOnError)
+	eventsFile := fs.String("events", "chronogo.events", "Path to the events file of the parent process")
+	outFile := fs.String("o", "", "Path to write the merged events file to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *outFile == "" {
+		return fmt.Errorf("-o is required")
+	}
+
+	parent, err := recorder.ReadEventsFile(*eventsFile)
+	if err != nil {
+		return err
+	}
+	events, err := recorder.StitchChildRecordings(parent, filepath.Dir(*eventsFile))
+	if err != nil {
+		return err
+	}
+	if err := recorder.WriteEventsFile(*outFile, events, recorder.DefaultFileRecorderOptions()); err != nil {
+		return err
+	}
+	fmt.Printf("Merged %d events from child processes into %s, %d in all\n", len(events)-len(parent), *outFile, len(events))
+	return nil
+}
+
+// runStats writes per-function and per-event-type aggregates of a recording
+// as CSV or JSON. The recording is streamed, so it needn't fit in memory.
+func runStats(args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	eventsFile := fs.String("events", "chronogo.events", "Path to the events file to summarize")
+	format := fs.String("format", "csv", "Output format, csv or json")
+	outFile := fs.String("o", "", "Path to write the stats to (default stdout)")
+	bucket := fs.Duration("bucket", time.Second, "Width of the time buckets event types are counted in")
+	maxFuncs := fs.Int("max-funcs", replay.DefaultStatsOptions().MaxFunctions, "Functions listed by name; the rest are counted as "+replay.OtherFunctions)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var write func(io.Writer, replay.Stats) error
+	switch *format {
+	case "csv":
+		write = replay.WriteStatsCSV
+	case "json":
+		write = replay.WriteStatsJSON
+	default:
+		return fmt.Errorf("unknown format %q, use csv or json", *format)
+	}
+
+	collector := replay.NewStatsCollector(replay.StatsOptions{BucketWidth: *bucket, MaxFunctions: *maxFuncs})
+	var rngUsage replay.RandUsage
+	if err := recorder.ScanEventsFile(*eventsFile, func(e recorder.Event) error {
+		collector.Add(e)
+		rngUsage.Add(e)
+		return nil
+	}); err != nil {
+		return err
+	}
+	// Written to stderr so the note doesn't end up in stats written to stdout
+	if calls := rngUsage.Untraced(); calls > 0 {
+		fmt.Fprintf(os.Stderr, "Note: The recording enters math/rand %d times but has no RngEvents; untraced random values can make re-execution diverge, use instrumentation.TracedRand to record them\n", calls)
+	}
+
+	stats := collector.Stats()
+	if *outFile == "" {
+		return write(os.Stdout, stats)
+	}
+	f, err := os.Create(*outFile)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", *outFile, err)
+	}
+	if err := write(f, stats); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote stats for %d events to %s\n", stats.Events, *outFile)
+	return nil
+}
+
+// runBundle packages an events file and the source files it refers to
+func runBundle(args []string) error {
+	fs := flag.NewFlagSet("bundle", flag.ExitOnError)
+	eventsFile := fs.String("events", "chronogo.events", "Path to the events file to bundle")
+	root := fs.String("root", ".", "Module root; source files under it are included")
+	outFile := fs.String("o", "chronogo.zip", "Path to write the bundle to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if err := recorder.Bundle(*eventsFile, *root, *outFile); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s; replay it with chrono -replay -events %s\n", *outFile, *outFile)
+	return nil
+}
+
+// flamegraphFormat is the export format for folded stacks
+const flamegraphFormat = "flamegraph"
+
+// runExport renders a recording in a format for another tool
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", export.MermaidSequence, "Output format, "+export.MermaidSequence+" or "+flamegraphFormat)
+	eventsFile := fs.String("events", "chronogo.events", "Path to the events file to export")
+	outFile := fs.String("o", "", "Path to write the export to (default stdout)")
+	opts := export.DefaultSequenceOptions()
+	fs.IntVar(&opts.From, "from", opts.From, "Index of the first event to draw")
+	fs.IntVar(&opts.To, "to", opts.To, "Index of the last event to draw, -1 for the end")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var write func(w io.Writer, events []recorder.Event) error
+	var what string
+	switch *format {
+	case export.MermaidSequence:
+		write = func(w io.Writer, events []recorder.Event) error {
+			return export.WriteMermaidSequence(w, events, opts)
+		}
+		what = "a sequence diagram"
+	case flamegraphFormat:
+		write = func(w io.Writer, events []recorder.Event) error {
+			return replay.ExportFlamegraph(events, w)
+		}
+		what = "folded stacks"
+	default:
+		return fmt.Errorf("unknown format %q, use %s or %s", *format, export.MermaidSequence, flamegraphFormat)
+	}
+
+	events, err := recorder.ReadEventsFile(*eventsFile)
+	if err != nil {
+		return err
+	}
+	// Indices match the replayer's
+	recorder.StableSort(events)
+
+	if *outFile == "" {
+		return write(os.Stdout, events)
+	}
+	f, err := os.Create(*outFile)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", *outFile, err)
+	}
+	if err := write(f, events); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s of %d events to %s\n", what, len(events), *outFile)
+	return nil
+}
+
+// errDiverged is returned by runVerify when live execution doesn't follow the recording
+var errDiverged = errors.New("live execution diverged from the recording")
+
+// runVerify re-runs a binary under Delve and checks that it enters the
+// functions of a recording in the recorded order
+func runVerify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	eventsFile := fs.String("events", "chronogo.events", "Path to the events file to verify")
+	opts := debugger.DefaultVerifyOptions()
+	fs.IntVar(&opts.MaxEntries, "max", opts.MaxEntries, "Function entries to check, 0 for all")
+	fs.IntVar(&opts.BatchSize, "batch", opts.BatchSize, "Functions given breakpoints at a time")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 {
+		return fmt.Errorf("expected the binary to run")
+	}
+
+	events, err := recorder.ReadEventsFile(*eventsFile)
+	if err != nil {
+		return err
+	}
+	// Indices match the replayer's
+	recorder.StableSort(events)
+
+	dbg, err := debugger.NewDelveDebuggerWithArgs(fs.Arg(0), fs.Args()[1:])
+	if err != nil {
+		return err
+	}
+	defer dbg.Close()
+
+	result, err := debugger.VerifyRecording(dbg, events, opts)
+	if err != nil {
+		return err
+	}
+	if len(result.Skipped) > 0 {
+		fmt.Printf("Warning: Skipped entries of %d functions no breakpoint could be set on: %s\n",
+			len(result.Skipped), strings.Join(result.Skipped, ", "))
+	}
+	if result.Divergence != nil {
+		fmt.Printf("Diverged after %d of %d function entries:\n%s\n", result.Checked, result.Total, result.Divergence)
+		return errDiverged
+	}
+	fmt.Printf("Verified %d function entries: %s follows the recording\n", result.Checked, fs.Arg(0))
+	return nil
+}
+
+// runImportLogs converts a structured log into a recording
+func runImportLogs(args []string) error {
+	fs := flag.NewFlagSet("import-logs", flag.ExitOnError)
+	format := fs.String("format", importer.JSONLines, "Log format, "+importer.JSONLines)
+	fieldMap := fs.String("map", "", "Comma-separated event=path pairs overriding timestamp=time,details=msg,level=level;\n"+
+		"events also take funcName, file, line, goroutine and trace, and paths may be dotted, e.g. trace=req.id")
+	levelTypes := fs.String("level-types", "", "Comma-separated level=EventType pairs, e.g. warn=ErrorEvent (default error=ErrorEvent)")
+	defaultType := fs.String("type", recorder.StatementExecution.String(), "Event type of records whose level has no type")
+	outFile := fs.String("o", "", "Path to write the events file to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *outFile == "" {
+		return fmt.Errorf("-o is required")
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("expected one log file, got %d", fs.NArg())
+	}
+	if *format != importer.JSONLines {
+		return fmt.Errorf("unknown format %q, use %s", *format, importer.JSONLines)
+	}
+
+	mapping := importer.DefaultLogMapping()
+	if err := mapping.ParseFieldMap(*fieldMap); err != nil {
+		return err
+	}
+	if err := mapping.ParseLevelTypes(*levelTypes); err != nil {
+		return err
+	}
+	t, ok := recorder.ParseEventType(*defaultType)
+	if !ok {
+		return fmt.Errorf("unknown event type: %s", *defaultType)
+	}
+	mapping.DefaultType = t
+
+	logFile := fs.Arg(0)
+	f, err := os.Open(logFile)
+	if err != nil {
+		return fmt.Errorf("failed to open log: %v", err)
+	}
+	defer f.Close()
+	result, err := importer.ImportJSONLines(f, mapping)
+	if err != nil {
+		return err
+	}
+	if err := recorder.WriteEventsFile(*outFile, result.Events, recorder.DefaultFileRecorderOptions()); err != nil {
+		return err
+	}
+
+	fmt.Printf("Imported %d of %d log records into %s\n", len(result.Events), result.Records, *outFile)
+	if len(result.Skipped) > 0 {
+		fmt.Printf("Warning: Skipped %d records that couldn't be mapped:\n", len(result.Skipped))
+		for _, reason := range result.SkipReasons() {
+			fmt.Printf("  %s\n", reason)
+		}
+	}
+	fmt.Printf("Replay it with chrono -replay -events %s\n", *outFile)
+	return nil
+}
+
+// runCoverage writes a coverage profile of the lines a recording executed
+func runCoverage(args []string) error {
+	fs := flag.NewFlagSet("coverage", flag.ExitOnError)
+	eventsFile := fs.String("events", "chronogo.events", "Path to the events file to read")
+	outFile := fs.String("o", "coverage.out", "Path to write the coverage profile to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	collector := replay.NewCoverageCollector()
+	if err := recorder.ScanEventsFile(*eventsFile, func(e recorder.Event) error {
+		collector.Add(e)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	out, err := os.Create(*outFile)
+	if err != nil {
+		return fmt.Errorf("failed to create coverage profile: %v", err)
+	}
+	summary, err := collector.WriteProfile(out)
+	if err != nil {
+		out.Close()
+		return fmt.Errorf("failed to write coverage profile: %v", err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to write coverage profile: %v", err)
+	}
+
+	fmt.Printf("Wrote %s: %d of %d statements in %d files executed\n", *outFile, summary.Covered, summary.Blocks, summary.Files)
+	if summary.Skipped > 0 {
+		fmt.Printf("Warning: Skipped %d recorded files not found on disk\n", summary.Skipped)
+	}
+	fmt.Printf("View it with go tool cover -html=%s\n", *outFile)
+	return nil
+}
+
+// runBench measures the recording overhead of the built-in workloads and prints a table
+func runBench() {
+	fmt.Println("Measuring recording overhead...")
+	results := make([]bench.Result, 0)
+	for _, w := range bench.Workloads() {
+		fmt.Printf("  %s\n", w.Name)
+		results = append(results, bench.Run(w))
+	}
+	fmt.Println()
+	bench.WriteTable(os.Stdout, results)
+}
+
+// restoreSession restores the named session into the CLI, if one was requested
+func restoreSession(cli *debugger.CLI, name string) {
+	if name == "" {
+		return
+	}
+	if err := cli.RestoreSession(name); err != nil {
+		fmt.Printf("Warning: Could not restore session '%s': %v\n", name, err)
+	}
+}
+
+// startAtEnd positions the CLI at the last event, if requested
+func startAtEnd(cli *debugger.CLI, enabled bool) {
+	if !enabled {
+		return
+	}
+	if err := cli.SeekEnd(); err != nil {
+		fmt.Printf("Warning: Could not start at end: %v\n", err)
+		return
+	}
+	fmt.Println("Starting at the last recorded event; use 'backstep' to walk backward")
 }
 
 // debugHelper provides a long-running function for debugging tests
@@ -114,17 +776,122 @@ func debugHelper() {
 
 // The main function coordinates the debugger and replayer
 func main() {
+	// Stop dlv processes left behind by a chrono that crashed
+	if killed, err := debugger.CleanupOrphans(); err != nil {
+		fmt.Printf("Warning: Failed to clean up orphaned Delve processes: %v\n", err)
+	} else if killed > 0 {
+		fmt.Printf("Cleaned up %d orphaned Delve processes\n", killed)
+	}
+
+	// Subcommands take their own flags
+	if len(os.Args) > 1 && os.Args[1] == "rekey" {
+		if err := runRekey(os.Args[2:]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "compact" {
+		if err := runCompact(os.Args[2:]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "transcode" {
+		if err := runTranscode(os.Args[2:]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "merge" {
+		if err := runMerge(os.Args[2:]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "info" {
+		if err := runInfo(os.Args[2:]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "bundle" {
+		if err := runBundle(os.Args[2:]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		if err := runExport(os.Args[2:]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "coverage" {
+		if err := runCoverage(os.Args[2:]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "verify" {
+		if err := runVerify(os.Args[2:]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "import-logs" {
+		if err := runImportLogs(os.Args[2:]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "stats" {
+		if err := runStats(os.Args[2:]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "bench" {
+		runBench()
+		return
+	}
+
 	// Set custom usage function for better help
 	flag.Usage = printUsage
 
 	// Parse command line flags
-	eventsFileFlag := flag.String("events", "chronogo.events", "Path to the events file")
+	eventsFileFlag := flag.String("events", "chronogo.events", "Path to the events file or segment directory")
 	replayModeFlag := flag.Bool("replay", false, "Run in replay mode only (no execution)")
+	sessionFlag := flag.String("session", "", "Name of a saved session to restore")
+	startAtEndFlag := flag.Bool("start-at-end", false, "Start replay at the last recorded event")
+	fromFlag := flag.String("from", "", "Replay from this event index or RFC 3339 time")
+	toFlag := flag.String("to", "", "Replay up to this event index or RFC 3339 time")
+	keyFileFlag := flag.String("key-file", "", "Path to the key for secure recordings (16, 24 or 32 bytes, or hex:<hex> or base64:<base64>)")
+	forceFlag := flag.Bool("force", false, "Replay a secure recording even if many of its events can't be read")
+	attachFlag := flag.Int("attach", 0, "PID of a running instrumented process to attach Delve to while replaying its recording")
+	collectFlag := flag.String("collect", "", "Listen address for collecting events from remote TCP recorders")
+	followFlag := flag.Bool("follow", false, "With -collect, print events as they are collected")
+	keepOrderFlag := flag.Bool("keep-order", false, "Replay events in file order instead of ordering them by timestamp")
+	noColorFlag := flag.Bool("no-color", false, "Disable colored output (also set by NO_COLOR)")
 	helpFlag := flag.Bool("help", false, "Show help message")
 	debugFlag := flag.Bool("debug", false, "Run in debug test mode")
 	testFlag := flag.Bool("test", false, "Run in test mode (for integration tests)")
 	flag.Parse()
 
+	if *noColorFlag {
+		debugger.SetColor(false)
+	}
+
 	// Check for test mode - this is used by the test suite
 	if *testFlag || testMode == "true" {
 		fmt.Println("Running in test mode - executing testFunction directly")
@@ -162,31 +929,93 @@ func main() {
 	fmt.Println("ChronoGo Time-Travel Debugger")
 	fmt.Println("-----------------------------")
 
+	// Secure recordings and their sessions are opened with the same key
+	var sessionOpts []chrono.Option
+	if *keyFileFlag != "" {
+		securityOpts, err := loadSecurityOptions(*keyFileFlag)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		debugger.SessionSecurity = securityOpts
+		sessionOpts = append(sessionOpts, chrono.WithSecurity(securityOpts))
+	}
+	replayOpts := replay.DefaultReplayOptions()
+	if *keepOrderFlag {
+		replayOpts.NormalizeTimestamps = false
+		sessionOpts = append(sessionOpts, chrono.WithReplayOptions(replayOpts))
+	}
+
+	if *fromFlag != "" || *toFlag != "" {
+		window, err := parseWindow(*fromFlag, *toFlag)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		sessionOpts = append(sessionOpts, window)
+	}
+
+	// Run as a collector for remote recorders
+	if *collectFlag != "" {
+		fmt.Printf("Collecting events on %s into %s\n", *collectFlag, *eventsFileFlag)
+		if err := runCollector(*collectFlag, *eventsFileFlag, *followFlag); err != nil {
+			fmt.Printf("Error running collector: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Attach to a running process, replaying the recording it has written so far
+	if *attachFlag != 0 {
+		if _, err := os.Stat(*eventsFileFlag); err != nil {
+			fmt.Printf("Error: Cannot find events file '%s' of process %d\n", *eventsFileFlag, *attachFlag)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Attaching to process %d and loading events from: %s\n", *attachFlag, *eventsFileFlag)
+		session, err := openSession(*eventsFileFlag, *forceFlag, append(sessionOpts, chrono.WithDelveAttach(*attachFlag))...)
+		if err != nil {
+			fmt.Printf("Error attaching: %v\n", err)
+			os.Exit(1)
+		}
+		defer session.Close()
+		warnClockSkew(session.ClockSkew(), replayOpts)
+
+		fmt.Printf("Loaded %d events. Entering replay mode with live debugging...\n", len(session.Events()))
+		cli := session.CLI()
+		setEventsSource(cli, session, *eventsFileFlag)
+		restoreSession(cli, *sessionFlag)
+		startAtEnd(cli, *startAtEndFlag)
+		cli.Start()
+		return
+	}
+
 	// Check if replay mode was explicitly requested
 	if *replayModeFlag {
-		if _, err := os.Stat(*eventsFileFlag); err != nil {
+		if _, err := os.Stat(*eventsFileFlag); err != nil && *eventsFileFlag != "-" {
 			fmt.Printf("Error: Cannot find events file '%s' for replay\n", *eventsFileFlag)
 			os.Exit(1)
 		}
 
 		fmt.Printf("Loading events from: %s\n", *eventsFileFlag)
-		events, err := loadEventsFromFile(*eventsFileFlag)
+		session, err := openSession(*eventsFileFlag, *forceFlag, sessionOpts...)
 		if err != nil {
 			fmt.Printf("Error loading events: %v\n", err)
 			os.Exit(1)
 		}
+		defer session.Close()
+		warnClockSkew(session.ClockSkew(), replayOpts)
 
-		if len(events) == 0 {
+		if len(session.Events()) == 0 {
 			fmt.Println("Error: No events found in the specified file")
 			os.Exit(1)
 		}
 
-		fmt.Printf("Loaded %d events. Entering replay mode...\n", len(events))
-		replayer := replay.NewBasicReplayer()
-		if err := replayer.LoadEvents(events); err != nil {
-			fmt.Printf("Error loading events: %v\n", err)
-		}
-		cli := debugger.NewCLI(replayer)
+		fmt.Printf("Loaded %d events. Entering replay mode...\n", len(session.Events()))
+		cli := session.CLI()
+		setEventsSource(cli, session, *eventsFileFlag)
+		restoreSession(cli, *sessionFlag)
+		startAtEnd(cli, *startAtEndFlag)
 		cli.Start()
 		return
 	}
@@ -220,20 +1049,19 @@ func main() {
 	// Check if the events file exists (either the default or custom one)
 	if _, err := os.Stat(customEventsFile); err == nil {
 		fmt.Printf("Found events file: %s\n", customEventsFile)
-		events, err := loadEventsFromFile(customEventsFile)
+		session, err := openSession(customEventsFile, *forceFlag, sessionOpts...)
 		if err != nil {
 			fmt.Printf("Error loading events: %v\n", err)
-		} else if len(events) > 0 {
-			fmt.Printf("Loaded %d events. Entering replay mode...\n", len(events))
-
-			// Initialize replayer with loaded events
-			replayer := replay.NewBasicReplayer()
-			if err := replayer.LoadEvents(events); err != nil {
-				fmt.Printf("Error loading events: %v\n", err)
-			}
+		} else if len(session.Events()) > 0 {
+			fmt.Printf("Loaded %d events. Entering replay mode...\n", len(session.Events()))
+			defer session.Close()
+			warnClockSkew(session.ClockSkew(), replayOpts)
 
 			// Start CLI in replay mode
-			cli := debugger.NewCLI(replayer)
+			cli := session.CLI()
+			setEventsSource(cli, session, customEventsFile)
+			restoreSession(cli, *sessionFlag)
+			startAtEnd(cli, *startAtEndFlag)
 			cli.Start()
 			return
 		} else {
@@ -249,6 +1077,7 @@ func main() {
 		fmt.Println("\nOptions:")
 		fmt.Println("  -events <file>    Specify events file path (default: chronogo.events)")
 		fmt.Println("  -replay           Run in replay mode only (no execution)")
+		fmt.Println("                    -events may name a flight recorder segment directory")
 		fmt.Println("\nExamples:")
 		fmt.Println("  chrono myapp               # Debug myapp with default settings")
 		fmt.Println("  chrono -events custom.log myapp  # Debug with custom events file")
@@ -276,7 +1105,8 @@ func main() {
 	instrumentation.InitInstrumentation(rec)
 
 	// Create a replayer
-	replayer := replay.NewBasicReplayer()
+	replayer := replay.NewBasicReplayerWithOptions(replayOpts)
+	defer replayer.Close()
 
 	// Try to initialize Delve debugger
 	delveDebugger, delveErr := debugger.NewDelveDebugger(absPath)
@@ -315,7 +1145,11 @@ func main() {
 
 	// Optionally save events to the specified file
 	if len(events) > 0 {
-		fileRec, err := recorder.NewFileRecorder(customEventsFile)
+		options := recorder.DefaultFileRecorderOptions()
+		if delveErr == nil {
+			options.Metadata.DelveVersion, _ = debugger.DelveAvailable()
+		}
+		fileRec, err := recorder.NewFileRecorderWithOptions(customEventsFile, options)
 		if err == nil {
 			for _, e := range events {
 				if err := fileRec.RecordEvent(e); err != nil {
@@ -332,9 +1166,15 @@ func main() {
 	if err := replayer.LoadEvents(events); err != nil {
 		fmt.Printf("Error loading events: %v\n", err)
 	}
+	warnClockSkew(replayer.ClockSkew(), replayOpts)
 
 	// Start the appropriate CLI (with or without Delve)
-	if delveErr != nil {
+	if errors.Is(delveErr, debugger.ErrDelveNotInstalled) || errors.Is(delveErr, debugger.ErrDelveUnsupported) {
+		fmt.Printf("Warning: %v\n", delveErr)
+		fmt.Println("Running in replay-only mode (no live debugging)")
+		cli := debugger.NewCLI(replayer)
+		cli.Start()
+	} else if delveErr != nil {
 		fmt.Printf("Warning: Failed to initialize Delve debugger: %v\n", delveErr)
 		fmt.Println("Running in replay-only mode (no live debugging)")
 		cli := debugger.NewCLI(replayer)
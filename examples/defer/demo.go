@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/willibrandon/ChronoGo/pkg/instrumentation"
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+	"github.com/willibrandon/ChronoGo/pkg/replay"
+)
+
+// This program demonstrates recording deferred calls and recover. process
+// panics inside parse; its deferred handler runs while the panic unwinds,
+// recovers, and process returns normally. The recorded events show the order
+// in which this happened, and the reconstructed call stack shows parse being
+// unwound when the deferred call starts.
+func main() {
+	// Package main has no dot in its path, so it counts as standard library
+	instrumentation.CurrentOptions.InstrumentStdlib = true
+
+	rec := recorder.NewInMemoryRecorder()
+	instrumentation.InitInstrumentation(rec)
+
+	err := process("a,b,")
+	instrumentation.InitInstrumentation(nil)
+	fmt.Printf("process returned: %v\n\n", err)
+
+	events := rec.GetEvents()
+	fmt.Println("Recorded events:")
+	for i, e := range events {
+		fmt.Printf("[%d] %s: %s\n", i, e.Type, e.Details)
+		fmt.Printf("    stack: %s\n", formatStack(replay.CallStack(events, i)))
+	}
+}
+
+// process parses input, turning a panic into an error
+func process(input string) (err error) {
+	instrumentation.FuncEntry("main.process", "examples/defer/demo.go", 38)
+	defer instrumentation.FuncExit("main.process", "examples/defer/demo.go", 39)
+
+	defer func() {
+		instrumentation.DeferEntry("main.process", "examples/defer/demo.go", 42)
+		defer instrumentation.DeferExit("main.process", "examples/defer/demo.go", 43)
+
+		if r := recover(); r != nil {
+			instrumentation.Recovered("main.process", "examples/defer/demo.go", 46, r)
+			err = fmt.Errorf("parse failed: %v", r)
+		}
+	}()
+
+	return parse(input)
+}
+
+// parse panics on an empty field
+func parse(input string) error {
+	instrumentation.FuncEntry("main.parse", "examples/defer/demo.go", 56)
+
+	for _, field := range strings.Split(input, ",") {
+		if field == "" {
+			// The panic skips the exit event below
+			panic("empty field")
+		}
+		instrumentation.RecordStatement("main.parse", "examples/defer/demo.go", 63, "parsed "+field)
+	}
+
+	instrumentation.FuncExit("main.parse", "examples/defer/demo.go", 66)
+	return nil
+}
+
+// formatStack prints a call stack outermost first, marking deferred calls
+func formatStack(stack []recorder.Event) string {
+	frames := make([]string, len(stack))
+	for i, frame := range stack {
+		frames[i] = frame.FuncName
+		if frame.Type == recorder.DeferOperation {
+			frames[i] = "defer in " + frame.FuncName
+		}
+	}
+	if len(frames) == 0 {
+		return "(empty)"
+	}
+	return strings.Join(frames, " > ")
+}
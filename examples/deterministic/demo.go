@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/willibrandon/ChronoGo/pkg/instrumentation"
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+	"github.com/willibrandon/ChronoGo/pkg/replay"
+)
+
+// This program demonstrates reproducing a flaky test with ChronoGo.
+// The code under test reads the clock, draws random numbers and races two
+// goroutines through a select. It is run repeatedly while recording until it
+// fails, and then the failing recording is re-executed deterministically with
+// a replay.DeterministicRunner, failing the same way every time.
+func main() {
+	fmt.Println("Running flaky test until it fails...")
+
+	var failing []recorder.Event
+	for attempt := 1; attempt <= 50; attempt++ {
+		rec := recorder.NewInMemoryRecorder()
+		instrumentation.InitInstrumentation(rec)
+
+		in := &liveInputs{rnd: instrumentation.NewTracedRand(time.Now().UnixNano())}
+		err := flakyTest(in)
+		instrumentation.InitInstrumentation(nil)
+
+		if err != nil {
+			fmt.Printf("Attempt %d failed: %v\n", attempt, err)
+			failing = rec.GetEvents()
+			break
+		}
+		fmt.Printf("Attempt %d passed\n", attempt)
+	}
+
+	if failing == nil {
+		fmt.Println("The test never failed; try running the demo again")
+		return
+	}
+
+	fmt.Printf("\nCaptured %d nondeterministic inputs. Re-executing the failure...\n", len(failing))
+	for run := 1; run <= 3; run++ {
+		runner := replay.NewDeterministicRunner(failing)
+		err := flakyTest(&replayInputs{runner: runner})
+		fmt.Printf("Replay %d: %v (diverged: %v)\n", run, err, runner.Diverged())
+	}
+}
+
+// inputs abstracts every nondeterministic input read by the code under test
+type inputs interface {
+	Now() time.Time
+	Intn(n int) int
+	// First returns the index of the channel that delivers first and its value
+	First(chs ...<-chan string) (int, string)
+}
+
+// liveInputs reads real inputs and records them
+type liveInputs struct {
+	rnd *instrumentation.TracedRand
+}
+
+func (l *liveInputs) Now() time.Time { return instrumentation.Now() }
+func (l *liveInputs) Intn(n int) int { return l.rnd.Intn(n) }
+
+func (l *liveInputs) First(chs ...<-chan string) (int, string) {
+	select {
+	case v := <-chs[0]:
+		instrumentation.RecordSelect(0, len(chs))
+		return 0, v
+	case v := <-chs[1]:
+		instrumentation.RecordSelect(1, len(chs))
+		return 1, v
+	}
+}
+
+// replayInputs serves the inputs captured in a recording
+type replayInputs struct {
+	runner *replay.DeterministicRunner
+}
+
+func (r *replayInputs) Now() time.Time { return r.runner.Now() }
+func (r *replayInputs) Intn(n int) int { return r.runner.Intn(n) }
+
+func (r *replayInputs) First(chs ...<-chan string) (int, string) {
+	choice := r.runner.SelectChoice(len(chs))
+	return choice, <-chs[choice]
+}
+
+// flakyTest checks that the primary backend answers a request within its budget
+func flakyTest(in inputs) error {
+	start := in.Now()
+
+	primary := make(chan string, 1)
+	fallback := make(chan string, 1)
+	go backend("primary", in.Intn(20), primary)
+	go backend("fallback", in.Intn(20), fallback)
+
+	which, answer := in.First(primary, fallback)
+	elapsed := in.Now().Sub(start)
+
+	if which != 0 {
+		return fmt.Errorf("expected answer from primary, got %q after %v", answer, elapsed)
+	}
+	return nil
+}
+
+// backend answers after a delay in milliseconds
+func backend(name string, delay int, out chan<- string) {
+	time.Sleep(time.Duration(delay) * time.Millisecond)
+	out <- name
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/willibrandon/ChronoGo/pkg/instrumentation"
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+// This program demonstrates recording outbound HTTP calls with ChronoGo.
+// A handler that calls other services records each call, so a replay shows
+// which services it talked to, what it sent and what came back.
+func main() {
+	// A stand-in for an external payment service
+	payments := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"status": "charged"}`)
+	}))
+	defer payments.Close()
+
+	rec := recorder.NewInMemoryRecorder()
+	transport := instrumentation.NewRecordingTransport(rec, nil)
+	transport.RecordHeaders = true
+	transport.RecordBodies = true
+	client := &http.Client{Transport: transport}
+
+	fmt.Println("Charging an order...")
+	if err := charge(client, payments.URL); err != nil {
+		fmt.Printf("Error charging: %v\n", err)
+		return
+	}
+
+	fmt.Println("\nRecorded network events:")
+	for _, event := range rec.GetEvents() {
+		fmt.Printf("  %s: %s\n", event.Type, event.Details)
+	}
+	fmt.Println("\nThe Authorization header and the card token are redacted.")
+}
+
+// charge asks the payment service to charge an order
+func charge(client *http.Client, url string) error {
+	req, err := http.NewRequest("POST", url+"/charges", strings.NewReader(`{"amount": 1999, "token": "tok_visa_4242"}`))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer sk_live_example")
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Payment service replied %s: %s\n", resp.Status, body)
+	return nil
+}
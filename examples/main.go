@@ -31,6 +31,10 @@ func printUsage() {
 	fmt.Println("  runtime-trace   - Demo of runtime/trace integration")
 	fmt.Println("  performance     - Demo of performance optimization features")
 	fmt.Println("  security        - Demo of security features (encryption, redaction, integrity)")
+	fmt.Println("  rng             - Demo of recording and replaying random number reads")
+	fmt.Println("  deterministic   - Demo of reproducing a flaky test from a recording")
+	fmt.Println("  defer           - Demo of recording deferred calls and recover during a panic")
+	fmt.Println("  http-client     - Demo of recording outbound HTTP calls")
 	fmt.Println("\nThe performance demo has these subcommands:")
 	fmt.Println("  compression     - Demonstrate compression of event logs")
 	fmt.Println("  snapshots       - Demonstrate configurable snapshot intervals")
@@ -60,6 +64,14 @@ func runDemo(demoName string, args []string) error {
 		demoPath = filepath.Join(workingDir, "examples", "performance", "demo.go")
 	case "security":
 		demoPath = filepath.Join(workingDir, "examples", "security", "demo.go")
+	case "rng":
+		demoPath = filepath.Join(workingDir, "examples", "rng", "demo.go")
+	case "deterministic":
+		demoPath = filepath.Join(workingDir, "examples", "deterministic", "demo.go")
+	case "defer":
+		demoPath = filepath.Join(workingDir, "examples", "defer", "demo.go")
+	case "http-client":
+		demoPath = filepath.Join(workingDir, "examples", "http_client", "demo.go")
 	default:
 		return fmt.Errorf("unknown demo: %s", demoName)
 	}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/willibrandon/ChronoGo/pkg/instrumentation"
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+	"github.com/willibrandon/ChronoGo/pkg/replay"
+)
+
+// This program demonstrates recording random number generator reads with ChronoGo.
+// The branch taken in run depends on random data, so without tracing the RNG a
+// re-execution (for example under Delve) could take a different path than the recording.
+func main() {
+	// Record a run with a time-based seed, as a real program would
+	rec := recorder.NewInMemoryRecorder()
+	instrumentation.InitInstrumentation(rec)
+
+	fmt.Println("Recording run...")
+	recordedBranch := run(instrumentation.NewTracedRand(time.Now().UnixNano()))
+
+	events := rec.GetEvents()
+	fmt.Printf("Recorded %d random number events\n", len(events))
+
+	// Stop recording and re-execute with the recorded values
+	instrumentation.InitInstrumentation(nil)
+
+	fmt.Println("\nRe-executing with recorded values...")
+	replayed := replay.RandFromEvents(events)
+	replayedBranch := run(replayed)
+
+	if replayedBranch == recordedBranch && !replayed.Diverged() {
+		fmt.Printf("\nRe-execution followed the recorded path (%s result)\n", recordedBranch)
+	} else {
+		fmt.Printf("\nRe-execution diverged: recorded %s, replayed %s\n", recordedBranch, replayedBranch)
+	}
+}
+
+// run mirrors the real-world sample program: generate data, average it and
+// branch on the result
+func run(r instrumentation.Rand) string {
+	result := processData(generateData(r, 10))
+	fmt.Printf("Processing complete with result: %v\n", result)
+
+	if result > 50 {
+		fmt.Printf("Large result detected: %d\n", result)
+		return "large"
+	}
+	fmt.Printf("Small result detected: %d\n", result)
+	return "small"
+}
+
+func generateData(r instrumentation.Rand, size int) []int {
+	data := make([]int, size)
+	for i := 0; i < size; i++ {
+		data[i] = r.Intn(100)
+	}
+	return data
+}
+
+func processData(data []int) int {
+	sum := 0
+	for _, value := range data {
+		sum += value
+	}
+	return sum / len(data)
+}
@@ -2,11 +2,14 @@ module github.com/willibrandon/ChronoGo
 
 go 1.24.1
 
+require (
+	github.com/go-delve/delve v1.24.1
+	github.com/klauspost/compress v1.18.0
+)
+
 require (
 	github.com/cilium/ebpf v0.11.0 // indirect
-	github.com/go-delve/delve v1.24.1 // indirect
 	github.com/hashicorp/golang-lru v1.0.2 // indirect
-	github.com/klauspost/compress v1.18.0 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
 	golang.org/x/arch v0.11.0 // indirect
 	golang.org/x/exp v0.0.0-20230224173230-c95f2b4c22f2 // indirect
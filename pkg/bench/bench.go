@@ -0,0 +1,209 @@
+// Package bench measures the runtime overhead of recording.
+//
+// The same workloads back the `chrono bench` command and the benchmarks in
+// the tests package, so numbers from CI and from a developer's machine are
+// directly comparable:
+//
+//	results := bench.RunAll()
+//	bench.WriteTable(os.Stdout, results)
+package bench
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"testing"
+	"text/tabwriter"
+
+	"github.com/willibrandon/ChronoGo/pkg/instrumentation"
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+// Workload is a micro workload that exercises one recording path
+type Workload struct {
+	Name        string
+	EventsPerOp int // Events recorded by each iteration of Bench
+	Bench       func(b *testing.B)
+}
+
+// Result is the measured overhead of a workload
+type Result struct {
+	Name          string
+	EventsPerOp   int
+	NsPerOp       float64
+	NsPerEvent    float64 // Wall time per recorded event, including the workload itself
+	RecorderNs    float64 // Time spent inside the recorder per event
+	BytesPerEvent float64 // Bytes written per event, for recorders that write bytes
+}
+
+// Workloads returns the built-in workloads in report order
+func Workloads() []Workload {
+	return []Workload{
+		{Name: "baseline", EventsPerOp: 0, Bench: Baseline},
+		{Name: "instrumented-calls", EventsPerOp: 2, Bench: InstrumentedCalls},
+		{Name: "goroutine-ids", EventsPerOp: 2, Bench: GoroutineIDs},
+		{Name: "channel-ping-pong", EventsPerOp: 4, Bench: ChannelPingPong},
+		{Name: "file-none", EventsPerOp: 2, Bench: FileRecording(recorder.NoCompression)},
+		{Name: "file-zstd", EventsPerOp: 2, Bench: FileRecording(recorder.ZstdCompression)},
+	}
+}
+
+// Run measures a single workload with testing.Benchmark
+func Run(w Workload) Result {
+	instrumentation.ResetOverheadStats()
+	r := testing.Benchmark(w.Bench)
+	stats := instrumentation.OverheadStats()
+
+	result := Result{
+		Name:        w.Name,
+		EventsPerOp: w.EventsPerOp,
+		NsPerOp:     float64(r.NsPerOp()),
+	}
+	if w.EventsPerOp > 0 {
+		result.NsPerEvent = result.NsPerOp / float64(w.EventsPerOp)
+	}
+	if stats.Events > 0 {
+		result.RecorderNs = float64(stats.PerEvent().Nanoseconds())
+	}
+	result.BytesPerEvent = r.Extra["bytes/event"]
+	return result
+}
+
+// RunAll measures every built-in workload
+func RunAll() []Result {
+	workloads := Workloads()
+	results := make([]Result, 0, len(workloads))
+	for _, w := range workloads {
+		results = append(results, Run(w))
+	}
+	return results
+}
+
+// WriteTable prints results as an aligned table
+func WriteTable(out io.Writer, results []Result) {
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "workload\tevents/op\tns/op\tns/event\trecorder ns/event\tbytes/event\t")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%d\t%.0f\t%s\t%s\t%s\t\n",
+			r.Name, r.EventsPerOp, r.NsPerOp,
+			optional(r.NsPerEvent, "%.0f"), optional(r.RecorderNs, "%.0f"), optional(r.BytesPerEvent, "%.1f"))
+	}
+	tw.Flush()
+}
+
+// optional formats v, or a dash if it wasn't measured
+func optional(v float64, format string) string {
+	if v == 0 {
+		return "-"
+	}
+	return fmt.Sprintf(format, v)
+}
+
+// work is the small amount of computation each workload iteration does
+func work(n int) int {
+	result := 0
+	for i := 0; i < n; i++ {
+		result += i * i
+	}
+	return result
+}
+
+// sink keeps the compiler from optimizing work away
+var sink int
+
+// Baseline runs the workload without any instrumentation
+func Baseline(b *testing.B) {
+	instrumentation.InitInstrumentation(nil)
+	for i := 0; i < b.N; i++ {
+		sink += work(5)
+	}
+}
+
+// InstrumentedCalls records a function entry and exit around each call into an in-memory recorder
+func InstrumentedCalls(b *testing.B) {
+	instrumentation.InitInstrumentation(recorder.NewInMemoryRecorder())
+	defer instrumentation.InitInstrumentation(nil)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		instrumentation.FuncEntry("bench.instrumentedCall", "bench.go", 10)
+		sink += work(5)
+		instrumentation.FuncExit("bench.instrumentedCall", "bench.go", 12)
+	}
+}
+
+// GoroutineIDs runs InstrumentedCalls with every event stamped with its
+// goroutine, which costs a stack trace per event
+func GoroutineIDs(b *testing.B) {
+	original := instrumentation.CurrentOptions
+	defer instrumentation.SetInstrumentationOptions(original)
+	options := original
+	options.GoroutineIDs = true
+	instrumentation.SetInstrumentationOptions(options)
+	InstrumentedCalls(b)
+}
+
+// ChannelPingPong bounces a value between two goroutines, recording each send and receive
+func ChannelPingPong(b *testing.B) {
+	instrumentation.InitInstrumentation(recorder.NewInMemoryRecorder())
+	defer instrumentation.InitInstrumentation(nil)
+
+	ping := make(chan int)
+	pong := make(chan int)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for v := range ping {
+			instrumentation.ChannelRecv(1, 2, v)
+			instrumentation.ChannelSend(2, 2, v)
+			pong <- v
+		}
+	}()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		instrumentation.ChannelSend(1, 1, i)
+		ping <- i
+		v := <-pong
+		instrumentation.ChannelRecv(2, 1, v)
+	}
+	b.StopTimer()
+
+	close(ping)
+	<-done
+}
+
+// FileRecording returns a workload that records function entries and exits
+// to a file with the given compression and reports bytes written per event
+func FileRecording(compressionType recorder.CompressionType) func(b *testing.B) {
+	return func(b *testing.B) {
+		f, err := os.CreateTemp("", "chrono_bench_*.events")
+		if err != nil {
+			b.Fatalf("Failed to create temp file: %v", err)
+		}
+		f.Close()
+		defer os.Remove(f.Name())
+
+		fileRecorder, err := recorder.NewFileRecorderWithOptions(f.Name(), recorder.FileRecorderOptions{
+			CompressionType: compressionType,
+		})
+		if err != nil {
+			b.Fatalf("Failed to create file recorder: %v", err)
+		}
+		instrumentation.InitInstrumentation(fileRecorder)
+		defer instrumentation.InitInstrumentation(nil)
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			instrumentation.FuncEntry("bench.recordedCall", "bench.go", 20)
+			sink += work(5)
+			instrumentation.FuncExit("bench.recordedCall", "bench.go", 22)
+		}
+		if err := fileRecorder.Close(); err != nil {
+			b.Fatalf("Failed to close file recorder: %v", err)
+		}
+		b.StopTimer()
+
+		b.ReportMetric(float64(fileRecorder.BytesWritten())/float64(2*b.N), "bytes/event")
+	}
+}
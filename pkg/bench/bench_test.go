@@ -0,0 +1,42 @@
+package bench
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteTable(t *testing.T) {
+	var out bytes.Buffer
+	WriteTable(&out, []Result{
+		{Name: "baseline", NsPerOp: 5},
+		{Name: "file-zstd", EventsPerOp: 2, NsPerOp: 5000, NsPerEvent: 2500, RecorderNs: 1500, BytesPerEvent: 11.5},
+	})
+
+	lines := strings.Split(strings.TrimRight(out.String(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected header and 2 rows, got %d lines:\n%s", len(lines), out.String())
+	}
+	if fields := strings.Fields(lines[1]); fields[len(fields)-1] != "-" {
+		t.Errorf("Expected unmeasured values to be shown as '-', got %q", lines[1])
+	}
+	if fields := strings.Fields(lines[2]); fields[0] != "file-zstd" || fields[len(fields)-1] != "11.5" {
+		t.Errorf("Unexpected row: %q", lines[2])
+	}
+}
+
+func TestWorkloadsRecordEvents(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping benchmark run in short mode")
+	}
+
+	for _, w := range Workloads() {
+		if w.Name == "baseline" {
+			continue
+		}
+		r := Run(w)
+		if r.NsPerEvent <= 0 || r.RecorderNs <= 0 {
+			t.Errorf("%s: expected events to be recorded, got %+v", w.Name, r)
+		}
+	}
+}
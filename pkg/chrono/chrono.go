@@ -0,0 +1,504 @@
+// Package chrono provides a programmatic API for replaying ChronoGo recordings.
+//
+// A Session wraps the replayer, the breakpoint manager and an optional live
+// Delve session behind a single type, so recordings can be explored from
+// tests and tools without going through the interactive CLI:
+//
+//	s, err := chrono.Open("chronogo.events")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer s.Close()
+//
+//	if _, err := s.SetBreakpoint("func:processItem"); err != nil {
+//		log.Fatal(err)
+//	}
+//
+//	event, err := s.Continue()
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	fmt.Println("stopped at", event.Details)
+//	for _, frame := range s.Stack() {
+//		fmt.Println("  in", frame.FuncName)
+//	}
+package chrono
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/willibrandon/ChronoGo/pkg/debugger"
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+	"github.com/willibrandon/ChronoGo/pkg/replay"
+)
+
+var (
+	// ErrEndOfRecording is returned when moving past the last event
+	ErrEndOfRecording = debugger.ErrEndOfRecording
+	// ErrStartOfRecording is returned when moving before the first event
+	ErrStartOfRecording = debugger.ErrStartOfRecording
+	// ErrNoCurrentEvent is returned when replay hasn't started yet
+	ErrNoCurrentEvent = errors.New("no current event")
+)
+
+// Option configures a Session
+type Option func(*options)
+
+type options struct {
+	delveTarget string
+	delveArgs   []string
+	attachPID   int // Running process to attach Delve to, 0 for none
+	security    *recorder.SecurityOptions
+	replay      *replay.ReplayOptions
+	window      *[2]replay.WindowBound // From and to
+}
+
+// WithDelve attaches a live Delve session for the given target binary
+func WithDelve(target string, args ...string) Option {
+	return func(o *options) {
+		o.delveTarget = target
+		o.delveArgs = args
+	}
+}
+
+// WithDelveAttach attaches a live Delve session to the running process pid,
+// typically the one that wrote the recording. Close detaches and leaves the
+// process running.
+func WithDelveAttach(pid int) Option {
+	return func(o *options) {
+		o.attachPID = pid
+	}
+}
+
+// WithSecurity reads a recording written by a SecureFileRecorder, decrypting
+// and verifying events with the given options
+func WithSecurity(opts recorder.SecurityOptions) Option {
+	return func(o *options) {
+		o.security = &opts
+	}
+}
+
+// WithReplayOptions loads the recording with the given replay options, e.g.
+// to replay events in file order without normalizing timestamps
+func WithReplayOptions(opts replay.ReplayOptions) Option {
+	return func(o *options) {
+		o.replay = &opts
+	}
+}
+
+// WithWindow loads only the events from from to to, inclusive, streaming
+// past the rest of the recording, to replay recordings too large to hold in
+// memory. Use replay.Unbounded for to to run to the end. Session indices are
+// relative to the window, see replay.BasicReplayer.Window. Only plain
+// events files can be opened with a window.
+func WithWindow(from, to replay.WindowBound) Option {
+	return func(o *options) {
+		o.window = &[2]replay.WindowBound{from, to}
+	}
+}
+
+// Session is a replay session over a single recording
+type Session struct {
+	replayer    *replay.BasicReplayer
+	breakpoints *debugger.BreakpointManager
+	debugger    *debugger.DelveDebugger
+	segments    []recorder.SegmentBoundary
+	sources     debugger.SourceProvider // Source files of a bundle, nil to read them from disk
+	metadata    *recorder.RecordingMetadata
+	readReport  *recorder.ReadReport // Events of a secure recording skipped while reading it
+	stream      string               // Stream the recording was read from, empty for a file
+}
+
+// Open loads the events file at path and starts a session positioned before
+// the first event. If path is a directory, the segments a FlightRecorder
+// wrote there are replayed as one recording. A bundle written by
+// recorder.Bundle is replayed with the source files it carries. A path of
+// "-" reads the recording from standard input, and a named pipe is read as
+// a stream, as OpenStream does.
+func Open(path string, opts ...Option) (*Session, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if path == "-" {
+		return OpenStream(os.Stdin, "stdin", opts...)
+	}
+	if info, err := os.Stat(path); err == nil && info.Mode()&(os.ModeNamedPipe|os.ModeCharDevice) != 0 {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("error opening events stream: %v", err)
+		}
+		defer f.Close()
+		return OpenStream(f, path, opts...)
+	}
+
+	if o.window != nil {
+		return openWindow(path, o, opts)
+	}
+
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		if o.security != nil {
+			return nil, fmt.Errorf("secure recordings can't be replayed from a segment directory")
+		}
+		events, segments, err := recorder.ReadSegmentDir(path)
+		if err != nil {
+			return nil, err
+		}
+		s, err := OpenEvents(events, opts...)
+		if err != nil {
+			return nil, err
+		}
+		s.segments = segments
+		if len(segments) > 0 {
+			s.metadata = readMetadata(segments[0].Path, o.security)
+		}
+		return s, nil
+	}
+
+	if recorder.IsBundle(path) {
+		if o.security != nil {
+			return nil, fmt.Errorf("secure recordings can't be replayed from a bundle")
+		}
+		bundle, err := recorder.OpenBundle(path)
+		if err != nil {
+			return nil, err
+		}
+		s, err := OpenEvents(bundle.Events, opts...)
+		if err != nil {
+			return nil, err
+		}
+		s.sources = bundle
+		s.metadata = bundle.Metadata
+		return s, nil
+	}
+
+	var events []recorder.Event
+	var report *recorder.ReadReport
+	var err error
+	if o.security != nil {
+		events, report, err = recorder.ReadSecureEventsFileWithReport(path, *o.security)
+	} else {
+		events, err = recorder.ReadEventsFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s, err := OpenEvents(events, opts...)
+	if err != nil {
+		return nil, err
+	}
+	s.metadata = readMetadata(path, o.security)
+	s.readReport = report
+	return s, nil
+}
+
+// openWindow opens the window o selects of the events file at path
+func openWindow(path string, o options, opts []Option) (*Session, error) {
+	if info, err := os.Stat(path); err == nil && info.IsDir() || recorder.IsBundle(path) || o.security != nil {
+		return nil, fmt.Errorf("only plain events files can be replayed in a window")
+	}
+	window, err := replay.ScanEventWindow(func(fn func(recorder.Event) error) error {
+		return recorder.ScanEventsFile(path, fn)
+	}, o.window[0], o.window[1])
+	if err != nil {
+		return nil, err
+	}
+	s, err := newSession(func(r *replay.BasicReplayer) error { return r.LoadWindow(window) }, opts)
+	if err != nil {
+		return nil, err
+	}
+	s.metadata = readMetadata(path, nil)
+	return s, nil
+}
+
+// OpenStream reads a recording from r, which need not be seekable, and
+// starts a session over it, naming the stream name. The recording is
+// spooled, in memory or beyond recorder.DefaultSpoolMemory in a temporary
+// file, so that its compression can be detected and it can be read more
+// than once. Windows can be opened, but secure recordings, segment
+// directories and bundles can't be read from a stream.
+func OpenStream(r io.Reader, name string, opts ...Option) (*Session, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.security != nil {
+		return nil, fmt.Errorf("secure recordings can't be replayed from a stream")
+	}
+
+	spool, err := recorder.SpoolStream(r, recorder.DefaultSpoolMemory)
+	if err != nil {
+		return nil, err
+	}
+	defer spool.Close()
+
+	var s *Session
+	if o.window != nil {
+		window, err := replay.ScanEventWindow(spool.ScanEvents, o.window[0], o.window[1])
+		if err != nil {
+			return nil, err
+		}
+		s, err = newSession(func(r *replay.BasicReplayer) error { return r.LoadWindow(window) }, opts)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		var events []recorder.Event
+		err := spool.ScanEvents(func(e recorder.Event) error {
+			events = append(events, e)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if s, err = OpenEvents(events, opts...); err != nil {
+			return nil, err
+		}
+	}
+	if s.metadata, err = spool.Metadata(); err != nil {
+		fmt.Printf("Warning: Failed to read recording metadata: %v\n", err)
+	}
+	s.stream = name
+	return s, nil
+}
+
+// readMetadata returns the metadata of the events file at path, or nil if
+// it has none or it can't be read
+func readMetadata(path string, security *recorder.SecurityOptions) *recorder.RecordingMetadata {
+	var md *recorder.RecordingMetadata
+	var err error
+	if security != nil {
+		md, err = recorder.ReadSecureMetadata(path, *security)
+	} else {
+		md, err = recorder.ReadMetadata(path)
+	}
+	if err != nil {
+		fmt.Printf("Warning: Failed to read recording metadata: %v\n", err)
+	}
+	return md
+}
+
+// OpenEvents starts a session over already loaded events
+func OpenEvents(events []recorder.Event, opts ...Option) (*Session, error) {
+	return newSession(func(r *replay.BasicReplayer) error { return r.LoadEvents(events) }, opts)
+}
+
+// newSession starts a session over the events load loads into its replayer
+func newSession(load func(*replay.BasicReplayer) error, opts []Option) (*Session, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	replayOpts := replay.DefaultReplayOptions()
+	if o.replay != nil {
+		replayOpts = *o.replay
+	}
+	s := &Session{
+		replayer:    replay.NewBasicReplayerWithOptions(replayOpts),
+		breakpoints: debugger.NewBreakpointManager(),
+	}
+	if err := load(s.replayer); err != nil {
+		return nil, err
+	}
+	s.breakpoints.SetRecordedLocations(s.replayer.Events())
+
+	var dbg *debugger.DelveDebugger
+	var err error
+	switch {
+	case o.attachPID != 0:
+		dbg, err = debugger.NewDelveDebuggerAttach(o.attachPID)
+	case o.delveTarget != "":
+		dbg, err = debugger.NewDelveDebuggerWithArgs(o.delveTarget, o.delveArgs)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach delve: %v", err)
+	}
+	if dbg != nil {
+		s.debugger = dbg
+		s.breakpoints.SetBackend(dbg)
+	}
+
+	return s, nil
+}
+
+// Events returns all events in the recording
+func (s *Session) Events() []recorder.Event {
+	return s.replayer.Events()
+}
+
+// ReadReport returns the events of a secure recording that were skipped
+// because they couldn't be decrypted, verified or parsed, nil for other
+// recordings
+func (s *Session) ReadReport() *recorder.ReadReport {
+	return s.readReport
+}
+
+// ClockSkew returns how far timestamps went backward in the recording
+func (s *Session) ClockSkew() replay.ClockSkew {
+	return s.replayer.ClockSkew()
+}
+
+// CurrentIndex returns the index of the current event, or -1 before the first step
+func (s *Session) CurrentIndex() int {
+	return s.replayer.CurrentIndex()
+}
+
+// CurrentEvent returns the current event
+func (s *Session) CurrentEvent() (recorder.Event, error) {
+	idx := s.replayer.CurrentIndex()
+	events := s.replayer.Events()
+	if idx < 0 || idx >= len(events) {
+		return recorder.Event{}, ErrNoCurrentEvent
+	}
+	return events[idx], nil
+}
+
+// Step moves forward one event
+func (s *Session) Step() (recorder.Event, error) {
+	if _, err := debugger.StepForward(s.replayer, 1); err != nil {
+		return recorder.Event{}, err
+	}
+	return s.CurrentEvent()
+}
+
+// StepBack moves backward one event
+func (s *Session) StepBack() (recorder.Event, error) {
+	if _, err := debugger.StepBackward(s.replayer, 1); err != nil {
+		return recorder.Event{}, err
+	}
+	return s.CurrentEvent()
+}
+
+// GotoIndex moves to the event at idx
+func (s *Session) GotoIndex(idx int) (recorder.Event, error) {
+	if err := debugger.GotoIndex(s.replayer, idx); err != nil {
+		return recorder.Event{}, err
+	}
+	return s.CurrentEvent()
+}
+
+// GotoTime moves to the last event recorded at or before t
+func (s *Session) GotoTime(t time.Time) (recorder.Event, error) {
+	if _, err := debugger.GotoTime(s.replayer, t); err != nil {
+		return recorder.Event{}, err
+	}
+	return s.CurrentEvent()
+}
+
+// SetBreakpoint adds a breakpoint using the same location syntax as the CLI:
+// "file:line", "func:<name>" or an event type name such as "FunctionEntry".
+// Shorthand such as "processData" or "payment.go:20" is resolved against the
+// recording, see debugger.BreakpointManager.ResolveLocation. When a Delve
+// session is attached, location and function breakpoints are also set in the
+// live process.
+func (s *Session) SetBreakpoint(location string) (*debugger.Breakpoint, error) {
+	return s.breakpoints.AddBreakpoint(location)
+}
+
+// Breakpoints returns all breakpoints in the session
+func (s *Session) Breakpoints() []*debugger.Breakpoint {
+	return s.breakpoints.GetBreakpoints()
+}
+
+// Continue moves forward to the next event that hits a breakpoint or
+// watchpoint and returns it. Unlike the CLI's continue command it doesn't
+// print the events in between or pause on each. If no breakpoint is hit, the
+// session stops at the last event and ErrEndOfRecording is returned.
+func (s *Session) Continue() (recorder.Event, error) {
+	events := s.replayer.Events()
+	idx, _ := debugger.NextHit(events, s.replayer.CurrentIndex(), s.breakpoints)
+	if idx < 0 {
+		if len(events) > 0 {
+			if err := debugger.GotoIndex(s.replayer, len(events)-1); err != nil {
+				return recorder.Event{}, err
+			}
+		}
+		return recorder.Event{}, ErrEndOfRecording
+	}
+	if err := debugger.GotoIndex(s.replayer, idx); err != nil {
+		return recorder.Event{}, err
+	}
+	return s.CurrentEvent()
+}
+
+// Stack returns the call stack at the current event, outermost call first
+func (s *Session) Stack() []recorder.Event {
+	return replay.CallStack(s.replayer.Events(), s.replayer.CurrentIndex())
+}
+
+// Search returns the indices of all events whose details, function name or
+// file contain query, ignoring case
+func (s *Session) Search(query string) []int {
+	query = strings.ToLower(query)
+
+	var matches []int
+	for i, e := range s.replayer.Events() {
+		if strings.Contains(strings.ToLower(e.Details), query) ||
+			strings.Contains(strings.ToLower(e.FuncName), query) ||
+			strings.Contains(strings.ToLower(e.File), query) {
+			matches = append(matches, i)
+		}
+	}
+	return matches
+}
+
+// Replayer returns the underlying replayer, for use with the interactive CLI
+func (s *Session) Replayer() *replay.BasicReplayer {
+	return s.replayer
+}
+
+// Debugger returns the attached Delve session, or nil if none is attached
+func (s *Session) Debugger() *debugger.DelveDebugger {
+	return s.debugger
+}
+
+// CLI returns an interactive CLI that operates on this session's replayer,
+// breakpoints and Delve attachment
+func (s *Session) CLI() *debugger.CLI {
+	cli := debugger.NewCLIWithBreakpoints(s.replayer, s.debugger, s.breakpoints)
+	cli.SetSegments(s.segments)
+	if s.sources != nil {
+		cli.SetSources(s.sources)
+	}
+	cli.SetMetadata(s.metadata)
+	return cli
+}
+
+// Metadata returns the build and environment the recording was made in, or
+// nil for recordings written before metadata was recorded
+func (s *Session) Metadata() *recorder.RecordingMetadata {
+	return s.metadata
+}
+
+// Stream returns the name of the stream the recording was read from, or ""
+// if it was read from a file. Features that read the events file again,
+// such as inspect --raw, are unavailable for streamed recordings.
+func (s *Session) Stream() string {
+	return s.stream
+}
+
+// Segments returns where each segment starts when the recording was opened
+// from a segment directory, or nil for a single events file
+func (s *Session) Segments() []recorder.SegmentBoundary {
+	return s.segments
+}
+
+// Close releases the session, terminating any attached Delve process
+func (s *Session) Close() error {
+	err := s.replayer.Close()
+	if s.debugger != nil {
+		s.breakpoints.SetBackend(nil)
+		if dbgErr := s.debugger.Close(); dbgErr != nil {
+			err = dbgErr
+		}
+		s.debugger = nil
+	}
+	return err
+}
@@ -0,0 +1,341 @@
+package chrono
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+	"github.com/willibrandon/ChronoGo/pkg/replay"
+)
+
+// writeRecording records a small program run to a compressed events file
+func writeRecording(t *testing.T) (string, []recorder.Event) {
+	t.Helper()
+
+	base := time.Now()
+	events := []recorder.Event{
+		{ID: 1, Timestamp: base, Type: recorder.FuncEntry, Details: "Entering main", FuncName: "main", File: "main.go", Line: 5},
+		{ID: 2, Timestamp: base.Add(1 * time.Millisecond), Type: recorder.FuncEntry, Details: "Entering processItem", FuncName: "processItem", File: "main.go", Line: 20},
+		{ID: 3, Timestamp: base.Add(2 * time.Millisecond), Type: recorder.StatementExecution, Details: "total = 42", FuncName: "processItem", File: "main.go", Line: 22},
+		{ID: 4, Timestamp: base.Add(3 * time.Millisecond), Type: recorder.FuncExit, Details: "Exiting processItem", FuncName: "processItem", File: "main.go", Line: 25},
+		{ID: 5, Timestamp: base.Add(4 * time.Millisecond), Type: recorder.StatementExecution, Details: "print total", FuncName: "main", File: "main.go", Line: 8},
+		{ID: 6, Timestamp: base.Add(5 * time.Millisecond), Type: recorder.FuncExit, Details: "Exiting main", FuncName: "main", File: "main.go", Line: 9},
+	}
+
+	path := filepath.Join(t.TempDir(), "session.events")
+	rec, err := recorder.NewFileRecorder(path)
+	if err != nil {
+		t.Fatalf("Failed to create file recorder: %v", err)
+	}
+	for _, e := range events {
+		if err := rec.RecordEvent(e); err != nil {
+			t.Fatalf("Failed to record event: %v", err)
+		}
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatalf("Failed to close recorder: %v", err)
+	}
+
+	return path, events
+}
+
+func TestScriptedSession(t *testing.T) {
+	path, events := writeRecording(t)
+
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open session: %v", err)
+	}
+	defer s.Close()
+
+	if len(s.Events()) != len(events) {
+		t.Fatalf("Expected %d events, got %d", len(events), len(s.Events()))
+	}
+
+	// Nothing is current before the first step
+	if _, err := s.CurrentEvent(); err != ErrNoCurrentEvent {
+		t.Errorf("Expected ErrNoCurrentEvent, got %v", err)
+	}
+
+	// Step onto the first event
+	e, err := s.Step()
+	if err != nil || e.ID != 1 {
+		t.Fatalf("Expected to step to event 1, got %d (%v)", e.ID, err)
+	}
+
+	// Continue to a location breakpoint
+	if _, err := s.SetBreakpoint("main.go:22"); err != nil {
+		t.Fatalf("Failed to set breakpoint: %v", err)
+	}
+	e, err = s.Continue()
+	if err != nil {
+		t.Fatalf("Continue failed: %v", err)
+	}
+	if e.ID != 3 {
+		t.Errorf("Expected breakpoint hit at event 3, got %d", e.ID)
+	}
+
+	// The stack shows main calling processItem
+	stack := s.Stack()
+	if len(stack) != 2 || stack[0].FuncName != "main" || stack[1].FuncName != "processItem" {
+		t.Errorf("Unexpected stack: %+v", stack)
+	}
+
+	// Step back and forward again
+	if e, err := s.StepBack(); err != nil || e.ID != 2 {
+		t.Errorf("Expected to step back to event 2, got %d (%v)", e.ID, err)
+	}
+	if e, err := s.Step(); err != nil || e.ID != 3 {
+		t.Errorf("Expected to step to event 3, got %d (%v)", e.ID, err)
+	}
+
+	// With no more breakpoints ahead, continue runs to the end
+	if _, err := s.Continue(); err != ErrEndOfRecording {
+		t.Errorf("Expected ErrEndOfRecording, got %v", err)
+	}
+	if s.CurrentIndex() != len(events)-1 {
+		t.Errorf("Expected to stop at the last event, got index %d", s.CurrentIndex())
+	}
+	if len(s.Stack()) != 0 {
+		t.Errorf("Expected empty stack after main exits, got %d frames", len(s.Stack()))
+	}
+	if _, err := s.Step(); err != ErrEndOfRecording {
+		t.Errorf("Expected ErrEndOfRecording stepping past the end, got %v", err)
+	}
+
+	// Jump by index and by time
+	if e, err := s.GotoIndex(0); err != nil || e.ID != 1 {
+		t.Errorf("Expected GotoIndex(0) to reach event 1, got %d (%v)", e.ID, err)
+	}
+	if _, err := s.StepBack(); err != ErrStartOfRecording {
+		t.Errorf("Expected ErrStartOfRecording, got %v", err)
+	}
+	if e, err := s.GotoTime(events[3].Timestamp.Add(500 * time.Microsecond)); err != nil || e.ID != 4 {
+		t.Errorf("Expected GotoTime to reach event 4, got %d (%v)", e.ID, err)
+	}
+	if _, err := s.GotoTime(events[0].Timestamp.Add(-time.Second)); err == nil {
+		t.Error("Expected error for a time before the recording")
+	}
+
+	// Search matches details and function names case-insensitively
+	matches := s.Search("PROCESSITEM")
+	if len(matches) != 3 || matches[0] != 1 || matches[2] != 3 {
+		t.Errorf("Unexpected search results: %v", matches)
+	}
+}
+
+func TestContinueIsQuiet(t *testing.T) {
+	path, events := writeRecording(t)
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open session: %v", err)
+	}
+	defer s.Close()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	if _, err := s.SetBreakpoint("main.go:22"); err != nil {
+		t.Fatalf("Failed to set breakpoint: %v", err)
+	}
+	hit, hitErr := s.Continue()
+	_, endErr := s.Continue()
+
+	os.Stdout = stdout
+	w.Close()
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if hitErr != nil || hit.ID != 3 {
+		t.Errorf("Expected breakpoint hit at event 3, got %d (%v)", hit.ID, hitErr)
+	}
+	if endErr != ErrEndOfRecording || s.CurrentIndex() != len(events)-1 {
+		t.Errorf("Expected ErrEndOfRecording at the last event, got %v at %d", endErr, s.CurrentIndex())
+	}
+	if len(out) != 0 {
+		t.Errorf("Expected Continue to write nothing to stdout, got %q", out)
+	}
+}
+
+func TestSkewedSession(t *testing.T) {
+	base := time.Now()
+	offsets := []time.Duration{0, 30, 10, 20, 40} // Milliseconds; the clock jumped ahead then back
+	var events []recorder.Event
+	for i, offset := range offsets {
+		events = append(events, recorder.Event{ID: int64(i + 1), Timestamp: base.Add(offset * time.Millisecond),
+			Type: recorder.StatementExecution, Details: "step"})
+	}
+
+	s, err := OpenEvents(events)
+	if err != nil {
+		t.Fatalf("Failed to open events: %v", err)
+	}
+	if skew := s.ClockSkew(); skew.Events != 2 || skew.Max != 20*time.Millisecond {
+		t.Errorf("Expected 2 events skewed by up to 20ms, got %+v", skew)
+	}
+	if e, err := s.GotoTime(base.Add(25 * time.Millisecond)); err != nil || e.ID != 4 {
+		t.Errorf("Expected GotoTime to reach event 4, got %d (%v)", e.ID, err)
+	}
+
+	ordered, err := OpenEvents(events, WithReplayOptions(replay.ReplayOptions{NormalizeTimestamps: false}))
+	if err != nil {
+		t.Fatalf("Failed to open events: %v", err)
+	}
+	if ordered.Events()[1].ID != 2 {
+		t.Errorf("Expected events in the order given, got %+v", ordered.Events())
+	}
+}
+
+func TestOpenMissingFile(t *testing.T) {
+	if _, err := Open(filepath.Join(t.TempDir(), "missing.events")); err == nil {
+		t.Error("Expected error opening a missing events file")
+	}
+}
+
+func TestOpenSegmentDirectory(t *testing.T) {
+	dir := t.TempDir()
+	fr, err := recorder.NewFlightRecorder(dir, recorder.FlightOptions{
+		SegmentSize:     1, // One event per segment
+		MaxSegments:     10,
+		CompressionType: recorder.NoCompression,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create flight recorder: %v", err)
+	}
+	_, events := writeRecording(t)
+	for _, e := range events {
+		if err := fr.RecordEvent(e); err != nil {
+			t.Fatalf("Failed to record event: %v", err)
+		}
+	}
+	if err := fr.Close(); err != nil {
+		t.Fatalf("Failed to close flight recorder: %v", err)
+	}
+
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Failed to open segment directory: %v", err)
+	}
+	defer s.Close()
+
+	if len(s.Events()) != len(events) {
+		t.Fatalf("Expected %d events, got %d", len(events), len(s.Events()))
+	}
+	if len(s.Segments()) != len(events) {
+		t.Errorf("Expected %d segments, got %d", len(events), len(s.Segments()))
+	}
+
+	// Stepping crosses segment boundaries like any other event
+	for i := range events {
+		event, err := s.Step()
+		if err != nil {
+			t.Fatalf("Failed to step to event %d: %v", i, err)
+		}
+		if event.ID != events[i].ID {
+			t.Errorf("Expected event %d, got %d", events[i].ID, event.ID)
+		}
+	}
+}
+
+func TestOpenWindow(t *testing.T) {
+	path, events := writeRecording(t)
+
+	s, err := Open(path, WithWindow(replay.WindowBound{Index: 1}, replay.WindowBound{Index: 3}))
+	if err != nil {
+		t.Fatalf("Failed to open window: %v", err)
+	}
+	defer s.Close()
+
+	if len(s.Events()) != 3 || s.Events()[0].ID != events[1].ID {
+		t.Fatalf("Expected events 1-3, got %+v", s.Events())
+	}
+	if offset, total := s.Replayer().Window(); offset != 1 || total != len(events) {
+		t.Errorf("Expected window 1 of %d, got %d of %d", len(events), offset, total)
+	}
+	if _, err := s.SetBreakpoint("func:processItem"); err != nil {
+		t.Fatalf("Failed to set breakpoint: %v", err)
+	}
+	if event, err := s.Continue(); err != nil || event.ID != 2 {
+		t.Errorf("Expected to stop at event ID 2, got %+v, %v", event, err)
+	}
+
+	if _, err := Open(filepath.Dir(path), WithWindow(replay.WindowBound{}, replay.Unbounded)); err == nil {
+		t.Error("Expected an error opening a segment directory in a window")
+	}
+}
+
+func TestOpenStdin(t *testing.T) {
+	_, events := writeRecording(t)
+	path := filepath.Join(t.TempDir(), "piped.events")
+	options := recorder.DefaultFileRecorderOptions()
+	options.CompressionType = recorder.GzipCompression
+	rec, err := recorder.NewFileRecorderWithOptions(path, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range events {
+		if err := rec.RecordEvent(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Pipe the gzip'd recording through stdin, left compressed
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdin := os.Stdin
+	os.Stdin = r
+	defer func() {
+		os.Stdin = stdin
+		r.Close()
+	}()
+	go func() {
+		w.Write(data)
+		w.Close()
+	}()
+
+	s, err := Open("-")
+	if err != nil {
+		t.Fatalf("Failed to open stdin: %v", err)
+	}
+	defer s.Close()
+
+	if s.Stream() != "stdin" {
+		t.Errorf("Expected the session to be streamed from stdin, got %q", s.Stream())
+	}
+	if len(s.Events()) != len(events) {
+		t.Fatalf("Expected %d events, got %d", len(events), len(s.Events()))
+	}
+	if s.Metadata() == nil {
+		t.Error("Expected the recording's metadata")
+	}
+	if _, err := s.GotoIndex(3); err != nil {
+		t.Fatal(err)
+	}
+	if event, err := s.StepBack(); err != nil || event.ID != events[2].ID {
+		t.Errorf("Expected to step back to event ID %d, got %+v, %v", events[2].ID, event, err)
+	}
+
+	if _, err := OpenStream(bytes.NewReader(data), "test", WithSecurity(recorder.DefaultSecurityOptions())); err == nil {
+		t.Error("Expected an error opening a secure recording from a stream")
+	}
+}
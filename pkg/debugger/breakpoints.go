@@ -2,8 +2,15 @@ package debugger
 
 import (
 	"fmt"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+
+	"github.com/go-delve/delve/service/api"
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+	"github.com/willibrandon/ChronoGo/pkg/replay"
 )
 
 // BreakpointType defines the type of breakpoint
@@ -34,13 +41,155 @@ type Breakpoint struct {
 	EventType  string // For EventTypeBreakpoint
 	Expression string // For Watchpoint: the expression to watch
 	Address    uint64 // For Watchpoint: the memory address to watch (if resolved)
+	Condition  string // For LocationBreakpoint: only stop in Delve when this is true
 	Enabled    bool
+	DelveID    int // ID of the matching breakpoint in Delve, 0 if it is replay-only
+}
+
+// BreakpointBackend sets breakpoints in a live debugger. DelveDebugger
+// implements it; tests can substitute a fake.
+type BreakpointBackend interface {
+	SetBreakpoint(file string, line int) (*api.Breakpoint, error)
+	SetConditionalBreakpoint(file string, line int, condition string) (*api.Breakpoint, error)
+	SetFunctionBreakpoint(funcName string) (*api.Breakpoint, error)
+	SetWatchpoint(expr string, readFlag, writeFlag bool) (*api.Breakpoint, error)
+	ClearBreakpoint(id int) error
+	SetBreakpointEnabled(id int, enabled bool) error
+}
+
+// functionLister is implemented by backends that can list the target's
+// functions, such as DelveDebugger, to widen function name resolution
+type functionLister interface {
+	ListFunctions(filter string) ([]string, error)
+}
+
+// AmbiguousLocationError is returned when a shorthand location matches more
+// than one recorded or live function or file
+type AmbiguousLocationError struct {
+	Location   string
+	Candidates []string // Full locations the shorthand matched, sorted
+}
+
+func (e *AmbiguousLocationError) Error() string {
+	return fmt.Sprintf("%s is ambiguous, it matches:\n  %s", e.Location, strings.Join(e.Candidates, "\n  "))
+}
+
+// Matches reports whether the breakpoint is enabled and should stop replay at the given event
+func (bp *Breakpoint) Matches(event recorder.Event) bool {
+	if !bp.Enabled {
+		return false
+	}
+
+	switch bp.Type {
+	case LocationBreakpoint:
+		if event.File == "" || event.Line <= 0 {
+			return false
+		}
+		if bp.Line != event.Line {
+			return false
+		}
+		ok, _ := replay.HostPaths.Match(bp.File, event.File)
+		return ok
+	case FunctionBreakpoint:
+		return event.Type == recorder.FuncEntry &&
+			(strings.Contains(event.Details, bp.Function) ||
+				(event.FuncName != "" && (strings.Contains(event.FuncName, bp.Function) ||
+					FuncNameMatches(event.FuncName, bp.Function))))
+	case EventTypeBreakpoint:
+		return event.Type.String() == bp.EventType
+	case WatchpointWrite, WatchpointReadWrite:
+		// Recordings only hold writes, as assignments to named variables
+		if event.Type != recorder.VarAssignment {
+			return false
+		}
+		name, _, ok := replay.ParseAssignment(event.Details)
+		return ok && name == bp.Expression
+	}
+	return false
+}
+
+// NormalizeFuncName returns a function name in one form whichever way it was
+// written, so that Delve's "pkg/path.(*T).M", the runtime's "pkg/path.T.M"
+// and a user's "(*T).M" compare equal: the package path before the package
+// name, pointer receiver markers, type parameters and the "-fm" suffix of
+// method values are dropped, e.g. "pkg.T.M"
+func NormalizeFuncName(name string) string {
+	name = strings.TrimSpace(name)
+	name = strings.ReplaceAll(name, "[...]", "")
+	name = strings.TrimSuffix(name, "-fm")
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.ReplaceAll(name, "(*", "")
+	return strings.ReplaceAll(name, ")", "")
+}
+
+// FuncNameMatches reports whether a recorded or Delve function name is the
+// function a user named. The user's name may leave out the package and
+// receiver, e.g. "Handle" or "Server.Handle" match "main.(*Server).Handle".
+func FuncNameMatches(name, target string) bool {
+	name, target = NormalizeFuncName(name), NormalizeFuncName(target)
+	if name == "" || target == "" {
+		return false
+	}
+	return name == target || strings.HasSuffix(name, "."+target)
 }
 
-// BreakpointManager manages breakpoints for the debugger
+// IsWatchpoint reports whether the breakpoint watches an expression
+func (bp *Breakpoint) IsWatchpoint() bool {
+	return bp.Type == WatchpointRead || bp.Type == WatchpointWrite || bp.Type == WatchpointReadWrite
+}
+
+// BreakpointChange describes what happened to a breakpoint
+type BreakpointChange int
+
+const (
+	// BreakpointAdded is sent when a breakpoint or watchpoint is added
+	BreakpointAdded BreakpointChange = iota
+	// BreakpointRemoved is sent when a breakpoint is removed
+	BreakpointRemoved
+	// BreakpointEnabled is sent when a breakpoint is enabled
+	BreakpointEnabled
+	// BreakpointDisabled is sent when a breakpoint is disabled
+	BreakpointDisabled
+)
+
+// String returns a human-readable name for the change
+func (c BreakpointChange) String() string {
+	switch c {
+	case BreakpointAdded:
+		return "added"
+	case BreakpointRemoved:
+		return "removed"
+	case BreakpointEnabled:
+		return "enabled"
+	case BreakpointDisabled:
+		return "disabled"
+	default:
+		return "unknown"
+	}
+}
+
+// BreakpointEvent is sent to OnChange listeners when a breakpoint changes
+type BreakpointEvent struct {
+	Change     BreakpointChange
+	Breakpoint Breakpoint // Copy of the breakpoint after the change
+}
+
+// BreakpointManager manages breakpoints for the debugger. It is safe for
+// concurrent use; breakpoints returned from it are copies.
+//
+// When a backend is attached, every change is applied to it as well. The
+// backend is changed first, so a change it rejects leaves both sides as they
+// were and returns the error.
 type BreakpointManager struct {
+	mu          sync.RWMutex
 	breakpoints []*Breakpoint
 	nextID      int
+	listeners   []func(BreakpointEvent)
+	backend     BreakpointBackend
+	functions   []string // Distinct function names in the recording
+	files       []string // Distinct files in the recording
 }
 
 // NewBreakpointManager creates a new breakpoint manager
@@ -51,13 +200,262 @@ func NewBreakpointManager() *BreakpointManager {
 	}
 }
 
+// OnChange registers fn to be called after every breakpoint change. Listeners
+// are called outside the manager's lock, in registration order, so they may
+// call back into the manager.
+func (bm *BreakpointManager) OnChange(fn func(BreakpointEvent)) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	bm.listeners = append(bm.listeners, fn)
+}
+
+// notify sends events to all listeners
+func (bm *BreakpointManager) notify(events ...BreakpointEvent) {
+	bm.mu.RLock()
+	listeners := append([]func(BreakpointEvent){}, bm.listeners...)
+	bm.mu.RUnlock()
+
+	for _, event := range events {
+		for _, fn := range listeners {
+			fn(event)
+		}
+	}
+}
+
+// SetBackend attaches the live debugger that breakpoints are mirrored to, or
+// detaches it when backend is nil. Breakpoints already in the manager are set
+// in the new backend; ones it rejects stay replay-only with a warning.
+func (bm *BreakpointManager) SetBackend(backend BreakpointBackend) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+
+	if backend == bm.backend {
+		return
+	}
+	bm.backend = backend
+
+	// Delve IDs belong to the previous backend
+	for _, bp := range bm.breakpoints {
+		bp.DelveID = 0
+		if backend == nil {
+			continue
+		}
+		if err := bm.setInBackend(bp); err != nil {
+			fmt.Printf("Warning: Could not set breakpoint %d in Delve: %v\n", bp.ID, err)
+		}
+	}
+}
+
+// setInBackend sets bp in the backend and records its Delve ID. Event type
+// breakpoints only apply to replay and are left alone. Callers hold bm.mu.
+func (bm *BreakpointManager) setInBackend(bp *Breakpoint) error {
+	var dbp *api.Breakpoint
+	var err error
+	switch bp.Type {
+	case LocationBreakpoint:
+		if bp.Condition != "" {
+			dbp, err = bm.backend.SetConditionalBreakpoint(bp.File, bp.Line, bp.Condition)
+		} else {
+			dbp, err = bm.backend.SetBreakpoint(bp.File, bp.Line)
+		}
+	case FunctionBreakpoint:
+		dbp, err = bm.backend.SetFunctionBreakpoint(bp.Function)
+	case WatchpointRead, WatchpointWrite, WatchpointReadWrite:
+		dbp, err = bm.backend.SetWatchpoint(bp.Expression,
+			bp.Type != WatchpointWrite, bp.Type != WatchpointRead)
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	bp.DelveID = dbp.ID
+
+	if !bp.Enabled {
+		if err := bm.backend.SetBreakpointEnabled(dbp.ID, false); err != nil {
+			_ = bm.clearInBackend(bp)
+			return err
+		}
+	}
+	return nil
+}
+
+// clearInBackend removes bp from the backend, if it was set there. Callers hold bm.mu.
+func (bm *BreakpointManager) clearInBackend(bp *Breakpoint) error {
+	if bm.backend == nil || bp.DelveID == 0 {
+		return nil
+	}
+	if err := bm.backend.ClearBreakpoint(bp.DelveID); err != nil {
+		return fmt.Errorf("failed to clear Delve breakpoint %d: %v", bp.DelveID, err)
+	}
+	bp.DelveID = 0
+	return nil
+}
+
+// SetRecordedLocations sets the functions and files that shorthand
+// locations are resolved against from the events of a recording
+func (bm *BreakpointManager) SetRecordedLocations(events []recorder.Event) {
+	funcs := make(map[string]bool)
+	files := make(map[string]bool)
+	for _, event := range events {
+		if event.FuncName != "" {
+			funcs[event.FuncName] = true
+		}
+		if event.File != "" {
+			files[event.File] = true
+		}
+	}
+
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	bm.functions = sortedKeys(funcs)
+	bm.files = sortedKeys(files)
+}
+
+// sortedKeys returns the keys of a set in order
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ResolveLocation expands a shorthand location to the one it names in the
+// recording, or in the live process when the backend can list functions:
+//
+//   - a bare name that isn't an event type, e.g. "processData", becomes
+//     "func:main.processData"; names nothing matches are left as event types
+//   - "func:processData" becomes "func:main.processData"
+//   - "payment.go:20" becomes "/src/shop/payment.go:20"
+//
+// Locations that match nothing known are returned unchanged, so Delve can
+// still try them. A shorthand matching several functions or files returns an
+// *AmbiguousLocationError listing them.
+func (bm *BreakpointManager) ResolveLocation(location string) (string, error) {
+	if name, ok := strings.CutPrefix(location, "func:"); ok {
+		fn, err := bm.resolveFunction(name)
+		if err != nil || fn == "" {
+			return location, err
+		}
+		return "func:" + fn, nil
+	}
+
+	if i := strings.LastIndex(location, ":"); i >= 0 {
+		file, err := bm.resolveFile(location[:i])
+		if err != nil || file == "" {
+			return location, err
+		}
+		return file + location[i:], nil
+	}
+
+	if _, ok := recorder.ParseEventType(location); ok {
+		return location, nil
+	}
+	fn, err := bm.resolveFunction(location)
+	if err != nil || fn == "" {
+		return location, err
+	}
+	return "func:" + fn, nil
+}
+
+// resolveFunction returns the one recorded or live function name matches,
+// "" if none does. An exact match wins over suffix matches.
+func (bm *BreakpointManager) resolveFunction(name string) (string, error) {
+	bm.mu.RLock()
+	names := append([]string(nil), bm.functions...)
+	lister, _ := bm.backend.(functionLister)
+	bm.mu.RUnlock()
+
+	if lister != nil {
+		live, err := lister.ListFunctions(regexp.QuoteMeta(NormalizeFuncName(name)))
+		if err != nil {
+			fmt.Printf("Warning: Could not list functions in Delve: %v\n", err)
+		}
+		names = append(names, live...)
+	}
+
+	// Forms of one function, e.g. "main.(*T).M" from Delve and "main.T.M"
+	// from the runtime, count once
+	byForm := make(map[string]string)
+	for _, fn := range names {
+		if fn == name {
+			return fn, nil
+		}
+		if !FuncNameMatches(fn, name) {
+			continue
+		}
+		form := NormalizeFuncName(fn)
+		if i := strings.LastIndex(fn, "/"); i >= 0 {
+			form = fn[:i+1] + form
+		}
+		if _, ok := byForm[form]; !ok {
+			byForm[form] = fn
+		}
+	}
+	return unique(name, byForm)
+}
+
+// resolveFile returns the recorded file that file is another spelling of,
+// or else the one recorded file whose path ends in file, "" if none does or
+// file is already a recorded path
+func (bm *BreakpointManager) resolveFile(file string) (string, error) {
+	bm.mu.RLock()
+	defer bm.mu.RUnlock()
+
+	matches := make(map[string]string)
+	for _, recorded := range bm.files {
+		if recorded == file {
+			return "", nil
+		}
+		ok, bySuffix := replay.HostPaths.Match(recorded, file)
+		if ok && !bySuffix {
+			return recorded, nil
+		}
+		if ok {
+			matches[replay.HostPaths.Normalize(recorded)] = recorded
+		}
+	}
+	return unique(file, matches)
+}
+
+// unique returns the only value in matches, "" when it is empty, or an
+// *AmbiguousLocationError listing the values
+func unique(location string, matches map[string]string) (string, error) {
+	if len(matches) == 0 {
+		return "", nil
+	}
+	candidates := make([]string, 0, len(matches))
+	for _, match := range matches {
+		candidates = append(candidates, match)
+	}
+	if len(candidates) == 1 {
+		return candidates[0], nil
+	}
+	sort.Strings(candidates)
+	return "", &AmbiguousLocationError{Location: location, Candidates: candidates}
+}
+
 // AddBreakpoint adds a breakpoint at the specified location
 func (bm *BreakpointManager) AddBreakpoint(location string) (*Breakpoint, error) {
+	return bm.AddConditionalBreakpoint(location, "")
+}
+
+// AddConditionalBreakpoint adds a breakpoint at the specified location that
+// only stops the live process when condition is true. Replay ignores the
+// condition. An empty condition adds a plain breakpoint. Shorthand locations
+// are expanded with ResolveLocation first.
+func (bm *BreakpointManager) AddConditionalBreakpoint(location, condition string) (*Breakpoint, error) {
+	location, err := bm.ResolveLocation(location)
+	if err != nil {
+		return nil, err
+	}
+
 	bp := &Breakpoint{
-		ID:      bm.nextID,
-		Enabled: true,
+		Enabled:   true,
+		Condition: condition,
 	}
-	bm.nextID++
 
 	// Parse location string
 	if strings.HasPrefix(location, "func:") {
@@ -88,50 +486,108 @@ func (bm *BreakpointManager) AddBreakpoint(location string) (*Breakpoint, error)
 		bp.EventType = location
 	}
 
+	if bp.Condition != "" && bp.Type != LocationBreakpoint {
+		return nil, fmt.Errorf("conditions are only supported on file:line breakpoints")
+	}
+
+	bm.mu.Lock()
+	if bm.backend != nil {
+		if err := bm.setInBackend(bp); err != nil {
+			bm.mu.Unlock()
+			return nil, fmt.Errorf("failed to set breakpoint in Delve: %v", err)
+		}
+	}
+	return bm.add(bp), nil
+}
+
+// add assigns the next ID to bp, stores it and notifies listeners. It returns
+// a copy. Callers hold bm.mu, which add releases.
+func (bm *BreakpointManager) add(bp *Breakpoint) *Breakpoint {
+	bp.ID = bm.nextID
+	bm.nextID++
 	bm.breakpoints = append(bm.breakpoints, bp)
-	return bp, nil
+	added := *bp
+	bm.mu.Unlock()
+
+	bm.notify(BreakpointEvent{Change: BreakpointAdded, Breakpoint: added})
+	return &added
 }
 
-// GetBreakpoints returns all breakpoints
+// GetBreakpoints returns copies of all breakpoints
 func (bm *BreakpointManager) GetBreakpoints() []*Breakpoint {
-	return bm.breakpoints
+	bm.mu.RLock()
+	defer bm.mu.RUnlock()
+
+	breakpoints := make([]*Breakpoint, len(bm.breakpoints))
+	for i, bp := range bm.breakpoints {
+		copied := *bp
+		breakpoints[i] = &copied
+	}
+	return breakpoints
 }
 
 // RemoveBreakpoint removes a breakpoint by ID
 func (bm *BreakpointManager) RemoveBreakpoint(id int) error {
+	bm.mu.Lock()
 	for i, bp := range bm.breakpoints {
 		if bp.ID == id {
+			if err := bm.clearInBackend(bp); err != nil {
+				bm.mu.Unlock()
+				return err
+			}
 			bm.breakpoints = append(bm.breakpoints[:i], bm.breakpoints[i+1:]...)
+			bm.mu.Unlock()
+
+			bm.notify(BreakpointEvent{Change: BreakpointRemoved, Breakpoint: *bp})
 			return nil
 		}
 	}
+	bm.mu.Unlock()
 	return fmt.Errorf("breakpoint %d not found", id)
 }
 
 // EnableBreakpoint enables a breakpoint by ID
 func (bm *BreakpointManager) EnableBreakpoint(id int) error {
-	for _, bp := range bm.breakpoints {
-		if bp.ID == id {
-			bp.Enabled = true
-			return nil
-		}
-	}
-	return fmt.Errorf("breakpoint %d not found", id)
+	return bm.setEnabled(id, true)
 }
 
 // DisableBreakpoint disables a breakpoint by ID
 func (bm *BreakpointManager) DisableBreakpoint(id int) error {
+	return bm.setEnabled(id, false)
+}
+
+// setEnabled enables or disables a breakpoint and notifies listeners
+func (bm *BreakpointManager) setEnabled(id int, enabled bool) error {
+	bm.mu.Lock()
 	for _, bp := range bm.breakpoints {
 		if bp.ID == id {
-			bp.Enabled = false
+			if bm.backend != nil && bp.DelveID != 0 {
+				if err := bm.backend.SetBreakpointEnabled(bp.DelveID, enabled); err != nil {
+					bm.mu.Unlock()
+					return fmt.Errorf("failed to update Delve breakpoint %d: %v", bp.DelveID, err)
+				}
+			}
+			bp.Enabled = enabled
+			changed := *bp
+			bm.mu.Unlock()
+
+			change := BreakpointDisabled
+			if enabled {
+				change = BreakpointEnabled
+			}
+			bm.notify(BreakpointEvent{Change: change, Breakpoint: changed})
 			return nil
 		}
 	}
+	bm.mu.Unlock()
 	return fmt.Errorf("breakpoint %d not found", id)
 }
 
 // CheckBreakpoint checks if a breakpoint should be hit
 func (bm *BreakpointManager) CheckBreakpoint(details string, eventType string) bool {
+	bm.mu.RLock()
+	defer bm.mu.RUnlock()
+
 	for _, bp := range bm.breakpoints {
 		if !bp.Enabled {
 			continue
@@ -148,30 +604,77 @@ func (bm *BreakpointManager) CheckBreakpoint(details string, eventType string) b
 	return false
 }
 
-// AddWatchpoint adds a watchpoint for an expression
+// RestoreBreakpoints replaces all breakpoints with copies of the given ones,
+// keeping their IDs so later additions don't collide. Listeners see the old
+// breakpoints removed and the restored ones added. With a backend attached,
+// the restored breakpoints are set in it; ones it rejects stay replay-only
+// with a warning.
+func (bm *BreakpointManager) RestoreBreakpoints(breakpoints []Breakpoint) {
+	bm.mu.Lock()
+	var events []BreakpointEvent
+	for _, bp := range bm.breakpoints {
+		if err := bm.clearInBackend(bp); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+		events = append(events, BreakpointEvent{Change: BreakpointRemoved, Breakpoint: *bp})
+	}
+
+	bm.breakpoints = make([]*Breakpoint, 0, len(breakpoints))
+	bm.nextID = 1
+	for i := range breakpoints {
+		bp := breakpoints[i]
+
+		// Saved Delve IDs refer to a process that is gone
+		bp.DelveID = 0
+		if bm.backend != nil {
+			if err := bm.setInBackend(&bp); err != nil {
+				fmt.Printf("Warning: Could not set breakpoint %d in Delve: %v\n", bp.ID, err)
+			}
+		}
+		bm.breakpoints = append(bm.breakpoints, &bp)
+		if bp.ID >= bm.nextID {
+			bm.nextID = bp.ID + 1
+		}
+		events = append(events, BreakpointEvent{Change: BreakpointAdded, Breakpoint: bp})
+	}
+	bm.mu.Unlock()
+
+	bm.notify(events...)
+}
+
+// AddWatchpoint adds a watchpoint for an expression. Delve can only watch
+// expressions that are in scope in the live process, so a watchpoint it
+// rejects is still added for replay, with DelveID 0 and a warning.
 func (bm *BreakpointManager) AddWatchpoint(expression string, watchType BreakpointType) (*Breakpoint, error) {
 	if watchType != WatchpointRead && watchType != WatchpointWrite && watchType != WatchpointReadWrite {
 		return nil, fmt.Errorf("invalid watchpoint type")
 	}
 
 	bp := &Breakpoint{
-		ID:         bm.nextID,
 		Type:       watchType,
 		Expression: expression,
 		Enabled:    true,
 	}
-	bm.nextID++
 
-	bm.breakpoints = append(bm.breakpoints, bp)
-	return bp, nil
+	bm.mu.Lock()
+	if bm.backend != nil {
+		if err := bm.setInBackend(bp); err != nil {
+			fmt.Printf("Warning: Unable to set live Delve watchpoint: %v\n", err)
+		}
+	}
+	return bm.add(bp), nil
 }
 
-// GetWatchpoints returns all watchpoints
+// GetWatchpoints returns copies of all watchpoints
 func (bm *BreakpointManager) GetWatchpoints() []*Breakpoint {
+	bm.mu.RLock()
+	defer bm.mu.RUnlock()
+
 	watchpoints := make([]*Breakpoint, 0)
 	for _, bp := range bm.breakpoints {
-		if bp.Type == WatchpointRead || bp.Type == WatchpointWrite || bp.Type == WatchpointReadWrite {
-			watchpoints = append(watchpoints, bp)
+		if bp.IsWatchpoint() {
+			copied := *bp
+			watchpoints = append(watchpoints, &copied)
 		}
 	}
 	return watchpoints
@@ -1,7 +1,14 @@
 package debugger
 
 import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
 	"testing"
+
+	"github.com/go-delve/delve/service/api"
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
 )
 
 func TestNewBreakpointManager(t *testing.T) {
@@ -244,6 +251,52 @@ func TestCheckBreakpoint(t *testing.T) {
 	}
 }
 
+func TestNormalizeFuncName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"main.main", "main.main"},
+		{"github.com/acme/app/server.(*Server).Handle", "server.Server.Handle"},
+		{"github.com/acme/app/server.Server.Handle", "server.Server.Handle"},
+		{"server.(*Server).Handle", "server.Server.Handle"},
+		{"(*Server).Handle", "Server.Handle"},
+		{"Server.Handle", "Server.Handle"},
+		{"main.(*Server).Handle.func1", "main.Server.Handle.func1"},
+		{"main.(*Server).Handle-fm", "main.Server.Handle"},
+		{"main.(*List[...]).Push", "main.List.Push"},
+		{"  main.run ", "main.run"},
+	}
+	for _, tt := range tests {
+		if got := NormalizeFuncName(tt.name); got != tt.want {
+			t.Errorf("NormalizeFuncName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMethodBreakpoints(t *testing.T) {
+	tests := []struct {
+		breakpoint string
+		funcName   string
+		want       bool
+	}{
+		{"(*Server).Handle", "main.(*Server).Handle", true},
+		{"(*Server).Handle", "main.Server.Handle", true},
+		{"main.(*Server).Handle", "github.com/acme/app/main.Server.Handle", true},
+		{"Server.Handle", "github.com/acme/app/server.(*Server).Handle", true},
+		{"server.(*Server).Handle", "github.com/acme/app/server.(*Server).Handle", true},
+		{"(*Server).Handle", "main.(*Client).Handle", false},
+		{"web.(*Server).Handle", "github.com/acme/app/server.(*Server).Handle", false},
+	}
+	for _, tt := range tests {
+		bp := &Breakpoint{Type: FunctionBreakpoint, Function: tt.breakpoint, Enabled: true}
+		event := recorder.Event{Type: recorder.FuncEntry, FuncName: tt.funcName, Details: "Entering function"}
+		if got := bp.Matches(event); got != tt.want {
+			t.Errorf("Breakpoint on %q matching %q = %v, want %v", tt.breakpoint, tt.funcName, got, tt.want)
+		}
+	}
+}
+
 func TestAddWatchpoint(t *testing.T) {
 	bm := NewBreakpointManager()
 
@@ -313,3 +366,314 @@ func TestGetWatchpoints(t *testing.T) {
 		t.Errorf("Expected 2 watchpoints, got %d", len(watchpoints))
 	}
 }
+
+func TestBreakpointManagerConcurrency(t *testing.T) {
+	bm := NewBreakpointManager()
+
+	var mu sync.Mutex
+	counts := make(map[BreakpointChange]int)
+	bm.OnChange(func(event BreakpointEvent) {
+		mu.Lock()
+		counts[event.Change]++
+		mu.Unlock()
+	})
+
+	// Writers add, toggle and remove while readers iterate, as the CLI and
+	// the continue loop do
+	const writers = 4
+	const perWriter = 50
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				bp, err := bm.AddBreakpoint(fmt.Sprintf("file%d.go:%d", w, i))
+				if err != nil {
+					t.Errorf("Failed to add breakpoint: %v", err)
+					return
+				}
+				if err := bm.DisableBreakpoint(bp.ID); err != nil {
+					t.Errorf("Failed to disable breakpoint: %v", err)
+				}
+				if err := bm.EnableBreakpoint(bp.ID); err != nil {
+					t.Errorf("Failed to enable breakpoint: %v", err)
+				}
+				if i%2 == 0 {
+					if err := bm.RemoveBreakpoint(bp.ID); err != nil {
+						t.Errorf("Failed to remove breakpoint: %v", err)
+					}
+				}
+			}
+		}(w)
+	}
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			event := recorder.Event{Type: recorder.FuncEntry, File: "file0.go", Line: 1}
+			for i := 0; i < 200; i++ {
+				for _, bp := range bm.GetBreakpoints() {
+					bp.Matches(event)
+				}
+				bm.CheckBreakpoint("Entering main", "FunctionEntry")
+				bm.GetWatchpoints()
+			}
+		}()
+	}
+	wg.Wait()
+
+	remaining := bm.GetBreakpoints()
+	if len(remaining) != writers*perWriter/2 {
+		t.Errorf("Expected %d breakpoints, got %d", writers*perWriter/2, len(remaining))
+	}
+
+	// IDs stay unique under concurrent adds
+	seen := make(map[int]bool)
+	for _, bp := range remaining {
+		if seen[bp.ID] {
+			t.Errorf("Duplicate breakpoint ID %d", bp.ID)
+		}
+		seen[bp.ID] = true
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if counts[BreakpointAdded] != writers*perWriter || counts[BreakpointRemoved] != writers*perWriter/2 ||
+		counts[BreakpointEnabled] != writers*perWriter || counts[BreakpointDisabled] != writers*perWriter {
+		t.Errorf("Unexpected change notifications: %v", counts)
+	}
+}
+
+func TestBreakpointManagerReturnsCopies(t *testing.T) {
+	bm := NewBreakpointManager()
+	bp, err := bm.AddBreakpoint("main.go:10")
+	if err != nil {
+		t.Fatalf("Failed to add breakpoint: %v", err)
+	}
+
+	// Changing returned breakpoints doesn't change the manager's
+	bp.Enabled = false
+	bm.GetBreakpoints()[0].Line = 99
+	stored := bm.GetBreakpoints()[0]
+	if !stored.Enabled || stored.Line != 10 {
+		t.Errorf("Expected stored breakpoint to be unchanged, got %+v", stored)
+	}
+
+	var events []BreakpointEvent
+	bm.OnChange(func(event BreakpointEvent) {
+		events = append(events, event)
+	})
+	bm.RestoreBreakpoints([]Breakpoint{{ID: 7, Type: FunctionBreakpoint, Function: "main", Enabled: true}})
+	if len(events) != 2 || events[0].Change != BreakpointRemoved || events[0].Breakpoint.ID != 1 ||
+		events[1].Change != BreakpointAdded || events[1].Breakpoint.ID != 7 {
+		t.Errorf("Unexpected restore notifications: %+v", events)
+	}
+}
+
+// fakeBackend records the breakpoints set through a BreakpointManager and
+// fails on demand
+type fakeBackend struct {
+	nextID   int
+	set      map[int]*api.Breakpoint
+	disabled map[int]bool
+	fail     error
+}
+
+func newFakeBackend() *fakeBackend {
+	return &fakeBackend{nextID: 1, set: make(map[int]*api.Breakpoint), disabled: make(map[int]bool)}
+}
+
+func (f *fakeBackend) create(bp *api.Breakpoint) (*api.Breakpoint, error) {
+	if f.fail != nil {
+		return nil, f.fail
+	}
+	bp.ID = f.nextID
+	f.nextID++
+	f.set[bp.ID] = bp
+	return bp, nil
+}
+
+func (f *fakeBackend) SetBreakpoint(file string, line int) (*api.Breakpoint, error) {
+	return f.create(&api.Breakpoint{File: file, Line: line})
+}
+
+func (f *fakeBackend) SetConditionalBreakpoint(file string, line int, condition string) (*api.Breakpoint, error) {
+	return f.create(&api.Breakpoint{File: file, Line: line, Cond: condition})
+}
+
+func (f *fakeBackend) SetFunctionBreakpoint(funcName string) (*api.Breakpoint, error) {
+	return f.create(&api.Breakpoint{FunctionName: funcName})
+}
+
+func (f *fakeBackend) SetWatchpoint(expr string, readFlag, writeFlag bool) (*api.Breakpoint, error) {
+	return f.create(&api.Breakpoint{WatchExpr: expr})
+}
+
+func (f *fakeBackend) ClearBreakpoint(id int) error {
+	if f.fail != nil {
+		return f.fail
+	}
+	if f.set[id] == nil {
+		return fmt.Errorf("no breakpoint %d", id)
+	}
+	delete(f.set, id)
+	return nil
+}
+
+func (f *fakeBackend) SetBreakpointEnabled(id int, enabled bool) error {
+	if f.fail != nil {
+		return f.fail
+	}
+	f.disabled[id] = !enabled
+	return nil
+}
+
+func TestBreakpointManagerBackend(t *testing.T) {
+	backend := newFakeBackend()
+	bm := NewBreakpointManager()
+
+	// Breakpoints added before the backend is attached are set when it is
+	bm.AddBreakpoint("FunctionEntry")
+	early, _ := bm.AddBreakpoint("func:main.early")
+	bm.SetBackend(backend)
+	if bp := bm.GetBreakpoints()[1]; bp.DelveID == 0 || backend.set[bp.DelveID].FunctionName != "main.early" {
+		t.Fatalf("Expected breakpoint %d to be set in Delve, got %+v", early.ID, bp)
+	}
+	if bm.GetBreakpoints()[0].DelveID != 0 {
+		t.Error("Expected event type breakpoint to stay replay-only")
+	}
+
+	bp, err := bm.AddConditionalBreakpoint("main.go:10", "x > 1")
+	if err != nil {
+		t.Fatalf("Failed to add breakpoint: %v", err)
+	}
+	if dbp := backend.set[bp.DelveID]; dbp == nil || dbp.Cond != "x > 1" {
+		t.Fatalf("Expected conditional Delve breakpoint, got %+v", dbp)
+	}
+
+	if err := bm.DisableBreakpoint(bp.ID); err != nil {
+		t.Fatalf("Failed to disable breakpoint: %v", err)
+	}
+	if !backend.disabled[bp.DelveID] {
+		t.Error("Expected Delve breakpoint to be disabled")
+	}
+
+	// A failing backend leaves both sides unchanged
+	backend.fail = fmt.Errorf("connection lost")
+	if err := bm.EnableBreakpoint(bp.ID); err == nil {
+		t.Error("Expected enable to fail")
+	}
+	if bm.GetBreakpoints()[2].Enabled {
+		t.Error("Expected breakpoint to stay disabled after failed enable")
+	}
+	if err := bm.RemoveBreakpoint(bp.ID); err == nil {
+		t.Error("Expected remove to fail")
+	}
+	if len(bm.GetBreakpoints()) != 3 {
+		t.Error("Expected breakpoint to stay after failed remove")
+	}
+	if _, err := bm.AddBreakpoint("main.go:20"); err == nil {
+		t.Error("Expected add to fail")
+	}
+	if len(bm.GetBreakpoints()) != 3 {
+		t.Error("Expected no breakpoint after failed add")
+	}
+
+	// Watchpoints Delve rejects are kept for replay
+	wp, err := bm.AddWatchpoint("counter", WatchpointWrite)
+	if err != nil || wp.DelveID != 0 {
+		t.Errorf("Expected replay-only watchpoint, got %+v, %v", wp, err)
+	}
+
+	backend.fail = nil
+	delveID := bp.DelveID
+	if err := bm.RemoveBreakpoint(bp.ID); err != nil {
+		t.Fatalf("Failed to remove breakpoint: %v", err)
+	}
+	if backend.set[delveID] != nil {
+		t.Error("Expected Delve breakpoint to be cleared")
+	}
+
+	// Detaching forgets the Delve IDs
+	bm.SetBackend(nil)
+	for _, bp := range bm.GetBreakpoints() {
+		if bp.DelveID != 0 {
+			t.Errorf("Expected breakpoint %d to be replay-only after detaching, got Delve ID %d", bp.ID, bp.DelveID)
+		}
+	}
+}
+
+// listingBackend is a fakeBackend that can list the target's functions
+type listingBackend struct {
+	*fakeBackend
+	functions []string
+}
+
+func (l *listingBackend) ListFunctions(filter string) ([]string, error) {
+	return l.functions, nil
+}
+
+func TestResolveLocation(t *testing.T) {
+	bm := NewBreakpointManager()
+	bm.SetRecordedLocations([]recorder.Event{
+		{Type: recorder.FuncEntry, FuncName: "main.processData", File: "/src/shop/main.go", Line: 10},
+		{Type: recorder.FuncEntry, FuncName: "shop/payment.(*Gateway).Charge", File: "/src/shop/payment/payment.go", Line: 20},
+		{Type: recorder.FuncEntry, FuncName: "shop/payment.validate", File: "/src/shop/payment/validate.go", Line: 5},
+		{Type: recorder.FuncEntry, FuncName: "shop/orders.validate", File: "/src/shop/orders/validate.go", Line: 7},
+	})
+
+	tests := []struct {
+		location string
+		want     string
+	}{
+		{"processData", "func:main.processData"},
+		{"func:processData", "func:main.processData"},
+		{"Charge", "func:shop/payment.(*Gateway).Charge"},
+		{"Gateway.Charge", "func:shop/payment.(*Gateway).Charge"},
+		{"payment.validate", "func:shop/payment.validate"},
+		{"payment.go:20", "/src/shop/payment/payment.go:20"},
+		{"orders/validate.go:7", "/src/shop/orders/validate.go:7"},
+		{"/src/shop/main.go:10", "/src/shop/main.go:10"},
+		{"other.go:3", "other.go:3"},             // Left for Delve
+		{"func:notRecorded", "func:notRecorded"}, // Left for Delve
+		{"FuncEntry", "FuncEntry"},               // Event type
+		{"FunctionEntry", "FunctionEntry"},       // Unknown names stay event types
+	}
+	for _, tt := range tests {
+		got, err := bm.ResolveLocation(tt.location)
+		if err != nil || got != tt.want {
+			t.Errorf("ResolveLocation(%q) = %q, %v, want %q", tt.location, got, err, tt.want)
+		}
+	}
+
+	// Ambiguous shorthand lists the candidates and adds nothing
+	for location, candidates := range map[string][]string{
+		"validate":      {"shop/orders.validate", "shop/payment.validate"},
+		"validate.go:5": {"/src/shop/orders/validate.go", "/src/shop/payment/validate.go"},
+		"func:validate": {"shop/orders.validate", "shop/payment.validate"},
+	} {
+		_, err := bm.AddBreakpoint(location)
+		var ambiguous *AmbiguousLocationError
+		if !errors.As(err, &ambiguous) || !reflect.DeepEqual(ambiguous.Candidates, candidates) {
+			t.Errorf("Expected %q to be ambiguous between %v, got %v", location, candidates, err)
+		}
+	}
+	if len(bm.GetBreakpoints()) != 0 {
+		t.Errorf("Expected no breakpoints from ambiguous locations, got %d", len(bm.GetBreakpoints()))
+	}
+
+	// A live backend's functions are searched too, each function once
+	backend := &listingBackend{fakeBackend: newFakeBackend(), functions: []string{"main.processData", "main.flush"}}
+	bm.SetBackend(backend)
+	bp, err := bm.AddBreakpoint("flush")
+	if err != nil || bp.Type != FunctionBreakpoint || bp.Function != "main.flush" {
+		t.Fatalf("Expected a breakpoint on main.flush, got %+v, %v", bp, err)
+	}
+	if dbp := backend.set[bp.DelveID]; dbp == nil || dbp.FunctionName != "main.flush" {
+		t.Errorf("Expected the resolved name in Delve, got %+v", dbp)
+	}
+	if got, err := bm.ResolveLocation("processData"); err != nil || got != "func:main.processData" {
+		t.Errorf("Expected recorded and live names to agree, got %q, %v", got, err)
+	}
+}
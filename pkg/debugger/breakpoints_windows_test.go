@@ -0,0 +1,35 @@
+//go:build windows
+// +build windows
+
+package debugger
+
+import (
+	"testing"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+func TestBreakpointMatchesWindowsPaths(t *testing.T) {
+	recorded := `C:\Users\me\proj\main.go`
+	tests := []struct {
+		file string
+		ok   bool
+	}{
+		{`C:\Users\me\proj\main.go`, true},
+		{`c:\users\ME\proj\main.go`, true},
+		{"C:/Users/me/proj/main.go", true},
+		{`C:/Users\me/proj\main.go`, true},
+		{`\\?\C:\Users\me\proj\main.go`, true},
+		{"main.go", true},
+		{`proj\main.go`, true},
+		{`D:\Users\me\proj\main.go`, false},
+		{`C:\Users\me\other\main.go`, false},
+	}
+	for _, tt := range tests {
+		bp := &Breakpoint{Type: LocationBreakpoint, File: tt.file, Line: 20, Enabled: true}
+		event := recorder.Event{Type: recorder.StatementExecution, File: recorded, Line: 20}
+		if got := bp.Matches(event); got != tt.ok {
+			t.Errorf("Breakpoint at %s matches %s: %v, want %v", tt.file, recorded, got, tt.ok)
+		}
+	}
+}
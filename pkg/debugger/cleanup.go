@@ -0,0 +1,89 @@
+package debugger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// processRegistryDir holds one file per dlv process started by a chrono
+// process, named after the dlv PID and containing the PID of its owner
+var processRegistryDir = filepath.Join(os.TempDir(), "chronogo-dlv")
+
+// RegisterProcess records a process started by this process, so
+// CleanupOrphans can kill it if this process exits without stopping it
+func RegisterProcess(pid int) {
+	if err := os.MkdirAll(processRegistryDir, 0755); err != nil {
+		fmt.Printf("Warning: Failed to create process registry: %v\n", err)
+		return
+	}
+	path := filepath.Join(processRegistryDir, strconv.Itoa(pid))
+	if err := os.WriteFile(path, []byte(strconv.Itoa(os.Getpid())), 0644); err != nil {
+		fmt.Printf("Warning: Failed to register process %d: %v\n", pid, err)
+	}
+}
+
+// UnregisterProcess removes a process from the registry once it has been stopped
+func UnregisterProcess(pid int) {
+	_ = os.Remove(filepath.Join(processRegistryDir, strconv.Itoa(pid)))
+}
+
+// KillProcess kills a process along with the processes it started, such as
+// the program dlv is debugging, and removes it from the registry. A process
+// that has already exited is not an error, but the processes it left
+// behind in its process group are still killed.
+func KillProcess(pid int) error {
+	defer UnregisterProcess(pid)
+	if !processAlive(pid) && !processGroupAlive(pid) {
+		return nil
+	}
+	if err := killProcessTree(pid); err != nil {
+		return fmt.Errorf("failed to kill process %d: %v", pid, err)
+	}
+	return nil
+}
+
+// CleanupOrphans kills registered processes whose owning chrono process is
+// gone, which happens when chrono or a test crashes before closing its
+// debugger. It returns the number of processes killed.
+func CleanupOrphans() (int, error) {
+	entries, err := os.ReadDir(processRegistryDir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read process registry: %v", err)
+	}
+
+	killed := 0
+	var firstErr error
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(processRegistryDir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		owner, err := strconv.Atoi(strings.TrimSpace(string(data)))
+		if err == nil && (owner == os.Getpid() || processAlive(owner)) {
+			continue
+		}
+
+		if !processAlive(pid) {
+			UnregisterProcess(pid)
+			continue
+		}
+		if err := KillProcess(pid); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		killed++
+	}
+	return killed, firstErr
+}
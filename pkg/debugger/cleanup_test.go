@@ -0,0 +1,105 @@
+package debugger
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// TestHelperProcess stands in for a dlv process in the cleanup tests
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("CHRONOGO_HELPER_PROCESS") != "1" {
+		return
+	}
+	time.Sleep(time.Minute)
+	os.Exit(0)
+}
+
+// startHelperProcess starts a long-running child process
+func startHelperProcess(t *testing.T) *exec.Cmd {
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHelperProcess$")
+	cmd.Env = append(os.Environ(), "CHRONOGO_HELPER_PROCESS=1")
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Failed to start helper process: %v", err)
+	}
+	return cmd
+}
+
+// waitExit reports whether cmd exits within a few seconds
+func waitExit(cmd *exec.Cmd) bool {
+	done := make(chan struct{})
+	go func() {
+		_ = cmd.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(5 * time.Second):
+		return false
+	}
+}
+
+func TestCleanupOrphans(t *testing.T) {
+	originalDir := processRegistryDir
+	processRegistryDir = t.TempDir()
+	defer func() {
+		processRegistryDir = originalDir
+	}()
+
+	// The PID of a process that has exited stands in for a crashed chrono
+	exited := exec.Command(os.Args[0], "-test.run=^$")
+	if err := exited.Run(); err != nil {
+		t.Fatalf("Failed to run short-lived process: %v", err)
+	}
+	deadOwner := strconv.Itoa(exited.Process.Pid)
+
+	orphan := startHelperProcess(t)
+	orphanEntry := filepath.Join(processRegistryDir, strconv.Itoa(orphan.Process.Pid))
+	if err := os.WriteFile(orphanEntry, []byte(deadOwner), 0644); err != nil {
+		t.Fatalf("Failed to write registry entry: %v", err)
+	}
+
+	// A stale entry for a process that is already gone
+	staleEntry := filepath.Join(processRegistryDir, deadOwner)
+	if err := os.WriteFile(staleEntry, []byte(deadOwner), 0644); err != nil {
+		t.Fatalf("Failed to write registry entry: %v", err)
+	}
+
+	owned := startHelperProcess(t)
+	RegisterProcess(owned.Process.Pid)
+	defer owned.Process.Kill()
+
+	killed, err := CleanupOrphans()
+	if err != nil {
+		t.Fatalf("CleanupOrphans failed: %v", err)
+	}
+	if killed != 1 {
+		t.Errorf("Expected 1 orphan killed, got %d", killed)
+	}
+	if !waitExit(orphan) {
+		t.Error("Expected the orphaned process to be killed")
+	}
+	for _, path := range []string{orphanEntry, staleEntry} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected registry entry %s to be removed", filepath.Base(path))
+		}
+	}
+
+	// Processes owned by a running chrono are left alone
+	if !processAlive(owned.Process.Pid) {
+		t.Fatal("Expected the owned process to keep running")
+	}
+	if err := KillProcess(owned.Process.Pid); err != nil {
+		t.Fatalf("KillProcess failed: %v", err)
+	}
+	if !waitExit(owned) {
+		t.Error("Expected KillProcess to kill the owned process")
+	}
+	if entries, _ := os.ReadDir(processRegistryDir); len(entries) != 0 {
+		t.Errorf("Expected an empty registry, got %d entries", len(entries))
+	}
+}
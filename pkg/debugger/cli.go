@@ -2,10 +2,17 @@ package debugger
 
 import (
 	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-delve/delve/service/api"
@@ -15,36 +22,95 @@ import (
 
 // CLI represents the command-line interface for the debugger
 type CLI struct {
-	replayer  replay.Replayer
-	debugger  *DelveDebugger
-	running   bool
-	bpManager *BreakpointManager
+	replayer    replay.Replayer
+	debugger    *DelveDebugger
+	running     bool
+	bpManager   *BreakpointManager
+	eventsFile  string                      // Path of the events file being replayed, if known
+	stream      string                      // Stream the recording was read from, which can't be read again
+	segments    []recorder.SegmentBoundary  // Segment starts when replaying a segment directory
+	eventIndex  []recorder.EventOffset      // Byte offsets of the event lines, built by the first inspect --raw
+	formatter   *eventFormatter             // User event format, nil for the built-in one
+	sources     SourceProvider              // Source files shown by list, read from disk if nil
+	metadata    *recorder.RecordingMetadata // Shown by info recording, read from eventsFile if nil
+	tags        map[int][]string            // Tags added in this session, by event index, saved with sessions
+	stepFilter  string                      // Events step and backstep stop at, tag:<label> or type:<name>, empty for all
+	locations   *replay.LocationIndex       // Built by the first when, for the events in locationsOf
+	locationsOf []recorder.Event            // Events the location index was built for
+	displays    []*displayExpr              // Expressions re-evaluated in Delve at every stop
+	nextDisplay int                         // Number of the last display expression added
+
+	busy        atomic.Bool  // Whether a command is running
+	interrupted atomic.Bool  // Whether Ctrl-C asked the running command to stop
+	interrupts  atomic.Int32 // Ctrl-C presses since the last command finished
 }
 
+// exitProcess ends the program after a second Ctrl-C. Tests replace it.
+var exitProcess = os.Exit
+
+// startDelveDebugger starts the debugger when a reset needs a fresh process.
+// Tests replace it to avoid running dlv.
+var startDelveDebugger = NewDelveDebugger
+
 // NewCLI creates a new CLI instance
 func NewCLI(replayer replay.Replayer) *CLI {
-	return &CLI{
+	c := &CLI{
 		replayer:  replayer,
 		running:   false,
 		bpManager: NewBreakpointManager(),
 	}
+	c.applyEnvEventFormat()
+	c.printReplayedEvents()
+	return c
 }
 
 // NewCLIWithDelve creates a new CLI instance with Delve integration
 func NewCLIWithDelve(replayer replay.Replayer, dbg *DelveDebugger) *CLI {
-	return &CLI{
+	return NewCLIWithBreakpoints(replayer, dbg, NewBreakpointManager())
+}
+
+// NewCLIWithBreakpoints creates a new CLI instance that shares an existing
+// breakpoint manager, e.g. one owned by a chrono.Session. dbg may be nil.
+func NewCLIWithBreakpoints(replayer replay.Replayer, dbg *DelveDebugger, bpManager *BreakpointManager) *CLI {
+	c := &CLI{
 		replayer:  replayer,
 		debugger:  dbg,
 		running:   false,
-		bpManager: NewBreakpointManager(),
+		bpManager: bpManager,
+	}
+	c.applyEnvEventFormat()
+	c.printReplayedEvents()
+
+	// Breakpoint changes made through the manager are applied to Delve too
+	if dbg != nil {
+		bpManager.SetBackend(dbg)
+	}
+	return c
+}
+
+// printReplayedEvents has the replayer print the events continue passes
+// over the way the CLI formats them
+func (c *CLI) printReplayedEvents() {
+	if r, ok := c.replayer.(*replay.BasicReplayer); ok {
+		r.SetEventPrinter(c.formatEvent)
 	}
 }
 
-// Start begins the command loop
+// Start begins the command loop. Ctrl-C stops a running continue; a
+// second Ctrl-C closes the debugger and exits.
 func (c *CLI) Start() {
 	c.running = true
 	reader := bufio.NewReader(os.Stdin)
 
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt)
+	done := make(chan struct{})
+	defer func() {
+		signal.Stop(sigs)
+		close(done)
+	}()
+	go c.watchSignals(sigs, done)
+
 	fmt.Println("ChronoGo Debugger CLI")
 	if c.debugger != nil {
 		fmt.Println("Delve integration enabled")
@@ -53,9 +119,66 @@ func (c *CLI) Start() {
 
 	for c.running {
 		fmt.Print("(chrono) ")
-		input, _ := reader.ReadString('\n')
-		input = strings.TrimSpace(input)
-		c.handleCommand(input)
+		input, err := reader.ReadString('\n')
+		if err != nil && input == "" {
+			// Stdin closed: leave without orphaning the Delve process
+			fmt.Println()
+			c.shutdown()
+			return
+		}
+		c.runCommand(strings.TrimSpace(input))
+	}
+}
+
+// runCommand handles one command, marking it as running so Ctrl-C can stop it
+func (c *CLI) runCommand(input string) {
+	c.interrupted.Store(false)
+	c.busy.Store(true)
+	c.handleCommand(input)
+	c.busy.Store(false)
+	c.interrupts.Store(0)
+}
+
+// watchSignals handles Ctrl-C until done is closed
+func (c *CLI) watchSignals(sigs <-chan os.Signal, done <-chan struct{}) {
+	for {
+		select {
+		case <-done:
+			return
+		case <-sigs:
+			c.handleSignal()
+		}
+	}
+}
+
+// handleSignal stops the running command on the first Ctrl-C, and closes
+// the debugger and exits on the second
+func (c *CLI) handleSignal() {
+	if c.interrupts.Add(1) > 1 {
+		fmt.Println("\nExiting...")
+		c.shutdown()
+		exitProcess(130)
+		return
+	}
+
+	if !c.busy.Load() {
+		fmt.Println("\nPress Ctrl-C again or type quit to exit")
+		return
+	}
+	fmt.Println("\nInterrupting... press Ctrl-C again to exit")
+	c.interrupted.Store(true)
+	if c.debugger != nil {
+		if _, err := c.debugger.Interrupt(); err != nil {
+			fmt.Printf("Warning: Failed to interrupt target: %v\n", err)
+		}
+	}
+}
+
+// shutdown ends the command loop, terminating the Delve process if attached
+func (c *CLI) shutdown() {
+	c.running = false
+	if c.debugger != nil {
+		c.debugger.Close()
 	}
 }
 
@@ -63,29 +186,83 @@ func (c *CLI) Start() {
 func (c *CLI) printHelp() {
 	fmt.Println("\nAvailable commands:")
 	fmt.Println("  continue (c)      - Continue execution")
-	fmt.Println("  step (s)          - Step forward one event")
-	fmt.Println("  backstep (b)      - Step backward one event")
-	fmt.Println("  info (i)          - Show current execution state")
+	fmt.Println("  step (s) [n]      - Step forward one event, or n events")
+	fmt.Println("  backstep (b) [n]  - Step backward one event, or n events")
+	fmt.Println("  end               - Jump to the last recorded event")
+	fmt.Println("  restart (r)       - Start the replay over from the beginning")
+	fmt.Println("  info (i) [--json] - Show the current event, goroutine, call stack, locals, breakpoints and nearest snapshot")
+	fmt.Println("  info recording    - Show the build and environment the recording was made in")
+	fmt.Println("  map [buckets]     - Show an overview of the recording")
+	fmt.Println("  history <var> [--all] - Show the values assigned to a variable in the current function, or everywhere")
+	fmt.Println("  history <var> goto <n> - Jump to the nth entry of the history")
+	fmt.Println("  errors            - List every recorded error, and the recover that stopped each panic")
+	fmt.Println("  stack (bt)        - Show the call stack at the current event, deferred calls marked")
+	fmt.Println("  goroutines (gr)   - List the goroutines recorded so far and the OS thread each last ran on")
+	fmt.Println("  next-error        - Jump to the next recorded error")
+	fmt.Println("  prev-error        - Jump to the previous recorded error")
+	fmt.Println("  nextiter, previter - Jump to the same point in the next or previous iteration of the current loop")
+	fmt.Println("  iter <n>          - Jump to iteration n of the current loop")
+	fmt.Println("  bisect <predicate> - Jump to the first event where e.g. var total > 1000 or event.func == \"main.f\" holds")
+	fmt.Println("  when <file:line|func> [--count] - List the events recorded at a line, or entering and leaving a function")
+	fmt.Println("  find <file:line>  - Jump to the next event recorded at a line, without a breakpoint")
+	fmt.Println("  find-prev <file:line> - Jump to the previous event recorded at a line")
+	fmt.Println("  check [chan <id> <max>] - Check invariants over the whole recording")
+	fmt.Println("  verify-sync       - Check that Delve is stopped where the current event was recorded")
+	fmt.Println("  stats [width]     - Show per-function counts and event types over time, in buckets of width")
+	fmt.Println("  io [name]         - Summarize traced I/O, or jump to the last write to a file or connection")
+	fmt.Println("  traces            - List the recorded requests, one row per trace ID")
+	fmt.Println("  trace <id>        - Jump to the first event of a request")
+	fmt.Println("  follow-child [n]  - List the child processes started, or replay the recording of the nth")
+	fmt.Println("  inspect [index] [--raw] - Show every field of an event, or its serialized line")
+	fmt.Println("  list (l) [n]      - Show the code around the current event, n lines either side")
+	fmt.Println("  tag <index|from-to> <label> - Tag events, e.g. a suspicious region")
+	fmt.Println("  filter [tag:<label>|type:<event type>|off] - Step only through events with a tag or of a type")
+	fmt.Println("  format [template|default] - Show or set the text/template used to print events")
+	fmt.Println("  checkpoint        - Remember the current replay position")
+	fmt.Println("  checkpoints       - List the checkpoints")
+	fmt.Println("  restore <id>      - Return to a checkpoint")
 
 	if c.debugger != nil {
 		fmt.Println("\nDelve debugging commands:")
 		fmt.Println("  breakpoint (bp) <file:line> - Set a breakpoint")
 		fmt.Println("  bp func:<funcname>  - Set a function breakpoint")
 		fmt.Println("  bp <file:line> -c <cond> - Set a conditional breakpoint")
-		fmt.Println("  list (l)        - List all breakpoints")
+		fmt.Println("  bp list         - List all breakpoints")
 		fmt.Println("  print (p) <var> - Print value of a variable")
-		fmt.Println("  goroutines (gr) - List all goroutines")
+		fmt.Println("  set <var>=<value> - Change a variable in the live process")
+		fmt.Println("  display [expr]  - Show expr whenever it changes at a stop, or list displays")
+		fmt.Println("  undisplay <n>   - Stop showing display expression n")
+		fmt.Println("  goroutines (gr) - List all goroutines in the live process")
 		fmt.Println("  watch (w) [-r|-w|-rw] <expr> - Set a watchpoint")
+		fmt.Println("  config [loadstring|loadarray <n>] - Show or raise the limits for printing values")
+		fmt.Println("  interrupt       - Stop the running target")
 		fmt.Println("  bp remove <id>  - Remove a breakpoint")
 		fmt.Println("  bp enable <id>  - Enable a breakpoint")
 		fmt.Println("  bp disable <id> - Disable a breakpoint")
+	} else if _, err := DelveAvailable(); err != nil {
+		fmt.Printf("\nLive debugging commands are hidden: %s\n", delveInstallHint)
 	}
 
 	fmt.Println("\nGeneral commands:")
+	fmt.Println("  session save [name]    - Save the current session")
+	fmt.Println("  session restore [name] - Restore a saved session")
 	fmt.Println("  help (h)          - Show this help message")
 	fmt.Println("  quit (q)          - Exit the debugger")
 }
 
+// delveCommands only work in a live debugging session
+var delveCommands = map[string]bool{
+	"bp":         true,
+	"breakpoint": true,
+	"p":          true,
+	"print":      true,
+	"set":        true,
+	"display":    true,
+	"undisplay":  true,
+	"config":     true,
+	"interrupt":  true,
+}
+
 // handleCommand processes user input
 func (c *CLI) handleCommand(input string) {
 	parts := strings.Fields(input)
@@ -96,34 +273,119 @@ func (c *CLI) handleCommand(input string) {
 	cmd := parts[0]
 	args := parts[1:]
 
+	if delveCommands[cmd] && c.debugger == nil {
+		if _, err := DelveAvailable(); err != nil {
+			fmt.Printf("%s needs Delve: %s\n", cmd, delveInstallHint)
+			return
+		}
+	}
+
 	switch cmd {
 	case "h", "help":
 		c.printHelp()
 	case "c", "continue":
 		c.handleContinue()
 	case "s", "step":
-		c.handleStep()
+		c.handleStep(args)
 	case "b", "backstep":
-		c.handleBackstep()
+		c.handleBackstep(args)
+	case "end":
+		c.handleEnd()
+	case "r", "restart":
+		c.handleRestart()
 	case "i", "info":
-		c.handleInfo()
-	case "q", "quit", "exit":
-		c.running = false
-		// Close delve if available
-		if c.debugger != nil {
-			c.debugger.Close()
+		if len(args) > 0 && args[0] == "recording" {
+			c.handleRecordingInfo()
+			return
 		}
+		c.handleInfo(args)
+	case "map":
+		c.handleMap(args)
+	case "bisect":
+		// Keep quoted values intact
+		c.handleBisect(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(input), cmd)))
+	case "history":
+		c.handleHistory(args)
+	case "errors":
+		c.handleErrors()
+	case "next-error":
+		c.handleNextError(1)
+	case "prev-error":
+		c.handleNextError(-1)
+	case "nextiter":
+		c.handleIteration(1, 0)
+	case "previter":
+		c.handleIteration(-1, 0)
+	case "iter":
+		c.handleIter(args)
+	case "when":
+		c.handleWhen(args)
+	case "stack", "bt":
+		c.handleStack()
+	case "verify-sync":
+		c.handleVerifySync()
+	case "check":
+		c.handleCheck(args)
+	case "stats":
+		c.handleStats(args)
+	case "io":
+		c.handleIO(args)
+	case "traces":
+		c.handleTraces()
+	case "find":
+		c.handleFind(args, false)
+	case "find-prev":
+		c.handleFind(args, true)
+	case "follow-child":
+		c.handleFollowChild(args)
+	case "trace":
+		c.handleTrace(args)
+	case "inspect":
+		c.handleInspect(args)
+	case "tag":
+		c.handleTag(args)
+	case "filter":
+		c.handleFilter(args)
+	case "checkpoint":
+		c.handleCheckpoint()
+	case "checkpoints":
+		c.handleCheckpoints()
+	case "restore":
+		c.handleRestore(args)
+	case "format":
+		// Keep the template's spacing
+		c.handleFormat(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(input), cmd)))
+	case "session":
+		c.handleSession(args)
+	case "q", "quit", "exit":
+		c.shutdown()
 	// Delve-specific commands
 	case "bp", "breakpoint":
 		c.handleBreakpointCommand(args)
 	case "l", "list":
-		c.handleListBreakpoints()
+		// "list breakpoints" is kept for those used to it listing breakpoints
+		if len(args) > 0 && (args[0] == "breakpoints" || args[0] == "bp") {
+			c.handleListBreakpoints()
+			return
+		}
+		c.handleList(args)
 	case "p", "print":
 		c.handlePrintVariable(args)
+	case "set":
+		c.handleSetVariable(args)
+	case "display":
+		// Expressions may contain spaces
+		c.handleDisplay(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(input), cmd)))
+	case "undisplay":
+		c.handleUndisplay(args)
 	case "gr", "goroutines":
 		c.handleListGoroutines()
 	case "w", "watch":
 		c.handleWatch(args)
+	case "config":
+		c.handleConfig(args)
+	case "interrupt":
+		c.handleInterrupt()
 	default:
 		fmt.Printf("Unknown command: %s\n", cmd)
 		c.printHelp()
@@ -141,7 +403,7 @@ func (c *CLI) handleBreakpointCommand(args []string) {
 		// No args - show usage
 		fmt.Println("Usage: breakpoint <file:line> or <command> [args]")
 		fmt.Println("Commands: list, remove, enable, disable")
-		fmt.Println("Function breakpoint: breakpoint func:<function_name>")
+		fmt.Println("Function breakpoint: breakpoint func:<function_name> or <function_name>")
 		fmt.Println("Conditional breakpoint: breakpoint <file:line> -c <condition>")
 		return
 	}
@@ -162,19 +424,9 @@ func (c *CLI) handleBreakpointCommand(args []string) {
 			return
 		}
 
-		// Remove from our manager
-		err = c.bpManager.RemoveBreakpoint(id)
-		if err != nil {
-			fmt.Printf("Error removing breakpoint from manager: %v\n", err)
-		}
-
-		// Remove from Delve if available
-		if c.debugger != nil {
-			err = c.debugger.ClearBreakpoint(id)
-			if err != nil {
-				fmt.Printf("Error removing breakpoint from Delve: %v\n", err)
-				return
-			}
+		if err := c.bpManager.RemoveBreakpoint(id); err != nil {
+			printError("Error removing breakpoint: %v\n", err)
+			return
 		}
 
 		fmt.Printf("Removed breakpoint %d\n", id)
@@ -189,28 +441,9 @@ func (c *CLI) handleBreakpointCommand(args []string) {
 			return
 		}
 
-		// Enable in our manager
-		err = c.bpManager.EnableBreakpoint(id)
-		if err != nil {
-			fmt.Printf("Error enabling breakpoint in manager: %v\n", err)
-		}
-
-		// Enable in Delve if available
-		if c.debugger != nil {
-			// Get current breakpoint
-			bp, err := c.debugger.client.GetBreakpoint(id)
-			if err != nil {
-				fmt.Printf("Error getting breakpoint %d from Delve: %v\n", id, err)
-				return
-			}
-
-			// Enable the breakpoint using Delve API
-			bp.Disabled = false // Note: Delve uses Disabled rather than Enabled
-			err = c.debugger.client.AmendBreakpoint(bp)
-			if err != nil {
-				fmt.Printf("Error enabling breakpoint %d in Delve: %v\n", id, err)
-				return
-			}
+		if err := c.bpManager.EnableBreakpoint(id); err != nil {
+			printError("Error enabling breakpoint: %v\n", err)
+			return
 		}
 
 		fmt.Printf("Enabled breakpoint %d\n", id)
@@ -225,28 +458,9 @@ func (c *CLI) handleBreakpointCommand(args []string) {
 			return
 		}
 
-		// Disable in our manager
-		err = c.bpManager.DisableBreakpoint(id)
-		if err != nil {
-			fmt.Printf("Error disabling breakpoint in manager: %v\n", err)
-		}
-
-		// Disable in Delve if available
-		if c.debugger != nil {
-			// Get current breakpoint
-			bp, err := c.debugger.client.GetBreakpoint(id)
-			if err != nil {
-				fmt.Printf("Error getting breakpoint %d from Delve: %v\n", id, err)
-				return
-			}
-
-			// Disable the breakpoint using Delve API
-			bp.Disabled = true // Note: Delve uses Disabled rather than Enabled
-			err = c.debugger.client.AmendBreakpoint(bp)
-			if err != nil {
-				fmt.Printf("Error disabling breakpoint %d in Delve: %v\n", id, err)
-				return
-			}
+		if err := c.bpManager.DisableBreakpoint(id); err != nil {
+			printError("Error disabling breakpoint: %v\n", err)
+			return
 		}
 
 		fmt.Printf("Disabled breakpoint %d\n", id)
@@ -256,85 +470,79 @@ func (c *CLI) handleBreakpointCommand(args []string) {
 	}
 }
 
-// formatEvent returns a string representation of an event
-func (c *CLI) formatEvent(event recorder.Event) string {
-	return fmt.Sprintf("[%s] Event %d: %s - %s",
+// formatEvent returns a string representation of an event, described by
+// the formatter registered for its type
+func (c *CLI) formatEvent(idx int, event recorder.Event) string {
+	if c.formatter != nil {
+		var start time.Time
+		if events := c.replayer.Events(); len(events) > 0 {
+			start = events[0].Timestamp
+		}
+		return c.formatter.format(c.recordingIndex(idx), event, start)
+	}
+
+	formatted := fmt.Sprintf("[%s] Event %d: %s - %s",
 		event.Timestamp.Format(time.RFC3339),
 		event.ID,
-		event.Type,
-		event.Details)
+		styleEventType(event.Type),
+		eventFormatterFor(event.Type).FormatEvent(event))
+
+	// Compacted loops stand for several executions
+	if event.Repeat > 1 {
+		formatted += fmt.Sprintf(" (x%d)", event.Repeat)
+	}
+	if event.TraceID != "" {
+		formatted += fmt.Sprintf(" [trace %s]", event.TraceID)
+	}
+	if event.DetailsLen > 0 {
+		formatted += fmt.Sprintf(" (truncated from %d bytes)", event.DetailsLen)
+	}
+	return formatted
 }
 
 // handleContinue resumes execution
 func (c *CLI) handleContinue() {
 	fmt.Println("Continuing execution...")
 
-	// Create a breakpoint checker function
-	breakpointChecker := func(event recorder.Event) bool {
-		// Check if we have any breakpoints in the breakpoint manager
-		for _, bp := range c.GetBreakpoints() {
-			if !bp.Enabled {
-				continue // Skip disabled breakpoints
-			}
-
-			// For file:line breakpoints, check if event's file and line match
-			if bp.Type == LocationBreakpoint && event.File != "" && event.Line > 0 {
-				// Normalize paths for comparison (convert backslashes to forward slashes)
-				bpFile := strings.ReplaceAll(bp.File, "\\", "/")
-				eventFile := strings.ReplaceAll(event.File, "\\", "/")
-
-				// Normalize case for case-insensitive file systems (e.g., Windows)
-				bpFile = strings.ToLower(bpFile)
-				eventFile = strings.ToLower(eventFile)
-
-				// Debug output for breakpoint comparison
-				fmt.Printf("DEBUG: Checking breakpoint %s:%d against event at %s:%d\n",
-					bpFile, bp.Line, eventFile, event.Line)
-
-				if bpFile == eventFile && bp.Line == event.Line {
-					fmt.Printf("HIT: Breakpoint at %s:%d\n", bp.File, bp.Line)
-					return true
-				}
-			}
-
-			// For function breakpoints, check event details
-			if bp.Type == FunctionBreakpoint && event.Type == recorder.FuncEntry {
-				if strings.Contains(event.Details, bp.Function) ||
-					(event.FuncName != "" && strings.Contains(event.FuncName, bp.Function)) {
-					return true
-				}
-			}
-
-			// For event type breakpoints
-			if bp.Type == EventTypeBreakpoint && event.Type.String() == bp.EventType {
-				return true
-			}
-		}
-
-		return false
-	}
-
-	// Continue in the replayer until breakpoint
-	if err := c.replayer.ReplayUntilBreakpoint(breakpointChecker); err != nil {
-		fmt.Printf("Error continuing execution: %v\n", err)
+	bp, err := Continue(c.replayer, c.bpManager, c.interrupted.Load)
+	if err != nil && !errors.Is(err, ErrEndOfRecording) {
+		printError("Error continuing execution: %v\n", err)
 		return
 	}
+	events := c.replayer.Events()
+	idx := c.replayer.CurrentIndex()
+	switch {
+	case c.interrupted.Load():
+		fmt.Println("Interrupted")
+	case bp == nil:
+	case bp.IsWatchpoint():
+		fmt.Println(style(fmt.Sprintf("HIT: Watchpoint on %s: %s", bp.Expression, events[idx].Details), "bold", "yellow"))
+	case bp.Type == LocationBreakpoint:
+		hit := fmt.Sprintf("HIT: Breakpoint at %s:%d", bp.File, bp.Line)
+		if _, bySuffix := replay.HostPaths.Match(bp.File, events[idx].File); bySuffix {
+			hit += fmt.Sprintf(" in %s (matched by suffix)", events[idx].File)
+		}
+		fmt.Println(style(hit, "bold", "yellow"))
+	}
 
-	// If Delve is available, also continue in the debugger
-	if c.debugger != nil {
+	// If Delve is available, also continue in the debugger, unless Ctrl-C
+	// already stopped the replay
+	if c.debugger != nil && !c.interrupted.Load() {
 		state, err := c.debugger.Continue()
 		if err != nil {
 			fmt.Printf("Delve debugger error: %v\n", err)
-		} else if state != nil {
+			printDelveTimeoutHint(err)
+		} else if state != nil && state.Exited {
+			fmt.Println("The target has exited")
+		} else if state != nil && state.CurrentThread != nil {
 			fmt.Printf("Debugger stopped at: %s:%d\n", state.CurrentThread.File, state.CurrentThread.Line)
+			c.showDisplays()
 		}
 	}
 
 	// Show current event
-	events := c.replayer.Events()
-	idx := c.replayer.CurrentIndex()
 	if idx >= 0 && idx < len(events) {
-		fmt.Printf("Current event: %s\n", c.formatEvent(events[idx]))
+		fmt.Printf("Current event: %s\n", c.formatEvent(idx, events[idx]))
 	}
 }
 
@@ -347,7 +555,7 @@ func (c *CLI) showCurrentVariables() {
 	// Try to get local variables
 	state, err := c.debugger.client.GetState()
 	if err != nil {
-		fmt.Printf("Error getting state: %v\n", err)
+		printError("Error getting state: %v\n", err)
 		return
 	}
 
@@ -363,16 +571,10 @@ func (c *CLI) showCurrentVariables() {
 		vars, err := c.debugger.client.ListLocalVariables(api.EvalScope{
 			GoroutineID: state.CurrentThread.GoroutineID,
 			Frame:       0,
-		}, api.LoadConfig{
-			FollowPointers:     true,
-			MaxVariableRecurse: 1,
-			MaxStringLen:       64,
-			MaxArrayValues:     64,
-			MaxStructFields:    -1,
-		})
+		}, c.debugger.LoadConfig())
 
 		if err != nil {
-			fmt.Printf("Error getting variables: %v\n", err)
+			printError("Error getting variables: %v\n", err)
 			return
 		}
 
@@ -382,40 +584,153 @@ func (c *CLI) showCurrentVariables() {
 		}
 
 		// Print variable info
-		for _, v := range vars {
-			fmt.Printf("%s = %s (type: %s)\n", v.Name, v.Value, v.Type)
+		for i := range vars {
+			printVariable(&vars[i])
 		}
 	}
 }
 
-// handleStep executes a single step forward
-func (c *CLI) handleStep() {
-	// First step in Delve if available
-	if c.debugger != nil {
+// parseStepCount returns the number of events to step, which defaults to 1
+func parseStepCount(args []string) (int, error) {
+	if len(args) == 0 {
+		return 1, nil
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid step count: %s", args[0])
+	}
+	return n, nil
+}
+
+// handleStep steps forward one event, or the given number of events
+func (c *CLI) handleStep(args []string) {
+	count, err := parseStepCount(args)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	events := c.replayer.Events()
+	currentIdx := c.replayer.CurrentIndex()
+	if currentIdx >= len(events)-1 {
+		fmt.Println("Already at the last event")
+		return
+	}
+	if c.stepFilter != "" {
+		target := c.filteredIndex(currentIdx, count, 1)
+		if target < 0 {
+			fmt.Printf("No later events are %s\n", c.filterDescription())
+			return
+		}
+		count = target - currentIdx
+	}
+	if remaining := len(events) - 1 - currentIdx; count > remaining {
+		fmt.Printf("Only %d events left, stepping to the last event\n", remaining)
+		count = remaining
+	}
+
+	// A single step also steps Delve; longer steps resynchronize it once at the end
+	if c.debugger != nil && count == 1 {
 		fmt.Println("Stepping with Delve...")
 		state, err := c.debugger.Step()
 		if err != nil {
 			fmt.Printf("Delve debugger error: %v\n", err)
+			printDelveTimeoutHint(err)
 		} else if state != nil {
 			fmt.Printf("Debugger stepped to: %s:%d\n", state.CurrentThread.File, state.CurrentThread.Line)
 
 			// Show current variables if available
 			c.showCurrentVariables()
+			c.showDisplays()
 		}
 	}
 
 	// Then step in the replayer
-	currentIdx := c.replayer.CurrentIndex()
-	nextIdx := currentIdx + 1
-	if err := c.replayer.ReplayToEventIndex(nextIdx); err != nil {
-		fmt.Printf("Error stepping forward in replayer: %v\n", err)
+	if _, err := StepForward(c.replayer, count); err != nil {
+		printError("Error stepping forward in replayer: %v\n", err)
 		return
 	}
 
+	newIdx := c.replayer.CurrentIndex()
+	if count == 1 {
+		fmt.Printf("Stepped to event: %s\n", c.formatEvent(newIdx, events[newIdx]))
+	} else {
+		fmt.Printf("Stepped %d events to event: %s\n", count, c.formatEvent(newIdx, events[newIdx]))
+		if c.debugger != nil {
+			if err := c.syncDebuggerToEvent(newIdx); err != nil {
+				printError("Error synchronizing debugger state: %v\n", err)
+			}
+			c.showDisplays()
+		}
+	}
+}
+
+// checkSync compares the current replay event with where Delve's current
+// thread is stopped. It reports whether they agree, with a description of
+// the location, or of the discrepancy when they don't. Only the fields the
+// event records are compared.
+func (c *CLI) checkSync() (bool, string) {
+	if c.debugger == nil {
+		return false, "Delve integration not enabled"
+	}
 	events := c.replayer.Events()
-	if nextIdx >= 0 && nextIdx < len(events) {
-		fmt.Printf("Stepped to event: %s\n", c.formatEvent(events[nextIdx]))
+	idx := c.replayer.CurrentIndex()
+	if idx < 0 || idx >= len(events) {
+		return false, "No current event to compare"
+	}
+	event := events[idx]
+	if event.File == "" && event.FuncName == "" {
+		return false, fmt.Sprintf("Event %d has no location to compare", c.recordingIndex(idx))
+	}
+
+	state, err := c.debugger.client.GetState()
+	if err != nil {
+		return false, fmt.Sprintf("Error getting debugger state: %v", err)
+	}
+	if state.Exited {
+		return false, "The target has exited"
+	}
+	thread := state.CurrentThread
+	if thread == nil {
+		return false, "Delve has no current thread"
+	}
+	delveFunc := ""
+	if thread.Function != nil {
+		delveFunc = thread.Function.Name()
+	}
+
+	var diffs []string
+	if event.File != "" && !sameSourceFile(event.File, thread.File) {
+		diffs = append(diffs, fmt.Sprintf("file: replay %s, Delve %s", event.File, thread.File))
+	}
+	if event.Line > 0 && event.Line != thread.Line {
+		diffs = append(diffs, fmt.Sprintf("line: replay %d, Delve %d", event.Line, thread.Line))
+	}
+	if event.FuncName != "" && NormalizeFuncName(event.FuncName) != NormalizeFuncName(delveFunc) {
+		diffs = append(diffs, fmt.Sprintf("function: replay %s, Delve %s", event.FuncName, delveFunc))
+	}
+	if len(diffs) > 0 {
+		return false, fmt.Sprintf("Out of sync at event %d:\n  %s", c.recordingIndex(idx), strings.Join(diffs, "\n  "))
+	}
+	return true, fmt.Sprintf("In sync at event %d: %s:%d in %s", c.recordingIndex(idx), thread.File, thread.Line, delveFunc)
+}
+
+// sameSourceFile reports whether two paths name the same source file, where
+// either may be relative to the module, e.g. "main.go" and "/src/app/main.go"
+func sameSourceFile(a, b string) bool {
+	ok, _ := replay.HostPaths.Match(a, b)
+	return ok
+}
+
+// handleVerifySync reports whether the replay and the live process agree on
+// where execution is
+func (c *CLI) handleVerifySync() {
+	ok, msg := c.checkSync()
+	if ok || c.debugger == nil {
+		fmt.Println(msg)
+		return
 	}
+	printError("%s\n", msg)
 }
 
 // syncDebuggerToEvent tries to synchronize the debugger state with the current event
@@ -426,7 +741,7 @@ func (c *CLI) syncDebuggerToEvent(eventIdx int) error {
 	}
 
 	event := events[eventIdx]
-	fmt.Printf("Synchronizing debugger to event: %s\n", c.formatEvent(event))
+	fmt.Printf("Synchronizing debugger to event: %s\n", c.formatEvent(eventIdx, event))
 
 	// Try multiple synchronization strategies
 
@@ -496,7 +811,7 @@ func (c *CLI) syncDebuggerToEvent(eventIdx int) error {
 		if err == nil {
 			for _, g := range goroutines {
 				if g.CurrentLoc.Function != nil &&
-					strings.Contains(g.CurrentLoc.Function.Name(), event.FuncName) {
+					FuncNameMatches(g.CurrentLoc.Function.Name(), event.FuncName) {
 					// Switch to this goroutine
 					_, err := c.debugger.client.SwitchGoroutine(g.ID)
 					if err == nil {
@@ -573,30 +888,13 @@ func (c *CLI) syncDebuggerToEvent(eventIdx int) error {
 	return fmt.Errorf("could not precisely synchronize debugger state to event %d", eventIdx)
 }
 
-// resetDebuggerToEvent restarts the Delve debugger and brings it to a state matching the current event
-func (c *CLI) resetDebuggerToEvent(eventIdx int) error {
-	if c.debugger == nil {
-		return nil // No debugger to reset
-	}
-
-	events := c.replayer.Events()
-	if eventIdx < 0 || eventIdx >= len(events) {
-		return fmt.Errorf("invalid event index: %d", eventIdx)
-	}
-
-	fmt.Println("Resetting debugger state to match replayer...")
-
+// restartDebugger starts a new Delve session for the target, with the
+// managed breakpoints and load limits of the old one, stopped at main.main
+func (c *CLI) restartDebugger() error {
 	// Get the current target program
 	targetPath := c.debugger.target
 
-	// Store existing breakpoints before closing so we can restore them
-	var existingBreakpoints []*api.Breakpoint
-	if c.debugger.client != nil {
-		bps, err := c.debugger.client.ListBreakpoints(false)
-		if err == nil {
-			existingBreakpoints = bps
-		}
-	}
+	loadConfig := c.debugger.LoadConfig()
 
 	// Close the current debugger session
 	if err := c.debugger.Close(); err != nil {
@@ -605,38 +903,18 @@ func (c *CLI) resetDebuggerToEvent(eventIdx int) error {
 
 	// Create a new debugger session
 	var err error
-	c.debugger, err = NewDelveDebugger(targetPath)
+	c.debugger, err = startDelveDebugger(targetPath)
 	if err != nil {
+		// c.debugger is nil now, so later steps don't try to restart Delve
+		c.bpManager.SetBackend(nil)
+		fmt.Println("Continuing in replay-only mode (no live debugging)")
 		return fmt.Errorf("failed to restart debugger: %v", err)
 	}
 
-	// Restore previous breakpoints
-	for _, bp := range existingBreakpoints {
-		// Skip internal breakpoints (those with special IDs)
-		if bp.ID <= 0 {
-			continue
-		}
-
-		// Restore based on file:line
-		if bp.File != "" && bp.Line > 0 {
-			fmt.Printf("Restoring breakpoint at %s:%d\n", bp.File, bp.Line)
-			_, err := c.debugger.SetBreakpoint(bp.File, bp.Line)
-			if err != nil {
-				fmt.Printf("Warning: failed to restore breakpoint at %s:%d: %v\n",
-					bp.File, bp.Line, err)
-			}
-		}
-	}
+	c.debugger.SetLoadConfig(loadConfig)
 
-	// Build a map of all available file:line locations from recorded events
-	// This helps with finding the nearest valid execution point
-	validLocations := make(map[string]bool)
-	for _, e := range events {
-		if e.File != "" && e.Line > 0 {
-			key := fmt.Sprintf("%s:%d", e.File, e.Line)
-			validLocations[key] = true
-		}
-	}
+	// Set the managed breakpoints in the new process
+	c.bpManager.SetBackend(c.debugger)
 
 	// Try setting a breakpoint at main() to start
 	mainBp, _ := c.debugger.client.CreateBreakpoint(&api.Breakpoint{
@@ -653,8 +931,37 @@ func (c *CLI) resetDebuggerToEvent(eventIdx int) error {
 		}
 	}
 
+	return nil
+}
+
+// resetDebuggerToEvent restarts the Delve debugger and brings it to a state matching the current event
+func (c *CLI) resetDebuggerToEvent(eventIdx int) error {
+	if c.debugger == nil {
+		return nil // No debugger to reset
+	}
+
+	events := c.replayer.Events()
+	if eventIdx < 0 || eventIdx >= len(events) {
+		return fmt.Errorf("invalid event index: %d", eventIdx)
+	}
+
+	fmt.Println("Resetting debugger state to match replayer...")
+	if err := c.restartDebugger(); err != nil {
+		return err
+	}
+
+	// Build a map of all available file:line locations from recorded events
+	// This helps with finding the nearest valid execution point
+	validLocations := make(map[string]bool)
+	for _, e := range events {
+		if e.File != "" && e.Line > 0 {
+			key := fmt.Sprintf("%s:%d", e.File, e.Line)
+			validLocations[key] = true
+		}
+	}
+
 	// Now try to synchronize to the specific event
-	err = c.syncDebuggerToEvent(eventIdx)
+	err := c.syncDebuggerToEvent(eventIdx)
 	if err != nil {
 		// If sync fails, try to get reasonably close
 		fmt.Printf("Warning: precise sync failed, using best-effort approach: %v\n", err)
@@ -694,61 +1001,362 @@ func (c *CLI) resetDebuggerToEvent(eventIdx int) error {
 	return nil
 }
 
-// handleBackstep steps backward one event
-func (c *CLI) handleBackstep() {
-	currentIdx := c.replayer.CurrentIndex()
-	newIdx, err := c.replayer.StepBackward(currentIdx)
+// handleBackstep steps backward one event, or the given number of events
+func (c *CLI) handleBackstep(args []string) {
+	count, err := parseStepCount(args)
 	if err != nil {
-		fmt.Printf("Error stepping backward: %v\n", err)
+		fmt.Println(err)
+		return
+	}
+
+	currentIdx := c.replayer.CurrentIndex()
+	if currentIdx <= 0 {
+		printError("Error stepping backward: already at the beginning")
+		return
+	}
+	if c.stepFilter != "" {
+		target := c.filteredIndex(currentIdx, count, -1)
+		if target < 0 {
+			fmt.Printf("No earlier events are %s\n", c.filterDescription())
+			return
+		}
+		count = currentIdx - target
+	}
+	if count > currentIdx {
+		fmt.Printf("Only %d events before this one, stepping to the first event\n", currentIdx)
+		count = currentIdx
+	}
+
+	if _, err := StepBackward(c.replayer, count); err != nil {
+		printError("Error stepping backward: %v\n", err)
 		return
 	}
 
 	events := c.replayer.Events()
+	newIdx := c.replayer.CurrentIndex()
 	if newIdx >= 0 && newIdx < len(events) {
-		fmt.Printf("Stepped back to event: %s\n", c.formatEvent(events[newIdx]))
+		if count == 1 {
+			fmt.Printf("Stepped back to event: %s\n", c.formatEvent(newIdx, events[newIdx]))
+		} else {
+			fmt.Printf("Stepped back %d events to event: %s\n", count, c.formatEvent(newIdx, events[newIdx]))
+		}
 
-		// If Delve is available, reset the debugging session
+		// If Delve is available, reset the debugging session once
 		// to match the replayer's new state, as Delve can't step backward
 		if c.debugger != nil {
 			if err := c.resetDebuggerToEvent(newIdx); err != nil {
-				fmt.Printf("Error synchronizing debugger state: %v\n", err)
+				printError("Error synchronizing debugger state: %v\n", err)
 			}
 		}
 	}
 }
 
-// handleInfo shows current execution state
-func (c *CLI) handleInfo() {
-	events := c.replayer.Events()
-	idx := c.replayer.CurrentIndex()
-	if idx >= 0 && idx < len(events) {
-		fmt.Printf("\nCurrent event: %s\n", c.formatEvent(events[idx]))
-	} else {
-		fmt.Println("No current event")
+// handleRestart starts the session over from before the first event,
+// restarting Delve at main.main if it is attached
+func (c *CLI) handleRestart() {
+	if resetter, ok := c.replayer.(interface{ Reset() }); ok {
+		resetter.Reset()
+	} else if err := c.replayer.LoadEvents(c.replayer.Events()); err != nil {
+		printError("Error restarting replay: %v\n", err)
+		return
 	}
+	fmt.Printf("Restarted replay, %d events to go\n", len(c.replayer.Events()))
 
-	// If Delve is available, show debugger state
 	if c.debugger != nil {
-		state, err := c.debugger.client.GetState()
-		if err != nil {
-			fmt.Printf("Error getting debugger state: %v\n", err)
-			return
-		}
-
-		if state.CurrentThread != nil {
-			fmt.Printf("\nDebugger state:\n")
-			fmt.Printf("  Current position: %s:%d\n", state.CurrentThread.File, state.CurrentThread.Line)
-			if state.CurrentThread.Function != nil {
-				fmt.Printf("  Current function: %s\n", state.CurrentThread.Function.Name())
-			}
-			fmt.Printf("  Goroutine: %d\n", state.CurrentThread.GoroutineID)
+		if err := c.restartDebugger(); err != nil {
+			fmt.Printf("Warning: %v\n", err)
 		}
 	}
 }
 
-// Delve-specific command handlers
+// handleEnd jumps to the last recorded event
+func (c *CLI) handleEnd() {
+	if err := c.SeekEnd(); err != nil {
+		printError("Error jumping to end: %v\n", err)
+		return
+	}
 
-// handleBreakpoint sets a breakpoint at the specified location
+	events := c.replayer.Events()
+	fmt.Printf("At last event: %s\n", c.formatEvent(len(events)-1, events[len(events)-1]))
+}
+
+// SeekEnd positions replay at the last recorded event, with goroutine and
+// channel state rebuilt from the whole recording, so the session can be
+// walked backward from the end like a post-mortem
+func (c *CLI) SeekEnd() error {
+	events := c.replayer.Events()
+	if len(events) == 0 {
+		return fmt.Errorf("no events loaded")
+	}
+
+	if seeker, ok := c.replayer.(interface{ SeekEnd() error }); ok {
+		return seeker.SeekEnd()
+	}
+	return c.replayer.ReplayToEventIndex(len(events) - 1)
+}
+
+// handleInfo shows the full context of the current position, as JSON with --json
+func (c *CLI) handleInfo(args []string) {
+	ctx := c.replayContext()
+	if len(args) > 0 && args[0] == "--json" {
+		data, err := json.MarshalIndent(ctx, "", "  ")
+		if err != nil {
+			printError("Error encoding context: %v\n", err)
+			return
+		}
+		fmt.Println(string(data))
+		return
+	}
+
+	events := c.replayer.Events()
+	if ctx.Event != nil {
+		fmt.Printf("\nCurrent event: %s\n", c.formatEvent(ctx.Index, *ctx.Event))
+	} else {
+		fmt.Println("No current event")
+	}
+	if offset, total := c.window(); offset > 0 || total > len(events) {
+		fmt.Printf("Viewing events %d–%d of %s\n", offset, offset+len(events)-1, formatCount(total))
+	}
+	if ctx.Event != nil {
+		fmt.Printf("Goroutine: %s\n", formatGoroutine(ctx.Goroutine))
+		if len(ctx.CallStack) > 0 {
+			fmt.Println("Call stack:")
+			for i := len(ctx.CallStack) - 1; i >= 0; i-- {
+				frame := ctx.CallStack[i]
+				fmt.Printf("  #%d %s", len(ctx.CallStack)-1-i, frame.FuncName)
+				if frame.File != "" {
+					fmt.Printf(" at %s:%d", frame.File, frame.Line)
+				}
+				fmt.Println()
+			}
+		}
+		if len(ctx.Locals) > 0 {
+			fmt.Println("Locals:")
+			for _, local := range ctx.Locals {
+				fmt.Printf("  %s = %s (event %d)\n", local.Name, local.Value, ctx.Offset+local.EventIdx)
+			}
+		}
+		if ctx.Snapshot >= 0 {
+			fmt.Printf("Nearest snapshot: event %d\n", ctx.Offset+ctx.Snapshot)
+		}
+	}
+	if len(ctx.Breakpoints) > 0 {
+		fmt.Println("Breakpoints:")
+		for _, bp := range ctx.Breakpoints {
+			fmt.Printf("  %s\n", bp)
+		}
+	}
+
+	// If Delve is available, show debugger state
+	if c.debugger != nil {
+		state, err := c.debugger.client.GetState()
+		if err != nil {
+			printError("Error getting debugger state: %v\n", err)
+			return
+		}
+
+		if state.CurrentThread != nil {
+			fmt.Printf("\nDebugger state:\n")
+			fmt.Printf("  Current position: %s:%d\n", state.CurrentThread.File, state.CurrentThread.Line)
+			if state.CurrentThread.Function != nil {
+				fmt.Printf("  Current function: %s\n", state.CurrentThread.Function.Name())
+			}
+			fmt.Printf("  Goroutine: %d\n", state.CurrentThread.GoroutineID)
+		}
+	}
+}
+
+// replayContext assembles the context of the current position, the
+// replayer's along with the enabled breakpoints and watchpoints
+func (c *CLI) replayContext() replay.ReplayContext {
+	var ctx replay.ReplayContext
+	if contexter, ok := c.replayer.(interface{ Context() replay.ReplayContext }); ok {
+		ctx = contexter.Context()
+	} else {
+		events := c.replayer.Events()
+		ctx = replay.ReplayContext{Index: c.replayer.CurrentIndex(), Snapshot: -1}
+		ctx.Offset, _ = c.window()
+		if ctx.Index >= 0 && ctx.Index < len(events) {
+			event := events[ctx.Index]
+			ctx.Event = &event
+			ctx.Goroutine = event.GoroutineID
+			ctx.CallStack = replay.CallStack(events, ctx.Index)
+			ctx.Locals = replay.Locals(events, ctx.Index)
+		}
+	}
+
+	for _, bp := range c.GetBreakpoints() {
+		if bp.Enabled {
+			where, kind := describeBreakpoint(bp)
+			ctx.Breakpoints = append(ctx.Breakpoints, fmt.Sprintf("%d: %s (%s)", bp.ID, where, kind))
+		}
+	}
+	return ctx
+}
+
+// handleRecordingInfo prints the build and environment the recording was made in
+func (c *CLI) handleRecordingInfo() {
+	md := c.metadata
+	if md == nil && c.eventsFile != "" {
+		var err error
+		if md, err = recorder.ReadMetadata(c.eventsFile); err != nil {
+			printError("Error reading recording metadata: %v\n", err)
+			return
+		}
+	}
+	if md == nil {
+		fmt.Println("The recording has no metadata")
+		return
+	}
+	fmt.Printf("\n%s\n", md)
+}
+
+// defaultMapBuckets is the number of buckets shown by the map command
+const defaultMapBuckets = 60
+
+// eventTypeGlyph returns the single character used for an event type in the map
+func eventTypeGlyph(t recorder.EventType) byte {
+	switch t {
+	case recorder.FuncEntry:
+		return 'E'
+	case recorder.FuncExit:
+		return 'X'
+	case recorder.VarAssignment:
+		return 'V'
+	case recorder.GoroutineSwitch:
+		return 'G'
+	case recorder.StatementExecution:
+		return 'S'
+	case recorder.ChannelOperation:
+		return 'C'
+	case recorder.SyncOperation:
+		return 'M'
+	case recorder.SnapshotEvent:
+		return '#'
+	case recorder.RngEvent:
+		return 'R'
+	case recorder.TimeReadEvent:
+		return 'T'
+	case recorder.SelectEvent:
+		return 'L'
+	case recorder.DeferOperation:
+		return 'D'
+	case recorder.ErrorEvent:
+		return '!'
+	case recorder.IOEvent:
+		return 'O'
+	case recorder.RuntimeEvent:
+		return 'U'
+	case recorder.RecordingStopped:
+		return '|'
+	case recorder.NetworkOperation:
+		return 'N'
+	case recorder.RotationEvent:
+		return '~'
+	case recorder.ContextEvent:
+		return 'K'
+	case recorder.ProcessSpawnEvent:
+		return 'P'
+	}
+	if t >= recorder.FirstUserEventType && t.String() != "Unknown" {
+		return '@'
+	}
+	return '?'
+}
+
+// handleMap prints a compact overview of the whole recording with the
+// current position and breakpoint locations marked
+func (c *CLI) handleMap(args []string) {
+	histogrammer, ok := c.replayer.(interface {
+		Histogram(buckets int) []replay.BucketStat
+	})
+	if !ok {
+		fmt.Println("Map is not supported by this replayer")
+		return
+	}
+
+	buckets := defaultMapBuckets
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n <= 0 {
+			fmt.Printf("Invalid bucket count: %s\n", args[0])
+			return
+		}
+		buckets = n
+	}
+
+	stats := histogrammer.Histogram(buckets)
+	if len(stats) == 0 {
+		fmt.Println("No events loaded")
+		return
+	}
+
+	events := c.replayer.Events()
+	idx := c.replayer.CurrentIndex()
+	breakpoints := c.GetBreakpoints()
+
+	var watchpointHits []int
+	if watched, ok := c.replayer.(interface{ WatchpointHits() []int }); ok {
+		watchpointHits = watched.WatchpointHits()
+	}
+
+	bar := make([]byte, len(stats))
+	marks := make([]byte, len(stats))
+	for b, stat := range stats {
+		bar[b] = eventTypeGlyph(stat.Dominant)
+		marks[b] = ' '
+
+		for i := stat.StartIdx; i <= stat.EndIdx && marks[b] == ' '; i++ {
+			for _, bp := range breakpoints {
+				if bp.Matches(events[i]) {
+					marks[b] = '*'
+					break
+				}
+			}
+		}
+
+		// Watchpoints that fired this session take precedence over breakpoints
+		for _, hit := range watchpointHits {
+			if hit >= stat.StartIdx && hit <= stat.EndIdx {
+				marks[b] = 'w'
+				break
+			}
+		}
+
+		// Segment boundaries after the first start a new segment in this bucket
+		for _, segment := range c.segments {
+			if segment.StartIdx > 0 && segment.StartIdx >= stat.StartIdx && segment.StartIdx <= stat.EndIdx && marks[b] == ' ' {
+				marks[b] = '|'
+			}
+		}
+
+		if idx >= stat.StartIdx && idx <= stat.EndIdx {
+			marks[b] = '^'
+		}
+	}
+
+	fmt.Printf("\nRecording map: %d events in %d buckets\n", len(events), len(stats))
+	fmt.Printf("  |%s|\n", bar)
+	fmt.Printf("   %s\n", strings.TrimRight(string(marks), " "))
+	if idx >= 0 && idx < len(events) {
+		_, total := c.window()
+		fmt.Printf("  ^ current event %d of %d\n", c.recordingIndex(idx), total)
+	} else {
+		fmt.Println("  ^ not started")
+	}
+	fmt.Println("  * breakpoint")
+	if len(watchpointHits) > 0 {
+		fmt.Printf("  w watchpoint hit (%d this session)\n", len(watchpointHits))
+	}
+	if len(c.segments) > 0 {
+		fmt.Printf("  | segment boundary (%d segments)\n", len(c.segments))
+	}
+	fmt.Println("  Legend: E=entry X=exit V=assignment S=statement G=goroutine C=channel M=sync #=snapshot R=random T=time L=select D=defer !=error O=I/O U=runtime N=network |=stopped ~=rotated K=context P=spawn @=registered")
+}
+
+// Delve-specific command handlers
+
+// handleBreakpoint sets a breakpoint at the specified location
 func (c *CLI) handleBreakpoint(args []string) {
 	if c.debugger == nil {
 		fmt.Println("Delve integration not enabled")
@@ -760,6 +1368,7 @@ func (c *CLI) handleBreakpoint(args []string) {
 		fmt.Println("Optional: breakpoint <file:line> -c <condition>")
 		return
 	}
+	c.bpManager.SetRecordedLocations(c.replayer.Events())
 
 	// Check for conditional breakpoint syntax
 	var condition string
@@ -774,25 +1383,29 @@ func (c *CLI) handleBreakpoint(args []string) {
 		locationArg = args[0]
 	}
 
+	// Expand shorthand like "processData" or "payment.go:20"
+	locationArg, err := c.bpManager.ResolveLocation(locationArg)
+	if err != nil {
+		printError("Error setting breakpoint: %v\n", err)
+		return
+	}
+	if !strings.HasPrefix(locationArg, "func:") && !strings.Contains(locationArg, ":") {
+		fmt.Printf("No function matches %s. Use file:line (e.g., main.go:42) or func:functionName\n", locationArg)
+		return
+	}
+
 	// Check if this is a function breakpoint
 	if strings.HasPrefix(locationArg, "func:") {
 		funcName := strings.TrimPrefix(locationArg, "func:")
 
-		// Set a function breakpoint
-		dbp, err := c.debugger.SetFunctionBreakpoint(funcName)
-		if err != nil {
-			fmt.Printf("Error setting function breakpoint: %v\n", err)
-			return
-		}
-
-		// Add to our breakpoint manager
 		bp, err := c.bpManager.AddBreakpoint("func:" + funcName)
 		if err != nil {
-			fmt.Printf("Warning: Error adding breakpoint to manager: %v\n", err)
+			printError("Error setting function breakpoint: %v\n", err)
+			return
 		}
 
 		fmt.Printf("Function breakpoint %d set at %s (Delve bp: %d)\n",
-			bp.ID, funcName, dbp.ID)
+			bp.ID, funcName, bp.DelveID)
 		return
 	}
 
@@ -819,79 +1432,72 @@ func (c *CLI) handleBreakpoint(args []string) {
 		return
 	}
 
-	// Set breakpoint in the Delve debugger
-	var dbp *api.Breakpoint
-	var breakpointErr error
-
-	if condition != "" {
-		// Set conditional breakpoint
-		dbp, breakpointErr = c.debugger.SetConditionalBreakpoint(file, line, condition)
-	} else {
-		// Regular breakpoint
-		dbp, breakpointErr = c.debugger.SetBreakpoint(file, line)
-	}
-
-	if breakpointErr != nil {
-		fmt.Printf("Error setting breakpoint: %v\n", breakpointErr)
-		return
-	}
-
-	// Also add the breakpoint to our own manager
-	bp, err := c.bpManager.AddBreakpoint(fmt.Sprintf("%s:%d", file, line))
+	bp, err := c.bpManager.AddConditionalBreakpoint(fmt.Sprintf("%s:%d", file, line), condition)
 	if err != nil {
-		fmt.Printf("Warning: Error adding breakpoint to manager: %v\n", err)
+		printError("Error setting breakpoint: %v\n", err)
+		return
 	}
 
 	if condition != "" {
 		fmt.Printf("Conditional breakpoint %d set at %s:%d with condition '%s' (Delve bp: %d)\n",
-			bp.ID, file, line, condition, dbp.ID)
+			bp.ID, file, line, condition, bp.DelveID)
 	} else {
-		fmt.Printf("Breakpoint %d set at %s:%d (Delve bp: %d)\n", bp.ID, file, line, dbp.ID)
+		fmt.Printf("Breakpoint %d set at %s:%d (Delve bp: %d)\n", bp.ID, file, line, bp.DelveID)
 	}
 }
 
-// handleListBreakpoints lists all breakpoints
+// describeBreakpoint returns where a breakpoint stops and what kind it is
+func describeBreakpoint(bp *Breakpoint) (where, kind string) {
+	switch bp.Type {
+	case LocationBreakpoint:
+		where, kind = fmt.Sprintf("%s:%d", bp.File, bp.Line), "location"
+		if bp.Condition != "" {
+			where += " if " + bp.Condition
+		}
+	case FunctionBreakpoint:
+		where, kind = bp.Function, "function"
+	case EventTypeBreakpoint:
+		where, kind = bp.EventType, "event"
+	case WatchpointRead, WatchpointWrite, WatchpointReadWrite:
+		where, kind = bp.Expression, "watch"
+	}
+	return where, kind
+}
+
+// handleListBreakpoints lists all breakpoints in one table, with the Delve
+// breakpoint each is mirrored to
 func (c *CLI) handleListBreakpoints() {
 	fmt.Println("\nBreakpoints:")
 
-	// Show our managed breakpoints
+	managed := make(map[int]bool)
 	for _, bp := range c.GetBreakpoints() {
-		status := "enabled"
-		if !bp.Enabled {
-			status = "disabled"
+		status := styleStatus(bp.Enabled)
+		delve := "-"
+		if bp.DelveID != 0 {
+			delve = strconv.Itoa(bp.DelveID)
+			managed[bp.DelveID] = true
 		}
 
-		switch bp.Type {
-		case LocationBreakpoint:
-			fmt.Printf("%d: %s:%d (location) [%s]\n", bp.ID, bp.File, bp.Line, status)
-		case FunctionBreakpoint:
-			fmt.Printf("%d: %s (function) [%s]\n", bp.ID, bp.Function, status)
-		case EventTypeBreakpoint:
-			fmt.Printf("%d: %s (event) [%s]\n", bp.ID, bp.EventType, status)
-		}
+		where, kind := describeBreakpoint(bp)
+		fmt.Printf("%d: %s (%s) [%s] Delve: %s\n", bp.ID, where, kind, status, delve)
 	}
 
-	// If Delve is enabled, also show Delve breakpoints
+	// Breakpoints set directly in Delve, outside the manager
 	if c.debugger != nil {
-		// Get breakpoints from Delve API
-		breakpoints, err := c.debugger.client.ListBreakpoints(false)
+		breakpoints, err := c.debugger.ListBreakpoints()
 		if err != nil {
-			fmt.Printf("Error listing Delve breakpoints: %v\n", err)
+			printError("Error listing Delve breakpoints: %v\n", err)
 			return
 		}
 
-		// Show Delve breakpoints if any
-		if len(breakpoints) > 0 {
-			fmt.Println("\nDelve Breakpoints:")
-			for _, bp := range breakpoints {
-				status := "enabled"
-				if bp.Disabled {
-					status = "disabled"
-				}
-
-				fmt.Printf("[%d] %s:%d %s (%s)\n",
-					bp.ID, bp.File, bp.Line, bp.FunctionName, status)
+		for _, bp := range breakpoints {
+			// Skip internal breakpoints (those with special IDs)
+			if bp.ID <= 0 || managed[bp.ID] {
+				continue
 			}
+			status := styleStatus(!bp.Disabled)
+			fmt.Printf("-: %s:%d %s (delve only) [%s] Delve: %d\n",
+				bp.File, bp.Line, bp.FunctionName, status, bp.ID)
 		}
 	}
 }
@@ -911,111 +1517,1456 @@ func (c *CLI) handlePrintVariable(args []string) {
 	varName := args[0]
 	v, err := c.debugger.GetVariable(varName)
 	if err != nil {
-		fmt.Printf("Error getting variable '%s': %v\n", varName, err)
+		printError("Error getting variable '%s': %v\n", varName, err)
+		printDelveTimeoutHint(err)
 		return
 	}
 
-	fmt.Printf("%s = %s (type: %s)\n", v.Name, v.Value, v.Type)
+	printVariable(v)
 }
 
-// handleListGoroutines lists all goroutines
-func (c *CLI) handleListGoroutines() {
+// printVariable prints a variable inspected through Delve, pretty printing composite values
+func printVariable(v *api.Variable) {
+	fmt.Printf("%s = %s (type: %s)\n", v.Name, PrettyValue(v.Type, variableValue(v)), v.Type)
+}
+
+// variableValue returns the value of a variable inspected through Delve
+func variableValue(v *api.Variable) string {
+	// Delve only fills Value for scalars
+	if v.Value == "" {
+		return v.SinglelineString()
+	}
+	return v.Value
+}
+
+// handleSetVariable changes a variable in the live process and records the
+// change as a synthetic assignment event in the replay timeline
+func (c *CLI) handleSetVariable(args []string) {
 	if c.debugger == nil {
-		fmt.Println("Delve integration not enabled")
+		fmt.Println("Delve integration not enabled: set requires a live debugging session")
 		return
 	}
 
-	goroutines, err := c.debugger.ListGoroutines()
-	if err != nil {
-		fmt.Printf("Error listing goroutines: %v\n", err)
+	assignment := strings.Join(args, " ")
+	eqIndex := strings.Index(assignment, "=")
+	if eqIndex <= 0 {
+		fmt.Println("Usage: set <variable>=<value>")
 		return
 	}
 
-	fmt.Printf("Found %d goroutines:\n", len(goroutines))
-	for i, g := range goroutines {
-		fmt.Printf("[%d] Goroutine %d", i, g.ID)
-		if g.CurrentLoc.Function != nil {
-			fmt.Printf(" - %s (%s:%d)", g.CurrentLoc.Function.Name(), g.CurrentLoc.File, g.CurrentLoc.Line)
+	name := strings.TrimSpace(assignment[:eqIndex])
+	value := strings.TrimSpace(assignment[eqIndex+1:])
+	if name == "" || value == "" {
+		fmt.Println("Usage: set <variable>=<value>")
+		return
+	}
+
+	if err := c.debugger.SetVariable(name, value); err != nil {
+		printError("Error setting variable: %v\n", err)
+		return
+	}
+	fmt.Printf("%s = %s\n", name, value)
+
+	// Record the change in the timeline, stamped and numbered by the
+	// replayer to keep it in order
+	event := recorder.Event{
+		Type:    recorder.VarAssignment,
+		Details: fmt.Sprintf("Debugger set %s = %s", name, value),
+	}
+	if state, err := c.debugger.client.GetState(); err == nil && state.CurrentThread != nil {
+		event.File = state.CurrentThread.File
+		event.Line = state.CurrentThread.Line
+		if state.CurrentThread.Function != nil {
+			event.FuncName = state.CurrentThread.Function.Name()
 		}
-		fmt.Println()
+	}
+	if err := c.insertEvent(event); err != nil {
+		fmt.Printf("Warning: could not record assignment in timeline: %v\n", err)
 	}
 }
 
-// handleWatch handles the watch command
-func (c *CLI) handleWatch(args []string) {
-	if len(args) < 1 {
-		fmt.Println("Usage: watch [-r|-w|-rw] <expression>")
-		fmt.Println("  -r    stops when the memory location is read")
-		fmt.Println("  -w    stops when the memory location is written")
-		fmt.Println("  -rw   stops when the memory location is read or written (default)")
+// insertEvent inserts a synthetic event after the current event, if the
+// replayer supports it, moving the session's tags after it along
+func (c *CLI) insertEvent(event recorder.Event) error {
+	inserter, ok := c.replayer.(interface {
+		InsertEvent(event recorder.Event) error
+	})
+	if !ok {
+		return nil
+	}
+	if err := inserter.InsertEvent(event); err != nil {
+		return err
+	}
+
+	pos := c.replayer.CurrentIndex()
+	shifted := make(map[int][]string, len(c.tags))
+	for idx, labels := range c.tags {
+		if idx >= pos {
+			idx++
+		}
+		shifted[idx] = labels
+	}
+	c.tags = shifted
+	return nil
+}
+
+// handleConfig shows or changes the limits used when printing variables
+func (c *CLI) handleConfig(args []string) {
+	if c.debugger == nil {
+		fmt.Println("Delve integration not enabled: config requires a live debugging session")
 		return
 	}
 
-	// Parse flags
-	var readFlag, writeFlag bool = true, true // Default to -rw
-	var expr string
+	cfg := c.debugger.LoadConfig()
+	if len(args) == 0 {
+		fmt.Printf("loadstring %d\n", cfg.MaxStringLen)
+		fmt.Printf("loadarray  %d\n", cfg.MaxArrayValues)
+		return
+	}
 
-	if args[0] == "-r" {
-		if len(args) < 2 {
-			fmt.Println("Expression required")
+	if len(args) != 2 {
+		fmt.Println("Usage: config [loadstring|loadarray <n>]")
+		return
+	}
+	n, err := strconv.Atoi(args[1])
+	if err != nil || n < 0 {
+		fmt.Printf("Invalid limit: %s\n", args[1])
+		return
+	}
+
+	switch args[0] {
+	case "loadstring":
+		cfg.MaxStringLen = n
+	case "loadarray":
+		cfg.MaxArrayValues = n
+	default:
+		fmt.Println("Usage: config [loadstring|loadarray <n>]")
+		return
+	}
+	c.debugger.SetLoadConfig(cfg)
+	fmt.Printf("%s set to %d\n", args[0], n)
+}
+
+// handleHistory prints the timeline of values assigned to a variable in the
+// current function, or in every function with --all. "history <var> goto <n>"
+// jumps to the nth entry of that timeline.
+func (c *CLI) handleHistory(args []string) {
+	usage := "Usage: history <variable> [--all] [goto <n>]"
+	if len(args) == 0 {
+		fmt.Println(usage)
+		return
+	}
+	varName := args[0]
+	all := false
+	gotoEntry := 0
+	for i := 1; i < len(args); i++ {
+		switch args[i] {
+		case "--all", "-all":
+			all = true
+		case "goto":
+			if i+1 >= len(args) {
+				fmt.Println(usage)
+				return
+			}
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil || n <= 0 {
+				fmt.Printf("Invalid history entry: %s\n", args[i+1])
+				return
+			}
+			gotoEntry = n
+			i++
+		default:
+			fmt.Println(usage)
 			return
 		}
-		readFlag, writeFlag = true, false
-		expr = args[1]
-	} else if args[0] == "-w" {
-		if len(args) < 2 {
-			fmt.Println("Expression required")
+	}
+
+	historian, ok := c.replayer.(interface {
+		ValueHistory(varName string) []replay.ValueChange
+	})
+	if !ok {
+		fmt.Println("History is not supported by this replayer")
+		return
+	}
+
+	history := historian.ValueHistory(varName)
+	idx := c.replayer.CurrentIndex()
+	scope := ""
+	if events := c.replayer.Events(); !all && idx >= 0 && idx < len(events) {
+		scope = events[idx].FuncName
+	}
+	if scope != "" {
+		inScope := history[:0:0]
+		for _, change := range history {
+			if change.FuncName == scope {
+				inScope = append(inScope, change)
+			}
+		}
+		history = inScope
+	}
+	if len(history) == 0 {
+		if scope != "" {
+			fmt.Printf("No assignments to %s recorded in %s; use --all to search every function\n", varName, scope)
+		} else {
+			fmt.Printf("No assignments to %s recorded\n", varName)
+		}
+		return
+	}
+
+	if gotoEntry > 0 {
+		if gotoEntry > len(history) {
+			fmt.Printf("History of %s has %d entries\n", varName, len(history))
 			return
 		}
-		readFlag, writeFlag = false, true
-		expr = args[1]
-	} else if args[0] == "-rw" {
-		if len(args) < 2 {
-			fmt.Println("Expression required")
+		target := history[gotoEntry-1].EventIdx
+		if err := GotoIndex(c.replayer, target); err != nil {
+			printError("Error jumping to assignment: %v\n", err)
 			return
 		}
-		readFlag, writeFlag = true, true
-		expr = args[1]
-	} else {
-		// No flag, use the first arg as expression with default -rw
-		expr = args[0]
+		fmt.Printf("Jumped to event %d: %s\n", c.recordingIndex(target), c.formatEvent(target, c.replayer.Events()[target]))
+		return
 	}
 
-	// Determine watchpoint type for our manager
-	var watchType BreakpointType
-	if readFlag && writeFlag {
-		watchType = WatchpointReadWrite
-	} else if readFlag {
-		watchType = WatchpointRead
+	if scope != "" {
+		fmt.Printf("\nHistory of %s in %s (%d assignments):\n", varName, scope, len(history))
 	} else {
-		watchType = WatchpointWrite
+		fmt.Printf("\nHistory of %s (%d assignments):\n", varName, len(history))
+	}
+	for i, change := range history {
+		// Mark the value in effect at the current event
+		marker := " "
+		if change.EventIdx <= idx && (i == len(history)-1 || history[i+1].EventIdx > idx) {
+			marker = ">"
+		}
+		goroutine := "-"
+		if change.Goroutine != 0 {
+			goroutine = fmt.Sprintf("g%d", change.Goroutine)
+		}
+		value := strings.ReplaceAll(PrettyValue("", change.Value), "\n", "\n    ")
+		fmt.Printf("%s %2d. [%d] %s %-5s %-20s %s\n", marker, i+1, c.recordingIndex(change.EventIdx),
+			change.Timestamp.Format("15:04:05.000"), goroutine, change.FuncName, value)
 	}
+}
 
-	// Try to set watchpoint in Delve if it's active
-	var watchDbp *api.Breakpoint
-	var delveErr error
+// locationIndex returns the location index of the loaded events, building
+// it again when events were inserted since
+func (c *CLI) locationIndex() *replay.LocationIndex {
+	events := c.replayer.Events()
+	if c.locations == nil || len(events) != len(c.locationsOf) || len(events) > 0 && &events[0] != &c.locationsOf[0] {
+		c.locations = replay.IndexLocations(events)
+		c.locationsOf = events
+	}
+	return c.locations
+}
 
-	if c.debugger != nil {
-		watchDbp, delveErr = c.debugger.SetWatchpoint(expr, readFlag, writeFlag)
-		if delveErr != nil {
-			// Don't return error immediately - we'll still create a replay watchpoint
-			fmt.Printf("Warning: Unable to set live Delve watchpoint: %v\n", delveErr)
-			fmt.Println("Creating replay-only watchpoint instead.")
+// handleWhen lists every event recorded at a source line, or every entry to
+// and exit from a function, so a breakpoint can be checked against the
+// recording before setting it. Locations are resolved like breakpoints;
+// with --count only the number of events is printed.
+func (c *CLI) handleWhen(args []string) {
+	const usage = "Usage: when <file:line|function> [--count]"
+	var location string
+	countOnly := false
+	for _, arg := range args {
+		switch {
+		case arg == "--count":
+			countOnly = true
+		case location == "":
+			location = arg
+		default:
+			fmt.Println(usage)
+			return
 		}
-	} else {
-		fmt.Println("Delve integration not active. Creating replay-only watchpoint.")
+	}
+	if location == "" {
+		fmt.Println(usage)
+		return
 	}
 
-	// Add to our breakpoint manager (for replay mode)
-	watchBp, err := c.bpManager.AddWatchpoint(expr, watchType)
+	c.bpManager.SetRecordedLocations(c.replayer.Events())
+	resolved, err := c.bpManager.ResolveLocation(location)
 	if err != nil {
-		fmt.Printf("Error adding watchpoint to manager: %v\n", err)
+		printError("Error resolving location: %v\n", err)
 		return
 	}
 
-	if watchDbp != nil {
-		fmt.Printf("Watchpoint %d set on expression '%s' (Delve bp: %d)\n",
-			watchBp.ID, expr, watchDbp.ID)
+	index := c.locationIndex()
+	var indices []int
+	if name, isFunc := strings.CutPrefix(resolved, "func:"); isFunc || !strings.Contains(resolved, ":") {
+		for _, fn := range index.Functions() {
+			if fn == name || FuncNameMatches(fn, name) {
+				indices = append(indices, index.Calls(fn)...)
+			}
+		}
+	} else {
+		i := strings.LastIndex(resolved, ":")
+		line, err := strconv.Atoi(resolved[i+1:])
+		if err != nil || line <= 0 {
+			fmt.Printf("Invalid line number: %s\n", resolved[i+1:])
+			return
+		}
+		for _, file := range index.Files() {
+			if sameSourceFile(file, resolved[:i]) {
+				indices = append(indices, index.AtLine(file, line)...)
+			}
+		}
+	}
+	sort.Ints(indices)
+
+	if countOnly {
+		fmt.Println(len(indices))
+		return
+	}
+	if len(indices) == 0 {
+		fmt.Printf("No events recorded at %s; a breakpoint there would never fire in this recording\n", location)
+		return
+	}
+
+	events := c.replayer.Events()
+	offset, _ := c.window()
+	fmt.Printf("\n%s: %d events\n", location, len(indices))
+	for _, i := range indices {
+		event := events[i]
+		goroutine := "-"
+		if event.GoroutineID != 0 {
+			goroutine = fmt.Sprintf("g%d", event.GoroutineID)
+		}
+		fmt.Printf("  [%d] %s %-5s %-18s %s\n", offset+i, event.Timestamp.Format("15:04:05.000"), goroutine, event.Type, event.Details)
+	}
+}
+
+// errorIndices returns the indices of all recorded error events
+func (c *CLI) errorIndices() []int {
+	var indices []int
+	for i, e := range c.replayer.Events() {
+		if e.Type == recorder.ErrorEvent {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
+
+// handleErrors lists every recorded error with its event index
+func (c *CLI) handleErrors() {
+	indices := c.errorIndices()
+	if len(indices) == 0 {
+		fmt.Println("No errors recorded")
+		return
+	}
+
+	events := c.replayer.Events()
+	idx := c.replayer.CurrentIndex()
+	fmt.Printf("\n%d errors recorded:\n", len(indices))
+	for _, i := range indices {
+		e := events[i]
+		marker := " "
+		if i == idx {
+			marker = ">"
+		}
+
+		message := e.Details
+		if msg, errType, ok := replay.ParseError(e.Details); ok {
+			message = fmt.Sprintf("%s (%s)", msg, errType)
+		}
+		location := e.FuncName
+		if e.File != "" {
+			location = fmt.Sprintf("%s %s:%d", e.FuncName, e.File, e.Line)
+		}
+		fmt.Printf("%s [%d] %s: %s\n", marker, c.recordingIndex(i), location, message)
+		if j := replay.RecoveredBy(events, i); j >= 0 {
+			fmt.Printf("      recovered by the deferred call in %s at event %d\n", events[j].FuncName, c.recordingIndex(j))
+		}
+	}
+}
+
+// handleStack prints the call stack at the current event, innermost call
+// first. A running deferred call is shown as its own frame above the
+// function that deferred it.
+func (c *CLI) handleStack() {
+	idx := c.replayer.CurrentIndex()
+	if idx < 0 {
+		fmt.Println("No current event; step first")
+		return
+	}
+	stack := replay.CallStack(c.replayer.Events(), idx)
+	offset, _ := c.window()
+	if len(stack) == 0 {
+		fmt.Printf("No calls active at event %d\n", offset+idx)
+		return
+	}
+
+	fmt.Printf("\nCall stack at event %d:\n", offset+idx)
+	for i := len(stack) - 1; i >= 0; i-- {
+		frame := stack[i]
+		name := frame.FuncName
+		if frame.Type == recorder.DeferOperation {
+			name = style("[deferred] ", "magenta") + name
+		}
+		location := ""
+		if frame.File != "" {
+			location = fmt.Sprintf(" at %s:%d", frame.File, frame.Line)
+		}
+		fmt.Printf("  #%d %s%s\n", len(stack)-1-i, name, location)
+	}
+}
+
+// handleBisect jumps to the first event at which a predicate holds
+func (c *CLI) handleBisect(expr string) {
+	if expr == "" {
+		fmt.Println("Usage: bisect var <name> <op> <value> | event.<field> <op> <value>")
+		return
+	}
+	pred, err := replay.ParsePredicate(expr)
+	if err != nil {
+		printError("%v\n", err)
+		return
+	}
+
+	events := c.replayer.Events()
+	idx, monotonic := replay.Bisect(events, pred)
+	if !monotonic {
+		fmt.Println("Warning: The predicate doesn't stay true once it holds, so every event was checked in order")
+	}
+	if idx < 0 {
+		fmt.Printf("%s never holds\n", expr)
+		return
+	}
+	if err := GotoIndex(c.replayer, idx); err != nil {
+		printError("Error jumping to event: %v\n", err)
+		return
+	}
+	offset, _ := c.window()
+	fmt.Printf("%s first holds at event %d: %s\n", expr, offset+idx, c.formatEvent(idx, events[idx]))
+}
+
+// handleCheck checks the registered invariants over the whole recording,
+// plus a channel capacity limit if one is given
+func (c *CLI) handleCheck(args []string) {
+	invariants := replay.Invariants()
+	if len(args) > 0 {
+		if len(args) != 3 || args[0] != "chan" {
+			fmt.Println("Usage: check [chan <id> <max>]")
+			return
+		}
+		chID, err := strconv.Atoi(args[1])
+		if err != nil {
+			fmt.Printf("Invalid channel ID: %v\n", err)
+			return
+		}
+		max, err := strconv.Atoi(args[2])
+		if err != nil || max < 0 {
+			fmt.Printf("Invalid capacity: %s\n", args[2])
+			return
+		}
+		invariants = append(invariants, replay.ChannelCapacity(chID, max))
+	}
+
+	violations := replay.CheckInvariants(c.replayer.Events(), invariants)
+	if len(violations) == 0 {
+		fmt.Printf("All %d invariants hold\n", len(invariants))
+		return
+	}
+
+	fmt.Printf("\n%d invariant violations:\n", len(violations))
+	for _, v := range violations {
+		fmt.Printf("  %s\n", v)
+	}
+}
+
+// handleNextError jumps to the next recorded error after the current event,
+// or the previous one before it if direction is negative
+func (c *CLI) handleNextError(direction int) {
+	indices := c.errorIndices()
+	if len(indices) == 0 {
+		fmt.Println("No errors recorded")
+		return
+	}
+
+	idx := c.replayer.CurrentIndex()
+	target := -1
+	if direction > 0 {
+		for _, i := range indices {
+			if i > idx {
+				target = i
+				break
+			}
+		}
+	} else {
+		for j := len(indices) - 1; j >= 0; j-- {
+			if indices[j] < idx {
+				target = indices[j]
+				break
+			}
+		}
+	}
+
+	if target < 0 {
+		if direction > 0 {
+			fmt.Println("No more errors after the current event")
+		} else {
+			fmt.Println("No errors before the current event")
+		}
+		return
+	}
+
+	if err := GotoIndex(c.replayer, target); err != nil {
+		printError("Error jumping to error: %v\n", err)
+		return
+	}
+	printError("Error at event %d: %s\n", c.recordingIndex(target), c.formatEvent(target, c.replayer.Events()[target]))
+}
+
+// handleIter jumps to the iteration given by args of the current loop
+func (c *CLI) handleIter(args []string) {
+	if len(args) != 1 {
+		fmt.Println("Usage: iter <n>")
+		return
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n <= 0 {
+		fmt.Printf("Invalid iteration: %s\n", args[0])
+		return
+	}
+	c.handleIteration(0, n)
+}
+
+// handleIteration jumps through the iterations of the innermost loop the
+// current event ran in: by direction, or to iteration n when n > 0. Loops are
+// detected from the recorded lines each time, so they follow edits to the
+// timeline.
+func (c *CLI) handleIteration(direction, n int) {
+	events := c.replayer.Events()
+	idx := c.replayer.CurrentIndex()
+	loops := replay.IndexLoops(events)
+	label := loops.At(idx)
+	if label.Loop < 0 {
+		fmt.Println("The current event isn't in a loop")
+		return
+	}
+	loop := loops.Loops[label.Loop]
+
+	if n == 0 {
+		n = label.Iteration + direction
+	}
+	if n < 1 {
+		fmt.Println("Already at the first iteration")
+		return
+	}
+	if n > len(loop.Starts) {
+		if direction > 0 {
+			fmt.Println("Already at the last iteration")
+		} else {
+			fmt.Printf("The loop at %s:%d ran %d iterations\n", loop.File, loop.Line, len(loop.Starts))
+		}
+		return
+	}
+
+	target, _ := loops.IterationTarget(events, idx, n)
+	if err := GotoIndex(c.replayer, target); err != nil {
+		printError("Error jumping to iteration: %v\n", err)
+		return
+	}
+	fmt.Printf("Iteration %d of %d of the loop at %s:%d\n", n, len(loop.Starts), loop.File, loop.Line)
+	fmt.Printf("Event %d: %s\n", c.recordingIndex(target), c.formatEvent(target, c.replayer.Events()[target]))
+}
+
+// statsTopFunctions is the number of functions the stats command lists
+const statsTopFunctions = 10
+
+// handleStats prints the busiest functions and the event types recorded in
+// each time bucket, using the same aggregation as "chrono stats"
+func (c *CLI) handleStats(args []string) {
+	opts := replay.DefaultStatsOptions()
+	if len(args) > 0 {
+		width, err := time.ParseDuration(args[0])
+		if err != nil || width <= 0 {
+			fmt.Printf("Invalid bucket width: %s\n", args[0])
+			return
+		}
+		opts.BucketWidth = width
+	}
+
+	stats := replay.ComputeStats(c.replayer.Events(), opts)
+	if stats.Events == 0 {
+		fmt.Println("No events recorded")
+		return
+	}
+
+	fmt.Printf("\n%d events over %v\n", stats.Events, stats.End.Sub(stats.Start))
+	fmt.Println("\nFunctions by events:")
+	for i, fn := range stats.Functions {
+		if i == statsTopFunctions {
+			fmt.Printf("  ... and %d more\n", len(stats.Functions)-i)
+			break
+		}
+		fmt.Printf("  %-40s %6d calls %8d events  mean %-10v max %v\n",
+			fn.Name, fn.Calls, fn.Events, fn.MeanDuration(), fn.MaxDuration)
+	}
+
+	fmt.Printf("\nEvent types per %v:\n", stats.BucketWidth)
+	for _, bucket := range stats.Buckets {
+		types := make([]recorder.EventType, 0, len(bucket.TypeCounts))
+		for t := range bucket.TypeCounts {
+			types = append(types, t)
+		}
+		sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+		counts := make([]string, len(types))
+		for i, t := range types {
+			counts[i] = fmt.Sprintf("%s=%d", styleEventType(t), bucket.TypeCounts[t])
+		}
+		fmt.Printf("  %s  %s\n", bucket.Start.Local().Format("15:04:05.000"), strings.Join(counts, " "))
+	}
+}
+
+// handleIO summarizes the bytes read and written per traced file and
+// connection, or with a name jumps to the last write to it before the
+// current event
+func (c *CLI) handleIO(args []string) {
+	summarizer, ok := c.replayer.(interface {
+		IOSummary() []replay.IOStat
+		LastWrite(name string, idx int) int
+	})
+	if !ok {
+		fmt.Println("I/O summary is not supported by this replayer")
+		return
+	}
+
+	if len(args) > 0 {
+		name := strings.Join(args, " ")
+		idx := c.replayer.CurrentIndex() - 1
+		if c.replayer.CurrentIndex() < 0 {
+			// Not started: search the whole recording
+			idx = len(c.replayer.Events()) - 1
+		}
+		target := summarizer.LastWrite(name, idx)
+		if target < 0 {
+			fmt.Printf("No writes to %s recorded before the current event\n", name)
+			return
+		}
+		if err := GotoIndex(c.replayer, target); err != nil {
+			printError("Error jumping to write: %v\n", err)
+			return
+		}
+		fmt.Printf("Last write to %s at event %d: %s\n", name, c.recordingIndex(target), c.formatEvent(target, c.replayer.Events()[target]))
+		return
+	}
+
+	stats := summarizer.IOSummary()
+	if len(stats) == 0 {
+		fmt.Println("No I/O recorded; enable it with CHRONOGO_TRACE_IO=1 and TracedFile or TracedConn")
+		return
+	}
+	fmt.Printf("\nI/O on %d files and connections:\n", len(stats))
+	for _, stat := range stats {
+		kind := "file"
+		if stat.Conn {
+			kind = "conn"
+		}
+		status := ""
+		if stat.Closed {
+			status = " (closed)"
+		}
+		lastWrite := "-"
+		if stat.LastWrite >= 0 {
+			lastWrite = strconv.Itoa(stat.LastWrite)
+		}
+		fmt.Printf("  %s %-30s in %8d bytes (%d reads)  out %8d bytes (%d writes)  last write [%s]%s\n",
+			kind, stat.Name, stat.BytesRead, stat.Reads, stat.BytesWritten, stat.Writes, lastWrite, status)
+	}
+}
+
+// traceGrouper is implemented by replayers that group events by trace ID
+type traceGrouper interface {
+	TraceGroups() []replay.TraceGroup
+	TraceIndices(traceID string) []int
+}
+
+// handleTraces lists one row per request: its time span, number of events,
+// the functions it touched and whether it panicked
+func (c *CLI) handleTraces() {
+	grouper, ok := c.replayer.(traceGrouper)
+	if !ok {
+		fmt.Println("Traces are not supported by this replayer")
+		return
+	}
+
+	groups := grouper.TraceGroups()
+	if len(groups) == 0 {
+		fmt.Println("No events recorded")
+		return
+	}
+
+	idx := c.replayer.CurrentIndex()
+	events := c.replayer.Events()
+	fmt.Printf("\n%d traces recorded:\n", len(groups))
+	for _, g := range groups {
+		marker := " "
+		if idx >= 0 && idx < len(events) && events[idx].TraceID == g.TraceID {
+			marker = ">"
+		}
+		status := ""
+		if g.Panicked {
+			status = " PANIC"
+		}
+		fmt.Printf("%s %-20s [%d-%d] %s-%s %5d events  %s%s\n", marker, g.Name(), c.recordingIndex(g.First), c.recordingIndex(g.Last),
+			g.Start.Format("15:04:05.000"), g.End.Format("15:04:05.000"), g.Events,
+			strings.Join(g.Functions, ", "), status)
+	}
+}
+
+// handleTrace jumps to the first event of a request
+func (c *CLI) handleTrace(args []string) {
+	if len(args) != 1 {
+		fmt.Println("Usage: trace <id>")
+		return
+	}
+	grouper, ok := c.replayer.(traceGrouper)
+	if !ok {
+		fmt.Println("Traces are not supported by this replayer")
+		return
+	}
+
+	indices := grouper.TraceIndices(args[0])
+	if len(indices) == 0 {
+		fmt.Printf("No events recorded for trace %s\n", args[0])
+		return
+	}
+	if err := GotoIndex(c.replayer, indices[0]); err != nil {
+		printError("Error jumping to trace: %v\n", err)
+		return
+	}
+	fmt.Printf("Trace %s: %d events from %d to %d\n", args[0], len(indices), c.recordingIndex(indices[0]), c.recordingIndex(indices[len(indices)-1]))
+	fmt.Printf("At event %d: %s\n", c.recordingIndex(indices[0]), c.formatEvent(indices[0], c.replayer.Events()[indices[0]]))
+}
+
+// locationFinder is implemented by replayers that can search for the events
+// recorded at a source line
+type locationFinder interface {
+	NextAtLocation(file string, line int, fromIdx int) (int, bool)
+	PrevAtLocation(file string, line int, fromIdx int) (int, bool)
+}
+
+// handleFind jumps to the next event recorded at file:line, or with
+// backward the previous one, without setting a breakpoint
+func (c *CLI) handleFind(args []string, backward bool) {
+	usage := "Usage: find <file>:<line>"
+	if backward {
+		usage = "Usage: find-prev <file>:<line>"
+	}
+	if len(args) != 1 {
+		fmt.Println(usage)
+		return
+	}
+	finder, ok := c.replayer.(locationFinder)
+	if !ok {
+		fmt.Println("Finding locations is not supported by this replayer")
+		return
+	}
+
+	// The last colon, as Windows paths have one after the drive letter
+	i := strings.LastIndex(args[0], ":")
+	if i <= 0 {
+		fmt.Println(usage)
+		return
+	}
+	file := args[0][:i]
+	line, err := strconv.Atoi(args[0][i+1:])
+	if err != nil || line <= 0 {
+		fmt.Printf("Invalid line number: %s\n", args[0][i+1:])
+		return
+	}
+
+	var idx int
+	if backward {
+		idx, ok = finder.PrevAtLocation(file, line, c.replayer.CurrentIndex())
+	} else {
+		idx, ok = finder.NextAtLocation(file, line, c.replayer.CurrentIndex())
+	}
+	if !ok {
+		direction := "after"
+		if backward {
+			direction = "before"
+		}
+		fmt.Printf("No events recorded at %s:%d %s the current event\n", file, line, direction)
+		return
+	}
+	if err := GotoIndex(c.replayer, idx); err != nil {
+		printError("Error jumping to event: %v\n", err)
+		return
+	}
+	fmt.Printf("At event %d: %s\n", c.recordingIndex(idx), c.formatEvent(idx, c.replayer.Events()[idx]))
+}
+
+// checkpointer is implemented by replayers that can return to a remembered position
+type checkpointer interface {
+	Checkpoint() replay.CheckpointID
+	Restore(id replay.CheckpointID) error
+	Checkpoints() []replay.CheckpointInfo
+}
+
+// handleCheckpoint remembers the current replay position
+func (c *CLI) handleCheckpoint() {
+	cp, ok := c.replayer.(checkpointer)
+	if !ok {
+		fmt.Println("Checkpoints are not supported by this replayer")
+		return
+	}
+	id := cp.Checkpoint()
+	if idx := c.replayer.CurrentIndex(); idx >= 0 {
+		fmt.Printf("Checkpoint %d at event %d\n", id, c.recordingIndex(idx))
+	} else {
+		fmt.Printf("Checkpoint %d before the first event\n", id)
+	}
+}
+
+// handleCheckpoints lists the checkpoints, marking those at the current event
+func (c *CLI) handleCheckpoints() {
+	cp, ok := c.replayer.(checkpointer)
+	if !ok {
+		fmt.Println("Checkpoints are not supported by this replayer")
+		return
+	}
+
+	infos := cp.Checkpoints()
+	if len(infos) == 0 {
+		fmt.Println("No checkpoints; create one with checkpoint")
+		return
+	}
+	idx := c.replayer.CurrentIndex()
+	events := c.replayer.Events()
+	fmt.Printf("\n%d checkpoints:\n", len(infos))
+	for _, info := range infos {
+		marker := " "
+		if info.Index == idx {
+			marker = ">"
+		}
+		at := "before the first event"
+		if info.Index >= 0 && info.Index < len(events) {
+			at = c.formatEvent(info.Index, events[info.Index])
+		}
+		fmt.Printf("%s %d: event %d, taken %s: %s\n", marker, info.ID, c.recordingIndex(info.Index), info.Created.Format("15:04:05"), at)
+	}
+}
+
+// handleRestore returns to a checkpoint
+func (c *CLI) handleRestore(args []string) {
+	if len(args) != 1 {
+		fmt.Println("Usage: restore <id>")
+		return
+	}
+	cp, ok := c.replayer.(checkpointer)
+	if !ok {
+		fmt.Println("Checkpoints are not supported by this replayer")
+		return
+	}
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		fmt.Printf("Invalid checkpoint ID: %s\n", args[0])
+		return
+	}
+	if err := cp.Restore(replay.CheckpointID(id)); err != nil {
+		printError("Error restoring checkpoint: %v\n", err)
+		return
+	}
+
+	idx := c.replayer.CurrentIndex()
+	events := c.replayer.Events()
+	if idx >= 0 && idx < len(events) {
+		fmt.Printf("Restored checkpoint %d at event %d: %s\n", id, c.recordingIndex(idx), c.formatEvent(idx, events[idx]))
+	} else {
+		fmt.Printf("Restored checkpoint %d before the first event\n", id)
+	}
+}
+
+// handleInspect shows every field of an event, the current one by default,
+// with the JSON and base64 payloads in its details decoded and indented.
+// With --raw it prints the event's line from the events file instead.
+func (c *CLI) handleInspect(args []string) {
+	raw, explicit := false, false
+	idx := c.replayer.CurrentIndex()
+	for _, arg := range args {
+		if arg == "--raw" {
+			raw = true
+			continue
+		}
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			fmt.Println("Usage: inspect [index] [--raw]")
+			return
+		}
+		if idx, err = c.loadedIndex(n); err != nil {
+			fmt.Println(err)
+			return
+		}
+		explicit = true
+	}
+
+	events := c.replayer.Events()
+	if idx < 0 || idx >= len(events) {
+		if !explicit {
+			fmt.Println("No current event; step first or give an index")
+		}
+		return
+	}
+	event := events[idx]
+
+	if raw {
+		line, err := c.rawEventLine(events, idx)
+		if err != nil {
+			printError("Error reading raw event: %v\n", err)
+			return
+		}
+		fmt.Println(string(line))
+		return
+	}
+
+	offset, total := c.window()
+	fmt.Printf("\nEvent %d of %d:\n", offset+idx, total)
+	fmt.Printf("  ID:        %d\n", event.ID)
+	fmt.Printf("  Timestamp: %s\n", event.Timestamp.Format(time.RFC3339Nano))
+	fmt.Printf("  Type:      %s\n", event.Type)
+	if event.FuncName != "" {
+		fmt.Printf("  Function:  %s\n", event.FuncName)
+	}
+	if event.File != "" {
+		fmt.Printf("  Location:  %s:%d\n", event.File, event.Line)
+	}
+	if event.Repeat > 0 {
+		fmt.Printf("  Repeat:    %d\n", event.Repeat)
+	}
+	if event.TraceID != "" {
+		fmt.Printf("  TraceID:   %s\n", event.TraceID)
+	}
+	if len(event.Tags) > 0 {
+		fmt.Printf("  Tags:      %s\n", strings.Join(event.Tags, ", "))
+	}
+	if locator, ok := c.replayer.(interface{ GoroutineAt(idx int) int }); ok {
+		fmt.Printf("  Goroutine: %s\n", formatGoroutine(locator.GoroutineAt(idx)))
+	}
+	if serialized, err := json.Marshal(event); err == nil {
+		fmt.Printf("  Size:      %d bytes serialized\n", len(serialized))
+	}
+	if event.DetailsLen > 0 {
+		fmt.Printf("  Details (%d bytes, truncated from %d):\n    %s\n", len(event.Details), event.DetailsLen, event.Details)
+	} else {
+		fmt.Printf("  Details (%d bytes):\n    %s\n", len(event.Details), event.Details)
+	}
+
+	for _, p := range FindPayloads(event.Details) {
+		if p.Decoded != nil {
+			fmt.Printf("  Base64 at byte %d (%d bytes, %d decoded):\n", p.Offset, p.Size, len(p.Decoded))
+		} else {
+			fmt.Printf("  JSON at byte %d (%d bytes):\n", p.Offset, p.Size)
+		}
+		fmt.Printf("    %s\n", strings.ReplaceAll(p.Pretty, "\n", "\n    "))
+	}
+}
+
+// handleTag tags an event, or a range of events, with a label
+func (c *CLI) handleTag(args []string) {
+	if len(args) != 2 {
+		fmt.Println("Usage: tag <index|from-to> <label>")
+		return
+	}
+	from, to, err := parseIndexRange(args[0])
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	first, err := c.loadedIndex(from)
+	if err == nil {
+		_, err = c.loadedIndex(to)
+	}
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for idx := first; idx <= first+to-from; idx++ {
+		if err := c.tagEvent(idx, args[1]); err != nil {
+			printError("Error tagging event: %v\n", err)
+			return
+		}
+	}
+	if from == to {
+		fmt.Printf("Tagged event %d %s\n", from, args[1])
+	} else {
+		fmt.Printf("Tagged events %d-%d %s\n", from, to, args[1])
+	}
+}
+
+// parseIndexRange parses an event index, e.g. "12", or an inclusive range,
+// e.g. "12-40"
+func parseIndexRange(arg string) (from, to int, err error) {
+	first, last, isRange := strings.Cut(arg, "-")
+	from, err = strconv.Atoi(first)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid event index: %s", arg)
+	}
+	if !isRange {
+		return from, from, nil
+	}
+	to, err = strconv.Atoi(last)
+	if err != nil || to < from {
+		return 0, 0, fmt.Errorf("invalid event range: %s", arg)
+	}
+	return from, to, nil
+}
+
+// window returns the index in the recording of the first loaded event and
+// the number of events in the recording, which differ from 0 and the events
+// loaded when replaying a window of it
+func (c *CLI) window() (offset, total int) {
+	if w, ok := c.replayer.(interface{ Window() (offset, total int) }); ok {
+		return w.Window()
+	}
+	return 0, len(c.replayer.Events())
+}
+
+// recordingIndex converts an index into the loaded events to the index of
+// the event in the recording, as commands print them
+func (c *CLI) recordingIndex(idx int) int {
+	offset, _ := c.window()
+	return offset + idx
+}
+
+// loadedIndex converts an event index in the recording, as commands take
+// them, to an index into the loaded events
+func (c *CLI) loadedIndex(n int) (int, error) {
+	offset, _ := c.window()
+	last := offset + len(c.replayer.Events()) - 1
+	if n < offset || n > last {
+		return 0, fmt.Errorf("event index %d out of range (%d-%d)", n, offset, last)
+	}
+	return n - offset, nil
+}
+
+// formatCount formats n with thousands separators, e.g. "2,340,000"
+func formatCount(n int) string {
+	digits := strconv.Itoa(n)
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 && digits[i-1] != '-' {
+			b.WriteByte(',')
+		}
+		b.WriteRune(d)
+	}
+	return b.String()
+}
+
+// formatGoroutine formats a goroutine ID, "unknown" for events recorded
+// without one
+func formatGoroutine(id int) string {
+	if id == 0 {
+		return "unknown"
+	}
+	return strconv.Itoa(id)
+}
+
+// tagEvent tags the event at idx in the replayer and remembers the tag for
+// saved sessions
+func (c *CLI) tagEvent(idx int, label string) error {
+	tagger, ok := c.replayer.(interface {
+		AddTag(idx int, label string) error
+	})
+	if !ok {
+		return fmt.Errorf("this replayer doesn't support tags")
+	}
+	if err := tagger.AddTag(idx, label); err != nil {
+		return err
+	}
+	if c.tags == nil {
+		c.tags = make(map[int][]string)
+	}
+	for _, existing := range c.tags[idx] {
+		if existing == label {
+			return nil
+		}
+	}
+	c.tags[idx] = append(c.tags[idx], label)
+	return nil
+}
+
+// handleFilter shows, sets or clears the tag or event type step and
+// backstep stop at
+func (c *CLI) handleFilter(args []string) {
+	if len(args) == 0 {
+		if c.stepFilter == "" {
+			fmt.Println("No step filter; step stops at every event")
+		} else {
+			fmt.Printf("Stepping through events %s\n", c.filterDescription())
+		}
+		return
+	}
+	if args[0] == "off" {
+		c.stepFilter = ""
+		fmt.Println("Step filter cleared")
+		return
+	}
+	filter, ok, err := parseStepFilter(args[0])
+	if !ok {
+		fmt.Println("Usage: filter [tag:<label>|type:<event type>|off]")
+		return
+	}
+	if err != nil {
+		fmt.Printf("Unknown event type: %s\n", strings.TrimPrefix(filter, "type:"))
+		return
+	}
+	c.stepFilter = filter
+	count := 0
+	for _, event := range c.replayer.Events() {
+		if c.passesFilter(event) {
+			count++
+		}
+	}
+	fmt.Printf("Stepping through the %d events %s\n", count, c.filterDescription())
+}
+
+// parseStepFilter returns the step filter a tag:<label>, type:<name> or
+// type=<name> argument selects, with ok false if arg is none of them and an
+// error if it names an unknown event type
+func parseStepFilter(arg string) (filter string, ok bool, err error) {
+	if label, ok := strings.CutPrefix(arg, "tag:"); ok && label != "" {
+		return "tag:" + label, true, nil
+	}
+	name, ok := cutTypeFilter(arg)
+	if !ok {
+		return "", false, nil
+	}
+	if _, known := recorder.ParseEventType(name); !known {
+		return "type:" + name, true, fmt.Errorf("unknown event type: %s", name)
+	}
+	return "type:" + name, true, nil
+}
+
+// cutTypeFilter returns the event type of a type:<name> or type=<name> filter
+func cutTypeFilter(arg string) (string, bool) {
+	name, ok := strings.CutPrefix(arg, "type:")
+	if !ok {
+		name, ok = strings.CutPrefix(arg, "type=")
+	}
+	return name, ok && name != ""
+}
+
+// passesFilter reports whether step and backstep stop at an event
+func (c *CLI) passesFilter(event recorder.Event) bool {
+	if name, ok := strings.CutPrefix(c.stepFilter, "type:"); ok {
+		return event.Type.String() == name
+	}
+	return event.HasTag(strings.TrimPrefix(c.stepFilter, "tag:"))
+}
+
+// filterDescription describes the events the step filter passes, e.g.
+// "tagged suspicious" or "of type CacheMiss"
+func (c *CLI) filterDescription() string {
+	if name, ok := strings.CutPrefix(c.stepFilter, "type:"); ok {
+		return "of type " + name
+	}
+	return "tagged " + strings.TrimPrefix(c.stepFilter, "tag:")
+}
+
+// filteredIndex returns the index of the count-th event from idx in the
+// given direction that passes the step filter, or -1 if there are fewer
+func (c *CLI) filteredIndex(idx, count, direction int) int {
+	events := c.replayer.Events()
+	for i := idx + direction; i >= 0 && i < len(events); i += direction {
+		if c.passesFilter(events[i]) {
+			count--
+			if count == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// SourceProvider returns the lines of the source files events were recorded
+// in, as named by Event.File
+type SourceProvider interface {
+	Source(file string) ([]string, bool)
+}
+
+// diskSources reads source files from disk, caching each one
+type diskSources map[string][]string
+
+// Source reads file, or returns false if it can't be read
+func (d diskSources) Source(file string) ([]string, bool) {
+	if lines, ok := d[file]; ok {
+		return lines, lines != nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		d[file] = nil
+		return nil, false
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	d[file] = lines
+	return lines, true
+}
+
+// handleList prints the code around the current event's line, marking it
+func (c *CLI) handleList(args []string) {
+	context := 5
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 0 {
+			fmt.Println("Usage: list [lines]")
+			return
+		}
+		context = n
+	}
+
+	idx := c.replayer.CurrentIndex()
+	events := c.replayer.Events()
+	if idx < 0 || idx >= len(events) {
+		fmt.Println("No current event; step first")
+		return
+	}
+	event := events[idx]
+	if event.File == "" || event.Line <= 0 {
+		fmt.Println("The current event has no source location")
+		return
+	}
+	if c.sources == nil {
+		c.sources = diskSources{}
+	}
+	lines, ok := c.sources.Source(event.File)
+	if !ok {
+		fmt.Printf("Source for %s is not available\n", event.File)
+		return
+	}
+	if event.Line > len(lines) {
+		fmt.Printf("%s has no line %d\n", event.File, event.Line)
+		return
+	}
+
+	from := max(event.Line-context, 1)
+	to := min(event.Line+context, len(lines))
+	fmt.Printf("%s:%d\n", event.File, event.Line)
+	for n := from; n <= to; n++ {
+		marker := "  "
+		text := fmt.Sprintf("%5d  %s", n, lines[n-1])
+		if n == event.Line {
+			marker = "=>"
+			text = style(text, "bold")
+		}
+		fmt.Printf("%s%s\n", marker, text)
+	}
+}
+
+// rawEventLine returns the serialized line of events[idx] in the events
+// file. Events are matched to lines by ID and timestamp, since replay may
+// have reordered them.
+func (c *CLI) rawEventLine(events []recorder.Event, idx int) ([]byte, error) {
+	if c.stream != "" {
+		return nil, fmt.Errorf("the recording was read from %s, which can't be read again; --raw needs the events file on disk", c.stream)
+	}
+	if c.eventsFile == "" {
+		return nil, fmt.Errorf("the events file is not known")
+	}
+	if c.eventIndex == nil {
+		index, err := recorder.IndexEventsFile(c.eventsFile)
+		if err != nil {
+			return nil, err
+		}
+		c.eventIndex = index
+	}
+
+	event := events[idx]
+	same := func(id int64, ts time.Time) bool {
+		return id == event.ID && ts.Equal(event.Timestamp)
+	}
+	// Events sharing an ID and timestamp keep their file order when sorted
+	occurrence := 0
+	for _, e := range events[:idx] {
+		if same(e.ID, e.Timestamp) {
+			occurrence++
+		}
+	}
+	for _, off := range c.eventIndex {
+		if !same(off.ID, off.Timestamp) {
+			continue
+		}
+		if occurrence == 0 {
+			return recorder.ReadEventLine(c.eventsFile, off)
+		}
+		occurrence--
+	}
+	return nil, fmt.Errorf("event %d is not in %s", event.ID, c.eventsFile)
+}
+
+// printDelveTimeoutHint tells the user how to regain control when a Delve
+// request timed out
+func printDelveTimeoutHint(err error) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		fmt.Println("Delve did not respond in time; use 'interrupt' to stop the target if it is still running")
+	}
+}
+
+// handleInterrupt stops the running target
+func (c *CLI) handleInterrupt() {
+	if c.debugger == nil {
+		fmt.Println("Delve integration not enabled: interrupt requires a live debugging session")
+		return
+	}
+
+	state, err := c.debugger.Interrupt()
+	if err != nil {
+		printError("Error interrupting target: %v\n", err)
+		return
+	}
+	if state != nil && state.CurrentThread != nil {
+		fmt.Printf("Target stopped at: %s:%d\n", state.CurrentThread.File, state.CurrentThread.Line)
+	} else {
+		fmt.Println("Target stopped")
+	}
+}
+
+// handleListGoroutines lists all goroutines, of the live process with
+// Delve and of the recording without
+func (c *CLI) handleListGoroutines() {
+	if c.debugger == nil {
+		c.handleRecordedGoroutines()
+		return
+	}
+
+	goroutines, err := c.debugger.ListGoroutines()
+	if err != nil {
+		printError("Error listing goroutines: %v\n", err)
+		printDelveTimeoutHint(err)
+		return
+	}
+
+	fmt.Printf("Found %d goroutines:\n", len(goroutines))
+	for i, g := range goroutines {
+		fmt.Printf("[%d] Goroutine %d", i, g.ID)
+		if g.CurrentLoc.Function != nil {
+			fmt.Printf(" - %s (%s:%d)", g.CurrentLoc.Function.Name(), g.CurrentLoc.File, g.CurrentLoc.Line)
+		}
+		if g.ThreadID != 0 {
+			fmt.Printf(" on thread %d", g.ThreadID)
+		}
+		fmt.Println()
+	}
+}
+
+// handleRecordedGoroutines lists the goroutines recorded up to the current
+// event, where each last was and the OS thread it last ran on, if known
+func (c *CLI) handleRecordedGoroutines() {
+	idx := c.replayer.CurrentIndex()
+	if idx < 0 {
+		fmt.Println("No current event; step first")
+		return
+	}
+	offset, _ := c.window()
+
+	type goroutine struct {
+		last       recorder.Event // Last event with a location
+		osThreadID int64
+		procID     int
+	}
+	goroutines := make(map[int]*goroutine)
+	var ids []int
+	for _, e := range c.replayer.Events()[:idx+1] {
+		if e.GoroutineID == 0 {
+			continue
+		}
+		g := goroutines[e.GoroutineID]
+		if g == nil {
+			g = &goroutine{}
+			goroutines[e.GoroutineID] = g
+			ids = append(ids, e.GoroutineID)
+		}
+		if e.FuncName != "" {
+			g.last = e
+		}
+		if e.OSThreadID != 0 || e.ProcID != 0 {
+			g.osThreadID, g.procID = e.OSThreadID, e.ProcID
+		}
+	}
+	if len(ids) == 0 {
+		fmt.Printf("No goroutines recorded up to event %d\n", offset+idx)
+		return
+	}
+	sort.Ints(ids)
+
+	fmt.Printf("%d goroutines recorded up to event %d:\n", len(ids), offset+idx)
+	for _, id := range ids {
+		g := goroutines[id]
+		fmt.Printf("  Goroutine %d", id)
+		if g.last.FuncName != "" {
+			fmt.Printf(" - %s (%s:%d)", g.last.FuncName, g.last.File, g.last.Line)
+		}
+		if thread := threadInfo(g.osThreadID, g.procID); thread != "" {
+			fmt.Printf(" %s", thread)
+		}
+		fmt.Println()
+	}
+}
+
+// handleWatch handles the watch command
+func (c *CLI) handleWatch(args []string) {
+	if len(args) < 1 {
+		fmt.Println("Usage: watch [-r|-w|-rw] <expression>")
+		fmt.Println("  -r    stops when the memory location is read")
+		fmt.Println("  -w    stops when the memory location is written")
+		fmt.Println("  -rw   stops when the memory location is read or written (default)")
+		return
+	}
+
+	// Parse flags
+	var readFlag, writeFlag bool = true, true // Default to -rw
+	var expr string
+
+	if args[0] == "-r" {
+		if len(args) < 2 {
+			fmt.Println("Expression required")
+			return
+		}
+		readFlag, writeFlag = true, false
+		expr = args[1]
+	} else if args[0] == "-w" {
+		if len(args) < 2 {
+			fmt.Println("Expression required")
+			return
+		}
+		readFlag, writeFlag = false, true
+		expr = args[1]
+	} else if args[0] == "-rw" {
+		if len(args) < 2 {
+			fmt.Println("Expression required")
+			return
+		}
+		readFlag, writeFlag = true, true
+		expr = args[1]
+	} else {
+		// No flag, use the first arg as expression with default -rw
+		expr = args[0]
+	}
+
+	// Determine watchpoint type for our manager
+	var watchType BreakpointType
+	if readFlag && writeFlag {
+		watchType = WatchpointReadWrite
+	} else if readFlag {
+		watchType = WatchpointRead
+	} else {
+		watchType = WatchpointWrite
+	}
+
+	if c.debugger == nil {
+		fmt.Println("Delve integration not active. Creating replay-only watchpoint.")
+	}
+
+	// The manager sets it in Delve too, falling back to replay-only
+	watchBp, err := c.bpManager.AddWatchpoint(expr, watchType)
+	if err != nil {
+		printError("Error adding watchpoint to manager: %v\n", err)
+		return
+	}
+
+	if watchBp.DelveID != 0 {
+		fmt.Printf("Watchpoint %d set on expression '%s' (Delve bp: %d)\n",
+			watchBp.ID, expr, watchBp.DelveID)
 	} else {
 		fmt.Printf("Replay watchpoint %d set on expression '%s'\n",
 			watchBp.ID, expr)
@@ -1024,6 +2975,189 @@ func (c *CLI) handleWatch(args []string) {
 	}
 }
 
+// handleSession handles the session save/restore commands
+func (c *CLI) handleSession(args []string) {
+	if len(args) < 1 {
+		fmt.Println("Usage: session save|restore [name]")
+		return
+	}
+
+	name := DefaultSessionName
+	if len(args) > 1 {
+		name = args[1]
+	}
+
+	switch args[0] {
+	case "save":
+		if err := c.SaveSession(name); err != nil {
+			printError("Error saving session: %v\n", err)
+			return
+		}
+		path, _ := SessionPath(name)
+		fmt.Printf("Session '%s' saved to %s\n", name, path)
+	case "restore":
+		if err := c.RestoreSession(name); err != nil {
+			printError("Error restoring session: %v\n", err)
+		}
+	default:
+		fmt.Printf("Unknown session command: %s\n", args[0])
+	}
+}
+
+// SetEventsFile records the path of the events file being replayed so it can
+// be stored with saved sessions
+func (c *CLI) SetEventsFile(path string) {
+	c.eventsFile = path
+	c.stream = ""
+	c.eventIndex = nil
+}
+
+// SetEventsStream records that the recording being replayed was read from
+// a stream, such as standard input, so that commands needing the events
+// file explain why they're unavailable
+func (c *CLI) SetEventsStream(name string) {
+	c.eventsFile = ""
+	c.stream = name
+	c.eventIndex = nil
+}
+
+// SetSources sets where the list command reads code from, e.g. a
+// recording bundle, instead of the files on disk
+func (c *CLI) SetSources(sources SourceProvider) {
+	c.sources = sources
+}
+
+// SetMetadata sets the recording metadata shown by info recording, e.g. one
+// read with a key from a secure recording
+func (c *CLI) SetMetadata(md *recorder.RecordingMetadata) {
+	c.metadata = md
+}
+
+// SetSegments records where each segment starts when replaying a flight
+// recorder segment directory, so the map can show segment boundaries
+func (c *CLI) SetSegments(segments []recorder.SegmentBoundary) {
+	c.segments = segments
+}
+
+// SaveSession persists the current replay position and breakpoints under the given name
+func (c *CLI) SaveSession(name string) error {
+	state := SessionState{
+		Name:       name,
+		SavedAt:    time.Now(),
+		EventsFile: c.eventsFile,
+		CurrentIdx: c.replayer.CurrentIndex(),
+	}
+	// Indices are saved as in the whole recording, so that sessions carry
+	// over between windows of it
+	offset, _ := c.window()
+	if state.CurrentIdx >= 0 {
+		state.CurrentIdx += offset
+	}
+
+	if c.eventsFile != "" {
+		hash, err := HashFile(c.eventsFile)
+		if err != nil {
+			return fmt.Errorf("failed to hash events file: %v", err)
+		}
+		state.EventsHash = hash
+	}
+
+	for _, bp := range c.GetBreakpoints() {
+		state.Breakpoints = append(state.Breakpoints, *bp)
+	}
+	if len(c.tags) > 0 {
+		state.Tags = make(map[int][]string, len(c.tags))
+		for idx, labels := range c.tags {
+			state.Tags[offset+idx] = labels
+		}
+	}
+
+	state.Filter = c.stepFilter
+	if c.formatter != nil {
+		state.Format = c.formatter.text
+	}
+
+	path, err := SessionPath(name)
+	if err != nil {
+		return err
+	}
+	return SaveSessionState(path, state)
+}
+
+// RestoreSession restores the replay position, breakpoints, tags, step
+// filter and event format saved under the given name. The saved state is
+// checked against the loaded recording before any of it is applied, so a
+// session that doesn't fit leaves the CLI as it was. A warning is printed if
+// the events file no longer matches the one the session was saved against.
+func (c *CLI) RestoreSession(name string) error {
+	path, err := SessionPath(name)
+	if err != nil {
+		return err
+	}
+	state, err := LoadSessionState(path)
+	if err != nil {
+		return err
+	}
+
+	if state.EventsFile != "" {
+		if c.eventsFile != "" && filepath.Clean(c.eventsFile) != filepath.Clean(state.EventsFile) {
+			fmt.Printf("Warning: session was saved for events file %s, but %s is loaded\n",
+				state.EventsFile, c.eventsFile)
+		}
+
+		hash, err := HashFile(state.EventsFile)
+		if err != nil {
+			fmt.Printf("Warning: could not verify events file %s: %v\n", state.EventsFile, err)
+		} else if hash != state.EventsHash {
+			fmt.Printf("Warning: events file %s has changed since the session was saved\n", state.EventsFile)
+		}
+	}
+
+	current := -1
+	if state.CurrentIdx >= 0 {
+		if current, err = c.loadedIndex(state.CurrentIdx); err != nil {
+			return fmt.Errorf("saved event index %d is out of range", state.CurrentIdx)
+		}
+	}
+	if state.Filter != "" {
+		if _, ok, err := parseStepFilter(state.Filter); !ok || err != nil {
+			return fmt.Errorf("invalid saved step filter %q", state.Filter)
+		}
+	}
+	var formatter *eventFormatter
+	if state.Format != "" {
+		if formatter, err = newEventFormatter(state.Format); err != nil {
+			return fmt.Errorf("invalid saved event format: %v", err)
+		}
+	}
+
+	c.bpManager.RestoreBreakpoints(state.Breakpoints)
+	for n, labels := range state.Tags {
+		idx, err := c.loadedIndex(n)
+		if err != nil {
+			fmt.Printf("Warning: could not restore the tags of event %d: %v\n", n, err)
+			continue
+		}
+		for _, label := range labels {
+			if err := c.tagEvent(idx, label); err != nil {
+				fmt.Printf("Warning: could not restore tag %s: %v\n", label, err)
+			}
+		}
+	}
+	c.stepFilter = state.Filter
+	c.formatter = formatter
+
+	if current >= 0 {
+		if err := GotoIndex(c.replayer, current); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Restored session '%s': event %d, %d breakpoints\n",
+		state.Name, state.CurrentIdx, len(state.Breakpoints))
+	return nil
+}
+
 // GetDebugger returns the current debugger instance in the CLI
 func (c *CLI) GetDebugger() *DelveDebugger {
 	return c.debugger
@@ -1033,6 +3167,7 @@ func (c *CLI) GetDebugger() *DelveDebugger {
 // the other parts of the CLI state
 func (c *CLI) CloseDebugger() {
 	if c.debugger != nil {
+		c.bpManager.SetBackend(nil)
 		c.debugger.Close()
 		c.debugger = nil
 	}
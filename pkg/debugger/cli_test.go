@@ -0,0 +1,1384 @@
+package debugger
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-delve/delve/service/api"
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+	"github.com/willibrandon/ChronoGo/pkg/replay"
+	"github.com/willibrandon/ChronoGo/pkg/testutil"
+)
+
+func TestStepCount(t *testing.T) {
+	// Goroutine 2 runs from event 3 until event 7 switches back to 1
+	start := time.Now()
+	var events []recorder.Event
+	for i := 0; i < 10; i++ {
+		e := recorder.Event{
+			ID:        int64(i + 1),
+			Timestamp: start.Add(time.Duration(i) * time.Millisecond),
+			Type:      recorder.StatementExecution,
+			Details:   fmt.Sprintf("statement %d", i),
+		}
+		switch i {
+		case 2:
+			e.Type, e.Details = recorder.GoroutineSwitch, "Goroutine 2 created"
+		case 3:
+			e.Type, e.Details = recorder.GoroutineSwitch, "Goroutine switch from 1 to 2"
+		case 7:
+			e.Type, e.Details = recorder.GoroutineSwitch, "Goroutine switch from 2 to 1"
+		}
+		events = append(events, e)
+	}
+
+	replayer := replay.NewBasicReplayer()
+	if err := replayer.LoadEvents(events); err != nil {
+		t.Fatalf("Failed to load events: %v", err)
+	}
+	cli := NewCLI(replayer)
+	if err := replayer.ReplayToEventIndex(0); err != nil {
+		t.Fatalf("Failed to move to first event: %v", err)
+	}
+
+	steps := []struct {
+		command   string
+		idx       int
+		goroutine int
+	}{
+		{"s 5", 5, 2},
+		{"b 3", 2, 1},
+		{"s", 3, 2},
+		{"step 100", 9, 1}, // Clamped at the last event
+		{"s 2", 9, 1},      // Already at the end
+		{"backstep 100", 0, 1},
+		{"b", 0, 1}, // Already at the beginning
+		{"s 0", 0, 1},
+		{"s x", 0, 1},
+	}
+	for _, step := range steps {
+		cli.handleCommand(step.command)
+		if replayer.CurrentIndex() != step.idx {
+			t.Errorf("%q: expected event %d, got %d", step.command, step.idx, replayer.CurrentIndex())
+		}
+		if replayer.ActiveGoroutine() != step.goroutine {
+			t.Errorf("%q: expected goroutine %d active, got %d", step.command, step.goroutine, replayer.ActiveGoroutine())
+		}
+	}
+}
+
+func TestErrorNavigation(t *testing.T) {
+	base := time.Now()
+	var events []recorder.Event
+	for i := 0; i < 10; i++ {
+		e := recorder.Event{
+			ID:        int64(i + 1),
+			Timestamp: base.Add(time.Duration(i) * time.Millisecond),
+			Type:      recorder.StatementExecution,
+			Details:   fmt.Sprintf("statement %d", i),
+		}
+		if i == 2 || i == 5 || i == 8 {
+			e.Type = recorder.ErrorEvent
+			e.Details = fmt.Sprintf("Error in main.work: failure %d (*errors.errorString)", i)
+		}
+		events = append(events, e)
+	}
+
+	replayer := replay.NewBasicReplayer()
+	if err := replayer.LoadEvents(events); err != nil {
+		t.Fatalf("Failed to load events: %v", err)
+	}
+	cli := NewCLI(replayer)
+
+	steps := []struct {
+		command string
+		idx     int
+	}{
+		{"next-error", 2},
+		{"next-error", 5},
+		{"next-error", 8},
+		{"next-error", 8}, // No more errors
+		{"prev-error", 5},
+		{"prev-error", 2},
+		{"prev-error", 2}, // No earlier errors
+	}
+	for _, step := range steps {
+		cli.handleCommand(step.command)
+		if replayer.CurrentIndex() != step.idx {
+			t.Errorf("%q: expected event %d, got %d", step.command, step.idx, replayer.CurrentIndex())
+		}
+	}
+
+	if indices := cli.errorIndices(); len(indices) != 3 {
+		t.Errorf("Expected 3 errors, got %v", indices)
+	}
+}
+
+func TestWatchpointHits(t *testing.T) {
+	base := time.Now()
+	var events []recorder.Event
+	for i := 0; i < 8; i++ {
+		e := recorder.Event{
+			ID:        int64(i + 1),
+			Timestamp: base.Add(time.Duration(i) * time.Millisecond),
+			Type:      recorder.StatementExecution,
+			Details:   fmt.Sprintf("statement %d", i),
+		}
+		switch i {
+		case 2, 6:
+			e.Type = recorder.VarAssignment
+			e.Details = fmt.Sprintf("x = %d", i)
+		case 4:
+			e.Type = recorder.VarAssignment
+			e.Details = "y = 4"
+		}
+		events = append(events, e)
+	}
+
+	replayer := replay.NewBasicReplayer()
+	if err := replayer.LoadEvents(events); err != nil {
+		t.Fatalf("Failed to load events: %v", err)
+	}
+	cli := NewCLI(replayer)
+
+	cli.handleCommand("watch -w x")
+	cli.handleCommand("continue")
+	if replayer.CurrentIndex() != 2 {
+		t.Fatalf("Expected the watchpoint to trip at event 2, got %d", replayer.CurrentIndex())
+	}
+	cli.handleCommand("continue")
+	if replayer.CurrentIndex() != 6 {
+		t.Fatalf("Expected the watchpoint to trip at event 6, got %d", replayer.CurrentIndex())
+	}
+
+	// Hits stay after moving away, and follow events inserted before them
+	cli.handleCommand("b 3")
+	if err := replayer.InsertEvent(recorder.Event{ID: 99, Type: recorder.VarAssignment, Details: "Debugger set y = 5"}); err != nil {
+		t.Fatalf("Failed to insert event: %v", err)
+	}
+	if hits := replayer.WatchpointHits(); fmt.Sprint(hits) != "[2 7]" {
+		t.Errorf("Expected watchpoint hits [2 7], got %v", hits)
+	}
+}
+
+func TestContinueNotesSuffixMatch(t *testing.T) {
+	base := time.Now()
+	replayer := replay.NewBasicReplayer()
+	if err := replayer.LoadEvents([]recorder.Event{
+		{ID: 1, Timestamp: base, Type: recorder.StatementExecution, File: "/src/app/main.go", Line: 10},
+		{ID: 2, Timestamp: base.Add(time.Millisecond), Type: recorder.StatementExecution, File: "/src/app/main.go", Line: 11},
+	}); err != nil {
+		t.Fatalf("Failed to load events: %v", err)
+	}
+	cli := NewCLI(replayer)
+
+	// Added directly, so it isn't expanded to the recorded path
+	if _, err := cli.bpManager.AddBreakpoint("main.go:11"); err != nil {
+		t.Fatalf("Failed to add breakpoint: %v", err)
+	}
+	output := captureOutput(t, func() { cli.handleCommand("continue") })
+	if replayer.CurrentIndex() != 1 || !strings.Contains(output, "HIT: Breakpoint at main.go:11 in /src/app/main.go (matched by suffix)") {
+		t.Errorf("Expected a hit matched by suffix at event 1, got event %d:\n%s", replayer.CurrentIndex(), output)
+	}
+}
+
+// captureOutput returns what f prints to stdout
+func captureOutput(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	original := os.Stdout
+	os.Stdout = w
+	defer func() {
+		os.Stdout = original
+	}()
+
+	output := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		output <- string(data)
+	}()
+	f()
+	w.Close()
+	return <-output
+}
+
+// delveEvents returns a recording with locations for the fake Delve client
+// to stop at
+func delveEvents() []recorder.Event {
+	base := time.Now()
+	return []recorder.Event{
+		{ID: 1, Timestamp: base, Type: recorder.FuncEntry, FuncName: "main.main", File: "main.go", Line: 10, Details: "Entering main.main"},
+		{ID: 2, Timestamp: base.Add(time.Millisecond), Type: recorder.StatementExecution, FuncName: "main.main", File: "main.go", Line: 11, Details: "x := 42"},
+		{ID: 3, Timestamp: base.Add(2 * time.Millisecond), Type: recorder.FuncEntry, FuncName: "main.work", File: "work.go", Line: 5, Details: "Entering main.work"},
+		{ID: 4, Timestamp: base.Add(3 * time.Millisecond), Type: recorder.StatementExecution, FuncName: "main.work", Details: "no location"},
+	}
+}
+
+// newFakeDelveCLI returns a CLI on delveEvents backed by a fake Delve client
+func newFakeDelveCLI(t *testing.T) (*CLI, *testutil.FakeDelveClient) {
+	replayer := replay.NewBasicReplayer()
+	if err := replayer.LoadEvents(delveEvents()); err != nil {
+		t.Fatalf("Failed to load events: %v", err)
+	}
+	client := testutil.NewFakeDelveClient()
+	return NewCLIWithDelve(replayer, NewDelveDebuggerWithClient("prog", client)), client
+}
+
+func TestBreakpointShorthand(t *testing.T) {
+	cli, client := newFakeDelveCLI(t)
+	client.Functions = []string{"main.work", "worker/pool.work", "main.main"}
+
+	// Ambiguous names list the candidates so the user can pick one
+	output := captureOutput(t, func() { cli.handleBreakpoint([]string{"work"}) })
+	if !strings.Contains(output, "work is ambiguous") || !strings.Contains(output, "  main.work\n") ||
+		!strings.Contains(output, "  worker/pool.work") {
+		t.Errorf("Expected the ambiguous candidates, got %q", output)
+	}
+	if len(cli.bpManager.GetBreakpoints()) != 0 {
+		t.Fatal("Expected no breakpoint for an ambiguous name")
+	}
+
+	output = captureOutput(t, func() { cli.handleBreakpoint([]string{"pool.work"}) })
+	if !strings.Contains(output, "Function breakpoint 1 set at worker/pool.work") {
+		t.Errorf("Expected a breakpoint on worker/pool.work, got %q", output)
+	}
+	output = captureOutput(t, func() { cli.handleBreakpoint([]string{"main"}) })
+	if !strings.Contains(output, "Function breakpoint 2 set at main.main") {
+		t.Errorf("Expected a breakpoint on main.main, got %q", output)
+	}
+
+	output = captureOutput(t, func() { cli.handleBreakpoint([]string{"missing"}) })
+	if !strings.Contains(output, "No function matches missing") {
+		t.Errorf("Expected no match for an unknown name, got %q", output)
+	}
+}
+
+func TestSyncDebuggerToEvent(t *testing.T) {
+	cli, client := newFakeDelveCLI(t)
+
+	// An event with a location is reached through a temporary breakpoint
+	if err := cli.syncDebuggerToEvent(1); err != nil {
+		t.Fatalf("Failed to sync: %v", err)
+	}
+	if state, _ := client.GetState(); state.CurrentThread.File != "main.go" || state.CurrentThread.Line != 11 {
+		t.Errorf("Expected to stop at main.go:11, got %s:%d", state.CurrentThread.File, state.CurrentThread.Line)
+	}
+	if len(client.Breakpoints) != 0 {
+		t.Errorf("Expected the temporary breakpoint to be cleared, got %v", client.Breakpoints)
+	}
+
+	// A function entry whose line can't be used falls back to a function breakpoint
+	client.InvalidLocations["work.go:5"] = true
+	if err := cli.syncDebuggerToEvent(2); err != nil {
+		t.Fatalf("Failed to sync to function: %v", err)
+	}
+	if state, _ := client.GetState(); state.CurrentThread.Function.Name() != "main.work" {
+		t.Errorf("Expected to stop in main.work, got %s", state.CurrentThread.Function.Name())
+	}
+
+	// Without a usable location anywhere nearby, sync fails
+	for _, location := range []string{"main.go:10", "main.go:11"} {
+		client.InvalidLocations[location] = true
+	}
+	if err := cli.syncDebuggerToEvent(3); err == nil {
+		t.Error("Expected sync to fail without a usable location")
+	}
+	if err := cli.syncDebuggerToEvent(10); err == nil {
+		t.Error("Expected an error for an invalid event index")
+	}
+}
+
+func TestResetDebuggerToEvent(t *testing.T) {
+	cli, oldClient := newFakeDelveCLI(t)
+	newClient := testutil.NewFakeDelveClient()
+
+	originalStart := startDelveDebugger
+	startDelveDebugger = func(targetPath string) (*DelveDebugger, error) {
+		if targetPath != "prog" {
+			t.Errorf("Expected to restart prog, got %s", targetPath)
+		}
+		return NewDelveDebuggerWithClient(targetPath, newClient), nil
+	}
+	defer func() {
+		startDelveDebugger = originalStart
+	}()
+
+	if _, err := cli.bpManager.AddBreakpoint("work.go:7"); err != nil {
+		t.Fatalf("Failed to add breakpoint: %v", err)
+	}
+	cli.handleCommand("config loadstring 500")
+
+	if err := cli.resetDebuggerToEvent(1); err != nil {
+		t.Fatalf("Failed to reset: %v", err)
+	}
+
+	if oldClient.Called("Disconnect") != 1 {
+		t.Error("Expected the old session to be closed")
+	}
+	if cli.debugger.LoadConfig().MaxStringLen != 500 {
+		t.Errorf("Expected the load config to survive the reset, got %d", cli.debugger.LoadConfig().MaxStringLen)
+	}
+
+	// The managed breakpoint is set in the new process and is all that's left
+	breakpoints, _ := newClient.ListBreakpoints(false)
+	if len(breakpoints) != 1 || breakpoints[0].File != "work.go" || breakpoints[0].Line != 7 {
+		t.Errorf("Expected only the managed breakpoint in the new process, got %v", breakpoints)
+	}
+	if managed := cli.bpManager.GetBreakpoints(); len(breakpoints) == 1 && managed[0].DelveID != breakpoints[0].ID {
+		t.Errorf("Expected managed breakpoint to point at Delve breakpoint %d, got %d", breakpoints[0].ID, managed[0].DelveID)
+	}
+
+	if state, _ := newClient.GetState(); state.CurrentThread.File != "main.go" || state.CurrentThread.Line != 11 {
+		t.Errorf("Expected to stop at main.go:11, got %s:%d", state.CurrentThread.File, state.CurrentThread.Line)
+	}
+}
+
+func TestHandlePrintVariable(t *testing.T) {
+	cli, client := newFakeDelveCLI(t)
+	client.Variables["x"] = &api.Variable{Name: "x", Type: "int", Kind: reflect.Int, Value: "42"}
+	client.Variables["s"] = &api.Variable{Name: "s", Type: "string", Kind: reflect.String, Value: strings.Repeat("a", 100)}
+
+	if out := captureOutput(t, func() { cli.handleCommand("print x") }); !strings.Contains(out, "x = 42 (type: int)") {
+		t.Errorf("Expected x to be printed, got %q", out)
+	}
+	if out := captureOutput(t, func() { cli.handleCommand("print missing") }); !strings.Contains(out, "Error getting variable 'missing'") {
+		t.Errorf("Expected an error for a missing variable, got %q", out)
+	}
+
+	// Strings are cut to the load limit until it is raised
+	if out := captureOutput(t, func() { cli.handleCommand("print s") }); strings.Contains(out, strings.Repeat("a", 65)) {
+		t.Errorf("Expected s to be cut to 64 bytes, got %q", out)
+	}
+	cli.handleCommand("config loadstring 200")
+	if out := captureOutput(t, func() { cli.handleCommand("print s") }); !strings.Contains(out, strings.Repeat("a", 100)) {
+		t.Errorf("Expected all of s after raising the limit, got %q", out)
+	}
+}
+
+func TestRestart(t *testing.T) {
+	cli, _ := newFakeDelveCLI(t)
+	replayer := cli.replayer.(*replay.BasicReplayer)
+	newClient := testutil.NewFakeDelveClient()
+	newClient.State.CurrentThread.Function = nil
+
+	originalStart := startDelveDebugger
+	startDelveDebugger = func(targetPath string) (*DelveDebugger, error) {
+		return NewDelveDebuggerWithClient(targetPath, newClient), nil
+	}
+	defer func() {
+		startDelveDebugger = originalStart
+	}()
+
+	if err := replayer.ReplayUntilWatchpoint(func(e recorder.Event) bool { return e.ID == 3 }, nil); err != nil {
+		t.Fatalf("Failed to replay: %v", err)
+	}
+	if replayer.CurrentIndex() != 2 || len(replayer.WatchpointHits()) != 1 {
+		t.Fatalf("Expected a watchpoint hit at event 2, got index %d, hits %v", replayer.CurrentIndex(), replayer.WatchpointHits())
+	}
+
+	cli.handleCommand("restart")
+
+	if replayer.CurrentIndex() != -1 {
+		t.Errorf("Expected index -1 after restart, got %d", replayer.CurrentIndex())
+	}
+	if len(replayer.WatchpointHits()) != 0 {
+		t.Errorf("Expected watchpoint hits to be cleared, got %v", replayer.WatchpointHits())
+	}
+	if replayer.ActiveGoroutine() != 1 {
+		t.Errorf("Expected goroutine 1 active, got %d", replayer.ActiveGoroutine())
+	}
+	if len(replayer.Events()) != 4 {
+		t.Errorf("Expected the events to be kept, got %d", len(replayer.Events()))
+	}
+
+	// Delve is restarted at main.main
+	if state, _ := newClient.GetState(); state.CurrentThread.Function == nil || state.CurrentThread.Function.Name() != "main.main" {
+		t.Errorf("Expected Delve to stop at main.main, got %+v", state.CurrentThread)
+	}
+
+	cli.handleCommand("s")
+	if replayer.CurrentIndex() != 0 {
+		t.Errorf("Expected to step to the first event, got %d", replayer.CurrentIndex())
+	}
+}
+
+func TestIOCommand(t *testing.T) {
+	base := time.Now()
+	details := []string{
+		"File state.json (fd 3): write 20 bytes",
+		"Conn tcp 10.0.0.1:80: read 512 bytes",
+		"File state.json (fd 3): write 8 bytes",
+		"File state.json (fd 4): read 28 bytes",
+	}
+	var events []recorder.Event
+	for i, d := range details {
+		events = append(events, recorder.Event{ID: int64(i + 1), Timestamp: base.Add(time.Duration(i) * time.Millisecond),
+			Type: recorder.IOEvent, Details: d})
+	}
+
+	replayer := replay.NewBasicReplayer()
+	if err := replayer.LoadEvents(events); err != nil {
+		t.Fatalf("Failed to load events: %v", err)
+	}
+	cli := NewCLI(replayer)
+
+	output := captureOutput(t, func() { cli.handleCommand("io") })
+	if !strings.Contains(output, "file state.json") || !strings.Contains(output, "out       28 bytes (2 writes)  last write [2]") ||
+		!strings.Contains(output, "conn tcp 10.0.0.1:80") {
+		t.Errorf("Unexpected summary:\n%s", output)
+	}
+
+	// From the read, find the write it saw
+	if err := replayer.ReplayToEventIndex(3); err != nil {
+		t.Fatalf("Failed to replay: %v", err)
+	}
+	cli.handleCommand("io state.json")
+	if replayer.CurrentIndex() != 2 {
+		t.Errorf("Expected the last write at event 2, got %d", replayer.CurrentIndex())
+	}
+	cli.handleCommand("io state.json")
+	if replayer.CurrentIndex() != 0 {
+		t.Errorf("Expected the earlier write at event 0, got %d", replayer.CurrentIndex())
+	}
+	cli.handleCommand("io state.json")
+	if replayer.CurrentIndex() != 0 {
+		t.Errorf("Expected to stay at event 0 with no earlier write, got %d", replayer.CurrentIndex())
+	}
+}
+
+// mapSources serves source files from memory, like a recording bundle
+type mapSources map[string][]string
+
+func (m mapSources) Source(file string) ([]string, bool) {
+	lines, ok := m[file]
+	return lines, ok
+}
+
+func TestListCommand(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "main.go")
+	if err := os.WriteFile(file, []byte("package main\n\nfunc main() {\n\tprintln(\"hi\")\n}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	events := []recorder.Event{
+		{ID: 1, Timestamp: time.Now(), Type: recorder.StatementExecution, File: file, Line: 4, FuncName: "main.main"},
+		{ID: 2, Timestamp: time.Now().Add(time.Millisecond), Type: recorder.GoroutineSwitch},
+	}
+	replayer := replay.NewBasicReplayer()
+	if err := replayer.LoadEvents(events); err != nil {
+		t.Fatalf("Failed to load events: %v", err)
+	}
+	cli := NewCLI(replayer)
+
+	output := captureOutput(t, func() { cli.handleCommand("list") })
+	if !strings.Contains(output, "No current event") {
+		t.Errorf("Expected a hint before the replay starts, got:\n%s", output)
+	}
+
+	cli.handleCommand("step")
+	output = captureOutput(t, func() { cli.handleCommand("l 1") })
+	for _, want := range []string{file + ":4", "    3  func main() {", "=>    4  \tprintln(\"hi\")", "    5  }"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in list output:\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "package main") {
+		t.Errorf("Expected only 1 line of context:\n%s", output)
+	}
+
+	// The file is cached, and a bundle's copy is used instead when set
+	os.Remove(file)
+	output = captureOutput(t, func() { cli.handleCommand("list") })
+	if !strings.Contains(output, "package main") {
+		t.Errorf("Expected the cached file to be listed:\n%s", output)
+	}
+	cli.SetSources(mapSources{file: {"package bundled", "", "func main() {", "\tprintln(\"bundled\")"}})
+	output = captureOutput(t, func() { cli.handleCommand("list") })
+	if !strings.Contains(output, "=>    4  \tprintln(\"bundled\")") {
+		t.Errorf("Expected the bundled source to be listed:\n%s", output)
+	}
+
+	cli.handleCommand("step")
+	output = captureOutput(t, func() { cli.handleCommand("list") })
+	if !strings.Contains(output, "no source location") {
+		t.Errorf("Expected a note for an event without a location, got:\n%s", output)
+	}
+
+	output = captureOutput(t, func() { cli.handleCommand("list breakpoints") })
+	if !strings.Contains(output, "Breakpoints:") {
+		t.Errorf("Expected list breakpoints to list breakpoints, got:\n%s", output)
+	}
+}
+
+func TestInfoRecordingCommand(t *testing.T) {
+	dir := t.TempDir()
+	events := []recorder.Event{{ID: 1, Timestamp: time.Now(), Type: recorder.FuncEntry}}
+	replayer := replay.NewBasicReplayer()
+	if err := replayer.LoadEvents(events); err != nil {
+		t.Fatalf("Failed to load events: %v", err)
+	}
+	cli := NewCLI(replayer)
+
+	// Written without a recorder, so without metadata
+	old := filepath.Join(dir, "old.events")
+	if err := recorder.WriteEventsFile(old, events, recorder.FileRecorderOptions{CompressionType: recorder.NoCompression}); err != nil {
+		t.Fatal(err)
+	}
+	cli.SetEventsFile(old)
+	output := captureOutput(t, func() { cli.handleCommand("info recording") })
+	if !strings.Contains(output, "no metadata") {
+		t.Errorf("Expected a note for a recording without metadata, got:\n%s", output)
+	}
+
+	path := filepath.Join(dir, "chronogo.events")
+	rec, err := recorder.NewFileRecorderWithOptions(path, recorder.FileRecorderOptions{CompressionType: recorder.ZstdCompression})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.RecordEvent(events[0])
+	rec.Close()
+	cli.SetEventsFile(path)
+	output = captureOutput(t, func() { cli.handleCommand("info recording") })
+	for _, want := range []string{"Go version: " + runtime.Version(), "Platform:   " + runtime.GOOS + "/" + runtime.GOARCH} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in info recording output:\n%s", want, output)
+		}
+	}
+}
+
+func TestTagAndFilter(t *testing.T) {
+	originalDir := SessionDir
+	SessionDir = t.TempDir()
+	defer func() { SessionDir = originalDir }()
+
+	base := time.Now()
+	var events []recorder.Event
+	for i := 0; i < 10; i++ {
+		events = append(events, recorder.Event{ID: int64(i + 1), Timestamp: base.Add(time.Duration(i) * time.Millisecond),
+			Type: recorder.StatementExecution, Details: fmt.Sprintf("statement %d", i)})
+	}
+	// Tagged while recording
+	events[8].Tags = []string{"suspicious"}
+	newTaggedCLI := func() *CLI {
+		replayer := replay.NewBasicReplayer()
+		if err := replayer.LoadEvents(events); err != nil {
+			t.Fatalf("Failed to load events: %v", err)
+		}
+		return NewCLI(replayer)
+	}
+	cli := newTaggedCLI()
+
+	cli.handleCommand("tag 2-4 suspicious")
+	cli.handleCommand("tag 7 suspicious")
+	output := captureOutput(t, func() { cli.handleCommand("filter tag:suspicious") })
+	if !strings.Contains(output, "the 5 events tagged suspicious") {
+		t.Errorf("Expected the tagged events to be counted, got:\n%s", output)
+	}
+
+	for _, step := range []struct {
+		command string
+		want    int
+	}{
+		{"step", 2}, {"step", 3}, {"step 2", 7}, {"backstep", 4}, {"step 2", 8},
+	} {
+		cli.handleCommand(step.command)
+		if got := cli.replayer.CurrentIndex(); got != step.want {
+			t.Fatalf("After %q: expected event %d, got %d", step.command, step.want, got)
+		}
+	}
+	output = captureOutput(t, func() { cli.handleCommand("step") })
+	if !strings.Contains(output, "No later events are tagged suspicious") || cli.replayer.CurrentIndex() != 8 {
+		t.Errorf("Expected to stay at the last tagged event, got:\n%s", output)
+	}
+
+	output = captureOutput(t, func() { cli.handleCommand("inspect 3") })
+	if !strings.Contains(output, "Tags:      suspicious") {
+		t.Errorf("Expected inspect to show the tags, got:\n%s", output)
+	}
+
+	cli.handleCommand("filter off")
+	cli.handleCommand("backstep")
+	if got := cli.replayer.CurrentIndex(); got != 7 {
+		t.Errorf("Expected an unfiltered backstep to reach event 7, got %d", got)
+	}
+
+	// Tags added in the session come back with it
+	if err := cli.SaveSession("tagged"); err != nil {
+		t.Fatalf("Failed to save session: %v", err)
+	}
+	restored := newTaggedCLI()
+	if err := restored.RestoreSession("tagged"); err != nil {
+		t.Fatalf("Failed to restore session: %v", err)
+	}
+	var tagged []int
+	for i, event := range restored.replayer.Events() {
+		if event.HasTag("suspicious") {
+			tagged = append(tagged, i)
+		}
+	}
+	if !reflect.DeepEqual(tagged, []int{2, 3, 4, 7, 8}) {
+		t.Errorf("Expected the session's tags to be restored, got %v", tagged)
+	}
+	if events[2].Tags != nil {
+		t.Error("Expected tagging to leave the loaded events untouched")
+	}
+
+	// Events inserted in the timeline, as set does, move the tags after them
+	cli.handleCommand("tag 5 later")
+	cli.handleCommand("backstep 4")
+	if err := cli.insertEvent(recorder.Event{Type: recorder.VarAssignment, Details: "Debugger set x = 5"}); err != nil {
+		t.Fatalf("Failed to insert event: %v", err)
+	}
+	if got := fmt.Sprint(cli.tags); got != "map[2:[suspicious] 3:[suspicious] 5:[suspicious] 6:[later] 8:[suspicious]]" {
+		t.Errorf("Expected the tags after the inserted event to move, got %s", got)
+	}
+}
+
+func TestFilterRegisteredType(t *testing.T) {
+	cacheMiss := recorder.RegisterEventType("CLITestCacheMiss")
+	base := time.Now()
+	var events []recorder.Event
+	for i := 0; i < 7; i++ {
+		typ := recorder.StatementExecution
+		if i%2 == 1 {
+			typ = cacheMiss
+		}
+		events = append(events, recorder.Event{ID: int64(i + 1), Timestamp: base.Add(time.Duration(i) * time.Millisecond),
+			Type: typ, Details: fmt.Sprintf("event %d", i)})
+	}
+	replayer := replay.NewBasicReplayer()
+	if err := replayer.LoadEvents(events); err != nil {
+		t.Fatalf("Failed to load events: %v", err)
+	}
+	cli := NewCLI(replayer)
+
+	output := captureOutput(t, func() { cli.handleCommand("filter type=CLITestCacheMiss") })
+	if !strings.Contains(output, "the 3 events of type CLITestCacheMiss") {
+		t.Errorf("Expected the events of the registered type to be counted, got:\n%s", output)
+	}
+	for _, want := range []int{1, 3, 5} {
+		cli.handleCommand("step")
+		if got := cli.replayer.CurrentIndex(); got != want {
+			t.Fatalf("Expected step to stop at event %d, got %d", want, got)
+		}
+	}
+	output = captureOutput(t, func() { cli.handleCommand("step") })
+	if !strings.Contains(output, "No later events are of type CLITestCacheMiss") {
+		t.Errorf("Expected no later matches, got:\n%s", output)
+	}
+
+	output = captureOutput(t, func() { cli.handleCommand("map 7") })
+	if !strings.Contains(output, "|S@S@S@S|") {
+		t.Errorf("Expected the map to mark the registered type, got:\n%s", output)
+	}
+	output = captureOutput(t, func() { cli.handleCommand("filter type:NoSuchType") })
+	if !strings.Contains(output, "Unknown event type: NoSuchType") {
+		t.Errorf("Expected an unknown type to be rejected, got:\n%s", output)
+	}
+}
+
+func TestInspectCommand(t *testing.T) {
+	base := time.Now()
+	// Written out of order, so replay sorts the second line first
+	events := []recorder.Event{
+		{ID: 2, Timestamp: base.Add(time.Millisecond), Type: recorder.StatementExecution, Details: "done"},
+		{ID: 1, Timestamp: base, Type: recorder.StatementExecution, Details: `request {"user":"ann","ids":[1,2]}`,
+			File: "main.go", Line: 12, FuncName: "main.handle", TraceID: "req-a"},
+	}
+	path := filepath.Join(t.TempDir(), "events.log")
+	if err := recorder.WriteEventsFile(path, events, recorder.FileRecorderOptions{CompressionType: recorder.NoCompression}); err != nil {
+		t.Fatalf("Failed to write events: %v", err)
+	}
+
+	replayer := replay.NewBasicReplayer()
+	if err := replayer.LoadEvents(events); err != nil {
+		t.Fatalf("Failed to load events: %v", err)
+	}
+	cli := NewCLI(replayer)
+
+	output := captureOutput(t, func() { cli.handleCommand("inspect") })
+	if !strings.Contains(output, "No current event") {
+		t.Errorf("Expected a hint before the replay starts, got:\n%s", output)
+	}
+
+	output = captureOutput(t, func() { cli.handleCommand("inspect 0") })
+	for _, want := range []string{"Event 0 of 2", "Location:  main.go:12", "TraceID:   req-a", "Details (34 bytes)", "JSON at byte 8 (26 bytes)", "    \"user\": \"ann\","} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in inspect output:\n%s", want, output)
+		}
+	}
+
+	output = captureOutput(t, func() { cli.handleCommand("inspect 0 --raw") })
+	if !strings.Contains(output, "the events file is not known") {
+		t.Errorf("Expected an error without an events file, got:\n%s", output)
+	}
+
+	cli.SetEventsStream("stdin")
+	output = captureOutput(t, func() { cli.handleCommand("inspect 0 --raw") })
+	if !strings.Contains(output, "read from stdin, which can't be read again") {
+		t.Errorf("Expected an error for a streamed recording, got:\n%s", output)
+	}
+
+	cli.SetEventsFile(path)
+	if err := replayer.ReplayToEventIndex(0); err != nil {
+		t.Fatalf("Failed to replay: %v", err)
+	}
+	output = captureOutput(t, func() { cli.handleCommand("inspect --raw") })
+	if !strings.HasPrefix(output, `{"ID":1,`) || !strings.Contains(output, `"TraceID":"req-a"`) {
+		t.Errorf("Expected the serialized line of event 1, got:\n%s", output)
+	}
+}
+
+func TestInterruptContinue(t *testing.T) {
+	// Replay pauses between events, so continuing over these takes seconds
+	base := time.Now()
+	var events []recorder.Event
+	for i := 0; i < 100; i++ {
+		events = append(events, recorder.Event{ID: int64(i + 1), Timestamp: base.Add(time.Duration(i) * time.Millisecond),
+			Type: recorder.StatementExecution, Details: fmt.Sprintf("statement %d", i)})
+	}
+	replayer := replay.NewBasicReplayer()
+	if err := replayer.LoadEvents(events); err != nil {
+		t.Fatalf("Failed to load events: %v", err)
+	}
+	cli := NewCLI(replayer)
+
+	exitCode := -1
+	originalExit := exitProcess
+	exitProcess = func(code int) { exitCode = code }
+	defer func() { exitProcess = originalExit }()
+
+	sigs := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	defer close(done)
+	go cli.watchSignals(sigs, done)
+
+	output := captureOutput(t, func() {
+		finished := make(chan struct{})
+		go func() {
+			cli.runCommand("continue")
+			close(finished)
+		}()
+		time.Sleep(200 * time.Millisecond)
+		sigs <- os.Interrupt
+
+		select {
+		case <-finished:
+		case <-time.After(3 * time.Second):
+			t.Fatal("continue was not interrupted")
+		}
+	})
+	if !strings.Contains(output, "Interrupted") {
+		t.Errorf("Expected the replay to report the interrupt, got:\n%s", output)
+	}
+	if idx := replayer.CurrentIndex(); idx >= len(events)-1 {
+		t.Errorf("Expected the replay to stop early, stopped at %d", idx)
+	}
+	if exitCode != -1 || cli.interrupts.Load() != 0 {
+		t.Errorf("Expected a single Ctrl-C not to exit and to be forgotten once continue returns, got exit code %d", exitCode)
+	}
+
+	// Two presses at the prompt exit
+	cli.running = true
+	captureOutput(t, func() {
+		cli.handleSignal()
+		cli.handleSignal()
+	})
+	if exitCode != 130 || cli.running {
+		t.Errorf("Expected a second Ctrl-C to exit with 130, got %d (running %v)", exitCode, cli.running)
+	}
+}
+
+func TestCheckpointCommands(t *testing.T) {
+	base := time.Now()
+	var events []recorder.Event
+	for i := 0; i < 5; i++ {
+		events = append(events, recorder.Event{ID: int64(i + 1), Timestamp: base.Add(time.Duration(i) * time.Millisecond),
+			Type: recorder.StatementExecution, Details: fmt.Sprintf("statement %d", i)})
+	}
+	replayer := replay.NewBasicReplayer()
+	if err := replayer.LoadEvents(events); err != nil {
+		t.Fatalf("Failed to load events: %v", err)
+	}
+	cli := NewCLI(replayer)
+
+	output := captureOutput(t, func() {
+		replayer.ReplayToEventIndex(1)
+		cli.handleCommand("checkpoint")
+		replayer.ReplayToEventIndex(3)
+		cli.handleCommand("checkpoint")
+		cli.handleCommand("checkpoints")
+	})
+	if !strings.Contains(output, "Checkpoint 1 at event 1") || !strings.Contains(output, "> 2: event 3") ||
+		!strings.Contains(output, "  1: event 1") {
+		t.Errorf("Unexpected checkpoint output:\n%s", output)
+	}
+
+	output = captureOutput(t, func() { cli.handleCommand("restore 1") })
+	if replayer.CurrentIndex() != 1 || !strings.Contains(output, "Restored checkpoint 1 at event 1") {
+		t.Errorf("Expected to return to event 1, at %d:\n%s", replayer.CurrentIndex(), output)
+	}
+	output = captureOutput(t, func() { cli.handleCommand("restore 9") })
+	if replayer.CurrentIndex() != 1 || !strings.Contains(output, "no checkpoint 9") {
+		t.Errorf("Expected an unknown checkpoint to be rejected:\n%s", output)
+	}
+}
+
+func TestTraceCommands(t *testing.T) {
+	base := time.Now()
+	traces := []string{"", "req-a", "req-b", "req-a", "req-b", ""}
+	var events []recorder.Event
+	for i, traceID := range traces {
+		events = append(events, recorder.Event{ID: int64(i + 1), Timestamp: base.Add(time.Duration(i) * time.Millisecond),
+			Type: recorder.StatementExecution, Details: fmt.Sprintf("statement %d", i), FuncName: "main.handle", TraceID: traceID})
+	}
+	events[4].Type = recorder.DeferOperation
+	events[4].Details = "Recovered in main.handle: boom"
+
+	replayer := replay.NewBasicReplayer()
+	if err := replayer.LoadEvents(events); err != nil {
+		t.Fatalf("Failed to load events: %v", err)
+	}
+	cli := NewCLI(replayer)
+
+	output := captureOutput(t, func() { cli.handleCommand("traces") })
+	if !strings.Contains(output, "3 traces recorded") || !strings.Contains(output, "(untraced)") ||
+		!strings.Contains(output, "main.handle PANIC") {
+		t.Errorf("Unexpected trace list:\n%s", output)
+	}
+
+	output = captureOutput(t, func() { cli.handleCommand("trace req-b") })
+	if replayer.CurrentIndex() != 2 {
+		t.Errorf("Expected to jump to event 2, got %d", replayer.CurrentIndex())
+	}
+	if !strings.Contains(output, "2 events from 2 to 4") || !strings.Contains(output, "[trace req-b]") {
+		t.Errorf("Unexpected trace output:\n%s", output)
+	}
+
+	cli.handleCommand("trace req-z")
+	if replayer.CurrentIndex() != 2 {
+		t.Errorf("Expected an unknown trace not to move, got %d", replayer.CurrentIndex())
+	}
+}
+
+func TestStatsCommand(t *testing.T) {
+	replayer := replay.NewBasicReplayer()
+	if err := replayer.LoadEvents(delveEvents()); err != nil {
+		t.Fatalf("Failed to load events: %v", err)
+	}
+	cli := NewCLI(replayer)
+
+	output := captureOutput(t, func() { cli.handleStats([]string{"10000h"}) })
+	if !strings.Contains(output, "4 events over") || !strings.Contains(output, "main.main") ||
+		!strings.Contains(output, "Event types per 10000h0m0s:") || !strings.Contains(output, "FunctionEntry=2 StatementExecution=2") {
+		t.Errorf("Unexpected stats output %q", output)
+	}
+
+	output = captureOutput(t, func() { cli.handleStats([]string{"wide"}) })
+	if !strings.Contains(output, "Invalid bucket width: wide") {
+		t.Errorf("Expected an invalid width error, got %q", output)
+	}
+}
+
+func TestHistoryCommand(t *testing.T) {
+	base := time.Now()
+	var events []recorder.Event
+	add := func(eventType recorder.EventType, funcName, details string) {
+		events = append(events, recorder.Event{ID: int64(len(events) + 1), Timestamp: base.Add(time.Duration(len(events)) * time.Millisecond),
+			Type: eventType, FuncName: funcName, Details: details, GoroutineID: 1})
+	}
+	add(recorder.FuncEntry, "main.other", "Entering main.other")
+	add(recorder.VarAssignment, "main.other", "total = 99")
+	add(recorder.FuncEntry, "main.sum", "Entering main.sum")
+	total := 0
+	for i := 1; i <= 3; i++ {
+		total += i
+		add(recorder.StatementExecution, "main.sum", "total += i")
+		add(recorder.VarAssignment, "main.sum", fmt.Sprintf("total = %d", total))
+	}
+	replayer := replay.NewBasicReplayer()
+	if err := replayer.LoadEvents(events); err != nil {
+		t.Fatalf("Failed to load events: %v", err)
+	}
+	cli := NewCLI(replayer)
+	if err := replayer.ReplayToEventIndex(6); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the current function's assignments, newest last, the current one marked
+	output := captureOutput(t, func() { cli.handleCommand("history total") })
+	if !strings.Contains(output, "History of total in main.sum (3 assignments)") {
+		t.Errorf("Expected the assignments in main.sum, got:\n%s", output)
+	}
+	if strings.Contains(output, "99") {
+		t.Errorf("Expected main.other's assignment to be left out, got:\n%s", output)
+	}
+	first, second, third := strings.Index(output, "  1. [4]"), strings.Index(output, ">  2. [6]"), strings.Index(output, "  3. [8]")
+	if first < 0 || second < first || third < second {
+		t.Errorf("Expected entries in recording order with the current one marked, got:\n%s", output)
+	}
+	if !strings.Contains(output, "g1") {
+		t.Errorf("Expected the goroutine, got:\n%s", output)
+	}
+
+	output = captureOutput(t, func() { cli.handleCommand("history total --all") })
+	if !strings.Contains(output, "History of total (4 assignments)") || !strings.Contains(output, " 1. [1]") {
+		t.Errorf("Expected every function's assignments, got:\n%s", output)
+	}
+
+	output = captureOutput(t, func() { cli.handleCommand("history total goto 3") })
+	if got := replayer.CurrentIndex(); got != 8 {
+		t.Errorf("Expected goto to jump to event 8, got %d:\n%s", got, output)
+	}
+	output = captureOutput(t, func() { cli.handleCommand("history total goto 9") })
+	if !strings.Contains(output, "has 3 entries") {
+		t.Errorf("Expected an out of range entry to be reported, got:\n%s", output)
+	}
+	output = captureOutput(t, func() { cli.handleCommand("history missing") })
+	if !strings.Contains(output, "use --all") {
+		t.Errorf("Expected a hint to search every function, got:\n%s", output)
+	}
+}
+
+func TestIterationCommands(t *testing.T) {
+	// for i := 0; i < 3; i++ { for j := 0; j < 4; j++ { sum += j } }, one
+	// event per line
+	var events []recorder.Event
+	add := func(line int) {
+		events = append(events, recorder.Event{ID: int64(len(events) + 1), Type: recorder.StatementExecution,
+			File: "main.go", Line: line, FuncName: "main.main", Details: fmt.Sprintf("line %d", line), GoroutineID: 1})
+	}
+	add(9)
+	for i := 0; i < 3; i++ {
+		add(10)
+		for j := 0; j < 4; j++ {
+			add(11)
+			add(12)
+		}
+	}
+	replayer := replay.NewBasicReplayer()
+	if err := replayer.LoadEvents(events); err != nil {
+		t.Fatalf("Failed to load events: %v", err)
+	}
+	cli := NewCLI(replayer)
+
+	// Line 12 of the second inner iteration, in the second outer iteration
+	start := 1 + 9 + 1 + 2 + 1
+	if err := replayer.ReplayToEventIndex(start); err != nil {
+		t.Fatal(err)
+	}
+	for _, step := range []struct {
+		command string
+		want    int
+	}{
+		{"nextiter", start + 2}, {"nextiter", start + 4}, {"previter", start + 2}, {"iter 1", start - 2},
+	} {
+		output := captureOutput(t, func() { cli.handleCommand(step.command) })
+		if got := replayer.CurrentIndex(); got != step.want {
+			t.Fatalf("After %q: expected event %d, got %d:\n%s", step.command, step.want, got, output)
+		}
+	}
+	output := captureOutput(t, func() { cli.handleCommand("previter") })
+	if !strings.Contains(output, "Already at the first iteration") {
+		t.Errorf("Expected to stop at the first iteration, got:\n%s", output)
+	}
+	output = captureOutput(t, func() { cli.handleCommand("iter 9") })
+	if !strings.Contains(output, "ran 4 iterations") {
+		t.Errorf("Expected the iteration count, got:\n%s", output)
+	}
+
+	// The outer loop, from its header
+	replayer.ReplayToEventIndex(10)
+	output = captureOutput(t, func() { cli.handleCommand("iter 3") })
+	if got := replayer.CurrentIndex(); got != 19 || !strings.Contains(output, "Iteration 3 of 3 of the loop at main.go:10") {
+		t.Errorf("Expected the third outer iteration at event 19, got %d:\n%s", got, output)
+	}
+
+	replayer.ReplayToEventIndex(0)
+	output = captureOutput(t, func() { cli.handleCommand("nextiter") })
+	if !strings.Contains(output, "isn't in a loop") {
+		t.Errorf("Expected the first event to be outside loops, got:\n%s", output)
+	}
+}
+
+func TestWhenCommand(t *testing.T) {
+	base := time.Now()
+	var events []recorder.Event
+	add := func(eventType recorder.EventType, funcName string, line, goroutine int) {
+		events = append(events, recorder.Event{ID: int64(len(events) + 1), Timestamp: base.Add(time.Duration(len(events)) * time.Millisecond),
+			Type: eventType, FuncName: funcName, File: "/src/app/main.go", Line: line, Details: fmt.Sprintf("line %d", line), GoroutineID: goroutine})
+	}
+	add(recorder.FuncEntry, "main.main", 10, 1)
+	for g := 2; g <= 3; g++ {
+		add(recorder.FuncEntry, "main.processData", 56, g)
+		add(recorder.StatementExecution, "main.processData", 57, g)
+		add(recorder.FuncExit, "main.processData", 58, g)
+	}
+	replayer := replay.NewBasicReplayer()
+	if err := replayer.LoadEvents(events); err != nil {
+		t.Fatalf("Failed to load events: %v", err)
+	}
+	cli := NewCLI(replayer)
+
+	// A line, by the recorded file's base name
+	output := captureOutput(t, func() { cli.handleCommand("when main.go:57") })
+	if !strings.Contains(output, "main.go:57: 2 events") {
+		t.Errorf("Expected two events at line 57, got:\n%s", output)
+	}
+	first, second := strings.Index(output, "[2]"), strings.Index(output, "[5]")
+	if first < 0 || second < first || !strings.Contains(output, "g2") || !strings.Contains(output, "g3") {
+		t.Errorf("Expected the events in order with their goroutines, got:\n%s", output)
+	}
+
+	// Every entry and exit of a function, by its short name
+	output = captureOutput(t, func() { cli.handleCommand("when processData --count") })
+	if strings.TrimSpace(output) != "4" {
+		t.Errorf("Expected 4 entries and exits, got:\n%s", output)
+	}
+	output = captureOutput(t, func() { cli.handleCommand("when func:processData") })
+	if !strings.Contains(output, "4 events") || !strings.Contains(output, "[1]") || !strings.Contains(output, "[6]") {
+		t.Errorf("Expected the entries and exits, got:\n%s", output)
+	}
+
+	output = captureOutput(t, func() { cli.handleCommand("when main.go:99") })
+	if !strings.Contains(output, "would never fire") {
+		t.Errorf("Expected no events at line 99, got:\n%s", output)
+	}
+	output = captureOutput(t, func() { cli.handleCommand("when") })
+	if !strings.Contains(output, "Usage: when") {
+		t.Errorf("Expected the usage, got:\n%s", output)
+	}
+}
+
+func TestStackCommand(t *testing.T) {
+	originalColor := colorEnabled
+	defer SetColor(originalColor)
+	SetColor(false)
+
+	events := []recorder.Event{
+		{ID: 1, Type: recorder.FuncEntry, FuncName: "main.process", File: "demo.go", Line: 38, Details: "Entering main.process"},
+		{ID: 2, Type: recorder.FuncEntry, FuncName: "main.parse", File: "demo.go", Line: 56, Details: "Entering main.parse"},
+		{ID: 3, Type: recorder.ErrorEvent, FuncName: "main.parse", File: "demo.go", Line: 60, Details: "Panic in main.parse: empty field (string)", GoroutineID: 1},
+		{ID: 4, Type: recorder.DeferOperation, FuncName: "main.process", File: "demo.go", Line: 42, Details: "Entering deferred call in main.process at demo.go:42", Deferred: true},
+		{ID: 5, Type: recorder.DeferOperation, FuncName: "main.process", File: "demo.go", Line: 46, Details: "Recovered in main.process: empty field", Deferred: true, GoroutineID: 1},
+	}
+	replayer := replay.NewBasicReplayer()
+	if err := replayer.LoadEvents(events); err != nil {
+		t.Fatalf("Failed to load events: %v", err)
+	}
+	cli := NewCLI(replayer)
+
+	output := captureOutput(t, func() { cli.handleCommand("stack") })
+	if !strings.Contains(output, "No current event") {
+		t.Errorf("Expected no stack before the first step, got:\n%s", output)
+	}
+
+	replayer.ReplayToEventIndex(2)
+	output = captureOutput(t, func() { cli.handleCommand("bt") })
+	inner, outer := strings.Index(output, "#0 main.parse at demo.go:56"), strings.Index(output, "#1 main.process at demo.go:38")
+	if inner < 0 || outer < inner {
+		t.Errorf("Expected parse above process, got:\n%s", output)
+	}
+
+	// The deferred call runs on top of process, parse unwound
+	replayer.ReplayToEventIndex(4)
+	output = captureOutput(t, func() { cli.handleCommand("stack") })
+	if !strings.Contains(output, "#0 [deferred] main.process at demo.go:42") || strings.Contains(output, "main.parse") {
+		t.Errorf("Expected the deferred frame marked, got:\n%s", output)
+	}
+
+	output = captureOutput(t, func() { cli.handleCommand("errors") })
+	if !strings.Contains(output, "recovered by the deferred call in main.process at event 4") {
+		t.Errorf("Expected the panic linked to its recover, got:\n%s", output)
+	}
+}
+
+func TestVerifySync(t *testing.T) {
+	cli, client := newFakeDelveCLI(t)
+
+	// Synchronized to main.go:11, recorded with a relative path
+	if err := cli.replayer.ReplayToEventIndex(1); err != nil {
+		t.Fatal(err)
+	}
+	client.State.CurrentThread.File = "/src/app/main.go"
+	client.State.CurrentThread.Line = 11
+	if ok, msg := cli.checkSync(); !ok || !strings.Contains(msg, "In sync at event 1") {
+		t.Errorf("Expected replay and Delve to agree, got %v: %s", ok, msg)
+	}
+
+	// Drifted to another function
+	client.State.CurrentThread.Line = 30
+	client.State.CurrentThread.Function = &api.Function{Name_: "main.(*Server).Handle"}
+	output := captureOutput(t, func() { cli.handleCommand("verify-sync") })
+	if !strings.Contains(output, "Out of sync at event 1") || !strings.Contains(output, "line: replay 11, Delve 30") ||
+		!strings.Contains(output, "function: replay main.main, Delve main.(*Server).Handle") {
+		t.Errorf("Expected the discrepancy, got:\n%s", output)
+	}
+	if strings.Contains(output, "file:") {
+		t.Errorf("Expected the file to agree, got:\n%s", output)
+	}
+
+	// Only what the event recorded is compared
+	cli.replayer.ReplayToEventIndex(3)
+	if ok, msg := cli.checkSync(); ok || !strings.Contains(msg, "function: replay main.work") || strings.Contains(msg, "line:") {
+		t.Errorf("Expected only the function to be compared, got %v: %s", ok, msg)
+	}
+	client.State.CurrentThread.Function = &api.Function{Name_: "main.work"}
+	if ok, msg := cli.checkSync(); !ok {
+		t.Errorf("Expected the functions to agree, got %s", msg)
+	}
+
+	replayer := replay.NewBasicReplayer()
+	replayer.LoadEvents(delveEvents())
+	if ok, msg := NewCLI(replayer).checkSync(); ok || msg != "Delve integration not enabled" {
+		t.Errorf("Expected no check without Delve, got %v: %s", ok, msg)
+	}
+}
+
+func TestReplayWindow(t *testing.T) {
+	originalDir := SessionDir
+	SessionDir = t.TempDir()
+	defer func() { SessionDir = originalDir }()
+
+	base := time.Now()
+	var events []recorder.Event
+	for i := 0; i < 1500; i++ {
+		events = append(events, recorder.Event{ID: int64(i + 1), Timestamp: base.Add(time.Duration(i) * time.Millisecond),
+			Type: recorder.StatementExecution, Details: fmt.Sprintf("statement %d", i)})
+	}
+	events[1050].Type, events[1050].Details = recorder.ErrorEvent, "Error in main: boom"
+	events[1060].File, events[1060].Line = "main.go", 7
+	newWindowCLI := func(from, to int) *CLI {
+		window, err := replay.ScanEventWindow(func(fn func(recorder.Event) error) error {
+			for _, e := range events {
+				if err := fn(e); err != nil {
+					return err
+				}
+			}
+			return nil
+		}, replay.WindowBound{Index: from}, replay.WindowBound{Index: to})
+		if err != nil {
+			t.Fatalf("ScanEventWindow failed: %v", err)
+		}
+		replayer := replay.NewBasicReplayer()
+		if err := replayer.LoadWindow(window); err != nil {
+			t.Fatalf("LoadWindow failed: %v", err)
+		}
+		return NewCLI(replayer)
+	}
+	cli := newWindowCLI(1000, 1199)
+
+	output := captureOutput(t, func() { cli.handleCommand("info") })
+	if !strings.Contains(output, "Viewing events 1000–1199 of 1,500") {
+		t.Errorf("Expected the window in info, got:\n%s", output)
+	}
+
+	// Commands take and show indices in the whole recording
+	output = captureOutput(t, func() { cli.handleCommand("inspect 1005") })
+	if !strings.Contains(output, "Event 1005 of 1500") || !strings.Contains(output, "statement 1005") {
+		t.Errorf("Expected event 1005 of the recording, got:\n%s", output)
+	}
+	output = captureOutput(t, func() { cli.handleCommand("inspect 5") })
+	if !strings.Contains(output, "event index 5 out of range (1000-1199)") {
+		t.Errorf("Expected an index outside the window to be refused, got:\n%s", output)
+	}
+
+	// Indices one command prints are those the next takes
+	output = captureOutput(t, func() { cli.handleCommand("errors") })
+	if !strings.Contains(output, "[1050]") {
+		t.Fatalf("Expected the error listed as event 1050, got:\n%s", output)
+	}
+	output = captureOutput(t, func() { cli.handleCommand("inspect 1050") })
+	if !strings.Contains(output, "Event 1050 of 1500") || !strings.Contains(output, "boom") {
+		t.Errorf("Expected to inspect the listed error, got:\n%s", output)
+	}
+	for _, step := range []struct{ command, want string }{
+		{"next-error", "Error at event 1050"},
+		{"checkpoint", "Checkpoint 1 at event 1050"},
+		{"find main.go:7", "At event 1060"},
+		{"map", "current event 1060 of 1500"},
+		{"checkpoints", "1: event 1050"},
+	} {
+		output = captureOutput(t, func() { cli.handleCommand(step.command) })
+		if !strings.Contains(output, step.want) {
+			t.Errorf("Expected %q from %s, got:\n%s", step.want, step.command, output)
+		}
+	}
+
+	cli.handleCommand("tag 1010-1011 suspicious")
+	if !cli.replayer.Events()[10].HasTag("suspicious") || !cli.replayer.Events()[11].HasTag("suspicious") {
+		t.Error("Expected events 1010-1011 of the recording tagged")
+	}
+
+	// Sessions keep recording indices, so they carry over to other windows
+	cli.replayer.ReplayToEventIndex(20)
+	if err := cli.SaveSession("window"); err != nil {
+		t.Fatalf("SaveSession failed: %v", err)
+	}
+	other := newWindowCLI(900, 1099)
+	captureOutput(t, func() {
+		if err := other.RestoreSession("window"); err != nil {
+			t.Errorf("RestoreSession failed: %v", err)
+		}
+	})
+	if idx := other.replayer.CurrentIndex(); idx != 120 || !other.replayer.Events()[110].HasTag("suspicious") {
+		t.Errorf("Expected event 1020 current and 1010 tagged in the other window, got index %d", idx)
+	}
+}
+
+func TestFormatCount(t *testing.T) {
+	for n, want := range map[int]string{0: "0", 999: "999", 1000: "1,000", 2340000: "2,340,000", -1500: "-1,500"} {
+		if got := formatCount(n); got != want {
+			t.Errorf("formatCount(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestRecordedGoroutinesCommand(t *testing.T) {
+	events := []recorder.Event{
+		{ID: 1, Type: recorder.FuncEntry, FuncName: "main.main", File: "main.go", Line: 10, GoroutineID: 1},
+		{ID: 2, Type: recorder.FuncEntry, FuncName: "main.worker", File: "main.go", Line: 20, GoroutineID: 2},
+		{ID: 3, Type: recorder.RuntimeEvent, Details: "Goroutine 7 blocked: sync", GoroutineID: 2, OSThreadID: 23550, ProcID: 1},
+		{ID: 4, Type: recorder.GoroutineSwitch, Details: "Goroutine 3 created", GoroutineID: 3},
+	}
+	replayer := replay.NewBasicReplayer()
+	if err := replayer.LoadEvents(events); err != nil {
+		t.Fatalf("Failed to load events: %v", err)
+	}
+	cli := NewCLI(replayer)
+
+	replayer.ReplayToEventIndex(2)
+	output := captureOutput(t, func() { cli.handleCommand("goroutines") })
+	if !strings.Contains(output, "2 goroutines recorded up to event 2") {
+		t.Errorf("Expected the goroutines seen so far, got:\n%s", output)
+	}
+	if !strings.Contains(output, "Goroutine 1 - main.main (main.go:10)\n") {
+		t.Errorf("Expected goroutine 1 without a thread, got:\n%s", output)
+	}
+	if !strings.Contains(output, "Goroutine 2 - main.worker (main.go:20) on thread 23550, P0") {
+		t.Errorf("Expected goroutine 2's thread, got:\n%s", output)
+	}
+
+	if got := formatRuntimeEvent(events[2]); got != "Goroutine 7 blocked: sync (on thread 23550, P0)" {
+		t.Errorf("Expected the thread in the event line, got %q", got)
+	}
+	if got := formatRuntimeEvent(recorder.Event{Details: "GC started"}); got != "GC started" {
+		t.Errorf("Expected no thread when unknown, got %q", got)
+	}
+}
+
+func TestInfoCommand(t *testing.T) {
+	events := []recorder.Event{
+		{ID: 1, Type: recorder.FuncEntry, FuncName: "main.main", File: "main.go", Line: 5, GoroutineID: 1},
+		{ID: 2, Type: recorder.FuncEntry, FuncName: "main.add", File: "main.go", Line: 12, GoroutineID: 1},
+		{ID: 3, Type: recorder.VarAssignment, FuncName: "main.add", File: "main.go", Line: 13, Details: "sum = 3", GoroutineID: 1},
+	}
+	replayer := replay.NewBasicReplayer()
+	if err := replayer.LoadEvents(events); err != nil {
+		t.Fatalf("Failed to load events: %v", err)
+	}
+	cli := NewCLI(replayer)
+	if _, err := cli.bpManager.AddBreakpoint("main.go:13"); err != nil {
+		t.Fatalf("Failed to add breakpoint: %v", err)
+	}
+	replayer.ReplayToEventIndex(2)
+
+	output := captureOutput(t, func() { cli.handleCommand("info") })
+	for _, want := range []string{"Goroutine: 1", "#0 main.add at main.go:12", "#1 main.main at main.go:5", "sum = 3 (event 2)", "1: main.go:13 (location)"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in info, got:\n%s", want, output)
+		}
+	}
+
+	output = captureOutput(t, func() { cli.handleCommand("info --json") })
+	var ctx replay.ReplayContext
+	if err := json.Unmarshal([]byte(output), &ctx); err != nil {
+		t.Fatalf("Expected JSON, got %v:\n%s", err, output)
+	}
+	if ctx.Index != 2 || len(ctx.CallStack) != 2 || len(ctx.Locals) != 1 || len(ctx.Breakpoints) != 1 {
+		t.Errorf("Unexpected context %+v", ctx)
+	}
+}
+
+func TestBisectCommand(t *testing.T) {
+	var events []recorder.Event
+	for i := 1; i <= 100; i++ {
+		events = append(events, recorder.Event{ID: int64(i), Type: recorder.VarAssignment, FuncName: "main.sum", Details: fmt.Sprintf("total = %d", 20*i)})
+	}
+	events[70].FuncName = "main.handleLargeResult"
+	replayer := replay.NewBasicReplayer()
+	if err := replayer.LoadEvents(events); err != nil {
+		t.Fatalf("Failed to load events: %v", err)
+	}
+	cli := NewCLI(replayer)
+
+	output := captureOutput(t, func() { cli.handleCommand("bisect var total > 1000") })
+	if !strings.Contains(output, "first holds at event 50") || replayer.CurrentIndex() != 50 {
+		t.Errorf("Expected a jump to event 50, at %d, got:\n%s", replayer.CurrentIndex(), output)
+	}
+
+	output = captureOutput(t, func() { cli.handleCommand(`bisect event.func == "main.handleLargeResult"`) })
+	if !strings.Contains(output, "every event was checked") || replayer.CurrentIndex() != 70 {
+		t.Errorf("Expected a warning and a jump to event 70, at %d, got:\n%s", replayer.CurrentIndex(), output)
+	}
+
+	output = captureOutput(t, func() { cli.handleCommand("bisect var total > 99999") })
+	if !strings.Contains(output, "never holds") || replayer.CurrentIndex() != 70 {
+		t.Errorf("Expected no jump, got:\n%s", output)
+	}
+}
+
+func TestFindCommand(t *testing.T) {
+	original := replay.HostPaths
+	replay.HostPaths = replay.NewPathResolver("windows")
+	defer func() { replay.HostPaths = original }()
+
+	now := time.Now()
+	var events []recorder.Event
+	for i, line := range []int{10, 42, 11, 42, 12} {
+		events = append(events, recorder.Event{
+			ID: int64(i + 1), Timestamp: now, Type: recorder.StatementExecution,
+			FuncName: "main.loop", File: `C:\src\app\main.go`, Line: line,
+		})
+	}
+	replayer := replay.NewBasicReplayer()
+	if err := replayer.LoadEvents(events); err != nil {
+		t.Fatalf("Failed to load events: %v", err)
+	}
+	cli := NewCLI(replayer)
+
+	steps := []struct {
+		command string
+		idx     int
+	}{
+		{`find C:\src\app\main.go:42`, 1},
+		{"find c:/SRC/app/main.go:42", 3},
+		{`find app\main.go:42`, 3}, // No later one
+		{"find-prev main.go:42", 1},
+		{"find-prev main.go:10", 0},
+	}
+	for _, step := range steps {
+		captureOutput(t, func() { cli.handleCommand(step.command) })
+		if got := replayer.CurrentIndex(); got != step.idx {
+			t.Errorf("%s: expected event %d, got %d", step.command, step.idx, got)
+		}
+	}
+
+	output := captureOutput(t, func() { cli.handleCommand("find main.go:99") })
+	if !strings.Contains(output, "No events recorded at main.go:99 after the current event") {
+		t.Errorf("Expected no events to be found, got %q", output)
+	}
+	if output := captureOutput(t, func() { cli.handleCommand("find main.go") }); !strings.Contains(output, "Usage: find") {
+		t.Errorf("Expected usage for a location without a line, got %q", output)
+	}
+}
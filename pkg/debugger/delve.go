@@ -1,6 +1,8 @@
 package debugger
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net"
 	"os/exec"
@@ -8,6 +10,7 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -15,12 +18,165 @@ import (
 	"github.com/go-delve/delve/service/rpc2"
 )
 
+// delveClient is the part of the Delve RPC client used by DelveDebugger. It
+// is implemented by *rpc2.RPCClient, and by fakes in tests.
+type delveClient interface {
+	GetState() (*api.DebuggerState, error)
+	Continue() <-chan *api.DebuggerState
+	Next() (*api.DebuggerState, error)
+	StepOut() (*api.DebuggerState, error)
+	Halt() (*api.DebuggerState, error)
+	SwitchGoroutine(goroutineID int64) (*api.DebuggerState, error)
+
+	CreateBreakpoint(bp *api.Breakpoint) (*api.Breakpoint, error)
+	AmendBreakpoint(bp *api.Breakpoint) error
+	ClearBreakpoint(id int) (*api.Breakpoint, error)
+	GetBreakpoint(id int) (*api.Breakpoint, error)
+	ListBreakpoints(all bool) ([]*api.Breakpoint, error)
+	ListSources(filter string) ([]string, error)
+	ListFunctions(filter string, traceFollow int) ([]string, error)
+
+	EvalVariable(scope api.EvalScope, expr string, cfg api.LoadConfig) (*api.Variable, error)
+	ListLocalVariables(scope api.EvalScope, cfg api.LoadConfig) ([]api.Variable, error)
+	ListFunctionArgs(scope api.EvalScope, cfg api.LoadConfig) ([]api.Variable, error)
+	SetVariable(scope api.EvalScope, symbol, value string) error
+	ListGoroutines(start, count int) ([]*api.Goroutine, int, error)
+
+	Detach(kill bool) error
+	Disconnect(cont bool) error
+}
+
+var _ delveClient = (*rpc2.RPCClient)(nil)
+
 // DelveDebugger wraps a Delve RPC client session, managing the underlying dlv process
 type DelveDebugger struct {
-	client    *rpc2.RPCClient
+	client    delveClient
 	target    string    // Target binary path
-	dlvCmd    *exec.Cmd // The running 'dlv exec' command
+	dlvCmd    *exec.Cmd // The running 'dlv exec' or 'dlv attach' command
 	dlvListen string    // The address dlv is listening on (e.g., "localhost:12345")
+	attached  bool      // Whether dlv attached to a running process, which Close leaves running
+
+	loadConfig api.LoadConfig // Limits for loading variable values
+	options    DelveOptions
+}
+
+// DelveOptions configures a DelveDebugger
+type DelveOptions struct {
+	Timeout time.Duration // How long to wait for Delve to answer a request, 0 to wait forever
+}
+
+// DefaultDelveOptions returns the default Delve options
+func DefaultDelveOptions() DelveOptions {
+	return DelveOptions{
+		Timeout: 30 * time.Second,
+	}
+}
+
+// DefaultLoadConfig returns the limits used when loading variable values
+func DefaultLoadConfig() api.LoadConfig {
+	return api.LoadConfig{
+		FollowPointers:     true,
+		MaxVariableRecurse: 1,
+		MaxStringLen:       64,
+		MaxArrayValues:     64,
+		MaxStructFields:    -1,
+	}
+}
+
+// ErrDelveNotInstalled is returned when the dlv binary can't be found in PATH
+var ErrDelveNotInstalled = errors.New("dlv not found in PATH; install it with 'go install github.com/go-delve/delve/cmd/dlv@latest'")
+
+// ErrDelveUnsupported is returned when the dlv binary in PATH is older than
+// MinDelveVersion, or its version can't be read
+var ErrDelveUnsupported = errors.New("unsupported dlv version")
+
+// MinDelveVersion is the oldest Delve release live debugging works with
+const MinDelveVersion = "1.21.0"
+
+// delveInstallHint tells the user how to get live debugging working
+const delveInstallHint = "install delve >= 1.21 to enable live debugging"
+
+// delveCheck caches the version check of the dlv binary last found in PATH
+var delveCheck struct {
+	mu      sync.Mutex
+	path    string
+	version string
+	err     error
+}
+
+// runDlvVersion returns the output of 'dlv version'. Tests replace it.
+var runDlvVersion = func(dlvPath string) ([]byte, error) {
+	return exec.Command(dlvPath, "version").Output()
+}
+
+// DelveAvailable returns the version of the dlv binary in PATH, e.g.
+// "1.23.1". It returns ErrDelveNotInstalled if there is none, and an error
+// wrapping ErrDelveUnsupported if it is older than MinDelveVersion. dlv is
+// only run the first time a binary is found at a path.
+func DelveAvailable() (version string, err error) {
+	_, version, err = findDelve()
+	return version, err
+}
+
+// findDelve returns the path and version of a supported dlv binary
+func findDelve() (path, version string, err error) {
+	path, err = exec.LookPath("dlv")
+	if err != nil {
+		return "", "", ErrDelveNotInstalled
+	}
+
+	delveCheck.mu.Lock()
+	defer delveCheck.mu.Unlock()
+	if delveCheck.path != path {
+		delveCheck.path = path
+		delveCheck.version, delveCheck.err = checkDelveVersion(path)
+	}
+	return path, delveCheck.version, delveCheck.err
+}
+
+// checkDelveVersion runs 'dlv version' and checks the version it reports
+func checkDelveVersion(dlvPath string) (string, error) {
+	out, err := runDlvVersion(dlvPath)
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to run dlv version: %v", ErrDelveUnsupported, err)
+	}
+	var version string
+	for _, line := range strings.Split(string(out), "\n") {
+		if v, ok := strings.CutPrefix(strings.TrimSpace(line), "Version:"); ok {
+			version = strings.TrimSpace(v)
+			break
+		}
+	}
+	if version == "" {
+		return "", fmt.Errorf("%w: no version in dlv version output", ErrDelveUnsupported)
+	}
+	if compareVersions(version, MinDelveVersion) < 0 {
+		return version, fmt.Errorf("%w: dlv %s is older than %s", ErrDelveUnsupported, version, MinDelveVersion)
+	}
+	return version, nil
+}
+
+// compareVersions compares dotted release numbers such as "1.21.0",
+// returning -1, 0 or 1. Missing or non-numeric parts count as 0, so a
+// pre-release suffix is ignored.
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(strings.TrimLeft(as[i], "v"))
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(strings.TrimLeft(bs[i], "v"))
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
 }
 
 // findFreePort finds an available TCP port on localhost
@@ -39,48 +195,119 @@ func findFreePort() (int, error) {
 
 // NewDelveDebuggerWithArgs launches a Delve headless server for the target with the given command line arguments and connects via RPC
 func NewDelveDebuggerWithArgs(targetPath string, args []string) (*DelveDebugger, error) {
-	// Convert to absolute path
-	absPath, err := filepath.Abs(targetPath)
+	return NewDelveDebuggerWithOptions(targetPath, args, DefaultDelveOptions())
+}
+
+// NewDelveDebuggerWithOptions launches a Delve headless server for the target
+// with the given command line arguments and options, and connects via RPC
+func NewDelveDebuggerWithOptions(targetPath string, args []string, opts DelveOptions) (*DelveDebugger, error) {
+	dlvPath, _, err := findDelve()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get absolute path for target %s: %v", targetPath, err)
+		return nil, err
 	}
 
-	// Find an available port for Delve to listen on
-	port, err := findFreePort()
+	// Convert to absolute path
+	absPath, err := filepath.Abs(targetPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to find free port for delve: %v", err)
+		return nil, fmt.Errorf("failed to get absolute path for target %s: %v", targetPath, err)
 	}
-	dlvListenAddr := "localhost:" + strconv.Itoa(port)
 
 	// Construct the dlv exec command with args
-	// Ensure dlv executable is in PATH or provide full path
-	cmdArgs := []string{
+	dlvArgs := []string{
 		"exec", absPath,
 		"--headless",
-		"--listen=" + dlvListenAddr,
 		"--api-version=2",
 		"--accept-multiclient",
+	}
+	programArgs := []string{
 		"--",     // Separator between dlv args and program args
 		"-debug", // Ensure debug functions are called
 	}
 
 	// Only add the '--' separator if we have args to pass
 	if len(args) > 0 {
-		cmdArgs = append(cmdArgs, "--")
-		cmdArgs = append(cmdArgs, args...)
+		programArgs = append(programArgs, "--")
+		programArgs = append(programArgs, args...)
+	}
+
+	dlvCmd, client, dlvListenAddr, err := startDelve(dlvPath, dlvArgs, programArgs)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("Started Delve headless server for %s on %s (PID: %d) with args: %v\n",
+		absPath, dlvListenAddr, dlvCmd.Process.Pid, args)
+	fmt.Printf("Connected RPC client to Delve headless server at %s\n", dlvListenAddr)
+
+	return &DelveDebugger{
+		client:     client,
+		target:     absPath,
+		dlvCmd:     dlvCmd,
+		dlvListen:  dlvListenAddr,
+		loadConfig: DefaultLoadConfig(),
+		options:    opts,
+	}, nil
+}
+
+// NewDelveDebuggerAttach attaches a Delve headless server to the running
+// process pid and connects via RPC. The process is stopped while Delve is
+// attached; Close detaches and leaves it running.
+func NewDelveDebuggerAttach(pid int) (*DelveDebugger, error) {
+	return NewDelveDebuggerAttachWithOptions(pid, DefaultDelveOptions())
+}
+
+// NewDelveDebuggerAttachWithOptions attaches a Delve headless server to the
+// running process pid with the given options, and connects via RPC
+func NewDelveDebuggerAttachWithOptions(pid int, opts DelveOptions) (*DelveDebugger, error) {
+	dlvPath, _, err := findDelve()
+	if err != nil {
+		return nil, err
 	}
 
-	dlvCmd := exec.Command("dlv", cmdArgs...)
+	dlvArgs := []string{
+		"attach", strconv.Itoa(pid),
+		"--headless",
+		"--api-version=2",
+		"--accept-multiclient",
+	}
+	dlvCmd, client, dlvListenAddr, err := startDelve(dlvPath, dlvArgs, nil)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("Attached Delve headless server to process %d on %s (PID: %d)\n",
+		pid, dlvListenAddr, dlvCmd.Process.Pid)
+
+	return &DelveDebugger{
+		client:     client,
+		target:     "pid " + strconv.Itoa(pid),
+		dlvCmd:     dlvCmd,
+		dlvListen:  dlvListenAddr,
+		attached:   true,
+		loadConfig: DefaultLoadConfig(),
+		options:    opts,
+	}, nil
+}
+
+// startDelve starts dlv with dlvArgs, a free address to listen on and
+// programArgs, and connects an RPC client to it
+func startDelve(dlvPath string, dlvArgs, programArgs []string) (*exec.Cmd, *rpc2.RPCClient, string, error) {
+	// Find an available port for Delve to listen on
+	port, err := findFreePort()
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("failed to find free port for delve: %v", err)
+	}
+	dlvListenAddr := "localhost:" + strconv.Itoa(port)
+
+	cmdArgs := append(append(dlvArgs, "--listen="+dlvListenAddr), programArgs...)
+	dlvCmd := exec.Command(dlvPath, cmdArgs...)
 
 	// Platform-specific process attributes are set in setupProcAttr function
 	setupProcAttr(dlvCmd)
 
 	// Start the Delve headless server
 	if err := dlvCmd.Start(); err != nil {
-		return nil, fmt.Errorf("failed to start delve process: %v", err)
+		return nil, nil, "", fmt.Errorf("failed to start delve process: %v", err)
 	}
-	fmt.Printf("Started Delve headless server for %s on %s (PID: %d) with args: %v\n",
-		absPath, dlvListenAddr, dlvCmd.Process.Pid, args)
+	RegisterProcess(dlvCmd.Process.Pid)
 
 	// Wait a moment for the server to initialize - longer time for testing
 	time.Sleep(1000 * time.Millisecond)
@@ -91,19 +318,11 @@ func NewDelveDebuggerWithArgs(targetPath string, args []string) (*DelveDebugger,
 	// Simple connection check
 	if _, err := client.GetState(); err != nil {
 		// If connection fails, attempt to kill the dlv process we started
-		_ = dlvCmd.Process.Kill()
+		_ = KillProcess(dlvCmd.Process.Pid)
 		_, _ = dlvCmd.Process.Wait() // Wait to clean up zombie process
-		return nil, fmt.Errorf("failed to connect RPC client to delve server at %s: %v", dlvListenAddr, err)
+		return nil, nil, "", fmt.Errorf("failed to connect RPC client to delve server at %s: %v", dlvListenAddr, err)
 	}
-
-	fmt.Printf("Connected RPC client to Delve headless server at %s\n", dlvListenAddr)
-
-	return &DelveDebugger{
-		client:    client,
-		target:    absPath,
-		dlvCmd:    dlvCmd,
-		dlvListen: dlvListenAddr,
-	}, nil
+	return dlvCmd, client, dlvListenAddr, nil
 }
 
 // NewDelveDebugger launches a Delve headless server for the target and connects via RPC
@@ -111,6 +330,18 @@ func NewDelveDebugger(targetPath string) (*DelveDebugger, error) {
 	return NewDelveDebuggerWithArgs(targetPath, nil)
 }
 
+// NewDelveDebuggerWithClient wraps an existing connection to Delve for the
+// target, such as a scripted client in tests. No dlv process is started, and
+// Close only disconnects the client.
+func NewDelveDebuggerWithClient(targetPath string, client delveClient) *DelveDebugger {
+	return &DelveDebugger{
+		client:     client,
+		target:     targetPath,
+		loadConfig: DefaultLoadConfig(),
+		options:    DefaultDelveOptions(),
+	}
+}
+
 // SetBreakpoint sets a breakpoint at the specified location using RPC
 func (d *DelveDebugger) SetBreakpoint(file string, line int) (*api.Breakpoint, error) {
 	// Normalize file path (for Windows compatibility)
@@ -263,6 +494,12 @@ func (d *DelveDebugger) SetFunctionBreakpoint(funcName string) (*api.Breakpoint,
 	return nil, fmt.Errorf("could not set breakpoint at function %s: %v", funcName, err)
 }
 
+// ListFunctions returns the names of the target's functions matching the
+// regular expression filter
+func (d *DelveDebugger) ListFunctions(filter string) ([]string, error) {
+	return d.client.ListFunctions(filter, 0)
+}
+
 // SetConditionalBreakpoint sets a breakpoint with a condition
 func (d *DelveDebugger) SetConditionalBreakpoint(file string, line int, condition string) (*api.Breakpoint, error) {
 	// Normalize file path
@@ -309,10 +546,69 @@ func (d *DelveDebugger) ClearBreakpoint(id int) error {
 	return err
 }
 
+// ListBreakpoints returns the breakpoints set in the debugged process using RPC
+func (d *DelveDebugger) ListBreakpoints() ([]*api.Breakpoint, error) {
+	return d.client.ListBreakpoints(false)
+}
+
+// SetBreakpointEnabled enables or disables a breakpoint by its ID using RPC
+func (d *DelveDebugger) SetBreakpointEnabled(id int, enabled bool) error {
+	bp, err := d.client.GetBreakpoint(id)
+	if err != nil {
+		return err
+	}
+	bp.Disabled = !enabled // Delve uses Disabled rather than Enabled
+	return d.client.AmendBreakpoint(bp)
+}
+
+// requestContext returns a context bounded by the configured timeout
+func (d *DelveDebugger) requestContext() (context.Context, context.CancelFunc) {
+	if d.options.Timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), d.options.Timeout)
+}
+
+// await runs call, which makes requests to Delve, and gives up on it when
+// ctx is done. The abandoned call is left to finish in the background. With
+// halt, the target is also asked to stop, so a continue that never returns
+// doesn't leave it running.
+func (d *DelveDebugger) await(ctx context.Context, halt bool, call func() error) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- call()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		if halt {
+			client := d.client
+			go func() {
+				_, _ = client.Halt()
+			}()
+		}
+		return fmt.Errorf("delve request abandoned: %w", ctx.Err())
+	}
+}
+
 // Continue resumes execution until the next breakpoint using RPC
 func (d *DelveDebugger) Continue() (*api.DebuggerState, error) {
-	stateChan := d.client.Continue()
-	state := <-stateChan
+	ctx, cancel := d.requestContext()
+	defer cancel()
+	return d.ContinueCtx(ctx)
+}
+
+// ContinueCtx is like Continue, but gives up when ctx is done
+func (d *DelveDebugger) ContinueCtx(ctx context.Context) (*api.DebuggerState, error) {
+	var state *api.DebuggerState
+	if err := d.await(ctx, true, func() error {
+		state = <-d.client.Continue()
+		return nil
+	}); err != nil {
+		return nil, err
+	}
 	if state.Err != nil {
 		return nil, state.Err
 	}
@@ -321,9 +617,20 @@ func (d *DelveDebugger) Continue() (*api.DebuggerState, error) {
 
 // Step executes a single instruction using RPC
 func (d *DelveDebugger) Step() (*api.DebuggerState, error) {
-	state, err := d.client.Next()
-	if err != nil {
-		return nil, fmt.Errorf("step command failed: %v", err)
+	ctx, cancel := d.requestContext()
+	defer cancel()
+	return d.StepCtx(ctx)
+}
+
+// StepCtx is like Step, but gives up when ctx is done
+func (d *DelveDebugger) StepCtx(ctx context.Context) (*api.DebuggerState, error) {
+	var state *api.DebuggerState
+	if err := d.await(ctx, true, func() error {
+		var err error
+		state, err = d.client.Next()
+		return err
+	}); err != nil {
+		return nil, fmt.Errorf("step command failed: %w", err)
 	}
 	if state.Err != nil {
 		return nil, state.Err
@@ -333,9 +640,20 @@ func (d *DelveDebugger) Step() (*api.DebuggerState, error) {
 
 // StepOut steps out of the current function using RPC
 func (d *DelveDebugger) StepOut() (*api.DebuggerState, error) {
-	state, err := d.client.StepOut()
-	if err != nil {
-		return nil, fmt.Errorf("step out command failed: %v", err)
+	ctx, cancel := d.requestContext()
+	defer cancel()
+	return d.StepOutCtx(ctx)
+}
+
+// StepOutCtx is like StepOut, but gives up when ctx is done
+func (d *DelveDebugger) StepOutCtx(ctx context.Context) (*api.DebuggerState, error) {
+	var state *api.DebuggerState
+	if err := d.await(ctx, true, func() error {
+		var err error
+		state, err = d.client.StepOut()
+		return err
+	}); err != nil {
+		return nil, fmt.Errorf("step out command failed: %w", err)
 	}
 	if state.Err != nil {
 		return nil, state.Err
@@ -343,8 +661,53 @@ func (d *DelveDebugger) StepOut() (*api.DebuggerState, error) {
 	return state, nil
 }
 
+// Interrupt stops the running target
+func (d *DelveDebugger) Interrupt() (*api.DebuggerState, error) {
+	ctx, cancel := d.requestContext()
+	defer cancel()
+
+	var state *api.DebuggerState
+	if err := d.await(ctx, false, func() error {
+		var err error
+		state, err = d.client.Halt()
+		return err
+	}); err != nil {
+		return nil, fmt.Errorf("halt command failed: %w", err)
+	}
+	return state, nil
+}
+
+// SetLoadConfig sets the limits used when loading variable values, e.g. to
+// print strings or slices longer than the defaults allow
+func (d *DelveDebugger) SetLoadConfig(cfg api.LoadConfig) {
+	d.loadConfig = cfg
+}
+
+// LoadConfig returns the limits used when loading variable values
+func (d *DelveDebugger) LoadConfig() api.LoadConfig {
+	return d.loadConfig
+}
+
 // GetVariable retrieves the value of a variable using RPC
 func (d *DelveDebugger) GetVariable(name string) (*api.Variable, error) {
+	ctx, cancel := d.requestContext()
+	defer cancel()
+	return d.GetVariableCtx(ctx, name)
+}
+
+// GetVariableCtx is like GetVariable, but gives up when ctx is done
+func (d *DelveDebugger) GetVariableCtx(ctx context.Context, name string) (*api.Variable, error) {
+	var v *api.Variable
+	err := d.await(ctx, false, func() error {
+		var err error
+		v, err = d.getVariable(name)
+		return err
+	})
+	return v, err
+}
+
+// getVariable tries each way of finding a variable in the current frame
+func (d *DelveDebugger) getVariable(name string) (*api.Variable, error) {
 	state, err := d.client.GetState()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get state: %v", err)
@@ -359,14 +722,7 @@ func (d *DelveDebugger) GetVariable(name string) (*api.Variable, error) {
 		Frame:       0,
 	}
 
-	// Customize load config based on variable type detection
-	cfg := api.LoadConfig{
-		FollowPointers:     true,
-		MaxVariableRecurse: 1,
-		MaxStringLen:       64,
-		MaxArrayValues:     64,
-		MaxStructFields:    -1,
-	}
+	cfg := d.loadConfig
 
 	// Try multiple approaches to find the variable
 
@@ -416,6 +772,32 @@ func (d *DelveDebugger) GetVariable(name string) (*api.Variable, error) {
 	return nil, fmt.Errorf("failed to evaluate variable '%s': could not find symbol value for %s", name, name)
 }
 
+// SetVariable assigns value to the variable or expression name in the current
+// goroutine and frame using RPC
+func (d *DelveDebugger) SetVariable(name, value string) error {
+	if d.client == nil {
+		return fmt.Errorf("no live debugging session")
+	}
+
+	state, err := d.client.GetState()
+	if err != nil {
+		return fmt.Errorf("failed to get state: %v", err)
+	}
+	if state.CurrentThread == nil {
+		return fmt.Errorf("no current thread available")
+	}
+
+	scope := api.EvalScope{
+		GoroutineID: state.CurrentThread.GoroutineID,
+		Frame:       0,
+	}
+
+	if err := d.client.SetVariable(scope, name, value); err != nil {
+		return fmt.Errorf("cannot assign %s to '%s': %v", value, name, err)
+	}
+	return nil
+}
+
 // loadComplexVariable provides enhanced loading for complex variable types
 func (d *DelveDebugger) loadComplexVariable(v *api.Variable, scope api.EvalScope) (*api.Variable, error) {
 	// Already loaded simple types can be returned as-is
@@ -427,64 +809,20 @@ func (d *DelveDebugger) loadComplexVariable(v *api.Variable, scope api.EvalScope
 		return v, nil
 	}
 
-	// Create type-specific loading configurations
-	var cfg api.LoadConfig
+	// Adjust the configured limits to the type
+	cfg := d.loadConfig
 
 	switch v.Kind {
 	case reflect.Struct:
 		// For structs, load all fields
-		cfg = api.LoadConfig{
-			FollowPointers:     true,
-			MaxVariableRecurse: 1,
-			MaxStringLen:       64,
-			MaxArrayValues:     0,  // Don't load arrays within structs by default
-			MaxStructFields:    -1, // Load all struct fields
-		}
+		cfg.MaxArrayValues = 0 // Don't load arrays within structs by default
+		cfg.MaxStructFields = -1
 	case reflect.Slice, reflect.Array:
-		// For slices/arrays, load more elements but limit recursion
-		cfg = api.LoadConfig{
-			FollowPointers:     true,
-			MaxVariableRecurse: 0, // Don't follow pointers in array elements by default
-			MaxStringLen:       32,
-			MaxArrayValues:     100, // Show more array elements
-			MaxStructFields:    -1,
-		}
-	case reflect.Map:
-		// For maps, load more key/values
-		cfg = api.LoadConfig{
-			FollowPointers:     true,
-			MaxVariableRecurse: 1,
-			MaxStringLen:       32,
-			MaxArrayValues:     100, // More map entries
-			MaxStructFields:    -1,
-		}
+		// For slices/arrays, limit recursion
+		cfg.MaxVariableRecurse = 0 // Don't follow pointers in array elements by default
 	case reflect.Ptr, reflect.Interface:
 		// For pointers, increase recursion
-		cfg = api.LoadConfig{
-			FollowPointers:     true,
-			MaxVariableRecurse: 2, // Follow deeper
-			MaxStringLen:       64,
-			MaxArrayValues:     64,
-			MaxStructFields:    -1,
-		}
-	case reflect.Chan:
-		// For channels, try to examine buffer and structure
-		cfg = api.LoadConfig{
-			FollowPointers:     true,
-			MaxVariableRecurse: 1,
-			MaxStringLen:       64,
-			MaxArrayValues:     32, // Show buffered channel elements
-			MaxStructFields:    -1,
-		}
-	default:
-		// Default config for other types
-		cfg = api.LoadConfig{
-			FollowPointers:     true,
-			MaxVariableRecurse: 1,
-			MaxStringLen:       64,
-			MaxArrayValues:     64,
-			MaxStructFields:    -1,
-		}
+		cfg.MaxVariableRecurse = 2 // Follow deeper
 	}
 
 	// Re-evaluate with the type-specific config
@@ -493,15 +831,32 @@ func (d *DelveDebugger) loadComplexVariable(v *api.Variable, scope api.EvalScope
 
 // ListGoroutines returns all active goroutines using RPC
 func (d *DelveDebugger) ListGoroutines() ([]*api.Goroutine, error) {
-	goroutines, _, err := d.client.ListGoroutines(0, 0)
-	if err != nil {
+	ctx, cancel := d.requestContext()
+	defer cancel()
+	return d.ListGoroutinesCtx(ctx)
+}
+
+// ListGoroutinesCtx is like ListGoroutines, but gives up when ctx is done
+func (d *DelveDebugger) ListGoroutinesCtx(ctx context.Context) ([]*api.Goroutine, error) {
+	var goroutines []*api.Goroutine
+	if err := d.await(ctx, false, func() error {
+		var err error
+		goroutines, _, err = d.client.ListGoroutines(0, 0)
+		return err
+	}); err != nil {
 		return nil, err
 	}
 	return goroutines, nil
 }
 
-// Close terminates the connection and the Delve process
+// Close terminates the connection and the Delve process. A process Delve
+// was attached to is detached from and left running; one it launched is
+// killed.
 func (d *DelveDebugger) Close() error {
+	if d.attached {
+		return d.detach()
+	}
+
 	var closeErr error
 	if d.client != nil {
 		// Detach from the process (kill=false is often problematic here, let Kill handle it)
@@ -514,12 +869,10 @@ func (d *DelveDebugger) Close() error {
 	}
 	if d.dlvCmd != nil && d.dlvCmd.Process != nil {
 		fmt.Printf("Attempting to terminate Delve process (PID: %d)...\n", d.dlvCmd.Process.Pid)
-		if err := d.dlvCmd.Process.Kill(); err != nil {
-			// If already exited, it's not an error we need to report upwards usually.
-			if err.Error() != "os: process already finished" {
-				fmt.Printf("Error killing delve process %d: %v\n", d.dlvCmd.Process.Pid, err)
-				closeErr = fmt.Errorf("failed to kill delve process: %v", err)
-			}
+		// Kills the debugged program too, and an already exited process isn't an error
+		if err := KillProcess(d.dlvCmd.Process.Pid); err != nil {
+			fmt.Printf("Error killing delve process %d: %v\n", d.dlvCmd.Process.Pid, err)
+			closeErr = fmt.Errorf("failed to kill delve process: %v", err)
 		}
 		// Wait for the process to release resources
 		_, waitErr := d.dlvCmd.Process.Wait()
@@ -536,6 +889,39 @@ func (d *DelveDebugger) Close() error {
 	return closeErr
 }
 
+// detach detaches Delve from the attached process, resuming it, and waits
+// for dlv to exit
+func (d *DelveDebugger) detach() error {
+	var closeErr error
+	if d.client != nil {
+		if err := d.client.Detach(false); err != nil {
+			fmt.Printf("Error detaching Delve: %v\n", err)
+			closeErr = fmt.Errorf("failed to detach delve: %v", err)
+		}
+		d.client = nil
+	}
+	if d.dlvCmd != nil && d.dlvCmd.Process != nil {
+		// dlv exits once detached; only kill it if it hangs, which leaves
+		// the attached process alone since it isn't in dlv's process group
+		exited := make(chan struct{})
+		go func() {
+			_, _ = d.dlvCmd.Process.Wait()
+			close(exited)
+		}()
+		select {
+		case <-exited:
+		case <-time.After(5 * time.Second):
+			fmt.Printf("Delve process (PID: %d) did not exit after detaching, killing it\n", d.dlvCmd.Process.Pid)
+			if err := KillProcess(d.dlvCmd.Process.Pid); err != nil && closeErr == nil {
+				closeErr = fmt.Errorf("failed to kill delve process: %v", err)
+			}
+			<-exited
+		}
+		d.dlvCmd = nil
+	}
+	return closeErr
+}
+
 // Helper to check for specific Wait error on Windows
 func isWaitAlreadyExited(err error) bool {
 	if e, ok := err.(*exec.ExitError); ok {
@@ -567,14 +953,7 @@ func (d *DelveDebugger) SetWatchpoint(expr string, readFlag, writeFlag bool) (*a
 		scope.GoroutineID = state.CurrentThread.GoroutineID
 	}
 
-	// Standard load config
-	cfg := api.LoadConfig{
-		FollowPointers:     true,
-		MaxVariableRecurse: 1,
-		MaxStringLen:       64,
-		MaxArrayValues:     64,
-		MaxStructFields:    -1,
-	}
+	cfg := d.loadConfig
 
 	// Try to evaluate the expression to get the address
 	v, err := d.client.EvalVariable(scope, expr, cfg)
@@ -0,0 +1,214 @@
+package debugger
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-delve/delve/service/api"
+	"github.com/go-delve/delve/service/rpc2"
+	"github.com/willibrandon/ChronoGo/pkg/replay"
+	"github.com/willibrandon/ChronoGo/pkg/testutil"
+)
+
+func TestDelveNotInstalled(t *testing.T) {
+	t.Setenv("PATH", "")
+
+	if _, err := DelveAvailable(); !errors.Is(err, ErrDelveNotInstalled) {
+		t.Fatalf("Expected dlv to be unavailable with an empty PATH, got %v", err)
+	}
+
+	dbg, err := NewDelveDebugger("./testdata/program")
+	if !errors.Is(err, ErrDelveNotInstalled) {
+		t.Fatalf("Expected ErrDelveNotInstalled, got %v", err)
+	}
+	if dbg != nil {
+		t.Error("Expected no debugger when dlv is missing")
+	}
+}
+
+// fakeDlv puts a dlv binary in a fresh PATH whose 'dlv version' prints
+// output, and returns how many times it was run
+func fakeDlv(t *testing.T, output string) *int {
+	t.Helper()
+	dir := t.TempDir()
+	name := "dlv"
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), nil, 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+
+	runs := new(int)
+	original := runDlvVersion
+	runDlvVersion = func(string) ([]byte, error) {
+		*runs++
+		return []byte(output), nil
+	}
+	t.Cleanup(func() { runDlvVersion = original })
+	return runs
+}
+
+func TestDelveAvailableVersion(t *testing.T) {
+	runs := fakeDlv(t, "Delve Debugger\nVersion: 1.23.1\nBuild: $Id: 2eba762d75437d380e48fc42213853f13aa2904d $\n")
+	for i := 0; i < 2; i++ {
+		version, err := DelveAvailable()
+		if err != nil || version != "1.23.1" {
+			t.Fatalf("DelveAvailable() = %q, %v, want 1.23.1", version, err)
+		}
+	}
+	if *runs != 1 {
+		t.Errorf("Expected dlv version to run once, ran %d times", *runs)
+	}
+}
+
+func TestDelveTooOld(t *testing.T) {
+	fakeDlv(t, "Delve Debugger\nVersion: 1.20.2\n")
+
+	version, err := DelveAvailable()
+	if !errors.Is(err, ErrDelveUnsupported) || version != "1.20.2" {
+		t.Fatalf("DelveAvailable() = %q, %v, want ErrDelveUnsupported", version, err)
+	}
+	if _, err := NewDelveDebugger("./testdata/program"); !errors.Is(err, ErrDelveUnsupported) {
+		t.Errorf("Expected NewDelveDebugger to refuse old dlv, got %v", err)
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.21.0", "1.21.0", 0},
+		{"1.21", "1.21.0", 0},
+		{"1.9.1", "1.21.0", -1},
+		{"1.22.0", "1.21.9", 1},
+		{"2.0.0", "1.21.0", 1},
+	}
+	for _, tt := range tests {
+		if got := compareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestCLIWithoutDelve(t *testing.T) {
+	t.Setenv("PATH", "")
+	cli := NewCLI(replay.NewBasicReplayer())
+
+	output := captureOutput(t, func() { cli.printHelp() })
+	if strings.Contains(output, "print (p)") || !strings.Contains(output, "install delve >= 1.21") {
+		t.Errorf("Expected Delve commands to be hidden with a note, got %q", output)
+	}
+	for _, command := range []string{"print x", "bp main.go:10", "display x"} {
+		output := captureOutput(t, func() { cli.handleCommand(command) })
+		want := strings.Fields(command)[0] + " needs Delve: install delve >= 1.21 to enable live debugging\n"
+		if output != want {
+			t.Errorf("%s: expected %q, got %q", command, want, output)
+		}
+	}
+}
+
+// wedgedServer answers the API version handshake and then never responds
+type wedgedServer struct {
+	release chan struct{}
+}
+
+func (s *wedgedServer) SetApiVersion(args api.SetAPIVersionIn, out *api.SetAPIVersionOut) error {
+	return nil
+}
+
+func (s *wedgedServer) Command(args api.DebuggerCommand, out *rpc2.CommandOut) error {
+	<-s.release
+	return nil
+}
+
+func (s *wedgedServer) ListGoroutines(args rpc2.ListGoroutinesIn, out *rpc2.ListGoroutinesOut) error {
+	<-s.release
+	return nil
+}
+
+func TestDelveRequestTimeout(t *testing.T) {
+	server := &wedgedServer{release: make(chan struct{})}
+	defer close(server.release)
+
+	rpcServer := rpc.NewServer()
+	if err := rpcServer.RegisterName("RPCServer", server); err != nil {
+		t.Fatalf("Failed to register mock server: %v", err)
+	}
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go rpcServer.ServeCodec(jsonrpc.NewServerCodec(conn))
+		}
+	}()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to connect to mock server: %v", err)
+	}
+	dbg := &DelveDebugger{
+		client:     rpc2.NewClientFromConn(conn),
+		loadConfig: DefaultLoadConfig(),
+		options:    DelveOptions{Timeout: 50 * time.Millisecond},
+	}
+	defer dbg.client.Disconnect(false)
+
+	start := time.Now()
+	if _, err := dbg.Continue(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected Continue to time out, got %v", err)
+	}
+	if _, err := dbg.Step(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected Step to time out, got %v", err)
+	}
+	if _, err := dbg.ListGoroutines(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected ListGoroutines to time out, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the timeouts to fire promptly, took %v", elapsed)
+	}
+
+	// A cancelled context gives up without waiting for the timeout
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := dbg.GetVariableCtx(ctx, "x"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected GetVariableCtx to be cancelled, got %v", err)
+	}
+}
+
+func TestCloseDetachesAttached(t *testing.T) {
+	client := testutil.NewFakeDelveClient()
+	dbg := &DelveDebugger{client: client, attached: true, loadConfig: DefaultLoadConfig()}
+	if err := dbg.Close(); err != nil {
+		t.Fatalf("Failed to close: %v", err)
+	}
+	if got := strings.Join(client.Calls, ","); got != "Detach" {
+		t.Errorf("Expected an attached debugger to detach, got calls %s", got)
+	}
+
+	client = testutil.NewFakeDelveClient()
+	if err := NewDelveDebuggerWithClient("prog", client).Close(); err != nil {
+		t.Fatalf("Failed to close: %v", err)
+	}
+	if got := strings.Join(client.Calls, ","); got != "Disconnect" {
+		t.Errorf("Expected a launched debugger to disconnect, got calls %s", got)
+	}
+}
@@ -5,10 +5,38 @@ package debugger
 
 import (
 	"os/exec"
+	"syscall"
 )
 
 // setupProcAttr configures platform-specific process attributes.
-// On non-Windows platforms, this is a no-op.
+// On Unix-like systems, dlv is started in its own process group so it can
+// be killed together with the program it debugs.
 func setupProcAttr(cmd *exec.Cmd) {
-	// No special attributes needed for Unix-like systems
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// processAlive reports whether a process with the given PID is running
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+// processGroupAlive reports whether any process is left in the process
+// group led by pid, which outlives its leader when dlv exits before the
+// program it was debugging
+func processGroupAlive(pid int) bool {
+	err := syscall.Kill(-pid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+// killProcessTree kills the process group led by pid, or just the process
+// if it doesn't lead a group
+func killProcessTree(pid int) error {
+	if err := syscall.Kill(-pid, syscall.SIGKILL); err == nil {
+		return nil
+	}
+	if err := syscall.Kill(pid, syscall.SIGKILL); err != nil && err != syscall.ESRCH {
+		return err
+	}
+	return nil
 }
@@ -0,0 +1,96 @@
+//go:build !windows
+// +build !windows
+
+package debugger
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestHelperProcessGroup stands in for a dlv process that starts the
+// program it debugs as a child, printing the child's PID
+func TestHelperProcessGroup(t *testing.T) {
+	if os.Getenv("CHRONOGO_HELPER_GROUP") == "" {
+		return
+	}
+	child := exec.Command(os.Args[0], "-test.run=^TestHelperProcess$")
+	child.Env = append(os.Environ(), "CHRONOGO_HELPER_PROCESS=1", "CHRONOGO_HELPER_GROUP=")
+	if err := child.Start(); err != nil {
+		os.Exit(1)
+	}
+	fmt.Println(child.Process.Pid)
+	if os.Getenv("CHRONOGO_HELPER_GROUP") == "exit" {
+		os.Exit(0)
+	}
+	time.Sleep(time.Minute)
+	os.Exit(0)
+}
+
+// processGone reports whether pid exits, or is left a zombie, within a few seconds
+func processGone(pid int) bool {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if !processAlive(pid) {
+			return true
+		}
+		stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+		if err == nil {
+			// The state follows the parenthesized command name
+			if fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:])); len(fields) > 0 && fields[0] == "Z" {
+				return true
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return false
+}
+
+func TestCloseKillsProcessGroup(t *testing.T) {
+	for _, mode := range []string{"sleep", "exit"} {
+		t.Run(mode, func(t *testing.T) {
+			cmd := exec.Command(os.Args[0], "-test.run=^TestHelperProcessGroup$")
+			cmd.Env = append(os.Environ(), "CHRONOGO_HELPER_GROUP="+mode)
+			setupProcAttr(cmd)
+			stdout, err := cmd.StdoutPipe()
+			if err != nil {
+				t.Fatalf("Failed to get stdout: %v", err)
+			}
+			if err := cmd.Start(); err != nil {
+				t.Fatalf("Failed to start helper process: %v", err)
+			}
+			line, err := bufio.NewReader(stdout).ReadString('\n')
+			if err != nil {
+				t.Fatalf("Failed to read child PID: %v", err)
+			}
+			child, err := strconv.Atoi(strings.TrimSpace(line))
+			if err != nil {
+				t.Fatalf("Unexpected child PID %q", line)
+			}
+			defer killProcessTree(child)
+
+			if mode == "sleep" {
+				dbg := &DelveDebugger{dlvCmd: cmd}
+				if err := dbg.Close(); err != nil {
+					t.Fatalf("Close failed: %v", err)
+				}
+			} else {
+				// The stand-in dlv is gone, as after a crash, and only its
+				// child is left in the group
+				_ = cmd.Wait()
+				if err := KillProcess(cmd.Process.Pid); err != nil {
+					t.Fatalf("KillProcess failed: %v", err)
+				}
+			}
+			if !processGone(child) {
+				t.Errorf("Expected the debugged program (PID %d) not to survive Close", child)
+			}
+		})
+	}
+}
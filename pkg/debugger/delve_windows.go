@@ -4,12 +4,45 @@
 package debugger
 
 import (
+	"fmt"
 	"os/exec"
 	"syscall"
 )
 
+// stillActive is the exit code GetExitCodeProcess reports for a running process
+const stillActive = 259
+
 // setupProcAttr configures platform-specific process attributes.
 // On Windows, this prevents Delve from creating a console window.
 func setupProcAttr(cmd *exec.Cmd) {
 	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
 }
+
+// processAlive reports whether a process with the given PID is running
+func processAlive(pid int) bool {
+	h, err := syscall.OpenProcess(syscall.PROCESS_QUERY_INFORMATION, false, uint32(pid))
+	if err != nil {
+		return false
+	}
+	defer syscall.CloseHandle(h)
+	var code uint32
+	if err := syscall.GetExitCodeProcess(h, &code); err != nil {
+		return false
+	}
+	return code == stillActive
+}
+
+// processGroupAlive reports whether the process is running; Windows has no
+// process groups to outlive it
+func processGroupAlive(pid int) bool {
+	return processAlive(pid)
+}
+
+// killProcessTree kills the process and its child processes
+func killProcessTree(pid int) error {
+	out, err := exec.Command("taskkill", "/F", "/T", "/PID", fmt.Sprintf("%d", pid)).CombinedOutput()
+	if err != nil && processAlive(pid) {
+		return fmt.Errorf("%v: %s", err, out)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package debugger
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// displayExpr is an expression evaluated in the live process at every stop,
+// like gdb's display. Unlike a watchpoint it needs no hardware slot, so any
+// number can be kept, at the cost of only noticing changes at stops.
+type displayExpr struct {
+	id    int
+	expr  string
+	value string // Value at the last stop, or the error evaluating it
+}
+
+// handleDisplay adds an expression to evaluate at every stop and prints its
+// value, or lists the expressions without one
+func (c *CLI) handleDisplay(expr string) {
+	if c.debugger == nil {
+		fmt.Println("Delve integration not enabled")
+		return
+	}
+	if expr == "" {
+		if len(c.displays) == 0 {
+			fmt.Println("No display expressions")
+			return
+		}
+		for _, d := range c.displays {
+			fmt.Printf("%d: %s = %s\n", d.id, d.expr, d.value)
+		}
+		return
+	}
+
+	c.nextDisplay++
+	d := &displayExpr{id: c.nextDisplay, expr: expr, value: c.evalDisplay(expr)}
+	c.displays = append(c.displays, d)
+	fmt.Printf("%d: %s = %s\n", d.id, d.expr, d.value)
+}
+
+// handleUndisplay removes a display expression by number
+func (c *CLI) handleUndisplay(args []string) {
+	if len(args) != 1 {
+		fmt.Println("Usage: undisplay <n>")
+		return
+	}
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		fmt.Printf("Invalid display number: %s\n", args[0])
+		return
+	}
+	for i, d := range c.displays {
+		if d.id == id {
+			c.displays = append(c.displays[:i], c.displays[i+1:]...)
+			fmt.Printf("Removed display %d: %s\n", id, d.expr)
+			return
+		}
+	}
+	fmt.Printf("No display %d\n", id)
+}
+
+// showDisplays re-evaluates the display expressions after the live process
+// stopped, printing those whose value changed since the last stop
+func (c *CLI) showDisplays() {
+	if c.debugger == nil {
+		return
+	}
+	for _, d := range c.displays {
+		value := c.evalDisplay(d.expr)
+		if value == d.value {
+			continue
+		}
+		fmt.Printf("%d: %s = %s -> %s\n", d.id, d.expr, d.value, value)
+		d.value = value
+	}
+}
+
+// evalDisplay evaluates an expression in the current goroutine and frame,
+// returning its value or the error as a placeholder
+func (c *CLI) evalDisplay(expr string) string {
+	v, err := c.debugger.GetVariable(expr)
+	if err != nil {
+		return fmt.Sprintf("<error: %v>", err)
+	}
+	return PrettyValue(v.Type, variableValue(v))
+}
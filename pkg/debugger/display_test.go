@@ -0,0 +1,55 @@
+package debugger
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-delve/delve/service/api"
+)
+
+func TestDisplayExpressions(t *testing.T) {
+	cli, client := newFakeDelveCLI(t)
+	client.Variables["x"] = &api.Variable{Name: "x", Type: "int", Kind: reflect.Int, Value: "1"}
+	client.Variables["len(items)"] = &api.Variable{Name: "len(items)", Type: "int", Kind: reflect.Int, Value: "0"}
+
+	output := captureOutput(t, func() {
+		cli.handleCommand("display x")
+		cli.handleCommand("display len(items)")
+	})
+	if !strings.Contains(output, "1: x = 1\n") || !strings.Contains(output, "2: len(items) = 0\n") {
+		t.Errorf("Expected the values when added, got %q", output)
+	}
+
+	// Unchanged values aren't repeated
+	output = captureOutput(t, func() { cli.handleCommand("step") })
+	if strings.Contains(output, "x = ") {
+		t.Errorf("Expected no display output when nothing changed, got %q", output)
+	}
+
+	client.Variables["x"].Value = "2"
+	output = captureOutput(t, func() { cli.handleCommand("step") })
+	if !strings.Contains(output, "1: x = 1 -> 2\n") || strings.Contains(output, "len(items)") {
+		t.Errorf("Expected only x to be shown as changed, got %q", output)
+	}
+
+	// Expressions that stop evaluating are shown as errors, then removed
+	delete(client.Variables, "len(items)")
+	if _, err := client.CreateBreakpoint(&api.Breakpoint{File: "main.go", Line: 20}); err != nil {
+		t.Fatal(err)
+	}
+	output = captureOutput(t, func() { cli.handleCommand("continue") })
+	if !strings.Contains(output, "2: len(items) = 0 -> <error: ") {
+		t.Errorf("Expected the evaluation error, got %q", output)
+	}
+	output = captureOutput(t, func() {
+		cli.handleCommand("undisplay 2")
+		cli.handleCommand("display")
+	})
+	if !strings.Contains(output, "Removed display 2") || !strings.Contains(output, "1: x = 2\n") || strings.Contains(output, "2: len(items) =") {
+		t.Errorf("Expected only display 1 to be left, got %q", output)
+	}
+	if output := captureOutput(t, func() { cli.handleCommand("undisplay 7") }); !strings.Contains(output, "No display 7") {
+		t.Errorf("Expected an unknown display to be reported, got %q", output)
+	}
+}
@@ -0,0 +1,119 @@
+package debugger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+	"github.com/willibrandon/ChronoGo/pkg/replay"
+)
+
+// spawnEvents returns the indices of the ProcessSpawnEvents of the recording
+func (c *CLI) spawnEvents() []int {
+	var indices []int
+	for i, e := range c.replayer.Events() {
+		if e.Type == recorder.ProcessSpawnEvent {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
+
+// recordingDir returns the directory the recording being replayed is in,
+// where the recordings of its child processes are looked for when they
+// aren't where the children wrote them
+func (c *CLI) recordingDir() string {
+	if c.eventsFile == "" {
+		return "."
+	}
+	if info, err := os.Stat(c.eventsFile); err == nil && info.IsDir() {
+		return c.eventsFile
+	}
+	return filepath.Dir(c.eventsFile)
+}
+
+// handleFollowChild switches the replay to the recording of the child
+// process started by the nth spawn event, at the last event the child
+// recorded by the time of the current event, or lists the spawns
+func (c *CLI) handleFollowChild(args []string) {
+	spawns := c.spawnEvents()
+	events := c.replayer.Events()
+	if len(args) == 0 {
+		if len(spawns) == 0 {
+			fmt.Println("No child processes recorded")
+			return
+		}
+		for n, idx := range spawns {
+			fmt.Printf("%d: event %d: %s\n", n+1, c.recordingIndex(idx), events[idx].Details)
+		}
+		return
+	}
+	if len(args) != 1 {
+		fmt.Println("Usage: follow-child [spawn-index]")
+		return
+	}
+	if c.debugger != nil {
+		fmt.Println("Can't follow a child process while Delve is attached to the parent")
+		return
+	}
+
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 1 || n > len(spawns) {
+		fmt.Printf("Invalid spawn index: %s (%d spawns recorded)\n", args[0], len(spawns))
+		return
+	}
+	spawnEvent := events[spawns[n-1]]
+	spawn, ok := recorder.ParseProcessSpawn(spawnEvent.Details)
+	if !ok || spawn.EventsFile == "" {
+		fmt.Printf("Spawn %d didn't ask the child to record\n", n)
+		return
+	}
+	path := recorder.ChildRecording(spawn, c.recordingDir())
+	if path == "" {
+		fmt.Printf("No recording found for child pid %d at %s\n", spawn.PID, spawn.EventsFile)
+		return
+	}
+	childEvents, err := recorder.ReadEventsFile(path)
+	if err != nil {
+		printError("Error reading child recording: %v\n", err)
+		return
+	}
+	if len(childEvents) == 0 {
+		fmt.Printf("The recording of child pid %d is empty\n", spawn.PID)
+		return
+	}
+
+	// The child's last event by the time the parent reached the current
+	// event; the spawn itself if the replay is before it
+	at := spawnEvent.Timestamp
+	if idx := c.replayer.CurrentIndex(); idx >= 0 && idx < len(events) && events[idx].Timestamp.After(at) {
+		at = events[idx].Timestamp
+	}
+	replayer := replay.NewBasicReplayer()
+	if err := replayer.LoadEvents(childEvents); err != nil {
+		printError("Error loading child recording: %v\n", err)
+		return
+	}
+	target, err := GotoTime(replayer, at)
+	if err != nil {
+		target, err = 0, GotoIndex(replayer, 0)
+	}
+	if err != nil {
+		printError("Error jumping in child recording: %v\n", err)
+		return
+	}
+	c.replayer.Close()
+	c.replayer = replayer
+	c.printReplayedEvents()
+	c.SetEventsFile(path)
+	c.metadata = nil
+	c.segments = nil
+	c.tags = nil
+	c.locations = nil
+	c.locationsOf = nil
+
+	fmt.Printf("Following child pid %d: %s (%d events)\n", spawn.PID, path, len(childEvents))
+	fmt.Printf("At event %d: %s\n", target, c.formatEvent(target, replayer.Events()[target]))
+}
@@ -0,0 +1,67 @@
+package debugger
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+	"github.com/willibrandon/ChronoGo/pkg/replay"
+)
+
+func TestFollowChild(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Now().UTC()
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+
+	// A parent/child fixture pair; the child's recording has been moved
+	// next to the parent's
+	spawn := recorder.ProcessSpawn{PID: 4242, Command: "worker", EventsFile: "/elsewhere/worker.events"}
+	parent := []recorder.Event{
+		{ID: 1, Timestamp: at(0), Type: recorder.FuncEntry, FuncName: "main.main"},
+		{ID: 2, Timestamp: at(1), Type: recorder.ProcessSpawnEvent, FuncName: "main.main", Details: spawn.Details()},
+		{ID: 3, Timestamp: at(5), Type: recorder.StatementExecution, FuncName: "main.main", File: "main.go", Line: 9},
+		{ID: 4, Timestamp: at(10), Type: recorder.FuncExit, FuncName: "main.main"},
+	}
+	child := []recorder.Event{
+		{ID: 1, Timestamp: at(2), Type: recorder.FuncEntry, FuncName: "worker.main"},
+		{ID: 2, Timestamp: at(3), Type: recorder.FuncEntry, FuncName: "worker.run"},
+		{ID: 3, Timestamp: at(6), Type: recorder.FuncExit, FuncName: "worker.run"},
+	}
+	parentFile := filepath.Join(dir, "chronogo.events")
+	if err := recorder.WriteEventsFile(parentFile, parent, recorder.DefaultFileRecorderOptions()); err != nil {
+		t.Fatal(err)
+	}
+	if err := recorder.WriteEventsFile(filepath.Join(dir, "worker.events"), child, recorder.DefaultFileRecorderOptions()); err != nil {
+		t.Fatal(err)
+	}
+
+	replayer := replay.NewBasicReplayer()
+	if err := replayer.LoadEvents(parent); err != nil {
+		t.Fatalf("Failed to load events: %v", err)
+	}
+	cli := NewCLI(replayer)
+	cli.SetEventsFile(parentFile)
+
+	output := captureOutput(t, func() { cli.handleCommand("follow-child") })
+	if !strings.Contains(output, "1: event 1: Spawned pid 4242") {
+		t.Errorf("Expected the spawn to be listed, got %q", output)
+	}
+	if output := captureOutput(t, func() { cli.handleCommand("follow-child 2") }); !strings.Contains(output, "Invalid spawn index") {
+		t.Errorf("Expected an unknown spawn to be rejected, got %q", output)
+	}
+
+	// At the parent's statement the child had entered worker.run
+	captureOutput(t, func() { cli.replayer.ReplayToEventIndex(2) })
+	output = captureOutput(t, func() { cli.handleCommand("follow-child 1") })
+	if !strings.Contains(output, "Following child pid 4242") {
+		t.Errorf("Expected to follow the child, got %q", output)
+	}
+	if idx := cli.replayer.CurrentIndex(); idx != 1 || cli.replayer.Events()[idx].FuncName != "worker.run" {
+		t.Errorf("Expected the child at event 1, got %d", idx)
+	}
+	if cli.eventsFile != filepath.Join(dir, "worker.events") {
+		t.Errorf("Expected the child's recording to be replayed, got %s", cli.eventsFile)
+	}
+}
@@ -0,0 +1,126 @@
+package debugger
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+// EventFormatEnv names the environment variable holding the default event
+// format template
+const EventFormatEnv = "CHRONOGO_EVENT_FORMAT"
+
+// EventFormatData is what an event format template is executed with
+type EventFormatData struct {
+	recorder.Event
+	Index int    // Index of the event in the replay
+	Type  string // Name of the event type, e.g. FunctionEntry
+}
+
+// eventFormatter renders events with a user-supplied template
+type eventFormatter struct {
+	text  string
+	tmpl  *template.Template
+	start time.Time // Timestamp of the first event, for reltime
+}
+
+// newEventFormatter parses a format template. Besides the standard template
+// functions it offers trunc, which shortens text to n runes, reltime, which
+// shows a timestamp relative to the first event, and color, which wraps text
+// in an ANSI color when color output is enabled.
+func newEventFormatter(text string) (*eventFormatter, error) {
+	f := &eventFormatter{text: text}
+	funcs := template.FuncMap{
+		"trunc": func(n int, s string) string {
+			runes := []rune(s)
+			if n < 0 || len(runes) <= n {
+				return s
+			}
+			if n <= 3 {
+				return string(runes[:n])
+			}
+			return string(runes[:n-3]) + "..."
+		},
+		"reltime": func(t time.Time) string {
+			return "+" + t.Sub(f.start).String()
+		},
+		"color": func(name string, v interface{}) string {
+			return style(fmt.Sprint(v), name)
+		},
+	}
+
+	tmpl, err := template.New("event").Funcs(funcs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	f.tmpl = tmpl
+	return f, nil
+}
+
+// format renders the event at idx. Execution errors are shown in place of
+// the event, since the template may reference fields that don't exist.
+func (f *eventFormatter) format(idx int, event recorder.Event, start time.Time) string {
+	f.start = start
+	var b strings.Builder
+	data := EventFormatData{Event: event, Index: idx, Type: event.Type.String()}
+	if err := f.tmpl.Execute(&b, data); err != nil {
+		return fmt.Sprintf("<format error: %v>", err)
+	}
+	return b.String()
+}
+
+// SetEventFormat renders events with a text/template instead of the
+// built-in one-line format, e.g.
+//
+//	{{.Index}} {{.Type}} {{.FuncName}}:{{.Line}} {{.Details | trunc 80}}
+//
+// An empty format restores the built-in one. An invalid template is
+// rejected and the current format is kept.
+func (c *CLI) SetEventFormat(text string) error {
+	if text == "" {
+		c.formatter = nil
+		return nil
+	}
+	f, err := newEventFormatter(text)
+	if err != nil {
+		return err
+	}
+	c.formatter = f
+	return nil
+}
+
+// applyEnvEventFormat sets the event format from CHRONOGO_EVENT_FORMAT, if set
+func (c *CLI) applyEnvEventFormat() {
+	text := os.Getenv(EventFormatEnv)
+	if text == "" {
+		return
+	}
+	if err := c.SetEventFormat(text); err != nil {
+		fmt.Printf("Warning: Ignoring invalid %s: %v\n", EventFormatEnv, err)
+	}
+}
+
+// handleFormat shows, sets or resets the event format template
+func (c *CLI) handleFormat(text string) {
+	switch text {
+	case "":
+		if c.formatter == nil {
+			fmt.Println("Using the default event format")
+		} else {
+			fmt.Printf("Event format: %s\n", c.formatter.text)
+		}
+	case "default":
+		c.SetEventFormat("")
+		fmt.Println("Restored the default event format")
+	default:
+		if err := c.SetEventFormat(text); err != nil {
+			fmt.Printf("Invalid format, keeping the previous one: %v\n", err)
+			return
+		}
+		fmt.Printf("Event format set to: %s\n", text)
+	}
+}
@@ -0,0 +1,81 @@
+package debugger
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+	"github.com/willibrandon/ChronoGo/pkg/replay"
+)
+
+func TestEventFormat(t *testing.T) {
+	base := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	events := []recorder.Event{
+		{ID: 1, Timestamp: base, Type: recorder.FuncEntry, Details: "Entering main.handle", FuncName: "main.handle", File: "main.go", Line: 10},
+		{ID: 2, Timestamp: base.Add(1500 * time.Millisecond), Type: recorder.StatementExecution,
+			Details: "request body was much longer than anyone wanted to read", FuncName: "main.handle", File: "main.go", Line: 12},
+	}
+	replayer := replay.NewBasicReplayer()
+	if err := replayer.LoadEvents(events); err != nil {
+		t.Fatalf("Failed to load events: %v", err)
+	}
+	cli := NewCLI(replayer)
+	originalColor := colorEnabled
+	defer SetColor(originalColor)
+	SetColor(true)
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"", "[2026-01-02T03:04:06Z] Event 2: \033[34mStatementExecution\033[0m - request body was much longer than anyone wanted to read"},
+		{"{{.Index}} {{.Type}} {{.FuncName}}:{{.Line}} {{.Details | trunc 20}}",
+			"1 StatementExecution main.handle:12 request body was ..."},
+		{"{{reltime .Timestamp}} {{color \"red\" .Type}} {{color \"plaid\" .ID}}",
+			"+1.5s \033[31mStatementExecution\033[0m 2"},
+	}
+	for _, tt := range tests {
+		if err := cli.SetEventFormat(tt.format); err != nil {
+			t.Fatalf("Failed to set format %q: %v", tt.format, err)
+		}
+		if got := cli.formatEvent(1, replayer.Events()[1]); got != tt.want {
+			t.Errorf("Format %q:\ngot  %q\nwant %q", tt.format, got, tt.want)
+		}
+	}
+
+	// An invalid template keeps the previous one
+	captureOutput(t, func() { cli.handleCommand("format {{.Index}}  {{.Type}}") })
+	output := captureOutput(t, func() { cli.handleCommand("format {{.Index") })
+	if !strings.Contains(output, "Invalid format, keeping the previous one") {
+		t.Errorf("Expected the parse error, got:\n%s", output)
+	}
+	if got := cli.formatEvent(0, replayer.Events()[0]); got != "0  FunctionEntry" {
+		t.Errorf("Expected the previous format to be kept, got %q", got)
+	}
+
+	captureOutput(t, func() { cli.handleCommand("format default") })
+	if got := cli.formatEvent(0, replayer.Events()[0]); !strings.HasPrefix(got, "[2026-01-02T03:04:05Z] Event 1: \033[32mFunctionEntry") {
+		t.Errorf("Expected the default format, got %q", got)
+	}
+}
+
+func TestEventFormatEnv(t *testing.T) {
+	t.Setenv(EventFormatEnv, "{{.ID}}/{{.Type}}")
+	replayer := replay.NewBasicReplayer()
+	if err := replayer.LoadEvents([]recorder.Event{{ID: 7, Timestamp: time.Now(), Type: recorder.FuncExit}}); err != nil {
+		t.Fatalf("Failed to load events: %v", err)
+	}
+
+	cli := NewCLI(replayer)
+	if got := cli.formatEvent(0, replayer.Events()[0]); got != "7/FunctionExit" {
+		t.Errorf("Expected the format from %s, got %q", EventFormatEnv, got)
+	}
+
+	t.Setenv(EventFormatEnv, "{{.ID")
+	var invalid *CLI
+	output := captureOutput(t, func() { invalid = NewCLI(replayer) })
+	if !strings.Contains(output, "Warning: Ignoring invalid "+EventFormatEnv) || invalid.formatter != nil {
+		t.Errorf("Expected an invalid format to be ignored, got:\n%s", output)
+	}
+}
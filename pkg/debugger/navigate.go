@@ -0,0 +1,160 @@
+package debugger
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+	"github.com/willibrandon/ChronoGo/pkg/replay"
+)
+
+// The CLI's navigation commands and chrono.Session move through the
+// functions below, so that both step, jump and stop at breakpoints alike
+
+var (
+	// ErrEndOfRecording is returned when moving past the last event
+	ErrEndOfRecording = errors.New("end of recording")
+	// ErrStartOfRecording is returned when moving before the first event
+	ErrStartOfRecording = errors.New("start of recording")
+)
+
+// GotoIndex moves r to the event at idx
+func GotoIndex(r replay.Replayer, idx int) error {
+	if n := len(r.Events()); idx < 0 || idx >= n {
+		return fmt.Errorf("event index %d out of range [0, %d)", idx, n)
+	}
+	return r.ReplayToEventIndex(idx)
+}
+
+// GotoTime moves r to the last event recorded at or before t and returns
+// its index
+func GotoTime(r replay.Replayer, t time.Time) (int, error) {
+	target := -1
+	for i, e := range r.Events() {
+		if e.Timestamp.After(t) {
+			break
+		}
+		target = i
+	}
+	if target < 0 {
+		return -1, fmt.Errorf("no events recorded at or before %s", t.Format(time.RFC3339Nano))
+	}
+	return target, GotoIndex(r, target)
+}
+
+// StepForward moves r forward count events, stopping at the last event if
+// fewer are left, and returns how many it stepped. It returns
+// ErrEndOfRecording if r is already at the last event.
+func StepForward(r replay.Replayer, count int) (int, error) {
+	remaining := len(r.Events()) - 1 - r.CurrentIndex()
+	if remaining <= 0 {
+		return 0, ErrEndOfRecording
+	}
+	count = min(count, remaining)
+	for i := 0; i < count; i++ {
+		if err := r.ReplayToEventIndex(r.CurrentIndex() + 1); err != nil {
+			return i, err
+		}
+	}
+	return count, nil
+}
+
+// StepBackward moves r backward count events, stopping at the first event
+// if fewer are before the current one, and returns how many it stepped. It
+// returns ErrStartOfRecording if r is at or before the first event.
+func StepBackward(r replay.Replayer, count int) (int, error) {
+	idx := r.CurrentIndex()
+	if idx <= 0 {
+		return 0, ErrStartOfRecording
+	}
+	count = min(count, idx)
+	for i := 0; i < count; i++ {
+		var err error
+		if idx, err = r.StepBackward(idx); err != nil {
+			return i, err
+		}
+	}
+	return count, nil
+}
+
+// Continue replays r forward from the current event until an event hits one
+// of bm's breakpoints or watchpoints, and returns the breakpoint hit. Where a
+// watchpoint tripped is remembered if r can, see
+// replay.BasicReplayer.WatchpointHits. If nothing is hit, r stops at the last
+// event and ErrEndOfRecording is returned. interrupted, which may be nil, is
+// polled before each event; once it reports true replay stops there and
+// Continue returns nil, nil.
+func Continue(r replay.Replayer, bm *BreakpointManager, interrupted func() bool) (*Breakpoint, error) {
+	var hit *Breakpoint
+	stopped := false
+	breakpointCheck := func(event recorder.Event) bool {
+		if interrupted != nil && interrupted() {
+			stopped = true
+			return true
+		}
+		hit = hitBreakpoint(bm, event)
+		return hit != nil
+	}
+	watchCheck := func(event recorder.Event) bool {
+		hit = hitWatchpoint(bm, event)
+		return hit != nil
+	}
+
+	var err error
+	if watcher, ok := r.(interface {
+		ReplayUntilWatchpoint(watchCheck, breakpointCheck func(event recorder.Event) bool) error
+	}); ok {
+		err = watcher.ReplayUntilWatchpoint(watchCheck, breakpointCheck)
+	} else {
+		err = r.ReplayUntilBreakpoint(func(event recorder.Event) bool {
+			return watchCheck(event) || breakpointCheck(event)
+		})
+	}
+	switch {
+	case err != nil:
+		return nil, err
+	case stopped:
+		return nil, nil
+	case hit == nil:
+		return nil, ErrEndOfRecording
+	}
+	return hit, nil
+}
+
+// NextHit returns the index of the first event after from that hits one of
+// bm's breakpoints or watchpoints, and the breakpoint hit, or -1 and nil if
+// none does. Unlike Continue it only scans events, so nothing is replayed,
+// printed or remembered.
+func NextHit(events []recorder.Event, from int, bm *BreakpointManager) (int, *Breakpoint) {
+	for i := max(from+1, 0); i < len(events); i++ {
+		if bp := hitWatchpoint(bm, events[i]); bp != nil {
+			return i, bp
+		}
+		if bp := hitBreakpoint(bm, events[i]); bp != nil {
+			return i, bp
+		}
+	}
+	return -1, nil
+}
+
+// hitWatchpoint returns the first of bm's watchpoints event trips, or nil
+func hitWatchpoint(bm *BreakpointManager, event recorder.Event) *Breakpoint {
+	for _, bp := range bm.GetWatchpoints() {
+		if bp.Matches(event) {
+			return bp
+		}
+	}
+	return nil
+}
+
+// hitBreakpoint returns the first of bm's breakpoints, other than
+// watchpoints, that event hits, or nil
+func hitBreakpoint(bm *BreakpointManager, event recorder.Event) *Breakpoint {
+	for _, bp := range bm.GetBreakpoints() {
+		if !bp.IsWatchpoint() && bp.Matches(event) {
+			return bp
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package debugger
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+	"github.com/willibrandon/ChronoGo/pkg/replay"
+)
+
+func TestNavigate(t *testing.T) {
+	base := time.Now()
+	events := []recorder.Event{
+		{ID: 1, Timestamp: base, Type: recorder.FuncEntry, FuncName: "main.main", Details: "Entering main"},
+		{ID: 2, Timestamp: base.Add(time.Millisecond), Type: recorder.VarAssignment, Details: "x = 1"},
+		{ID: 3, Timestamp: base.Add(2 * time.Millisecond), Type: recorder.FuncEntry, FuncName: "main.work", Details: "Entering work"},
+		{ID: 4, Timestamp: base.Add(3 * time.Millisecond), Type: recorder.StatementExecution, Details: "y++"},
+	}
+	r := replay.NewBasicReplayer()
+	if err := r.LoadEvents(events); err != nil {
+		t.Fatal(err)
+	}
+
+	bm := NewBreakpointManager()
+	if _, err := bm.AddBreakpoint("func:work"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bm.AddWatchpoint("x", WatchpointWrite); err != nil {
+		t.Fatal(err)
+	}
+
+	bp, err := Continue(r, bm, nil)
+	if err != nil || bp == nil || !bp.IsWatchpoint() || r.CurrentIndex() != 1 {
+		t.Fatalf("Expected the watchpoint to stop at 1, got %v at %d (%v)", bp, r.CurrentIndex(), err)
+	}
+	if hits := r.WatchpointHits(); len(hits) != 1 || hits[0] != 1 {
+		t.Errorf("Expected the watchpoint hit to be remembered, got %v", hits)
+	}
+	bp, err = Continue(r, bm, nil)
+	if err != nil || bp == nil || bp.Type != FunctionBreakpoint || r.CurrentIndex() != 2 {
+		t.Fatalf("Expected the breakpoint to stop at 2, got %v at %d (%v)", bp, r.CurrentIndex(), err)
+	}
+	if _, err := Continue(r, bm, nil); !errors.Is(err, ErrEndOfRecording) || r.CurrentIndex() != 3 {
+		t.Errorf("Expected ErrEndOfRecording at the last event, got %v at %d", err, r.CurrentIndex())
+	}
+
+	if n, err := StepForward(r, 1); !errors.Is(err, ErrEndOfRecording) || n != 0 {
+		t.Errorf("Expected ErrEndOfRecording stepping past the end, got %d (%v)", n, err)
+	}
+	if n, err := StepBackward(r, 10); err != nil || n != 3 || r.CurrentIndex() != 0 {
+		t.Errorf("Expected to step back 3 events to the first, got %d to %d (%v)", n, r.CurrentIndex(), err)
+	}
+	if _, err := StepBackward(r, 1); !errors.Is(err, ErrStartOfRecording) {
+		t.Errorf("Expected ErrStartOfRecording, got %v", err)
+	}
+	if n, err := StepForward(r, 2); err != nil || n != 2 || r.CurrentIndex() != 2 {
+		t.Errorf("Expected to step 2 events to 2, got %d to %d (%v)", n, r.CurrentIndex(), err)
+	}
+
+	if err := GotoIndex(r, len(events)); err == nil {
+		t.Error("Expected an error going past the last event")
+	}
+	if idx, err := GotoTime(r, base.Add(1500*time.Microsecond)); err != nil || idx != 1 || r.CurrentIndex() != 1 {
+		t.Errorf("Expected GotoTime to reach 1, got %d (%v)", idx, err)
+	}
+
+	// Interrupting stops at the next event
+	r.Reset()
+	if bp, err := Continue(r, bm, func() bool { return true }); bp != nil || err != nil || r.CurrentIndex() != 0 {
+		t.Errorf("Expected an interrupted continue to stop at once, got %v at %d (%v)", bp, r.CurrentIndex(), err)
+	}
+}
+
+func TestNextHit(t *testing.T) {
+	events := []recorder.Event{
+		{ID: 1, Type: recorder.FuncEntry, FuncName: "main.main"},
+		{ID: 2, Type: recorder.VarAssignment, Details: "x = 1"},
+		{ID: 3, Type: recorder.FuncEntry, FuncName: "main.work"},
+	}
+	bm := NewBreakpointManager()
+	if _, err := bm.AddBreakpoint("func:work"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bm.AddWatchpoint("x", WatchpointWrite); err != nil {
+		t.Fatal(err)
+	}
+
+	if idx, bp := NextHit(events, -1, bm); idx != 1 || bp == nil || !bp.IsWatchpoint() {
+		t.Errorf("Expected the watchpoint at 1, got %v at %d", bp, idx)
+	}
+	if idx, bp := NextHit(events, 1, bm); idx != 2 || bp == nil || bp.Type != FunctionBreakpoint {
+		t.Errorf("Expected the breakpoint at 2, got %v at %d", bp, idx)
+	}
+	if idx, bp := NextHit(events, 2, bm); idx != -1 || bp != nil {
+		t.Errorf("Expected no hit after the last event, got %v at %d", bp, idx)
+	}
+}
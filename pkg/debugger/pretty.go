@@ -0,0 +1,307 @@
+package debugger
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxPrettyElements is the number of elements PrettyValue shows for a slice,
+// map or JSON array before truncating
+var MaxPrettyElements = 10
+
+// prettyIndent is the indentation added for each nesting level
+const prettyIndent = "  "
+
+// sliceHeader matches the "len: 3, cap: 4, " header Delve prints before slice contents
+var sliceHeader = regexp.MustCompile(`len: \d+, cap: \d+, `)
+
+// prettyNode is a parsed value: a scalar, or text followed by a bracketed list of elements
+type prettyNode struct {
+	text  string        // Text before the opening bracket, or the whole value for scalars
+	open  byte          // Opening bracket, or 0 for scalars
+	elems []*prettyNode // Elements between the brackets
+	after string        // Text after the closing bracket
+}
+
+// PrettyValue formats a recorded or inspected variable value for display.
+// JSON values and Go composite literals (as printed by Delve) are re-indented
+// one element per line, and slices and maps longer than MaxPrettyElements are
+// truncated with an element count. Scalars and values that can't be parsed
+// are returned unchanged.
+func PrettyValue(typeName, value string) string {
+	trimmed := strings.TrimSpace(value)
+	if !strings.ContainsAny(trimmed, "{[") {
+		return value
+	}
+
+	// The type prefix may itself contain brackets, e.g. map[string]int
+	prefix := ""
+	if typeName != "" && strings.HasPrefix(trimmed, typeName) {
+		prefix = typeName
+		trimmed = trimmed[len(typeName):]
+	}
+
+	// Keep Delve's slice header on one line
+	trimmed = sliceHeader.ReplaceAllStringFunc(trimmed, func(s string) string {
+		return strings.ReplaceAll(s, ",", "\x00")
+	})
+
+	p := &prettyParser{s: trimmed}
+	node := p.parseNode(0)
+	if p.err || p.pos != len(p.s) || node.open == 0 || node.after != "" {
+		return value
+	}
+
+	// Only a type name may precede the value; anything else is prose that happens to contain brackets
+	if head := sliceHeader.ReplaceAllString(strings.ReplaceAll(node.text, "\x00", ","), ""); strings.ContainsAny(strings.TrimSpace(head), " \t") {
+		return value
+	}
+
+	node.text = prefix + node.text
+	return strings.ReplaceAll(node.render(""), "\x00", ",")
+}
+
+// prettyParser splits a value into nested bracketed elements
+type prettyParser struct {
+	s   string
+	pos int
+	err bool
+}
+
+// closerFor returns the bracket that closes open
+func closerFor(open byte) byte {
+	if open == '{' {
+		return '}'
+	}
+	return ']'
+}
+
+// parseNode reads a single element, stopping at a comma or the closing bracket of the enclosing list
+func (p *prettyParser) parseNode(closer byte) *prettyNode {
+	node := &prettyNode{}
+	var text strings.Builder
+
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		switch {
+		case c == '"' || c == '`' || c == '\'':
+			text.WriteString(p.readQuoted(c))
+			continue
+		case (c == ',' || c == closer) && closer != 0:
+			node.finish(text.String())
+			return node
+		case c == '}' || c == ']':
+			// Unbalanced closing bracket
+			p.err = true
+			return node
+		case c == '[' && p.isTypeBracket():
+			// Part of a type name such as []int, [3]int or map[string]int
+			end := strings.IndexByte(p.s[p.pos:], ']')
+			text.WriteString(p.s[p.pos : p.pos+end+1])
+			p.pos += end + 1
+			continue
+		case (c == '{' || c == '[') && node.open == 0:
+			node.text = text.String()
+			if closer != 0 {
+				// Drop the space after the separator of a nested element
+				node.text = strings.TrimLeft(node.text, " \t\n")
+			}
+			text.Reset()
+			node.open = c
+			p.pos++
+			node.elems = p.parseElems(closerFor(c))
+			continue
+		}
+		text.WriteByte(c)
+		p.pos++
+	}
+
+	if closer != 0 {
+		// Ran out of input inside brackets
+		p.err = true
+	}
+	node.finish(text.String())
+	return node
+}
+
+// finish stores the text read after the brackets, or the scalar text
+func (n *prettyNode) finish(text string) {
+	if n.open == 0 {
+		n.text = strings.TrimSpace(text)
+	} else {
+		n.after = strings.TrimSpace(text)
+	}
+}
+
+// parseElems reads comma-separated elements up to and including closer
+func (p *prettyParser) parseElems(closer byte) []*prettyNode {
+	var elems []*prettyNode
+	for p.pos < len(p.s) && !p.err {
+		elem := p.parseNode(closer)
+		if elem.open != 0 || elem.text != "" {
+			elems = append(elems, elem)
+		}
+		if p.pos >= len(p.s) {
+			break
+		}
+		c := p.s[p.pos]
+		p.pos++
+		if c == closer {
+			return elems
+		}
+	}
+	p.err = true
+	return elems
+}
+
+// isTypeBracket reports whether the '[' at the current position is part of a type name
+func (p *prettyParser) isTypeBracket() bool {
+	rest := p.s[p.pos+1:]
+	end := strings.IndexByte(rest, ']')
+	if end < 0 {
+		return false
+	}
+	if strings.HasSuffix(p.s[:p.pos], "map") {
+		return true
+	}
+	// []T and [N]T are followed directly by the element type
+	if strings.Trim(rest[:end], "0123456789") != "" || end+1 >= len(rest) {
+		return false
+	}
+	next := rest[end+1]
+	return next == '*' || next == '[' || next == '_' || next == '(' ||
+		(next >= 'a' && next <= 'z') || (next >= 'A' && next <= 'Z')
+}
+
+// readQuoted reads a quoted string starting at the current position
+func (p *prettyParser) readQuoted(quote byte) string {
+	start := p.pos
+	p.pos++
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		if c == '\\' && quote != '`' {
+			p.pos += 2
+			continue
+		}
+		p.pos++
+		if c == quote {
+			return p.s[start:p.pos]
+		}
+	}
+	p.err = true
+	return p.s[start:]
+}
+
+// render formats the node with one element per line
+func (n *prettyNode) render(indent string) string {
+	if n.open == 0 {
+		return n.text
+	}
+
+	var b strings.Builder
+	b.WriteString(n.text)
+	b.WriteByte(n.open)
+	if len(n.elems) == 0 {
+		b.WriteByte(closerFor(n.open))
+	} else {
+		shown := n.elems
+		if len(shown) > MaxPrettyElements {
+			shown = shown[:MaxPrettyElements]
+		}
+		for i, elem := range shown {
+			b.WriteString("\n" + indent + prettyIndent)
+			b.WriteString(elem.render(indent + prettyIndent))
+			if i < len(n.elems)-1 {
+				b.WriteByte(',')
+			}
+		}
+		if hidden := len(n.elems) - len(shown); hidden > 0 {
+			b.WriteString("\n" + indent + prettyIndent)
+			b.WriteString("... " + strconv.Itoa(hidden) + " more (" + strconv.Itoa(len(n.elems)) + " total)")
+		}
+		b.WriteString("\n" + indent)
+		b.WriteByte(closerFor(n.open))
+	}
+	if n.after != "" {
+		b.WriteString(" " + n.after)
+	}
+	return b.String()
+}
+
+// Payload is a JSON value or base64 blob found inside an event field
+type Payload struct {
+	Offset  int    // Byte offset of the payload in the field
+	Size    int    // Size of the payload in the field, in bytes
+	Decoded []byte // Decoded base64 bytes, nil for JSON
+	Pretty  string // Indented JSON, or the decoded text for base64
+}
+
+// base64Blob matches runs of characters that may be a base64 blob. Shorter
+// runs are usually ordinary words.
+var base64Blob = regexp.MustCompile(`[A-Za-z0-9+/]{16,}={0,2}`)
+
+// FindPayloads returns the JSON objects and arrays embedded in s, indented,
+// and the base64 blobs in the rest of s that decode to text or JSON
+func FindPayloads(s string) []Payload {
+	var payloads []Payload
+	covered := make([]bool, len(s))
+
+	for i := 0; i < len(s); i++ {
+		if s[i] != '{' && s[i] != '[' {
+			continue
+		}
+		dec := json.NewDecoder(strings.NewReader(s[i:]))
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			continue
+		}
+		size := int(dec.InputOffset())
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, raw, "", prettyIndent); err != nil {
+			continue
+		}
+		payloads = append(payloads, Payload{Offset: i, Size: size, Pretty: indented.String()})
+		for j := i; j < i+size; j++ {
+			covered[j] = true
+		}
+		i += size - 1
+	}
+
+	for _, loc := range base64Blob.FindAllStringIndex(s, -1) {
+		if covered[loc[0]] {
+			continue
+		}
+		blob := s[loc[0]:loc[1]]
+		decoded, err := base64.StdEncoding.DecodeString(blob)
+		if err != nil || !printable(decoded) {
+			continue
+		}
+		pretty := string(decoded)
+		var indented bytes.Buffer
+		if json.Indent(&indented, decoded, "", prettyIndent) == nil {
+			pretty = indented.String()
+		}
+		payloads = append(payloads, Payload{Offset: loc[0], Size: len(blob), Decoded: decoded, Pretty: pretty})
+	}
+	return payloads
+}
+
+// printable reports whether data is UTF-8 text, so hex digests and binary
+// ciphertext aren't shown as decoded blobs
+func printable(data []byte) bool {
+	if len(data) == 0 || !utf8.Valid(data) {
+		return false
+	}
+	for _, r := range string(data) {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
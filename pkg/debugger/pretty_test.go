@@ -0,0 +1,110 @@
+package debugger
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestPrettyValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		typeName string
+		value    string
+		expected string
+	}{
+		{
+			name:     "JSON object",
+			value:    `{"name":"Alice","tags":["a","b"]}`,
+			expected: "{\n  \"name\":\"Alice\",\n  \"tags\":[\n    \"a\",\n    \"b\"\n  ]\n}",
+		},
+		{
+			name:     "Go struct literal",
+			typeName: "main.Person",
+			value:    `main.Person {Name: "Alice, Bob", Age: 30, Inner: main.T {A: 1}}`,
+			expected: "main.Person {\n  Name: \"Alice, Bob\",\n  Age: 30,\n  Inner: main.T {\n    A: 1\n  }\n}",
+		},
+		{
+			name:     "Delve slice",
+			typeName: "[]int",
+			value:    "[]int len: 2, cap: 2, [1,2]",
+			expected: "[]int len: 2, cap: 2, [\n  1,\n  2\n]",
+		},
+		{
+			name:     "Map type",
+			typeName: "map[string]int",
+			value:    `map[string]int ["a": 1, ]`,
+			expected: "map[string]int [\n  \"a\": 1\n]",
+		},
+		{
+			name:     "Scalar",
+			typeName: "int",
+			value:    "42",
+			expected: "42",
+		},
+		{
+			name:     "Unbalanced",
+			value:    `{"name": "Alice"`,
+			expected: `{"name": "Alice"`,
+		},
+		{
+			name:     "Prose with brackets",
+			value:    "Entering main [x]",
+			expected: "Entering main [x]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PrettyValue(tt.typeName, tt.value); got != tt.expected {
+				t.Errorf("Expected:\n%s\ngot:\n%s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestPrettyValueTruncation(t *testing.T) {
+	originalMax := MaxPrettyElements
+	MaxPrettyElements = 3
+	defer func() {
+		MaxPrettyElements = originalMax
+	}()
+
+	elems := make([]string, 8)
+	for i := range elems {
+		elems[i] = fmt.Sprint(i)
+	}
+	got := PrettyValue("", "["+strings.Join(elems, ",")+"]")
+
+	if !strings.Contains(got, "... 5 more (8 total)") {
+		t.Errorf("Expected truncation note, got:\n%s", got)
+	}
+	if strings.Contains(got, "\n  3") {
+		t.Errorf("Expected elements past the limit to be hidden, got:\n%s", got)
+	}
+}
+
+func TestFindPayloads(t *testing.T) {
+	details := `POST /users body {"name":"ann","tags":["a","b"]} token eyJ1c2VyIjoiYW5uIn0= sum 0123456789abcdef0123`
+	payloads := FindPayloads(details)
+	if len(payloads) != 2 {
+		t.Fatalf("Expected a JSON and a base64 payload, got %+v", payloads)
+	}
+
+	body := payloads[0]
+	if body.Decoded != nil || body.Offset != strings.Index(details, "{") || body.Size != len(`{"name":"ann","tags":["a","b"]}`) {
+		t.Errorf("Unexpected JSON payload: %+v", body)
+	}
+	if !strings.Contains(body.Pretty, "\n  \"name\": \"ann\",") {
+		t.Errorf("Expected indented JSON, got:\n%s", body.Pretty)
+	}
+
+	token := payloads[1]
+	if string(token.Decoded) != `{"user":"ann"}` || !strings.Contains(token.Pretty, "\"user\": \"ann\"") {
+		t.Errorf("Unexpected base64 payload: %+v", token)
+	}
+
+	if payloads := FindPayloads("Entering main.run [not json]"); len(payloads) != 0 {
+		t.Errorf("Expected no payloads in plain details, got %+v", payloads)
+	}
+}
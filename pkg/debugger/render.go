@@ -0,0 +1,116 @@
+package debugger
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+// EventFormatter renders the description of one type of event, the part of
+// an event line after its timestamp, ID and type
+type EventFormatter interface {
+	FormatEvent(event recorder.Event) string
+}
+
+// EventFormatterFunc adapts a function to an EventFormatter
+type EventFormatterFunc func(event recorder.Event) string
+
+// FormatEvent calls f
+func (f EventFormatterFunc) FormatEvent(event recorder.Event) string {
+	return f(event)
+}
+
+var (
+	formattersMu    sync.RWMutex
+	eventFormatters = map[recorder.EventType]EventFormatter{
+		recorder.GoroutineSwitch:  EventFormatterFunc(formatConcurrencyEvent),
+		recorder.ChannelOperation: EventFormatterFunc(formatConcurrencyEvent),
+		recorder.SyncOperation:    EventFormatterFunc(formatConcurrencyEvent),
+		recorder.ErrorEvent:       EventFormatterFunc(formatErrorEvent),
+		recorder.RuntimeEvent:     EventFormatterFunc(formatRuntimeEvent),
+	}
+	defaultFormatters = copyFormatters(eventFormatters)
+)
+
+// copyFormatters returns a copy of a formatter registry
+func copyFormatters(formatters map[recorder.EventType]EventFormatter) map[recorder.EventType]EventFormatter {
+	copied := make(map[recorder.EventType]EventFormatter, len(formatters))
+	for t, f := range formatters {
+		copied[t] = f
+	}
+	return copied
+}
+
+// RegisterEventFormatter renders events of type t with f in the debugger's
+// event lines, replacing the built-in formatter for t. A nil f restores the
+// built-in one. A format set with the format command takes precedence.
+func RegisterEventFormatter(t recorder.EventType, f EventFormatter) {
+	formattersMu.Lock()
+	defer formattersMu.Unlock()
+	if f == nil {
+		f = defaultFormatters[t]
+	}
+	if f == nil {
+		delete(eventFormatters, t)
+		return
+	}
+	eventFormatters[t] = f
+}
+
+// eventFormatterFor returns the formatter registered for t
+func eventFormatterFor(t recorder.EventType) EventFormatter {
+	formattersMu.RLock()
+	defer formattersMu.RUnlock()
+	if f, ok := eventFormatters[t]; ok {
+		return f
+	}
+	return EventFormatterFunc(formatDetails)
+}
+
+// formatDetails shows an event's details, panics and recoveries in red
+func formatDetails(event recorder.Event) string {
+	if isPanicEvent(event) {
+		return style(event.Details, "bold", "red")
+	}
+	return event.Details
+}
+
+// formatConcurrencyEvent shows the details of a goroutine, channel or sync
+// event along with the goroutine that recorded it
+func formatConcurrencyEvent(event recorder.Event) string {
+	if event.GoroutineID == 0 {
+		return event.Details
+	}
+	return fmt.Sprintf("%s (goroutine %d)", event.Details, event.GoroutineID)
+}
+
+// formatErrorEvent shows every recorded error's message in bold red, not
+// just panics
+func formatErrorEvent(event recorder.Event) string {
+	return style(event.Details, "bold", "red")
+}
+
+// formatRuntimeEvent shows the details of an event from a runtime trace
+// along with the OS thread and processor it happened on, if known
+func formatRuntimeEvent(event recorder.Event) string {
+	if thread := threadInfo(event.OSThreadID, event.ProcID); thread != "" {
+		return fmt.Sprintf("%s (%s)", event.Details, thread)
+	}
+	return event.Details
+}
+
+// threadInfo describes an OS thread and a processor counted from 1, as
+// stored in events, e.g. "on thread 23550, P0", or returns "" if neither
+// is known
+func threadInfo(osThreadID int64, procID int) string {
+	switch {
+	case osThreadID != 0 && procID != 0:
+		return fmt.Sprintf("on thread %d, P%d", osThreadID, procID-1)
+	case osThreadID != 0:
+		return fmt.Sprintf("on thread %d", osThreadID)
+	case procID != 0:
+		return fmt.Sprintf("on P%d", procID-1)
+	}
+	return ""
+}
@@ -0,0 +1,51 @@
+package debugger
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+	"github.com/willibrandon/ChronoGo/pkg/replay"
+)
+
+func TestEventFormatterRegistry(t *testing.T) {
+	originalColor := colorEnabled
+	defer SetColor(originalColor)
+	SetColor(false)
+
+	now := time.Now()
+	events := []recorder.Event{
+		{ID: 1, Timestamp: now, Type: recorder.ChannelOperation, Details: "Channel 2: send by goroutine 3", GoroutineID: 3},
+		{ID: 2, Timestamp: now, Type: recorder.VarAssignment, Details: "total = 6", FuncName: "main.sum"},
+	}
+	replayer := replay.NewBasicReplayer()
+	if err := replayer.LoadEvents(events); err != nil {
+		t.Fatalf("Failed to load events: %v", err)
+	}
+	cli := NewCLI(replayer)
+
+	// Channel operations show their goroutine
+	if got := cli.formatEvent(0, events[0]); !strings.HasSuffix(got, "ChannelOperation - Channel 2: send by goroutine 3 (goroutine 3)") {
+		t.Errorf("Expected the channel operation with its goroutine, got %q", got)
+	}
+
+	RegisterEventFormatter(recorder.VarAssignment, EventFormatterFunc(func(e recorder.Event) string {
+		return "assigned in " + e.FuncName + ": " + e.Details
+	}))
+	defer RegisterEventFormatter(recorder.VarAssignment, nil)
+	if got := cli.formatEvent(1, events[1]); !strings.HasSuffix(got, "VariableAssignment - assigned in main.sum: total = 6") {
+		t.Errorf("Expected the custom formatter, got %q", got)
+	}
+
+	// Replay prints the events continue passes over the same way
+	output := captureOutput(t, func() { cli.handleCommand("continue") })
+	if !strings.Contains(output, "VariableAssignment - assigned in main.sum: total = 6") {
+		t.Errorf("Expected continue to use the custom formatter, got:\n%s", output)
+	}
+
+	RegisterEventFormatter(recorder.VarAssignment, nil)
+	if got := cli.formatEvent(1, events[1]); !strings.HasSuffix(got, "VariableAssignment - total = 6") {
+		t.Errorf("Expected nil to restore the built-in formatter, got %q", got)
+	}
+}
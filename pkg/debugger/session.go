@@ -0,0 +1,111 @@
+package debugger
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+// SessionDir is the directory where debugging sessions are stored
+var SessionDir = ".chronogo"
+
+// SessionSecurity protects saved session files. When encryption or integrity
+// checks are enabled, sessions are sealed with recorder.WriteSecureFile using
+// the same options as the recording they belong to.
+var SessionSecurity = recorder.DefaultSecurityOptions()
+
+// DefaultSessionName is used when no session name is given
+const DefaultSessionName = "default"
+
+// SessionState holds the persisted state of a debugging session
+type SessionState struct {
+	Name        string           `json:"name"`
+	SavedAt     time.Time        `json:"saved_at"`
+	EventsFile  string           `json:"events_file"`
+	EventsHash  string           `json:"events_hash"` // SHA-256 of the events file when the session was saved
+	CurrentIdx  int              `json:"current_idx"`
+	Breakpoints []Breakpoint     `json:"breakpoints"`      // Breakpoints and watchpoints
+	Tags        map[int][]string `json:"tags,omitempty"`   // Tags added during the session, by event index
+	Filter      string           `json:"filter,omitempty"` // Step filter, tag:<label> or type:<name>
+	Format      string           `json:"format,omitempty"` // Event format template, empty for the default
+}
+
+// SessionPath returns the file path used to store the named session. Names
+// can't contain path separators or "..", so sessions stay in SessionDir.
+func SessionPath(name string) (string, error) {
+	if name == "" {
+		name = DefaultSessionName
+	}
+	if strings.ContainsAny(name, `/\`) || strings.Contains(name, "..") || filepath.VolumeName(name) != "" {
+		return "", fmt.Errorf("invalid session name %q", name)
+	}
+	return filepath.Join(SessionDir, "sessions", name+".json"), nil
+}
+
+// SaveSessionState writes the session state as JSON to the given path,
+// creating parent directories as needed
+func SaveSessionState(path string, state SessionState) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create session directory: %v", err)
+	}
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize session: %v", err)
+	}
+
+	if sessionSecured() {
+		return recorder.WriteSecureFile(path, data, SessionSecurity, 0600)
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// sessionSecured reports whether session files are sealed
+func sessionSecured() bool {
+	return SessionSecurity.EnableEncryption || SessionSecurity.EnableIntegrityCheck
+}
+
+// LoadSessionState reads a session state previously written by SaveSessionState
+func LoadSessionState(path string) (SessionState, error) {
+	var state SessionState
+
+	var data []byte
+	var err error
+	if sessionSecured() {
+		data, err = recorder.ReadSecureFile(path, SessionSecurity)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return state, fmt.Errorf("failed to read session: %v", err)
+	}
+
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, fmt.Errorf("failed to parse session %s: %v", path, err)
+	}
+
+	return state, nil
+}
+
+// HashFile returns the hex-encoded SHA-256 of the file at path
+func HashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,236 @@
+package debugger
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+	"github.com/willibrandon/ChronoGo/pkg/replay"
+)
+
+func TestSessionSaveRestore(t *testing.T) {
+	// Store sessions in a temporary directory
+	tempDir := t.TempDir()
+	originalDir := SessionDir
+	SessionDir = filepath.Join(tempDir, ".chronogo")
+	defer func() {
+		SessionDir = originalDir
+	}()
+
+	eventsFile := filepath.Join(tempDir, "test.events")
+	if err := os.WriteFile(eventsFile, []byte("events"), 0644); err != nil {
+		t.Fatalf("Failed to write events file: %v", err)
+	}
+
+	events := []recorder.Event{
+		{ID: 1, Timestamp: time.Now(), Type: recorder.FuncEntry, Details: "Entering main"},
+		{ID: 2, Timestamp: time.Now(), Type: recorder.StatementExecution, Details: "x = 1"},
+		{ID: 3, Timestamp: time.Now(), Type: recorder.FuncExit, Details: "Exiting main"},
+	}
+
+	newCLI := func() *CLI {
+		replayer := replay.NewBasicReplayer()
+		if err := replayer.LoadEvents(events); err != nil {
+			t.Fatalf("Failed to load events: %v", err)
+		}
+		cli := NewCLI(replayer)
+		cli.SetEventsFile(eventsFile)
+		return cli
+	}
+
+	// Set up some state and save it
+	cli := newCLI()
+	if _, err := cli.bpManager.AddBreakpoint("main.go:10"); err != nil {
+		t.Fatalf("Failed to add breakpoint: %v", err)
+	}
+	if _, err := cli.bpManager.AddWatchpoint("x", WatchpointWrite); err != nil {
+		t.Fatalf("Failed to add watchpoint: %v", err)
+	}
+	if err := cli.bpManager.DisableBreakpoint(1); err != nil {
+		t.Fatalf("Failed to disable breakpoint: %v", err)
+	}
+	if err := cli.replayer.ReplayToEventIndex(1); err != nil {
+		t.Fatalf("Failed to move to event: %v", err)
+	}
+	if err := cli.SaveSession("test"); err != nil {
+		t.Fatalf("Failed to save session: %v", err)
+	}
+
+	// Restore into a fresh CLI
+	restored := newCLI()
+	if err := restored.RestoreSession("test"); err != nil {
+		t.Fatalf("Failed to restore session: %v", err)
+	}
+
+	if restored.replayer.CurrentIndex() != 1 {
+		t.Errorf("Expected current index 1, got %d", restored.replayer.CurrentIndex())
+	}
+
+	bps := restored.GetBreakpoints()
+	if len(bps) != 2 {
+		t.Fatalf("Expected 2 breakpoints, got %d", len(bps))
+	}
+	if bps[0].File != "main.go" || bps[0].Line != 10 || bps[0].Enabled {
+		t.Errorf("Unexpected restored breakpoint: %+v", *bps[0])
+	}
+	if bps[1].Type != WatchpointWrite || bps[1].Expression != "x" {
+		t.Errorf("Unexpected restored watchpoint: %+v", *bps[1])
+	}
+
+	// New breakpoints must not reuse restored IDs
+	bp, err := restored.bpManager.AddBreakpoint("func:main")
+	if err != nil {
+		t.Fatalf("Failed to add breakpoint: %v", err)
+	}
+	if bp.ID != 3 {
+		t.Errorf("Expected new breakpoint ID 3, got %d", bp.ID)
+	}
+
+	// The stored hash must detect a changed events file
+	path, err := SessionPath("test")
+	if err != nil {
+		t.Fatalf("Failed to get session path: %v", err)
+	}
+	state, err := LoadSessionState(path)
+	if err != nil {
+		t.Fatalf("Failed to load session state: %v", err)
+	}
+	if err := os.WriteFile(eventsFile, []byte("changed"), 0644); err != nil {
+		t.Fatalf("Failed to rewrite events file: %v", err)
+	}
+	hash, err := HashFile(eventsFile)
+	if err != nil {
+		t.Fatalf("Failed to hash events file: %v", err)
+	}
+	if hash == state.EventsHash {
+		t.Error("Expected events hash to change after modifying the file")
+	}
+}
+
+func TestRestoreMissingSession(t *testing.T) {
+	originalDir := SessionDir
+	SessionDir = t.TempDir()
+	defer func() {
+		SessionDir = originalDir
+	}()
+
+	cli := NewCLI(replay.NewBasicReplayer())
+	if err := cli.RestoreSession("missing"); err == nil {
+		t.Error("Expected error restoring a missing session")
+	}
+}
+
+func TestSessionNames(t *testing.T) {
+	for _, name := range []string{"../escape", "a/b", `a\b`, "..", "x..y"} {
+		if _, err := SessionPath(name); err == nil {
+			t.Errorf("Expected session name %q to be rejected", name)
+		}
+	}
+	if path, err := SessionPath(""); err != nil || filepath.Base(path) != DefaultSessionName+".json" {
+		t.Errorf("Expected the default session, got %s (%v)", path, err)
+	}
+
+	cli := NewCLI(replay.NewBasicReplayer())
+	if err := cli.SaveSession("../escape"); err == nil {
+		t.Error("Expected saving under an invalid name to fail")
+	}
+}
+
+func TestSessionViewState(t *testing.T) {
+	originalDir := SessionDir
+	SessionDir = t.TempDir()
+	defer func() {
+		SessionDir = originalDir
+	}()
+
+	base := time.Now()
+	var events []recorder.Event
+	for i := 0; i < 5; i++ {
+		events = append(events, recorder.Event{ID: int64(i + 1), Timestamp: base.Add(time.Duration(i) * time.Millisecond),
+			Type: recorder.StatementExecution, Details: "x++"})
+	}
+	newCLI := func(events []recorder.Event) *CLI {
+		replayer := replay.NewBasicReplayer()
+		if err := replayer.LoadEvents(events); err != nil {
+			t.Fatalf("Failed to load events: %v", err)
+		}
+		return NewCLI(replayer)
+	}
+
+	// The step filter and event format come back with the session
+	cli := newCLI(events)
+	cli.handleCommand("filter type:StatementExecution")
+	cli.handleCommand("format {{.ID}}")
+	cli.handleCommand("step 4")
+	if err := cli.SaveSession("view"); err != nil {
+		t.Fatalf("Failed to save session: %v", err)
+	}
+	restored := newCLI(events)
+	if err := restored.RestoreSession("view"); err != nil {
+		t.Fatalf("Failed to restore session: %v", err)
+	}
+	if restored.stepFilter != "type:StatementExecution" || restored.formatter == nil || restored.formatter.text != "{{.ID}}" {
+		t.Errorf("Expected the filter and format to be restored, got %q and %+v", restored.stepFilter, restored.formatter)
+	}
+
+	// A session that doesn't fit the recording changes nothing
+	short := newCLI(events[:2])
+	if _, err := short.bpManager.AddBreakpoint("main.go:10"); err != nil {
+		t.Fatalf("Failed to add breakpoint: %v", err)
+	}
+	if err := short.RestoreSession("view"); err == nil {
+		t.Fatal("Expected restoring an out of range event to fail")
+	}
+	if bps := short.GetBreakpoints(); len(bps) != 1 || bps[0].File != "main.go" ||
+		short.stepFilter != "" || short.formatter != nil {
+		t.Errorf("Expected the failed restore to leave the CLI as it was, got %d breakpoints, filter %q", len(bps), short.stepFilter)
+	}
+}
+
+func TestSecureSessionState(t *testing.T) {
+	originalSecurity := SessionSecurity
+	defer func() {
+		SessionSecurity = originalSecurity
+	}()
+	key := []byte("0123456789ABCDEF")
+	recorder.WithEncryption(key)(&SessionSecurity)
+	recorder.WithIntegrityCheck(key)(&SessionSecurity)
+
+	path := filepath.Join(t.TempDir(), "secure.json")
+	state := SessionState{
+		Name:        "secure",
+		EventsFile:  "secret-service.events",
+		Breakpoints: []Breakpoint{{ID: 1, Type: WatchpointWrite, Expression: "apiToken", Enabled: true}},
+	}
+	if err := SaveSessionState(path, state); err != nil {
+		t.Fatalf("Failed to save session: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read session file: %v", err)
+	}
+	for _, plaintext := range []string{"secret-service", "apiToken"} {
+		if strings.Contains(string(data), plaintext) {
+			t.Errorf("Session file contains plaintext %q", plaintext)
+		}
+	}
+
+	loaded, err := LoadSessionState(path)
+	if err != nil {
+		t.Fatalf("Failed to load session: %v", err)
+	}
+	if loaded.EventsFile != state.EventsFile || len(loaded.Breakpoints) != 1 || loaded.Breakpoints[0].Expression != "apiToken" {
+		t.Errorf("Loaded session doesn't match saved session: %+v", loaded)
+	}
+
+	// A different key must not open the session
+	recorder.WithEncryption([]byte("FEDCBA9876543210"))(&SessionSecurity)
+	recorder.WithIntegrityCheck([]byte("FEDCBA9876543210"))(&SessionSecurity)
+	if _, err := LoadSessionState(path); err == nil {
+		t.Errorf("Expected error loading session with the wrong key")
+	}
+}
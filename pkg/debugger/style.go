@@ -0,0 +1,106 @@
+package debugger
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+// ansiColors are the styles the CLI and the color template function use
+var ansiColors = map[string]string{
+	"bold":    "\033[1m",
+	"red":     "\033[31m",
+	"green":   "\033[32m",
+	"yellow":  "\033[33m",
+	"blue":    "\033[34m",
+	"magenta": "\033[35m",
+	"cyan":    "\033[36m",
+	"gray":    "\033[90m",
+}
+
+// ansiReset ends a styled span
+const ansiReset = "\033[0m"
+
+// eventTypeColors gives each event type a stable color
+var eventTypeColors = map[recorder.EventType]string{
+	recorder.FuncEntry:          "green",
+	recorder.FuncExit:           "green",
+	recorder.VarAssignment:      "cyan",
+	recorder.GoroutineSwitch:    "magenta",
+	recorder.StatementExecution: "blue",
+	recorder.ChannelOperation:   "magenta",
+	recorder.SyncOperation:      "magenta",
+	recorder.SnapshotEvent:      "gray",
+	recorder.RngEvent:           "gray",
+	recorder.TimeReadEvent:      "gray",
+	recorder.SelectEvent:        "magenta",
+	recorder.DeferOperation:     "yellow",
+	recorder.ErrorEvent:         "red",
+	recorder.IOEvent:            "cyan",
+	recorder.RuntimeEvent:       "gray",
+	recorder.RecordingStopped:   "yellow",
+	recorder.NetworkOperation:   "cyan",
+	recorder.RotationEvent:      "yellow",
+	recorder.ContextEvent:       "yellow",
+	recorder.ProcessSpawnEvent:  "magenta",
+}
+
+// colorEnabled controls whether output is styled. It is on when stdout is
+// a terminal and NO_COLOR is unset, so piped output and tests stay plain.
+var colorEnabled = detectColor()
+
+// detectColor reports whether stdout is a terminal that should get colors
+func detectColor() bool {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// SetColor turns styled output on or off, overriding terminal detection
+func SetColor(enabled bool) {
+	colorEnabled = enabled
+}
+
+// style wraps text in the named ANSI styles when color is enabled. Unknown
+// names are ignored.
+func style(text string, names ...string) string {
+	if !colorEnabled {
+		return text
+	}
+	var codes string
+	for _, name := range names {
+		codes += ansiColors[name]
+	}
+	if codes == "" {
+		return text
+	}
+	return codes + text + ansiReset
+}
+
+// styleEventType colors an event type name by type
+func styleEventType(t recorder.EventType) string {
+	return style(t.String(), eventTypeColors[t])
+}
+
+// isPanicEvent reports whether an event records a panic or a recovery
+func isPanicEvent(event recorder.Event) bool {
+	return strings.HasPrefix(event.Details, "Panic in ") || strings.HasPrefix(event.Details, "Recovered in ")
+}
+
+// styleStatus returns a breakpoint's status, green when enabled
+func styleStatus(enabled bool) string {
+	if enabled {
+		return style("enabled", "green")
+	}
+	return style("disabled", "gray")
+}
+
+// printError prints an error message in red
+func printError(format string, args ...interface{}) {
+	msg := strings.TrimSuffix(fmt.Sprintf(format, args...), "\n")
+	fmt.Println(style(msg, "red"))
+}
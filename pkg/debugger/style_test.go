@@ -0,0 +1,50 @@
+package debugger
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+	"github.com/willibrandon/ChronoGo/pkg/replay"
+)
+
+func TestStyle(t *testing.T) {
+	originalColor := colorEnabled
+	defer SetColor(originalColor)
+
+	SetColor(false)
+	if got := style("text", "red"); got != "text" {
+		t.Errorf("Expected plain text with color off, got %q", got)
+	}
+
+	SetColor(true)
+	if got := style("text", "bold", "red"); got != "\033[1m\033[31mtext\033[0m" {
+		t.Errorf("Unexpected styled text %q", got)
+	}
+	if got := style("text", "plaid"); got != "text" {
+		t.Errorf("Expected unknown styles to be ignored, got %q", got)
+	}
+
+	output := captureOutput(t, func() { printError("Error loading: %v\n", "boom") })
+	if output != "\033[31mError loading: boom\033[0m\n" {
+		t.Errorf("Expected a red error line, got %q", output)
+	}
+
+	panicEvent := recorder.Event{ID: 3, Timestamp: time.Now(), Type: recorder.ErrorEvent, Details: "Panic in main.run: boom (string)"}
+	replayer := replay.NewBasicReplayer()
+	if err := replayer.LoadEvents([]recorder.Event{panicEvent}); err != nil {
+		t.Fatalf("Failed to load events: %v", err)
+	}
+	formatted := NewCLI(replayer).formatEvent(0, panicEvent)
+	if !strings.Contains(formatted, "\033[31mErrorEvent\033[0m") || !strings.Contains(formatted, "\033[1m\033[31mPanic in main.run") {
+		t.Errorf("Expected the panic to be highlighted, got %q", formatted)
+	}
+}
+
+func TestDetectColor(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+	if detectColor() {
+		t.Error("Expected NO_COLOR to disable color, even when empty")
+	}
+}
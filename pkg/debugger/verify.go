@@ -0,0 +1,186 @@
+package debugger
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-delve/delve/service/api"
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+// VerifyOptions bounds a check of a recording against live execution
+type VerifyOptions struct {
+	MaxEntries int // Function entries checked before stopping, 0 for all
+	// Distinct functions given breakpoints at a time. Larger batches need
+	// fewer round trips to Delve, but a live call to a function outside the
+	// batch goes unnoticed.
+	BatchSize int
+}
+
+// DefaultVerifyOptions checks up to 10000 entries, 50 functions at a time
+func DefaultVerifyOptions() VerifyOptions {
+	return VerifyOptions{MaxEntries: 10000, BatchSize: 50}
+}
+
+// Divergence is where live execution stopped following a recording
+type Divergence struct {
+	EventIdx int            // Index of the recorded entry live execution didn't reach
+	Expected recorder.Event // The recorded entry
+	Previous int            // Index of the last entry both agree on, -1 if none
+	Live     string         // Function live execution entered instead, empty if it exited
+	LiveFile string
+	LiveLine int
+}
+
+func (d *Divergence) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Recording expects event %d: entering %s", d.EventIdx, d.Expected.FuncName)
+	if d.Expected.File != "" {
+		fmt.Fprintf(&b, " at %s:%d", d.Expected.File, d.Expected.Line)
+	}
+	if d.Previous >= 0 {
+		fmt.Fprintf(&b, ", after event %d", d.Previous)
+	}
+	if d.Live == "" {
+		b.WriteString("\nLive execution exited instead")
+	} else {
+		fmt.Fprintf(&b, "\nLive execution entered %s at %s:%d instead", d.Live, d.LiveFile, d.LiveLine)
+	}
+	return b.String()
+}
+
+// VerifyResult is the outcome of VerifyRecording
+type VerifyResult struct {
+	Checked    int         // Entries live execution reached in the recorded order
+	Total      int         // Entries that could be checked, up to MaxEntries
+	Skipped    []string    // Functions no breakpoint could be set on, whose entries were skipped
+	Divergence *Divergence // First divergence, nil if there was none
+}
+
+// VerifyRecording checks that a program being debugged from its start
+// enters the functions of a recording's FuncEntry events in the recorded
+// order. Only the entries of the goroutine that made the first one are
+// checked, against the live goroutine that reaches the first breakpoint,
+// since scheduling orders other goroutines differently from run to run.
+//
+// Breakpoints are set on the functions of the next entries in batches; a
+// stop in a function of the batch other than the one expected next is a
+// divergence. Entries of functions Delve can't set a breakpoint on, such as
+// inlined ones, are skipped. Breakpoints are cleared before returning.
+func VerifyRecording(d *DelveDebugger, events []recorder.Event, opts VerifyOptions) (*VerifyResult, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultVerifyOptions().BatchSize
+	}
+	result := &VerifyResult{}
+
+	var entries []int
+	goroutine := -1
+	for i, e := range events {
+		if e.Type != recorder.FuncEntry || e.FuncName == "" {
+			continue
+		}
+		if goroutine < 0 {
+			goroutine = e.GoroutineID
+		}
+		if e.GoroutineID == goroutine || e.GoroutineID == 0 {
+			entries = append(entries, i)
+		}
+	}
+
+	breakpoints := make(map[string]int) // Function to breakpoint ID
+	functions := make(map[int]string)   // Breakpoint ID to function
+	unsettable := make(map[string]bool)
+	defer func() {
+		for id := range functions {
+			d.ClearBreakpoint(id)
+		}
+	}()
+
+	var liveGoroutine int64 = -1
+	previous := -1
+	for pos := 0; pos < len(entries); pos++ {
+		if opts.MaxEntries > 0 && result.Total >= opts.MaxEntries {
+			break
+		}
+		expected := events[entries[pos]]
+		if unsettable[expected.FuncName] {
+			continue
+		}
+
+		// Cover the functions of the next entries, dropping the rest
+		if _, ok := breakpoints[expected.FuncName]; !ok {
+			batch := make(map[string]bool)
+			for i := pos; i < len(entries) && len(batch) < opts.BatchSize; i++ {
+				batch[events[entries[i]].FuncName] = true
+			}
+			for fn, id := range breakpoints {
+				if !batch[fn] {
+					if err := d.ClearBreakpoint(id); err != nil {
+						return nil, fmt.Errorf("failed to clear breakpoint on %s: %v", fn, err)
+					}
+					delete(breakpoints, fn)
+					delete(functions, id)
+				}
+			}
+			for fn := range batch {
+				if _, ok := breakpoints[fn]; ok || unsettable[fn] {
+					continue
+				}
+				bp, err := d.SetFunctionBreakpoint(fn)
+				if err != nil {
+					unsettable[fn] = true
+					result.Skipped = append(result.Skipped, fn)
+					continue
+				}
+				breakpoints[fn] = bp.ID
+				functions[bp.ID] = fn
+			}
+			if unsettable[expected.FuncName] {
+				continue
+			}
+		}
+		result.Total++
+
+		live, thread, err := continueToFunction(d, functions, &liveGoroutine)
+		if err != nil {
+			return nil, err
+		}
+		if live != expected.FuncName {
+			divergence := &Divergence{EventIdx: entries[pos], Expected: expected, Previous: previous, Live: live}
+			if thread != nil {
+				divergence.LiveFile, divergence.LiveLine = thread.File, thread.Line
+			}
+			result.Divergence = divergence
+			return result, nil
+		}
+		result.Checked++
+		previous = entries[pos]
+	}
+	return result, nil
+}
+
+// continueToFunction continues to the next stop at one of the breakpoints
+// in functions on the live goroutine being followed, which is the first
+// one to stop, and returns the function stopped in, or "" if the program
+// exited
+func continueToFunction(d *DelveDebugger, functions map[int]string, liveGoroutine *int64) (string, *api.Thread, error) {
+	for {
+		state, err := d.Continue()
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to continue: %v", err)
+		}
+		if state.Exited || state.CurrentThread == nil {
+			return "", nil, nil
+		}
+		thread := state.CurrentThread
+		if *liveGoroutine < 0 {
+			*liveGoroutine = thread.GoroutineID
+		}
+		if thread.GoroutineID != *liveGoroutine || thread.Breakpoint == nil {
+			continue
+		}
+		if fn, ok := functions[thread.Breakpoint.ID]; ok {
+			return fn, thread, nil
+		}
+	}
+}
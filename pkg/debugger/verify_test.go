@@ -0,0 +1,115 @@
+package debugger
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+	"github.com/willibrandon/ChronoGo/pkg/testutil"
+)
+
+// entries returns a FuncEntry event for each function, on goroutine 1
+func entries(funcs ...string) []recorder.Event {
+	var events []recorder.Event
+	for i, fn := range funcs {
+		events = append(events, recorder.Event{ID: int64(i + 1), Type: recorder.FuncEntry, FuncName: fn, GoroutineID: 1})
+	}
+	return events
+}
+
+func TestVerifyRecording(t *testing.T) {
+	recorded := entries("main.main", "main.load", "main.parse", "main.load")
+	// Exits and other goroutines aren't checked
+	recorded = append(recorded,
+		recorder.Event{ID: 10, Type: recorder.FuncExit, FuncName: "main.load", GoroutineID: 1},
+		recorder.Event{ID: 11, Type: recorder.FuncEntry, FuncName: "main.worker", GoroutineID: 2})
+
+	tests := []struct {
+		name       string
+		live       []string
+		opts       VerifyOptions
+		invalid    string
+		checked    int
+		total      int
+		skipped    []string
+		divergence *Divergence
+	}{
+		{
+			name:    "same order",
+			live:    []string{"main.main", "main.helper", "main.load", "main.parse", "main.load"},
+			opts:    DefaultVerifyOptions(),
+			checked: 4, total: 4,
+		},
+		{
+			name:    "small batches",
+			live:    []string{"main.main", "main.load", "main.parse", "main.load"},
+			opts:    VerifyOptions{BatchSize: 1},
+			checked: 4, total: 4,
+		},
+		{
+			name:    "different order",
+			live:    []string{"main.main", "main.parse", "main.load"},
+			opts:    DefaultVerifyOptions(),
+			checked: 1, total: 2,
+			divergence: &Divergence{EventIdx: 1, Previous: 0, Live: "main.parse"},
+		},
+		{
+			name:    "exited early",
+			live:    []string{"main.main", "main.load"},
+			opts:    DefaultVerifyOptions(),
+			checked: 2, total: 3,
+			divergence: &Divergence{EventIdx: 2, Previous: 1},
+		},
+		{
+			name:    "capped",
+			live:    []string{"main.main", "main.load"},
+			opts:    VerifyOptions{MaxEntries: 2},
+			checked: 2, total: 2,
+		},
+		{
+			name:    "no breakpoint possible",
+			live:    []string{"main.main", "main.load", "main.load"},
+			opts:    DefaultVerifyOptions(),
+			invalid: "main.parse",
+			checked: 3, total: 3,
+			skipped: []string{"main.parse"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := testutil.NewFakeDelveClient()
+			client.Entered = tt.live
+			if tt.invalid != "" {
+				// Nor with the prefixes SetFunctionBreakpoint retries with
+				for _, prefix := range []string{"", "runtime.", "github.com/"} {
+					client.InvalidLocations[prefix+tt.invalid] = true
+				}
+			}
+			d := NewDelveDebuggerWithClient("prog", client)
+
+			result, err := VerifyRecording(d, recorded, tt.opts)
+			if err != nil {
+				t.Fatalf("VerifyRecording failed: %v", err)
+			}
+			if result.Checked != tt.checked || result.Total != tt.total || !reflect.DeepEqual(result.Skipped, tt.skipped) {
+				t.Errorf("checked %d of %d, skipped %v, want %d of %d, skipped %v",
+					result.Checked, result.Total, result.Skipped, tt.checked, tt.total, tt.skipped)
+			}
+			switch {
+			case tt.divergence == nil && result.Divergence != nil:
+				t.Errorf("unexpected divergence:\n%s", result.Divergence)
+			case tt.divergence != nil && result.Divergence == nil:
+				t.Errorf("expected a divergence at event %d", tt.divergence.EventIdx)
+			case tt.divergence != nil:
+				got := result.Divergence
+				if got.EventIdx != tt.divergence.EventIdx || got.Previous != tt.divergence.Previous || got.Live != tt.divergence.Live {
+					t.Errorf("divergence at %d after %d entering %q, want %d after %d entering %q",
+						got.EventIdx, got.Previous, got.Live, tt.divergence.EventIdx, tt.divergence.Previous, tt.divergence.Live)
+				}
+			}
+			if len(client.Breakpoints) != 0 {
+				t.Errorf("%d breakpoints left behind", len(client.Breakpoints))
+			}
+		})
+	}
+}
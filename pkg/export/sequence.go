@@ -0,0 +1,218 @@
+// Package export renders recordings in formats made for other tools
+package export
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+// MermaidSequence is the format name of WriteMermaidSequence
+const MermaidSequence = "mermaid-sequence"
+
+// maxLabelLength bounds the values shown on diagram arrows
+const maxLabelLength = 40
+
+// Message is a value passed over a channel, from the goroutine that sent it
+// to the one that received it. Sends and receives are matched in order per
+// channel, as channels deliver them. A send that was never received, or a
+// receive whose send wasn't recorded, is lost: its other side is -1.
+type Message struct {
+	Channel  int
+	From     int    // Sending goroutine, -1 if the send wasn't recorded
+	To       int    // Receiving goroutine, -1 if it was never received
+	Send     int    // Index of the send event, -1 if not recorded
+	Received int    // Index of the receive event, -1 if never received
+	Value    string // Value as recorded, empty if it wasn't
+}
+
+// Lost reports whether only one side of the message was recorded
+func (m Message) Lost() bool {
+	return m.Send < 0 || m.Received < 0
+}
+
+// channelOp is a parsed channel event
+type channelOp struct {
+	channel   int
+	goroutine int
+	kind      string // "send", "receive" or "closed"
+	value     string
+}
+
+// parseChannelOp parses the details of a ChannelOperation event, e.g.
+// "Channel 1: send by goroutine 2, value: 42"
+func parseChannelOp(details string) (channelOp, bool) {
+	var op channelOp
+	if _, err := fmt.Sscanf(details, "Channel %d: %s by goroutine %d", &op.channel, &op.kind, &op.goroutine); err != nil {
+		return op, false
+	}
+	if _, value, ok := strings.Cut(details, ", value: "); ok {
+		op.value = value
+	}
+	return op, op.kind == "send" || op.kind == "receive" || op.kind == "closed"
+}
+
+// parseMutexOp parses the details of a mutex SyncOperation event, e.g.
+// "Mutex 1: locked by goroutine 2"
+func parseMutexOp(details string) (mutex, goroutine int, action string, ok bool) {
+	if _, err := fmt.Sscanf(details, "Mutex %d: %s by goroutine %d", &mutex, &action, &goroutine); err != nil {
+		return 0, 0, "", false
+	}
+	return mutex, goroutine, action, action == "locked" || action == "unlocked"
+}
+
+// MatchMessages pairs the channel sends and receives of events, given in
+// replay order, into messages. The result is ordered by where each message
+// appears in a diagram: at its receive, or at its send if it was lost.
+func MatchMessages(events []recorder.Event) []Message {
+	var messages []Message
+	pending := make(map[int][]int) // Unreceived messages of each channel, oldest first
+	for i, e := range events {
+		if e.Type != recorder.ChannelOperation {
+			continue
+		}
+		op, ok := parseChannelOp(e.Details)
+		if !ok {
+			continue
+		}
+		switch op.kind {
+		case "send":
+			pending[op.channel] = append(pending[op.channel], len(messages))
+			messages = append(messages, Message{Channel: op.channel, From: op.goroutine, To: -1, Send: i, Received: -1, Value: op.value})
+		case "receive":
+			if queue := pending[op.channel]; len(queue) > 0 {
+				m := &messages[queue[0]]
+				m.To, m.Received = op.goroutine, i
+				if m.Value == "" {
+					m.Value = op.value
+				}
+				pending[op.channel] = queue[1:]
+			} else {
+				messages = append(messages, Message{Channel: op.channel, From: -1, To: op.goroutine, Send: -1, Received: i, Value: op.value})
+			}
+		}
+	}
+
+	sort.SliceStable(messages, func(i, j int) bool { return messages[i].position() < messages[j].position() })
+	return messages
+}
+
+// position is the index of the event a message is drawn at
+func (m Message) position() int {
+	if m.Received >= 0 {
+		return m.Received
+	}
+	return m.Send
+}
+
+// SequenceOptions limits a sequence diagram to a range of events, to keep
+// it readable
+type SequenceOptions struct {
+	From int // Index of the first event drawn
+	To   int // Index of the last event drawn, -1 for the end of the recording
+}
+
+// DefaultSequenceOptions returns options that draw the whole recording
+func DefaultSequenceOptions() SequenceOptions {
+	return SequenceOptions{From: 0, To: -1}
+}
+
+// WriteMermaidSequence writes a Mermaid sequence diagram of the goroutines
+// in events, given in replay order. Goroutines are participants, channel
+// messages are arrows from sender to receiver, drawn when received, and
+// mutex operations and channel closes are notes. Lost messages are crossed
+// arrows back to the goroutine that sent or received them. Messages are
+// matched over the whole recording, so one sent before opts.From and
+// received within the range is drawn whole.
+func WriteMermaidSequence(w io.Writer, events []recorder.Event, opts SequenceOptions) error {
+	to := opts.To
+	if to < 0 || to >= len(events) {
+		to = len(events) - 1
+	}
+	inRange := func(i int) bool { return i >= opts.From && i <= to }
+
+	// Diagram lines by the event index they are drawn at
+	type step struct {
+		index int
+		line  string
+	}
+	var steps []step
+	participants := make(map[int]bool)
+	for _, m := range MatchMessages(events) {
+		if !inRange(m.position()) {
+			continue
+		}
+		label := fmt.Sprintf("ch %d", m.Channel)
+		if m.Value != "" {
+			label += ": " + m.Value
+		}
+		var line string
+		switch {
+		case m.Received < 0:
+			line = fmt.Sprintf("G%d-xG%d: %s (never received)", m.From, m.From, escapeLabel(label))
+			participants[m.From] = true
+		case m.Send < 0:
+			line = fmt.Sprintf("G%d-xG%d: %s (send not recorded)", m.To, m.To, escapeLabel(label))
+			participants[m.To] = true
+		default:
+			line = fmt.Sprintf("G%d->>G%d: %s", m.From, m.To, escapeLabel(label))
+			participants[m.From] = true
+			participants[m.To] = true
+		}
+		steps = append(steps, step{m.position(), line})
+	}
+
+	for i := opts.From; i <= to && i < len(events); i++ {
+		e := events[i]
+		switch e.Type {
+		case recorder.SyncOperation:
+			if mutex, g, action, ok := parseMutexOp(e.Details); ok {
+				verb := "lock"
+				if action == "unlocked" {
+					verb = "unlock"
+				}
+				steps = append(steps, step{i, fmt.Sprintf("Note over G%d: %s mutex %d", g, verb, mutex)})
+				participants[g] = true
+			}
+		case recorder.ChannelOperation:
+			if op, ok := parseChannelOp(e.Details); ok && op.kind == "closed" {
+				steps = append(steps, step{i, fmt.Sprintf("Note over G%d: close ch %d", op.goroutine, op.channel)})
+				participants[op.goroutine] = true
+			}
+		}
+	}
+	sort.SliceStable(steps, func(i, j int) bool { return steps[i].index < steps[j].index })
+
+	ids := make([]int, 0, len(participants))
+	for g := range participants {
+		ids = append(ids, g)
+	}
+	sort.Ints(ids)
+
+	var b strings.Builder
+	b.WriteString("sequenceDiagram\n")
+	for _, g := range ids {
+		fmt.Fprintf(&b, "    participant G%d as goroutine %d\n", g, g)
+	}
+	for _, s := range steps {
+		fmt.Fprintf(&b, "    %s\n", s.line)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// escapeLabel makes recorded text safe in a Mermaid message, where # starts
+// an entity code and ; ends a statement, and shortens long values
+func escapeLabel(label string) string {
+	label = strings.Join(strings.Fields(label), " ")
+	if len(label) > maxLabelLength {
+		label = label[:maxLabelLength-3] + "..."
+	}
+	return labelEscaper.Replace(label)
+}
+
+// labelEscaper replaces the characters Mermaid gives a meaning in messages
+var labelEscaper = strings.NewReplacer("#", "#35;", ";", "#59;")
@@ -0,0 +1,135 @@
+package export
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+// concurrencyEvents are the concurrency events TestConcurrencyEvents in the
+// replay package replays, with a value sent and a second, lost, send
+func concurrencyEvents() []recorder.Event {
+	base := time.Now()
+	details := []struct {
+		t       recorder.EventType
+		details string
+	}{
+		{recorder.GoroutineSwitch, "Goroutine 2 created"},
+		{recorder.GoroutineSwitch, "Goroutine switch from 1 to 2"},
+		{recorder.ChannelOperation, "Channel 1: send by goroutine 2, value: 42"},
+		{recorder.GoroutineSwitch, "Goroutine switch from 2 to 1"},
+		{recorder.ChannelOperation, "Channel 1: receive by goroutine 1, value: 42"},
+		{recorder.SyncOperation, "Mutex 1: locked by goroutine 1"},
+		{recorder.SyncOperation, "Mutex 1: unlocked by goroutine 1"},
+		{recorder.ChannelOperation, "Channel 1: send by goroutine 2, value: 43"},
+		{recorder.ChannelOperation, "Channel 1: closed by goroutine 2"},
+	}
+	events := make([]recorder.Event, len(details))
+	for i, d := range details {
+		events[i] = recorder.Event{ID: int64(i + 1), Timestamp: base.Add(time.Duration(i) * time.Millisecond), Type: d.t, Details: d.details}
+	}
+	return events
+}
+
+func TestMatchMessages(t *testing.T) {
+	events := append(concurrencyEvents(), recorder.Event{Type: recorder.ChannelOperation, Details: "Channel 2: receive by goroutine 3"})
+	messages := MatchMessages(events)
+
+	want := []Message{
+		{Channel: 1, From: 2, To: 1, Send: 2, Received: 4, Value: "42"},
+		{Channel: 1, From: 2, To: -1, Send: 7, Received: -1, Value: "43"},
+		{Channel: 2, From: -1, To: 3, Send: -1, Received: 9},
+	}
+	if len(messages) != len(want) {
+		t.Fatalf("Expected %d messages, got %+v", len(want), messages)
+	}
+	for i := range want {
+		if messages[i] != want[i] {
+			t.Errorf("Message %d: expected %+v, got %+v", i, want[i], messages[i])
+		}
+	}
+	if messages[0].Lost() || !messages[1].Lost() || !messages[2].Lost() {
+		t.Error("Expected only the unmatched messages to be lost")
+	}
+}
+
+func TestMatchMessagesFIFO(t *testing.T) {
+	// A buffered channel delivers in send order, whoever receives
+	var events []recorder.Event
+	for _, d := range []string{
+		"Channel 1: send by goroutine 1, value: a",
+		"Channel 1: send by goroutine 2, value: b",
+		"Channel 1: receive by goroutine 3",
+		"Channel 1: receive by goroutine 4",
+	} {
+		events = append(events, recorder.Event{Type: recorder.ChannelOperation, Details: d})
+	}
+	messages := MatchMessages(events)
+	if len(messages) != 2 || messages[0].From != 1 || messages[0].To != 3 || messages[0].Value != "a" ||
+		messages[1].From != 2 || messages[1].To != 4 || messages[1].Value != "b" {
+		t.Errorf("Expected sends matched to receives in order, got %+v", messages)
+	}
+}
+
+// mermaidLine matches the statements WriteMermaidSequence writes, following
+// Mermaid's sequence diagram grammar
+var mermaidLine = regexp.MustCompile(`^(sequenceDiagram|    participant G\d+ as goroutine \d+|    G\d+(->>|-x)G\d+: [^;\n]+|    Note over G\d+: [^;\n]+)$`)
+
+func TestWriteMermaidSequence(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteMermaidSequence(&buf, concurrencyEvents(), DefaultSequenceOptions()); err != nil {
+		t.Fatalf("WriteMermaidSequence failed: %v", err)
+	}
+
+	want := `sequenceDiagram
+    participant G1 as goroutine 1
+    participant G2 as goroutine 2
+    G2->>G1: ch 1: 42
+    Note over G1: lock mutex 1
+    Note over G1: unlock mutex 1
+    G2-xG2: ch 1: 43 (never received)
+    Note over G2: close ch 1
+`
+	if buf.String() != want {
+		t.Errorf("Unexpected diagram:\n%s\nwant:\n%s", buf.String(), want)
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		if !mermaidLine.MatchString(line) {
+			t.Errorf("Not a Mermaid statement: %q", line)
+		}
+	}
+}
+
+func TestWriteMermaidSequenceRange(t *testing.T) {
+	// The message received at 4 is drawn whole though sent before the range
+	var buf bytes.Buffer
+	if err := WriteMermaidSequence(&buf, concurrencyEvents(), SequenceOptions{From: 3, To: 5}); err != nil {
+		t.Fatalf("WriteMermaidSequence failed: %v", err)
+	}
+	want := `sequenceDiagram
+    participant G1 as goroutine 1
+    participant G2 as goroutine 2
+    G2->>G1: ch 1: 42
+    Note over G1: lock mutex 1
+`
+	if buf.String() != want {
+		t.Errorf("Unexpected diagram:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestEscapeLabel(t *testing.T) {
+	for label, want := range map[string]string{
+		"ch 1: a;b":                        "ch 1: a#59;b",
+		"ch 1: #1":                         "ch 1: #35;1",
+		"ch 1: multi\nline":                "ch 1: multi line",
+		"ch 1: " + strings.Repeat("x", 60): "ch 1: " + strings.Repeat("x", 31) + "...",
+	} {
+		if got := escapeLabel(label); got != want {
+			t.Errorf("escapeLabel(%q) = %q, want %q", label, got, want)
+		}
+	}
+}
@@ -0,0 +1,323 @@
+// Package importer converts the output of other tools into recordings, so
+// programs that weren't instrumented can still be navigated in replay
+package importer
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+// JSONLines is the format name of ImportJSONLines: one JSON object per line
+const JSONLines = "json-lines"
+
+// Event fields a log field can be mapped to
+const (
+	FieldTimestamp = "timestamp"
+	FieldDetails   = "details"
+	FieldFuncName  = "funcName"
+	FieldFile      = "file"
+	FieldLine      = "line"
+	FieldGoroutine = "goroutine"
+	FieldTrace     = "trace"
+	FieldLevel     = "level" // Not stored, but picks the event type
+)
+
+var mappableFields = []string{FieldTimestamp, FieldDetails, FieldFuncName, FieldFile, FieldLine, FieldGoroutine, FieldTrace, FieldLevel}
+
+// timestampLayouts are the layouts tried for string timestamps, in order
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999Z0700",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006/01/02 15:04:05.999999999",
+	time.RFC1123Z,
+}
+
+// LogMapping says which log record fields become which event fields
+type LogMapping struct {
+	// Fields maps event fields to dotted paths into a record, e.g.
+	// details=msg or trace=request.id
+	Fields map[string]string
+	// LevelTypes maps the lower-cased level of a record to the type of
+	// its event; records of other levels get DefaultType
+	LevelTypes  map[string]recorder.EventType
+	DefaultType recorder.EventType
+}
+
+// DefaultLogMapping returns the mapping for log/slog's JSON handler, which
+// logrus and many others share: time, msg and level, with every record a
+// StatementExecution except errors
+func DefaultLogMapping() LogMapping {
+	return LogMapping{
+		Fields: map[string]string{
+			FieldTimestamp: "time",
+			FieldDetails:   "msg",
+			FieldLevel:     "level",
+		},
+		LevelTypes:  map[string]recorder.EventType{"error": recorder.ErrorEvent},
+		DefaultType: recorder.StatementExecution,
+	}
+}
+
+// ParseFieldMap parses comma-separated event=path pairs, such as
+// funcName=caller,details=msg,timestamp=ts, into the mapping's Fields.
+// Fields not named keep their current mapping.
+func (m *LogMapping) ParseFieldMap(spec string) error {
+	for _, pair := range strings.Split(spec, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		field, path, ok := strings.Cut(pair, "=")
+		field, path = strings.TrimSpace(field), strings.TrimSpace(path)
+		if !ok || path == "" {
+			return fmt.Errorf("invalid field mapping %q, expected <event field>=<log field>", pair)
+		}
+		if !isMappable(field) {
+			return fmt.Errorf("unknown event field %q, use one of %s", field, strings.Join(mappableFields, ", "))
+		}
+		m.Fields[field] = path
+	}
+	return nil
+}
+
+// ParseLevelTypes parses comma-separated level=EventType pairs, such as
+// warn=ErrorEvent,debug=StatementExecution, into the mapping's LevelTypes
+func (m *LogMapping) ParseLevelTypes(spec string) error {
+	for _, pair := range strings.Split(spec, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		level, name, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("invalid level type %q, expected <level>=<event type>", pair)
+		}
+		t, ok := recorder.ParseEventType(strings.TrimSpace(name))
+		if !ok {
+			return fmt.Errorf("unknown event type: %s", name)
+		}
+		m.LevelTypes[strings.ToLower(strings.TrimSpace(level))] = t
+	}
+	return nil
+}
+
+func isMappable(field string) bool {
+	for _, f := range mappableFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// SkippedRecord is a log line that couldn't be turned into an event
+type SkippedRecord struct {
+	Line   int // Line number in the log, from 1
+	Reason string
+}
+
+// ImportResult is what ImportJSONLines made of a log
+type ImportResult struct {
+	Events  []recorder.Event
+	Records int // Non-blank lines read
+	Skipped []SkippedRecord
+}
+
+// ImportJSONLines converts a log of one JSON object per line into events,
+// with sequential IDs from 1 in log order. A record is skipped if it isn't
+// a JSON object or its timestamp is missing or can't be parsed. Timestamps
+// may be strings in RFC 3339 and a few other common layouts, or numbers of
+// seconds, milliseconds, microseconds or nanoseconds since the Unix epoch,
+// told apart by magnitude. A numeric goroutine is used as is; other values
+// are numbered in order of appearance. A file of the form path:line sets
+// the line too, unless line is mapped.
+func ImportJSONLines(r io.Reader, mapping LogMapping) (*ImportResult, error) {
+	result := &ImportResult{}
+	goroutines := make(map[string]int)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		result.Records++
+
+		var record map[string]any
+		decoder := json.NewDecoder(strings.NewReader(line))
+		decoder.UseNumber()
+		if err := decoder.Decode(&record); err != nil {
+			result.Skipped = append(result.Skipped, SkippedRecord{lineNo, "not a JSON object"})
+			continue
+		}
+
+		path := mapping.Fields[FieldTimestamp]
+		raw, ok := lookup(record, path)
+		if !ok {
+			result.Skipped = append(result.Skipped, SkippedRecord{lineNo, fmt.Sprintf("no timestamp field %q", path)})
+			continue
+		}
+		ts, err := parseTimestamp(raw)
+		if err != nil {
+			result.Skipped = append(result.Skipped, SkippedRecord{lineNo, err.Error()})
+			continue
+		}
+
+		e := recorder.Event{
+			ID:        int64(len(result.Events) + 1),
+			Timestamp: ts,
+			Type:      mapping.DefaultType,
+			Details:   field(record, mapping.Fields[FieldDetails]),
+			FuncName:  field(record, mapping.Fields[FieldFuncName]),
+			File:      field(record, mapping.Fields[FieldFile]),
+			TraceID:   field(record, mapping.Fields[FieldTrace]),
+		}
+		if level := field(record, mapping.Fields[FieldLevel]); level != "" {
+			if t, ok := mapping.LevelTypes[strings.ToLower(level)]; ok {
+				e.Type = t
+			}
+		}
+		if mapping.Fields[FieldLine] != "" {
+			e.Line, _ = strconv.Atoi(field(record, mapping.Fields[FieldLine]))
+		} else if i := strings.LastIndex(e.File, ":"); i > 0 {
+			if n, err := strconv.Atoi(e.File[i+1:]); err == nil {
+				e.File, e.Line = e.File[:i], n
+			}
+		}
+		if g := field(record, mapping.Fields[FieldGoroutine]); g != "" {
+			if n, err := strconv.Atoi(g); err == nil {
+				e.GoroutineID = n
+			} else {
+				if _, seen := goroutines[g]; !seen {
+					goroutines[g] = len(goroutines) + 1
+				}
+				e.GoroutineID = goroutines[g]
+			}
+		}
+		result.Events = append(result.Events, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read log: %v", err)
+	}
+	return result, nil
+}
+
+// lookup returns the value at a dotted path into a record, e.g. request.id.
+// A key containing dots is matched whole before being split.
+func lookup(record map[string]any, path string) (any, bool) {
+	if path == "" {
+		return nil, false
+	}
+	if v, ok := record[path]; ok {
+		return v, true
+	}
+	var current any = record
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := current.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if current, ok = obj[key]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// field returns the value at a dotted path as a string, empty if missing.
+// Objects and arrays are returned as JSON.
+func field(record map[string]any, path string) string {
+	v, ok := lookup(record, path)
+	if !ok || v == nil {
+		return ""
+	}
+	switch v := v.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case map[string]any, []any:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+	return fmt.Sprint(v)
+}
+
+// parseTimestamp parses a string timestamp in one of timestampLayouts or a
+// numeric Unix time
+func parseTimestamp(v any) (time.Time, error) {
+	switch v := v.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid numeric timestamp")
+		}
+		return unixTime(f), nil
+	case string:
+		for _, layout := range timestampLayouts {
+			if ts, err := time.Parse(layout, v); err == nil {
+				return ts, nil
+			}
+		}
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return unixTime(f), nil
+		}
+		return time.Time{}, fmt.Errorf("unrecognized timestamp layout")
+	}
+	return time.Time{}, fmt.Errorf("timestamp is neither a string nor a number")
+}
+
+// unixTime converts a Unix time in seconds, milliseconds, microseconds or
+// nanoseconds, whichever puts it between 1973 and 5138, to a time
+func unixTime(f float64) time.Time {
+	abs := math.Abs(f)
+	switch {
+	case abs >= 1e17:
+		return time.Unix(0, int64(f))
+	case abs >= 1e14:
+		return time.UnixMicro(int64(f))
+	case abs >= 1e11:
+		return time.UnixMilli(int64(f))
+	}
+	sec, frac := math.Modf(f)
+	return time.Unix(int64(sec), int64(math.Round(frac*1e9)))
+}
+
+// SkipReasons counts skipped records by reason, most common first, with
+// the first line skipped for each, e.g. "3 not a JSON object (first at line 7)"
+func (r *ImportResult) SkipReasons() []string {
+	counts := make(map[string]int)
+	first := make(map[string]int)
+	for _, s := range r.Skipped {
+		if counts[s.Reason] == 0 {
+			first[s.Reason] = s.Line
+		}
+		counts[s.Reason]++
+	}
+	reasons := make([]string, 0, len(counts))
+	for reason := range counts {
+		reasons = append(reasons, reason)
+	}
+	sort.Slice(reasons, func(i, j int) bool {
+		if counts[reasons[i]] != counts[reasons[j]] {
+			return counts[reasons[i]] > counts[reasons[j]]
+		}
+		return reasons[i] < reasons[j]
+	})
+	for i, reason := range reasons {
+		reasons[i] = fmt.Sprintf("%d %s (first at line %d)", counts[reason], reason, first[reason])
+	}
+	return reasons
+}
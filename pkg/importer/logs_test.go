@@ -0,0 +1,80 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+func TestImportJSONLines(t *testing.T) {
+	log := strings.Join([]string{
+		`{"ts":1760600000.5,"level":"info","msg":"start","caller":"main.go:10","req":{"id":"r1"},"worker":"a"}`,
+		`not json`,
+		``,
+		`{"ts":"2026-10-16 06:00:01.250","level":"ERROR","msg":"boom","caller":"main.go:20","req":{"id":"r1"},"worker":"b"}`,
+		`{"ts":"yesterday","msg":"when?"}`,
+		`{"msg":"no time"}`,
+		`{"ts":1760600002000,"level":"warn","msg":"slow","req.id":"r2","worker":7}`,
+	}, "\n")
+
+	mapping := DefaultLogMapping()
+	if err := mapping.ParseFieldMap("timestamp=ts, file=caller,trace=req.id,goroutine=worker"); err != nil {
+		t.Fatal(err)
+	}
+	if err := mapping.ParseLevelTypes("warn=SelectEvent"); err != nil {
+		t.Fatal(err)
+	}
+	result, err := ImportJSONLines(strings.NewReader(log), mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Records != 6 || len(result.Events) != 3 || len(result.Skipped) != 3 {
+		t.Fatalf("records=%d events=%d skipped=%d, want 6, 3 and 3", result.Records, len(result.Events), len(result.Skipped))
+	}
+	want := []recorder.Event{
+		{ID: 1, Timestamp: time.Unix(1760600000, 5e8), Type: recorder.StatementExecution, Details: "start", File: "main.go", Line: 10, TraceID: "r1", GoroutineID: 1},
+		{ID: 2, Timestamp: time.Date(2026, 10, 16, 6, 0, 1, 25e7, time.UTC), Type: recorder.ErrorEvent, Details: "boom", File: "main.go", Line: 20, TraceID: "r1", GoroutineID: 2},
+		{ID: 3, Timestamp: time.UnixMilli(1760600002000), Type: recorder.SelectEvent, Details: "slow", TraceID: "r2", GoroutineID: 7},
+	}
+	for i, e := range result.Events {
+		w := want[i]
+		if e.ID != w.ID || !e.Timestamp.Equal(w.Timestamp) || e.Type != w.Type || e.Details != w.Details ||
+			e.File != w.File || e.Line != w.Line || e.TraceID != w.TraceID || e.GoroutineID != w.GoroutineID {
+			t.Errorf("event %d = %+v, want %+v", i, e, w)
+		}
+	}
+
+	reasons := result.SkipReasons()
+	wantReasons := []string{
+		`1 no timestamp field "ts" (first at line 6)`,
+		"1 not a JSON object (first at line 2)",
+		"1 unrecognized timestamp layout (first at line 5)",
+	}
+	if strings.Join(reasons, "\n") != strings.Join(wantReasons, "\n") {
+		t.Errorf("SkipReasons() = %q, want %q", reasons, wantReasons)
+	}
+}
+
+func TestParseFieldMap(t *testing.T) {
+	mapping := DefaultLogMapping()
+	for _, spec := range []string{"details", "details=", "severity=lvl"} {
+		if err := mapping.ParseFieldMap(spec); err == nil {
+			t.Errorf("ParseFieldMap(%q) succeeded, want an error", spec)
+		}
+	}
+	if err := mapping.ParseLevelTypes("warn=Bogus"); err == nil {
+		t.Error("ParseLevelTypes accepted an unknown event type")
+	}
+}
+
+func TestUnixTime(t *testing.T) {
+	want := time.Unix(1760600000, 0)
+	for _, v := range []float64{1760600000, 1760600000e3, 1760600000e6, 1760600000e9} {
+		if got := unixTime(v); !got.Equal(want) {
+			t.Errorf("unixTime(%v) = %v, want %v", v, got, want)
+		}
+	}
+}
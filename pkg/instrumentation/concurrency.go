@@ -41,6 +41,8 @@ func GoroutineSwitch(fromID, toID int) {
 			Timestamp: time.Now(),
 			Type:      recorder.GoroutineSwitch,
 			Details:   fmt.Sprintf("Goroutine switch from %d to %d", fromID, toID),
+			// Events after a switch belong to the goroutine switched to
+			GoroutineID: toID,
 		})
 		if err != nil {
 			fmt.Printf("Error recording goroutine switch: %v\n", err)
@@ -148,10 +150,10 @@ func MutexUnlock(mutexID, goroutineID int) {
 	}
 }
 
-// shouldInstrumentCaller checks if the caller's package should be instrumented
+// shouldInstrumentCaller checks if the caller's package and file should be instrumented
 func shouldInstrumentCaller() bool {
 	// Skip 2 frames to get the actual caller (not this function or the instrumentation function)
-	pc, _, _, ok := runtime.Caller(2)
+	pc, file, _, ok := runtime.Caller(2)
 	if !ok {
 		// If we can't determine caller, default to instrumenting
 		return true
@@ -164,5 +166,5 @@ func shouldInstrumentCaller() bool {
 
 	fullName := fn.Name()
 	pkgPath := extractPackagePath(fullName)
-	return ShouldInstrument(pkgPath)
+	return ShouldInstrument(pkgPath) && ShouldInstrumentFile(file)
 }
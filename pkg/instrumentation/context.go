@@ -0,0 +1,62 @@
+package instrumentation
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"time"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+// WrapContext records a ContextEvent when ctx is cancelled or its deadline
+// expires, labeled with label and giving the cause, at the location of the
+// WrapContext call. The event is stamped with the goroutine that wrapped
+// ctx. It returns ctx, so it can wrap a context where it is created:
+//
+//	ctx = instrumentation.WrapContext(ctx, "fetch user")
+//
+// Nothing is recorded for a context that can't be cancelled.
+func WrapContext(ctx context.Context, label string) context.Context {
+	wrapped := time.Now()
+	goroutine := currentGoroutineID()
+	event := recorder.Event{Type: recorder.ContextEvent, GoroutineID: goroutine}
+	if pc, file, line, ok := runtime.Caller(1); ok {
+		event.File = file
+		event.Line = line
+		if fn := runtime.FuncForPC(pc); fn != nil {
+			event.FuncName = fn.Name()
+		}
+	}
+
+	// Runs in its own goroutine once ctx is done
+	context.AfterFunc(ctx, func() {
+		if !CurrentOptions.Enabled || globalRecorder == nil {
+			return
+		}
+		now := time.Now()
+		event.ID = now.UnixNano()
+		event.Timestamp = now
+		event.Details = contextDetails(ctx, label, now.Sub(wrapped))
+		if err := globalRecorder.RecordEvent(event); err != nil {
+			fmt.Printf("Error recording context event: %v\n", err)
+		}
+	})
+	return ctx
+}
+
+// contextDetails describes why a done context ended, e.g.
+// `Context "fetch user" deadline exceeded after 2s` or
+// `Context "fetch user" cancelled after 15ms: client went away`
+func contextDetails(ctx context.Context, label string, after time.Duration) string {
+	what := "cancelled"
+	if ctx.Err() == context.DeadlineExceeded {
+		what = "deadline exceeded"
+	}
+	details := fmt.Sprintf("Context %q %s after %v", label, what, after.Round(time.Microsecond))
+	// The cause is only worth showing if it says more than the error
+	if cause := context.Cause(ctx); cause != nil && cause != ctx.Err() {
+		details += ": " + cause.Error()
+	}
+	return details
+}
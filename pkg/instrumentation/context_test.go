@@ -0,0 +1,121 @@
+package instrumentation
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+// lockedRecorder records events from any goroutine, since ContextEvents are
+// recorded from the goroutine watching the context
+type lockedRecorder struct {
+	mu     sync.Mutex
+	events []recorder.Event
+}
+
+func (r *lockedRecorder) RecordEvent(e recorder.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+	return nil
+}
+
+func (r *lockedRecorder) GetEvents() []recorder.Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]recorder.Event(nil), r.events...)
+}
+
+func (r *lockedRecorder) Clear() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = nil
+}
+
+// waitForContextEvents waits for the recording to contain n ContextEvents
+func waitForContextEvents(t *testing.T, rec *lockedRecorder, n int) []recorder.Event {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		var found []recorder.Event
+		for _, e := range rec.GetEvents() {
+			if e.Type == recorder.ContextEvent {
+				found = append(found, e)
+			}
+		}
+		if len(found) >= n {
+			return found
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("fewer than %d ContextEvents recorded within 5s", n)
+	return nil
+}
+
+func TestWrapContextCancelled(t *testing.T) {
+	rec := &lockedRecorder{}
+	InitInstrumentation(rec)
+	t.Cleanup(func() { InitInstrumentation(nil) })
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	ctx = WrapContext(ctx, "fetch user")
+	goroutine := currentGoroutineID()
+
+	rec.RecordEvent(recorder.Event{ID: 1, Timestamp: time.Now(), Type: recorder.StatementExecution, Details: "before"})
+	cancel(errors.New("client went away"))
+	events := waitForContextEvents(t, rec, 1)
+	rec.RecordEvent(recorder.Event{ID: 2, Timestamp: time.Now(), Type: recorder.StatementExecution, Details: "after"})
+
+	e := events[0]
+	if !strings.HasPrefix(e.Details, `Context "fetch user" cancelled after `) || !strings.HasSuffix(e.Details, ": client went away") {
+		t.Errorf("Details = %q, want the label, cancellation and cause", e.Details)
+	}
+	if e.GoroutineID != goroutine {
+		t.Errorf("GoroutineID = %d, want the wrapping goroutine %d", e.GoroutineID, goroutine)
+	}
+	if !strings.HasSuffix(e.File, "context_test.go") || !strings.HasSuffix(e.FuncName, "TestWrapContextCancelled") {
+		t.Errorf("location = %s %s, want the WrapContext call", e.File, e.FuncName)
+	}
+
+	// The cancellation lands between the events around it
+	var order []string
+	for _, e := range rec.GetEvents() {
+		switch e.Type {
+		case recorder.StatementExecution:
+			order = append(order, e.Details)
+		case recorder.ContextEvent:
+			order = append(order, "context")
+		}
+	}
+	if got := strings.Join(order, ","); got != "before,context,after" {
+		t.Errorf("event order = %s, want before,context,after", got)
+	}
+}
+
+func TestWrapContextDeadline(t *testing.T) {
+	rec := &lockedRecorder{}
+	InitInstrumentation(rec)
+	t.Cleanup(func() { InitInstrumentation(nil) })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	WrapContext(ctx, "query")
+
+	// Neither can be cancelled, so neither is recorded
+	WrapContext(context.Background(), "background")
+	WrapContext(context.WithoutCancel(ctx), "detached")
+
+	events := waitForContextEvents(t, rec, 1)
+	time.Sleep(20 * time.Millisecond)
+	if n := len(waitForContextEvents(t, rec, 1)); n != 1 {
+		t.Errorf("recorded %d ContextEvents, want 1", n)
+	}
+	if want := `Context "query" deadline exceeded after `; !strings.HasPrefix(events[0].Details, want) || strings.Contains(events[0].Details, ": ") {
+		t.Errorf("Details = %q, want %q with no separate cause", events[0].Details, want)
+	}
+}
@@ -0,0 +1,46 @@
+package instrumentation
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+// TimeFormat is the layout used to store timestamps in TimeReadEvent details
+const TimeFormat = time.RFC3339Nano
+
+// Now returns the current time and records it as a TimeReadEvent. It is a
+// drop-in replacement for time.Now in instrumented code.
+func Now() time.Time {
+	// Strip the monotonic clock reading so durations computed by the program
+	// match those computed from the recorded wall clock values on replay
+	now := time.Now().Round(0)
+	recordInput(recorder.TimeReadEvent, fmt.Sprintf("Time read %s", now.Format(TimeFormat)))
+	return now
+}
+
+// RecordSelect records that a select statement with n cases chose case choice.
+// Instrumented programs call it from each case so that a re-execution can
+// force the same choice.
+func RecordSelect(choice, n int) {
+	recordInput(recorder.SelectEvent, fmt.Sprintf("Select chose case %d of %d", choice, n))
+}
+
+// recordInput records a nondeterministic input to the program. Unlike other
+// hooks it ignores package filters: every input must be captured for a
+// re-execution to follow the recorded path.
+func recordInput(eventType recorder.EventType, details string) {
+	if !CurrentOptions.Enabled || globalRecorder == nil {
+		return
+	}
+
+	if err := globalRecorder.RecordEvent(recorder.Event{
+		ID:        time.Now().UnixNano(),
+		Timestamp: time.Now(),
+		Type:      eventType,
+		Details:   details,
+	}); err != nil {
+		fmt.Printf("Error recording %s: %v\n", eventType, err)
+	}
+}
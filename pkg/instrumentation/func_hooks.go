@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"runtime"
 	"strings"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"github.com/willibrandon/ChronoGo/pkg/recorder"
 )
@@ -13,7 +15,13 @@ var globalRecorder recorder.Recorder
 
 // InitInstrumentation initializes the instrumentation with a recorder
 func InitInstrumentation(r recorder.Recorder) {
-	globalRecorder = r
+	if r == nil {
+		globalRecorder = nil
+		return
+	}
+	resetRecordingLimits()
+	atomic.StoreInt32(&paused, 0)
+	globalRecorder = &meteredRecorder{Recorder: r}
 }
 
 // FuncEntry records a function entry event
@@ -21,14 +29,16 @@ func FuncEntry(funcName string, file string, line int) {
 	// Special case for tests - always enable instrumentation for functions with "Test" prefix
 	if strings.HasPrefix(funcName, "Test") {
 		if globalRecorder != nil {
+			details, length := truncateDetails(fmt.Sprintf("Entering %s at %s:%d", funcName, file, line))
 			if err := globalRecorder.RecordEvent(recorder.Event{
-				ID:        time.Now().UnixNano(),
-				Timestamp: time.Now(),
-				Type:      recorder.FuncEntry,
-				Details:   fmt.Sprintf("Entering %s at %s:%d", funcName, file, line),
-				File:      file,
-				Line:      line,
-				FuncName:  funcName,
+				ID:         time.Now().UnixNano(),
+				Timestamp:  time.Now(),
+				Type:       recorder.FuncEntry,
+				Details:    details,
+				File:       file,
+				Line:       line,
+				FuncName:   funcName,
+				DetailsLen: length,
 			}); err != nil {
 				fmt.Printf("Error recording function entry event: %v\n", err)
 			}
@@ -36,21 +46,23 @@ func FuncEntry(funcName string, file string, line int) {
 		return
 	}
 
-	// Skip recording if instrumentation is disabled for this package
+	// Skip recording if instrumentation is disabled for this package, function or file
 	pkgPath := getPackagePathFromFunc(funcName)
-	if !ShouldInstrument(pkgPath) {
+	if !ShouldInstrumentFunction(pkgPath, funcName) || !ShouldInstrumentFile(file) {
 		return
 	}
 
 	if globalRecorder != nil {
+		details, length := truncateDetails(fmt.Sprintf("Entering %s at %s:%d", funcName, file, line))
 		if err := globalRecorder.RecordEvent(recorder.Event{
-			ID:        time.Now().UnixNano(),
-			Timestamp: time.Now(),
-			Type:      recorder.FuncEntry,
-			Details:   fmt.Sprintf("Entering %s at %s:%d", funcName, file, line),
-			File:      file,
-			Line:      line,
-			FuncName:  funcName,
+			ID:         time.Now().UnixNano(),
+			Timestamp:  time.Now(),
+			Type:       recorder.FuncEntry,
+			Details:    details,
+			File:       file,
+			Line:       line,
+			FuncName:   funcName,
+			DetailsLen: length,
 		}); err != nil {
 			fmt.Printf("Error recording function entry event: %v\n", err)
 		}
@@ -77,9 +89,9 @@ func FuncExit(funcName string, file string, line int) {
 		return
 	}
 
-	// Skip recording if instrumentation is disabled for this package
+	// Skip recording if instrumentation is disabled for this package, function or file
 	pkgPath := getPackagePathFromFunc(funcName)
-	if !ShouldInstrument(pkgPath) {
+	if !ShouldInstrumentFunction(pkgPath, funcName) || !ShouldInstrumentFile(file) {
 		return
 	}
 
@@ -98,29 +110,238 @@ func FuncExit(funcName string, file string, line int) {
 	}
 }
 
+// DeferEntry records the start of a deferred call. funcName is the function
+// that deferred the call; its deferred calls run while it returns or while a
+// panic unwinds through it.
+func DeferEntry(funcName string, file string, line int) {
+	// Skip recording if instrumentation is disabled for this package, function or file
+	pkgPath := getPackagePathFromFunc(funcName)
+	if !ShouldInstrumentFunction(pkgPath, funcName) || !ShouldInstrumentFile(file) {
+		return
+	}
+
+	recordDeferEvent(funcName, file, line, fmt.Sprintf("Entering deferred call in %s at %s:%d", funcName, file, line), 0)
+}
+
+// DeferExit records the end of a deferred call started with DeferEntry
+func DeferExit(funcName string, file string, line int) {
+	// Skip recording if instrumentation is disabled for this package, function or file
+	pkgPath := getPackagePathFromFunc(funcName)
+	if !ShouldInstrumentFunction(pkgPath, funcName) || !ShouldInstrumentFile(file) {
+		return
+	}
+
+	recordDeferEvent(funcName, file, line, fmt.Sprintf("Exiting deferred call in %s at %s:%d", funcName, file, line), 0)
+}
+
+// Recovered records a deferred call in funcName stopping a panic with recover.
+// Call it only when recover returned a non-nil value.
+func Recovered(funcName string, file string, line int, value interface{}) {
+	// Skip recording if instrumentation is disabled for this package, function or file
+	pkgPath := getPackagePathFromFunc(funcName)
+	if !ShouldInstrumentFunction(pkgPath, funcName) || !ShouldInstrumentFile(file) {
+		return
+	}
+
+	// Always stamped, so replay can tell which panic this recover stopped
+	recordDeferEvent(funcName, file, line, fmt.Sprintf("Recovered in %s: %v", funcName, value), currentGoroutineID())
+}
+
+// recordDeferEvent records a DeferOperation event, flagged as Deferred.
+// goroutineID, if not 0, stamps the event even when stamping is off.
+func recordDeferEvent(funcName string, file string, line int, details string, goroutineID int) {
+	if globalRecorder != nil {
+		if err := globalRecorder.RecordEvent(recorder.Event{
+			ID:          time.Now().UnixNano(),
+			Timestamp:   time.Now(),
+			Type:        recorder.DeferOperation,
+			Details:     details,
+			File:        file,
+			Line:        line,
+			FuncName:    funcName,
+			Deferred:    true,
+			GoroutineID: goroutineID,
+		}); err != nil {
+			fmt.Printf("Error recording defer event: %v\n", err)
+		}
+	}
+}
+
 // RecordStatement can be used to record execution of a specific statement
 func RecordStatement(funcName string, file string, line int, description string) {
-	// Skip recording if instrumentation is disabled for this package
+	// Skip recording if instrumentation is disabled for this package, function or file
 	pkgPath := getPackagePathFromFunc(funcName)
-	if !ShouldInstrument(pkgPath) {
+	if !ShouldInstrumentFunction(pkgPath, funcName) || !ShouldInstrumentFile(file) {
 		return
 	}
 
 	if globalRecorder != nil {
+		details, length := truncateDetails(fmt.Sprintf("Executing statement in %s at %s:%d: %s", funcName, file, line, description))
 		if err := globalRecorder.RecordEvent(recorder.Event{
-			ID:        time.Now().UnixNano(),
-			Timestamp: time.Now(),
-			Type:      recorder.StatementExecution,
-			Details:   fmt.Sprintf("Executing statement in %s at %s:%d: %s", funcName, file, line, description),
-			File:      file,
-			Line:      line,
-			FuncName:  funcName,
+			ID:         time.Now().UnixNano(),
+			Timestamp:  time.Now(),
+			Type:       recorder.StatementExecution,
+			Details:    details,
+			File:       file,
+			Line:       line,
+			FuncName:   funcName,
+			DetailsLen: length,
 		}); err != nil {
 			fmt.Printf("Error recording statement execution event: %v\n", err)
 		}
 	}
 }
 
+// RecordCustomEvent records a domain event, such as a cache miss, of a type
+// registered with recorder.RegisterEventType
+func RecordCustomEvent(eventType recorder.EventType, funcName string, file string, line int, details string) {
+	// Skip recording if instrumentation is disabled for this package, function or file
+	pkgPath := getPackagePathFromFunc(funcName)
+	if !ShouldInstrumentFunction(pkgPath, funcName) || !ShouldInstrumentFile(file) {
+		return
+	}
+
+	if globalRecorder != nil {
+		details, length := truncateDetails(details)
+		if err := globalRecorder.RecordEvent(recorder.Event{
+			ID:         time.Now().UnixNano(),
+			Timestamp:  time.Now(),
+			Type:       eventType,
+			Details:    details,
+			File:       file,
+			Line:       line,
+			FuncName:   funcName,
+			DetailsLen: length,
+		}); err != nil {
+			fmt.Printf("Error recording %s event: %v\n", eventType, err)
+		}
+	}
+}
+
+// RecordError records an error returned or handled in funcName. The details
+// hold the error message followed by its dynamic type, e.g.
+// "Error in main.load: open config.json: no such file (*fs.PathError)".
+// Nil errors are ignored.
+func RecordError(funcName string, file string, line int, err error) {
+	if err == nil {
+		return
+	}
+
+	// Skip recording if instrumentation is disabled for this package, function or file
+	pkgPath := getPackagePathFromFunc(funcName)
+	if !ShouldInstrumentFunction(pkgPath, funcName) || !ShouldInstrumentFile(file) {
+		return
+	}
+
+	recordErrorEvent(funcName, file, line, fmt.Sprintf("Error in %s: %v (%T)", funcName, err, err), 0)
+}
+
+// RecordPanic records a panic in funcName before it is allowed to crash the
+// program, typically from a deferred call that re-panics. The details hold
+// the panic value and its type, e.g. "Panic in main.run: boom (string)".
+func RecordPanic(funcName string, file string, line int, value interface{}) {
+	// Skip recording if instrumentation is disabled for this package, function or file
+	pkgPath := getPackagePathFromFunc(funcName)
+	if !ShouldInstrumentFunction(pkgPath, funcName) || !ShouldInstrumentFile(file) {
+		return
+	}
+
+	// Always stamped, so replay can find the recover that stopped it
+	recordErrorEvent(funcName, file, line, fmt.Sprintf("Panic in %s: %v (%T)", funcName, value, value), currentGoroutineID())
+}
+
+// recordErrorEvent records an ErrorEvent and, if enabled, persists the
+// events leading up to it. goroutineID, if not 0, stamps the event even
+// when stamping is off.
+func recordErrorEvent(funcName string, file string, line int, details string, goroutineID int) {
+	if globalRecorder == nil {
+		return
+	}
+
+	if err := globalRecorder.RecordEvent(recorder.Event{
+		ID:          time.Now().UnixNano(),
+		Timestamp:   time.Now(),
+		Type:        recorder.ErrorEvent,
+		Details:     details,
+		File:        file,
+		Line:        line,
+		FuncName:    funcName,
+		GoroutineID: goroutineID,
+	}); err != nil {
+		fmt.Printf("Error recording error event: %v\n", err)
+	}
+	persistOnFailure()
+}
+
+// detailsEllipsis ends details truncated to MaxDetailsLen
+const detailsEllipsis = "..."
+
+// truncateDetails shortens details to CurrentOptions.MaxDetailsLen bytes,
+// ellipsis included, without splitting a UTF-8 character. It also returns
+// the original length if it truncated, 0 if it didn't.
+func truncateDetails(details string) (string, int) {
+	max := CurrentOptions.MaxDetailsLen
+	if max <= 0 || len(details) <= max {
+		return details, 0
+	}
+
+	cut := max - len(detailsEllipsis)
+	if cut < 0 {
+		cut = 0
+	}
+	for cut > 0 && !utf8.RuneStart(details[cut]) {
+		cut--
+	}
+	return details[:cut] + detailsEllipsis, len(details)
+}
+
+// CallerFuncName returns the name of the function calling it, to pass to
+// FuncEntry and the other hooks. A closure is named after the function
+// enclosing it and its ordinal there, e.g. "main.processData.func2", and a
+// nested closure after each enclosing one, e.g. "main.processData.func2.func1"
+// where the runtime says "main.processData.func2.1". Ordinals follow the
+// order closures appear in the source, so they're stable across runs.
+func CallerFuncName() string {
+	pc, _, _, ok := runtime.Caller(1)
+	if !ok {
+		return ""
+	}
+	fn := runtime.FuncForPC(pc)
+	if fn == nil {
+		return ""
+	}
+	return closureName(fn.Name())
+}
+
+// closureName names the nested closures in a runtime function name like the
+// outermost one, "func" followed by their ordinal
+func closureName(name string) string {
+	prefix := ""
+	if i := strings.LastIndexByte(name, '/'); i >= 0 {
+		prefix, name = name[:i+1], name[i+1:]
+	}
+	parts := strings.Split(name, ".")
+	for i := 1; i < len(parts); i++ {
+		if isOrdinal(parts[i]) {
+			parts[i] = "func" + parts[i]
+		}
+	}
+	return prefix + strings.Join(parts, ".")
+}
+
+// isOrdinal reports whether s is a non-empty string of digits
+func isOrdinal(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
 // getPackagePathFromFunc extracts the package path from a function name
 func getPackagePathFromFunc(funcName string) string {
 	// Function names from the runtime are formatted as: "package.function"
@@ -0,0 +1,296 @@
+package instrumentation
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+func TestDeferEvents(t *testing.T) {
+	rec := recorder.NewInMemoryRecorder()
+	InitInstrumentation(rec)
+	defer InitInstrumentation(nil)
+
+	func() {
+		FuncEntry("instrumentation.guarded", "func_hooks_test.go", 16)
+		defer FuncExit("instrumentation.guarded", "func_hooks_test.go", 17)
+
+		defer func() {
+			DeferEntry("instrumentation.guarded", "func_hooks_test.go", 20)
+			defer DeferExit("instrumentation.guarded", "func_hooks_test.go", 21)
+			if r := recover(); r != nil {
+				Recovered("instrumentation.guarded", "func_hooks_test.go", 23, r)
+			}
+		}()
+
+		panic("boom")
+	}()
+
+	events := rec.GetEvents()
+	expected := []struct {
+		eventType recorder.EventType
+		details   string
+	}{
+		{recorder.FuncEntry, "Entering instrumentation.guarded"},
+		{recorder.DeferOperation, "Entering deferred call in instrumentation.guarded"},
+		{recorder.DeferOperation, "Recovered in instrumentation.guarded: boom"},
+		{recorder.DeferOperation, "Exiting deferred call in instrumentation.guarded"},
+		{recorder.FuncExit, "Exiting instrumentation.guarded"},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("Expected %d events, got %d: %+v", len(expected), len(events), events)
+	}
+	for i, want := range expected {
+		if events[i].Type != want.eventType || !strings.HasPrefix(events[i].Details, want.details) {
+			t.Errorf("Event %d: expected %s %q, got %s %q", i, want.eventType, want.details, events[i].Type, events[i].Details)
+		}
+		if deferred := want.eventType == recorder.DeferOperation; events[i].Deferred != deferred {
+			t.Errorf("Event %d: expected Deferred %v, got %v", i, deferred, events[i].Deferred)
+		}
+	}
+
+	// The recover is stamped even with stamping off, so replay can match it
+	// to its panic
+	if got, want := events[2].GoroutineID, currentGoroutineID(); got != want {
+		t.Errorf("Expected the recover stamped with goroutine %d, got %d", want, got)
+	}
+}
+
+func TestCallerFuncName(t *testing.T) {
+	const prefix = "github.com/willibrandon/ChronoGo/pkg/instrumentation.TestCallerFuncName"
+	if got := CallerFuncName(); got != prefix {
+		t.Errorf("Expected %s, got %s", prefix, got)
+	}
+
+	var outer, nested string
+	func() {
+		outer = CallerFuncName()
+		func() {
+			nested = CallerFuncName()
+		}()
+	}()
+	if outer != prefix+".func1" || nested != prefix+".func1.func1" {
+		t.Errorf("Expected closures named after their enclosing functions, got %s and %s", outer, nested)
+	}
+
+	if got := closureName("example.com/a.b/pkg.(*T).M.func2.3"); got != "example.com/a.b/pkg.(*T).M.func2.func3" {
+		t.Errorf("Unexpected name %s", got)
+	}
+}
+
+func TestRecordError(t *testing.T) {
+	rec := recorder.NewInMemoryRecorder()
+	InitInstrumentation(rec)
+	defer InitInstrumentation(nil)
+
+	_, parseErr := strconv.Atoi("abc")
+	RecordError("instrumentation.load", "func_hooks_test.go", 60, errors.New("config missing"))
+	RecordError("instrumentation.load", "func_hooks_test.go", 61, nil) // Ignored
+	RecordError("instrumentation.parse", "func_hooks_test.go", 62, parseErr)
+
+	events := rec.GetEvents()
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 error events, got %d", len(events))
+	}
+
+	expected := []string{
+		"Error in instrumentation.load: config missing (*errors.errorString)",
+		`Error in instrumentation.parse: strconv.Atoi: parsing "abc": invalid syntax (*strconv.NumError)`,
+	}
+	for i, e := range events {
+		if e.Type != recorder.ErrorEvent {
+			t.Errorf("Event %d: expected ErrorEvent, got %s", i, e.Type)
+		}
+		if e.Details != expected[i] {
+			t.Errorf("Event %d: expected %q, got %q", i, expected[i], e.Details)
+		}
+	}
+	if events[1].FuncName != "instrumentation.parse" || events[1].Line != 62 {
+		t.Errorf("Expected location of the parse error, got %s:%d", events[1].FuncName, events[1].Line)
+	}
+}
+
+func TestRecordCustomEvent(t *testing.T) {
+	rec := recorder.NewInMemoryRecorder()
+	InitInstrumentation(rec)
+	defer InitInstrumentation(nil)
+
+	cacheMiss := recorder.RegisterEventType("InstrumentationTestCacheMiss")
+	RecordCustomEvent(cacheMiss, "instrumentation.lookup", "func_hooks_test.go", 70, "user:42 not cached")
+
+	events := rec.GetEvents()
+	if len(events) != 1 || events[0].Type != cacheMiss || events[0].Details != "user:42 not cached" {
+		t.Fatalf("Expected one InstrumentationTestCacheMiss event, got %+v", events)
+	}
+	if events[0].Type.String() != "InstrumentationTestCacheMiss" {
+		t.Errorf("Expected the registered name, got %s", events[0].Type)
+	}
+}
+
+func TestBroadcastRecorderInstrumentation(t *testing.T) {
+	rec := recorder.NewBroadcastRecorder(recorder.NewInMemoryRecorder())
+	live := rec.Subscribe(context.Background())
+	InitInstrumentation(rec)
+	defer InitInstrumentation(nil)
+
+	RecordStatement("instrumentation.work", "func_hooks_test.go", 150, "x := 1")
+
+	select {
+	case e := <-live:
+		if e.Type != recorder.StatementExecution || e.FuncName != "instrumentation.work" {
+			t.Errorf("Subscriber got %+v, want the statement", e)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Subscriber didn't receive the recorded event")
+	}
+	if len(rec.GetEvents()) != 1 {
+		t.Errorf("Expected the event in the inner recorder, got %+v", rec.GetEvents())
+	}
+}
+
+func TestFunctionFilterHooks(t *testing.T) {
+	originalOptions := CurrentOptions
+	defer func() {
+		CurrentOptions = originalOptions
+	}()
+	CurrentOptions.ExcludeFunctions = []string{"*.String", "*.logDebug"}
+
+	rec := recorder.NewInMemoryRecorder()
+	InitInstrumentation(rec)
+	defer InitInstrumentation(nil)
+
+	FuncEntry("instrumentation.(*item).String", "func_hooks_test.go", 100)
+	RecordStatement("instrumentation.(*item).String", "func_hooks_test.go", 101, "format")
+	FuncExit("instrumentation.(*item).String", "func_hooks_test.go", 102)
+	FuncEntry("instrumentation.logDebug", "func_hooks_test.go", 110)
+	FuncEntry("instrumentation.process", "func_hooks_test.go", 120)
+	RecordStatement("instrumentation.process", "func_hooks_test.go", 121, "work")
+	FuncExit("instrumentation.process", "func_hooks_test.go", 122)
+
+	events := rec.GetEvents()
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 events from the unfiltered function, got %d", len(events))
+	}
+	for _, e := range events {
+		if e.FuncName != "instrumentation.process" {
+			t.Errorf("Expected only instrumentation.process events, got one from %s", e.FuncName)
+		}
+	}
+}
+
+func TestFunctionDenyListInIncludedPackage(t *testing.T) {
+	originalOptions := CurrentOptions
+	defer func() {
+		CurrentOptions = originalOptions
+	}()
+	// The package is that of the caller, this test
+	const pkg = "github.com/willibrandon/ChronoGo/pkg/instrumentation"
+	CurrentOptions.IncludePackages = []string{pkg}
+	CurrentOptions.ExcludeFunctions = []string{"instrumentation.hash"}
+
+	rec := recorder.NewInMemoryRecorder()
+	InitInstrumentation(rec)
+	defer InitInstrumentation(nil)
+
+	FuncEntry(pkg+".hash", "func_hooks_test.go", 10)
+	FuncExit(pkg+".hash", "func_hooks_test.go", 12)
+	FuncEntry(pkg+".sum", "func_hooks_test.go", 20)
+	FuncExit(pkg+".sum", "func_hooks_test.go", 22)
+
+	events := rec.GetEvents()
+	if len(events) != 2 {
+		t.Fatalf("Expected the entry and exit of sum, got %+v", events)
+	}
+	for _, e := range events {
+		if e.FuncName != pkg+".sum" {
+			t.Errorf("Expected only sum events, got one from %s", e.FuncName)
+		}
+	}
+}
+
+func TestGoroutineIDStamping(t *testing.T) {
+	original := CurrentOptions
+	defer SetInstrumentationOptions(original)
+	options := DefaultInstrumentationOptions()
+	options.GoroutineIDs = true
+	SetInstrumentationOptions(options)
+
+	rec := recorder.NewInMemoryRecorder()
+	InitInstrumentation(rec)
+	defer InitInstrumentation(nil)
+
+	RecordStatement("instrumentation.main", "func_hooks_test.go", 10, "before")
+	mainID := int(getGoroutineID())
+
+	var workerID int
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		workerID = int(getGoroutineID())
+		GoroutineSwitch(mainID, workerID)
+		RecordStatement("instrumentation.worker", "func_hooks_test.go", 20, "in worker")
+	}()
+	<-done
+
+	var got []int
+	for _, event := range rec.GetEvents() {
+		// Goroutines other tests left running may record too
+		if strings.HasPrefix(event.FuncName, "instrumentation.") ||
+			event.Details == "Goroutine switch from "+strconv.Itoa(mainID)+" to "+strconv.Itoa(workerID) {
+			got = append(got, event.GoroutineID)
+		}
+	}
+	if len(got) != 3 || got[0] != mainID || got[1] != workerID || got[2] != workerID || mainID == workerID {
+		t.Errorf("Expected events stamped %d, %d, %d, got %v", mainID, workerID, workerID, got)
+	}
+
+	// Stamping costs a stack trace per event, so it's off unless asked for
+	SetInstrumentationOptions(DefaultInstrumentationOptions())
+	RecordStatement("instrumentation.main", "func_hooks_test.go", 30, "unstamped")
+	events := rec.GetEvents()
+	if last := events[len(events)-1]; !strings.HasSuffix(last.Details, "unstamped") || last.GoroutineID != 0 {
+		t.Errorf("Expected an unstamped event by default, got %+v", last)
+	}
+}
+
+func TestMaxDetailsLen(t *testing.T) {
+	original := CurrentOptions
+	defer SetInstrumentationOptions(original)
+	options := DefaultInstrumentationOptions()
+	options.MaxDetailsLen = 128
+	SetInstrumentationOptions(options)
+
+	rec := recorder.NewInMemoryRecorder()
+	InitInstrumentation(rec)
+	defer InitInstrumentation(nil)
+
+	large := strings.Repeat("é", 100) // Two bytes each, so a cut may fall inside one
+	RecordStatement("instrumentation.dump", "func_hooks_test.go", 170, large)
+	RecordStatement("instrumentation.dump", "func_hooks_test.go", 171, "short")
+
+	events := ownEvents(rec.GetEvents())
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(events))
+	}
+
+	full := "Executing statement in instrumentation.dump at func_hooks_test.go:170: " + large
+	truncated := events[0]
+	if len(truncated.Details) > 128 || !strings.HasSuffix(truncated.Details, "...") || !utf8.ValidString(truncated.Details) {
+		t.Errorf("Expected valid details of at most 128 bytes ending in an ellipsis, got %q", truncated.Details)
+	}
+	if !strings.HasPrefix(full, strings.TrimSuffix(truncated.Details, "...")) {
+		t.Errorf("Expected a prefix of the original details, got %q", truncated.Details)
+	}
+	if truncated.DetailsLen != len(full) {
+		t.Errorf("Expected the original length %d preserved, got %d", len(full), truncated.DetailsLen)
+	}
+	if events[1].DetailsLen != 0 || !strings.HasSuffix(events[1].Details, "short") {
+		t.Errorf("Expected short details recorded whole, got %q (length %d)", events[1].Details, events[1].DetailsLen)
+	}
+}
@@ -0,0 +1,88 @@
+package instrumentation
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// generatedFilePatterns match the names of files that code generators
+// conventionally produce, checked before reading the file header
+var generatedFilePatterns = []string{
+	"*_gen.go",
+	"*.gen.go",
+	"*_generated.go",
+	"zz_generated*.go",
+	"*.pb.go",
+	"*.pb.gw.go",
+}
+
+// generatedHeader matches the comment that marks a file as generated, see
+// https://go.dev/s/generatedcode
+var generatedHeader = regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.$`)
+
+// generatedFiles caches IsGeneratedFile by path, since it is checked on every
+// recorded event
+var generatedFiles sync.Map
+
+// IsGeneratedSource reports whether Go source carries a
+// "// Code generated ... DO NOT EDIT." comment before its package clause
+func IsGeneratedSource(src []byte) bool {
+	return hasGeneratedHeader(bytes.NewReader(src))
+}
+
+// hasGeneratedHeader scans source up to the package clause for the generated code comment
+func hasGeneratedHeader(r io.Reader) bool {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if generatedHeader.MatchString(line) {
+			return true
+		}
+		if strings.HasPrefix(line, "package ") {
+			return false
+		}
+	}
+	return false
+}
+
+// IsGeneratedFile reports whether the file at path is generated code, either
+// by its name, such as "api.pb.go", or by its header. Files that can't be
+// read are only judged by name.
+func IsGeneratedFile(path string) bool {
+	if cached, ok := generatedFiles.Load(path); ok {
+		return cached.(bool)
+	}
+
+	generated := false
+	base := filepath.Base(path)
+	for _, pattern := range generatedFilePatterns {
+		if matched, _ := filepath.Match(pattern, base); matched {
+			generated = true
+			break
+		}
+	}
+	if !generated {
+		if f, err := os.Open(path); err == nil {
+			generated = hasGeneratedHeader(f)
+			f.Close()
+		}
+	}
+
+	generatedFiles.Store(path, generated)
+	return generated
+}
+
+// ShouldInstrumentFile checks if events from a source file should be
+// recorded. With SkipGenerated set, generated files are skipped.
+func ShouldInstrumentFile(path string) bool {
+	if path == "" || !CurrentOptions.SkipGenerated {
+		return true
+	}
+	return !IsGeneratedFile(path)
+}
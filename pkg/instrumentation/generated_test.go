@@ -0,0 +1,91 @@
+package instrumentation
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+const generatedSource = `// Code generated by stringer -type=Color; DO NOT EDIT.
+
+package colors
+
+func (c Color) String() string { return "red" }
+`
+
+const handwrittenSource = `// Package colors names colors.
+// The String method is generated; see color_string.go, DO NOT EDIT it there.
+package colors
+
+// Code generated by hand. DO NOT EDIT.
+type Color int
+`
+
+func TestIsGeneratedFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, src string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		return path
+	}
+
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{write("color_string.go", generatedSource), true},
+		{write("color.go", handwrittenSource), false}, // Header after the package clause
+		{write("model_gen.go", handwrittenSource), true},
+		{filepath.Join(dir, "api.pb.go"), true}, // Judged by name without reading
+		{filepath.Join(dir, "missing.go"), false},
+	}
+	for _, tt := range tests {
+		if got := IsGeneratedFile(tt.path); got != tt.expected {
+			t.Errorf("IsGeneratedFile(%s) = %v, expected %v", filepath.Base(tt.path), got, tt.expected)
+		}
+	}
+
+	if !IsGeneratedSource([]byte(generatedSource)) || IsGeneratedSource([]byte(handwrittenSource)) {
+		t.Error("Expected only the source with a generated header before its package clause to be generated")
+	}
+}
+
+func TestSkipGenerated(t *testing.T) {
+	originalOptions := CurrentOptions
+	defer SetInstrumentationOptions(originalOptions)
+	SetInstrumentationOptions(DefaultInstrumentationOptions())
+
+	rec := recorder.NewInMemoryRecorder()
+	InitInstrumentation(rec)
+	defer InitInstrumentation(nil)
+
+	dir := t.TempDir()
+	generated := filepath.Join(dir, "color_string.go")
+	if err := os.WriteFile(generated, []byte(generatedSource), 0644); err != nil {
+		t.Fatalf("Failed to write generated file: %v", err)
+	}
+	handwritten := filepath.Join(dir, "color.go")
+
+	// Generated files are skipped by default
+	RecordStatement("colors.Color.String", generated, 5, "return")
+	RecordStatement("colors.Paint", handwritten, 10, "paint()")
+	events := rec.GetEvents()
+	if len(events) != 1 || events[0].File != handwritten {
+		t.Fatalf("Expected only the handwritten file's event, got %+v", events)
+	}
+
+	CurrentOptions.SkipGenerated = false
+	RecordStatement("colors.Color.String", generated, 5, "return")
+	if len(rec.GetEvents()) != 2 {
+		t.Error("Expected generated files to be recorded with SkipGenerated off")
+	}
+
+	t.Setenv("CHRONOGO_SKIP_GENERATED", "false")
+	if loadOptionsFromEnvironment().SkipGenerated {
+		t.Error("Expected CHRONOGO_SKIP_GENERATED=false to turn skipping off")
+	}
+}
@@ -0,0 +1,216 @@
+package instrumentation
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+// sensitiveHeaders are recorded with their values redacted
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+	"X-Api-Key":           true,
+}
+
+// RecordingTransport is an http.RoundTripper that records outbound requests
+// and their responses as NetworkOperation events. The events of one call
+// share a generated ID, e.g. "HTTP 9f86d081: GET https://api.example.com/pay"
+// and "HTTP 9f86d081: 200 OK in 12ms".
+type RecordingTransport struct {
+	RecordHeaders bool // Record request headers; credentials are redacted
+	RecordBodies  bool // Record request and response bodies, redacted
+	MaxBodySize   int  // Bytes of each body recorded, -1 for all
+
+	rec   recorder.Recorder
+	inner http.RoundTripper
+}
+
+// NewRecordingTransport creates a transport that records the calls made
+// through inner to rec. A nil inner uses http.DefaultTransport.
+func NewRecordingTransport(rec recorder.Recorder, inner http.RoundTripper) *RecordingTransport {
+	if inner == nil {
+		inner = http.DefaultTransport
+	}
+	return &RecordingTransport{
+		MaxBodySize: 1024,
+		rec:         rec,
+		inner:       inner,
+	}
+}
+
+// RoundTrip records the request, sends it with the inner transport and
+// records the response or error. A recorded response body is recorded once
+// it has been read to the end or closed.
+func (t *RecordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	id := newCallID()
+	caller := httpCaller()
+
+	details := fmt.Sprintf("HTTP %s: %s %s", id, req.Method, req.URL.Redacted())
+	if t.RecordHeaders && len(req.Header) > 0 {
+		details += ", headers " + redactHeaders(req.Header)
+	}
+	if t.RecordBodies && req.Body != nil && req.Body != http.NoBody {
+		data, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %v", err)
+		}
+		// The inner transport gets a fresh copy, and so do redirects
+		req.Body = io.NopCloser(bytes.NewReader(data))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(data)), nil
+		}
+		details += ", body " + t.payload(data, len(data))
+	}
+	t.record(caller, details)
+
+	start := time.Now()
+	resp, err := t.inner.RoundTrip(req)
+	elapsed := time.Since(start)
+	if err != nil {
+		t.record(caller, fmt.Sprintf("HTTP %s: failed after %v: %v", id, elapsed, err))
+		return nil, err
+	}
+
+	t.record(caller, fmt.Sprintf("HTTP %s: %s in %v", id, resp.Status, elapsed))
+	if t.RecordBodies && resp.Body != nil && resp.Body != http.NoBody {
+		resp.Body = &recordedBody{ReadCloser: resp.Body, transport: t, id: id, caller: caller}
+	}
+	return resp, nil
+}
+
+// payload describes a body of n bytes of which data was seen, e.g.
+// "12 bytes, payload \"{\\\"ok\\\":true}\"". Data is redacted like I/O payloads.
+func (t *RecordingTransport) payload(data []byte, n int) string {
+	security := recorder.DefaultSecurityOptions()
+	redacted := recorder.RedactData(data, security.RedactionPatterns, security.RedactionReplacement)
+	if t.MaxBodySize >= 0 && len(redacted) > t.MaxBodySize {
+		redacted = redacted[:t.MaxBodySize]
+	}
+	return fmt.Sprintf("%d bytes, payload %q", n, redacted)
+}
+
+// record records a NetworkOperation event at the caller's location
+func (t *RecordingTransport) record(caller runtime.Frame, details string) {
+	if t.rec == nil {
+		return
+	}
+	now := time.Now()
+	event := recorder.Event{
+		ID:        now.UnixNano(),
+		Timestamp: now,
+		Type:      recorder.NetworkOperation,
+		Details:   details,
+		File:      caller.File,
+		Line:      caller.Line,
+		FuncName:  caller.Function,
+		// The transport's recorder isn't the metered one that stamps events
+		GoroutineID: currentGoroutineID(),
+	}
+	if err := t.rec.RecordEvent(event); err != nil {
+		fmt.Printf("Warning: Failed to record HTTP call: %v\n", err)
+	}
+}
+
+// recordedBody records a response body as it is read
+type recordedBody struct {
+	io.ReadCloser
+	transport *RecordingTransport
+	id        string
+	caller    runtime.Frame
+
+	data []byte // The start of the body, up to MaxBodySize bytes
+	n    int    // Bytes read
+	once sync.Once
+}
+
+// Read reads from the body, keeping the start of it, and records the body at EOF
+func (b *recordedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += n
+	if limit := b.transport.MaxBodySize; limit < 0 || len(b.data) < limit {
+		keep := p[:n]
+		if limit >= 0 && len(b.data)+n > limit {
+			keep = keep[:limit-len(b.data)]
+		}
+		b.data = append(b.data, keep...)
+	}
+	if err == io.EOF {
+		b.finish()
+	}
+	return n, err
+}
+
+// Close closes the body and records what was read of it
+func (b *recordedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.finish()
+	return err
+}
+
+// finish records the body once
+func (b *recordedBody) finish() {
+	b.once.Do(func() {
+		b.transport.record(b.caller, fmt.Sprintf("HTTP %s: response body %s", b.id, b.transport.payload(b.data, b.n)))
+	})
+}
+
+// newCallID returns a random ID correlating the events of one call
+func newCallID() string {
+	var id [4]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return fmt.Sprintf("%08x", uint32(time.Now().UnixNano()))
+	}
+	return hex.EncodeToString(id[:])
+}
+
+// redactHeaders formats headers in name order, e.g.
+// "{Accept: application/json, Authorization: ***REDACTED***}"
+func redactHeaders(header http.Header) string {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	replacement := recorder.DefaultSecurityOptions().RedactionReplacement
+	fields := make([]string, len(names))
+	for i, name := range names {
+		value := strings.Join(header[name], ", ")
+		if sensitiveHeaders[http.CanonicalHeaderKey(name)] {
+			value = replacement
+		}
+		fields[i] = name + ": " + value
+	}
+	return "{" + strings.Join(fields, ", ") + "}"
+}
+
+// httpCaller returns the first frame outside net/http and this file, the
+// code that made the call
+func httpCaller() runtime.Frame {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, "net/http.") &&
+			!strings.Contains(frame.Function, "instrumentation.(*RecordingTransport)") {
+			return frame
+		}
+		if !more {
+			return runtime.Frame{}
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package instrumentation
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+func TestRecordingTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != `{"amount": 42, "password": "hunter2"}` {
+			t.Errorf("Server got body %q", body)
+		}
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, `{"status": "charged"}`)
+	}))
+	defer server.Close()
+
+	rec := recorder.NewInMemoryRecorder()
+	transport := NewRecordingTransport(rec, nil)
+	transport.RecordHeaders = true
+	transport.RecordBodies = true
+	client := &http.Client{Transport: transport}
+
+	req, _ := http.NewRequest("POST", server.URL+"/charge", strings.NewReader(`{"amount": 42, "password": "hunter2"}`))
+	req.Header.Set("Authorization", "Bearer s3cret")
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != `{"status": "charged"}` {
+		t.Errorf("Expected the response body to pass through, got %q", body)
+	}
+
+	events := rec.GetEvents()
+	if len(events) != 3 {
+		t.Fatalf("Expected request, response and response body events, got %d: %+v", len(events), events)
+	}
+	id := strings.TrimSuffix(strings.Fields(events[0].Details)[1], ":")
+	for i, event := range events {
+		if event.Type != recorder.NetworkOperation || !strings.HasPrefix(event.Details, "HTTP "+id+": ") {
+			t.Errorf("Expected event %d to be a NetworkOperation for call %s, got %s %q", i, id, event.Type, event.Details)
+		}
+	}
+	if !strings.HasSuffix(events[0].File, "http_test.go") {
+		t.Errorf("Expected the request recorded at the caller, got %s:%d", events[0].File, events[0].Line)
+	}
+
+	request := events[0].Details
+	if !strings.Contains(request, "POST "+server.URL+"/charge") ||
+		!strings.Contains(request, "headers {Accept: application/json, Authorization: ***REDACTED***}") {
+		t.Errorf("Unexpected request event %q", request)
+	}
+	if strings.Contains(request, "s3cret") || strings.Contains(request, "hunter2") || !strings.Contains(request, `\"amount\": 42`) {
+		t.Errorf("Expected the request body recorded with credentials redacted, got %q", request)
+	}
+	if !strings.Contains(events[1].Details, ": 201 Created in ") {
+		t.Errorf("Unexpected response event %q", events[1].Details)
+	}
+	if !strings.Contains(events[2].Details, `response body 21 bytes, payload "{\"status\": \"charged\"}"`) {
+		t.Errorf("Unexpected response body event %q", events[2].Details)
+	}
+}
+
+func TestRecordingTransportError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	rec := recorder.NewInMemoryRecorder()
+	client := &http.Client{Transport: NewRecordingTransport(rec, nil)}
+	if _, err := client.Get(url + "/gone"); err == nil {
+		t.Fatal("Expected the request to a closed server to fail")
+	}
+
+	events := rec.GetEvents()
+	if len(events) != 2 || !strings.Contains(events[1].Details, ": failed after ") {
+		t.Errorf("Expected the request and its failure, got %+v", events)
+	}
+}
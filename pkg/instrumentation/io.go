@@ -0,0 +1,208 @@
+package instrumentation
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+// TracedFile wraps an *os.File and records its reads, writes and close as
+// IOEvents when CurrentOptions.TraceIO is set. Other methods are those of
+// the underlying file.
+type TracedFile struct {
+	*os.File
+	label string // Identifies the file in IOEvent details
+}
+
+// TraceFile wraps f so that its I/O is recorded
+func TraceFile(f *os.File) *TracedFile {
+	label := "File " + f.Name()
+	// Fd would switch the file to blocking mode, so read the descriptor
+	// through the raw connection instead
+	if raw, err := f.SyscallConn(); err == nil {
+		raw.Control(func(fd uintptr) {
+			label = fmt.Sprintf("File %s (fd %d)", f.Name(), fd)
+		})
+	}
+	return &TracedFile{File: f, label: label}
+}
+
+// name identifies the file in IOEvent details, e.g. "File data.txt (fd 3)"
+func (f *TracedFile) name() string {
+	return f.label
+}
+
+// Read reads from the file and records the bytes read
+func (f *TracedFile) Read(p []byte) (int, error) {
+	n, err := f.File.Read(p)
+	recordIO(f.name(), "read", p[:n])
+	return n, err
+}
+
+// ReadAt reads from the file at an offset and records the bytes read
+func (f *TracedFile) ReadAt(p []byte, off int64) (int, error) {
+	n, err := f.File.ReadAt(p, off)
+	recordIO(f.name(), "read", p[:n])
+	return n, err
+}
+
+// Write writes to the file and records the bytes written
+func (f *TracedFile) Write(p []byte) (int, error) {
+	n, err := f.File.Write(p)
+	recordIO(f.name(), "write", p[:n])
+	return n, err
+}
+
+// WriteAt writes to the file at an offset and records the bytes written
+func (f *TracedFile) WriteAt(p []byte, off int64) (int, error) {
+	n, err := f.File.WriteAt(p, off)
+	recordIO(f.name(), "write", p[:n])
+	return n, err
+}
+
+// WriteString writes a string to the file and records the bytes written
+func (f *TracedFile) WriteString(s string) (int, error) {
+	n, err := f.File.WriteString(s)
+	recordIO(f.name(), "write", []byte(s[:n]))
+	return n, err
+}
+
+// ReadFrom copies r into the file, as io.Copy does, and records the bytes
+// written. The data never passes through the wrapper, so no payload is recorded.
+func (f *TracedFile) ReadFrom(r io.Reader) (int64, error) {
+	n, err := f.File.ReadFrom(r)
+	recordIOCount(f.name(), "write", n)
+	return n, err
+}
+
+// WriteTo copies the file into w and records the bytes read. No payload is recorded.
+func (f *TracedFile) WriteTo(w io.Writer) (int64, error) {
+	n, err := f.File.WriteTo(w)
+	recordIOCount(f.name(), "read", n)
+	return n, err
+}
+
+// Close closes the file and records it
+func (f *TracedFile) Close() error {
+	err := f.File.Close()
+	recordIOClose(f.name())
+	return err
+}
+
+// TracedConn wraps a net.Conn and records its reads, writes and close as
+// IOEvents when CurrentOptions.TraceIO is set
+type TracedConn struct {
+	net.Conn
+}
+
+// TraceConn wraps c so that its I/O is recorded
+func TraceConn(c net.Conn) *TracedConn {
+	return &TracedConn{Conn: c}
+}
+
+// name identifies the connection in IOEvent details by its remote
+// address, e.g. "Conn tcp 127.0.0.1:5000"
+func (c *TracedConn) name() string {
+	addr := c.Conn.RemoteAddr()
+	if addr == nil {
+		return "Conn unknown"
+	}
+	return fmt.Sprintf("Conn %s %s", addr.Network(), addr.String())
+}
+
+// Read reads from the connection and records the bytes read
+func (c *TracedConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	recordIO(c.name(), "read", p[:n])
+	return n, err
+}
+
+// Write writes to the connection and records the bytes written
+func (c *TracedConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	recordIO(c.name(), "write", p[:n])
+	return n, err
+}
+
+// Close closes the connection and records it
+func (c *TracedConn) Close() error {
+	err := c.Conn.Close()
+	recordIOClose(c.name())
+	return err
+}
+
+// ioHashLen is the number of bytes of the SHA-256 kept by IOPayloadHash
+const ioHashLen = 8
+
+// recordIO records data read from or written to name, e.g.
+// "File data.txt (fd 3): write 5 bytes, payload \"hello\"". Empty reads and
+// writes, such as a read at EOF, are not recorded.
+func recordIO(name, op string, data []byte) {
+	if !CurrentOptions.TraceIO || len(data) == 0 {
+		return
+	}
+
+	details := fmt.Sprintf("%s: %s %d bytes", name, op, len(data))
+	switch CurrentOptions.IOPayload {
+	case IOPayloadHash:
+		sum := sha256.Sum256(data)
+		details += fmt.Sprintf(", sha256 %x", sum[:ioHashLen])
+	case IOPayloadPrefix:
+		security := recorder.DefaultSecurityOptions()
+		payload := recorder.RedactData(data, security.RedactionPatterns, security.RedactionReplacement)
+		if limit := CurrentOptions.IOPayloadPrefix; limit >= 0 && len(payload) > limit {
+			payload = payload[:limit]
+		}
+		details += fmt.Sprintf(", payload %q", payload)
+	}
+	recordIOEvent(details)
+}
+
+// recordIOCount records a transfer whose data wasn't seen by the wrapper
+func recordIOCount(name, op string, n int64) {
+	if !CurrentOptions.TraceIO || n == 0 {
+		return
+	}
+	recordIOEvent(fmt.Sprintf("%s: %s %d bytes", name, op, n))
+}
+
+// recordIOClose records that name was closed
+func recordIOClose(name string) {
+	if !CurrentOptions.TraceIO {
+		return
+	}
+	recordIOEvent(name + ": closed")
+}
+
+// recordIOEvent records an IOEvent at the location of the traced call.
+// Like recordInput it ignores package filters.
+func recordIOEvent(details string) {
+	if !CurrentOptions.Enabled || globalRecorder == nil {
+		return
+	}
+
+	event := recorder.Event{
+		ID:        time.Now().UnixNano(),
+		Timestamp: time.Now(),
+		Type:      recorder.IOEvent,
+		Details:   details,
+	}
+	// Skip this function, the record helper and the wrapper method
+	if pc, file, line, ok := runtime.Caller(3); ok {
+		event.File = file
+		event.Line = line
+		if fn := runtime.FuncForPC(pc); fn != nil {
+			event.FuncName = fn.Name()
+		}
+	}
+
+	if err := globalRecorder.RecordEvent(event); err != nil {
+		fmt.Printf("Error recording I/O: %v\n", err)
+	}
+}
@@ -0,0 +1,143 @@
+package instrumentation
+
+import (
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+// traceIO enables I/O tracing with the given payload mode for the rest of the test
+func traceIO(t *testing.T, payload IOPayloadMode) *recorder.InMemoryRecorder {
+	originalOptions := CurrentOptions
+	CurrentOptions.TraceIO = true
+	CurrentOptions.IOPayload = payload
+	rec := recorder.NewInMemoryRecorder()
+	InitInstrumentation(rec)
+	t.Cleanup(func() {
+		CurrentOptions = originalOptions
+		InitInstrumentation(nil)
+	})
+	return rec
+}
+
+func TestTracedFile(t *testing.T) {
+	rec := traceIO(t, IOPayloadNone)
+
+	path := filepath.Join(t.TempDir(), "data.txt")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	traced := TraceFile(f)
+	if _, err := traced.WriteString("hello "); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	if _, err := io.Copy(traced, strings.NewReader("world")); err != nil {
+		t.Fatalf("Failed to copy: %v", err)
+	}
+	traced.Close()
+
+	f, err = os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	traced = TraceFile(f)
+	data, err := io.ReadAll(traced)
+	if err != nil || string(data) != "hello world" {
+		t.Fatalf("Expected to read back the data, got %q (%v)", data, err)
+	}
+	traced.Close()
+
+	events := rec.GetEvents()
+	expected := []string{": write 6 bytes", ": write 5 bytes", ": closed", ": read 11 bytes", ": closed"}
+	if len(events) != len(expected) {
+		t.Fatalf("Expected %d events, got %d: %+v", len(expected), len(events), events)
+	}
+	for i, e := range events {
+		if e.Type != recorder.IOEvent || !strings.HasPrefix(e.Details, "File "+path+" (fd ") ||
+			!strings.HasSuffix(e.Details, expected[i]) {
+			t.Errorf("Event %d: expected IOEvent on %s ending %q, got %s %q", i, path, expected[i], e.Type, e.Details)
+		}
+	}
+	if events[0].FuncName != "github.com/willibrandon/ChronoGo/pkg/instrumentation.TestTracedFile" {
+		t.Errorf("Expected the write to be attributed to the test, got %s", events[0].FuncName)
+	}
+}
+
+func TestTracedConnPayload(t *testing.T) {
+	rec := traceIO(t, IOPayloadPrefix)
+	CurrentOptions.IOPayloadPrefix = 29
+
+	client, server := net.Pipe()
+	traced := TraceConn(client)
+	go func() {
+		buf := make([]byte, 64)
+		n, _ := server.Read(buf)
+		server.Write(buf[:n])
+		server.Close()
+	}()
+
+	if _, err := traced.Write([]byte("login password=hunter2 and more data")); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	buf := make([]byte, 64)
+	if _, err := io.ReadFull(traced, buf[:4]); err != nil {
+		t.Fatalf("Failed to read: %v", err)
+	}
+	traced.Close()
+
+	events := rec.GetEvents()
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 events, got %d: %+v", len(events), events)
+	}
+	if want := `Conn pipe pipe: write 36 bytes, payload "login password=***REDACTED***"`; events[0].Details != want {
+		t.Errorf("Expected redacted prefix %q, got %q", want, events[0].Details)
+	}
+	if strings.Contains(events[0].Details, "hunter2") {
+		t.Error("Expected the password to be redacted")
+	}
+	if want := `Conn pipe pipe: read 4 bytes, payload "logi"`; events[1].Details != want {
+		t.Errorf("Expected %q, got %q", want, events[1].Details)
+	}
+}
+
+func TestTracedIOOptions(t *testing.T) {
+	rec := traceIO(t, IOPayloadHash)
+
+	path := filepath.Join(t.TempDir(), "data.txt")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	traced := TraceFile(f)
+	defer traced.Close()
+
+	traced.Write([]byte("abc"))
+	events := rec.GetEvents()
+	// The first 8 bytes of the SHA-256 of "abc"
+	if len(events) != 1 || !strings.HasSuffix(events[0].Details, ": write 3 bytes, sha256 ba7816bf8f01cfea") {
+		t.Errorf("Expected a hashed payload, got %+v", events)
+	}
+
+	// Tracing is off by default
+	CurrentOptions.TraceIO = DefaultInstrumentationOptions().TraceIO
+	traced.Write([]byte("abc"))
+	if len(rec.GetEvents()) != 1 {
+		t.Error("Expected no events with I/O tracing off")
+	}
+
+	t.Setenv("CHRONOGO_TRACE_IO", "1")
+	t.Setenv("CHRONOGO_IO_PAYLOAD", "prefix")
+	options := loadOptionsFromEnvironment()
+	if !options.TraceIO || options.IOPayload != IOPayloadPrefix {
+		t.Errorf("Expected tracing with prefix payloads from the environment, got %+v", options)
+	}
+	if DefaultInstrumentationOptions().IOPayload != IOPayloadNone {
+		t.Error("Expected payload capture to default to off")
+	}
+}
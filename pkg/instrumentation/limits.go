@@ -0,0 +1,91 @@
+package instrumentation
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+var (
+	recordedCount  int64        // Events recorded since recording started or resumed
+	recordingStart atomic.Value // time.Time recording started or resumed
+	paused         int32        // 1 while recording is paused
+)
+
+// PauseRecording stops recording events until ResumeRecording is called.
+// Events from instrumented code are dropped while paused.
+func PauseRecording() {
+	atomic.StoreInt32(&paused, 1)
+}
+
+// ResumeRecording starts recording again after PauseRecording or after a
+// MaxEvents or MaxDuration limit stopped it. The limits apply afresh from
+// the moment recording resumes.
+func ResumeRecording() {
+	resetRecordingLimits()
+	atomic.StoreInt32(&paused, 0)
+}
+
+// RecordingPaused reports whether recording is paused
+func RecordingPaused() bool {
+	return atomic.LoadInt32(&paused) == 1
+}
+
+// RecordedCount returns the number of events recorded since recording
+// started or last resumed
+func RecordedCount() int64 {
+	return atomic.LoadInt64(&recordedCount)
+}
+
+// resetRecordingLimits starts counting events and time for the limits anew
+func resetRecordingLimits() {
+	atomic.StoreInt64(&recordedCount, 0)
+	recordingStart.Store(time.Now())
+}
+
+// recordWithinLimits records e unless recording is paused, stopping
+// recording once CurrentOptions.MaxEvents or MaxDuration is reached
+func recordWithinLimits(r recorder.Recorder, e recorder.Event) error {
+	if RecordingPaused() {
+		return nil
+	}
+
+	if max := CurrentOptions.MaxDuration; max > 0 {
+		if start, ok := recordingStart.Load().(time.Time); ok && time.Since(start) >= max {
+			stopRecording(r, fmt.Sprintf("reached the MaxDuration limit of %v", max))
+			return nil
+		}
+	}
+
+	max := int64(CurrentOptions.MaxEvents)
+	n := atomic.AddInt64(&recordedCount, 1)
+	if max > 0 && n > max {
+		// Raced with the event that reached the limit
+		atomic.AddInt64(&recordedCount, -1)
+		return nil
+	}
+	err := r.RecordEvent(e)
+	if max > 0 && n == max {
+		stopRecording(r, fmt.Sprintf("reached the MaxEvents limit of %d events", max))
+	}
+	return err
+}
+
+// stopRecording pauses recording and records why, once
+func stopRecording(r recorder.Recorder, reason string) {
+	if !atomic.CompareAndSwapInt32(&paused, 0, 1) {
+		return
+	}
+	fmt.Printf("Warning: Recording stopped: %s\n", reason)
+	now := time.Now()
+	if err := r.RecordEvent(recorder.Event{
+		ID:        now.UnixNano(),
+		Timestamp: now,
+		Type:      recorder.RecordingStopped,
+		Details:   "Recording stopped: " + reason,
+	}); err != nil {
+		fmt.Printf("Error recording stop event: %v\n", err)
+	}
+}
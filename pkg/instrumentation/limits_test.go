@@ -0,0 +1,95 @@
+package instrumentation
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+func TestMaxEvents(t *testing.T) {
+	original := CurrentOptions
+	defer SetInstrumentationOptions(original)
+	options := DefaultInstrumentationOptions()
+	options.MaxEvents = 100
+	SetInstrumentationOptions(options)
+
+	rec := recorder.NewInMemoryRecorder()
+	InitInstrumentation(rec)
+	defer InitInstrumentation(nil)
+
+	for i := 0; i < 150; i++ {
+		RecordStatement("instrumentation.loop", "limits_test.go", 20, "step")
+	}
+
+	events := rec.GetEvents()
+	if len(events) != 101 {
+		t.Fatalf("Expected 100 events and the stop marker, got %d", len(events))
+	}
+	last := events[100]
+	if last.Type != recorder.RecordingStopped || !strings.Contains(last.Details, "MaxEvents limit of 100 events") {
+		t.Errorf("Expected the stop marker last, got %s %q", last.Type, last.Details)
+	}
+	if !RecordingPaused() || RecordedCount() != 100 {
+		t.Errorf("Expected recording paused after 100 events, paused %v, count %d", RecordingPaused(), RecordedCount())
+	}
+
+	// Resuming starts a new budget
+	ResumeRecording()
+	RecordStatement("instrumentation.loop", "limits_test.go", 30, "after resume")
+	events = ownEvents(rec.GetEvents())
+	if !strings.HasSuffix(events[len(events)-1].Details, "after resume") || RecordedCount() == 0 {
+		t.Errorf("Expected recording to resume, got count %d", RecordedCount())
+	}
+}
+
+// ownEvents drops events recorded by goroutines that other tests left running
+func ownEvents(events []recorder.Event) []recorder.Event {
+	var own []recorder.Event
+	for _, event := range events {
+		if event.Type == recorder.RecordingStopped || strings.HasPrefix(event.FuncName, "instrumentation.") {
+			own = append(own, event)
+		}
+	}
+	return own
+}
+
+func TestMaxDuration(t *testing.T) {
+	original := CurrentOptions
+	defer SetInstrumentationOptions(original)
+	options := DefaultInstrumentationOptions()
+	options.MaxDuration = 20 * time.Millisecond
+	SetInstrumentationOptions(options)
+
+	rec := recorder.NewInMemoryRecorder()
+	InitInstrumentation(rec)
+	defer InitInstrumentation(nil)
+
+	RecordStatement("instrumentation.serve", "limits_test.go", 50, "early")
+	time.Sleep(30 * time.Millisecond)
+	RecordStatement("instrumentation.serve", "limits_test.go", 51, "late")
+	RecordStatement("instrumentation.serve", "limits_test.go", 52, "later")
+
+	events := ownEvents(rec.GetEvents())
+	if len(events) != 2 || !strings.HasSuffix(events[0].Details, "early") || events[1].Type != recorder.RecordingStopped ||
+		!strings.Contains(events[1].Details, "MaxDuration limit of 20ms") {
+		t.Errorf("Expected the early event and the stop marker, got %+v", events)
+	}
+}
+
+func TestPauseRecording(t *testing.T) {
+	rec := recorder.NewInMemoryRecorder()
+	InitInstrumentation(rec)
+	defer InitInstrumentation(nil)
+
+	PauseRecording()
+	RecordStatement("instrumentation.quiet", "limits_test.go", 70, "dropped")
+	ResumeRecording()
+	RecordStatement("instrumentation.quiet", "limits_test.go", 71, "kept")
+
+	events := ownEvents(rec.GetEvents())
+	if len(events) != 1 || !strings.HasSuffix(events[0].Details, "kept") {
+		t.Errorf("Expected only the event recorded after resuming, got %+v", events)
+	}
+}
@@ -0,0 +1,208 @@
+package instrumentation
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+// maxMetricEventTypes bounds the per-type counters; event types beyond it are
+// counted in the total only
+const maxMetricEventTypes = 64
+
+// latencyBuckets are the upper bounds of the RecordEvent latency histogram
+var latencyBuckets = [...]time.Duration{
+	time.Microsecond, 5 * time.Microsecond, 10 * time.Microsecond, 50 * time.Microsecond,
+	100 * time.Microsecond, 500 * time.Microsecond, time.Millisecond, 5 * time.Millisecond,
+	10 * time.Millisecond, 50 * time.Millisecond, 100 * time.Millisecond,
+}
+
+var (
+	eventTypeCounts [maxMetricEventTypes]int64
+	eventsTotal     int64
+	recorderErrors  int64
+	// Events whose latency fell in each bucket, the last one for those
+	// slower than every bound
+	latencyCounts [len(latencyBuckets) + 1]int64
+	latencyNanos  int64
+)
+
+// MetricsSnapshot is a point-in-time view of the recording counters. Unlike
+// the overhead stats, these counters are never reset, so they can be
+// scraped as monotonic counters.
+type MetricsSnapshot struct {
+	Events         int64                        // Events passed to the recorder
+	EventsByType   map[recorder.EventType]int64 // Events passed to the recorder, by type
+	Dropped        int64                        // Events the recorder discarded, if it reports them
+	BytesWritten   int64                        // Bytes the current recorder has written, if it reports them
+	FileSize       int64                        // Size of the current recording file, if recording to a file
+	RecorderErrors int64                        // Events the recorder failed to record
+	Latency        []LatencyBucket              // Cumulative RecordEvent latency histogram, by upper bound
+	LatencySum     time.Duration                // Total time spent in RecordEvent
+}
+
+// LatencyBucket counts the events recorded within an upper bound. The last
+// bucket of a histogram has no bound and counts every event.
+type LatencyBucket struct {
+	UpperBound time.Duration // 0 for the last bucket
+	Count      int64
+}
+
+// countEvent updates the metrics counters for one recorded event, which took
+// latency to record
+func countEvent(t recorder.EventType, latency time.Duration, err error) {
+	atomic.AddInt64(&eventsTotal, 1)
+	bucket := len(latencyBuckets)
+	for i, bound := range latencyBuckets {
+		if latency <= bound {
+			bucket = i
+			break
+		}
+	}
+	atomic.AddInt64(&latencyCounts[bucket], 1)
+	atomic.AddInt64(&latencyNanos, int64(latency))
+	if t >= 0 && t < maxMetricEventTypes {
+		atomic.AddInt64(&eventTypeCounts[t], 1)
+	}
+	if err != nil {
+		atomic.AddInt64(&recorderErrors, 1)
+	}
+}
+
+// Metrics returns a snapshot of the recording counters
+func Metrics() MetricsSnapshot {
+	snapshot := MetricsSnapshot{
+		Events:         atomic.LoadInt64(&eventsTotal),
+		EventsByType:   make(map[recorder.EventType]int64),
+		RecorderErrors: atomic.LoadInt64(&recorderErrors),
+	}
+	for i := range eventTypeCounts {
+		if n := atomic.LoadInt64(&eventTypeCounts[i]); n > 0 {
+			snapshot.EventsByType[recorder.EventType(i)] = n
+		}
+	}
+	var cumulative int64
+	for i := range latencyCounts {
+		cumulative += atomic.LoadInt64(&latencyCounts[i])
+		bucket := LatencyBucket{Count: cumulative}
+		if i < len(latencyBuckets) {
+			bucket.UpperBound = latencyBuckets[i]
+		}
+		snapshot.Latency = append(snapshot.Latency, bucket)
+	}
+	snapshot.LatencySum = time.Duration(atomic.LoadInt64(&latencyNanos))
+
+	// The rest is reported by the recorder itself, when it supports it
+	if m, ok := globalRecorder.(*meteredRecorder); ok {
+		if r, ok := m.Recorder.(interface{ Dropped() int }); ok {
+			snapshot.Dropped = int64(r.Dropped())
+		}
+		if r, ok := m.Recorder.(interface{ BytesWritten() int64 }); ok {
+			snapshot.BytesWritten = r.BytesWritten()
+		}
+		if r, ok := m.Recorder.(interface{ FileSize() int64 }); ok {
+			snapshot.FileSize = r.FileSize()
+		}
+	}
+
+	return snapshot
+}
+
+// WritePrometheus writes the snapshot in the Prometheus text exposition format
+func (s MetricsSnapshot) WritePrometheus(w io.Writer) error {
+	var err error
+	write := func(format string, args ...interface{}) {
+		if err == nil {
+			_, err = fmt.Fprintf(w, format, args...)
+		}
+	}
+	metric := func(name, kind, help string) {
+		write("# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+
+	metric("chrono_events_recorded_total", "counter", "Events passed to the recorder, by event type.")
+	for i := 0; i < maxMetricEventTypes; i++ {
+		if n, ok := s.EventsByType[recorder.EventType(i)]; ok {
+			write("chrono_events_recorded_total{type=%q} %d\n", recorder.EventType(i).String(), n)
+		}
+	}
+	metric("chrono_events_dropped_total", "counter", "Events discarded by the recorder.")
+	write("chrono_events_dropped_total %d\n", s.Dropped)
+	metric("chrono_bytes_written_total", "counter", "Bytes written by the current recorder.")
+	write("chrono_bytes_written_total %d\n", s.BytesWritten)
+	metric("chrono_file_size_bytes", "gauge", "Size of the current recording file.")
+	write("chrono_file_size_bytes %d\n", s.FileSize)
+	metric("chrono_recorder_errors_total", "counter", "Events the recorder failed to record.")
+	write("chrono_recorder_errors_total %d\n", s.RecorderErrors)
+	metric("chrono_record_latency_seconds", "histogram", "Time spent recording each event.")
+	var count int64
+	for _, bucket := range s.Latency {
+		le := "+Inf"
+		if bucket.UpperBound > 0 {
+			le = fmt.Sprint(bucket.UpperBound.Seconds())
+		}
+		write("chrono_record_latency_seconds_bucket{le=%q} %d\n", le, bucket.Count)
+		count = bucket.Count
+	}
+	write("chrono_record_latency_seconds_sum %v\n", s.LatencySum.Seconds())
+	write("chrono_record_latency_seconds_count %d\n", count)
+
+	return err
+}
+
+// MetricsHandler returns an http.Handler serving the recording metrics in
+// Prometheus text format
+func MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		if err := Metrics().WritePrometheus(w); err != nil {
+			fmt.Printf("Warning: Error writing metrics: %v\n", err)
+		}
+	})
+}
+
+// MetricsServer serves /metrics for a long-running instrumented service
+type MetricsServer struct {
+	listener net.Listener
+	server   *http.Server
+}
+
+// ServeMetrics starts serving the recording metrics at /metrics on addr in
+// the background. Use an addr with port 0 to pick a free port and Addr to
+// find it.
+func ServeMetrics(addr string) (*MetricsServer, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %v", addr, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", MetricsHandler())
+	ms := &MetricsServer{
+		listener: listener,
+		server:   &http.Server{Handler: mux},
+	}
+
+	go func() {
+		if err := ms.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			fmt.Printf("Warning: Metrics server stopped: %v\n", err)
+		}
+	}()
+
+	return ms, nil
+}
+
+// Addr returns the address the metrics server is listening on
+func (ms *MetricsServer) Addr() net.Addr {
+	return ms.listener.Addr()
+}
+
+// Close stops the metrics server
+func (ms *MetricsServer) Close() error {
+	return ms.server.Close()
+}
@@ -0,0 +1,110 @@
+package instrumentation
+
+import (
+	"bufio"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+// scrapeMetrics fetches /metrics and returns the samples by name and labels
+func scrapeMetrics(t *testing.T, addr string) map[string]float64 {
+	t.Helper()
+
+	resp, err := http.Get("http://" + addr + "/metrics")
+	if err != nil {
+		t.Fatalf("Failed to scrape metrics: %v", err)
+	}
+	defer resp.Body.Close()
+
+	samples := make(map[string]float64)
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		sep := strings.LastIndex(line, " ")
+		value, err := strconv.ParseFloat(line[sep+1:], 64)
+		if err != nil {
+			t.Fatalf("Malformed sample %q: %v", line, err)
+		}
+		samples[line[:sep]] = value
+	}
+	return samples
+}
+
+func TestServeMetrics(t *testing.T) {
+	rec, err := recorder.NewFileRecorderWithOptions(filepath.Join(t.TempDir(), "metrics.events"),
+		recorder.FileRecorderOptions{CompressionType: recorder.NoCompression})
+	if err != nil {
+		t.Fatalf("Failed to create file recorder: %v", err)
+	}
+	defer rec.Close()
+
+	InitInstrumentation(rec)
+	defer InitInstrumentation(nil)
+
+	server, err := ServeMetrics("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to serve metrics: %v", err)
+	}
+	defer server.Close()
+	addr := server.Addr().String()
+
+	before := scrapeMetrics(t, addr)
+
+	for i := 0; i < 5; i++ {
+		FuncEntry("instrumentation.metricsCall", "metrics.go", 10)
+		FuncExit("instrumentation.metricsCall", "metrics.go", 12)
+	}
+	ChannelSend(1, 1, 42)
+
+	after := scrapeMetrics(t, addr)
+
+	entries := `chrono_events_recorded_total{type="FunctionEntry"}`
+	if delta := after[entries] - before[entries]; delta != 5 {
+		t.Errorf("Expected 5 more function entries, got %v", delta)
+	}
+	exits := `chrono_events_recorded_total{type="FunctionExit"}`
+	if delta := after[exits] - before[exits]; delta != 5 {
+		t.Errorf("Expected 5 more function exits, got %v", delta)
+	}
+	channels := `chrono_events_recorded_total{type="ChannelOperation"}`
+	if delta := after[channels] - before[channels]; delta != 1 {
+		t.Errorf("Expected 1 more channel operation, got %v", delta)
+	}
+
+	if after["chrono_bytes_written_total"] <= before["chrono_bytes_written_total"] {
+		t.Errorf("Expected bytes written to grow, got %v then %v",
+			before["chrono_bytes_written_total"], after["chrono_bytes_written_total"])
+	}
+	if after["chrono_file_size_bytes"] != float64(rec.FileSize()) {
+		t.Errorf("Expected file size %d, got %v", rec.FileSize(), after["chrono_file_size_bytes"])
+	}
+	if after["chrono_recorder_errors_total"] != before["chrono_recorder_errors_total"] {
+		t.Errorf("Expected no recorder errors, got %v", after["chrono_recorder_errors_total"])
+	}
+
+	// Every event lands in the histogram, and the +Inf bucket holds them all
+	count := "chrono_record_latency_seconds_count"
+	if delta := after[count] - before[count]; delta != 11 {
+		t.Errorf("Expected 11 more latency observations, got %v", delta)
+	}
+	if inf := after[`chrono_record_latency_seconds_bucket{le="+Inf"}`]; inf != after[count] {
+		t.Errorf("Expected the +Inf bucket to equal the count %v, got %v", after[count], inf)
+	}
+	if after["chrono_record_latency_seconds_sum"] <= before["chrono_record_latency_seconds_sum"] {
+		t.Errorf("Expected the latency sum to grow, got %v then %v",
+			before["chrono_record_latency_seconds_sum"], after["chrono_record_latency_seconds_sum"])
+	}
+
+	// The snapshot agrees with the endpoint
+	if n := Metrics().EventsByType[recorder.FuncEntry]; float64(n) != after[entries] {
+		t.Errorf("Expected Metrics to report %v function entries, got %d", after[entries], n)
+	}
+}
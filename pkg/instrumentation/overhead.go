@@ -0,0 +1,75 @@
+package instrumentation
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+// Overhead describes the runtime cost of recording since the counters were last reset
+type Overhead struct {
+	Events       int64         // Events passed to the recorder
+	Bytes        int64         // Bytes the current recorder has written, if it reports them
+	RecorderTime time.Duration // Time spent inside the recorder
+}
+
+// PerEvent returns the average time spent in the recorder per event
+func (o Overhead) PerEvent() time.Duration {
+	if o.Events == 0 {
+		return 0
+	}
+	return o.RecorderTime / time.Duration(o.Events)
+}
+
+var (
+	overheadEvents int64
+	overheadNanos  int64
+)
+
+// meteredRecorder wraps the global recorder to count events and the time spent recording them
+type meteredRecorder struct {
+	recorder.Recorder
+}
+
+// RecordEvent stamps the event with the recording goroutine, unless the
+// caller already has or stamping is off, checks it in debug builds, forwards it to the wrapped recorder and updates the
+// overhead counters
+func (m *meteredRecorder) RecordEvent(e recorder.Event) error {
+	start := time.Now()
+	if e.GoroutineID == 0 && stampGoroutines() {
+		e.GoroutineID = currentGoroutineID()
+	}
+	tagEvent(&e)
+	validateEvent(e)
+	err := recordWithinLimits(m.Recorder, e)
+	elapsed := time.Since(start)
+	atomic.AddInt64(&overheadNanos, int64(elapsed))
+	atomic.AddInt64(&overheadEvents, 1)
+	countEvent(e.Type, elapsed, err)
+	return err
+}
+
+// OverheadStats returns counters describing how much recording has cost so
+// far. They are updated with atomics, so it is cheap to leave them on in
+// production and poll them periodically.
+func OverheadStats() Overhead {
+	stats := Overhead{
+		Events:       atomic.LoadInt64(&overheadEvents),
+		RecorderTime: time.Duration(atomic.LoadInt64(&overheadNanos)),
+	}
+
+	if m, ok := globalRecorder.(*meteredRecorder); ok {
+		if counter, ok := m.Recorder.(interface{ BytesWritten() int64 }); ok {
+			stats.Bytes = counter.BytesWritten()
+		}
+	}
+
+	return stats
+}
+
+// ResetOverheadStats sets the event and time counters back to zero
+func ResetOverheadStats() {
+	atomic.StoreInt64(&overheadEvents, 0)
+	atomic.StoreInt64(&overheadNanos, 0)
+}
@@ -0,0 +1,48 @@
+package instrumentation
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+func TestOverheadStats(t *testing.T) {
+	rec, err := recorder.NewFileRecorderWithOptions(filepath.Join(t.TempDir(), "overhead.events"),
+		recorder.FileRecorderOptions{CompressionType: recorder.NoCompression})
+	if err != nil {
+		t.Fatalf("Failed to create file recorder: %v", err)
+	}
+	defer rec.Close()
+
+	InitInstrumentation(rec)
+	defer InitInstrumentation(nil)
+	ResetOverheadStats()
+
+	for i := 0; i < 5; i++ {
+		FuncEntry("instrumentation.overheadCall", "overhead.go", 10)
+		FuncExit("instrumentation.overheadCall", "overhead.go", 12)
+	}
+
+	stats := OverheadStats()
+	if stats.Events != 10 {
+		t.Errorf("Expected 10 events, got %d", stats.Events)
+	}
+	if stats.RecorderTime <= 0 || stats.PerEvent() <= 0 {
+		t.Errorf("Expected time spent in recorder to be measured, got %v", stats.RecorderTime)
+	}
+	if stats.Bytes != rec.BytesWritten() || stats.Bytes == 0 {
+		t.Errorf("Expected %d bytes written, got %d", rec.BytesWritten(), stats.Bytes)
+	}
+
+	ResetOverheadStats()
+	if stats := OverheadStats(); stats.Events != 0 || stats.RecorderTime != 0 {
+		t.Errorf("Expected counters to be reset, got %+v", stats)
+	}
+
+	// Recorders that don't report bytes leave the byte count at zero
+	InitInstrumentation(recorder.NewInMemoryRecorder())
+	if stats := OverheadStats(); stats.Bytes != 0 {
+		t.Errorf("Expected no bytes for in-memory recorder, got %d", stats.Bytes)
+	}
+}
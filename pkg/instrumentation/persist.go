@@ -0,0 +1,46 @@
+package instrumentation
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+// persistence holds where events are written when an error or panic is recorded
+var persistence struct {
+	mu   sync.Mutex
+	path string
+}
+
+// EnableErrorTriggeredPersistence writes the events held by the recorder to
+// path whenever RecordError or RecordPanic is called. Combined with a
+// recorder.BoundedRecorder this records cheaply into memory all the time
+// and only persists the lead-up to a failure. Each failure replaces the
+// file with the events buffered at that point.
+func EnableErrorTriggeredPersistence(path string) {
+	persistence.mu.Lock()
+	defer persistence.mu.Unlock()
+	persistence.path = path
+}
+
+// DisableErrorTriggeredPersistence stops writing events on errors
+func DisableErrorTriggeredPersistence() {
+	EnableErrorTriggeredPersistence("")
+}
+
+// persistOnFailure writes the recorder's events to the persistence path, if
+// one is set. It runs synchronously, since a panic may be about to end the
+// program.
+func persistOnFailure() {
+	persistence.mu.Lock()
+	defer persistence.mu.Unlock()
+
+	if persistence.path == "" || globalRecorder == nil {
+		return
+	}
+	events := globalRecorder.GetEvents()
+	if err := recorder.WriteEventsFile(persistence.path, events, recorder.DefaultFileRecorderOptions()); err != nil {
+		fmt.Printf("Warning: Failed to persist events on error: %v\n", err)
+	}
+}
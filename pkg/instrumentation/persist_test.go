@@ -0,0 +1,64 @@
+package instrumentation
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+func TestErrorTriggeredPersistence(t *testing.T) {
+	InitInstrumentation(recorder.NewBoundedRecorder(4))
+	defer InitInstrumentation(nil)
+
+	path := filepath.Join(t.TempDir(), "failure.events")
+	EnableErrorTriggeredPersistence(path)
+	defer DisableErrorTriggeredPersistence()
+
+	// Normal activity is only buffered
+	for i := 0; i < 6; i++ {
+		RecordStatement("instrumentation.work", "persist_test.go", 20+i, "step")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("Expected no file before an error, got %v", err)
+	}
+
+	RecordError("instrumentation.work", "persist_test.go", 30, errors.New("disk full"))
+
+	events, err := recorder.ReadEventsFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read persisted events: %v", err)
+	}
+	if len(events) != 4 {
+		t.Fatalf("Expected the 4 buffered events, got %d: %+v", len(events), events)
+	}
+	for i, e := range events[:3] {
+		if e.Type != recorder.StatementExecution || e.Line != 23+i {
+			t.Errorf("Event %d: expected the statement at line %d, got %s at %d", i, 23+i, e.Type, e.Line)
+		}
+	}
+	if last := events[3]; last.Type != recorder.ErrorEvent || last.Details != "Error in instrumentation.work: disk full (*errors.errorString)" {
+		t.Errorf("Expected the error last, got %s %q", last.Type, last.Details)
+	}
+
+	// A panic replaces the file with the events leading up to it
+	RecordStatement("instrumentation.run", "persist_test.go", 40, "step")
+	RecordPanic("instrumentation.run", "persist_test.go", 41, "boom")
+	events, err = recorder.ReadEventsFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read persisted events: %v", err)
+	}
+	if len(events) != 4 || events[3].Details != "Panic in instrumentation.run: boom (string)" {
+		t.Errorf("Expected the panic to be persisted, got %+v", events)
+	}
+
+	// Disabled persistence leaves the file alone
+	DisableErrorTriggeredPersistence()
+	os.Remove(path)
+	RecordError("instrumentation.work", "persist_test.go", 50, errors.New("again"))
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected no file with persistence disabled, got %v", err)
+	}
+}
@@ -0,0 +1,156 @@
+package instrumentation
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+// TracedCmd wraps an *exec.Cmd so that starting it records a
+// ProcessSpawnEvent, and asks the child, if it is instrumented and calls
+// InitFromEnvironment, to write its own recording linked back to that
+// event. Other methods are those of the underlying command.
+type TracedCmd struct {
+	*exec.Cmd
+	// Recording the child is asked to write. If empty, Start picks
+	// chronogo.<command>.<spawn event ID>.events in the working directory.
+	EventsFile string
+}
+
+// TracedCommand returns a TracedCmd to run the named program with the
+// given arguments, as exec.Command does
+func TracedCommand(name string, arg ...string) *TracedCmd {
+	return &TracedCmd{Cmd: exec.Command(name, arg...)}
+}
+
+// Start starts the command and records its spawn
+func (c *TracedCmd) Start() error {
+	return c.start()
+}
+
+// start starts the command, recording the spawn at the location of the
+// wrapper's caller
+func (c *TracedCmd) start() error {
+	if !CurrentOptions.Enabled || globalRecorder == nil {
+		return c.Cmd.Start()
+	}
+
+	id := time.Now().UnixNano()
+	if c.EventsFile == "" {
+		c.EventsFile = fmt.Sprintf("chronogo.%s.%d.events", filepath.Base(c.Path), id)
+	}
+	// The child may run in another directory
+	if abs, err := filepath.Abs(c.EventsFile); err == nil {
+		c.EventsFile = abs
+	}
+	env := c.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	// Later entries win, so these replace any inherited from a parent
+	c.Env = append(env,
+		recorder.EventsFileEnv+"="+c.EventsFile,
+		recorder.ParentIDEnv+"="+strconv.FormatInt(id, 10))
+
+	if err := c.Cmd.Start(); err != nil {
+		return err
+	}
+
+	security := recorder.DefaultSecurityOptions()
+	args := make([]string, len(c.Args))
+	for i, arg := range c.Args {
+		args[i] = string(recorder.RedactData([]byte(arg), security.RedactionPatterns, security.RedactionReplacement))
+	}
+	spawn := recorder.ProcessSpawn{
+		PID:        c.Process.Pid,
+		Command:    strings.Join(args, " "),
+		EventsFile: c.EventsFile,
+	}
+	event := recorder.Event{
+		ID:          id,
+		Timestamp:   time.Now(),
+		Type:        recorder.ProcessSpawnEvent,
+		Details:     spawn.Details(),
+		GoroutineID: currentGoroutineID(),
+	}
+	// Skip this function and the wrapper method
+	if pc, file, line, ok := runtime.Caller(2); ok {
+		event.File = file
+		event.Line = line
+		if fn := runtime.FuncForPC(pc); fn != nil {
+			event.FuncName = fn.Name()
+		}
+	}
+	if err := globalRecorder.RecordEvent(event); err != nil {
+		fmt.Printf("Error recording process spawn: %v\n", err)
+	}
+	return nil
+}
+
+// Run starts the command, records its spawn and waits for it to finish
+func (c *TracedCmd) Run() error {
+	if err := c.start(); err != nil {
+		return err
+	}
+	return c.Wait()
+}
+
+// Output runs the command as Run does and returns its standard output
+func (c *TracedCmd) Output() ([]byte, error) {
+	if c.Stdout != nil {
+		return nil, errors.New("exec: Stdout already set")
+	}
+	var stdout bytes.Buffer
+	c.Stdout = &stdout
+	if err := c.start(); err != nil {
+		return nil, err
+	}
+	err := c.Wait()
+	return stdout.Bytes(), err
+}
+
+// CombinedOutput runs the command as Run does and returns its standard
+// output and standard error interleaved
+func (c *TracedCmd) CombinedOutput() ([]byte, error) {
+	if c.Stdout != nil {
+		return nil, errors.New("exec: Stdout already set")
+	}
+	if c.Stderr != nil {
+		return nil, errors.New("exec: Stderr already set")
+	}
+	var out bytes.Buffer
+	c.Stdout = &out
+	c.Stderr = &out
+	if err := c.start(); err != nil {
+		return nil, err
+	}
+	err := c.Wait()
+	return out.Bytes(), err
+}
+
+// InitFromEnvironment records to the file named by CHRONOGO_EVENTS_FILE,
+// as a TracedCmd asks the child processes it starts to, and returns the
+// recorder so the program can close it before exiting. The recording's
+// metadata links it to the parent through CHRONOGO_PARENT_ID. It returns
+// nil and does nothing when the variable isn't set.
+func InitFromEnvironment() (*recorder.FileRecorder, error) {
+	path := os.Getenv(recorder.EventsFileEnv)
+	if path == "" {
+		return nil, nil
+	}
+	rec, err := recorder.NewFileRecorder(path)
+	if err != nil {
+		return nil, err
+	}
+	InitInstrumentation(rec)
+	return rec, nil
+}
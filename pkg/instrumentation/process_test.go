@@ -0,0 +1,103 @@
+package instrumentation
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+// TestHelperChild is the child of the parent/child fixture: an
+// instrumented program that records where its parent asks it to
+func TestHelperChild(t *testing.T) {
+	if os.Getenv("CHRONOGO_HELPER_CHILD") != "1" {
+		return
+	}
+	rec, err := InitFromEnvironment()
+	if err != nil || rec == nil {
+		os.Exit(2)
+	}
+	FuncEntry("child.main", "child.go", 5)
+	RecordStatement("child.main", "child.go", 6, "work()")
+	FuncExit("child.main", "child.go", 7)
+	InitInstrumentation(nil)
+	if err := rec.Close(); err != nil {
+		os.Exit(3)
+	}
+	os.Exit(0)
+}
+
+func TestTracedCommand(t *testing.T) {
+	dir := t.TempDir()
+	rec := recorder.NewInMemoryRecorder()
+	InitInstrumentation(rec)
+	defer InitInstrumentation(nil)
+
+	// The parent of the fixture
+	FuncEntry("parent.main", "parent.go", 10)
+	cmd := TracedCommand(os.Args[0], "-test.run=^TestHelperChild$", "--", "-token=secret123")
+	cmd.Env = append(os.Environ(), "CHRONOGO_HELPER_CHILD=1")
+	cmd.EventsFile = filepath.Join(dir, "child.events")
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Child failed: %v", err)
+	}
+	FuncExit("parent.main", "parent.go", 12)
+
+	parent := rec.GetEvents()
+	var spawnEvent recorder.Event
+	for _, e := range parent {
+		if e.Type == recorder.ProcessSpawnEvent {
+			spawnEvent = e
+		}
+	}
+	spawn, ok := recorder.ParseProcessSpawn(spawnEvent.Details)
+	if !ok {
+		t.Fatalf("No spawn event recorded: %+v", parent)
+	}
+	if spawn.PID != cmd.Process.Pid || spawn.EventsFile != cmd.EventsFile {
+		t.Errorf("Spawn = %+v, want pid %d recording to %s", spawn, cmd.Process.Pid, cmd.EventsFile)
+	}
+	if strings.Contains(spawn.Command, "secret123") {
+		t.Errorf("Spawn command wasn't redacted: %s", spawn.Command)
+	}
+	if !strings.HasSuffix(spawnEvent.File, "process_test.go") {
+		t.Errorf("Spawn recorded at %s, want the test", spawnEvent.File)
+	}
+
+	md, err := recorder.ReadMetadata(cmd.EventsFile)
+	if err != nil || md == nil {
+		t.Fatalf("Child recording has no metadata: %v", err)
+	}
+	if md.ParentID != spawnEvent.ID {
+		t.Errorf("Child names parent event %d, want %d", md.ParentID, spawnEvent.ID)
+	}
+
+	// The parent's recording stitched with the child's
+	parentFile := filepath.Join(dir, "parent.events")
+	if err := recorder.WriteEventsFile(parentFile, parent, recorder.DefaultFileRecorderOptions()); err != nil {
+		t.Fatal(err)
+	}
+	merged, err := recorder.ReadProcessTree(parentFile)
+	if err != nil {
+		t.Fatalf("ReadProcessTree failed: %v", err)
+	}
+	if len(merged) != len(parent)+3 {
+		t.Fatalf("Expected %d events, got %d: %+v", len(parent)+3, len(merged), merged)
+	}
+	childTag := fmt.Sprintf("pid:%d", spawn.PID)
+	var funcs []string
+	for _, e := range merged {
+		if e.HasTag(childTag) {
+			funcs = append(funcs, e.FuncName)
+		}
+	}
+	if len(funcs) != 3 || funcs[0] != "child.main" {
+		t.Errorf("Expected the child's 3 events tagged %s, got %v", childTag, funcs)
+	}
+	if merged[len(merged)-1].FuncName != "parent.main" {
+		t.Errorf("Expected the parent's exit last, got %+v", merged[len(merged)-1])
+	}
+}
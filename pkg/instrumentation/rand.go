@@ -0,0 +1,50 @@
+package instrumentation
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+// Rand is the subset of *rand.Rand used by instrumented programs. Both
+// TracedRand and the replay-side generator satisfy it, so code written
+// against Rand can be re-executed with recorded values.
+type Rand interface {
+	Intn(n int) int
+	Float64() float64
+}
+
+// TracedRand wraps a rand.Rand and records its seed and every value drawn
+// from it as RngEvents, so a re-execution can be fed the same values
+type TracedRand struct {
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+// NewTracedRand creates a traced random number generator with the given seed
+func NewTracedRand(seed int64) *TracedRand {
+	recordInput(recorder.RngEvent, fmt.Sprintf("Rand seed %d", seed))
+	return &TracedRand{rnd: rand.New(rand.NewSource(seed))}
+}
+
+// Intn returns a non-negative pseudo-random number in [0,n) and records it
+func (r *TracedRand) Intn(n int) int {
+	r.mu.Lock()
+	v := r.rnd.Intn(n)
+	r.mu.Unlock()
+
+	recordInput(recorder.RngEvent, fmt.Sprintf("Rand Intn(%d) = %d", n, v))
+	return v
+}
+
+// Float64 returns a pseudo-random number in [0.0,1.0) and records it
+func (r *TracedRand) Float64() float64 {
+	r.mu.Lock()
+	v := r.rnd.Float64()
+	r.mu.Unlock()
+
+	recordInput(recorder.RngEvent, fmt.Sprintf("Rand Float64() = %v", v))
+	return v
+}
@@ -9,6 +9,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/willibrandon/ChronoGo/pkg/recorder"
@@ -25,6 +26,16 @@ type traceIntegration struct {
 	nextMutexID     int32
 	ctx             context.Context
 	cancel          context.CancelFunc
+	traceFile       *os.File // Runtime trace output, see MergeRuntimeTrace
+
+	statesMu sync.Mutex
+	states   map[int64]goroutineState // Last state recorded for each runtime goroutine ID
+}
+
+// goroutineState is the state the monitor last recorded for a goroutine
+type goroutineState struct {
+	state string
+	at    time.Time
 }
 
 var (
@@ -44,11 +55,12 @@ func InitRuntimeTracing(rec recorder.Recorder) error {
 		ctx, cancel := context.WithCancel(context.Background())
 		traceInt = &traceIntegration{
 			recorder:        rec,
-			nextGoroutineID: 1, // Start at 1 (main goroutine)
+			nextGoroutineID: 2, // 1 is the main goroutine
 			nextChannelID:   1,
 			nextMutexID:     1,
 			ctx:             ctx,
 			cancel:          cancel,
+			states:          make(map[int64]goroutineState),
 		}
 
 		// Store the main goroutine mapping
@@ -70,6 +82,7 @@ func InitRuntimeTracing(rec recorder.Recorder) error {
 			initErr = fmt.Errorf("failed to start runtime tracing: %v", err)
 			return
 		}
+		traceInt.traceFile = f
 
 		// Initialize our global recorder for manual instrumentation
 		InitInstrumentation(rec)
@@ -86,7 +99,20 @@ func StopRuntimeTracing() {
 	if traceInt != nil && traceInt.cancel != nil {
 		traceInt.cancel()
 		trace.Stop()
+		if traceInt.traceFile != nil {
+			traceInt.traceFile.Close()
+		}
+	}
+}
+
+// RuntimeTraceFile returns the path of the runtime trace written since
+// InitRuntimeTracing, or "" if tracing was never started. Once tracing is
+// stopped it can be merged into the recording with MergeRuntimeTrace.
+func RuntimeTraceFile() string {
+	if traceInt == nil || traceInt.traceFile == nil {
+		return ""
 	}
+	return traceInt.traceFile.Name()
 }
 
 // monitorGoroutines periodically scans for new goroutines using runtime.Stack()
@@ -112,75 +138,102 @@ func captureGoroutineInfo() {
 	stacks := string(buf[:n])
 
 	// Parse goroutine info from stack trace
+	alive := make(map[int64]bool)
 	for _, stack := range strings.Split(stacks, "\n\n") {
 		if strings.HasPrefix(stack, "goroutine ") {
-			parseGoroutineStack(stack)
+			if gid := parseGoroutineStack(stack); gid != 0 {
+				alive[gid] = true
+			}
+		}
+	}
+
+	// Forget goroutines that have exited
+	traceInt.statesMu.Lock()
+	for gid := range traceInt.states {
+		if !alive[gid] {
+			delete(traceInt.states, gid)
 		}
 	}
+	traceInt.statesMu.Unlock()
 }
 
-// parseGoroutineStack extracts goroutine information from a stack trace
-func parseGoroutineStack(stack string) {
+// parseGoroutineStack extracts goroutine information from a stack trace and
+// records the goroutine's state if it changed since the last one recorded,
+// at most once per CurrentOptions.GoroutineStateInterval. It returns the
+// runtime goroutine ID, or 0 if the stack couldn't be parsed.
+func parseGoroutineStack(stack string) int64 {
 	// Get the goroutine ID from the first line, format: "goroutine 1 [running]:"
-	firstLine := strings.Split(stack, "\n")[0]
-	parts := strings.Fields(firstLine)
+	lines := strings.Split(stack, "\n")
+	parts := strings.Fields(lines[0])
 	if len(parts) < 2 {
-		return
+		return 0
 	}
 
 	runtimeGID, err := strconv.ParseInt(parts[1], 10, 64)
 	if err != nil {
-		return
+		return 0
 	}
 
 	// Get our internal goroutine ID or assign a new one
-	var ourGID int32
-	if val, ok := traceInt.goroutineMap.Load(runtimeGID); ok {
-		// Make sure we get an int32, not interface{} cast to int32
-		switch v := val.(type) {
-		case int32:
-			ourGID = v
-		case int:
-			ourGID = int32(v)
-		case int64:
-			ourGID = int32(v)
-		default:
-			// If it's some other type, assign a new ID to be safe
-			// This shouldn't normally happen
-			fmt.Printf("Warning: Unexpected type in goroutine map: %T\n", val)
-			ourGID = traceInt.nextGoroutineID
-			traceInt.nextGoroutineID++
-			traceInt.goroutineMap.Store(runtimeGID, ourGID)
-		}
-	} else {
-		ourGID = traceInt.nextGoroutineID
-		traceInt.nextGoroutineID++
-		traceInt.goroutineMap.Store(runtimeGID, ourGID)
-
-		// Record the new goroutine creation
-		GoroutineCreate(int(ourGID))
-	}
+	ourGID := assignGoroutineID(runtimeGID)
 
-	// Extract the goroutine state (running, waiting, etc.)
+	// Extract the goroutine state, e.g. "running" or "chan receive" from
+	// "[chan receive, 2 minutes]"
 	state := "unknown"
-	if len(parts) >= 3 {
-		state = strings.Trim(parts[2], "[]:")
+	if open, end := strings.Index(lines[0], "["), strings.LastIndex(lines[0], "]"); open >= 0 && end > open {
+		state, _, _ = strings.Cut(lines[0][open+1:end], ",")
 	}
 
-	// Record state changes for significant states
-	if state == "running" || state == "waiting" || state == "locked" {
-		if traceInt.recorder != nil {
-			err := traceInt.recorder.RecordEvent(recorder.Event{
-				ID:        time.Now().UnixNano(),
-				Timestamp: time.Now(),
-				Type:      recorder.GoroutineSwitch,
-				Details:   fmt.Sprintf("Goroutine %d state: %s", ourGID, state),
-			})
-			if err != nil {
-				fmt.Printf("Error recording goroutine state change: %v\n", err)
-			}
+	now := time.Now()
+	traceInt.statesMu.Lock()
+	last, seen := traceInt.states[runtimeGID]
+	if seen && (last.state == state || now.Sub(last.at) < CurrentOptions.GoroutineStateInterval) {
+		traceInt.statesMu.Unlock()
+		return runtimeGID
+	}
+	traceInt.states[runtimeGID] = goroutineState{state: state, at: now}
+	traceInt.statesMu.Unlock()
+
+	if traceInt.recorder != nil {
+		funcName, file, line := stackTop(lines)
+		err := traceInt.recorder.RecordEvent(recorder.Event{
+			ID:        now.UnixNano(),
+			Timestamp: now,
+			Type:      recorder.GoroutineSwitch,
+			Details:   fmt.Sprintf("Goroutine %d state: %s", ourGID, state),
+			File:      file,
+			Line:      line,
+			FuncName:  funcName,
+			// The monitor records this about another goroutine
+			GoroutineID: int(ourGID),
+		})
+		if err != nil {
+			fmt.Printf("Error recording goroutine state change: %v\n", err)
 		}
 	}
+	return runtimeGID
+}
+
+// stackTop returns the function a goroutine is in, and its file and line,
+// from the lines of its stack trace, e.g.
+//
+//	main.worker(0xc000012345)
+//		/src/app/main.go:42 +0x3d
+func stackTop(lines []string) (funcName, file string, line int) {
+	if len(lines) < 3 {
+		return "", "", 0
+	}
+	funcName = lines[1]
+	if i := strings.LastIndex(funcName, "("); i > 0 {
+		funcName = funcName[:i]
+	}
+
+	location, _, _ := strings.Cut(strings.TrimSpace(lines[2]), " +0x")
+	if i := strings.LastIndex(location, ":"); i > 0 {
+		file = location[:i]
+		line, _ = strconv.Atoi(location[i+1:])
+	}
+	return funcName, file, line
 }
 
 // TraceChannelOperation records a channel operation using our instrumentation and runtime trace
@@ -311,32 +364,51 @@ func getGoroutineID() int64 {
 
 // getGoroutineIDOrAssign returns our internal goroutine ID for current goroutine
 func getGoroutineIDOrAssign() int32 {
-	runtimeGID := getGoroutineID()
+	return assignGoroutineID(getGoroutineID())
+}
 
-	// Check if we already have this goroutine ID mapped
+// assignGoroutineID returns our internal ID for a runtime goroutine ID,
+// assigning the next one and recording the goroutine's creation the first
+// time it is seen. Goroutines and the monitor may race to assign an ID;
+// the first to store it wins.
+func assignGoroutineID(runtimeGID int64) int32 {
 	if val, ok := traceInt.goroutineMap.Load(runtimeGID); ok {
-		// Make sure we get an int32, not interface{} cast to int32
+		// The main goroutine is stored as an int
 		switch v := val.(type) {
 		case int32:
 			return v
 		case int:
 			return int32(v)
-		case int64:
-			return int32(v)
-		default:
-			// If it's some other type, assign a new ID to be safe
-			// This shouldn't normally happen
-			fmt.Printf("Warning: Unexpected type in goroutine map: %T\n", val)
 		}
 	}
 
-	// Assign a new ID
-	ourGID := traceInt.nextGoroutineID
-	traceInt.nextGoroutineID++
-	traceInt.goroutineMap.Store(runtimeGID, ourGID)
+	ourGID := atomic.AddInt32(&traceInt.nextGoroutineID, 1) - 1
+	if val, loaded := traceInt.goroutineMap.LoadOrStore(runtimeGID, ourGID); loaded {
+		if v, ok := val.(int32); ok {
+			return v
+		}
+		return int32(val.(int))
+	}
 
 	// Record the new goroutine
 	GoroutineCreate(int(ourGID))
-
 	return ourGID
 }
+
+// stampGoroutines reports whether events are stamped with the goroutine
+// that recorded them: when asked to, and whenever the ID is needed to match
+// events to GoroutineSwitch events or to open tag regions
+func stampGoroutines() bool {
+	return CurrentOptions.GoroutineIDs || atomic.LoadInt64(&openRegions) > 0 ||
+		traceInt != nil && traceInt.ctx.Err() == nil
+}
+
+// currentGoroutineID returns the ID events of the current goroutine are
+// stamped with: our sequential ID while runtime tracing is on, so that it
+// matches GoroutineSwitch events, and the runtime goroutine ID otherwise
+func currentGoroutineID() int {
+	if traceInt != nil && traceInt.ctx.Err() == nil {
+		return int(getGoroutineIDOrAssign())
+	}
+	return int(getGoroutineID())
+}
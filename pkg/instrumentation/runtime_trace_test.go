@@ -1,6 +1,8 @@
 package instrumentation
 
 import (
+	"context"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -57,6 +59,39 @@ func TestAutomaticConcurrencyRecording(t *testing.T) {
 	}
 }
 
+func TestGoroutineIDsUnderTracing(t *testing.T) {
+	rec := recorder.NewInMemoryRecorder()
+	if err := InitRuntimeTracing(rec); err != nil {
+		t.Fatalf("Failed to initialize runtime tracing: %v", err)
+	}
+	defer StopRuntimeTracing()
+	defer InitInstrumentation(nil)
+
+	RecordStatement("instrumentation.main", "runtime_trace_test.go", 1, "main")
+	for _, name := range []string{"first", "second"} {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			RecordStatement("instrumentation.worker", "runtime_trace_test.go", 2, name)
+		}()
+		<-done
+	}
+
+	ids := map[string]int{}
+	for _, e := range rec.GetEvents() {
+		if strings.HasPrefix(e.FuncName, "instrumentation.") {
+			ids[e.Details[strings.LastIndex(e.Details, " ")+1:]] = e.GoroutineID
+		}
+	}
+	if ids["main"] != 1 {
+		t.Errorf("Expected main's events stamped 1, got %v", ids)
+	}
+	if ids["first"] == 0 || ids["first"] == ids["main"] || ids["second"] == 0 ||
+		ids["second"] == ids["main"] || ids["second"] == ids["first"] {
+		t.Errorf("Expected main and each goroutine stamped with its own ID, got %v", ids)
+	}
+}
+
 func runConcurrentTestProgram() {
 	// Create channels
 	ch := make(chan int)
@@ -152,3 +187,52 @@ func TestGoroutineTracking(t *testing.T) {
 func isGoroutineCreateEvent(details string) bool {
 	return details != "" && (details[0:10] == "Goroutine " && details != "Goroutine switch")
 }
+
+func TestGoroutineStateChanges(t *testing.T) {
+	rec := recorder.NewInMemoryRecorder()
+	originalTrace, originalOptions := traceInt, CurrentOptions
+	defer func() { traceInt, CurrentOptions = originalTrace, originalOptions }()
+	// Stopped, so nothing else stamps events with its IDs
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	traceInt = &traceIntegration{recorder: rec, nextGoroutineID: 2, states: make(map[int64]goroutineState), ctx: ctx, cancel: cancel}
+	CurrentOptions.GoroutineStateInterval = 0
+
+	stack := func(state string) string {
+		return "goroutine 7 [" + state + "]:\nmain.worker(0xc000012345)\n\t/src/app/main.go:42 +0x3d\ncreated by main.main in goroutine 1\n\t/src/app/main.go:10 +0x25"
+	}
+	states := func() []string {
+		var got []string
+		for _, e := range rec.GetEvents() {
+			if strings.Contains(e.Details, "state:") {
+				got = append(got, e.Details[strings.Index(e.Details, "state: ")+7:])
+			}
+		}
+		return got
+	}
+
+	// Unchanged states are recorded once
+	for _, state := range []string{"running", "running", "chan receive, 2 minutes", "chan receive, 3 minutes", "running"} {
+		parseGoroutineStack(stack(state))
+	}
+	if got := strings.Join(states(), ","); got != "running,chan receive,running" {
+		t.Errorf("Expected only the state changes, got %q", got)
+	}
+	for _, e := range rec.GetEvents() {
+		if strings.Contains(e.Details, "state:") && (e.FuncName != "main.worker" || e.File != "/src/app/main.go" || e.Line != 42) {
+			t.Errorf("Expected the goroutine's function and location, got %q at %s:%d", e.FuncName, e.File, e.Line)
+		}
+	}
+
+	// Changes within the interval wait for it to pass
+	CurrentOptions.GoroutineStateInterval = time.Hour
+	parseGoroutineStack(stack("select"))
+	if got := len(states()); got != 3 {
+		t.Errorf("Expected a change within the interval to be held back, got %d state events", got)
+	}
+	traceInt.states[7] = goroutineState{state: "running", at: time.Now().Add(-2 * time.Hour)}
+	parseGoroutineStack(stack("select"))
+	if got := states(); len(got) != 4 || got[3] != "select" {
+		t.Errorf("Expected the change once the interval passed, got %q", got)
+	}
+}
@@ -1,9 +1,14 @@
 package instrumentation
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 // InstrumentationOptions stores configuration for selective instrumentation
@@ -21,8 +26,77 @@ type InstrumentationOptions struct {
 
 	// InstrumentStdlib indicates whether to instrument standard library code
 	InstrumentStdlib bool
+
+	// IncludeFunctions is a list of function name patterns to instrument
+	// within instrumented packages. Empty means all functions.
+	// Patterns are globs where * matches any characters, e.g. "*.String",
+	// or regular expressions between slashes, e.g. "/^main\.log[A-Z]/".
+	// A glob matches the full recorded name, e.g.
+	// "github.com/me/app/util.hash", or, without a slash, its last
+	// package and function, e.g. "util.hash".
+	IncludeFunctions []string
+
+	// ExcludeFunctions is a list of function name patterns to skip, such as
+	// noisy logging wrappers, getters or a hot hash helper, even in
+	// instrumented packages. This takes precedence over IncludeFunctions
+	ExcludeFunctions []string
+
+	// SkipGenerated skips generated files, such as protobuf output or files
+	// with a "// Code generated ... DO NOT EDIT." header
+	SkipGenerated bool
+
+	// TraceIO records reads, writes and closes on TracedFile and TracedConn
+	// values as IOEvents
+	TraceIO bool
+
+	// IOPayload controls how much of the data read or written is recorded
+	// with each IOEvent. Payloads are not recorded by default.
+	IOPayload IOPayloadMode
+
+	// IOPayloadPrefix is the number of bytes recorded with IOPayloadPrefix
+	IOPayloadPrefix int
+
+	// MaxEvents stops recording after this many events, 0 for no limit.
+	// A RecordingStopped event marks where it stopped.
+	MaxEvents int
+
+	// MaxDuration stops recording this long after it started, 0 for no
+	// limit. A RecordingStopped event marks where it stopped.
+	MaxDuration time.Duration
+
+	// GoroutineStateInterval is the least time between two GoroutineSwitch
+	// events the goroutine monitor records for one goroutine. The monitor
+	// only records changes of state; a goroutine flapping between states
+	// faster than this is recorded in its latest state when the interval
+	// has passed.
+	GoroutineStateInterval time.Duration
+
+	// GoroutineIDs stamps every event with the goroutine that recorded it
+	// even while runtime tracing is off. Finding the goroutine takes a
+	// stack trace, a few microseconds per event, so it is off by default.
+	// Events are always stamped while runtime tracing is on or a TagRegion
+	// is open, and panics and recovers are always stamped.
+	GoroutineIDs bool
+
+	// MaxDetailsLen truncates the Details of function entry and statement
+	// events to this many bytes, 0 for no limit. Truncated details end in
+	// an ellipsis and keep their original length in Event.DetailsLen.
+	MaxDetailsLen int
 }
 
+// IOPayloadMode selects what an IOEvent records about the data transferred
+type IOPayloadMode int
+
+const (
+	// IOPayloadNone records only the byte count
+	IOPayloadNone IOPayloadMode = iota
+	// IOPayloadHash records a truncated SHA-256 of the data
+	IOPayloadHash
+	// IOPayloadPrefix records the first IOPayloadPrefix bytes, with
+	// sensitive values redacted
+	IOPayloadPrefix
+)
+
 // DefaultInstrumentationOptions returns the default instrumentation options
 func DefaultInstrumentationOptions() InstrumentationOptions {
 	return InstrumentationOptions{
@@ -30,6 +104,12 @@ func DefaultInstrumentationOptions() InstrumentationOptions {
 		IncludePackages:  []string{}, // Empty means all packages
 		ExcludePackages:  []string{}, // Don't exclude any packages by default
 		InstrumentStdlib: false,      // Don't instrument stdlib by default
+		SkipGenerated:    true,       // Generated code only bloats recordings
+		TraceIO:          false,
+		IOPayload:        IOPayloadNone,
+		IOPayloadPrefix:  32,
+
+		GoroutineStateInterval: 500 * time.Millisecond,
 	}
 }
 
@@ -49,18 +129,22 @@ func loadOptionsFromEnvironment() InstrumentationOptions {
 
 	// CHRONOGO_INSTRUMENT controls which packages to instrument
 	if instruments := os.Getenv("CHRONOGO_INSTRUMENT"); instruments != "" {
-		options.IncludePackages = strings.Split(instruments, ",")
-		for i, pkg := range options.IncludePackages {
-			options.IncludePackages[i] = strings.TrimSpace(pkg)
-		}
+		options.IncludePackages = splitList(instruments)
 	}
 
 	// CHRONOGO_EXCLUDE controls which packages to exclude
 	if excludes := os.Getenv("CHRONOGO_EXCLUDE"); excludes != "" {
-		options.ExcludePackages = strings.Split(excludes, ",")
-		for i, pkg := range options.ExcludePackages {
-			options.ExcludePackages[i] = strings.TrimSpace(pkg)
-		}
+		options.ExcludePackages = splitList(excludes)
+	}
+
+	// CHRONOGO_INSTRUMENT_FUNCS controls which functions to instrument
+	if instruments := os.Getenv("CHRONOGO_INSTRUMENT_FUNCS"); instruments != "" {
+		options.IncludeFunctions = splitList(instruments)
+	}
+
+	// CHRONOGO_EXCLUDE_FUNCS controls which functions to exclude
+	if excludes := os.Getenv("CHRONOGO_EXCLUDE_FUNCS"); excludes != "" {
+		options.ExcludeFunctions = splitList(excludes)
 	}
 
 	// CHRONOGO_INSTRUMENT_STDLIB controls whether to instrument standard library
@@ -68,9 +152,78 @@ func loadOptionsFromEnvironment() InstrumentationOptions {
 		options.InstrumentStdlib = instrumentStdlib == "1" || instrumentStdlib == "true" || instrumentStdlib == "yes"
 	}
 
+	// CHRONOGO_SKIP_GENERATED controls whether generated files are skipped
+	if skipGenerated := os.Getenv("CHRONOGO_SKIP_GENERATED"); skipGenerated != "" {
+		options.SkipGenerated = skipGenerated == "1" || skipGenerated == "true" || skipGenerated == "yes"
+	}
+
+	// CHRONOGO_TRACE_IO controls whether traced files and connections record I/O
+	if traceIO := os.Getenv("CHRONOGO_TRACE_IO"); traceIO != "" {
+		options.TraceIO = traceIO == "1" || traceIO == "true" || traceIO == "yes"
+	}
+
+	// CHRONOGO_IO_PAYLOAD selects payload capture: "hash" or "prefix"
+	switch os.Getenv("CHRONOGO_IO_PAYLOAD") {
+	case "hash":
+		options.IOPayload = IOPayloadHash
+	case "prefix":
+		options.IOPayload = IOPayloadPrefix
+	}
+
+	// CHRONOGO_MAX_EVENTS stops recording after this many events
+	if maxEvents := os.Getenv("CHRONOGO_MAX_EVENTS"); maxEvents != "" {
+		if n, err := strconv.Atoi(maxEvents); err == nil {
+			options.MaxEvents = n
+		} else {
+			fmt.Printf("Warning: Ignoring invalid CHRONOGO_MAX_EVENTS %q: %v\n", maxEvents, err)
+		}
+	}
+
+	// CHRONOGO_MAX_DURATION stops recording after a duration such as "10m"
+	if maxDuration := os.Getenv("CHRONOGO_MAX_DURATION"); maxDuration != "" {
+		if d, err := time.ParseDuration(maxDuration); err == nil {
+			options.MaxDuration = d
+		} else {
+			fmt.Printf("Warning: Ignoring invalid CHRONOGO_MAX_DURATION %q: %v\n", maxDuration, err)
+		}
+	}
+
+	// CHRONOGO_GOROUTINE_STATE_INTERVAL limits how often a goroutine's
+	// state changes are recorded, e.g. "1s"
+	if interval := os.Getenv("CHRONOGO_GOROUTINE_STATE_INTERVAL"); interval != "" {
+		if d, err := time.ParseDuration(interval); err == nil {
+			options.GoroutineStateInterval = d
+		} else {
+			fmt.Printf("Warning: Ignoring invalid CHRONOGO_GOROUTINE_STATE_INTERVAL %q: %v\n", interval, err)
+		}
+	}
+
+	// CHRONOGO_GOROUTINE_IDS stamps every event with its goroutine
+	if goroutineIDs := os.Getenv("CHRONOGO_GOROUTINE_IDS"); goroutineIDs != "" {
+		options.GoroutineIDs = goroutineIDs == "1" || goroutineIDs == "true" || goroutineIDs == "yes"
+	}
+
+	// CHRONOGO_MAX_DETAILS_LEN truncates long event details
+	if maxDetails := os.Getenv("CHRONOGO_MAX_DETAILS_LEN"); maxDetails != "" {
+		if n, err := strconv.Atoi(maxDetails); err == nil {
+			options.MaxDetailsLen = n
+		} else {
+			fmt.Printf("Warning: Ignoring invalid CHRONOGO_MAX_DETAILS_LEN %q: %v\n", maxDetails, err)
+		}
+	}
+
 	return options
 }
 
+// splitList splits a comma-separated environment value, trimming spaces
+func splitList(value string) []string {
+	items := strings.Split(value, ",")
+	for i, item := range items {
+		items[i] = strings.TrimSpace(item)
+	}
+	return items
+}
+
 // ShouldInstrument checks if a package should be instrumented
 func ShouldInstrument(packagePath string) bool {
 	if !CurrentOptions.Enabled {
@@ -118,6 +271,77 @@ func matchesPackagePath(packagePath, pattern string) bool {
 	return matched
 }
 
+// ShouldInstrumentFunction checks if a function in a package should be
+// instrumented, applying the function filters on top of the package filters
+func ShouldInstrumentFunction(packagePath, funcName string) bool {
+	if !ShouldInstrument(packagePath) {
+		return false
+	}
+
+	// Check if function is explicitly excluded
+	for _, exclude := range CurrentOptions.ExcludeFunctions {
+		if matchesFunctionName(funcName, exclude) {
+			return false
+		}
+	}
+
+	// If no includes specified, instrument everything except exclusions
+	if len(CurrentOptions.IncludeFunctions) == 0 {
+		return true
+	}
+
+	// Check if function is explicitly included
+	for _, include := range CurrentOptions.IncludeFunctions {
+		if matchesFunctionName(funcName, include) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// functionPatterns caches compiled function name patterns, since they are
+// matched on every recorded event. Invalid patterns are cached as nil.
+var functionPatterns sync.Map
+
+// matchesFunctionName checks if a function name matches a pattern
+func matchesFunctionName(funcName, pattern string) bool {
+	cached, ok := functionPatterns.Load(pattern)
+	if !ok {
+		cached, _ = functionPatterns.LoadOrStore(pattern, compileFunctionPattern(pattern))
+	}
+
+	re := cached.(*regexp.Regexp)
+	return re != nil && re.MatchString(funcName)
+}
+
+// compileFunctionPattern compiles a function pattern to a regular expression.
+// Unlike package patterns, * in a glob also matches dots and slashes, so
+// "*.String" matches methods in any package. A glob without a slash may be
+// preceded by any import path, so "util.hash" matches
+// "github.com/me/app/util.hash".
+func compileFunctionPattern(pattern string) *regexp.Regexp {
+	var expr string
+	if len(pattern) > 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		expr = pattern[1 : len(pattern)-1]
+	} else {
+		expr = regexp.QuoteMeta(pattern)
+		expr = strings.ReplaceAll(expr, `\*`, ".*")
+		expr = strings.ReplaceAll(expr, `\?`, ".")
+		if !strings.Contains(pattern, "/") {
+			expr = "(?:.*/)?" + expr
+		}
+		expr = "^" + expr + "$"
+	}
+
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		fmt.Printf("Warning: Ignoring invalid function pattern %q: %v\n", pattern, err)
+		return nil
+	}
+	return re
+}
+
 // SetInstrumentationOptions sets the current instrumentation options
 func SetInstrumentationOptions(options InstrumentationOptions) {
 	CurrentOptions = options
@@ -125,12 +125,192 @@ func TestShouldInstrument(t *testing.T) {
 	}
 }
 
+func TestShouldInstrumentFunction(t *testing.T) {
+	// Save current options and restore at end of test
+	originalOptions := CurrentOptions
+	defer func() {
+		CurrentOptions = originalOptions
+	}()
+
+	const pkg = "github.com/willibrandon/ChronoGo/pkg/test"
+
+	tests := []struct {
+		name             string
+		options          InstrumentationOptions
+		packagePath      string
+		funcName         string
+		shouldInstrument bool
+	}{
+		{
+			name: "all functions enabled",
+			options: InstrumentationOptions{
+				Enabled: true,
+			},
+			packagePath:      pkg,
+			funcName:         pkg + ".Process",
+			shouldInstrument: true,
+		},
+		{
+			name: "specific function excluded",
+			options: InstrumentationOptions{
+				Enabled:          true,
+				ExcludeFunctions: []string{pkg + ".logDebug"},
+			},
+			packagePath:      pkg,
+			funcName:         pkg + ".logDebug",
+			shouldInstrument: false,
+		},
+		{
+			name: "other function not excluded",
+			options: InstrumentationOptions{
+				Enabled:          true,
+				ExcludeFunctions: []string{pkg + ".logDebug"},
+			},
+			packagePath:      pkg,
+			funcName:         pkg + ".Process",
+			shouldInstrument: true,
+		},
+		{
+			name: "wildcard exclude matches methods in any package",
+			options: InstrumentationOptions{
+				Enabled:          true,
+				ExcludeFunctions: []string{"*.String"},
+			},
+			packagePath:      pkg,
+			funcName:         pkg + ".(*Item).String",
+			shouldInstrument: false,
+		},
+		{
+			name: "wildcard exclude is anchored",
+			options: InstrumentationOptions{
+				Enabled:          true,
+				ExcludeFunctions: []string{"*.String"},
+			},
+			packagePath:      pkg,
+			funcName:         pkg + ".Stringify",
+			shouldInstrument: true,
+		},
+		{
+			name: "regex exclude",
+			options: InstrumentationOptions{
+				Enabled:          true,
+				ExcludeFunctions: []string{`/\.(Get|get)[A-Z]\w*$/`},
+			},
+			packagePath:      pkg,
+			funcName:         pkg + ".(*Config).GetTimeout",
+			shouldInstrument: false,
+		},
+		{
+			name: "wildcard include",
+			options: InstrumentationOptions{
+				Enabled:          true,
+				IncludeFunctions: []string{"*.handle*"},
+			},
+			packagePath:      pkg,
+			funcName:         pkg + ".handleRequest",
+			shouldInstrument: true,
+		},
+		{
+			name: "function not included",
+			options: InstrumentationOptions{
+				Enabled:          true,
+				IncludeFunctions: []string{"*.handle*"},
+			},
+			packagePath:      pkg,
+			funcName:         pkg + ".Process",
+			shouldInstrument: false,
+		},
+		{
+			name: "exclude takes precedence over include",
+			options: InstrumentationOptions{
+				Enabled:          true,
+				IncludeFunctions: []string{"*.handle*"},
+				ExcludeFunctions: []string{"*.handleHealth"},
+			},
+			packagePath:      pkg,
+			funcName:         pkg + ".handleHealth",
+			shouldInstrument: false,
+		},
+		{
+			name: "package exclusion still applies",
+			options: InstrumentationOptions{
+				Enabled:          true,
+				ExcludePackages:  []string{pkg},
+				IncludeFunctions: []string{"*.handle*"},
+			},
+			packagePath:      pkg,
+			funcName:         pkg + ".handleRequest",
+			shouldInstrument: false,
+		},
+		{
+			name: "pkg.Func exclude skips a function in an included package",
+			options: InstrumentationOptions{
+				Enabled:          true,
+				IncludePackages:  []string{pkg},
+				ExcludeFunctions: []string{"test.hash"},
+			},
+			packagePath:      pkg,
+			funcName:         pkg + ".hash",
+			shouldInstrument: false,
+		},
+		{
+			name: "pkg.Func exclude only matches whole package names",
+			options: InstrumentationOptions{
+				Enabled:          true,
+				ExcludeFunctions: []string{"test.hash"},
+			},
+			packagePath:      "github.com/willibrandon/ChronoGo/pkg/mytest",
+			funcName:         "github.com/willibrandon/ChronoGo/pkg/mytest.hash",
+			shouldInstrument: true,
+		},
+		{
+			name: "exact exclude",
+			options: InstrumentationOptions{
+				Enabled:          true,
+				IncludePackages:  []string{pkg},
+				ExcludeFunctions: []string{pkg + ".hash"},
+			},
+			packagePath:      pkg,
+			funcName:         pkg + ".hash",
+			shouldInstrument: false,
+		},
+		{
+			name: "invalid regex matches nothing",
+			options: InstrumentationOptions{
+				Enabled:          true,
+				ExcludeFunctions: []string{"/([/"},
+			},
+			packagePath:      pkg,
+			funcName:         pkg + ".Process",
+			shouldInstrument: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Set options for this test
+			CurrentOptions = tt.options
+
+			// Check if should instrument
+			result := ShouldInstrumentFunction(tt.packagePath, tt.funcName)
+
+			// Verify result
+			if result != tt.shouldInstrument {
+				t.Errorf("ShouldInstrumentFunction(%q, %q) = %v, want %v",
+					tt.packagePath, tt.funcName, result, tt.shouldInstrument)
+			}
+		})
+	}
+}
+
 func TestLoadOptionsFromEnvironment(t *testing.T) {
 	// Save original environment values
 	origEnabled := os.Getenv("CHRONOGO_ENABLED")
 	origInstrument := os.Getenv("CHRONOGO_INSTRUMENT")
 	origExclude := os.Getenv("CHRONOGO_EXCLUDE")
 	origStdlib := os.Getenv("CHRONOGO_INSTRUMENT_STDLIB")
+	origFuncs := os.Getenv("CHRONOGO_INSTRUMENT_FUNCS")
+	origExcludeFuncs := os.Getenv("CHRONOGO_EXCLUDE_FUNCS")
 
 	// Restore environment values at end of test
 	defer func() {
@@ -138,6 +318,8 @@ func TestLoadOptionsFromEnvironment(t *testing.T) {
 		os.Setenv("CHRONOGO_INSTRUMENT", origInstrument)
 		os.Setenv("CHRONOGO_EXCLUDE", origExclude)
 		os.Setenv("CHRONOGO_INSTRUMENT_STDLIB", origStdlib)
+		os.Setenv("CHRONOGO_INSTRUMENT_FUNCS", origFuncs)
+		os.Setenv("CHRONOGO_EXCLUDE_FUNCS", origExcludeFuncs)
 	}()
 
 	// Test with explicit values
@@ -145,6 +327,8 @@ func TestLoadOptionsFromEnvironment(t *testing.T) {
 	os.Setenv("CHRONOGO_INSTRUMENT", "pkg1,pkg2,pkg3")
 	os.Setenv("CHRONOGO_EXCLUDE", "test,benchmark")
 	os.Setenv("CHRONOGO_INSTRUMENT_STDLIB", "true")
+	os.Setenv("CHRONOGO_INSTRUMENT_FUNCS", "*.handle*")
+	os.Setenv("CHRONOGO_EXCLUDE_FUNCS", "*.String, /log[A-Z]/")
 
 	options := loadOptionsFromEnvironment()
 
@@ -181,6 +365,15 @@ func TestLoadOptionsFromEnvironment(t *testing.T) {
 		t.Error("Expected InstrumentStdlib to be true")
 	}
 
+	if len(options.IncludeFunctions) != 1 || options.IncludeFunctions[0] != "*.handle*" {
+		t.Errorf("Expected include functions [*.handle*], got %v", options.IncludeFunctions)
+	}
+
+	if len(options.ExcludeFunctions) != 2 || options.ExcludeFunctions[0] != "*.String" ||
+		options.ExcludeFunctions[1] != "/log[A-Z]/" {
+		t.Errorf("Expected exclude functions [*.String /log[A-Z]/], got %v", options.ExcludeFunctions)
+	}
+
 	// Test with disabled values
 	os.Setenv("CHRONOGO_ENABLED", "0")
 	os.Setenv("CHRONOGO_INSTRUMENT", "")
@@ -2,6 +2,7 @@ package instrumentation
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -122,10 +123,34 @@ func TestStressConcurrentRecording(t *testing.T) {
 			numGoroutines, counts[recorder.ChannelOperation])
 	}
 
-	// Check that we recorded at least some goroutine switches
-	if counts[recorder.GoroutineSwitch] < numGoroutines {
-		t.Errorf("Expected at least %d goroutine switches, got %d",
-			numGoroutines, counts[recorder.GoroutineSwitch])
+	// Goroutine events are creations, plus the state changes the monitor
+	// saw. It records a state only when it differs from the last one
+	// recorded for the goroutine, so an idle goroutine adds nothing.
+	creations := 0
+	transitions := 0
+	lastState := make(map[int]string)
+	for _, event := range events {
+		if event.Type != recorder.GoroutineSwitch {
+			continue
+		}
+		if strings.HasSuffix(event.Details, " created") {
+			creations++
+			continue
+		}
+		_, state, ok := strings.Cut(event.Details, " state: ")
+		if !ok {
+			continue
+		}
+		if lastState[event.GoroutineID] == state {
+			t.Errorf("Goroutine %d recorded state %q twice in a row", event.GoroutineID, state)
+		}
+		lastState[event.GoroutineID] = state
+		transitions++
+	}
+	t.Logf("Goroutine creations: %d, state transitions: %d", creations, transitions)
+
+	if creations < numGoroutines {
+		t.Errorf("Expected at least %d goroutine creations, got %d", numGoroutines, creations)
 	}
 }
 
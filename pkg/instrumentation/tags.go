@@ -0,0 +1,67 @@
+package instrumentation
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+var (
+	regionTags   sync.Map   // Goroutine ID to the labels of its open tag regions, []string
+	regionTagsMu sync.Mutex // Serializes changes to regionTags
+	openRegions  int64      // Tag regions not yet ended, so untagged recording skips the lookup
+)
+
+// TagRegion tags the events the calling goroutine records until the
+// returned function is called, e.g.
+//
+//	defer instrumentation.TagRegion("suspicious")()
+//
+// Regions nest; events get the labels of every open region.
+func TagRegion(labels ...string) (end func()) {
+	gid := currentGoroutineID()
+
+	regionTagsMu.Lock()
+	var open []string
+	if v, ok := regionTags.Load(gid); ok {
+		open = v.([]string)
+	}
+	depth := len(open)
+	// A fresh array, since events recorded so far share the old one
+	regionTags.Store(gid, append(open[:depth:depth], labels...))
+	regionTagsMu.Unlock()
+	atomic.AddInt64(&openRegions, 1)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			regionTagsMu.Lock()
+			if v, ok := regionTags.Load(gid); ok {
+				if open := v.([]string); depth > 0 && depth <= len(open) {
+					regionTags.Store(gid, open[:depth:depth])
+				} else {
+					regionTags.Delete(gid)
+				}
+			}
+			regionTagsMu.Unlock()
+			atomic.AddInt64(&openRegions, -1)
+		})
+	}
+}
+
+// tagEvent adds the labels of the tag regions open on the event's goroutine
+func tagEvent(e *recorder.Event) {
+	if atomic.LoadInt64(&openRegions) == 0 {
+		return
+	}
+	v, ok := regionTags.Load(e.GoroutineID)
+	if !ok {
+		return
+	}
+	for _, label := range v.([]string) {
+		if !e.HasTag(label) {
+			e.Tags = append(e.Tags, label)
+		}
+	}
+}
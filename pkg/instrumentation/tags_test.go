@@ -0,0 +1,60 @@
+package instrumentation
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+func TestTagRegion(t *testing.T) {
+	rec := recorder.NewInMemoryRecorder()
+	InitInstrumentation(rec)
+	defer InitInstrumentation(nil)
+
+	RecordStatement("instrumentation.untagged", "tags_test.go", 15, "before")
+	endOuter := TagRegion("suspicious")
+	RecordStatement("instrumentation.outer", "tags_test.go", 17, "outer")
+	endInner := TagRegion("retry")
+	RecordStatement("instrumentation.inner", "tags_test.go", 19, "inner")
+
+	// Another goroutine's events aren't in the region
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		RecordStatement("instrumentation.other", "tags_test.go", 25, "other goroutine")
+	}()
+	wg.Wait()
+
+	endInner()
+	endInner() // Ending twice is harmless
+	RecordStatement("instrumentation.outer", "tags_test.go", 31, "outer again")
+	endOuter()
+	RecordStatement("instrumentation.untagged", "tags_test.go", 33, "after")
+
+	want := map[string][]string{
+		"before":          nil,
+		"outer":           {"suspicious"},
+		"inner":           {"suspicious", "retry"},
+		"other goroutine": nil,
+		"outer again":     {"suspicious"},
+		"after":           nil,
+	}
+	seen := 0
+	for _, event := range ownEvents(rec.GetEvents()) {
+		for details, tags := range want {
+			if strings.HasSuffix(event.Details, ": "+details) {
+				seen++
+				if !slices.Equal(event.Tags, tags) {
+					t.Errorf("Event %q: expected tags %v, got %v", details, tags, event.Tags)
+				}
+			}
+		}
+	}
+	if seen != len(want) {
+		t.Errorf("Expected %d events, matched %d", len(want), seen)
+	}
+}
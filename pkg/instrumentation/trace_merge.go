@@ -0,0 +1,225 @@
+package instrumentation
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+// traceTool starts a command that prints the events of a runtime trace, one
+// per line. The Go toolchain's trace tool parses every trace format the
+// runtime has written, so no parser needs to be vendored.
+var traceTool = func(traceFile string) *exec.Cmd {
+	return exec.Command("go", "tool", "trace", "-d=parsed", traceFile)
+}
+
+// traceField matches the key=value fields of a parsed trace event, where
+// values may be quoted, e.g. Reason="chan receive"
+var traceField = regexp.MustCompile(`(\w+)=("(?:[^"\\]|\\.)*"|\S+)`)
+
+// ignoredBlockReasons are waits of runtime goroutines, which would only add noise
+var ignoredBlockReasons = map[string]bool{
+	"system goroutine wait":      true,
+	"GC background sweeper wait": true,
+	"GC scavenge wait":           true,
+	"GC worker (idle)":           true,
+	"GC weak to strong wait":     true,
+	"finalizer wait":             true,
+	"forced GC (idle)":           true,
+}
+
+// MergeRuntimeTrace injects the goroutine block and unblock events and the
+// GC cycles of a runtime/trace capture, such as the one written since
+// InitRuntimeTracing, into a recording as RuntimeEvents. The merged events
+// are ordered by timestamp.
+//
+// Trace times are converted to wall clock times using the clock snapshots
+// in the trace, so the Go toolchain that parses it must be Go 1.25 or later.
+func MergeRuntimeTrace(traceFile string, events []recorder.Event) ([]recorder.Event, error) {
+	cmd := traceTool(traceFile)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read runtime trace: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to run trace tool: %v", err)
+	}
+
+	runtimeEvents, parseErr := parseRuntimeTrace(stdout)
+	// Drain the rest of the output so the tool can exit
+	io.Copy(io.Discard, stdout)
+	if err := cmd.Wait(); err != nil {
+		return nil, fmt.Errorf("failed to parse runtime trace %s: %v: %s", traceFile, err, strings.TrimSpace(stderr.String()))
+	}
+	if parseErr != nil {
+		return nil, parseErr
+	}
+
+	merged := make([]recorder.Event, 0, len(events)+len(runtimeEvents))
+	merged = append(merged, events...)
+	merged = append(merged, runtimeEvents...)
+	recorder.StableSort(merged)
+	return merged, nil
+}
+
+// parseRuntimeTrace converts the trace tool's parsed events into RuntimeEvents
+func parseRuntimeTrace(r io.Reader) ([]recorder.Event, error) {
+	var events []recorder.Event
+	var syncTrace int64 // Trace time of the last clock snapshot
+	var syncWall time.Time
+	blocked := make(map[int64]bool)
+
+	// goID is the runtime ID of the goroutine the event is about, 0 for none
+	add := func(traceTime int64, details string, goID int64, fields map[string]string) {
+		ts := syncWall.Add(time.Duration(traceTime - syncTrace))
+		e := recorder.Event{
+			ID:        ts.UnixNano(),
+			Timestamp: ts,
+			Type:      recorder.RuntimeEvent,
+			Details:   details,
+		}
+		if goID > 0 {
+			e.GoroutineID = recordedGoroutineID(goID)
+			// M and P are where the event happened, which is the
+			// goroutine's own thread only if it was the one running
+			if fields["G"] == strconv.FormatInt(goID, 10) {
+				e.OSThreadID, e.ProcID = threadOf(fields)
+			}
+		}
+		events = append(events, e)
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		// Event lines start with the thread; stacks are indented below them
+		if !strings.HasPrefix(line, "M=") {
+			continue
+		}
+		parts := strings.Fields(line)
+		if len(parts) < 5 {
+			continue
+		}
+		kind := parts[3]
+		fields := parseTraceFields(line)
+		traceTime, err := strconv.ParseInt(fields["Time"], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		if kind == "Sync" {
+			wall, err := time.Parse(time.RFC3339Nano, fields["Wall"])
+			if err != nil {
+				continue
+			}
+			syncWall = wall
+			syncTrace = traceTime
+			if t, err := strconv.ParseInt(fields["Trace"], 10, 64); err == nil {
+				syncTrace = t
+			}
+			continue
+		}
+		if syncWall.IsZero() {
+			continue
+		}
+
+		switch kind {
+		case "StateTransition":
+			goID, err := strconv.ParseInt(fields["GoID"], 10, 64)
+			if err != nil {
+				continue // A processor, not a goroutine
+			}
+			if len(parts) < 7 {
+				continue
+			}
+			transition := parts[6]
+			reason := fields["Reason"]
+			switch {
+			case transition == "Running->Waiting" && !ignoredBlockReasons[reason]:
+				blocked[goID] = true
+				add(traceTime, fmt.Sprintf("Goroutine %d blocked: %s", goID, reason), goID, fields)
+			case transition == "Waiting->Runnable" && blocked[goID]:
+				delete(blocked, goID)
+				by, _ := strconv.ParseInt(fields["G"], 10, 64)
+				if by > 0 && by != goID {
+					add(traceTime, fmt.Sprintf("Goroutine %d unblocked by goroutine %d", goID, by), goID, fields)
+				} else {
+					add(traceTime, fmt.Sprintf("Goroutine %d unblocked", goID), goID, fields)
+				}
+			}
+		case "RangeBegin", "RangeEnd":
+			if fields["Name"] != "GC concurrent mark phase" {
+				continue
+			}
+			if kind == "RangeBegin" {
+				add(traceTime, "GC started", 0, fields)
+			} else {
+				add(traceTime, "GC finished", 0, fields)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read runtime trace: %v", err)
+	}
+	if syncWall.IsZero() {
+		return nil, fmt.Errorf("runtime trace has no wall clock snapshot to align it with the recording")
+	}
+	return events, nil
+}
+
+// threadOf returns the OS thread and the processor, counted from 1, of a
+// parsed trace event, 0 for those it wasn't on
+func threadOf(fields map[string]string) (osThreadID int64, procID int) {
+	if m, err := strconv.ParseInt(fields["M"], 10, 64); err == nil && m > 0 {
+		osThreadID = m
+	}
+	if p, err := strconv.Atoi(fields["P"]); err == nil && p >= 0 {
+		procID = p + 1
+	}
+	return osThreadID, procID
+}
+
+// recordedGoroutineID returns the ID events of a runtime goroutine were
+// recorded with, as currentGoroutineID stamps them: the runtime ID if
+// runtime tracing was never initialized, the sequential one if it was and
+// the goroutine was seen, and otherwise 0
+func recordedGoroutineID(runtimeGID int64) int {
+	if traceInt == nil {
+		return int(runtimeGID)
+	}
+	val, ok := traceInt.goroutineMap.Load(runtimeGID)
+	if !ok {
+		return 0
+	}
+	switch v := val.(type) {
+	case int32:
+		return int(v)
+	case int:
+		return v
+	}
+	return 0
+}
+
+// parseTraceFields returns the key=value fields of a parsed trace event, with quotes removed
+func parseTraceFields(line string) map[string]string {
+	fields := make(map[string]string)
+	for _, m := range traceField.FindAllStringSubmatch(line, -1) {
+		value := m[2]
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		}
+		fields[m[1]] = value
+	}
+	return fields
+}
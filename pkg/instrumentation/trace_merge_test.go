@@ -0,0 +1,149 @@
+package instrumentation
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"runtime/trace"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+// capturedTrace is an excerpt of "go tool trace -d=parsed" over a program in
+// which main holds a mutex while goroutine 9 waits for it, then hands it a
+// value over a channel and runs a GC
+const capturedTrace = `M=-1 P=-1 G=-1 Sync Time=7070120609152 N=1 Trace=7070120620352 Mono=7070120620350 Wall=2026-10-16T01:20:20.106328621Z
+M=23550 P=0 G=1 StateTransition Time=7070120625152 GoID=1 Undetermined->Running Reason=""
+M=23550 P=0 G=1 StateTransition Time=7070120647360 GoID=1 Running->Waiting Reason="sleep"
+Stack=
+	time.Sleep @ 0x4a14b3
+		/usr/local/go/src/runtime/time.go:338
+
+M=23550 P=0 G=9 StateTransition Time=7070120651392 GoID=9 Running->Waiting Reason="sync"
+M=23550 P=0 G=8 StateTransition Time=7070120665536 GoID=8 Running->Waiting Reason="system goroutine wait"
+M=23550 P=0 G=-1 StateTransition Time=7070125808320 GoID=1 Waiting->Runnable Reason=""
+M=23550 P=0 G=1 StateTransition Time=7070125814528 GoID=9 Waiting->Runnable Reason=""
+M=23550 P=0 G=-1 StateTransition Time=7070125815000 GoID=8 Waiting->Runnable Reason=""
+M=23550 P=0 G=1 RangeBegin Time=7070125823296 Name="GC concurrent mark phase" Scope=None
+M=23550 P=0 G=1 RangeBegin Time=7070125846528 Name="stop-the-world (GC sweep termination)" Scope=Goroutine(1)
+M=23550 P=0 G=10 RangeEnd Time=7070126147584 Name="GC concurrent mark phase" Scope=None Attributes=[]
+`
+
+func TestParseRuntimeTrace(t *testing.T) {
+	events, err := parseRuntimeTrace(strings.NewReader(capturedTrace))
+	if err != nil {
+		t.Fatalf("Failed to parse trace: %v", err)
+	}
+
+	sync := time.Date(2026, 10, 16, 1, 20, 20, 106328621, time.UTC)
+	expected := []struct {
+		traceTime int64
+		details   string
+	}{
+		{7070120647360, "Goroutine 1 blocked: sleep"},
+		{7070120651392, "Goroutine 9 blocked: sync"},
+		{7070125808320, "Goroutine 1 unblocked"},
+		{7070125814528, "Goroutine 9 unblocked by goroutine 1"},
+		{7070125823296, "GC started"},
+		{7070126147584, "GC finished"},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("Expected %d events, got %d: %+v", len(expected), len(events), events)
+	}
+	for i, want := range expected {
+		ts := sync.Add(time.Duration(want.traceTime - 7070120620352))
+		e := events[i]
+		if e.Type != recorder.RuntimeEvent || e.Details != want.details || !e.Timestamp.Equal(ts) {
+			t.Errorf("Event %d: expected %q at %v, got %s %q at %v", i, want.details, ts, e.Type, e.Details, e.Timestamp)
+		}
+	}
+
+	// Only a goroutine's own transitions happen on its thread
+	if e := events[0]; e.OSThreadID != 23550 || e.ProcID != 1 {
+		t.Errorf("Expected goroutine 1 to block on thread 23550, P0, got thread %d, ProcID %d", e.OSThreadID, e.ProcID)
+	}
+	for _, i := range []int{3, 4} {
+		if e := events[i]; e.OSThreadID != 0 || e.ProcID != 0 {
+			t.Errorf("Event %d: expected no thread, got thread %d, ProcID %d", i, e.OSThreadID, e.ProcID)
+		}
+	}
+
+	if _, err := parseRuntimeTrace(strings.NewReader("M=1 P=0 G=1 StateTransition Time=5 GoID=1 Running->Waiting Reason=\"sync\"\n")); err == nil {
+		t.Error("Expected an error for a trace without a clock snapshot")
+	}
+}
+
+func TestMergeRuntimeTrace(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not available to parse the trace")
+	}
+
+	path := filepath.Join(t.TempDir(), "trace.out")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create trace file: %v", err)
+	}
+	if err := trace.Start(f); err != nil {
+		t.Skipf("Runtime tracing unavailable: %v", err)
+	}
+
+	start := time.Now()
+	var mu sync.Mutex
+	mu.Lock()
+	done := make(chan struct{})
+	go func() {
+		mu.Lock() // Blocks until main unlocks
+		mu.Unlock()
+		close(done)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	mu.Unlock()
+	<-done
+	runtime.GC()
+	end := time.Now()
+
+	trace.Stop()
+	f.Close()
+
+	recorded := []recorder.Event{
+		{ID: start.UnixNano(), Timestamp: start, Type: recorder.FuncEntry, Details: "Entering main"},
+		{ID: end.UnixNano(), Timestamp: end, Type: recorder.FuncExit, Details: "Exiting main"},
+	}
+	merged, err := MergeRuntimeTrace(path, recorded)
+	if err != nil && strings.Contains(err.Error(), "wall clock") {
+		t.Skipf("Toolchain too old to align the trace: %v", err)
+	}
+	if err != nil {
+		t.Fatalf("Failed to merge trace: %v", err)
+	}
+
+	var blocked, gc bool
+	for i, e := range merged {
+		if i > 0 && e.Timestamp.Before(merged[i-1].Timestamp) {
+			t.Errorf("Event %d is out of order", i)
+		}
+		if e.Type != recorder.RuntimeEvent {
+			continue
+		}
+		if strings.HasSuffix(e.Details, "blocked: sync") {
+			blocked = true
+			if e.Timestamp.Before(start) || e.Timestamp.After(end) {
+				t.Errorf("Expected the block between the recorded events, got %v not in [%v, %v]", e.Timestamp, start, end)
+			}
+		}
+		if e.Details == "GC started" {
+			gc = true
+		}
+	}
+	if !blocked || !gc {
+		t.Errorf("Expected a sync block and a GC in the merged events, got %+v", merged)
+	}
+	if merged[0].Details != "Entering main" && merged[0].Type != recorder.RuntimeEvent {
+		t.Errorf("Expected recorded events to be kept, got %+v", merged[0])
+	}
+}
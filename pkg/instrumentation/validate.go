@@ -0,0 +1,8 @@
+//go:build !chronogo_debug
+
+package instrumentation
+
+import "github.com/willibrandon/ChronoGo/pkg/recorder"
+
+// validateEvent does nothing unless built with the chronogo_debug tag
+func validateEvent(recorder.Event) {}
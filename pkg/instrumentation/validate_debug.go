@@ -0,0 +1,17 @@
+//go:build chronogo_debug
+
+package instrumentation
+
+import (
+	"fmt"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+// validateEvent logs events that fail recorder.Event.Validate, so malformed
+// instrumentation shows up while developing. It never drops the event.
+func validateEvent(e recorder.Event) {
+	if err := e.Validate(); err != nil {
+		fmt.Printf("Warning: Recording malformed event: %v\n", err)
+	}
+}
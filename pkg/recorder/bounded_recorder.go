@@ -0,0 +1,62 @@
+package recorder
+
+import "sync"
+
+// BoundedRecorder keeps the most recent events in memory, dropping the
+// oldest once it holds its capacity. It bounds the memory used by recording
+// in long-running programs that only need the lead-up to a failure.
+type BoundedRecorder struct {
+	mu      sync.Mutex
+	events  []Event // Ring buffer, the oldest event at start once full
+	start   int
+	dropped int64
+}
+
+// NewBoundedRecorder creates a recorder that keeps the last capacity events
+func NewBoundedRecorder(capacity int) *BoundedRecorder {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &BoundedRecorder{events: make([]Event, 0, capacity)}
+}
+
+// RecordEvent adds an event, replacing the oldest one if the buffer is full
+func (r *BoundedRecorder) RecordEvent(e Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.events) < cap(r.events) {
+		r.events = append(r.events, e)
+		return nil
+	}
+	r.events[r.start] = e
+	r.start = (r.start + 1) % len(r.events)
+	r.dropped++
+	return nil
+}
+
+// GetEvents returns a copy of the buffered events, oldest first
+func (r *BoundedRecorder) GetEvents() []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	events := make([]Event, 0, len(r.events))
+	events = append(events, r.events[r.start:]...)
+	return append(events, r.events[:r.start]...)
+}
+
+// Dropped returns the number of events dropped to make room for newer ones
+func (r *BoundedRecorder) Dropped() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.dropped
+}
+
+// Clear removes all buffered events
+func (r *BoundedRecorder) Clear() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = r.events[:0]
+	r.start = 0
+	r.dropped = 0
+}
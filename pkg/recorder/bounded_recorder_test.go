@@ -0,0 +1,29 @@
+package recorder
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBoundedRecorder(t *testing.T) {
+	rec := NewBoundedRecorder(3)
+	for i := 1; i <= 5; i++ {
+		if err := rec.RecordEvent(Event{ID: int64(i), Timestamp: time.Now(), Type: StatementExecution}); err != nil {
+			t.Fatalf("Failed to record event: %v", err)
+		}
+	}
+
+	events := rec.GetEvents()
+	if len(events) != 3 || events[0].ID != 3 || events[1].ID != 4 || events[2].ID != 5 {
+		t.Errorf("Expected the last 3 events oldest first, got %+v", events)
+	}
+	if rec.Dropped() != 2 {
+		t.Errorf("Expected 2 dropped events, got %d", rec.Dropped())
+	}
+
+	rec.Clear()
+	rec.RecordEvent(Event{ID: 6})
+	if events := rec.GetEvents(); len(events) != 1 || events[0].ID != 6 || rec.Dropped() != 0 {
+		t.Errorf("Expected a single event after clearing, got %+v", events)
+	}
+}
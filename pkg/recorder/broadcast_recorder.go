@@ -0,0 +1,155 @@
+package recorder
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// BroadcastRecorderOptions contains options for creating a broadcast recorder
+type BroadcastRecorderOptions struct {
+	BufferSize int // Events held for each subscriber that hasn't received them yet
+}
+
+// DefaultBroadcastRecorderOptions returns default options for broadcast recorder
+func DefaultBroadcastRecorderOptions() BroadcastRecorderOptions {
+	return BroadcastRecorderOptions{
+		BufferSize: 1024,
+	}
+}
+
+// BroadcastRecorder records events to an inner recorder and hands each one
+// to live subscribers, such as a dashboard showing events as they happen.
+// Subscribers never slow down recording: each has a bounded buffer, and
+// events that don't fit are dropped for that subscriber alone and counted.
+//
+// A BroadcastRecorder is as safe for concurrent use as its inner recorder.
+type BroadcastRecorder struct {
+	inner   Recorder
+	options BroadcastRecorderOptions
+
+	mu     sync.RWMutex // Guards subs and closed; RecordEvent only reads them
+	subs   map[<-chan Event]*subscription
+	closed bool
+}
+
+// subscription is the buffer and drop count of one subscriber
+type subscription struct {
+	ch      chan Event
+	dropped atomic.Int64
+	stop    func() bool // Stops waiting for the subscriber's context
+}
+
+// NewBroadcastRecorder creates a broadcast recorder over inner with default options
+func NewBroadcastRecorder(inner Recorder) *BroadcastRecorder {
+	return NewBroadcastRecorderWithOptions(inner, DefaultBroadcastRecorderOptions())
+}
+
+// NewBroadcastRecorderWithOptions creates a broadcast recorder over inner with the given options
+func NewBroadcastRecorderWithOptions(inner Recorder, options BroadcastRecorderOptions) *BroadcastRecorder {
+	if options.BufferSize <= 0 {
+		options.BufferSize = DefaultBroadcastRecorderOptions().BufferSize
+	}
+	return &BroadcastRecorder{
+		inner:   inner,
+		options: options,
+		subs:    make(map[<-chan Event]*subscription),
+	}
+}
+
+// RecordEvent records an event to the inner recorder, then offers it to
+// every subscriber without waiting for any of them. Events the inner
+// recorder rejects aren't broadcast.
+func (b *BroadcastRecorder) RecordEvent(e Event) error {
+	if err := b.inner.RecordEvent(e); err != nil {
+		return err
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, s := range b.subs {
+		select {
+		case s.ch <- e:
+		default:
+			s.dropped.Add(1)
+		}
+	}
+	return nil
+}
+
+// GetEvents returns the events of the inner recorder
+func (b *BroadcastRecorder) GetEvents() []Event {
+	return b.inner.GetEvents()
+}
+
+// Clear clears the inner recorder. Subscribers keep their subscriptions.
+func (b *BroadcastRecorder) Clear() {
+	b.inner.Clear()
+}
+
+// Subscribe returns a channel receiving the events recorded from now on.
+// The channel is closed once ctx is done or the recorder is closed; a
+// subscription made after Close gets a closed channel.
+func (b *BroadcastRecorder) Subscribe(ctx context.Context) <-chan Event {
+	s := &subscription{ch: make(chan Event, b.options.BufferSize)}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(s.ch)
+		return s.ch
+	}
+	b.subs[s.ch] = s
+	s.stop = context.AfterFunc(ctx, func() { b.unsubscribe(s.ch) })
+	return s.ch
+}
+
+// unsubscribe closes a subscriber's channel, if it is still subscribed
+func (b *BroadcastRecorder) unsubscribe(ch <-chan Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if s, ok := b.subs[ch]; ok {
+		delete(b.subs, ch)
+		close(s.ch)
+	}
+}
+
+// Dropped returns the number of events the subscriber receiving on ch
+// missed because its buffer was full, or 0 if it isn't subscribed
+func (b *BroadcastRecorder) Dropped(ch <-chan Event) int64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if s, ok := b.subs[ch]; ok {
+		return s.dropped.Load()
+	}
+	return 0
+}
+
+// Subscribers returns the number of current subscribers
+func (b *BroadcastRecorder) Subscribers() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subs)
+}
+
+// Close ends every subscription, then closes the inner recorder if it has
+// a Close method. Closing again does nothing.
+func (b *BroadcastRecorder) Close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	for ch, s := range b.subs {
+		s.stop()
+		delete(b.subs, ch)
+		close(s.ch)
+	}
+	b.mu.Unlock()
+
+	if closer, ok := b.inner.(interface{ Close() error }); ok {
+		return closer.Close()
+	}
+	return nil
+}
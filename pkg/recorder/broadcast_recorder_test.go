@@ -0,0 +1,116 @@
+package recorder
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// receive reads n events from ch, failing if they don't arrive in time
+func receive(t *testing.T, ch <-chan Event, n int) []Event {
+	t.Helper()
+	var events []Event
+	for len(events) < n {
+		select {
+		case e, ok := <-ch:
+			if !ok {
+				t.Fatalf("Subscription closed after %d of %d events", len(events), n)
+			}
+			events = append(events, e)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Timed out after %d of %d events", len(events), n)
+		}
+	}
+	return events
+}
+
+// waitClosed fails unless ch is closed in time, discarding buffered events
+func waitClosed(t *testing.T, ch <-chan Event) {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case _, ok := <-ch:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("Subscription wasn't closed")
+		}
+	}
+}
+
+func TestBroadcastRecorderSubscribers(t *testing.T) {
+	inner := NewInMemoryRecorder()
+	b := NewBroadcastRecorder(inner)
+	ctx := context.Background()
+	first, second := b.Subscribe(ctx), b.Subscribe(ctx)
+
+	for i := 1; i <= 3; i++ {
+		if err := b.RecordEvent(Event{ID: int64(i), Type: StatementExecution}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, ch := range []<-chan Event{first, second} {
+		events := receive(t, ch, 3)
+		if events[0].ID != 1 || events[2].ID != 3 {
+			t.Errorf("Subscriber got %+v, want events 1 to 3 in order", events)
+		}
+	}
+	if len(inner.GetEvents()) != 3 || len(b.GetEvents()) != 3 {
+		t.Errorf("Inner recorder holds %d events, want 3", len(inner.GetEvents()))
+	}
+}
+
+func TestBroadcastRecorderSlowSubscriber(t *testing.T) {
+	b := NewBroadcastRecorderWithOptions(NewInMemoryRecorder(), BroadcastRecorderOptions{BufferSize: 2})
+	ctx := context.Background()
+	slow, fast := b.Subscribe(ctx), b.Subscribe(ctx)
+
+	// Recording never waits for the slow subscriber, which misses what
+	// doesn't fit in its buffer
+	for i := 1; i <= 5; i++ {
+		if err := b.RecordEvent(Event{ID: int64(i)}); err != nil {
+			t.Fatal(err)
+		}
+		if e := receive(t, fast, 1)[0]; e.ID != int64(i) {
+			t.Errorf("Fast subscriber got event %d, want %d", e.ID, i)
+		}
+	}
+	if got := receive(t, slow, 2); got[0].ID != 1 || got[1].ID != 2 {
+		t.Errorf("Slow subscriber got %+v, want the first 2 events", got)
+	}
+	if dropped := b.Dropped(slow); dropped != 3 {
+		t.Errorf("Slow subscriber dropped %d events, want 3", dropped)
+	}
+	if dropped := b.Dropped(fast); dropped != 0 {
+		t.Errorf("Fast subscriber dropped %d events, want 0", dropped)
+	}
+}
+
+func TestBroadcastRecorderUnsubscribe(t *testing.T) {
+	b := NewBroadcastRecorder(NewInMemoryRecorder())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := b.Subscribe(ctx)
+	kept := b.Subscribe(context.Background())
+
+	cancel()
+	waitClosed(t, cancelled)
+	if n := b.Subscribers(); n != 1 {
+		t.Errorf("%d subscribers after cancelling one of 2, want 1", n)
+	}
+	if err := b.RecordEvent(Event{ID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	receive(t, kept, 1)
+
+	// Close ends the rest, and later subscriptions are closed from the start
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	waitClosed(t, kept)
+	waitClosed(t, b.Subscribe(context.Background()))
+	if err := b.Close(); err != nil {
+		t.Errorf("Closing again failed: %v", err)
+	}
+}
@@ -0,0 +1,243 @@
+package recorder
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Names of the entries in a recording bundle
+const (
+	bundleManifestName = "manifest.json"
+	bundleEventsName   = "chronogo.events"
+	bundleSourcesDir   = "src/"
+)
+
+// zipMagic is the header that starts every zip archive
+var zipMagic = []byte("PK\x03\x04")
+
+// bundleManifest describes the contents of a recording bundle
+type bundleManifest struct {
+	Version int               `json:"version"`
+	Events  string            `json:"events"`
+	Sources map[string]string `json:"sources"` // Recorded file path to entry name
+}
+
+// RecordingBundle is a recording opened from a bundle, with the source files
+// its events refer to
+type RecordingBundle struct {
+	Events   []Event
+	Metadata *RecordingMetadata  // Nil if the recording has none
+	sources  map[string][]string // Lines of each recorded file path
+}
+
+// Bundle packages the events file at eventsPath and the source files its
+// events were recorded in into a single zip at outPath, so the recording can
+// be replayed with its code on a machine without the repository. Only files
+// under moduleRoot are included, each once; others are skipped.
+func Bundle(eventsPath, moduleRoot, outPath string) error {
+	root, err := filepath.Abs(moduleRoot)
+	if err != nil {
+		return fmt.Errorf("failed to resolve module root: %v", err)
+	}
+
+	files := make(map[string]bool)
+	if err := ScanEventsFile(eventsPath, func(e Event) error {
+		if e.File != "" {
+			files[e.File] = true
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	out, err := os.Create(outPath)
+	if err != nil {
+		return fmt.Errorf("failed to create bundle: %v", err)
+	}
+	zw := zip.NewWriter(out)
+	if err := writeBundle(zw, eventsPath, root, files); err != nil {
+		zw.Close()
+		out.Close()
+		os.Remove(outPath)
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to write bundle: %v", err)
+	}
+	return out.Close()
+}
+
+// writeBundle writes the events file, the source files under root and the
+// manifest to zw
+func writeBundle(zw *zip.Writer, eventsPath, root string, files map[string]bool) error {
+	// The events are stored as recorded, compressed or not
+	if err := copyToZip(zw, bundleEventsName, eventsPath); err != nil {
+		return err
+	}
+
+	manifest := bundleManifest{Version: 1, Events: bundleEventsName, Sources: make(map[string]string)}
+	stored := make(map[string]bool)
+	for _, file := range sortedFiles(files) {
+		abs := file
+		if !filepath.IsAbs(abs) {
+			abs = filepath.Join(root, abs)
+		}
+		rel, err := filepath.Rel(root, abs)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if _, err := os.Stat(abs); err != nil {
+			fmt.Printf("Warning: Source file %s not found, leaving it out of the bundle\n", file)
+			continue
+		}
+
+		name := bundleSourcesDir + filepath.ToSlash(rel)
+		manifest.Sources[file] = name
+		if stored[name] {
+			// Recorded under another spelling of the same path
+			continue
+		}
+		if err := copyToZip(zw, name, abs); err != nil {
+			return err
+		}
+		stored[name] = true
+	}
+
+	w, err := zw.Create(bundleManifestName)
+	if err != nil {
+		return fmt.Errorf("failed to write bundle manifest: %v", err)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(manifest); err != nil {
+		return fmt.Errorf("failed to write bundle manifest: %v", err)
+	}
+	return nil
+}
+
+// copyToZip stores the file at path as the entry name
+func copyToZip(zw *zip.Writer, name, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer f.Close()
+
+	w, err := zw.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to add %s to bundle: %v", name, err)
+	}
+	if _, err := io.Copy(w, f); err != nil {
+		return fmt.Errorf("failed to add %s to bundle: %v", name, err)
+	}
+	return nil
+}
+
+// sortedFiles returns the file paths in order, so bundles are reproducible
+func sortedFiles(files map[string]bool) []string {
+	sorted := make([]string, 0, len(files))
+	for file := range files {
+		sorted = append(sorted, file)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// IsBundle reports whether the file at path is a zip archive, e.g. one
+// written by Bundle
+func IsBundle(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	header := make([]byte, len(zipMagic))
+	if _, err := io.ReadFull(f, header); err != nil {
+		return false
+	}
+	return bytes.Equal(header, zipMagic)
+}
+
+// OpenBundle loads the events and source files of a bundle written by Bundle
+func OpenBundle(path string) (*RecordingBundle, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bundle: %v", err)
+	}
+	defer zr.Close()
+
+	var manifest bundleManifest
+	if err := readZipEntry(&zr.Reader, bundleManifestName, func(r io.Reader) error {
+		return json.NewDecoder(r).Decode(&manifest)
+	}); err != nil {
+		return nil, err
+	}
+
+	b := &RecordingBundle{sources: make(map[string][]string)}
+	if err := readZipEntry(&zr.Reader, manifest.Events, func(r io.Reader) error {
+		return scanEventStream(r, func(e Event) error {
+			b.Events = append(b.Events, e)
+			return nil
+		})
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := readZipEntry(&zr.Reader, manifest.Events, func(r io.Reader) error {
+		md, err := readMetadata(r, nil)
+		b.Metadata = md
+		if errors.Is(err, ErrSealedMetadata) {
+			return nil
+		}
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	lines := make(map[string][]string)
+	for file, name := range manifest.Sources {
+		if _, ok := lines[name]; !ok {
+			if err := readZipEntry(&zr.Reader, name, func(r io.Reader) error {
+				data, err := io.ReadAll(r)
+				lines[name] = strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+				return err
+			}); err != nil {
+				return nil, err
+			}
+		}
+		b.sources[file] = lines[name]
+	}
+	return b, nil
+}
+
+// readZipEntry calls fn with the contents of the entry name
+func readZipEntry(zr *zip.Reader, name string, fn func(io.Reader) error) error {
+	rc, err := zr.Open(path.Clean(name))
+	if err != nil {
+		return fmt.Errorf("failed to read %s from bundle: %v", name, err)
+	}
+	defer rc.Close()
+
+	if err := fn(rc); err != nil {
+		return fmt.Errorf("failed to read %s from bundle: %v", name, err)
+	}
+	return nil
+}
+
+// Source returns the lines of a source file the events were recorded in, as
+// named by Event.File
+func (b *RecordingBundle) Source(file string) ([]string, bool) {
+	lines, ok := b.sources[file]
+	return lines, ok
+}
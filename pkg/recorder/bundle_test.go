@@ -0,0 +1,91 @@
+package recorder
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBundle(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "project")
+	mainFile := filepath.Join(root, "main.go")
+	utilFile := filepath.Join(root, "util", "util.go")
+	outside := filepath.Join(dir, "vendor.go")
+	for path, content := range map[string]string{
+		mainFile: "package main\n\nfunc main() {\n\tprintln(util.Sum(1, 2))\n}\n",
+		utilFile: "package util\n\nfunc Sum(a, b int) int {\n\treturn a + b\n}\n",
+		outside:  "package vendor\n",
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	base := time.Now()
+	events := []Event{
+		{ID: 1, Timestamp: base, Type: FuncEntry, File: mainFile, Line: 3, FuncName: "main.main"},
+		{ID: 2, Timestamp: base.Add(time.Millisecond), Type: FuncEntry, File: utilFile, Line: 3, FuncName: "util.Sum"},
+		{ID: 3, Timestamp: base.Add(2 * time.Millisecond), Type: StatementExecution, File: utilFile, Line: 4, FuncName: "util.Sum"},
+		{ID: 4, Timestamp: base.Add(3 * time.Millisecond), Type: StatementExecution, File: "main.go", Line: 4, FuncName: "main.main"},
+		{ID: 5, Timestamp: base.Add(4 * time.Millisecond), Type: FuncEntry, File: outside, Line: 1, FuncName: "vendor.Init"},
+	}
+	eventsPath := filepath.Join(dir, "chronogo.events")
+	if err := WriteEventsFile(eventsPath, events, DefaultFileRecorderOptions()); err != nil {
+		t.Fatalf("Failed to write events: %v", err)
+	}
+
+	bundlePath := filepath.Join(dir, "bug.zip")
+	if err := Bundle(eventsPath, root, bundlePath); err != nil {
+		t.Fatalf("Bundle failed: %v", err)
+	}
+	if !IsBundle(bundlePath) || IsBundle(eventsPath) {
+		t.Fatal("Expected only the bundle to be detected as one")
+	}
+
+	// The bundle must not depend on the project being there
+	if err := os.RemoveAll(root); err != nil {
+		t.Fatal(err)
+	}
+	bundle, err := OpenBundle(bundlePath)
+	if err != nil {
+		t.Fatalf("OpenBundle failed: %v", err)
+	}
+
+	if len(bundle.Events) != len(events) {
+		t.Fatalf("Expected %d events, got %d", len(events), len(bundle.Events))
+	}
+	for i, e := range bundle.Events {
+		if e.ID != events[i].ID || e.File != events[i].File || e.Line != events[i].Line {
+			t.Errorf("Event %d: expected %+v, got %+v", i, events[i], e)
+		}
+	}
+
+	lines, ok := bundle.Source(utilFile)
+	if !ok || len(lines) != 5 || lines[3] != "\treturn a + b" {
+		t.Errorf("Unexpected source for util.go: %q", lines)
+	}
+	// A relative path resolves against the module root, sharing its entry
+	abs, _ := bundle.Source(mainFile)
+	rel, ok := bundle.Source("main.go")
+	if !ok || len(rel) != len(abs) || rel[3] != "\tprintln(util.Sum(1, 2))" {
+		t.Errorf("Unexpected source for main.go: %q", rel)
+	}
+	if _, ok := bundle.Source(outside); ok {
+		t.Error("Expected files outside the module root to be left out")
+	}
+}
+
+func TestOpenBundleNotZip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chronogo.events")
+	if err := WriteEventsFile(path, loopRecording(), DefaultFileRecorderOptions()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenBundle(path); err == nil {
+		t.Error("Expected an error opening an events file as a bundle")
+	}
+}
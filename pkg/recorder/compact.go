@@ -0,0 +1,130 @@
+package recorder
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"slices"
+)
+
+// CompactOptions controls which events Compact keeps
+type CompactOptions struct {
+	KeepTypes   []EventType // Event types to keep, empty to keep all; snapshot markers are always kept
+	DedupeLoops bool        // Collapse consecutive statement events at the same location into one
+}
+
+// Compact returns a smaller copy of a recording. Events whose type isn't kept
+// are dropped, and with DedupeLoops a run of StatementExecution events with
+// the same function, file and line becomes its first event with Repeat set
+// to the length of the run.
+//
+// The result is in replay order and IDs are renumbered from 1, so indices
+// and IDs stay dense. Snapshot markers take the ID of the event before them,
+// as they do when recorded.
+func Compact(events []Event, opts CompactOptions) []Event {
+	sorted := append([]Event(nil), events...)
+	StableSort(sorted)
+
+	keep := make(map[EventType]bool)
+	for _, t := range opts.KeepTypes {
+		keep[t] = true
+	}
+
+	compacted := make([]Event, 0, len(sorted))
+	var lastID int64
+	for _, e := range sorted {
+		if e.Type == SnapshotEvent {
+			e.ID = lastID
+			compacted = append(compacted, e)
+			continue
+		}
+		if len(keep) > 0 && !keep[e.Type] {
+			continue
+		}
+
+		if opts.DedupeLoops && len(compacted) > 0 {
+			prev := &compacted[len(compacted)-1]
+			if sameStatement(*prev, e) {
+				prev.Repeat = repeatCount(*prev) + repeatCount(e)
+				continue
+			}
+		}
+
+		lastID++
+		e.ID = lastID
+		compacted = append(compacted, e)
+	}
+	return compacted
+}
+
+// sameStatement reports whether two events are executions of the same statement
+func sameStatement(a, b Event) bool {
+	return a.Type == StatementExecution && b.Type == StatementExecution &&
+		a.FuncName == b.FuncName && a.File == b.File && a.Line == b.Line && a.TraceID == b.TraceID && a.GoroutineID == b.GoroutineID &&
+		slices.Equal(a.Tags, b.Tags)
+}
+
+// repeatCount returns how many executions an event stands for
+func repeatCount(e Event) int {
+	if e.Repeat > 0 {
+		return e.Repeat
+	}
+	return 1
+}
+
+// CompactFile compacts the recording at inPath into outPath, keeping the
+// input's compression. inPath and outPath may be the same file. It returns
+// the number of events before and after compaction.
+func CompactFile(inPath, outPath string, opts CompactOptions) (before, after int, err error) {
+	f, err := os.Open(inPath)
+	if err != nil {
+		return 0, 0, fmt.Errorf("error opening events file: %v", err)
+	}
+	buffered := bufio.NewReader(f)
+	compressionType := detectCompression(buffered)
+	events, err := DecodeEvents(buffered, compressionType)
+	f.Close()
+	if err != nil {
+		return 0, 0, fmt.Errorf("error reading events file: %v", err)
+	}
+
+	compacted := Compact(events, opts)
+	if err := WriteEventsFile(outPath, compacted, FileRecorderOptions{CompressionType: compressionType}); err != nil {
+		return 0, 0, err
+	}
+	return len(events), len(compacted), nil
+}
+
+// WriteEventsFile replaces the file at path with the given events, exactly
+// as given: no snapshot markers are added. The events are written next to
+// path and renamed over it, so a failure never leaves a partial file behind.
+func WriteEventsFile(path string, events []Event, options FileRecorderOptions) error {
+	tmpPath := path + ".tmp"
+	out, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create events file: %v", err)
+	}
+	defer os.Remove(tmpPath)
+	defer out.Close()
+
+	bufWriter := bufio.NewWriter(out)
+	w := NewWriterRecorder(bufWriter, options)
+	for _, e := range events {
+		if err := w.writeEvent(e); err != nil {
+			return fmt.Errorf("failed to write events: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	if err := bufWriter.Flush(); err != nil {
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to replace %s: %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package recorder
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// loopRecording returns the events of a function running a loop of 3
+// iterations, with a snapshot marker in the middle of the loop
+func loopRecording() []Event {
+	base := time.Now()
+	var events []Event
+	add := func(id int64, t EventType, line int, details string) {
+		events = append(events, Event{
+			ID:        id,
+			Timestamp: base.Add(time.Duration(len(events)) * time.Millisecond),
+			Type:      t,
+			Details:   details,
+			File:      "main.go",
+			Line:      line,
+			FuncName:  "main.loop",
+		})
+	}
+
+	add(100, FuncEntry, 10, "Entering main.loop")
+	add(200, StatementExecution, 11, "sum += 1")
+	add(300, StatementExecution, 11, "sum += 2")
+	add(300, SnapshotEvent, 0, "Snapshot created")
+	add(400, StatementExecution, 11, "sum += 3")
+	add(500, VarAssignment, 12, "sum = 6")
+	add(600, StatementExecution, 13, "return")
+	add(700, FuncExit, 13, "Exiting main.loop")
+	return events
+}
+
+func TestCompact(t *testing.T) {
+	compacted := Compact(loopRecording(), CompactOptions{
+		KeepTypes:   []EventType{FuncEntry, FuncExit, StatementExecution},
+		DedupeLoops: true,
+	})
+
+	expected := []struct {
+		id      int64
+		typ     EventType
+		details string
+		repeat  int
+	}{
+		{1, FuncEntry, "Entering main.loop", 0},
+		{2, StatementExecution, "sum += 1", 2},
+		{2, SnapshotEvent, "Snapshot created", 0}, // Breaks the run, takes the ID before it
+		{3, StatementExecution, "sum += 3", 0},
+		{4, StatementExecution, "return", 0},
+		{5, FuncExit, "Exiting main.loop", 0},
+	}
+	if len(compacted) != len(expected) {
+		t.Fatalf("Expected %d events, got %d: %+v", len(expected), len(compacted), compacted)
+	}
+	for i, want := range expected {
+		got := compacted[i]
+		if got.ID != want.id || got.Type != want.typ || got.Details != want.details || got.Repeat != want.repeat {
+			t.Errorf("Event %d: expected %d %s %q x%d, got %d %s %q x%d", i,
+				want.id, want.typ, want.details, want.repeat, got.ID, got.Type, got.Details, got.Repeat)
+		}
+	}
+
+	// Compacting again merges the counts of runs that are now adjacent
+	recompacted := Compact(append(compacted[:2:2], compacted[3:]...), CompactOptions{DedupeLoops: true})
+	if recompacted[1].Repeat != 3 {
+		t.Errorf("Expected merged run of 3, got %d", recompacted[1].Repeat)
+	}
+}
+
+func TestCompactFile(t *testing.T) {
+	originalInterval := SnapshotInterval
+	SnapshotInterval = 0
+	defer func() {
+		SnapshotInterval = originalInterval
+	}()
+
+	path := filepath.Join(t.TempDir(), "loop.events")
+	rec, err := NewFileRecorderWithOptions(path, FileRecorderOptions{CompressionType: ZstdCompression})
+	if err != nil {
+		t.Fatalf("Failed to create file recorder: %v", err)
+	}
+	for _, e := range loopRecording() {
+		if err := rec.RecordEvent(e); err != nil {
+			t.Fatalf("Failed to record event: %v", err)
+		}
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatalf("Failed to close recorder: %v", err)
+	}
+
+	// Compact in place
+	before, after, err := CompactFile(path, path, CompactOptions{DedupeLoops: true})
+	if err != nil {
+		t.Fatalf("Failed to compact: %v", err)
+	}
+	if before != 8 || after != 7 {
+		t.Errorf("Expected 8 events compacted to 7, got %d to %d", before, after)
+	}
+
+	// Compression is kept
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read compacted file: %v", err)
+	}
+	if !bytes.HasPrefix(data, zstdMagic) {
+		t.Error("Expected compacted file to stay zstd compressed")
+	}
+
+	events, err := ReadEventsFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read compacted events: %v", err)
+	}
+	if len(events) != 7 || events[1].Repeat != 2 {
+		t.Errorf("Expected the loop's first run to be collapsed, got %+v", events)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Error("Expected temporary file to be removed")
+	}
+}
+
+func TestFlightRecorderCompaction(t *testing.T) {
+	originalInterval := SnapshotInterval
+	SnapshotInterval = 0
+	defer func() {
+		SnapshotInterval = originalInterval
+	}()
+
+	dir := t.TempDir()
+	fr, err := NewFlightRecorder(dir, FlightOptions{
+		SegmentSize:     2000,
+		MaxSegments:     10,
+		CompressionType: NoCompression,
+		Compaction:      &CompactOptions{DedupeLoops: true},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create flight recorder: %v", err)
+	}
+	defer fr.Close()
+
+	for i := 0; i < 100; i++ {
+		if err := fr.RecordEvent(Event{ID: int64(i + 1), Timestamp: time.Now(), Type: StatementExecution,
+			File: "main.go", Line: 20, FuncName: "main.spin", Details: "spin"}); err != nil {
+			t.Fatalf("Failed to record event: %v", err)
+		}
+	}
+
+	segments := fr.Segments()
+	if len(segments) < 2 {
+		t.Fatalf("Expected several segments, got %d", len(segments))
+	}
+
+	// Closed segments hold a single collapsed event; the open one is untouched
+	total := 0
+	for _, e := range fr.GetEvents() {
+		total += repeatCount(e)
+	}
+	if total != 100 {
+		t.Errorf("Expected the segments to account for 100 executions, got %d", total)
+	}
+	first, err := ReadEventsFile(segments[0])
+	if err != nil {
+		t.Fatalf("Failed to read segment: %v", err)
+	}
+	if len(first) != 1 || first[0].Repeat < 2 {
+		t.Errorf("Expected the first segment to be compacted to one event, got %+v", first)
+	}
+}
@@ -1,9 +1,11 @@
 package recorder
 
 import (
+	"bufio"
 	"bytes"
 	"io"
 
+	"github.com/klauspost/compress/gzip"
 	"github.com/klauspost/compress/zstd"
 )
 
@@ -15,6 +17,8 @@ const (
 	NoCompression CompressionType = iota
 	// ZstdCompression indicates Zstandard compression
 	ZstdCompression
+	// GzipCompression indicates gzip compression, for tools that can't read zstd
+	GzipCompression
 )
 
 var (
@@ -24,46 +28,80 @@ var (
 	// encoder and decoder for zstd are reusable and thread-safe
 	zstdEncoder, _ = zstd.NewWriter(nil)
 	zstdDecoder, _ = zstd.NewReader(nil)
+
+	// zstdMagic is the frame header that starts every zstd-compressed stream
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	// gzipMagic is the header that starts every gzip member
+	gzipMagic = []byte{0x1f, 0x8b}
 )
 
+// detectCompression peeks at the header of a stream to tell which
+// compression it uses, without consuming it
+func detectCompression(r *bufio.Reader) CompressionType {
+	if header, err := r.Peek(len(zstdMagic)); err == nil && bytes.Equal(header, zstdMagic) {
+		return ZstdCompression
+	}
+	if header, err := r.Peek(len(gzipMagic)); err == nil && bytes.Equal(header, gzipMagic) {
+		return GzipCompression
+	}
+	return NoCompression
+}
+
 // CompressData compresses a byte slice using the specified compression algorithm
 func CompressData(data []byte, compressionType CompressionType) ([]byte, error) {
-	if compressionType == NoCompression {
+	switch compressionType {
+	case NoCompression:
 		return data, nil
+	case GzipCompression:
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(data); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
 	}
-
-	// Currently we only support Zstd
 	return zstdEncoder.EncodeAll(data, make([]byte, 0, len(data))), nil
 }
 
 // DecompressData decompresses a byte slice using the specified compression algorithm
 func DecompressData(data []byte, compressionType CompressionType) ([]byte, error) {
-	if compressionType == NoCompression {
+	switch compressionType {
+	case NoCompression:
 		return data, nil
+	case GzipCompression:
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		return io.ReadAll(zr)
 	}
-
-	// Currently we only support Zstd
 	return zstdDecoder.DecodeAll(data, nil)
 }
 
 // NewCompressedWriter returns a writer that compresses data before writing
 func NewCompressedWriter(w io.Writer, compressionType CompressionType) io.Writer {
-	if compressionType == NoCompression {
+	switch compressionType {
+	case NoCompression:
 		return w
+	case GzipCompression:
+		return gzip.NewWriter(w)
 	}
-
-	// Currently we only support Zstd
 	encoder, _ := zstd.NewWriter(w)
 	return encoder
 }
 
 // NewCompressedReader returns a reader that decompresses data after reading
 func NewCompressedReader(r io.Reader, compressionType CompressionType) (io.Reader, error) {
-	if compressionType == NoCompression {
+	switch compressionType {
+	case NoCompression:
 		return r, nil
+	case GzipCompression:
+		return gzip.NewReader(r)
 	}
-
-	// Currently we only support Zstd
 	return zstd.NewReader(r)
 }
 
@@ -73,8 +111,11 @@ func CloseCompressedWriter(w io.Writer, compressionType CompressionType) error {
 		return nil
 	}
 
-	// Close the writer if it's a zstd writer
-	if zw, ok := w.(*zstd.Encoder); ok {
+	// Close the writer if it's a zstd or gzip writer
+	switch zw := w.(type) {
+	case *zstd.Encoder:
+		return zw.Close()
+	case *gzip.Writer:
 		return zw.Close()
 	}
 	return nil
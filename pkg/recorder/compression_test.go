@@ -2,6 +2,8 @@ package recorder
 
 import (
 	"bytes"
+	"fmt"
+	"os"
 	"testing"
 )
 
@@ -137,3 +139,58 @@ func TestFileRecorderWithCompression(t *testing.T) {
 		}
 	}
 }
+
+func TestReadTruncatedCompressedFile(t *testing.T) {
+	tempFile := t.TempDir() + "/truncated_events.json.zst"
+	recorder, err := NewFileRecorderWithOptions(tempFile, FileRecorderOptions{CompressionType: ZstdCompression})
+	if err != nil {
+		t.Fatalf("Failed to create file recorder: %v", err)
+	}
+
+	// Enough events to span several compressed blocks
+	const total = 5000
+	for i := 0; i < total; i++ {
+		event := Event{
+			ID:        int64(i),
+			Timestamp: CurrentTime(),
+			Type:      StatementExecution,
+			Details:   fmt.Sprintf("Test event %d", i),
+		}
+		if err := recorder.RecordEvent(event); err != nil {
+			t.Fatalf("Failed to record event: %v", err)
+		}
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatalf("Failed to close recorder: %v", err)
+	}
+
+	// Cut the file mid-block, as a killed program leaves it
+	info, err := os.Stat(tempFile)
+	if err != nil {
+		t.Fatalf("Failed to stat events file: %v", err)
+	}
+	if err := os.Truncate(tempFile, info.Size()-3); err != nil {
+		t.Fatalf("Failed to truncate events file: %v", err)
+	}
+
+	events, err := ReadEventsFile(tempFile)
+	if err != nil {
+		t.Fatalf("Expected truncated file to be readable, got %v", err)
+	}
+	if len(events) == 0 || len(events) >= total {
+		t.Fatalf("Expected a prefix of the %d events, got %d", total, len(events))
+	}
+
+	// The recovered events are the recording's prefix, with snapshot markers
+	// in between
+	next := int64(0)
+	for _, event := range events {
+		if event.Type == SnapshotEvent {
+			continue
+		}
+		if event.ID != next {
+			t.Fatalf("Expected event %d, got %d", next, event.ID)
+		}
+		next++
+	}
+}
@@ -0,0 +1,153 @@
+package recorder
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrTooManyEvents is returned by EventDecoder.Next once the decoder has
+// returned DecoderOptions.MaxEvents events
+var ErrTooManyEvents = errors.New("recording exceeds the event limit")
+
+// DecoderOptions limits what an EventDecoder accepts, so that a malformed or
+// hostile recording can't exhaust memory
+type DecoderOptions struct {
+	MaxLineSize int // Longest line decoded in bytes; longer lines are skipped
+	MaxEvents   int // Events decoded before ErrTooManyEvents, 0 for no limit
+	MaxErrors   int // Line errors kept for Errors, 0 for all; later ones are only counted
+}
+
+// DefaultDecoderOptions returns the limits used when reading recordings
+func DefaultDecoderOptions() DecoderOptions {
+	return DecoderOptions{
+		MaxLineSize: 512 * 1024,
+		MaxEvents:   10_000_000,
+		MaxErrors:   100,
+	}
+}
+
+// LineError is a line of a recording that couldn't be decoded
+type LineError struct {
+	Line int // 1-based line number
+	Err  error
+}
+
+func (e LineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+// EventDecoder reads newline-delimited JSON events, skipping lines that
+// can't be decoded instead of giving up on the rest of the recording.
+// Registered event types are mapped by name to the IDs they have in this
+// program, registering the ones it doesn't know.
+type EventDecoder struct {
+	lines   *lineReader
+	opts    DecoderOptions
+	lineNum int
+	events  int
+	errors  []LineError
+	skipped int
+	types   typeMapping // Recorded IDs of registered types that differ here
+}
+
+// NewEventDecoder creates a decoder reading events from r
+func NewEventDecoder(r io.Reader, opts DecoderOptions) *EventDecoder {
+	if opts.MaxLineSize <= 0 {
+		opts.MaxLineSize = DefaultDecoderOptions().MaxLineSize
+	}
+	return &EventDecoder{lines: newLineReader(r, opts.MaxLineSize), opts: opts, types: make(typeMapping)}
+}
+
+// Next returns the next event. Lines that can't be decoded are recorded,
+// see Errors, and skipped. It returns io.EOF at the end of the input,
+// ErrTooManyEvents once the event limit is reached, and read errors, such
+// as io.ErrUnexpectedEOF for a truncated compressed stream, as they occur.
+func (d *EventDecoder) Next() (Event, error) {
+	for {
+		if d.opts.MaxEvents > 0 && d.events >= d.opts.MaxEvents {
+			return Event{}, ErrTooManyEvents
+		}
+
+		line, tooLong, err := d.lines.next()
+		if err == nil || len(line) > 0 || tooLong {
+			d.lineNum++
+		}
+		if tooLong {
+			d.addError(fmt.Errorf("line exceeds %d bytes", d.opts.MaxLineSize))
+		} else if line = bytes.TrimSpace(line); isMetadataLine(line) || isEventTypesLine(line) {
+			d.types.declareLine(line)
+		} else if len(line) > 0 {
+			var event Event
+			if jsonErr := json.Unmarshal(line, &event); jsonErr != nil {
+				d.addError(jsonErr)
+			} else {
+				if t, ok := d.types[event.Type]; ok {
+					event.Type = t
+				}
+				d.events++
+				return event, nil
+			}
+		}
+
+		if err != nil {
+			return Event{}, err
+		}
+	}
+}
+
+// lineReader reads the lines of a recording, skipping over lines longer
+// than a limit instead of failing on them as a bufio.Scanner does
+type lineReader struct {
+	r       *bufio.Reader
+	maxSize int
+	line    []byte
+}
+
+// newLineReader creates a lineReader keeping lines of up to maxSize bytes
+func newLineReader(r io.Reader, maxSize int) *lineReader {
+	return &lineReader{r: bufio.NewReaderSize(r, 64*1024), maxSize: maxSize}
+}
+
+// next returns the next line without its newline. Lines longer than
+// maxSize are read to their end but not kept. The line is only valid until
+// the next call.
+func (lr *lineReader) next() (line []byte, tooLong bool, err error) {
+	lr.line = lr.line[:0]
+	for {
+		chunk, err := lr.r.ReadSlice('\n')
+		chunk = bytes.TrimSuffix(chunk, []byte("\n"))
+		if !tooLong && len(lr.line)+len(chunk) > lr.maxSize {
+			tooLong = true
+			lr.line = lr.line[:0]
+		}
+		if !tooLong {
+			lr.line = append(lr.line, chunk...)
+		}
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		return lr.line, tooLong, err
+	}
+}
+
+// addError records a line that couldn't be decoded
+func (d *EventDecoder) addError(err error) {
+	d.skipped++
+	if d.opts.MaxErrors <= 0 || len(d.errors) < d.opts.MaxErrors {
+		d.errors = append(d.errors, LineError{Line: d.lineNum, Err: err})
+	}
+}
+
+// Errors returns the lines skipped so far, up to MaxErrors of them
+func (d *EventDecoder) Errors() []LineError {
+	return d.errors
+}
+
+// Skipped returns the number of lines skipped so far
+func (d *EventDecoder) Skipped() int {
+	return d.skipped
+}
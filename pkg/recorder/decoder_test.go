@@ -0,0 +1,109 @@
+package recorder
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventDecoder(t *testing.T) {
+	valid := func(id int64, details string) string {
+		data, _ := json.Marshal(Event{ID: id, Timestamp: time.Unix(id, 0), Type: StatementExecution, Details: details})
+		return string(data)
+	}
+	input := strings.Join([]string{
+		valid(1, "first"),
+		`{"ID": 2, "Details": `, // Cut off mid-event
+		"",
+		valid(3, strings.Repeat("x", 200)), // Too long
+		valid(4, "after the bad lines"),
+		`not json`,
+		valid(5, "last"),
+	}, "\n")
+
+	dec := NewEventDecoder(strings.NewReader(input), DecoderOptions{MaxLineSize: 150, MaxErrors: 2})
+	var ids []int64
+	for {
+		event, err := dec.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		ids = append(ids, event.ID)
+	}
+
+	if len(ids) != 3 || ids[0] != 1 || ids[1] != 4 || ids[2] != 5 {
+		t.Errorf("Expected the valid events 1, 4 and 5, got %v", ids)
+	}
+	if dec.Skipped() != 3 {
+		t.Errorf("Expected 3 skipped lines, got %d", dec.Skipped())
+	}
+	errs := dec.Errors()
+	if len(errs) != 2 || errs[0].Line != 2 || errs[1].Line != 4 || !strings.Contains(errs[1].Error(), "line 4: line exceeds 150 bytes") {
+		t.Errorf("Expected errors for lines 2 and 4 only, got %v", errs)
+	}
+}
+
+func TestEventDecoderMaxEvents(t *testing.T) {
+	input := strings.Repeat(`{"ID": 1}`+"\n", 5)
+	dec := NewEventDecoder(strings.NewReader(input), DecoderOptions{MaxEvents: 3})
+	for i := 0; i < 3; i++ {
+		if _, err := dec.Next(); err != nil {
+			t.Fatalf("Unexpected error on event %d: %v", i, err)
+		}
+	}
+	if _, err := dec.Next(); !errors.Is(err, ErrTooManyEvents) {
+		t.Errorf("Expected ErrTooManyEvents, got %v", err)
+	}
+
+	opts := DefaultDecoderOptions()
+	opts.MaxEvents = 2
+	if _, err := DecodeEventsWithOptions(strings.NewReader(input), NoCompression, opts); err == nil {
+		t.Error("Expected DecodeEventsWithOptions to report the limit")
+	}
+}
+
+func FuzzEventDecode(f *testing.F) {
+	data, _ := json.Marshal(Event{ID: 7, Timestamp: time.Unix(7, 0), Type: ErrorEvent, Details: "Error in main: boom", TraceID: "req"})
+	f.Add(data)
+	f.Add([]byte(`{"ID":1}` + "\n" + `{"ID":` + "\n\n" + `[1,2,3]`))
+	f.Add([]byte(`{"Timestamp":"not a time"}`))
+	f.Add([]byte(strings.Repeat("[", 300)))
+
+	opts := DecoderOptions{MaxLineSize: 256, MaxEvents: 16, MaxErrors: 4}
+	f.Fuzz(func(t *testing.T, input []byte) {
+		dec := NewEventDecoder(strings.NewReader(string(input)), opts)
+		events := 0
+		for {
+			event, err := dec.Next()
+			if err != nil {
+				if !errors.Is(err, io.EOF) && !errors.Is(err, ErrTooManyEvents) {
+					t.Fatalf("Unexpected error from an in-memory reader: %v", err)
+				}
+				break
+			}
+			events++
+
+			// Whatever decodes must survive a round trip
+			encoded, err := json.Marshal(event)
+			if err != nil {
+				t.Fatalf("Decoded event doesn't encode: %v", err)
+			}
+			var again Event
+			if err := json.Unmarshal(encoded, &again); err != nil {
+				t.Fatalf("Encoded event doesn't decode: %v", err)
+			}
+		}
+		if events > opts.MaxEvents {
+			t.Errorf("Decoded %d events, over the limit of %d", events, opts.MaxEvents)
+		}
+		if len(dec.Errors()) > opts.MaxErrors || dec.Skipped() < len(dec.Errors()) {
+			t.Errorf("Kept %d errors of %d skipped lines, limit %d", len(dec.Errors()), dec.Skipped(), opts.MaxErrors)
+		}
+	})
+}
@@ -0,0 +1,138 @@
+package recorder
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// FirstUserEventType is the ID of the first event type registered with
+// RegisterEventType. Built-in types stay below it.
+const FirstUserEventType EventType = 1000
+
+// eventTypes holds the event types registered by the program, so domain
+// events such as CacheMiss get their own type instead of borrowing
+// StatementExecution
+var eventTypes = struct {
+	sync.RWMutex
+	byName map[string]EventType
+	names  map[EventType]string
+}{byName: make(map[string]EventType), names: make(map[EventType]string)}
+
+// RegisterEventType returns a new event type with the given name, which
+// String returns and ParseEventType accepts. Registering a name again
+// returns the same type, so packages can share a type by name. IDs are
+// handed out from FirstUserEventType in registration order and may differ
+// between programs, so recorders declare the types they write in the file
+// and readers map them back by name.
+//
+// It panics if the name is empty, contains spaces, commas or = signs, or is
+// the name of a built-in type.
+func RegisterEventType(name string) EventType {
+	t, err := registerEventType(name)
+	if err != nil {
+		panic("recorder: " + err.Error())
+	}
+	return t
+}
+
+// registerEventType registers an event type, or returns why it can't be
+func registerEventType(name string) (EventType, error) {
+	if name == "" || strings.ContainsFunc(name, func(r rune) bool { return unicode.IsSpace(r) || r == ',' || r == '=' }) {
+		return 0, fmt.Errorf("invalid event type name %q", name)
+	}
+	if t, ok := builtinEventType(name); ok {
+		return 0, fmt.Errorf("event type %s is built in (%d)", name, int(t))
+	}
+
+	eventTypes.Lock()
+	defer eventTypes.Unlock()
+	if t, ok := eventTypes.byName[name]; ok {
+		return t, nil
+	}
+	t := FirstUserEventType + EventType(len(eventTypes.byName))
+	eventTypes.byName[name] = t
+	eventTypes.names[t] = name
+	return t, nil
+}
+
+// RegisteredEventTypes returns the registered event types by name
+func RegisteredEventTypes() map[string]EventType {
+	eventTypes.RLock()
+	defer eventTypes.RUnlock()
+	types := make(map[string]EventType, len(eventTypes.byName))
+	for name, t := range eventTypes.byName {
+		types[name] = t
+	}
+	return types
+}
+
+// registeredName returns the name of a registered event type
+func registeredName(t EventType) (string, bool) {
+	eventTypes.RLock()
+	defer eventTypes.RUnlock()
+	name, ok := eventTypes.names[t]
+	return name, ok
+}
+
+// registeredType returns the registered event type with the given name
+func registeredType(name string) (EventType, bool) {
+	eventTypes.RLock()
+	defer eventTypes.RUnlock()
+	t, ok := eventTypes.byName[name]
+	return t, ok
+}
+
+// eventTypesRecord is the line declaring event types registered after a
+// file's metadata was written, before the first event of each
+type eventTypesRecord struct {
+	EventTypes map[string]EventType `json:"event_types"`
+}
+
+// eventTypesPrefix starts an event types line, which no event line does
+var eventTypesPrefix = []byte(`{"event_types":`)
+
+// isEventTypesLine reports whether a line of a recording declares event types
+func isEventTypesLine(line []byte) bool {
+	return bytes.HasPrefix(line, eventTypesPrefix)
+}
+
+// typeMapping maps the IDs of event types declared in a recording to the
+// IDs the same names have in this program, registering the names it
+// doesn't know yet
+type typeMapping map[EventType]EventType
+
+// declare adds the types a recording declares, skipping names that are
+// built in or invalid
+func (m typeMapping) declare(types map[string]EventType) {
+	for name, recorded := range types {
+		if recorded < FirstUserEventType {
+			continue
+		}
+		local, err := registerEventType(name)
+		if err != nil {
+			continue
+		}
+		if local != recorded {
+			m[recorded] = local
+		}
+	}
+}
+
+// declareLine adds the types declared by a metadata or event types line
+func (m typeMapping) declareLine(line []byte) {
+	var record struct {
+		Metadata   *RecordingMetadata   `json:"metadata"`
+		EventTypes map[string]EventType `json:"event_types"`
+	}
+	if json.Unmarshal(line, &record) != nil {
+		return
+	}
+	if record.Metadata != nil {
+		m.declare(record.Metadata.EventTypes)
+	}
+	m.declare(record.EventTypes)
+}
@@ -0,0 +1,121 @@
+package recorder
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegisterEventType(t *testing.T) {
+	cacheMiss := RegisterEventType("TestCacheMiss")
+	if cacheMiss < FirstUserEventType {
+		t.Errorf("RegisterEventType returned %d, want at least %d", cacheMiss, FirstUserEventType)
+	}
+	if again := RegisterEventType("TestCacheMiss"); again != cacheMiss {
+		t.Errorf("Registering the name again returned %d, want %d", again, cacheMiss)
+	}
+	retry := RegisterEventType("TestRetryScheduled")
+	if retry == cacheMiss {
+		t.Errorf("Two names share type %d", retry)
+	}
+
+	if got := cacheMiss.String(); got != "TestCacheMiss" {
+		t.Errorf("String() = %q, want TestCacheMiss", got)
+	}
+	if got, ok := ParseEventType("TestRetryScheduled"); !ok || got != retry {
+		t.Errorf("ParseEventType = %d, %v, want %d", got, ok, retry)
+	}
+	if got, ok := ParseEventType("FunctionEntry"); !ok || got != FuncEntry {
+		t.Errorf("ParseEventType(FunctionEntry) = %d, %v, want built-in %d", got, ok, FuncEntry)
+	}
+	if err := (Event{ID: 1, Timestamp: time.Now(), Type: cacheMiss}).Validate(); err != nil {
+		t.Errorf("Event of a registered type is invalid: %v", err)
+	}
+
+	// Built-in names and names that can't be used in commands are rejected
+	for _, name := range []string{"FunctionEntry", "", "Cache Miss", "a,b", "a=b"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("RegisterEventType(%q) didn't panic", name)
+				}
+			}()
+			RegisterEventType(name)
+		}()
+	}
+}
+
+func TestRegisteredEventTypesRoundTrip(t *testing.T) {
+	before := RegisterEventType("TestHeaderType")
+	for _, compressionType := range []CompressionType{NoCompression, ZstdCompression} {
+		path := filepath.Join(t.TempDir(), "chronogo.events")
+		rec, err := NewFileRecorderWithOptions(path, FileRecorderOptions{CompressionType: compressionType})
+		if err != nil {
+			t.Fatalf("Failed to create recorder: %v", err)
+		}
+		// Registered after the header was written, so declared in its own line
+		after := RegisterEventType(fmt.Sprintf("TestLateType%d", compressionType))
+		now := time.Now()
+		for i, typ := range []EventType{before, after, after} {
+			if err := rec.RecordEvent(Event{ID: int64(i + 1), Timestamp: now, Type: typ}); err != nil {
+				t.Fatalf("Failed to record event: %v", err)
+			}
+		}
+		rec.Close()
+
+		md, err := ReadMetadata(path)
+		if err != nil || md == nil || md.EventTypes["TestHeaderType"] != before {
+			t.Fatalf("Metadata doesn't declare TestHeaderType: %+v, %v", md, err)
+		}
+		events, err := ReadEventsFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 3 || events[0].Type != before || events[1].Type != after || events[2].Type != after {
+			t.Errorf("Read back %+v, want types %d, %d, %d", events, before, after, after)
+		}
+	}
+}
+
+func TestReadForeignEventTypes(t *testing.T) {
+	local := RegisterEventType("TestSharedType")
+
+	// Written by a program that registered types in another order, some of
+	// which this one has never heard of, with IDs that clash with ours
+	foreign := FirstUserEventType + 500
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	lines := []string{
+		fmt.Sprintf(`{"metadata":{"go_version":"go1.24.1","goos":"linux","goarch":"amd64","start_time":%q,"event_types":{"TestForeignType":%d,"TestSharedType":%d}}}`, now, foreign, foreign+1),
+		fmt.Sprintf(`{"ID":1,"Timestamp":%q,"Type":%d}`, now, foreign),
+		fmt.Sprintf(`{"ID":2,"Timestamp":%q,"Type":%d}`, now, foreign+1),
+		fmt.Sprintf(`{"event_types":{"TestForeignLate":%d}}`, local),
+		fmt.Sprintf(`{"ID":3,"Timestamp":%q,"Type":%d}`, now, local),
+		fmt.Sprintf(`{"ID":4,"Timestamp":%q,"Type":%d}`, now, FuncEntry),
+	}
+	path := filepath.Join(t.TempDir(), "foreign.events")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := ReadEventsFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range events {
+		names = append(names, e.Type.String())
+	}
+	want := "TestForeignType,TestSharedType,TestForeignLate,FunctionEntry"
+	if got := strings.Join(names, ","); got != want {
+		t.Errorf("Event types = %s, want %s", got, want)
+	}
+	if events[1].Type != local {
+		t.Errorf("TestSharedType read as %d, want this program's %d", events[1].Type, local)
+	}
+	if _, ok := ParseEventType("TestForeignType"); !ok {
+		t.Error("Types read from a recording aren't registered, so they can't be filtered on")
+	}
+}
@@ -1,6 +1,11 @@
 package recorder
 
-import "time"
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
 
 // EventType represents the type of an event
 type EventType int
@@ -22,6 +27,33 @@ const (
 	SyncOperation
 	// SnapshotEvent indicates a state snapshot was created
 	SnapshotEvent
+	// RngEvent indicates a random number generator was seeded or read
+	RngEvent
+	// TimeReadEvent indicates the program read the wall clock
+	TimeReadEvent
+	// SelectEvent indicates which case a select statement chose
+	SelectEvent
+	// DeferOperation indicates a deferred call starting or finishing, or a recover
+	DeferOperation
+	// ErrorEvent indicates the program produced an error
+	ErrorEvent
+	// IOEvent indicates a read, write or close on a traced file or connection
+	IOEvent
+	// RuntimeEvent indicates a goroutine blocking or unblocking, or a GC
+	// cycle, taken from a runtime/trace capture
+	RuntimeEvent
+	// RecordingStopped marks where recording stopped on reaching a limit
+	RecordingStopped
+	// NetworkOperation indicates an outbound HTTP request or its response
+	NetworkOperation
+	// RotationEvent marks where a FileRecorder started a new file on
+	// reaching its size limit; older files may have been deleted
+	RotationEvent
+	// ContextEvent indicates a context.Context was cancelled or its deadline expired
+	ContextEvent
+	// ProcessSpawnEvent indicates the program started a child process,
+	// whose own recording, if any, it links to
+	ProcessSpawnEvent
 	// ... add more as needed
 )
 
@@ -34,6 +66,57 @@ type Event struct {
 	File      string    // Source file where the event occurred
 	Line      int       // Line number where the event occurred
 	FuncName  string    // Function name where the event occurred
+	Repeat    int       `json:",omitempty"` // Times a compacted statement ran in a row, 0 if not compacted
+	TraceID   string    `json:",omitempty"` // Request or transaction the event belongs to, empty if untraced
+	// Goroutine that recorded the event, 0 if unknown. IDs are the
+	// sequential ones of GoroutineSwitch events while runtime tracing is
+	// on, and runtime goroutine IDs otherwise.
+	GoroutineID int `json:",omitempty"`
+	// Labels classifying the event, set while recording or during replay
+	Tags []string `json:",omitempty"`
+	// Length in bytes Details had before it was truncated to the
+	// MaxDetailsLen instrumentation option, 0 if it wasn't truncated
+	DetailsLen int `json:",omitempty"`
+	// Whether a deferred call recorded the event: its start and end, and
+	// a recover in it
+	Deferred bool `json:",omitempty"`
+	// OS thread (M) and processor (P) the goroutine was running on, taken
+	// from a runtime/trace capture, 0 if unknown. ProcID counts from 1 so
+	// that processor 0 can be told apart from an unknown one.
+	OSThreadID int64 `json:",omitempty"`
+	ProcID     int   `json:",omitempty"`
+}
+
+// HasTag reports whether the event is tagged with label
+func (e Event) HasTag(label string) bool {
+	for _, tag := range e.Tags {
+		if tag == label {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the fields every recorded event is expected to have: a
+// non-zero ID and Timestamp, a known Type, a Line that isn't negative and,
+// for statements, the File they ran in
+func (e Event) Validate() error {
+	if e.ID == 0 {
+		return errors.New("event has no ID")
+	}
+	if e.Timestamp.IsZero() {
+		return fmt.Errorf("event %d has no timestamp", e.ID)
+	}
+	if e.Type.String() == "Unknown" {
+		return fmt.Errorf("event %d has unknown type %d", e.ID, int(e.Type))
+	}
+	if e.Line < 0 {
+		return fmt.Errorf("event %d has negative line %d", e.ID, e.Line)
+	}
+	if e.Type == StatementExecution && e.File == "" {
+		return fmt.Errorf("statement event %d has no file", e.ID)
+	}
+	return nil
 }
 
 // String returns a human-readable representation of the event type
@@ -55,9 +138,67 @@ func (et EventType) String() string {
 		return "SyncOperation"
 	case SnapshotEvent:
 		return "SnapshotEvent"
-	default:
-		return "Unknown"
+	case RngEvent:
+		return "RngEvent"
+	case TimeReadEvent:
+		return "TimeReadEvent"
+	case SelectEvent:
+		return "SelectEvent"
+	case DeferOperation:
+		return "DeferOperation"
+	case ErrorEvent:
+		return "ErrorEvent"
+	case IOEvent:
+		return "IOEvent"
+	case RuntimeEvent:
+		return "RuntimeEvent"
+	case RecordingStopped:
+		return "RecordingStopped"
+	case NetworkOperation:
+		return "NetworkOperation"
+	case RotationEvent:
+		return "RotationEvent"
+	case ContextEvent:
+		return "ContextEvent"
+	case ProcessSpawnEvent:
+		return "ProcessSpawnEvent"
+	}
+	if name, ok := registeredName(et); ok {
+		return name
+	}
+	return "Unknown"
+}
+
+// ParseEventType returns the event type with the given name, as returned by
+// String, built in or registered
+func ParseEventType(name string) (EventType, bool) {
+	if t, ok := builtinEventType(name); ok {
+		return t, true
 	}
+	return registeredType(name)
+}
+
+// builtinEventType returns the built-in event type with the given name
+func builtinEventType(name string) (EventType, bool) {
+	for t := EventType(0); t.String() != "Unknown" && t < FirstUserEventType; t++ {
+		if t.String() == name {
+			return t, true
+		}
+	}
+	return 0, false
+}
+
+// StableSort orders events by Timestamp, breaking ties by ID. Event IDs are
+// assigned monotonically when recording, so events from the same goroutine
+// keep their recorded order even when their timestamps collide. Events with
+// equal Timestamp and ID keep their relative order.
+func StableSort(events []Event) {
+	sort.SliceStable(events, func(i, j int) bool {
+		if !events[i].Timestamp.Equal(events[j].Timestamp) {
+			return events[i].Timestamp.Before(events[j].Timestamp)
+		}
+		return events[i].ID < events[j].ID
+	})
 }
 
 // Configuration options for ChronoGo
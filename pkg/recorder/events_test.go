@@ -0,0 +1,41 @@
+package recorder
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventValidate(t *testing.T) {
+	valid := Event{ID: 1, Timestamp: time.Now(), Type: StatementExecution, File: "main.go", Line: 12}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("Expected a valid event, got %v", err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		modify func(e *Event)
+		want   string
+	}{
+		{"no ID", func(e *Event) { e.ID = 0 }, "no ID"},
+		{"no timestamp", func(e *Event) { e.Timestamp = time.Time{} }, "no timestamp"},
+		{"unknown type", func(e *Event) { e.Type = EventType(999) }, "unknown type 999"},
+		{"negative line", func(e *Event) { e.Line = -1 }, "negative line -1"},
+		{"statement without file", func(e *Event) { e.File = "" }, "has no file"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			e := valid
+			tc.modify(&e)
+			err := e.Validate()
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("Expected an error containing %q, got %v", tc.want, err)
+			}
+		})
+	}
+
+	// Only statements need a file
+	entry := Event{ID: 2, Timestamp: time.Now(), Type: FuncEntry}
+	if err := entry.Validate(); err != nil {
+		t.Errorf("Expected a function entry without a file to be valid, got %v", err)
+	}
+}
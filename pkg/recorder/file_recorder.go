@@ -2,25 +2,48 @@ package recorder
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
-// FileRecorder records events to a file with optional compression
+// FileRecorder records events to a file with optional compression. It is
+// safe for concurrent use.
+//
+// With a MaxFileSize, a full file is renamed with a numeric suffix, e.g.
+// chronogo.events.000001, and recording continues in a fresh file that
+// starts with a RotationEvent.
 type FileRecorder struct {
-	file            *os.File
-	writer          io.Writer
-	bufWriter       *bufio.Writer
-	path            string
-	compressionType CompressionType
-	eventCount      int
+	mu           sync.Mutex // Guards out and file, which Clear and rotation replace
+	out          *WriterRecorder
+	file         *os.File
+	path         string
+	options      FileRecorderOptions
+	fresh        bool  // Whether the file is empty, so the next event is preceded by the metadata
+	initialSize  int64 // Size of the file before out wrote to it
+	nextRotation int   // Sequence number of the next rotated file
 }
 
-// FileRecorderOptions contains options for creating a file recorder
+// FileRecorderOptions contains options for creating a file recorder.
+// MaxFileSize is measured after compression; the zstd encoder writes whole
+// blocks, so a compressed file can exceed it by up to one block.
 type FileRecorderOptions struct {
 	CompressionType CompressionType
+	Metadata        MetadataOptions // What goes into the metadata written at the start of a new file
+	MaxFileSize     int64           // Rotate the file once it holds this many bytes, 0 to disable
+	MaxRotatedFiles int             // Number of rotated files kept, 0 to keep them all
+	// Keys of a secure recording. Only Transcode uses them; record secure
+	// files with a SecureFileRecorder.
+	Security *SecurityOptions
 }
 
 // DefaultFileRecorderOptions returns default options for file recorder
@@ -37,158 +60,349 @@ func NewFileRecorder(path string) (*FileRecorder, error) {
 
 // NewFileRecorderWithOptions creates a new file recorder with the given options
 func NewFileRecorderWithOptions(path string, options FileRecorderOptions) (*FileRecorder, error) {
+	if options.Security != nil {
+		return nil, fmt.Errorf("security options are not supported by FileRecorder, use NewSecureFileRecorderWithOptions")
+	}
 	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
 	if err != nil {
 		return nil, err
 	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
 
-	bufWriter := bufio.NewWriter(f)
-	compressedWriter := NewCompressedWriter(bufWriter, options.CompressionType)
+	// Continue the numbering of files rotated by an earlier run
+	rotated, err := ListRotatedFiles(path)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	nextRotation := 1
+	for _, r := range rotated {
+		if seq, ok := rotationSeq(path, r); ok && seq >= nextRotation {
+			nextRotation = seq + 1
+		}
+	}
 
 	return &FileRecorder{
-		file:            f,
-		writer:          compressedWriter,
-		bufWriter:       bufWriter,
-		path:            path,
-		compressionType: options.CompressionType,
-		eventCount:      0,
+		out:          NewWriterRecorder(f, options),
+		file:         f,
+		path:         path,
+		options:      options,
+		fresh:        info.Size() == 0,
+		initialSize:  info.Size(),
+		nextRotation: nextRotation,
 	}, nil
 }
 
-// RecordEvent writes an event to the file with compression
+// RecordEvent writes an event to the file with compression. A full file is
+// rotated before writing, so every event lands whole in exactly one file.
 func (fr *FileRecorder) RecordEvent(e Event) error {
-	data, err := json.Marshal(e)
-	if err != nil {
-		return err
-	}
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
 
-	// Write the JSON data
-	if _, err := fr.writer.Write(data); err != nil {
-		return err
+	if fr.options.MaxFileSize > 0 && fr.initialSize+fr.out.BytesWritten() >= fr.options.MaxFileSize {
+		if err := fr.rotate(); err != nil {
+			return err
+		}
 	}
+	return fr.write(e)
+}
 
-	// Write a newline
-	if _, err := fr.writer.Write([]byte{'\n'}); err != nil {
-		return err
+// write writes an event, preceded by the metadata if the file is new.
+// Callers hold fr.mu.
+func (fr *FileRecorder) write(e Event) error {
+	// A new recording starts with its metadata; appending to one doesn't
+	if fr.fresh {
+		if err := fr.out.writeMetadata(CollectMetadata(fr.options.Metadata)); err != nil {
+			return fmt.Errorf("failed to write recording metadata: %v", err)
+		}
+		fr.fresh = false
 	}
+	return fr.out.RecordEvent(e)
+}
 
-	// Flush bufWriter to ensure data is written to the file
-	if err := fr.bufWriter.Flush(); err != nil {
-		return err
+// rotate renames the full file with the next sequence number, removes the
+// oldest rotated files beyond MaxRotatedFiles and starts a fresh file with a
+// RotationEvent. Callers hold fr.mu.
+func (fr *FileRecorder) rotate() error {
+	size := fr.initialSize + fr.out.BytesWritten()
+	if err := fr.out.finish(); err != nil {
+		return fmt.Errorf("failed to flush events file: %v", err)
+	}
+	if err := fr.file.Close(); err != nil {
+		return fmt.Errorf("failed to close events file: %v", err)
 	}
 
-	// Increment event count
-	fr.eventCount++
+	rotatedPath := rotationPath(fr.path, fr.nextRotation)
+	if err := os.Rename(fr.path, rotatedPath); err != nil {
+		return fmt.Errorf("failed to rotate events file: %v", err)
+	}
+	fr.nextRotation++
+	details := fmt.Sprintf("Rotated %d bytes to %s", size, filepath.Base(rotatedPath))
 
-	// Check if we need to create a snapshot based on the global interval
-	if SnapshotInterval > 0 && fr.eventCount%SnapshotInterval == 0 {
-		snapshot := CreateSnapshot(e.ID)
-		// Store snapshot metadata with the event
-		// In a real implementation, we would store the actual memory state
-		if err := fr.recordSnapshotEvent(snapshot, fr.eventCount); err != nil {
-			return err
+	if fr.options.MaxRotatedFiles > 0 {
+		rotated, err := ListRotatedFiles(fr.path)
+		if err != nil {
+			fmt.Printf("Warning: Could not list rotated files: %v\n", err)
+		}
+		for len(rotated) > fr.options.MaxRotatedFiles {
+			if err := os.Remove(rotated[0]); err != nil && !os.IsNotExist(err) {
+				fmt.Printf("Warning: Could not remove old rotated file %s: %v\n", rotated[0], err)
+			} else {
+				details += fmt.Sprintf(", removed %s", filepath.Base(rotated[0]))
+			}
+			rotated = rotated[1:]
 		}
 	}
 
-	return nil
+	f, err := os.OpenFile(fr.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create events file: %v", err)
+	}
+	fr.file = f
+	fr.out = NewWriterRecorder(f, fr.options)
+	fr.fresh = true
+	fr.initialSize = 0
+
+	now := time.Now()
+	return fr.write(Event{
+		ID:        now.UnixNano(),
+		Timestamp: now,
+		Type:      RotationEvent,
+		Details:   details,
+	})
 }
 
-// recordSnapshotEvent records a snapshot event to the file
-func (fr *FileRecorder) recordSnapshotEvent(snapshot Snapshot, eventIdx int) error {
-	// Create a special event to mark the snapshot
-	snapshotEvent := Event{
-		ID:        snapshot.ID,
-		Timestamp: CurrentTime(),
-		Type:      SnapshotEvent,
-		Details:   "Snapshot created",
+// rotationPath returns the name a FileRecorder writing path gives its
+// seq-th rotated file
+func rotationPath(path string, seq int) string {
+	return fmt.Sprintf("%s.%06d", path, seq)
+}
+
+// rotationSeq returns the sequence number of a file rotated from path
+func rotationSeq(path, rotated string) (int, bool) {
+	suffix := strings.TrimPrefix(rotated, path+".")
+	if len(suffix) != 6 || suffix == rotated {
+		return 0, false
 	}
+	seq, err := strconv.Atoi(suffix)
+	return seq, err == nil
+}
 
-	data, err := json.Marshal(snapshotEvent)
+// ListRotatedFiles returns the files a FileRecorder with a MaxFileSize
+// rotated from path, oldest first, not including path itself
+func ListRotatedFiles(path string) ([]string, error) {
+	matches, err := filepath.Glob(path + ".[0-9][0-9][0-9][0-9][0-9][0-9]")
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// Write the snapshot event
-	if _, err := fr.writer.Write(data); err != nil {
-		return err
-	}
-	if _, err := fr.writer.Write([]byte{'\n'}); err != nil {
-		return err
-	}
+	// Sequence numbers are zero-padded, so names sort in recording order
+	sort.Strings(matches)
+	return matches, nil
+}
 
-	return fr.bufWriter.Flush()
+// BytesWritten returns the number of bytes written to the file by this recorder
+func (fr *FileRecorder) BytesWritten() int64 {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	return fr.out.BytesWritten()
 }
 
-// GetEvents reads all events from the file, decompressing if necessary
+// FileSize returns the current size of the file on disk, including events
+// recorded before this recorder opened it
+func (fr *FileRecorder) FileSize() int64 {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	info, err := fr.file.Stat()
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// GetEvents reads all events from the retained rotated files and the
+// file, decompressing if necessary
 func (fr *FileRecorder) GetEvents() []Event {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	var events []Event
+	rotated, err := ListRotatedFiles(fr.path)
+	if err != nil {
+		fmt.Printf("Warning: Could not list rotated files: %v\n", err)
+	}
+	for _, path := range rotated {
+		rotatedEvents, err := ReadEventsFile(path)
+		if err != nil {
+			fmt.Printf("Warning: Error reading rotated file: %v\n", err)
+			continue
+		}
+		events = append(events, rotatedEvents...)
+	}
+
 	// Ensure data is flushed to disk
-	if err := CloseCompressedWriter(fr.writer, fr.compressionType); err != nil {
+	if err := fr.out.finish(); err != nil {
 		// Log the error but continue - we still want to try reading events
 		fmt.Printf("Warning: Error closing compressed writer: %v\n", err)
 	}
-	fr.bufWriter.Flush()
+	// Reopen the writer since we closed it
+	defer fr.out.reopen()
 
 	// Open the file for reading
 	f, err := os.Open(fr.path)
 	if err != nil {
-		return nil
+		return events
 	}
 	defer f.Close()
 
-	// Create a reader with decompression if needed
-	reader, err := NewCompressedReader(f, fr.compressionType)
+	current, err := DecodeEvents(f, fr.out.compressionType)
 	if err != nil {
-		return nil
-	}
-
-	var events []Event
-	scanner := bufio.NewScanner(reader)
-	for scanner.Scan() {
-		var event Event
-		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
-			continue
-		}
-		events = append(events, event)
+		return events
 	}
-
-	// Reopen the writer since we closed it
-	fr.writer = NewCompressedWriter(fr.bufWriter, fr.compressionType)
-
-	return events
+	return append(events, current...)
 }
 
-// Clear clears the file and resets the recorder
+// Clear clears the file, removes the rotated files and resets the recorder
 func (fr *FileRecorder) Clear() {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
 	// Ignore errors in Clear() as per interface
-	if err := CloseCompressedWriter(fr.writer, fr.compressionType); err != nil {
+	if err := fr.out.finish(); err != nil {
 		fmt.Printf("Warning: Error closing compressed writer: %v\n", err)
 	}
-	fr.bufWriter.Flush()
 	fr.file.Close()
 	if err := os.Truncate(fr.path, 0); err != nil {
 		fmt.Printf("Warning: Error truncating file: %v\n", err)
 	}
+	rotated, _ := ListRotatedFiles(fr.path)
+	for _, path := range rotated {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			fmt.Printf("Warning: Could not remove rotated file %s: %v\n", path, err)
+		}
+	}
 
 	// Reopen the file
 	f, err := os.OpenFile(fr.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
 	if err == nil {
 		fr.file = f
-		fr.bufWriter = bufio.NewWriter(f)
-		fr.writer = NewCompressedWriter(fr.bufWriter, fr.compressionType)
-		fr.eventCount = 0
+		fr.out = NewWriterRecorder(f, fr.options)
+		fr.fresh = true
+		fr.initialSize = 0
 	}
 }
 
 // Close flushes and closes the file
 func (fr *FileRecorder) Close() error {
-	// Close the compressed writer if needed
-	if err := CloseCompressedWriter(fr.writer, fr.compressionType); err != nil {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	if err := fr.out.finish(); err != nil {
 		return err
 	}
+	return fr.file.Close()
+}
+
+// ReadEventsFile reads all events from an events file written by a FileRecorder.
+// Compression is detected automatically. Lines that can't be parsed are skipped
+// with a warning.
+func ReadEventsFile(path string) ([]Event, error) {
+	var events []Event
+	err := ScanEventsFile(path, func(e Event) error {
+		events = append(events, e)
+		return nil
+	})
+	return events, err
+}
 
-	if err := fr.bufWriter.Flush(); err != nil {
+// ScanEventsFile calls fn for each event in an events file, like
+// ReadEventsFile but without holding the events in memory, stopping at the
+// first error fn returns
+func ScanEventsFile(path string, fn func(Event) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error opening events file: %v", err)
+	}
+	defer f.Close()
+
+	if err := scanEventStream(f, fn); err != nil {
+		return fmt.Errorf("error reading events file: %v", err)
+	}
+	return nil
+}
+
+// scanEventStream calls fn for each event in the contents of an events file,
+// detecting compression from the header
+func scanEventStream(r io.Reader, fn func(Event) error) error {
+	buffered := bufio.NewReader(r)
+	compressionType := detectCompression(buffered)
+
+	reader, err := NewCompressedReader(buffered, compressionType)
+	if err != nil {
 		return err
 	}
+	return scanEvents(reader, DefaultDecoderOptions(), fn)
+}
 
-	return fr.file.Close()
+// EventOffset locates the serialized line of an event in an events file
+type EventOffset struct {
+	Offset    int64     // Byte offset of the start of the line
+	Length    int       // Length of the line, without the newline
+	ID        int64     // ID of the event on the line
+	Timestamp time.Time // Timestamp of the event on the line
+}
+
+// IndexEventsFile returns the byte offset of every event line in an
+// uncompressed events file, in file order. Lines that can't be parsed as
+// events are left out.
+func IndexEventsFile(path string) ([]EventOffset, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening events file: %v", err)
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	if detectCompression(reader) != NoCompression {
+		return nil, fmt.Errorf("events file %s is compressed, byte offsets are only available for uncompressed recordings", path)
+	}
+
+	var index []EventOffset
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			content := bytes.TrimRight(line, "\r\n")
+			var event Event
+			if len(content) > 0 && !isMetadataLine(content) && !isEventTypesLine(content) && json.Unmarshal(content, &event) == nil {
+				index = append(index, EventOffset{Offset: offset, Length: len(content), ID: event.ID, Timestamp: event.Timestamp})
+			}
+			offset += int64(len(line))
+		}
+		if errors.Is(err, io.EOF) {
+			return index, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading events file: %v", err)
+		}
+	}
+}
+
+// ReadEventLine returns the serialized line of an event indexed by IndexEventsFile
+func ReadEventLine(path string, off EventOffset) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening events file: %v", err)
+	}
+	defer f.Close()
+
+	line := make([]byte, off.Length)
+	if _, err := f.ReadAt(line, off.Offset); err != nil {
+		return nil, fmt.Errorf("error reading event at offset %d: %v", off.Offset, err)
+	}
+	return line, nil
 }
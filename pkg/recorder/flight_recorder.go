@@ -0,0 +1,274 @@
+package recorder
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FlightOptions contains options for creating a flight recorder. SegmentSize
+// is measured after compression; the zstd encoder writes whole blocks, so a
+// compressed segment can exceed it by up to one block.
+type FlightOptions struct {
+	SegmentDuration time.Duration // Start a new segment after this long, 0 to disable
+	SegmentSize     int64         // Start a new segment after this many bytes on disk, 0 to disable
+	MaxSegments     int           // Number of segments kept, including the one being written
+	CompressionType CompressionType
+	Compaction      *CompactOptions // Compact each segment once it is closed, nil to keep segments as recorded
+}
+
+// DefaultFlightOptions returns default options for flight recorder
+func DefaultFlightOptions() FlightOptions {
+	return FlightOptions{
+		SegmentDuration: time.Minute,
+		SegmentSize:     10 * 1024 * 1024,
+		MaxSegments:     10,
+		CompressionType: DefaultCompression,
+	}
+}
+
+// FlightRecorder records continuously into rotating segment files in a
+// directory and keeps only the most recent ones, so recording can stay on in
+// production with bounded disk use. DumpTo stitches the retained segments
+// into a single events file when a recording is actually needed.
+//
+// Segments left in the directory by an earlier run are kept and count
+// towards MaxSegments.
+type FlightRecorder struct {
+	dir     string
+	options FlightOptions
+
+	mu           sync.Mutex
+	current      *FileRecorder
+	currentPath  string
+	segmentStart time.Time
+	segments     []string // Closed segments, oldest first
+	nextSeq      int
+	closed       bool
+}
+
+// NewFlightRecorder creates a flight recorder that writes segments into dir
+func NewFlightRecorder(dir string, options FlightOptions) (*FlightRecorder, error) {
+	if options.MaxSegments <= 0 {
+		options.MaxSegments = DefaultFlightOptions().MaxSegments
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create flight recorder directory: %v", err)
+	}
+
+	// Pick up segments from an earlier run
+	existing, err := ListSegments(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	fr := &FlightRecorder{
+		dir:      dir,
+		options:  options,
+		segments: existing,
+	}
+	for _, path := range existing {
+		var seq int
+		if _, err := fmt.Sscanf(filepath.Base(path), "segment-%d.events", &seq); err == nil && seq >= fr.nextSeq {
+			fr.nextSeq = seq + 1
+		}
+	}
+
+	if err := fr.openSegment(); err != nil {
+		return nil, err
+	}
+	return fr, nil
+}
+
+// openSegment starts a new segment file and removes segments beyond MaxSegments
+func (fr *FlightRecorder) openSegment() error {
+	path := filepath.Join(fr.dir, fmt.Sprintf("segment-%06d.events", fr.nextSeq))
+	rec, err := NewFileRecorderWithOptions(path, FileRecorderOptions{CompressionType: fr.options.CompressionType})
+	if err != nil {
+		return fmt.Errorf("failed to create segment: %v", err)
+	}
+
+	fr.nextSeq++
+	fr.current = rec
+	fr.currentPath = path
+	fr.segmentStart = time.Now()
+
+	for len(fr.segments)+1 > fr.options.MaxSegments {
+		if err := os.Remove(fr.segments[0]); err != nil && !os.IsNotExist(err) {
+			fmt.Printf("Warning: Could not remove old segment %s: %v\n", fr.segments[0], err)
+		}
+		fr.segments = fr.segments[1:]
+	}
+	return nil
+}
+
+// rotate closes the current segment and starts the next one
+func (fr *FlightRecorder) rotate() error {
+	if err := fr.current.Close(); err != nil {
+		return fmt.Errorf("failed to close segment: %v", err)
+	}
+	if fr.options.Compaction != nil {
+		if _, _, err := CompactFile(fr.currentPath, fr.currentPath, *fr.options.Compaction); err != nil {
+			fmt.Printf("Warning: Could not compact segment %s: %v\n", filepath.Base(fr.currentPath), err)
+		}
+	}
+	fr.segments = append(fr.segments, fr.currentPath)
+	return fr.openSegment()
+}
+
+// needsRotation reports whether the current segment is full
+func (fr *FlightRecorder) needsRotation() bool {
+	if fr.options.SegmentSize > 0 && fr.current.BytesWritten() >= fr.options.SegmentSize {
+		return true
+	}
+	return fr.options.SegmentDuration > 0 && time.Since(fr.segmentStart) >= fr.options.SegmentDuration
+}
+
+// RecordEvent writes an event to the current segment. Segments are rotated
+// before writing, so every event lands whole in exactly one segment.
+func (fr *FlightRecorder) RecordEvent(e Event) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	if fr.closed {
+		return errors.New("flight recorder is closed")
+	}
+
+	if fr.needsRotation() {
+		if err := fr.rotate(); err != nil {
+			return err
+		}
+	}
+	return fr.current.RecordEvent(e)
+}
+
+// Segments returns the paths of the retained segment files, oldest first,
+// including the one being written
+func (fr *FlightRecorder) Segments() []string {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	segments := append([]string(nil), fr.segments...)
+	if fr.closed {
+		return segments
+	}
+	return append(segments, fr.currentPath)
+}
+
+// BytesWritten returns the number of bytes written to the current segment
+func (fr *FlightRecorder) BytesWritten() int64 {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	return fr.current.BytesWritten()
+}
+
+// retainedEvents calls fn for every event in the retained segments, oldest first
+func (fr *FlightRecorder) retainedEvents(fn func(Event) error) error {
+	for _, path := range fr.segments {
+		events, err := ReadEventsFile(path)
+		if err != nil {
+			return err
+		}
+		for _, e := range events {
+			if err := fn(e); err != nil {
+				return err
+			}
+		}
+	}
+
+	if !fr.closed {
+		for _, e := range fr.current.GetEvents() {
+			if err := fn(e); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// GetEvents returns all events in the retained segments
+func (fr *FlightRecorder) GetEvents() []Event {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	var events []Event
+	if err := fr.retainedEvents(func(e Event) error {
+		events = append(events, e)
+		return nil
+	}); err != nil {
+		fmt.Printf("Warning: Error reading segments: %v\n", err)
+	}
+	return events
+}
+
+// DumpTo stitches the retained segments into a single events file at path
+// that can be replayed like any other recording. Recording continues while
+// and after the dump is written.
+func (fr *FlightRecorder) DumpTo(path string) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create dump file: %v", err)
+	}
+	defer f.Close()
+
+	bufWriter := bufio.NewWriter(f)
+	out := NewWriterRecorder(bufWriter, FileRecorderOptions{CompressionType: fr.options.CompressionType})
+
+	// Copy events as they are; snapshot markers are already in the segments
+	if err := fr.retainedEvents(out.writeEvent); err != nil {
+		return fmt.Errorf("failed to write dump: %v", err)
+	}
+
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if err := bufWriter.Flush(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// Clear removes all retained segments and starts a new one
+func (fr *FlightRecorder) Clear() {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	if fr.closed {
+		return
+	}
+
+	if err := fr.current.Close(); err != nil {
+		fmt.Printf("Warning: Error closing segment: %v\n", err)
+	}
+	for _, path := range append(fr.segments, fr.currentPath) {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			fmt.Printf("Warning: Could not remove segment %s: %v\n", path, err)
+		}
+	}
+	fr.segments = nil
+
+	if err := fr.openSegment(); err != nil {
+		fmt.Printf("Warning: Error starting segment: %v\n", err)
+	}
+}
+
+// Close flushes and closes the current segment. Retained segments stay on disk.
+func (fr *FlightRecorder) Close() error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	if fr.closed {
+		return nil
+	}
+	fr.closed = true
+	fr.segments = append(fr.segments, fr.currentPath)
+	return fr.current.Close()
+}
@@ -0,0 +1,121 @@
+package recorder
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// checkContiguous verifies events are exactly IDs first..last in order
+func checkContiguous(t *testing.T, events []Event, first, last int64) {
+	t.Helper()
+
+	if int64(len(events)) != last-first+1 {
+		t.Fatalf("Expected events %d..%d, got %d events", first, last, len(events))
+	}
+	for i, e := range events {
+		if e.ID != first+int64(i) {
+			t.Fatalf("Expected event %d at position %d, got %d", first+int64(i), i, e.ID)
+		}
+	}
+}
+
+func TestFlightRecorderRetention(t *testing.T) {
+	originalInterval := SnapshotInterval
+	SnapshotInterval = 0
+	defer func() {
+		SnapshotInterval = originalInterval
+	}()
+
+	dir := t.TempDir()
+	fr, err := NewFlightRecorder(dir, FlightOptions{
+		SegmentSize:     1, // One event per segment
+		MaxSegments:     3,
+		CompressionType: NoCompression,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create flight recorder: %v", err)
+	}
+	defer fr.Close()
+
+	for id := int64(1); id <= 10; id++ {
+		if err := fr.RecordEvent(Event{ID: id, Timestamp: time.Now(), Type: StatementExecution, Details: "tick"}); err != nil {
+			t.Fatalf("Failed to record event: %v", err)
+		}
+	}
+
+	segments, err := ListSegments(dir)
+	if err != nil {
+		t.Fatalf("Failed to list segments: %v", err)
+	}
+	if len(segments) != 3 || len(fr.Segments()) != 3 {
+		t.Fatalf("Expected 3 retained segments, got %d on disk and %d reported", len(segments), len(fr.Segments()))
+	}
+
+	// Only the newest events survive, with nothing lost at segment boundaries
+	checkContiguous(t, fr.GetEvents(), 8, 10)
+
+	dumpPath := filepath.Join(t.TempDir(), "dump.events")
+	if err := fr.DumpTo(dumpPath); err != nil {
+		t.Fatalf("Failed to dump: %v", err)
+	}
+	events, err := ReadEventsFile(dumpPath)
+	if err != nil {
+		t.Fatalf("Failed to read dump: %v", err)
+	}
+	checkContiguous(t, events, 8, 10)
+
+	// Recording continues after a dump
+	if err := fr.RecordEvent(Event{ID: 11, Timestamp: time.Now(), Type: StatementExecution, Details: "tick"}); err != nil {
+		t.Fatalf("Failed to record after dump: %v", err)
+	}
+	checkContiguous(t, fr.GetEvents(), 9, 11)
+}
+
+func TestFlightRecorderDumpCompressed(t *testing.T) {
+	dir := t.TempDir()
+	fr, err := NewFlightRecorder(dir, FlightOptions{
+		SegmentDuration: time.Millisecond,
+		MaxSegments:     100,
+		CompressionType: ZstdCompression,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create flight recorder: %v", err)
+	}
+
+	for id := int64(1); id <= 20; id++ {
+		if err := fr.RecordEvent(Event{ID: id, Timestamp: time.Now(), Type: FuncEntry, Details: "Entering work"}); err != nil {
+			t.Fatalf("Failed to record event: %v", err)
+		}
+		if id%5 == 0 {
+			time.Sleep(2 * time.Millisecond)
+		}
+	}
+	if len(fr.Segments()) < 2 {
+		t.Errorf("Expected time-based rotation, got %d segments", len(fr.Segments()))
+	}
+	if err := fr.Close(); err != nil {
+		t.Fatalf("Failed to close flight recorder: %v", err)
+	}
+
+	dumpPath := filepath.Join(t.TempDir(), "dump.events")
+	if err := fr.DumpTo(dumpPath); err != nil {
+		t.Fatalf("Failed to dump: %v", err)
+	}
+	events, err := ReadEventsFile(dumpPath)
+	if err != nil {
+		t.Fatalf("Failed to read dump: %v", err)
+	}
+	checkContiguous(t, events, 1, 20)
+
+	// A new recorder in the same directory continues after the old segments
+	reopened, err := NewFlightRecorder(dir, FlightOptions{MaxSegments: 100, CompressionType: ZstdCompression})
+	if err != nil {
+		t.Fatalf("Failed to reopen flight recorder: %v", err)
+	}
+	defer reopened.Close()
+	if err := reopened.RecordEvent(Event{ID: 21, Timestamp: time.Now(), Type: FuncExit, Details: "Exiting work"}); err != nil {
+		t.Fatalf("Failed to record event: %v", err)
+	}
+	checkContiguous(t, reopened.GetEvents(), 1, 21)
+}
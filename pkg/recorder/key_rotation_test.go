@@ -0,0 +1,201 @@
+package recorder
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// keyOptions returns security options that use key for both encryption and integrity
+func keyOptions(key []byte, previous ...[]byte) SecurityOptions {
+	opts := DefaultSecurityOptions()
+	WithEncryption(key)(&opts)
+	WithIntegrityCheck(key)(&opts)
+	WithDecryptionKeys(previous...)(&opts)
+	WithPreviousIntegrityKeys(previous...)(&opts)
+	return opts
+}
+
+// recordSecure appends events to path with the given options
+func recordSecure(t *testing.T, path string, opts SecurityOptions, compressionType CompressionType, events []Event) {
+	t.Helper()
+
+	rec, err := NewSecureFileRecorderWithOptions(path, SecureFileRecorderOptions{
+		SecurityOptions: opts,
+		CompressionType: compressionType,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create secure recorder: %v", err)
+	}
+	for _, e := range events {
+		if err := rec.RecordEvent(e); err != nil {
+			t.Fatalf("Failed to record event: %v", err)
+		}
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatalf("Failed to close recorder: %v", err)
+	}
+}
+
+// keyIDsInFile returns the key IDs of the events in an uncompressed secure events file
+func keyIDsInFile(t *testing.T, path string) map[string]int {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	defer f.Close()
+
+	ids := make(map[string]int)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var se SecureEvent
+		if err := json.Unmarshal(scanner.Bytes(), &se); err != nil {
+			t.Fatalf("Failed to parse event: %v", err)
+		}
+		ids[se.KeyID]++
+	}
+	return ids
+}
+
+func TestKeyRotation(t *testing.T) {
+	originalInterval := SnapshotInterval
+	SnapshotInterval = 0
+	defer func() {
+		SnapshotInterval = originalInterval
+	}()
+
+	keyA := []byte("0123456789ABCDEF")
+	keyB := []byte("FEDCBA9876543210")
+
+	for _, compressionType := range []CompressionType{NoCompression, ZstdCompression} {
+		t.Run(compressionTypeToString(compressionType), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "rotated.events")
+
+			// Half the recording is written before the rotation, half after
+			recordSecure(t, path, keyOptions(keyA), compressionType, []Event{
+				{ID: 1, Timestamp: time.Now(), Type: FuncEntry, Details: "before rotation 1"},
+				{ID: 2, Timestamp: time.Now(), Type: FuncExit, Details: "before rotation 2"},
+			})
+			recordSecure(t, path, keyOptions(keyB, keyA), compressionType, []Event{
+				{ID: 3, Timestamp: time.Now(), Type: FuncEntry, Details: "after rotation 1"},
+				{ID: 4, Timestamp: time.Now(), Type: FuncExit, Details: "after rotation 2"},
+			})
+
+			if compressionType == NoCompression {
+				ids := keyIDsInFile(t, path)
+				if ids[KeyID(keyA)] != 2 || ids[KeyID(keyB)] != 2 {
+					t.Errorf("Expected two events under each key, got %v", ids)
+				}
+			}
+
+			// The new key alone can only open the events written after the rotation
+			events, err := ReadSecureEventsFile(path, keyOptions(keyB))
+			if err != nil {
+				t.Fatalf("Failed to read events: %v", err)
+			}
+			if len(events) != 2 {
+				t.Errorf("Expected 2 events readable without the old key, got %d", len(events))
+			}
+
+			// With the old key as a decryption key the whole mixed file opens
+			rec, err := NewSecureFileRecorderWithOptions(path, SecureFileRecorderOptions{
+				SecurityOptions: keyOptions(keyB, keyA),
+				CompressionType: compressionType,
+			})
+			if err != nil {
+				t.Fatalf("Failed to create secure recorder: %v", err)
+			}
+			if events := rec.GetEvents(); len(events) != 4 {
+				t.Errorf("Expected 4 events from mixed-key file, got %d", len(events))
+			}
+			tampered, err := rec.DetectTampering()
+			if err != nil || tampered {
+				t.Errorf("Mixed-key file should verify, got tampered=%v err=%v", tampered, err)
+			}
+			rec.Close()
+
+			// Rekeying re-seals only the events under the old key
+			rekeyed, err := RekeyEventsFile(path, keyOptions(keyB, keyA))
+			if err != nil {
+				t.Fatalf("Failed to rekey: %v", err)
+			}
+			if rekeyed != 2 {
+				t.Errorf("Expected 2 events rekeyed, got %d", rekeyed)
+			}
+
+			events, err = ReadSecureEventsFile(path, keyOptions(keyB))
+			if err != nil {
+				t.Fatalf("Failed to read events: %v", err)
+			}
+			if len(events) != 4 {
+				t.Fatalf("Expected all 4 events readable with the new key, got %d", len(events))
+			}
+			for i, e := range events {
+				if e.ID != int64(i+1) {
+					t.Errorf("Expected event %d at position %d, got %d", i+1, i, e.ID)
+				}
+			}
+
+			// A second run has nothing left to do
+			if rekeyed, err := RekeyEventsFile(path, keyOptions(keyB, keyA)); err != nil || rekeyed != 0 {
+				t.Errorf("Expected no events rekeyed on second run, got %d, %v", rekeyed, err)
+			}
+		})
+	}
+}
+
+func TestPreviousIntegrityKeys(t *testing.T) {
+	keyA := []byte("0123456789ABCDEF")
+	keyB := []byte("FEDCBA9876543210")
+
+	event := Event{ID: 1, Timestamp: time.Now(), Type: FuncEntry, Details: "signed before rotation"}
+	secureEvent, err := SecureEventFromEvent(event, keyOptions(keyA))
+	if err != nil {
+		t.Fatalf("Failed to create secure event: %v", err)
+	}
+
+	// An old encryption key doesn't verify HMACs
+	opts := keyOptions(keyB)
+	WithDecryptionKeys(keyA)(&opts)
+	if _, err := secureEvent.GetOriginalEvent(opts); err == nil {
+		t.Error("Expected a decryption key not to be used as an integrity key")
+	}
+
+	WithPreviousIntegrityKeys(keyA)(&opts)
+	if got, err := secureEvent.GetOriginalEvent(opts); err != nil || got.Details != event.Details {
+		t.Errorf("Expected the previous integrity key to verify the event, got %q (%v)", got.Details, err)
+	}
+}
+
+func TestDecryptWithoutKeyID(t *testing.T) {
+	keyA := []byte("0123456789ABCDEF")
+	keyB := []byte("FEDCBA9876543210")
+
+	event := Event{ID: 1, Timestamp: time.Now(), Type: FuncEntry, Details: "legacy event"}
+	secureEvent, err := SecureEventFromEvent(event, keyOptions(keyA))
+	if err != nil {
+		t.Fatalf("Failed to create secure event: %v", err)
+	}
+	if secureEvent.KeyID != KeyID(keyA) {
+		t.Errorf("Expected key ID %s, got %s", KeyID(keyA), secureEvent.KeyID)
+	}
+
+	// Events written before key IDs existed are opened by trying each key in order
+	secureEvent.KeyID = ""
+	got, err := secureEvent.GetOriginalEvent(keyOptions(keyB, keyA))
+	if err != nil {
+		t.Fatalf("Failed to open event without key ID: %v", err)
+	}
+	if got.Details != event.Details {
+		t.Errorf("Expected %q, got %q", event.Details, got.Details)
+	}
+
+	if _, err := secureEvent.GetOriginalEvent(keyOptions(keyB)); err == nil {
+		t.Errorf("Expected error opening event without the right key")
+	}
+}
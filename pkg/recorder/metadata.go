@@ -0,0 +1,249 @@
+package recorder
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrSealedMetadata is returned by ReadMetadata for a secure recording,
+// whose metadata can only be read with its key, see ReadSecureMetadata
+var ErrSealedMetadata = errors.New("recording metadata is sealed; a key is needed to read it")
+
+// RecordingMetadata describes the program and machine a recording was made
+// on. FileRecorder and SecureFileRecorder write it as the first line of
+// every file they create.
+type RecordingMetadata struct {
+	GoVersion     string    `json:"go_version"`
+	GOOS          string    `json:"goos"`
+	GOARCH        string    `json:"goarch"`
+	ModulePath    string    `json:"module_path,omitempty"`    // Main module of the recorded binary
+	ModuleVersion string    `json:"module_version,omitempty"` // "(devel)" when built from a checkout
+	VCSRevision   string    `json:"vcs_revision,omitempty"`   // Commit the binary was built from, if embedded
+	VCSModified   bool      `json:"vcs_modified,omitempty"`   // Whether the checkout had uncommitted changes
+	Hostname      string    `json:"hostname,omitempty"`
+	Args          []string  `json:"args,omitempty"`          // Command line, redacted
+	DelveVersion  string    `json:"delve_version,omitempty"` // Version of the dlv the program ran under, if any
+	StartTime     time.Time `json:"start_time"`
+	// ID of the ProcessSpawnEvent in the parent's recording that started
+	// this process, 0 if no recorded program started it
+	ParentID int64 `json:"parent_id,omitempty"`
+	// Event types registered with RegisterEventType, by name, so readers
+	// can map their IDs back to names
+	EventTypes map[string]EventType `json:"event_types,omitempty"`
+}
+
+// MetadataOptions controls what identifying details go into the metadata
+// of a recording
+type MetadataOptions struct {
+	OmitHostname bool // Leave out the machine's hostname
+	OmitArgs     bool // Leave out the command line
+	// Version of the dlv the program is recorded under, empty if it
+	// isn't running under Delve
+	DelveVersion string
+}
+
+// metadataRecord is the line that carries the metadata. Secure recordings
+// seal it like their events.
+type metadataRecord struct {
+	Metadata *RecordingMetadata `json:"metadata,omitempty"`
+	Sealed   *SecureBlob        `json:"sealed_metadata,omitempty"`
+}
+
+// Metadata lines start with one of these, which no event line does
+var (
+	metadataPrefix       = []byte(`{"metadata":`)
+	sealedMetadataPrefix = []byte(`{"sealed_metadata":`)
+)
+
+// isMetadataLine reports whether a line of a recording is its metadata record
+func isMetadataLine(line []byte) bool {
+	return bytes.HasPrefix(line, metadataPrefix) || bytes.HasPrefix(line, sealedMetadataPrefix)
+}
+
+// CollectMetadata describes the running program. Arguments are redacted
+// with the default redaction patterns.
+func CollectMetadata(opts MetadataOptions) RecordingMetadata {
+	return collectMetadata(opts, DefaultSecurityOptions())
+}
+
+// collectMetadata describes the running program, redacting arguments with
+// the patterns of security
+func collectMetadata(opts MetadataOptions, security SecurityOptions) RecordingMetadata {
+	md := RecordingMetadata{
+		GoVersion:    runtime.Version(),
+		GOOS:         runtime.GOOS,
+		GOARCH:       runtime.GOARCH,
+		StartTime:    time.Now(),
+		DelveVersion: opts.DelveVersion,
+	}
+	if types := RegisteredEventTypes(); len(types) > 0 {
+		md.EventTypes = types
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		md.ModulePath = info.Main.Path
+		md.ModuleVersion = info.Main.Version
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				md.VCSRevision = setting.Value
+			case "vcs.modified":
+				md.VCSModified = setting.Value == "true"
+			}
+		}
+	}
+	if id, err := strconv.ParseInt(os.Getenv(ParentIDEnv), 10, 64); err == nil {
+		md.ParentID = id
+	}
+	if !opts.OmitHostname {
+		md.Hostname, _ = os.Hostname()
+	}
+	if !opts.OmitArgs {
+		md.Args = make([]string, len(os.Args))
+		for i, arg := range os.Args {
+			md.Args[i] = string(RedactData([]byte(arg), security.RedactionPatterns, security.RedactionReplacement))
+		}
+	}
+	return md
+}
+
+// String describes the metadata one field per line, e.g. "Go version: go1.24.1"
+func (m RecordingMetadata) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Go version: %s\n", m.GoVersion)
+	fmt.Fprintf(&b, "Platform:   %s/%s\n", m.GOOS, m.GOARCH)
+	if m.ModulePath != "" {
+		fmt.Fprintf(&b, "Module:     %s %s\n", m.ModulePath, m.ModuleVersion)
+	}
+	if m.VCSRevision != "" {
+		modified := ""
+		if m.VCSModified {
+			modified = " (modified)"
+		}
+		fmt.Fprintf(&b, "Revision:   %s%s\n", m.VCSRevision, modified)
+	}
+	if m.Hostname != "" {
+		fmt.Fprintf(&b, "Host:       %s\n", m.Hostname)
+	}
+	if len(m.Args) > 0 {
+		fmt.Fprintf(&b, "Command:    %s\n", strings.Join(m.Args, " "))
+	}
+	if m.DelveVersion != "" {
+		fmt.Fprintf(&b, "Delve:      %s\n", m.DelveVersion)
+	}
+	if len(m.EventTypes) > 0 {
+		names := make([]string, 0, len(m.EventTypes))
+		for name := range m.EventTypes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Fprintf(&b, "Event types: %s\n", strings.Join(names, ", "))
+	}
+	if m.ParentID != 0 {
+		fmt.Fprintf(&b, "Parent:     spawn event %d\n", m.ParentID)
+	}
+	fmt.Fprintf(&b, "Started:    %s", m.StartTime.Format(time.RFC3339))
+	return b.String()
+}
+
+// writeMetadataLine writes the metadata record, sealed with security if it
+// isn't nil
+func writeMetadataLine(w io.Writer, md RecordingMetadata, security *SecurityOptions) error {
+	record := metadataRecord{Metadata: &md}
+	if security != nil {
+		data, err := json.Marshal(md)
+		if err != nil {
+			return err
+		}
+		blob, err := SealBlob(data, *security)
+		if err != nil {
+			return err
+		}
+		record = metadataRecord{Sealed: &blob}
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(line, '\n'))
+	return err
+}
+
+// ReadMetadata returns the metadata at the start of an events file, or nil
+// if the file has none, as files recorded before metadata was added don't.
+// It returns ErrSealedMetadata for a secure recording.
+func ReadMetadata(path string) (*RecordingMetadata, error) {
+	return readMetadataFile(path, nil)
+}
+
+// ReadSecureMetadata returns the metadata at the start of an events file
+// written by a SecureFileRecorder, opening it with opts
+func ReadSecureMetadata(path string, opts SecurityOptions) (*RecordingMetadata, error) {
+	return readMetadataFile(path, &opts)
+}
+
+// readMetadataFile reads the metadata of the file at path
+func readMetadataFile(path string, security *SecurityOptions) (*RecordingMetadata, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening events file: %v", err)
+	}
+	defer f.Close()
+	return readMetadata(f, security)
+}
+
+// readMetadata reads the metadata record from the start of the contents of
+// an events file, detecting compression
+func readMetadata(r io.Reader, security *SecurityOptions) (*RecordingMetadata, error) {
+	buffered := bufio.NewReader(r)
+	compressionType := detectCompression(buffered)
+	reader, err := NewCompressedReader(buffered, compressionType)
+	if err != nil {
+		return nil, err
+	}
+
+	// The record is on the first line, which is far shorter than an event
+	// can be
+	line, err := bufio.NewReaderSize(reader, 64*1024).ReadSlice('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		if errors.Is(err, bufio.ErrBufferFull) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading metadata: %v", err)
+	}
+	line = bytes.TrimSpace(line)
+	if !isMetadataLine(line) {
+		return nil, nil
+	}
+
+	var record metadataRecord
+	if err := json.Unmarshal(line, &record); err != nil {
+		return nil, fmt.Errorf("failed to parse metadata: %v", err)
+	}
+	if record.Metadata != nil {
+		return record.Metadata, nil
+	}
+	if security == nil {
+		return nil, ErrSealedMetadata
+	}
+	data, err := record.Sealed.Open(*security)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open metadata: %v", err)
+	}
+	var md RecordingMetadata
+	if err := json.Unmarshal(data, &md); err != nil {
+		return nil, fmt.Errorf("failed to parse metadata: %v", err)
+	}
+	return &md, nil
+}
@@ -0,0 +1,141 @@
+package recorder
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileRecorderMetadata(t *testing.T) {
+	originalArgs := os.Args
+	os.Args = []string{"app", "-token=hunter2", "-v"}
+	defer func() { os.Args = originalArgs }()
+
+	for _, compressionType := range []CompressionType{NoCompression, ZstdCompression} {
+		path := filepath.Join(t.TempDir(), "chronogo.events")
+		for i := 0; i < 2; i++ {
+			// The second recorder appends to the recording, without a second header
+			rec, err := NewFileRecorderWithOptions(path, FileRecorderOptions{CompressionType: compressionType})
+			if err != nil {
+				t.Fatalf("Failed to create recorder: %v", err)
+			}
+			if err := rec.RecordEvent(Event{ID: int64(i + 1), Timestamp: time.Now(), Type: FuncEntry}); err != nil {
+				t.Fatalf("Failed to record event: %v", err)
+			}
+			if err := rec.Close(); err != nil {
+				t.Fatalf("Failed to close recorder: %v", err)
+			}
+		}
+
+		md, err := ReadMetadata(path)
+		if err != nil || md == nil {
+			t.Fatalf("Failed to read metadata: %v", err)
+		}
+		if md.GoVersion != runtime.Version() || md.GOOS != runtime.GOOS || md.GOARCH != runtime.GOARCH {
+			t.Errorf("Unexpected build metadata: %+v", md)
+		}
+		if md.StartTime.IsZero() {
+			t.Error("Expected the recording start time")
+		}
+		if got := strings.Join(md.Args, " "); got != "app -token=***REDACTED*** -v" {
+			t.Errorf("Expected redacted arguments, got %q", got)
+		}
+
+		events, err := ReadEventsFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read events: %v", err)
+		}
+		if len(events) != 2 || events[0].ID != 1 || events[1].ID != 2 {
+			t.Errorf("Expected the 2 recorded events without the metadata, got %+v", events)
+		}
+	}
+}
+
+func TestMetadataOptions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chronogo.events")
+	rec, err := NewFileRecorderWithOptions(path, FileRecorderOptions{
+		CompressionType: NoCompression,
+		Metadata:        MetadataOptions{OmitHostname: true, OmitArgs: true, DelveVersion: "1.23.1"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create recorder: %v", err)
+	}
+	rec.RecordEvent(Event{ID: 1, Timestamp: time.Now(), Type: FuncEntry})
+	rec.Close()
+
+	md, err := ReadMetadata(path)
+	if err != nil || md == nil {
+		t.Fatalf("Failed to read metadata: %v", err)
+	}
+	if md.Hostname != "" || md.Args != nil {
+		t.Errorf("Expected no hostname or arguments, got %q and %q", md.Hostname, md.Args)
+	}
+	if md.GoVersion == "" {
+		t.Error("Expected the Go version to be kept")
+	}
+	if md.DelveVersion != "1.23.1" || !strings.Contains(md.String(), "Delve:      1.23.1") {
+		t.Errorf("Expected the Delve version, got %q", md.DelveVersion)
+	}
+}
+
+func TestMetadataHeaderless(t *testing.T) {
+	// Written without a recorder, like recordings made before metadata
+	path := filepath.Join(t.TempDir(), "old.events")
+	if err := WriteEventsFile(path, loopRecording(), FileRecorderOptions{CompressionType: NoCompression}); err != nil {
+		t.Fatal(err)
+	}
+	md, err := ReadMetadata(path)
+	if err != nil || md != nil {
+		t.Errorf("Expected no metadata and no error, got %v, %v", md, err)
+	}
+}
+
+func TestSecureMetadata(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	newKey := []byte("fedcba9876543210fedcba9876543210")
+	path := filepath.Join(t.TempDir(), "secure.events")
+	recordSecure(t, path, keyOptions(key), NoCompression, []Event{
+		{ID: 1, Timestamp: time.Now(), Type: FuncEntry, Details: "a"},
+		{ID: 2, Timestamp: time.Now(), Type: FuncExit, Details: "b"},
+	})
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), runtime.Version()) {
+		t.Error("Expected the metadata to be encrypted")
+	}
+	if _, err := ReadMetadata(path); !errors.Is(err, ErrSealedMetadata) {
+		t.Errorf("Expected ErrSealedMetadata without a key, got %v", err)
+	}
+	md, err := ReadSecureMetadata(path, keyOptions(key))
+	if err != nil || md == nil || md.GoVersion != runtime.Version() {
+		t.Fatalf("Failed to read sealed metadata: %v, %v", md, err)
+	}
+
+	events, err := ReadSecureEventsFile(path, keyOptions(key))
+	if err != nil || len(events) != 2 {
+		t.Errorf("Expected 2 events without the metadata, got %d: %v", len(events), err)
+	}
+	rec, err := NewSecureFileRecorderWithOptions(path, SecureFileRecorderOptions{SecurityOptions: keyOptions(key)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tampered, err := rec.DetectTampering(); tampered || err != nil {
+		t.Errorf("Expected no tampering, got %v, %v", tampered, err)
+	}
+	rec.Close()
+
+	// Rekeying reseals the metadata too
+	if _, err := RekeyEventsFile(path, keyOptions(newKey, key)); err != nil {
+		t.Fatalf("Rekey failed: %v", err)
+	}
+	if md, err := ReadSecureMetadata(path, keyOptions(newKey)); err != nil || md == nil {
+		t.Errorf("Expected the metadata to open with the new key, got %v, %v", md, err)
+	}
+}
@@ -0,0 +1,164 @@
+package recorder
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Environment variables through which a recorded program tells a child
+// process it starts where to write its own recording, and which
+// ProcessSpawnEvent of the parent's recording started it
+const (
+	EventsFileEnv = "CHRONOGO_EVENTS_FILE"
+	ParentIDEnv   = "CHRONOGO_PARENT_ID"
+)
+
+// ProcessSpawn describes a child process started by a recorded program, as
+// carried in the Details of a ProcessSpawnEvent
+type ProcessSpawn struct {
+	PID        int
+	Command    string // Command line, redacted
+	EventsFile string // Recording the child was asked to write, empty if none
+}
+
+// Details formats the spawn for a ProcessSpawnEvent, e.g.
+// `Spawned pid 4242: "worker --id 3", recording to /tmp/worker.events`
+func (p ProcessSpawn) Details() string {
+	details := fmt.Sprintf("Spawned pid %d: %q", p.PID, p.Command)
+	if p.EventsFile != "" {
+		details += ", recording to " + p.EventsFile
+	}
+	return details
+}
+
+// ParseProcessSpawn reads the Details of a ProcessSpawnEvent
+func ParseProcessSpawn(details string) (ProcessSpawn, bool) {
+	rest, ok := strings.CutPrefix(details, "Spawned pid ")
+	if !ok {
+		return ProcessSpawn{}, false
+	}
+	pid, rest, ok := strings.Cut(rest, ": ")
+	if !ok {
+		return ProcessSpawn{}, false
+	}
+	var p ProcessSpawn
+	var err error
+	if p.PID, err = strconv.Atoi(pid); err != nil {
+		return ProcessSpawn{}, false
+	}
+	quoted, err := strconv.QuotedPrefix(rest)
+	if err != nil {
+		return ProcessSpawn{}, false
+	}
+	p.Command, _ = strconv.Unquote(quoted)
+	p.EventsFile, _ = strings.CutPrefix(rest[len(quoted):], ", recording to ")
+	return p, true
+}
+
+// ChildRecording returns the path of the recording a spawn links to: the
+// path the child was given if that file exists, or else the file of the
+// same name in dir, where recordings copied together end up. It returns ""
+// if neither exists.
+func ChildRecording(spawn ProcessSpawn, dir string) string {
+	if spawn.EventsFile == "" {
+		return ""
+	}
+	if _, err := os.Stat(spawn.EventsFile); err == nil {
+		return spawn.EventsFile
+	}
+	path := filepath.Join(dir, filepath.Base(spawn.EventsFile))
+	if _, err := os.Stat(path); err == nil {
+		return path
+	}
+	return ""
+}
+
+// ReadProcessTree reads the recording at path with the recordings of the
+// child processes it started stitched in, as StitchChildRecordings does
+func ReadProcessTree(path string) ([]Event, error) {
+	events, err := ReadEventsFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return StitchChildRecordings(events, filepath.Dir(path))
+}
+
+// StitchChildRecordings merges into events the recordings of the child
+// processes their ProcessSpawnEvents link to, and those of the children's
+// children. Child events are placed by timestamp, while events keep their
+// order. Child events are tagged pid:<pid>, so they can be told apart and
+// filtered. A child whose recording can't be found, as happens when it
+// wasn't instrumented, or whose metadata names another spawn event, is
+// skipped with a warning. Recordings are looked up as ChildRecording does,
+// in dir.
+func StitchChildRecordings(events []Event, dir string) ([]Event, error) {
+	merged, _, err := stitchChildren(events, dir, map[string]bool{})
+	return merged, err
+}
+
+// stitchChildren stitches in the children of events, skipping recordings
+// already in seen. It also returns the index in the merged events of each
+// of events.
+func stitchChildren(events []Event, dir string, seen map[string]bool) ([]Event, []int, error) {
+	var stitched []Event
+	for _, e := range events {
+		if e.Type != ProcessSpawnEvent {
+			continue
+		}
+		spawn, ok := ParseProcessSpawn(e.Details)
+		if !ok || spawn.EventsFile == "" {
+			continue
+		}
+		path := ChildRecording(spawn, dir)
+		if path == "" {
+			fmt.Printf("Warning: No recording found for child pid %d at %s\n", spawn.PID, spawn.EventsFile)
+			continue
+		}
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+
+		if md, err := ReadMetadata(path); err == nil && md != nil && md.ParentID != 0 && md.ParentID != e.ID {
+			fmt.Printf("Warning: Recording %s belongs to spawn event %d, not %d\n", path, md.ParentID, e.ID)
+			continue
+		}
+		children, err := ReadEventsFile(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error reading recording of child pid %d: %v", spawn.PID, err)
+		}
+		if children, _, err = stitchChildren(children, filepath.Dir(path), seen); err != nil {
+			return nil, nil, err
+		}
+		tag := fmt.Sprintf("pid:%d", spawn.PID)
+		for _, c := range children {
+			c.Tags = append(append([]string(nil), c.Tags...), tag)
+			stitched = append(stitched, c)
+		}
+	}
+	if len(stitched) == 0 {
+		return events, nil, nil
+	}
+
+	// Each child event goes before the first event recorded after it
+	StableSort(stitched)
+	merged := make([]Event, 0, len(events)+len(stitched))
+	positions := make([]int, len(events))
+	j := 0
+	for i, e := range events {
+		for j < len(stitched) && stitched[j].Timestamp.Before(e.Timestamp) {
+			merged = append(merged, stitched[j])
+			j++
+		}
+		positions[i] = len(merged)
+		merged = append(merged, e)
+	}
+	merged = append(merged, stitched[j:]...)
+	return merged, positions, nil
+}
@@ -0,0 +1,116 @@
+package recorder
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestParseProcessSpawn(t *testing.T) {
+	for _, spawn := range []ProcessSpawn{
+		{PID: 4242, Command: `worker --name "a, b"`, EventsFile: "/tmp/worker.events"},
+		{PID: 7, Command: "ls"},
+	} {
+		got, ok := ParseProcessSpawn(spawn.Details())
+		if !ok || got != spawn {
+			t.Errorf("ParseProcessSpawn(%q) = %+v, %v, want %+v", spawn.Details(), got, ok, spawn)
+		}
+	}
+	if _, ok := ParseProcessSpawn("Spawned a worker"); ok {
+		t.Error("Parsed details that don't describe a spawn")
+	}
+}
+
+// writeProcessFixture writes a parent recording spawning a child into dir,
+// and the child's recording, naming the spawn event parentID in its
+// metadata. The child's events fall between the parent's.
+func writeProcessFixture(t *testing.T, dir string, parentID int64) (parent, child string) {
+	t.Helper()
+	start := time.Now().UTC()
+	parent = filepath.Join(dir, "chronogo.events")
+	child = filepath.Join(dir, "chronogo.worker.events")
+	spawn := ProcessSpawn{PID: 4242, Command: "worker", EventsFile: "/elsewhere/chronogo.worker.events"}
+	parentEvents := []Event{
+		{ID: 1, Timestamp: start, Type: FuncEntry, FuncName: "main.main"},
+		{ID: 2, Timestamp: start.Add(time.Millisecond), Type: ProcessSpawnEvent, Details: spawn.Details(), FuncName: "main.main"},
+		{ID: 3, Timestamp: start.Add(10 * time.Millisecond), Type: FuncExit, FuncName: "main.main"},
+	}
+	if err := WriteEventsFile(parent, parentEvents, DefaultFileRecorderOptions()); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(ParentIDEnv, strconv.FormatInt(parentID, 10))
+	rec, err := NewFileRecorder(child)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, name := range []string{"worker.main", "worker.run"} {
+		e := Event{ID: int64(i + 1), Timestamp: start.Add(time.Duration(2+i) * time.Millisecond), Type: FuncEntry, FuncName: name}
+		if err := rec.RecordEvent(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return parent, child
+}
+
+func TestReadProcessTree(t *testing.T) {
+	parent, _ := writeProcessFixture(t, t.TempDir(), 2)
+
+	events, err := ReadProcessTree(parent)
+	if err != nil {
+		t.Fatalf("ReadProcessTree failed: %v", err)
+	}
+	want := []string{"main.main", "main.main", "worker.main", "worker.run", "main.main"}
+	if len(events) != len(want) {
+		t.Fatalf("Expected %d events, got %d: %+v", len(want), len(events), events)
+	}
+	for i, e := range events {
+		if e.FuncName != want[i] {
+			t.Errorf("Event %d is in %s, want %s", i, e.FuncName, want[i])
+		}
+		if child := e.FuncName != "main.main"; e.HasTag("pid:4242") != child {
+			t.Errorf("Event %d in %s has tags %v", i, e.FuncName, e.Tags)
+		}
+	}
+}
+
+func TestReadProcessTreeSkipsOtherParents(t *testing.T) {
+	parent, _ := writeProcessFixture(t, t.TempDir(), 99)
+
+	events, err := ReadProcessTree(parent)
+	if err != nil {
+		t.Fatalf("ReadProcessTree failed: %v", err)
+	}
+	if len(events) != 3 {
+		t.Errorf("Expected only the parent's 3 events, got %+v", events)
+	}
+}
+
+func TestReadSegmentDirStitchesChildren(t *testing.T) {
+	dir := t.TempDir()
+	writeProcessFixture(t, dir, 2)
+
+	events, segments, err := ReadSegmentDir(dir)
+	if err != nil {
+		t.Fatalf("ReadSegmentDir failed: %v", err)
+	}
+	if len(events) != 5 || events[2].FuncName != "worker.main" {
+		t.Errorf("Expected the child stitched into the parent, got %+v", events)
+	}
+	if len(segments) != 1 || filepath.Base(segments[0].Path) != "chronogo.events" || segments[0].StartIdx != 0 {
+		t.Errorf("Expected the parent recording as the one segment, got %+v", segments)
+	}
+
+	// Two unrelated recordings can't be told apart
+	if err := os.WriteFile(filepath.Join(dir, "other.events"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ReadSegmentDir(dir); err == nil {
+		t.Error("Expected an error for a directory of unrelated recordings")
+	}
+}
@@ -1,7 +1,13 @@
 package recorder
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -262,3 +268,241 @@ func compressionTypeToString(ct CompressionType) string {
 		return "Unknown"
 	}
 }
+
+func TestIndexEventsFile(t *testing.T) {
+	base := time.Now()
+	events := []Event{
+		{ID: 1, Timestamp: base, Type: FuncEntry, Details: "Entering main"},
+		{ID: 2, Timestamp: base.Add(time.Millisecond), Type: StatementExecution, Details: `body {"user": "ann"}`},
+	}
+	path := filepath.Join(t.TempDir(), "events.log")
+	if err := WriteEventsFile(path, events, FileRecorderOptions{CompressionType: NoCompression}); err != nil {
+		t.Fatalf("Failed to write events: %v", err)
+	}
+
+	index, err := IndexEventsFile(path)
+	if err != nil {
+		t.Fatalf("Failed to index events: %v", err)
+	}
+	if len(index) != len(events) {
+		t.Fatalf("Expected %d offsets, got %d", len(events), len(index))
+	}
+	for i, off := range index {
+		if off.ID != events[i].ID || !off.Timestamp.Equal(events[i].Timestamp) {
+			t.Errorf("Offset %d points at event %d, expected %d", i, off.ID, events[i].ID)
+		}
+		line, err := ReadEventLine(path, off)
+		if err != nil {
+			t.Fatalf("Failed to read line %d: %v", i, err)
+		}
+		var event Event
+		if err := json.Unmarshal(line, &event); err != nil || event.Details != events[i].Details {
+			t.Errorf("Line %d is not the serialized event: %s", i, line)
+		}
+	}
+
+	compressed := filepath.Join(t.TempDir(), "events.zst")
+	if err := WriteEventsFile(compressed, events, FileRecorderOptions{CompressionType: ZstdCompression}); err != nil {
+		t.Fatalf("Failed to write events: %v", err)
+	}
+	if _, err := IndexEventsFile(compressed); err == nil {
+		t.Error("Expected an error indexing a compressed file")
+	}
+}
+
+func TestStableSort(t *testing.T) {
+	// Two goroutines recording in the same nanosecond; IDs are assigned in record order
+	ts := time.Now()
+	recorded := []Event{
+		{ID: 1, Timestamp: ts, Details: "g1 enter"},
+		{ID: 2, Timestamp: ts, Details: "g2 enter"},
+		{ID: 3, Timestamp: ts, Details: "g1 stmt"},
+		{ID: 4, Timestamp: ts, Details: "g2 stmt"},
+		{ID: 5, Timestamp: ts, Details: "g1 exit"},
+		{ID: 6, Timestamp: ts, Details: "g2 exit"},
+		{ID: 7, Timestamp: ts.Add(time.Nanosecond), Details: "g1 later"},
+	}
+
+	// Merge them out of order, as an async path might
+	events := []Event{recorded[6], recorded[4], recorded[1], recorded[0], recorded[5], recorded[3], recorded[2]}
+	StableSort(events)
+
+	for i, e := range events {
+		if e.ID != recorded[i].ID {
+			t.Fatalf("Event %d: expected ID %d, got %d (%s)", i, recorded[i].ID, e.ID, e.Details)
+		}
+	}
+
+	// Each goroutine's sub-sequence must keep its recorded order
+	expected := map[string][]string{
+		"g1": {"g1 enter", "g1 stmt", "g1 exit", "g1 later"},
+		"g2": {"g2 enter", "g2 stmt", "g2 exit"},
+	}
+	for g, want := range expected {
+		var got []string
+		for _, e := range events {
+			if e.Details[:2] == g {
+				got = append(got, e.Details)
+			}
+		}
+		if len(got) != len(want) {
+			t.Fatalf("Goroutine %s: expected %d events, got %d", g, len(want), len(got))
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("Goroutine %s event %d: expected %q, got %q", g, i, want[i], got[i])
+			}
+		}
+	}
+}
+
+// hammerRecorder records perGoroutine events from each of 16 goroutines at
+// once, reading the recorder back while they run, and returns how many
+// events were recorded
+func hammerRecorder(t *testing.T, r Recorder, perGoroutine int) int {
+	t.Helper()
+	originalInterval := SnapshotInterval
+	SnapshotInterval = 0
+	defer func() { SnapshotInterval = originalInterval }()
+
+	const goroutines = 16
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < perGoroutine; i++ {
+				err := r.RecordEvent(Event{
+					ID:        int64(g*perGoroutine + i),
+					Timestamp: time.Now(),
+					Type:      StatementExecution,
+					Details:   fmt.Sprintf("goroutine %d event %d %s", g, i, bytes.Repeat([]byte("x"), 200)),
+				})
+				if err != nil {
+					t.Errorf("RecordEvent failed: %v", err)
+					return
+				}
+				if i%50 == 0 {
+					r.GetEvents()
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	return goroutines * perGoroutine
+}
+
+func TestFileRecorderConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "concurrent.events")
+	rec, err := NewFileRecorderWithOptions(path, FileRecorderOptions{CompressionType: NoCompression})
+	if err != nil {
+		t.Fatalf("Failed to create file recorder: %v", err)
+	}
+	recorded := hammerRecorder(t, rec, 200)
+	if err := rec.Close(); err != nil {
+		t.Fatalf("Failed to close recorder: %v", err)
+	}
+
+	// Every line must be a whole event; interleaved writes would split them
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read events file: %v", err)
+	}
+	lines := bytes.Split(bytes.TrimSuffix(data, []byte("\n")), []byte("\n"))
+	if !isMetadataLine(lines[0]) {
+		t.Fatalf("Expected the file to start with its metadata, got %s", lines[0])
+	}
+	lines = lines[1:]
+	seen := make(map[int64]bool)
+	for i, line := range lines {
+		var event Event
+		if err := json.Unmarshal(line, &event); err != nil {
+			t.Fatalf("Line %d doesn't parse: %v", i+1, err)
+		}
+		seen[event.ID] = true
+	}
+	if len(lines) != recorded || len(seen) != recorded {
+		t.Errorf("Expected %d distinct events, got %d lines with %d IDs", recorded, len(lines), len(seen))
+	}
+}
+
+func TestFileRecorderRotation(t *testing.T) {
+	// Each file starts with the recording metadata
+	var header bytes.Buffer
+	if err := writeMetadataLine(&header, CollectMetadata(MetadataOptions{}), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.events")
+	rec, err := NewFileRecorderWithOptions(path, FileRecorderOptions{
+		CompressionType: NoCompression,
+		MaxFileSize:     int64(header.Len()) + 400,
+		MaxRotatedFiles: 3,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create file recorder: %v", err)
+	}
+	for id := int64(1); id <= 30; id++ {
+		if err := rec.RecordEvent(Event{ID: id, Timestamp: time.Now(), Type: StatementExecution, File: "main.go", Line: int(id), Details: "tick"}); err != nil {
+			t.Fatalf("Failed to record event %d: %v", id, err)
+		}
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatalf("Failed to close file recorder: %v", err)
+	}
+
+	rotated, err := ListRotatedFiles(path)
+	if err != nil {
+		t.Fatalf("Failed to list rotated files: %v", err)
+	}
+	if len(rotated) != 3 {
+		t.Fatalf("Expected the 3 newest rotated files to be kept, got %v", rotated)
+	}
+	if _, err := os.Stat(rotationPath(path, 1)); !os.IsNotExist(err) {
+		t.Errorf("Expected the oldest rotated file to be removed, got %v", err)
+	}
+	last, _ := rotationSeq(path, rotated[len(rotated)-1])
+
+	// Every file parses whole, and the files after the first start with a rotation marker
+	var ids []int64
+	for _, file := range append(rotated, path) {
+		events, err := ReadEventsFile(file)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		if len(events) < 2 {
+			t.Fatalf("Expected %s to hold a rotation marker and events, got %v", file, events)
+		}
+		if events[0].Type != RotationEvent || !strings.HasPrefix(events[0].Details, "Rotated ") {
+			t.Errorf("Expected %s to start with a rotation event, got %+v", file, events[0])
+		}
+		if !strings.Contains(events[0].Details, ", removed test.events.") {
+			t.Errorf("Expected the marker to name the removed file, got %q", events[0].Details)
+		}
+		for _, e := range events[1:] {
+			ids = append(ids, e.ID)
+		}
+	}
+	for i := 1; i < len(ids); i++ {
+		if ids[i] != ids[i-1]+1 {
+			t.Fatalf("Expected consecutive events across rotations, got %v", ids)
+		}
+	}
+	if ids[len(ids)-1] != 30 {
+		t.Errorf("Expected the last event to be 30, got %v", ids)
+	}
+
+	// A new recorder continues the numbering
+	rec, err = NewFileRecorderWithOptions(path, FileRecorderOptions{CompressionType: NoCompression, MaxFileSize: 1})
+	if err != nil {
+		t.Fatalf("Failed to reopen file recorder: %v", err)
+	}
+	if err := rec.RecordEvent(Event{ID: 31, Timestamp: time.Now(), Type: StatementExecution, File: "main.go"}); err != nil {
+		t.Fatalf("Failed to record event: %v", err)
+	}
+	rec.Close()
+	if _, err := os.Stat(rotationPath(path, last+1)); err != nil {
+		t.Errorf("Expected rotation to continue after the existing files: %v", err)
+	}
+}
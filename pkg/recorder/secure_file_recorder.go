@@ -2,14 +2,19 @@ package recorder
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"sync"
 )
 
-// SecureFileRecorder records events to a file with security features
+// SecureFileRecorder records events to a file with security features. It is
+// safe for concurrent use.
 type SecureFileRecorder struct {
+	mu              sync.Mutex // Guards the file, the writers and the event count
 	file            *os.File
 	writer          io.Writer
 	bufWriter       *bufio.Writer
@@ -17,12 +22,15 @@ type SecureFileRecorder struct {
 	securityOpts    SecurityOptions
 	compressionType CompressionType
 	eventCount      int
+	metadata        MetadataOptions
+	fresh           bool // Whether the file is empty, so the next event is preceded by the metadata
 }
 
 // SecureFileRecorderOptions contains options for creating a secure file recorder
 type SecureFileRecorderOptions struct {
 	SecurityOptions SecurityOptions
 	CompressionType CompressionType
+	Metadata        MetadataOptions // What goes into the sealed metadata written at the start of a new file
 }
 
 // DefaultSecureFileRecorderOptions returns default options for secure file recorder
@@ -40,10 +48,19 @@ func NewSecureFileRecorder(path string) (*SecureFileRecorder, error) {
 
 // NewSecureFileRecorderWithOptions creates a new secure file recorder with the given options
 func NewSecureFileRecorderWithOptions(path string, options SecureFileRecorderOptions) (*SecureFileRecorder, error) {
+	if err := options.SecurityOptions.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid security options: %v", err)
+	}
+
 	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
 	if err != nil {
 		return nil, err
 	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
 
 	bufWriter := bufio.NewWriter(f)
 	compressedWriter := NewCompressedWriter(bufWriter, options.CompressionType)
@@ -56,30 +73,31 @@ func NewSecureFileRecorderWithOptions(path string, options SecureFileRecorderOpt
 		securityOpts:    options.SecurityOptions,
 		compressionType: options.CompressionType,
 		eventCount:      0,
+		metadata:        options.Metadata,
+		fresh:           info.Size() == 0,
 	}, nil
 }
 
+// sink returns a SecureSink over the current compressed writer. Callers hold sfr.mu.
+func (sfr *SecureFileRecorder) sink() *SecureSink {
+	return NewSecureSink(sfr.writer, sfr.securityOpts)
+}
+
 // RecordEvent applies security features and writes an event to the file
 func (sfr *SecureFileRecorder) RecordEvent(e Event) error {
-	// Apply security features to the event
-	secureEvent, err := SecureEventFromEvent(e, sfr.securityOpts)
-	if err != nil {
-		return err
-	}
-
-	// Serialize the secure event
-	data, err := json.Marshal(secureEvent)
-	if err != nil {
-		return err
-	}
-
-	// Write the JSON data
-	if _, err := sfr.writer.Write(data); err != nil {
-		return err
+	sfr.mu.Lock()
+	defer sfr.mu.Unlock()
+
+	// A new recording starts with its metadata, sealed like the events
+	if sfr.fresh {
+		md := collectMetadata(sfr.metadata, sfr.securityOpts)
+		if err := writeMetadataLine(sfr.writer, md, &sfr.securityOpts); err != nil {
+			return fmt.Errorf("failed to write recording metadata: %v", err)
+		}
+		sfr.fresh = false
 	}
 
-	// Write a newline
-	if _, err := sfr.writer.Write([]byte{'\n'}); err != nil {
+	if err := sfr.sink().WriteEvent(e); err != nil {
 		return err
 	}
 
@@ -113,30 +131,28 @@ func (sfr *SecureFileRecorder) recordSnapshotEvent(snapshot Snapshot, eventIdx i
 		Details:   "Snapshot created",
 	}
 
-	// Apply security features to the snapshot event
-	secureEvent, err := SecureEventFromEvent(snapshotEvent, sfr.securityOpts)
-	if err != nil {
-		return err
-	}
-
-	data, err := json.Marshal(secureEvent)
-	if err != nil {
-		return err
-	}
-
-	// Write the snapshot event
-	if _, err := sfr.writer.Write(data); err != nil {
-		return err
-	}
-	if _, err := sfr.writer.Write([]byte{'\n'}); err != nil {
+	if err := sfr.sink().WriteEvent(snapshotEvent); err != nil {
 		return err
 	}
 
 	return sfr.bufWriter.Flush()
 }
 
-// GetEvents reads all events from the file, applying security features in reverse
+// GetEvents retrieves all events from the file, skipping events that can't
+// be decrypted, verified or parsed. Use GetEventsWithReport to find out
+// which those were.
 func (sfr *SecureFileRecorder) GetEvents() []Event {
+	events, _ := sfr.GetEventsWithReport()
+	return events
+}
+
+// GetEventsWithReport retrieves all events from the file along with a
+// report of the events skipped because they couldn't be decrypted, verified
+// or parsed. The report is nil if the file couldn't be read at all.
+func (sfr *SecureFileRecorder) GetEventsWithReport() ([]Event, *ReadReport) {
+	sfr.mu.Lock()
+	defer sfr.mu.Unlock()
+
 	// Ensure data is flushed to disk
 	if err := CloseCompressedWriter(sfr.writer, sfr.compressionType); err != nil {
 		// Log the error but continue - we still want to try reading events
@@ -144,46 +160,36 @@ func (sfr *SecureFileRecorder) GetEvents() []Event {
 	}
 	sfr.bufWriter.Flush()
 
+	// Reopen the writer since we closed it
+	defer func() {
+		sfr.writer = NewCompressedWriter(sfr.bufWriter, sfr.compressionType)
+	}()
+
 	// Open the file for reading
 	f, err := os.Open(sfr.path)
 	if err != nil {
-		return nil
+		return nil, nil
 	}
 	defer f.Close()
 
 	// Create a reader with decompression if needed
 	reader, err := NewCompressedReader(f, sfr.compressionType)
 	if err != nil {
-		return nil
+		return nil, nil
 	}
 
-	var events []Event
-	scanner := bufio.NewScanner(reader)
-	for scanner.Scan() {
-		// Parse the secure event
-		var secureEvent SecureEvent
-		if err := json.Unmarshal(scanner.Bytes(), &secureEvent); err != nil {
-			continue
-		}
-
-		// Extract the original event
-		event, err := secureEvent.GetOriginalEvent(sfr.securityOpts)
-		if err != nil {
-			// Skip events that can't be decrypted or verified
-			continue
-		}
-
-		events = append(events, event)
+	events, report, err := readSecureEvents(reader, sfr.securityOpts)
+	if err != nil {
+		fmt.Printf("Warning: Error reading events: %v\n", err)
 	}
-
-	// Reopen the writer since we closed it
-	sfr.writer = NewCompressedWriter(sfr.bufWriter, sfr.compressionType)
-
-	return events
+	return events, report
 }
 
 // Clear clears the file and resets the recorder
 func (sfr *SecureFileRecorder) Clear() {
+	sfr.mu.Lock()
+	defer sfr.mu.Unlock()
+
 	// Ignore errors in Clear() as per interface
 	if err := CloseCompressedWriter(sfr.writer, sfr.compressionType); err != nil {
 		fmt.Printf("Warning: Error closing compressed writer: %v\n", err)
@@ -201,11 +207,15 @@ func (sfr *SecureFileRecorder) Clear() {
 		sfr.bufWriter = bufio.NewWriter(f)
 		sfr.writer = NewCompressedWriter(sfr.bufWriter, sfr.compressionType)
 		sfr.eventCount = 0
+		sfr.fresh = true
 	}
 }
 
 // Close flushes and closes the file
 func (sfr *SecureFileRecorder) Close() error {
+	sfr.mu.Lock()
+	defer sfr.mu.Unlock()
+
 	// Close the compressed writer if needed
 	if err := CloseCompressedWriter(sfr.writer, sfr.compressionType); err != nil {
 		return err
@@ -220,6 +230,9 @@ func (sfr *SecureFileRecorder) Close() error {
 
 // DetectTampering checks the file for any signs of tampering
 func (sfr *SecureFileRecorder) DetectTampering() (bool, error) {
+	sfr.mu.Lock()
+	defer sfr.mu.Unlock()
+
 	// Open the file for reading
 	f, err := os.Open(sfr.path)
 	if err != nil {
@@ -238,48 +251,69 @@ func (sfr *SecureFileRecorder) DetectTampering() (bool, error) {
 		return false, nil
 	}
 
-	// Check each event
-	scanner := bufio.NewScanner(reader)
+	// Check each event. A line too long to read can't be verified, but the
+	// lines after it still are.
+	maxLineSize := DefaultDecoderOptions().MaxLineSize
+	lines := newLineReader(reader, maxLineSize)
 	lineNum := 0
-	for scanner.Scan() {
-		lineNum++
-		// Parse the secure event
-		var secureEvent SecureEvent
-		if err := json.Unmarshal(scanner.Bytes(), &secureEvent); err != nil {
-			return true, err // Corrupted JSON is considered tampering
+	unreadable := 0
+	for {
+		line, tooLong, readErr := lines.next()
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			return true, readErr // Error during reading is considered tampering
 		}
-
-		// Skip events without HMAC
-		if secureEvent.HMAC == "" {
-			continue
+		if readErr == nil || len(line) > 0 || tooLong {
+			lineNum++
 		}
-
-		// If encrypted, verify HMAC of the encrypted data
-		if secureEvent.Encrypted {
-			encryptedData, err := json.Marshal(secureEvent.Event)
-			if err != nil {
-				return true, err
+		line = bytes.TrimSpace(line)
+		if tooLong {
+			if unreadable == 0 {
+				unreadable = lineNum
 			}
-
-			if !VerifyHMAC(encryptedData, sfr.securityOpts.IntegrityKey, secureEvent.HMAC) {
-				return true, nil // Tampering detected
-			}
-		} else {
-			// Verify HMAC of the event data
-			eventData, err := json.Marshal(secureEvent.Event)
-			if err != nil {
+		} else if len(line) > 0 {
+			tampered, err := sfr.lineTampered(line)
+			if tampered || err != nil {
 				return true, err
 			}
+		}
+		if readErr != nil {
+			break
+		}
+	}
 
-			if !VerifyHMAC(eventData, sfr.securityOpts.IntegrityKey, secureEvent.HMAC) {
-				return true, nil // Tampering detected
-			}
+	if unreadable > 0 {
+		return true, fmt.Errorf("line %d exceeds %d bytes and can't be verified", unreadable, maxLineSize)
+	}
+	return false, nil // No tampering detected
+}
+
+// lineTampered reports whether the metadata or secure event on a line
+// fails its integrity check. Corrupted JSON is considered tampering.
+func (sfr *SecureFileRecorder) lineTampered(line []byte) (bool, error) {
+	if isMetadataLine(line) {
+		var record metadataRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return true, err
 		}
+		return record.Sealed != nil && record.Sealed.HMAC != "" &&
+			!verifyIntegrity(record.Sealed.Data, record.Sealed.HMAC, sfr.securityOpts), nil
 	}
 
-	if scanner.Err() != nil {
-		return true, scanner.Err() // Error during scanning is considered tampering
+	// Parse the secure event
+	var secureEvent SecureEvent
+	if err := json.Unmarshal(line, &secureEvent); err != nil {
+		return true, err
 	}
 
-	return false, nil // No tampering detected
+	// Skip events without HMAC
+	if secureEvent.HMAC == "" {
+		return false, nil
+	}
+
+	// The HMAC covers the event as stored, encrypted or not
+	data, err := json.Marshal(secureEvent.Event)
+	if err != nil {
+		return true, err
+	}
+	return !verifyIntegrity(data, secureEvent.HMAC, sfr.securityOpts), nil
 }
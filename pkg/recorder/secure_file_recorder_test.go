@@ -3,6 +3,7 @@ package recorder
 import (
 	"bytes"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 )
@@ -235,3 +236,26 @@ func TestSecureFileRecorderWithVariousOptions(t *testing.T) {
 		})
 	}
 }
+
+func TestSecureFileRecorderConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "concurrent.secure")
+	opts := DefaultSecureFileRecorderOptions()
+	opts.CompressionType = NoCompression
+	opts.SecurityOptions.EnableIntegrityCheck = true
+	opts.SecurityOptions.IntegrityKey = []byte("concurrent-test-integrity-key-32")
+	rec, err := NewSecureFileRecorderWithOptions(path, opts)
+	if err != nil {
+		t.Fatalf("Failed to create secure recorder: %v", err)
+	}
+	recorded := hammerRecorder(t, rec, 100)
+
+	if tampered, err := rec.DetectTampering(); tampered || err != nil {
+		t.Errorf("Expected an intact file, got tampered %v, %v", tampered, err)
+	}
+	if events := rec.GetEvents(); len(events) != recorded {
+		t.Errorf("Expected %d events back, got %d", recorded, len(events))
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatalf("Failed to close recorder: %v", err)
+	}
+}
@@ -0,0 +1,462 @@
+package recorder
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// SecureBlob is an arbitrary payload (snapshot state, session file) sealed
+// with the same security options as the events it belongs to
+type SecureBlob struct {
+	Data      []byte `json:"data"`             // Payload, encrypted if Encrypted is set
+	Encrypted bool   `json:"encrypted"`        // Whether Data is encrypted
+	KeyID     string `json:"key_id,omitempty"` // ID of the encryption key, see KeyID
+	HMAC      string `json:"hmac"`             // HMAC of Data for integrity verification
+}
+
+// SecureSink applies one set of security options to everything written to
+// an output stream. Events and blobs are written as one JSON line each, so
+// every stream a recording produces is protected with the same keys.
+type SecureSink struct {
+	w    io.Writer
+	opts SecurityOptions
+}
+
+// NewSecureSink creates a sink that seals records written to w
+func NewSecureSink(w io.Writer, opts SecurityOptions) *SecureSink {
+	return &SecureSink{w: w, opts: opts}
+}
+
+// WriteEvent applies redaction, encryption and integrity checks to an event and writes it
+func (s *SecureSink) WriteEvent(e Event) error {
+	secureEvent, err := SecureEventFromEvent(e, s.opts)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(secureEvent)
+	if err != nil {
+		return err
+	}
+	return s.writeLine(data)
+}
+
+// WriteBlob encrypts and signs an arbitrary payload and writes it
+func (s *SecureSink) WriteBlob(data []byte) error {
+	blob, err := SealBlob(data, s.opts)
+	if err != nil {
+		return err
+	}
+
+	line, err := json.Marshal(blob)
+	if err != nil {
+		return err
+	}
+	return s.writeLine(line)
+}
+
+// writeLine writes data followed by a newline
+func (s *SecureSink) writeLine(data []byte) error {
+	if _, err := s.w.Write(data); err != nil {
+		return err
+	}
+	_, err := s.w.Write([]byte{'\n'})
+	return err
+}
+
+// resealMetadata writes a metadata record sealed with opts, opening it with
+// any of the keys in opts
+func resealMetadata(w io.Writer, line []byte, opts SecurityOptions) error {
+	md, err := readMetadata(bytes.NewReader(line), &opts)
+	if err != nil {
+		return err
+	}
+	return writeMetadataLine(w, *md, &opts)
+}
+
+// SealBlob encrypts and signs data according to opts
+func SealBlob(data []byte, opts SecurityOptions) (SecureBlob, error) {
+	blob := SecureBlob{Data: data}
+
+	if opts.EnableEncryption {
+		encrypted, err := EncryptData(data, opts.EncryptionKey)
+		if err != nil {
+			return blob, err
+		}
+		blob.Data = encrypted
+		blob.Encrypted = true
+		blob.KeyID = KeyID(opts.EncryptionKey)
+	}
+
+	if opts.EnableIntegrityCheck {
+		blob.HMAC = CalculateHMAC(blob.Data, opts.IntegrityKey)
+	}
+
+	return blob, nil
+}
+
+// Open verifies and decrypts the blob, returning the original payload
+func (b SecureBlob) Open(opts SecurityOptions) ([]byte, error) {
+	if opts.EnableIntegrityCheck && b.HMAC != "" {
+		if !verifyIntegrity(b.Data, b.HMAC, opts) {
+			return nil, errors.New("HMAC verification failed: data may have been tampered with")
+		}
+	}
+
+	if !b.Encrypted {
+		return b.Data, nil
+	}
+	return decryptWithKeys(b.Data, b.KeyID, opts)
+}
+
+// WriteSecureFile writes data to path as a single sealed blob
+func WriteSecureFile(path string, data []byte, opts SecurityOptions, perm os.FileMode) error {
+	var buf bytes.Buffer
+	if err := NewSecureSink(&buf, opts).WriteBlob(data); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), perm)
+}
+
+// ReadSecureFile reads a file written by WriteSecureFile and returns the original data
+func ReadSecureFile(path string, opts SecurityOptions) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var blob SecureBlob
+	if err := json.Unmarshal(data, &blob); err != nil {
+		return nil, fmt.Errorf("failed to parse secure file %s: %v", path, err)
+	}
+	return blob.Open(opts)
+}
+
+// ReadSecureEventsFile reads all events from a file written by a
+// SecureFileRecorder, decrypting and verifying them with opts. Compression is
+// detected automatically. Events that can't be read are skipped with a
+// warning summarizing them.
+func ReadSecureEventsFile(path string, opts SecurityOptions) ([]Event, error) {
+	events, report, err := ReadSecureEventsFileWithReport(path, opts)
+	if report != nil && report.Dropped() > 0 {
+		fmt.Printf("Warning: %s\n", report)
+	}
+	return events, err
+}
+
+// ReadSecureEventsFileWithReport reads a secure events file like
+// ReadSecureEventsFile, returning a report of the events it skipped instead
+// of printing warnings
+func ReadSecureEventsFileWithReport(path string, opts SecurityOptions) ([]Event, *ReadReport, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("invalid security options: %v", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error opening events file: %v", err)
+	}
+	defer f.Close()
+
+	// Peek at the header to detect compression
+	buffered := bufio.NewReader(f)
+	compressionType := detectCompression(buffered)
+
+	reader, err := NewCompressedReader(buffered, compressionType)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	events, report, err := readSecureEvents(reader, opts)
+	if err != nil {
+		return events, report, fmt.Errorf("error reading events file: %v", err)
+	}
+	return events, report, nil
+}
+
+// readSecureEvents opens the secure events read from r, reporting the
+// events it can't parse, decrypt or verify
+func readSecureEvents(r io.Reader, opts SecurityOptions) ([]Event, *ReadReport, error) {
+	var events []Event
+	report, err := scanSecureEvents(r, opts, DefaultDecoderOptions(), func(e Event) error {
+		events = append(events, e)
+		return nil
+	})
+	return events, report, err
+}
+
+// scanSecureEvents calls fn for each secure event read from r that can be
+// opened, stopping at the first error fn returns. Like EventDecoder, it
+// skips lines over limits.MaxLineSize and lines it can't read, reporting
+// them, instead of giving up on the rest of the recording, and stops with
+// ErrTooManyEvents past limits.MaxEvents. Registered event types declared
+// in the sealed metadata are mapped like EventDecoder maps them.
+func scanSecureEvents(r io.Reader, opts SecurityOptions, limits DecoderOptions, fn func(Event) error) (*ReadReport, error) {
+	if limits.MaxLineSize <= 0 {
+		limits.MaxLineSize = DefaultDecoderOptions().MaxLineSize
+	}
+	report := &ReadReport{}
+	types := make(typeMapping)
+	lines := newLineReader(r, limits.MaxLineSize)
+	lineNum := 0
+	delivered := 0
+	for {
+		line, tooLong, readErr := lines.next()
+		if readErr == nil || len(line) > 0 || tooLong {
+			lineNum++
+		}
+		line = bytes.TrimSpace(line)
+		switch {
+		case tooLong:
+			report.Events++
+			report.drop(&report.Unparseable, lineNum, fmt.Errorf("line exceeds %d bytes", limits.MaxLineSize))
+		case len(line) == 0:
+		case isMetadataLine(line):
+			if md, err := readMetadata(bytes.NewReader(line), &opts); err == nil && md != nil {
+				types.declare(md.EventTypes)
+			}
+		default:
+			report.Events++
+			event, ok := openSecureLine(line, lineNum, opts, report)
+			if !ok {
+				break
+			}
+			if limits.MaxEvents > 0 && delivered >= limits.MaxEvents {
+				return report, ErrTooManyEvents
+			}
+			delivered++
+			if t, ok := types[event.Type]; ok {
+				event.Type = t
+			}
+			if err := fn(event); err != nil {
+				return report, err
+			}
+		}
+
+		if errors.Is(readErr, io.EOF) {
+			return report, nil
+		}
+		if readErr != nil {
+			return report, readErr
+		}
+	}
+}
+
+// openSecureLine parses, verifies and decrypts the secure event on a line,
+// recording in report why it couldn't if it can't
+func openSecureLine(line []byte, lineNum int, opts SecurityOptions, report *ReadReport) (Event, bool) {
+	var secureEvent SecureEvent
+	if err := json.Unmarshal(line, &secureEvent); err != nil {
+		report.drop(&report.Unparseable, lineNum, err)
+		return Event{}, false
+	}
+
+	event, err := secureEvent.GetOriginalEvent(opts)
+	if errors.Is(err, ErrIntegrityCheckFailed) {
+		report.drop(&report.Unverifiable, lineNum, err)
+		return Event{}, false
+	}
+	if err != nil {
+		report.drop(&report.Undecryptable, lineNum, err)
+		return Event{}, false
+	}
+	return event, true
+}
+
+// ReadReport accounts for the events of a secure recording that were
+// skipped because they couldn't be read
+type ReadReport struct {
+	Events        int   // Event lines read, including skipped ones
+	Undecryptable []int // Lines of events that couldn't be decrypted, e.g. with the wrong key
+	Unverifiable  []int // Lines of events whose HMAC didn't match
+	Unparseable   []int // Lines that aren't secure events, e.g. in a corrupted region
+	FirstErr      error // Error that caused the first event to be skipped
+}
+
+// drop records that the event on line was skipped because of err
+func (r *ReadReport) drop(lines *[]int, line int, err error) {
+	*lines = append(*lines, line)
+	if r.FirstErr == nil {
+		r.FirstErr = fmt.Errorf("line %d: %w", line, err)
+	}
+}
+
+// Dropped returns the number of events skipped
+func (r *ReadReport) Dropped() int {
+	return len(r.Undecryptable) + len(r.Unverifiable) + len(r.Unparseable)
+}
+
+// DroppedFraction returns the fraction of events skipped, 0 if there were none
+func (r *ReadReport) DroppedFraction() float64 {
+	if r.Events == 0 {
+		return 0
+	}
+	return float64(r.Dropped()) / float64(r.Events)
+}
+
+// String summarizes the skipped events, e.g. "skipped 2 of 10 events:
+// 2 undecryptable (lines 4, 5); first error: line 4: cipher: message
+// authentication failed"
+func (r *ReadReport) String() string {
+	if r.Dropped() == 0 {
+		return fmt.Sprintf("read all %d events", r.Events)
+	}
+	var kinds []string
+	for _, kind := range []struct {
+		name  string
+		lines []int
+	}{
+		{"undecryptable", r.Undecryptable},
+		{"unverifiable", r.Unverifiable},
+		{"unparseable", r.Unparseable},
+	} {
+		if len(kind.lines) > 0 {
+			kinds = append(kinds, fmt.Sprintf("%d %s (lines %s)", len(kind.lines), kind.name, formatLines(kind.lines)))
+		}
+	}
+	return fmt.Sprintf("skipped %d of %d events: %s; first error: %v",
+		r.Dropped(), r.Events, strings.Join(kinds, ", "), r.FirstErr)
+}
+
+// formatLines lists line numbers, eliding all but the first few
+func formatLines(lines []int) string {
+	const shown = 5
+	parts := make([]string, 0, shown+1)
+	for i, line := range lines {
+		if i == shown {
+			parts = append(parts, fmt.Sprintf("and %d more", len(lines)-shown))
+			break
+		}
+		parts = append(parts, strconv.Itoa(line))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// RekeyEventsFile re-seals every event in a secure events file that isn't
+// already encrypted with opts.EncryptionKey. Events are opened with the
+// encryption key or any of opts.DecryptionKeys, so files holding a mix of
+// keys are handled. The file is rewritten one event at a time into a
+// temporary file that replaces the original only once every event has been
+// re-sealed, so an interrupted rekey leaves the original intact. It returns
+// the number of events that were re-sealed.
+func RekeyEventsFile(path string, opts SecurityOptions) (int, error) {
+	if err := opts.Validate(); err != nil {
+		return 0, fmt.Errorf("invalid security options: %v", err)
+	}
+
+	in, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("error opening events file: %v", err)
+	}
+	defer in.Close()
+
+	// Keep the compression of the original file
+	buffered := bufio.NewReader(in)
+	compressionType := detectCompression(buffered)
+	reader, err := NewCompressedReader(buffered, compressionType)
+	if err != nil {
+		return 0, err
+	}
+
+	tmpPath := path + ".rekey"
+	out, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return 0, fmt.Errorf("error creating rekeyed file: %v", err)
+	}
+	defer os.Remove(tmpPath) // No-op once renamed
+	defer out.Close()
+
+	bufWriter := bufio.NewWriter(out)
+	writer := NewCompressedWriter(bufWriter, compressionType)
+	sink := NewSecureSink(writer, opts)
+	currentKeyID := KeyID(opts.EncryptionKey)
+
+	// reseal re-seals the event on a line, reporting whether it needed to
+	reseal := func(line []byte, lineNum int) (bool, error) {
+		if isMetadataLine(line) {
+			if err := resealMetadata(sink.w, line, opts); err != nil {
+				return false, fmt.Errorf("could not reseal metadata on line %d: %v", lineNum, err)
+			}
+			return false, nil
+		}
+
+		var secureEvent SecureEvent
+		if err := json.Unmarshal(line, &secureEvent); err != nil {
+			return false, fmt.Errorf("could not parse event on line %d: %v", lineNum, err)
+		}
+
+		// Events already sealed with the current key are copied as they are
+		if secureEvent.Encrypted && secureEvent.KeyID == currentKeyID {
+			return false, sink.writeLine(line)
+		}
+
+		event, err := secureEvent.GetOriginalEvent(opts)
+		if err != nil {
+			return false, fmt.Errorf("could not open event on line %d: %v", lineNum, err)
+		}
+		resealed, err := SecureEventFromEvent(event, opts)
+		if err != nil {
+			return false, err
+		}
+		resealed.IsRedacted = resealed.IsRedacted || secureEvent.IsRedacted
+
+		data, err := json.Marshal(resealed)
+		if err != nil {
+			return false, err
+		}
+		return true, sink.writeLine(data)
+	}
+
+	// Every event must be carried over, so unlike reading, a line that
+	// can't be read fails the rekey
+	maxLineSize := DefaultDecoderOptions().MaxLineSize
+	lines := newLineReader(reader, maxLineSize)
+	rekeyed := 0
+	lineNum := 0
+	for {
+		line, tooLong, readErr := lines.next()
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			return rekeyed, fmt.Errorf("error reading events file: %v", readErr)
+		}
+		if readErr == nil || len(line) > 0 || tooLong {
+			lineNum++
+		}
+		if tooLong {
+			return rekeyed, fmt.Errorf("event on line %d exceeds %d bytes", lineNum, maxLineSize)
+		}
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			resealed, err := reseal(line, lineNum)
+			if err != nil {
+				return rekeyed, err
+			}
+			if resealed {
+				rekeyed++
+			}
+		}
+		if readErr != nil {
+			break
+		}
+	}
+
+	if err := CloseCompressedWriter(writer, compressionType); err != nil {
+		return rekeyed, err
+	}
+	if err := bufWriter.Flush(); err != nil {
+		return rekeyed, err
+	}
+	if err := out.Close(); err != nil {
+		return rekeyed, err
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return rekeyed, fmt.Errorf("error replacing events file: %v", err)
+	}
+	return rekeyed, nil
+}
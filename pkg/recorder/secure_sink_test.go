@@ -0,0 +1,256 @@
+package recorder
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSecureSinkNoPlaintextOnDisk(t *testing.T) {
+	dir := t.TempDir()
+	secret := "s3cr3t-value-42"
+
+	opts := DefaultSecurityOptions()
+	WithEncryption([]byte("0123456789ABCDEF"))(&opts)
+	WithIntegrityCheck([]byte("integrity-test-key"))(&opts)
+
+	// Record events without compression so plaintext would be visible on disk
+	eventsPath := filepath.Join(dir, "secure.events")
+	rec, err := NewSecureFileRecorderWithOptions(eventsPath, SecureFileRecorderOptions{
+		SecurityOptions: opts,
+		CompressionType: NoCompression,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create secure recorder: %v", err)
+	}
+
+	originalInterval := SnapshotInterval
+	SnapshotInterval = 2
+	defer func() {
+		SnapshotInterval = originalInterval
+	}()
+
+	testEvents := []Event{
+		{ID: 1, Timestamp: time.Now(), Type: FuncEntry, Details: "Entering login", FuncName: "login"},
+		{ID: 2, Timestamp: time.Now(), Type: VarAssignment, Details: "apiKey = " + secret, FuncName: "login"},
+		{ID: 3, Timestamp: time.Now(), Type: FuncExit, Details: "Exiting login", FuncName: "login"},
+	}
+	for _, e := range testEvents {
+		if err := rec.RecordEvent(e); err != nil {
+			t.Fatalf("Failed to record event: %v", err)
+		}
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatalf("Failed to close recorder: %v", err)
+	}
+
+	// Snapshot state goes through the same options
+	snapshotPath := filepath.Join(dir, "snapshot-2.bin")
+	if err := WriteSecureFile(snapshotPath, []byte("apiKey="+secret), opts, 0600); err != nil {
+		t.Fatalf("Failed to write snapshot: %v", err)
+	}
+
+	// Nothing on disk may contain the secret
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if bytes.Contains(data, []byte(secret)) {
+			t.Errorf("%s contains plaintext secret", filepath.Base(path))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to scan output: %v", err)
+	}
+
+	// Reading back with the same options recovers everything
+	events, err := ReadSecureEventsFile(eventsPath, opts)
+	if err != nil {
+		t.Fatalf("Failed to read secure events: %v", err)
+	}
+	if len(events) != len(testEvents)+1 {
+		t.Fatalf("Expected %d events including snapshot marker, got %d", len(testEvents)+1, len(events))
+	}
+	if events[1].Details != testEvents[1].Details {
+		t.Errorf("Expected %q, got %q", testEvents[1].Details, events[1].Details)
+	}
+	if events[2].Type != SnapshotEvent {
+		t.Errorf("Expected snapshot marker after event 2, got %s", events[2].Type)
+	}
+
+	state, err := ReadSecureFile(snapshotPath, opts)
+	if err != nil {
+		t.Fatalf("Failed to read snapshot: %v", err)
+	}
+	if string(state) != "apiKey="+secret {
+		t.Errorf("Snapshot state mismatch: %q", state)
+	}
+}
+
+func TestSecureBlobTampering(t *testing.T) {
+	opts := DefaultSecurityOptions()
+	WithIntegrityCheck([]byte("integrity-test-key"))(&opts)
+
+	blob, err := SealBlob([]byte("index data"), opts)
+	if err != nil {
+		t.Fatalf("Failed to seal blob: %v", err)
+	}
+
+	if data, err := blob.Open(opts); err != nil || string(data) != "index data" {
+		t.Fatalf("Failed to open blob: %q, %v", data, err)
+	}
+
+	blob.Data = []byte("tampered data")
+	if _, err := blob.Open(opts); err == nil {
+		t.Errorf("Expected HMAC verification to fail on tampered blob")
+	}
+}
+
+func TestReadSecureEventsReport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secure.events")
+	keyA, keyB := []byte("0123456789ABCDEF"), []byte("FEDCBA9876543210")
+	var events []Event
+	for i := 1; i <= 3; i++ {
+		events = append(events, Event{ID: int64(i), Timestamp: time.Now(), Type: StatementExecution, Details: "statement"})
+	}
+	recordSecure(t, path, keyOptions(keyA), NoCompression, events)
+
+	// A corrupted line, then an event sealed with another key
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	garbageLine := bytes.Count(data, []byte("\n")) + 1
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("{not an event\n")
+	f.Close()
+	recordSecure(t, path, keyOptions(keyB), NoCompression, []Event{{ID: 4, Timestamp: time.Now(), Type: FuncExit}})
+
+	read, report, err := ReadSecureEventsFileWithReport(path, keyOptions(keyA))
+	if err != nil {
+		t.Fatalf("Failed to read events: %v", err)
+	}
+	if len(read) != 3 || report.Events != 5 || report.Dropped() != 2 {
+		t.Fatalf("Expected 3 of 5 events read, got %d: %s", len(read), report)
+	}
+	if len(report.Unparseable) != 1 || report.Unparseable[0] != garbageLine {
+		t.Errorf("Expected line %d to be unparseable, got %v", garbageLine, report.Unparseable)
+	}
+	if len(report.Unverifiable) != 1 || len(report.Undecryptable) != 0 {
+		t.Errorf("Expected the other key's event to fail verification, got %s", report)
+	}
+	if report.FirstErr == nil || !strings.Contains(report.String(), "skipped 2 of 5 events") {
+		t.Errorf("Unexpected summary: %s", report)
+	}
+
+	// The right integrity key with the wrong encryption key can't decrypt
+	opts := keyOptions(keyB)
+	WithIntegrityCheck(keyA)(&opts)
+	rec, err := NewSecureFileRecorderWithOptions(path, SecureFileRecorderOptions{SecurityOptions: opts, CompressionType: NoCompression})
+	if err != nil {
+		t.Fatalf("Failed to open recorder: %v", err)
+	}
+	defer rec.Close()
+	read, report = rec.GetEventsWithReport()
+	if len(read) != 0 || len(report.Undecryptable) != 3 || report.DroppedFraction() != 1 {
+		t.Errorf("Expected the events to be undecryptable, got %d events: %s", len(read), report)
+	}
+}
+
+func TestSecureOversizedLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secure.events")
+	opts := keyOptions([]byte("0123456789ABCDEF"))
+	event := func(id int64) Event {
+		return Event{ID: id, Timestamp: time.Now(), Type: StatementExecution, Details: "statement"}
+	}
+	recordSecure(t, path, opts, NoCompression, []Event{event(1), event(2)})
+
+	// A line over the decoder's limit between valid events
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bigLine := bytes.Count(data, []byte("\n")) + 1
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"Event":"` + strings.Repeat("x", DefaultDecoderOptions().MaxLineSize) + `"}` + "\n")
+	f.Close()
+	recordSecure(t, path, opts, NoCompression, []Event{event(3)})
+
+	read, report, err := ReadSecureEventsFileWithReport(path, opts)
+	if err != nil {
+		t.Fatalf("Failed to read events: %v", err)
+	}
+	if len(read) != 3 || read[2].ID != 3 {
+		t.Fatalf("Expected the 3 valid events around the long line, got %+v", read)
+	}
+	if len(report.Unparseable) != 1 || report.Unparseable[0] != bigLine || !strings.Contains(report.String(), "exceeds") {
+		t.Errorf("Expected line %d reported as too long, got %s", bigLine, report)
+	}
+
+	// A transcode doesn't silently drop it
+	err = Transcode(path, filepath.Join(t.TempDir(), "plain.events"),
+		FileRecorderOptions{Security: &opts}, FileRecorderOptions{CompressionType: NoCompression})
+	if err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Errorf("Expected the transcode to report the long line, got %v", err)
+	}
+
+	rec, err := NewSecureFileRecorderWithOptions(path, SecureFileRecorderOptions{SecurityOptions: opts, CompressionType: NoCompression})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Close()
+	if events := rec.GetEvents(); len(events) != 3 {
+		t.Errorf("Expected GetEvents to read 3 events, got %d", len(events))
+	}
+	tampered, err := rec.DetectTampering()
+	if !tampered || err == nil || !strings.Contains(err.Error(), "can't be verified") {
+		t.Errorf("Expected the unverifiable line to count as tampering, got %v, %v", tampered, err)
+	}
+}
+
+func FuzzSecureEventDecode(f *testing.F) {
+	opts := keyOptions([]byte("0123456789ABCDEF"))
+	sealed, err := SecureEventFromEvent(Event{ID: 7, Timestamp: time.Unix(7, 0), Type: ErrorEvent, Details: "boom"}, opts)
+	if err != nil {
+		f.Fatal(err)
+	}
+	line, _ := json.Marshal(sealed)
+	f.Add(line)
+	f.Add(append(append(line, '\n'), line[:len(line)/2]...))
+	f.Add([]byte(`{"Event":` + strings.Repeat("[", 300)))
+	f.Add([]byte(`{"chronogo_metadata":1}` + "\n" + string(line)))
+
+	limits := DecoderOptions{MaxLineSize: 256, MaxEvents: 4}
+	f.Fuzz(func(t *testing.T, input []byte) {
+		events := 0
+		report, err := scanSecureEvents(bytes.NewReader(input), opts, limits, func(Event) error {
+			events++
+			return nil
+		})
+		if err != nil && !errors.Is(err, ErrTooManyEvents) {
+			t.Fatalf("Unexpected error from an in-memory reader: %v", err)
+		}
+		if events > limits.MaxEvents {
+			t.Errorf("Opened %d events, over the limit of %d", events, limits.MaxEvents)
+		}
+		if report.Dropped()+events > report.Events {
+			t.Errorf("Report counts %d events, but %d were opened and %d dropped", report.Events, events, report.Dropped())
+		}
+	})
+}
@@ -1,8 +1,10 @@
 package recorder
 
 import (
+	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hkdf"
 	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
@@ -10,24 +12,34 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"os"
 	"regexp"
+	"strings"
 )
 
+// ErrIntegrityCheckFailed is returned for events whose HMAC doesn't match
+var ErrIntegrityCheckFailed = errors.New("HMAC verification failed: data may have been tampered with")
+
 // SecurityOptions configures security features for event recording
 type SecurityOptions struct {
 	// Encryption settings
 	EnableEncryption bool
-	EncryptionKey    []byte // Should be 16, 24, or 32 bytes for AES-128, AES-192, or AES-256
+	EncryptionKey    []byte   // Should be 16, 24, or 32 bytes for AES-128, AES-192, or AES-256
+	DecryptionKeys   [][]byte // Previous keys, tried in order for data sealed before a key rotation
 
 	// Redaction settings
 	EnableRedaction      bool
 	RedactionPatterns    []string // Regex patterns to identify sensitive data
 	RedactionReplacement string   // String to replace sensitive data with
+	RedactionRegexes     []string // Custom regexes; every match in a string value is replaced
+	RedactFields         []string // Field or map key names whose string values are replaced entirely
 
 	// Integrity verification settings
-	EnableIntegrityCheck bool
-	IntegrityKey         []byte // Key for HMAC
+	EnableIntegrityCheck  bool
+	IntegrityKey          []byte   // Key for HMAC
+	PreviousIntegrityKeys [][]byte // Previous HMAC keys, tried in order for data signed before a key rotation
 }
 
 // DefaultSecurityOptions returns the default security options (no security features enabled)
@@ -51,6 +63,21 @@ func WithEncryption(key []byte) func(*SecurityOptions) {
 	}
 }
 
+// WithDecryptionKeys adds previous keys used to open data sealed before a key rotation
+func WithDecryptionKeys(keys ...[]byte) func(*SecurityOptions) {
+	return func(opts *SecurityOptions) {
+		opts.DecryptionKeys = append(opts.DecryptionKeys, keys...)
+	}
+}
+
+// WithPreviousIntegrityKeys adds previous HMAC keys used to verify data
+// signed before a key rotation
+func WithPreviousIntegrityKeys(keys ...[]byte) func(*SecurityOptions) {
+	return func(opts *SecurityOptions) {
+		opts.PreviousIntegrityKeys = append(opts.PreviousIntegrityKeys, keys...)
+	}
+}
+
 // WithRedaction enables redaction with the given patterns and replacement
 func WithRedaction(patterns []string, replacement string) func(*SecurityOptions) {
 	return func(opts *SecurityOptions) {
@@ -70,9 +97,110 @@ func WithIntegrityCheck(key []byte) func(*SecurityOptions) {
 	}
 }
 
+// WithKey enables encryption and integrity checks with keys derived from a
+// single master key, see DeriveKeys. The chrono command uses a -key-file
+// this way, so recordings it reads should be sealed with it too. An invalid
+// master key leaves both keys unset, which Validate reports.
+func WithKey(master []byte) func(*SecurityOptions) {
+	return func(opts *SecurityOptions) {
+		encryptionKey, integrityKey, err := DeriveKeys(master)
+		if err != nil {
+			encryptionKey, integrityKey = nil, nil
+		}
+		WithEncryption(encryptionKey)(opts)
+		WithIntegrityCheck(integrityKey)(opts)
+	}
+}
+
+// DeriveKeys derives separate encryption and integrity keys from a master
+// key with HKDF-SHA256, so that AES-GCM and HMAC never share a key. The
+// encryption key is as long as the master key, keeping its AES variant.
+func DeriveKeys(master []byte) (encryptionKey, integrityKey []byte, err error) {
+	if !validKeyLength(master) {
+		return nil, nil, fmt.Errorf("key must be 16, 24, or 32 bytes long, got %d", len(master))
+	}
+	encryptionKey, err = hkdf.Key(sha256.New, master, nil, "chronogo encryption", len(master))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to derive encryption key: %v", err)
+	}
+	integrityKey, err = hkdf.Key(sha256.New, master, nil, "chronogo integrity", sha256.Size)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to derive integrity key: %v", err)
+	}
+	return encryptionKey, integrityKey, nil
+}
+
+// ReadKeyFile reads a master key of 16, 24 or 32 bytes from a file. The
+// file holds the key as it is, or hex or base64 encoded after a "hex:" or
+// "base64:" prefix. Only a trailing newline is stripped, as editors add one,
+// and only from a key that isn't a valid length with it, since any byte may
+// be part of a binary key.
+func ReadKeyFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %v", err)
+	}
+	text := bytes.TrimSuffix(data, []byte("\n"))
+	text = bytes.TrimSuffix(text, []byte("\r"))
+	var key []byte
+	switch {
+	case bytes.HasPrefix(text, []byte("hex:")):
+		if key, err = hex.DecodeString(string(text[len("hex:"):])); err != nil {
+			return nil, fmt.Errorf("invalid hex key: %v", err)
+		}
+	case bytes.HasPrefix(text, []byte("base64:")):
+		if key, err = base64.StdEncoding.DecodeString(string(text[len("base64:"):])); err != nil {
+			return nil, fmt.Errorf("invalid base64 key: %v", err)
+		}
+	case validKeyLength(data):
+		key = data
+	default:
+		key = text
+	}
+	if !validKeyLength(key) {
+		return nil, fmt.Errorf("key must be 16, 24, or 32 bytes long, got %d", len(key))
+	}
+	return key, nil
+}
+
+// validKeyLength reports whether key is a valid AES-128, AES-192 or AES-256 key
+func validKeyLength(key []byte) bool {
+	return len(key) == 16 || len(key) == 24 || len(key) == 32
+}
+
+// Validate checks that the enabled security features have usable keys, so a
+// misconfiguration is reported when a recorder is created rather than on
+// every event
+func (opts SecurityOptions) Validate() error {
+	if opts.EnableEncryption {
+		if len(opts.EncryptionKey) == 0 {
+			return errors.New("encryption is enabled but no encryption key is set")
+		}
+		if !validKeyLength(opts.EncryptionKey) {
+			return fmt.Errorf("encryption key is %d bytes; it must be 16, 24, or 32 bytes long", len(opts.EncryptionKey))
+		}
+		for i, key := range opts.DecryptionKeys {
+			if !validKeyLength(key) {
+				return fmt.Errorf("decryption key %d is %d bytes; it must be 16, 24, or 32 bytes long", i+1, len(key))
+			}
+		}
+	}
+	if opts.EnableIntegrityCheck {
+		if len(opts.IntegrityKey) == 0 {
+			return errors.New("integrity check is enabled but no integrity key is set")
+		}
+		for i, key := range opts.PreviousIntegrityKeys {
+			if len(key) == 0 {
+				return fmt.Errorf("previous integrity key %d is empty", i+1)
+			}
+		}
+	}
+	return nil
+}
+
 // EncryptData encrypts data using AES-GCM
 func EncryptData(data []byte, key []byte) ([]byte, error) {
-	if len(key) != 16 && len(key) != 24 && len(key) != 32 {
+	if !validKeyLength(key) {
 		return nil, errors.New("encryption key must be 16, 24, or 32 bytes long")
 	}
 
@@ -150,6 +278,76 @@ func RedactData(data []byte, patterns []string, replacement string) []byte {
 	return []byte(strData)
 }
 
+// WithRedactionRegexes adds custom regexes whose matches are redacted, e.g. a
+// credit card number pattern
+func WithRedactionRegexes(regexes []string) func(*SecurityOptions) {
+	return func(opts *SecurityOptions) {
+		opts.EnableRedaction = true
+		opts.RedactionRegexes = append(opts.RedactionRegexes, regexes...)
+	}
+}
+
+// WithRedactFields adds field names whose values are redacted wherever they
+// appear in an event, including keys of nested structured values
+func WithRedactFields(fields []string) func(*SecurityOptions) {
+	return func(opts *SecurityOptions) {
+		opts.EnableRedaction = true
+		opts.RedactFields = append(opts.RedactFields, fields...)
+	}
+}
+
+// RedactValue applies redaction to a decoded JSON value, recursing into maps
+// and slices. String values have the key-value patterns and custom regexes
+// applied; map entries whose key is listed in RedactFields (case-insensitive)
+// have all their string values replaced.
+func RedactValue(v interface{}, opts SecurityOptions) interface{} {
+	regexes := make([]*regexp.Regexp, 0, len(opts.RedactionRegexes))
+	for _, expr := range opts.RedactionRegexes {
+		r, err := regexp.Compile(expr)
+		if err != nil {
+			// Skip invalid regexes
+			continue
+		}
+		regexes = append(regexes, r)
+	}
+
+	fields := make(map[string]bool, len(opts.RedactFields))
+	for _, f := range opts.RedactFields {
+		fields[strings.ToLower(f)] = true
+	}
+
+	return redactValue(v, opts, regexes, fields, false)
+}
+
+// redactValue is the recursive worker for RedactValue. If replaceAll is set,
+// every string value is replaced because an enclosing field was targeted.
+func redactValue(v interface{}, opts SecurityOptions, regexes []*regexp.Regexp, fields map[string]bool, replaceAll bool) interface{} {
+	switch val := v.(type) {
+	case string:
+		if replaceAll {
+			return opts.RedactionReplacement
+		}
+		redacted := string(RedactData([]byte(val), opts.RedactionPatterns, opts.RedactionReplacement))
+		for _, r := range regexes {
+			redacted = r.ReplaceAllString(redacted, opts.RedactionReplacement)
+		}
+		return redacted
+	case map[string]interface{}:
+		for k, item := range val {
+			val[k] = redactValue(item, opts, regexes, fields, replaceAll || fields[strings.ToLower(k)])
+		}
+		return val
+	case []interface{}:
+		for i, item := range val {
+			val[i] = redactValue(item, opts, regexes, fields, replaceAll)
+		}
+		return val
+	default:
+		// Numbers, booleans and nulls are left as they are
+		return v
+	}
+}
+
 // CalculateHMAC generates an HMAC for the given data
 func CalculateHMAC(data []byte, key []byte) string {
 	h := hmac.New(sha256.New, key)
@@ -157,6 +355,55 @@ func CalculateHMAC(data []byte, key []byte) string {
 	return hex.EncodeToString(h.Sum(nil))
 }
 
+// KeyID returns a short identifier for a key. It is stored with encrypted
+// data so the right key can be picked without trial decryption.
+func KeyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:4])
+}
+
+// decryptWithKeys decrypts data sealed with the key identified by keyID. If
+// keyID is empty or unknown, the encryption key and then each decryption key
+// are tried in order.
+func decryptWithKeys(data []byte, keyID string, opts SecurityOptions) ([]byte, error) {
+	keys := append([][]byte{opts.EncryptionKey}, opts.DecryptionKeys...)
+
+	if keyID != "" {
+		for _, key := range keys {
+			if key != nil && KeyID(key) == keyID {
+				return DecryptData(data, key)
+			}
+		}
+	}
+
+	err := fmt.Errorf("no key available to decrypt data sealed with key %s", keyID)
+	for _, key := range keys {
+		if key == nil {
+			continue
+		}
+		plaintext, decryptErr := DecryptData(data, key)
+		if decryptErr == nil {
+			return plaintext, nil
+		}
+		err = decryptErr
+	}
+	return nil, err
+}
+
+// verifyIntegrity checks the HMAC against the integrity key, falling back to
+// the previous integrity keys for data signed before a key rotation
+func verifyIntegrity(data []byte, expectedHMAC string, opts SecurityOptions) bool {
+	if VerifyHMAC(data, opts.IntegrityKey, expectedHMAC) {
+		return true
+	}
+	for _, key := range opts.PreviousIntegrityKeys {
+		if VerifyHMAC(data, key, expectedHMAC) {
+			return true
+		}
+	}
+	return false
+}
+
 // VerifyHMAC checks if the HMAC for the given data matches the expected value
 func VerifyHMAC(data []byte, key []byte, expectedHMAC string) bool {
 	h := hmac.New(sha256.New, key)
@@ -167,10 +414,11 @@ func VerifyHMAC(data []byte, key []byte, expectedHMAC string) bool {
 
 // SecureEvent represents an event with security features
 type SecureEvent struct {
-	Event      Event  `json:"event"`       // Original event (or encrypted)
-	Encrypted  bool   `json:"encrypted"`   // Whether the event is encrypted
-	HMAC       string `json:"hmac"`        // HMAC for integrity verification
-	IsRedacted bool   `json:"is_redacted"` // Whether the event is redacted
+	Event      Event  `json:"event"`            // Original event (or encrypted)
+	Encrypted  bool   `json:"encrypted"`        // Whether the event is encrypted
+	KeyID      string `json:"key_id,omitempty"` // ID of the encryption key, see KeyID
+	HMAC       string `json:"hmac"`             // HMAC for integrity verification
+	IsRedacted bool   `json:"is_redacted"`      // Whether the event is redacted
 }
 
 // SecureEventFromEvent creates a SecureEvent from an Event with the given security options
@@ -181,22 +429,38 @@ func SecureEventFromEvent(event Event, opts SecurityOptions) (SecureEvent, error
 		IsRedacted: false,
 	}
 
+	if err := opts.Validate(); err != nil {
+		return secureEvent, fmt.Errorf("invalid security options: %v", err)
+	}
+
 	// Convert event to JSON for processing
 	eventJSON, err := json.Marshal(event)
 	if err != nil {
 		return secureEvent, err
 	}
 
-	// Apply redaction if enabled
+	// Apply redaction if enabled, walking every structured field of the event
 	if opts.EnableRedaction {
-		redactedEventJSON := RedactData(eventJSON, opts.RedactionPatterns, opts.RedactionReplacement)
+		var decoded interface{}
+		if err := json.Unmarshal(eventJSON, &decoded); err != nil {
+			return secureEvent, err
+		}
+		redactedEventJSON, err := json.Marshal(RedactValue(decoded, opts))
+		if err != nil {
+			return secureEvent, err
+		}
 		var redactedEvent Event
 		if err := json.Unmarshal(redactedEventJSON, &redactedEvent); err != nil {
 			return secureEvent, err
 		}
 		secureEvent.Event = redactedEvent
 		secureEvent.IsRedacted = true
-		eventJSON = redactedEventJSON // Use redacted data for further processing
+		// Use redacted data for further processing, re-serialized in field
+		// order so the HMAC matches what verification computes
+		eventJSON, err = json.Marshal(redactedEvent)
+		if err != nil {
+			return secureEvent, err
+		}
 	}
 
 	// Apply encryption if enabled
@@ -216,6 +480,7 @@ func SecureEventFromEvent(event Event, opts SecurityOptions) (SecureEvent, error
 			FuncName:  "",
 		}
 		secureEvent.Encrypted = true
+		secureEvent.KeyID = KeyID(opts.EncryptionKey)
 		// Update the JSON for HMAC calculation
 		eventJSON, _ = json.Marshal(secureEvent.Event)
 	}
@@ -237,8 +502,8 @@ func (se SecureEvent) GetOriginalEvent(opts SecurityOptions) (Event, error) {
 			if err != nil {
 				return Event{}, err
 			}
-			if !VerifyHMAC(eventJSON, opts.IntegrityKey, se.HMAC) {
-				return Event{}, errors.New("HMAC verification failed: data may have been tampered with")
+			if !verifyIntegrity(eventJSON, se.HMAC, opts) {
+				return Event{}, ErrIntegrityCheckFailed
 			}
 		}
 		return se.Event, nil
@@ -256,13 +521,13 @@ func (se SecureEvent) GetOriginalEvent(opts SecurityOptions) (Event, error) {
 		if err != nil {
 			return Event{}, err
 		}
-		if !VerifyHMAC(eventJSON, opts.IntegrityKey, se.HMAC) {
-			return Event{}, errors.New("HMAC verification failed: data may have been tampered with")
+		if !verifyIntegrity(eventJSON, se.HMAC, opts) {
+			return Event{}, ErrIntegrityCheckFailed
 		}
 	}
 
 	// Decrypt data
-	decryptedData, err := DecryptData(encryptedData, opts.EncryptionKey)
+	decryptedData, err := decryptWithKeys(encryptedData, se.KeyID, opts)
 	if err != nil {
 		return Event{}, err
 	}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"os"
+	"strings"
 	"testing"
 	"time"
 )
@@ -73,6 +74,41 @@ func TestRedaction(t *testing.T) {
 	}
 }
 
+// TestRedactValue checks field targeting and custom regexes on structured values
+func TestRedactValue(t *testing.T) {
+	opts := SecurityOptions{
+		RedactionPatterns:    []string{"token"},
+		RedactionReplacement: "***REDACTED***",
+		RedactionRegexes:     []string{`\b(?:\d[ -]?){13,16}\b`},
+		RedactFields:         []string{"Password"},
+	}
+
+	value := map[string]interface{}{
+		"args": map[string]interface{}{
+			"user":     "john",
+			"password": map[string]interface{}{"current": "hunter2", "attempts": float64(3)},
+			"notes":    []interface{}{"token=abc123", "card 4111 1111 1111 1111 on file"},
+		},
+	}
+
+	redacted, err := json.Marshal(RedactValue(value, opts))
+	if err != nil {
+		t.Fatalf("Failed to marshal redacted value: %v", err)
+	}
+
+	for _, secret := range []string{"hunter2", "abc123", "4111"} {
+		if bytes.Contains(redacted, []byte(secret)) {
+			t.Errorf("%q was not redacted: %s", secret, redacted)
+		}
+	}
+	if !bytes.Contains(redacted, []byte("john")) {
+		t.Errorf("Username was incorrectly redacted: %s", redacted)
+	}
+	if !bytes.Contains(redacted, []byte(`"attempts":3`)) {
+		t.Errorf("Non-string value under a redacted field should be kept: %s", redacted)
+	}
+}
+
 // TestHMAC checks that HMAC generation and verification work correctly
 func TestHMAC(t *testing.T) {
 	// Create test data and key
@@ -174,6 +210,39 @@ func TestSecureEvent(t *testing.T) {
 		}
 	})
 
+	// Test field targeting and custom regexes together with integrity checks
+	t.Run("WithRedactFieldsAndIntegrity", func(t *testing.T) {
+		cardEvent := event
+		cardEvent.Details = "Charging card 4111-1111-1111-1111"
+
+		opts := SecurityOptions{
+			RedactionReplacement: "***REDACTED***",
+			EnableIntegrityCheck: true,
+			IntegrityKey:         []byte("integrity-test-key"),
+		}
+		WithRedactionRegexes([]string{`\b(?:\d[ -]?){13,16}\b`})(&opts)
+		WithRedactFields([]string{"FuncName"})(&opts)
+
+		secureEvent, err := SecureEventFromEvent(cardEvent, opts)
+		if err != nil {
+			t.Fatalf("Failed to create secure event: %v", err)
+		}
+
+		if bytes.Contains([]byte(secureEvent.Event.Details), []byte("4111")) {
+			t.Errorf("Card number was not redacted: %s", secureEvent.Event.Details)
+		}
+		if secureEvent.Event.FuncName != opts.RedactionReplacement {
+			t.Errorf("Expected FuncName to be redacted, got %s", secureEvent.Event.FuncName)
+		}
+		if secureEvent.Event.File != cardEvent.File {
+			t.Errorf("File was incorrectly redacted: %s", secureEvent.Event.File)
+		}
+
+		if _, err := secureEvent.GetOriginalEvent(opts); err != nil {
+			t.Errorf("Integrity check failed on redacted event: %v", err)
+		}
+	})
+
 	// Test with HMAC integrity check enabled
 	t.Run("WithIntegrityCheck", func(t *testing.T) {
 		opts := SecurityOptions{
@@ -407,3 +476,144 @@ func TestSecureFileRecorderWithFullSecurity(t *testing.T) {
 		tamperRecorder.Close()
 	})
 }
+
+// TestSecurityOptionsValidate checks that misconfigured keys are rejected when a recorder is created
+func TestSecurityOptionsValidate(t *testing.T) {
+	testCases := []struct {
+		name    string
+		opts    SecurityOptions
+		wantErr string
+	}{
+		{
+			name:    "EncryptionWithoutKey",
+			opts:    SecurityOptions{EnableEncryption: true},
+			wantErr: "no encryption key",
+		},
+		{
+			name:    "EncryptionWithShortKey",
+			opts:    SecurityOptions{EnableEncryption: true, EncryptionKey: []byte("too-short")},
+			wantErr: "encryption key is 9 bytes",
+		},
+		{
+			name: "InvalidDecryptionKey",
+			opts: SecurityOptions{
+				EnableEncryption: true,
+				EncryptionKey:    []byte("0123456789ABCDEF"),
+				DecryptionKeys:   [][]byte{[]byte("0123456789ABCDEF"), []byte("bad")},
+			},
+			wantErr: "decryption key 2 is 3 bytes",
+		},
+		{
+			name:    "IntegrityWithoutKey",
+			opts:    SecurityOptions{EnableIntegrityCheck: true},
+			wantErr: "no integrity key",
+		},
+		{
+			name: "Valid",
+			opts: SecurityOptions{
+				EnableEncryption:     true,
+				EncryptionKey:        []byte("0123456789ABCDEF0123456789ABCDEF"),
+				EnableIntegrityCheck: true,
+				IntegrityKey:         []byte("integrity-test-key"),
+			},
+		},
+		{
+			// Keys are only checked for enabled features
+			name: "DisabledFeatures",
+			opts: SecurityOptions{EncryptionKey: []byte("unused")},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := t.TempDir() + "/events.json"
+			rec, err := NewSecureFileRecorderWithOptions(path, SecureFileRecorderOptions{SecurityOptions: tc.opts})
+
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Expected options to be accepted, got %v", err)
+				}
+				rec.Close()
+				return
+			}
+
+			if err == nil {
+				rec.Close()
+				t.Fatalf("Expected error containing %q, got nil", tc.wantErr)
+			}
+			if !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tc.wantErr, err)
+			}
+			if _, statErr := os.Stat(path); !os.IsNotExist(statErr) {
+				t.Error("Expected no events file to be created for invalid options")
+			}
+
+			// Sealing a single event reports the same problem
+			if _, err := SecureEventFromEvent(Event{ID: 1}, tc.opts); err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("Expected SecureEventFromEvent error containing %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestReadKeyFile(t *testing.T) {
+	key := []byte("0123456789ABCDE\n") // A binary key may end in any byte
+	dir := t.TempDir()
+	for _, tc := range []struct {
+		name    string
+		content string
+		want    []byte
+	}{
+		{"Raw", string(key), key},
+		{"TrailingNewline", "0123456789ABCDEF\r\n", []byte("0123456789ABCDEF")},
+		{"Hex", "hex:303132333435363738394142434445460a0b0c0d0e0f1011\n", []byte("0123456789ABCDEF\n\v\f\r\x0e\x0f\x10\x11")},
+		{"Base64", "base64:MDEyMzQ1Njc4OUFCQ0RFCg==\n", key},
+		{"InvalidHex", "hex:zz", nil},
+		{"SpacesKept", " 0123456789ABCDEF ", nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := dir + "/" + tc.name + ".key"
+			if err := os.WriteFile(path, []byte(tc.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			got, err := ReadKeyFile(path)
+			if tc.want == nil {
+				if err == nil {
+					t.Errorf("Expected an error, got key %q", got)
+				}
+				return
+			}
+			if err != nil || !bytes.Equal(got, tc.want) {
+				t.Errorf("Expected key %q, got %q (%v)", tc.want, got, err)
+			}
+		})
+	}
+}
+
+func TestWithKey(t *testing.T) {
+	master := []byte("0123456789ABCDEF0123456789ABCDEF")
+	opts := DefaultSecurityOptions()
+	WithKey(master)(&opts)
+	if err := opts.Validate(); err != nil {
+		t.Fatalf("Expected derived keys to be valid, got %v", err)
+	}
+	if len(opts.EncryptionKey) != len(master) || bytes.Equal(opts.EncryptionKey, master) ||
+		bytes.Equal(opts.EncryptionKey, opts.IntegrityKey[:len(master)]) {
+		t.Errorf("Expected distinct derived keys, got %x and %x", opts.EncryptionKey, opts.IntegrityKey)
+	}
+
+	event := Event{ID: 1, Timestamp: time.Now(), Type: FuncEntry, Details: "sealed with derived keys"}
+	secureEvent, err := SecureEventFromEvent(event, opts)
+	if err != nil {
+		t.Fatalf("Failed to create secure event: %v", err)
+	}
+	if got, err := secureEvent.GetOriginalEvent(opts); err != nil || got.Details != event.Details {
+		t.Errorf("Expected to open the event, got %q (%v)", got.Details, err)
+	}
+
+	invalid := DefaultSecurityOptions()
+	WithKey([]byte("short"))(&invalid)
+	if err := invalid.Validate(); err == nil {
+		t.Error("Expected an invalid master key to be reported")
+	}
+}
@@ -0,0 +1,165 @@
+package recorder
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// segmentPattern matches the segment files written by a FlightRecorder
+const segmentPattern = "segment-*.events"
+
+// SegmentBoundary marks where a segment starts in an event stream read from
+// a segment directory
+type SegmentBoundary struct {
+	Path     string // Segment file
+	StartIdx int    // Index of the segment's first event in the stream
+}
+
+// ListSegments returns the segment files in dir, oldest first
+func ListSegments(dir string) ([]string, error) {
+	segments, err := filepath.Glob(filepath.Join(dir, segmentPattern))
+	if err != nil {
+		return nil, err
+	}
+
+	// Sequence numbers are zero-padded, so names sort in recording order
+	sort.Strings(segments)
+	return segments, nil
+}
+
+// listRotations returns the files FileRecorders rotated in dir along with
+// the files they were rotated from, grouped by recording, oldest first
+func listRotations(dir string) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*.[0-9][0-9][0-9][0-9][0-9][0-9]"))
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var paths []string
+	for _, match := range matches {
+		seen[strings.TrimSuffix(match, filepath.Ext(match))] = true
+	}
+	for path := range seen {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var rotations []string
+	for _, path := range paths {
+		rotated, err := ListRotatedFiles(path)
+		if err != nil {
+			return nil, err
+		}
+		rotations = append(rotations, rotated...)
+		if _, err := os.Stat(path); err == nil {
+			rotations = append(rotations, path)
+		}
+	}
+	return rotations, nil
+}
+
+// rootRecording returns the one events file in dir whose metadata doesn't
+// name a parent, or "" if there isn't exactly one
+func rootRecording(dir string) string {
+	matches, err := filepath.Glob(filepath.Join(dir, "*.events"))
+	if err != nil {
+		return ""
+	}
+	var roots []string
+	for _, match := range matches {
+		if md, err := ReadMetadata(match); err == nil && md != nil && md.ParentID != 0 {
+			continue
+		}
+		roots = append(roots, match)
+	}
+	if len(roots) != 1 {
+		return ""
+	}
+	return roots[0]
+}
+
+// ReadSegmentDir reads the segments a FlightRecorder wrote into dir as one
+// continuous event stream, without stitching them into a file first. It
+// also returns where each segment starts in the stream. A directory
+// without segments is read as the files a FileRecorder rotated by size,
+// each recording's rotated files followed by the file it was writing, with
+// a RotationEvent starting each file after the first. A directory without
+// either is read as its one recording that no recorded process started.
+// The recordings of child processes that spawn events link to are
+// stitched in, as StitchChildRecordings does.
+//
+// All segments are opened before any is read, so a FlightRecorder deleting
+// old segments for retention while the directory is being read doesn't lose
+// events from the middle of the stream. Segments removed before they could
+// be opened are skipped with a warning.
+func ReadSegmentDir(dir string) ([]Event, []SegmentBoundary, error) {
+	paths, err := ListSegments(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(paths) == 0 {
+		if paths, err = listRotations(dir); err != nil {
+			return nil, nil, err
+		}
+	}
+	if len(paths) == 0 {
+		if root := rootRecording(dir); root != "" {
+			paths = []string{root}
+		}
+	}
+	if len(paths) == 0 {
+		return nil, nil, fmt.Errorf("no segment files found in %s", dir)
+	}
+
+	var files []*os.File
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if os.IsNotExist(err) {
+			fmt.Printf("Warning: Segment %s was removed before it could be read\n", filepath.Base(path))
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("error opening segment: %v", err)
+		}
+		files = append(files, f)
+	}
+
+	var events []Event
+	var boundaries []SegmentBoundary
+	for _, f := range files {
+		// Segments are compressed independently
+		buffered := bufio.NewReader(f)
+		compressionType := detectCompression(buffered)
+
+		segmentEvents, err := DecodeEvents(buffered, compressionType)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error reading segment %s: %v", filepath.Base(f.Name()), err)
+		}
+		if len(segmentEvents) == 0 {
+			continue
+		}
+
+		boundaries = append(boundaries, SegmentBoundary{Path: f.Name(), StartIdx: len(events)})
+		events = append(events, segmentEvents...)
+	}
+
+	merged, positions, err := stitchChildren(events, dir, map[string]bool{})
+	if err != nil {
+		return nil, nil, err
+	}
+	if positions != nil {
+		for i := range boundaries {
+			boundaries[i].StartIdx = positions[boundaries[i].StartIdx]
+		}
+	}
+	return merged, boundaries, nil
+}
@@ -0,0 +1,131 @@
+package recorder
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReadSegmentDir(t *testing.T) {
+	originalInterval := SnapshotInterval
+	SnapshotInterval = 3
+	defer func() {
+		SnapshotInterval = originalInterval
+	}()
+
+	// Each segment starts with the recording metadata
+	var header bytes.Buffer
+	if err := writeMetadataLine(&header, CollectMetadata(MetadataOptions{}), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	fr, err := NewFlightRecorder(dir, FlightOptions{
+		SegmentSize:     int64(header.Len()) + 400,
+		MaxSegments:     100,
+		CompressionType: NoCompression,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create flight recorder: %v", err)
+	}
+	for id := int64(1); id <= 12; id++ {
+		if err := fr.RecordEvent(Event{ID: id, Timestamp: time.Now(), Type: StatementExecution, Details: "tick"}); err != nil {
+			t.Fatalf("Failed to record event: %v", err)
+		}
+	}
+	if err := fr.Close(); err != nil {
+		t.Fatalf("Failed to close flight recorder: %v", err)
+	}
+
+	events, boundaries, err := ReadSegmentDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to read segment directory: %v", err)
+	}
+	segments := fr.Segments()
+	if len(boundaries) != len(segments) || len(boundaries) < 2 {
+		t.Fatalf("Expected a boundary for each of %d segments, got %d", len(segments), len(boundaries))
+	}
+
+	// Events continue across segment boundaries, with each segment's snapshot markers kept
+	var ids []int64
+	snapshots := 0
+	for _, e := range events {
+		if e.Type == SnapshotEvent {
+			snapshots++
+			continue
+		}
+		ids = append(ids, e.ID)
+	}
+	for i, id := range ids {
+		if id != int64(i+1) {
+			t.Fatalf("Expected event %d at position %d, got %v", i+1, i, ids)
+		}
+	}
+	if len(ids) != 12 || snapshots == 0 {
+		t.Errorf("Expected 12 events and snapshot markers, got %d events and %d snapshots", len(ids), snapshots)
+	}
+
+	for i, b := range boundaries {
+		if b.Path != segments[i] {
+			t.Errorf("Boundary %d: expected %s, got %s", i, segments[i], b.Path)
+		}
+		if i == 0 && b.StartIdx != 0 {
+			t.Errorf("Expected first segment to start at 0, got %d", b.StartIdx)
+		}
+		if i > 0 && b.StartIdx <= boundaries[i-1].StartIdx {
+			t.Errorf("Expected boundaries in increasing order, got %+v", boundaries)
+		}
+	}
+}
+
+func TestReadSegmentDirEmpty(t *testing.T) {
+	dir := t.TempDir()
+	if _, _, err := ReadSegmentDir(dir); err == nil {
+		t.Errorf("Expected error reading a directory without segments")
+	}
+
+	// Unrelated files are ignored
+	if err := os.WriteFile(dir+"/notes.txt", []byte("hello"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if segments, err := ListSegments(dir); err != nil || len(segments) != 0 {
+		t.Errorf("Expected no segments, got %v (err: %v)", segments, err)
+	}
+}
+
+func TestReadSegmentDirRotations(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.events")
+	rec, err := NewFileRecorderWithOptions(path, FileRecorderOptions{CompressionType: NoCompression, MaxFileSize: 1})
+	if err != nil {
+		t.Fatalf("Failed to create file recorder: %v", err)
+	}
+	for id := int64(1); id <= 3; id++ {
+		if err := rec.RecordEvent(Event{ID: id, Timestamp: time.Now(), Type: StatementExecution, Details: "tick"}); err != nil {
+			t.Fatalf("Failed to record event: %v", err)
+		}
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatalf("Failed to close file recorder: %v", err)
+	}
+
+	// The rotated files come first, then the file being written
+	events, boundaries, err := ReadSegmentDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to read rotations: %v", err)
+	}
+	if len(boundaries) != 3 || boundaries[0].Path != rotationPath(path, 1) || boundaries[2].Path != path {
+		t.Fatalf("Expected a boundary for each of the 3 files, got %+v", boundaries)
+	}
+	var types []EventType
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+	want := []EventType{StatementExecution, RotationEvent, StatementExecution, RotationEvent, StatementExecution}
+	if !reflect.DeepEqual(types, want) {
+		t.Errorf("Expected events %v, got %v", want, types)
+	}
+}
@@ -0,0 +1,102 @@
+package recorder
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// DefaultSpoolMemory is how much of a streamed recording a Spool holds in
+// memory before spilling it to a temporary file
+const DefaultSpoolMemory = 32 << 20
+
+// Spool holds a recording read from a stream that can only be read once,
+// such as standard input or a named pipe, so that it can be read as often
+// as an events file: in memory up to a threshold, and in a temporary file
+// beyond it
+type Spool struct {
+	data []byte
+	file *os.File // Spill file, nil while the recording fits in memory
+	size int64
+}
+
+// SpoolStream reads r to the end into a Spool, holding up to memLimit bytes
+// in memory
+func SpoolStream(r io.Reader, memLimit int64) (*Spool, error) {
+	var buf bytes.Buffer
+	n, err := io.CopyN(&buf, r, memLimit+1)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("error reading stream: %v", err)
+	}
+	if n <= memLimit {
+		return &Spool{data: buf.Bytes(), size: n}, nil
+	}
+
+	f, err := os.CreateTemp("", "chronogo-stream-*.events")
+	if err != nil {
+		return nil, fmt.Errorf("error creating spill file: %v", err)
+	}
+	s := &Spool{file: f, size: n}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		s.Close()
+		return nil, fmt.Errorf("error writing spill file: %v", err)
+	}
+	rest, err := io.Copy(f, r)
+	if err != nil {
+		s.Close()
+		return nil, fmt.Errorf("error reading stream: %v", err)
+	}
+	s.size += rest
+	return s, nil
+}
+
+// Reader returns a reader over the spooled recording from its start
+func (s *Spool) Reader() io.Reader {
+	if s.file == nil {
+		return bytes.NewReader(s.data)
+	}
+	return io.NewSectionReader(s.file, 0, s.size)
+}
+
+// Size returns the number of bytes spooled
+func (s *Spool) Size() int64 {
+	return s.size
+}
+
+// Spilled reports whether the recording outgrew memory and is held in a
+// temporary file
+func (s *Spool) Spilled() bool {
+	return s.file != nil
+}
+
+// Close releases the spooled recording, removing the spill file
+func (s *Spool) Close() error {
+	s.data = nil
+	if s.file == nil {
+		return nil
+	}
+	name := s.file.Name()
+	err := s.file.Close()
+	if rmErr := os.Remove(name); err == nil {
+		err = rmErr
+	}
+	s.file = nil
+	return err
+}
+
+// ScanEvents calls fn for each event of the spooled recording, like
+// ScanEventsFile, detecting compression from the header
+func (s *Spool) ScanEvents(fn func(Event) error) error {
+	if err := scanEventStream(s.Reader(), fn); err != nil {
+		return fmt.Errorf("error reading events stream: %v", err)
+	}
+	return nil
+}
+
+// Metadata returns the metadata at the start of the spooled recording, or
+// nil if it has none
+func (s *Spool) Metadata() (*RecordingMetadata, error) {
+	return readMetadata(s.Reader(), nil)
+}
@@ -0,0 +1,75 @@
+package recorder
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSpoolStream(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stream.events")
+	options := DefaultFileRecorderOptions()
+	options.CompressionType = GzipCompression
+	fr, err := NewFileRecorderWithOptions(path, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Now()
+	for i := 1; i <= 50; i++ {
+		e := Event{ID: int64(i), Timestamp: base.Add(time.Duration(i) * time.Millisecond), Type: StatementExecution, Details: "x++"}
+		if err := fr.RecordEvent(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := fr.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, limit := range []int64{DefaultSpoolMemory, 16} {
+		// A reader that isn't a file, as a pipe isn't seekable
+		spool, err := SpoolStream(io.MultiReader(bytes.NewReader(data)), limit)
+		if err != nil {
+			t.Fatalf("SpoolStream(%d) failed: %v", limit, err)
+		}
+		if spilled := limit < int64(len(data)); spool.Spilled() != spilled {
+			t.Errorf("Limit %d: Spilled() = %v, want %v", limit, spool.Spilled(), spilled)
+		}
+		if spool.Size() != int64(len(data)) {
+			t.Errorf("Limit %d: spooled %d bytes, want %d", limit, spool.Size(), len(data))
+		}
+
+		// Read twice, as a windowed replay does
+		for pass := 0; pass < 2; pass++ {
+			n := 0
+			if err := spool.ScanEvents(func(Event) error { n++; return nil }); err != nil {
+				t.Fatalf("Limit %d: ScanEvents failed: %v", limit, err)
+			}
+			if n != 50 {
+				t.Errorf("Limit %d, pass %d: read %d events, want 50", limit, pass, n)
+			}
+		}
+		if md, err := spool.Metadata(); err != nil || md == nil {
+			t.Errorf("Limit %d: expected metadata, got %v, %v", limit, md, err)
+		}
+
+		var spillFile string
+		if spool.file != nil {
+			spillFile = spool.file.Name()
+		}
+		if err := spool.Close(); err != nil {
+			t.Errorf("Limit %d: Close failed: %v", limit, err)
+		}
+		if spillFile != "" {
+			if _, err := os.Stat(spillFile); !os.IsNotExist(err) {
+				t.Errorf("Spill file %s wasn't removed: %v", spillFile, err)
+			}
+		}
+	}
+}
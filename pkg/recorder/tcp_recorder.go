@@ -0,0 +1,411 @@
+package recorder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// TCPRecorderOptions contains options for creating a TCP recorder
+type TCPRecorderOptions struct {
+	BufferSize    int           // Maximum number of events held locally while the collector is unreachable
+	DialTimeout   time.Duration // Timeout for each connection attempt
+	RetryInterval time.Duration // Minimum time between reconnection attempts
+	WriteTimeout  time.Duration // Time allowed to send each event before the connection is dropped
+}
+
+// DefaultTCPRecorderOptions returns default options for TCP recorder
+func DefaultTCPRecorderOptions() TCPRecorderOptions {
+	return TCPRecorderOptions{
+		BufferSize:    10000,
+		DialTimeout:   2 * time.Second,
+		RetryInterval: time.Second,
+		WriteTimeout:  5 * time.Second,
+	}
+}
+
+// dropWarningInterval is the least time between warnings about events
+// dropped from a full TCPRecorder buffer
+const dropWarningInterval = 5 * time.Second
+
+// minRetryDelay keeps a TCPRecorder with no retry interval from redialing
+// an unreachable collector in a busy loop
+const minRetryDelay = 10 * time.Millisecond
+
+// TCPRecorder streams events to a remote collector started with
+// ServeCollector. Events are sent as uncompressed JSON lines; the collector
+// compresses them on disk.
+//
+// RecordEvent only queues events in a bounded local buffer; a background
+// goroutine sends them, so a slow or unreachable collector never blocks the
+// program being recorded. If the collector can't be reached, events stay
+// buffered and are sent once the connection is re-established. When the
+// buffer is full the oldest events are dropped.
+type TCPRecorder struct {
+	addr    string
+	options TCPRecorderOptions
+
+	mu          sync.Mutex
+	pending     []Event
+	inFlight    int // Events taken by the sender and not yet written
+	dropped     int
+	unreported  int       // Dropped events not warned about yet
+	lastWarning time.Time // When dropped events were last warned about
+	closedErr   error
+
+	wake chan struct{} // Signals the sender that events were queued
+	stop chan struct{} // Closed by Close for the sender to make a last attempt and exit
+	done chan struct{} // Closed by the sender once it exits
+
+	// Used by the sender only, and by the constructor before it starts
+	conn     net.Conn
+	out      *WriterRecorder
+	lastDial time.Time
+}
+
+// NewTCPRecorder creates a recorder that streams events to the collector at addr with default options
+func NewTCPRecorder(addr string) (*TCPRecorder, error) {
+	return NewTCPRecorderWithOptions(addr, DefaultTCPRecorderOptions())
+}
+
+// NewTCPRecorderWithOptions creates a recorder that streams events to the collector at addr.
+// The initial connection must succeed; later drops are handled by buffering and reconnecting.
+func NewTCPRecorderWithOptions(addr string, options TCPRecorderOptions) (*TCPRecorder, error) {
+	defaults := DefaultTCPRecorderOptions()
+	if options.BufferSize <= 0 {
+		options.BufferSize = defaults.BufferSize
+	}
+	if options.WriteTimeout <= 0 {
+		options.WriteTimeout = defaults.WriteTimeout
+	}
+
+	tr := &TCPRecorder{
+		addr:    addr,
+		options: options,
+		wake:    make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if err := tr.connect(); err != nil {
+		return nil, fmt.Errorf("failed to connect to collector at %s: %v", addr, err)
+	}
+	go tr.send()
+	return tr, nil
+}
+
+// connect dials the collector and sets up the event writer
+func (tr *TCPRecorder) connect() error {
+	tr.lastDial = time.Now()
+	conn, err := net.DialTimeout("tcp", tr.addr, tr.options.DialTimeout)
+	if err != nil {
+		return err
+	}
+	tr.conn = conn
+	tr.out = NewWriterRecorder(conn, FileRecorderOptions{CompressionType: NoCompression})
+	return nil
+}
+
+// disconnect drops the current connection
+func (tr *TCPRecorder) disconnect() {
+	if tr.conn != nil {
+		tr.conn.Close()
+	}
+	tr.conn = nil
+	tr.out = nil
+}
+
+// RecordEvent queues an event for the background sender and returns at
+// once. Network errors are not returned; the event stays buffered until the
+// collector is reachable again.
+func (tr *TCPRecorder) RecordEvent(e Event) error {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	if tr.closedErr != nil {
+		return tr.closedErr
+	}
+
+	tr.pending = append(tr.pending, e)
+	tr.trim()
+
+	select {
+	case tr.wake <- struct{}{}:
+	default: // The sender is already due to run
+	}
+	return nil
+}
+
+// trim drops the oldest queued events beyond the buffer size, warning about
+// them at most every dropWarningInterval. Callers must hold mu.
+func (tr *TCPRecorder) trim() {
+	over := min(len(tr.pending)+tr.inFlight-tr.options.BufferSize, len(tr.pending))
+	if over <= 0 {
+		return
+	}
+	tr.pending = tr.pending[over:]
+	tr.dropped += over
+	tr.unreported += over
+	if time.Since(tr.lastWarning) >= dropWarningInterval {
+		fmt.Printf("Warning: Collector unreachable, dropped %d buffered events\n", tr.unreported)
+		tr.unreported = 0
+		tr.lastWarning = time.Now()
+	}
+}
+
+// send runs in the background, sending queued events as they arrive and
+// retrying an unreachable collector every retry interval, until Close
+func (tr *TCPRecorder) send() {
+	defer close(tr.done)
+	defer tr.disconnect()
+
+	for {
+		var retry <-chan time.Time
+		if tr.flush() {
+			retry = time.After(max(tr.options.RetryInterval-time.Since(tr.lastDial), minRetryDelay))
+		}
+
+		select {
+		case <-tr.wake:
+		case <-retry:
+		case <-tr.stop:
+			tr.lastDial = time.Time{}
+			tr.flush()
+			return
+		}
+	}
+}
+
+// flush sends the queued events, reconnecting first if the retry interval
+// allows. It reports whether any are left unsent.
+func (tr *TCPRecorder) flush() bool {
+	for {
+		tr.mu.Lock()
+		batch := tr.pending
+		tr.pending = nil
+		tr.inFlight = len(batch)
+		tr.mu.Unlock()
+		if len(batch) == 0 {
+			return false
+		}
+
+		sent := tr.write(batch)
+
+		tr.mu.Lock()
+		tr.inFlight = 0
+		if sent < len(batch) {
+			// Unsent events go back ahead of those queued meanwhile
+			tr.pending = append(append([]Event(nil), batch[sent:]...), tr.pending...)
+			tr.trim()
+			tr.mu.Unlock()
+			return true
+		}
+		tr.mu.Unlock()
+	}
+}
+
+// write sends events to the collector, dialing first if there is no
+// connection and the retry interval has passed, and returns how many it sent
+func (tr *TCPRecorder) write(events []Event) int {
+	if tr.conn == nil {
+		if time.Since(tr.lastDial) < tr.options.RetryInterval {
+			return 0
+		}
+		if err := tr.connect(); err != nil {
+			return 0
+		}
+	}
+
+	for i, e := range events {
+		tr.conn.SetWriteDeadline(time.Now().Add(tr.options.WriteTimeout))
+		if err := tr.out.writeEvent(e); err != nil {
+			fmt.Printf("Warning: Lost connection to collector at %s: %v\n", tr.addr, err)
+			tr.disconnect()
+			return i
+		}
+	}
+	return len(events)
+}
+
+// Pending returns the number of events buffered locally waiting to be sent
+func (tr *TCPRecorder) Pending() int {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	return len(tr.pending) + tr.inFlight
+}
+
+// Dropped returns the number of events discarded because the buffer was full
+func (tr *TCPRecorder) Dropped() int {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	return tr.dropped
+}
+
+// GetEvents returns nil; recorded events live with the collector
+func (tr *TCPRecorder) GetEvents() []Event {
+	return nil
+}
+
+// Clear discards events that haven't been sent yet
+func (tr *TCPRecorder) Clear() {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	tr.pending = nil
+}
+
+// Close stops the background sender after a final attempt to send buffered
+// events, and closes the connection
+func (tr *TCPRecorder) Close() error {
+	tr.mu.Lock()
+	if tr.closedErr != nil {
+		tr.mu.Unlock()
+		return nil
+	}
+	tr.closedErr = errors.New("recorder is closed")
+	tr.mu.Unlock()
+
+	close(tr.stop)
+	<-tr.done
+
+	if unsent := tr.Pending(); unsent > 0 {
+		return fmt.Errorf("failed to send %d events to collector at %s", unsent, tr.addr)
+	}
+	return nil
+}
+
+// Collector accepts connections from TCP recorders and appends the events
+// they send to a single file recorder, broadcasting them to subscribers as
+// they arrive
+type Collector struct {
+	listener net.Listener
+	out      *BroadcastRecorder
+
+	mu     sync.Mutex
+	conns  map[net.Conn]bool
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// NewCollector listens on listenAddr and opens outputPath for the collected events
+func NewCollector(listenAddr, outputPath string, options FileRecorderOptions) (*Collector, error) {
+	out, err := NewFileRecorderWithOptions(outputPath, options)
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		out.Close()
+		return nil, err
+	}
+
+	return &Collector{
+		listener: listener,
+		out:      NewBroadcastRecorder(out),
+		conns:    make(map[net.Conn]bool),
+	}, nil
+}
+
+// ServeCollector listens on listenAddr and appends events received from TCP
+// recorders to outputPath until the listener fails
+func ServeCollector(listenAddr, outputPath string) error {
+	c, err := NewCollector(listenAddr, outputPath, DefaultFileRecorderOptions())
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	return c.Serve()
+}
+
+// Addr returns the address the collector is listening on
+func (c *Collector) Addr() net.Addr {
+	return c.listener.Addr()
+}
+
+// Subscribe returns a channel receiving the events collected from now on,
+// until ctx is done or the collector is closed. See BroadcastRecorder.
+func (c *Collector) Subscribe(ctx context.Context) <-chan Event {
+	return c.out.Subscribe(ctx)
+}
+
+// Serve accepts connections until the collector is closed
+func (c *Collector) Serve() error {
+	for {
+		conn, err := c.listener.Accept()
+		if err != nil {
+			c.mu.Lock()
+			closed := c.closed
+			c.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			conn.Close()
+			return nil
+		}
+		c.conns[conn] = true
+		c.wg.Add(1)
+		c.mu.Unlock()
+
+		go c.handle(conn)
+	}
+}
+
+// handle records every event received on conn
+func (c *Collector) handle(conn net.Conn) {
+	defer c.wg.Done()
+	defer func() {
+		c.mu.Lock()
+		delete(c.conns, conn)
+		c.mu.Unlock()
+		conn.Close()
+	}()
+
+	// A remote recorder may stream for as long as it runs
+	opts := DefaultDecoderOptions()
+	opts.MaxEvents = 0
+	err := scanEvents(conn, opts, func(e Event) error {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.out.RecordEvent(e)
+	})
+	if err != nil && !c.isClosed() {
+		fmt.Printf("Warning: Error receiving events from %s: %v\n", conn.RemoteAddr(), err)
+	}
+}
+
+// isClosed reports whether Close has been called
+func (c *Collector) isClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+// Close stops accepting connections, disconnects all recorders and closes the output file
+func (c *Collector) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	err := c.listener.Close()
+	for conn := range c.conns {
+		conn.Close()
+	}
+	c.mu.Unlock()
+
+	c.wg.Wait()
+
+	if closeErr := c.out.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	return err
+}
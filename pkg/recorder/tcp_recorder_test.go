@@ -0,0 +1,215 @@
+package recorder
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// waitForEvent polls the collector output until it holds an event with the given ID
+func waitForEvent(t *testing.T, path string, id int64) []Event {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		events, err := ReadEventsFile(path)
+		if err == nil && len(events) > 0 && events[len(events)-1].ID == id {
+			return events
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for event %d, got %d events (err: %v)", id, len(events), err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestTCPRecorderCollector(t *testing.T) {
+	outPath := filepath.Join(t.TempDir(), "collected.log")
+	collector, err := NewCollector("127.0.0.1:0", outPath, FileRecorderOptions{CompressionType: NoCompression})
+	if err != nil {
+		t.Fatalf("Failed to start collector: %v", err)
+	}
+	defer collector.Close()
+	go collector.Serve()
+
+	rec, err := NewTCPRecorder(collector.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to create TCP recorder: %v", err)
+	}
+
+	testEvents := []Event{
+		{ID: 1, Timestamp: time.Now(), Type: FuncEntry, Details: "Entering main", FuncName: "main"},
+		{ID: 2, Timestamp: time.Now(), Type: VarAssignment, Details: "x = 1", FuncName: "main"},
+		{ID: 3, Timestamp: time.Now(), Type: FuncExit, Details: "Exiting main", FuncName: "main"},
+	}
+	for _, e := range testEvents {
+		if err := rec.RecordEvent(e); err != nil {
+			t.Fatalf("Failed to record event: %v", err)
+		}
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatalf("Failed to close recorder: %v", err)
+	}
+
+	events := waitForEvent(t, outPath, 3)
+	for i, e := range events {
+		if e.ID != testEvents[i].ID || e.Details != testEvents[i].Details {
+			t.Errorf("Event %d mismatch: expected %+v, got %+v", i, testEvents[i], e)
+		}
+	}
+
+	if err := rec.RecordEvent(testEvents[0]); err == nil {
+		t.Errorf("Expected error recording to a closed recorder")
+	}
+}
+
+func TestTCPRecorderReconnect(t *testing.T) {
+	outPath := filepath.Join(t.TempDir(), "collected.log")
+	options := FileRecorderOptions{CompressionType: NoCompression}
+
+	collector, err := NewCollector("127.0.0.1:0", outPath, options)
+	if err != nil {
+		t.Fatalf("Failed to start collector: %v", err)
+	}
+	addr := collector.Addr().String()
+	go collector.Serve()
+
+	rec, err := NewTCPRecorderWithOptions(addr, TCPRecorderOptions{
+		BufferSize:    2,
+		DialTimeout:   time.Second,
+		RetryInterval: 0,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create TCP recorder: %v", err)
+	}
+	defer rec.Close()
+
+	if err := rec.RecordEvent(Event{ID: 1, Timestamp: time.Now(), Type: FuncEntry, Details: "before drop"}); err != nil {
+		t.Fatalf("Failed to record event: %v", err)
+	}
+	waitForEvent(t, outPath, 1)
+
+	// Take the collector down; writes fail once the connection is reset
+	collector.Close()
+	for id := int64(2); rec.Pending() == 0; id++ {
+		if id > 1000 {
+			t.Fatalf("Recorder never noticed the collector going away")
+		}
+		rec.RecordEvent(Event{ID: id, Timestamp: time.Now(), Type: StatementExecution, Details: "probe"})
+		time.Sleep(time.Millisecond)
+	}
+
+	// Buffer more events than fit while the collector is down
+	for id := int64(2001); id <= 2003; id++ {
+		rec.RecordEvent(Event{ID: id, Timestamp: time.Now(), Type: StatementExecution, Details: "while down"})
+	}
+	if rec.Pending() != 2 {
+		t.Errorf("Expected buffer bounded at 2 events, got %d", rec.Pending())
+	}
+	if rec.Dropped() == 0 {
+		t.Errorf("Expected oldest events to be dropped")
+	}
+
+	// Bring the collector back on the same address
+	var restarted *Collector
+	for i := 0; i < 50; i++ {
+		restarted, err = NewCollector(addr, outPath, options)
+		if err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Skipf("Could not rebind %s: %v", addr, err)
+	}
+	defer restarted.Close()
+	go restarted.Serve()
+
+	if err := rec.RecordEvent(Event{ID: 3000, Timestamp: time.Now(), Type: FuncExit, Details: "after reconnect"}); err != nil {
+		t.Fatalf("Failed to record event: %v", err)
+	}
+	events := waitForEvent(t, outPath, 3000)
+	if rec.Pending() != 0 {
+		t.Errorf("Expected buffered events to be sent after reconnect, %d pending", rec.Pending())
+	}
+
+	var last []int64
+	for _, e := range events {
+		if e.ID >= 2002 {
+			last = append(last, e.ID)
+		}
+	}
+	// The bounded buffer kept the newest two events recorded while the
+	// collector was down, 2002 and 2003, and the sender may reconnect and
+	// send them before 3000 is queued, which then drops 2002
+	if got := fmt.Sprint(last); got != "[2002 2003 3000]" && got != "[2003 3000]" {
+		t.Errorf("Expected buffered events up to 2003 and then 3000 after reconnect, got %v", last)
+	}
+}
+
+func TestTCPRecorderSlowCollector(t *testing.T) {
+	// A collector that accepts connections but never reads from them
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	rec, err := NewTCPRecorderWithOptions(l.Addr().String(), TCPRecorderOptions{
+		BufferSize:    100,
+		DialTimeout:   time.Second,
+		RetryInterval: time.Hour,
+		WriteTimeout:  100 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create TCP recorder: %v", err)
+	}
+
+	// Recording never waits on the network, even once the socket is full
+	details := strings.Repeat("x", 64<<10)
+	start := time.Now()
+	for id := int64(1); id <= 1000; id++ {
+		if err := rec.RecordEvent(Event{ID: id, Timestamp: time.Now(), Type: StatementExecution, Details: details}); err != nil {
+			t.Fatalf("Failed to record event: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected recording to return without waiting on the collector, took %v", elapsed)
+	}
+	if rec.Dropped() == 0 {
+		t.Error("Expected events beyond the buffer to be dropped")
+	}
+
+	// Writes time out, so Close's last attempt gives up instead of hanging
+	start = time.Now()
+	rec.Close()
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected Close to give up after the write timeout, took %v", elapsed)
+	}
+}
+
+func TestNewTCPRecorderUnreachable(t *testing.T) {
+	// Find a port with nothing listening on it
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	if _, err := NewTCPRecorder(addr); err == nil {
+		t.Errorf("Expected error connecting to %s", addr)
+	}
+}
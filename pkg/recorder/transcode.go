@@ -0,0 +1,131 @@
+package recorder
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+)
+
+// Transcode rewrites the recording at srcPath to dstPath with the
+// compression and security of dstOpts, streaming one event at a time so the
+// recording needn't fit in memory. Events, snapshot markers included, and
+// the metadata are copied as they are and in order. The source's
+// compression is detected, so only srcOpts.Security is used to open a
+// secure source; a secure destination is sealed with dstOpts.Security.
+// dstPath is replaced only once every event has been written, and must
+// differ from srcPath.
+//
+// A source with events that can't be opened, such as ones sealed with a
+// different key, is an error rather than a shorter copy. A secure
+// destination declares the event types registered when its metadata is
+// written, which includes those of the source's metadata.
+func Transcode(srcPath, dstPath string, srcOpts, dstOpts FileRecorderOptions) error {
+	for _, security := range []*SecurityOptions{srcOpts.Security, dstOpts.Security} {
+		if security == nil {
+			continue
+		}
+		if err := security.Validate(); err != nil {
+			return fmt.Errorf("invalid security options: %v", err)
+		}
+	}
+
+	md, err := readMetadataFile(srcPath, srcOpts.Security)
+	if err != nil {
+		return err
+	}
+	if md != nil && len(md.EventTypes) > 0 {
+		md.EventTypes = localEventTypes(md.EventTypes)
+	}
+
+	in, err := os.Open(srcPath)
+	if err != nil {
+		return fmt.Errorf("error opening events file: %v", err)
+	}
+	defer in.Close()
+	buffered := bufio.NewReader(in)
+	reader, err := NewCompressedReader(buffered, detectCompression(buffered))
+	if err != nil {
+		return err
+	}
+
+	tmpPath := dstPath + ".tmp"
+	out, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create events file: %v", err)
+	}
+	defer os.Remove(tmpPath) // No-op once renamed
+	defer out.Close()
+	bufWriter := bufio.NewWriter(out)
+
+	var write func(Event) error
+	var finish func() error
+	if dstOpts.Security != nil {
+		writer := NewCompressedWriter(bufWriter, dstOpts.CompressionType)
+		if md != nil {
+			md.EventTypes = RegisteredEventTypes()
+			if err := writeMetadataLine(writer, *md, dstOpts.Security); err != nil {
+				return fmt.Errorf("failed to write recording metadata: %v", err)
+			}
+		}
+		write = NewSecureSink(writer, *dstOpts.Security).WriteEvent
+		finish = func() error { return CloseCompressedWriter(writer, dstOpts.CompressionType) }
+	} else {
+		w := NewWriterRecorder(bufWriter, dstOpts)
+		if md != nil {
+			if err := w.writeMetadata(*md); err != nil {
+				return fmt.Errorf("failed to write recording metadata: %v", err)
+			}
+		}
+		write = w.writeEvent
+		finish = w.Close
+	}
+
+	if srcOpts.Security != nil {
+		// Transcoding copies recordings of any length
+		limits := DefaultDecoderOptions()
+		limits.MaxEvents = 0
+		report, err := scanSecureEvents(reader, *srcOpts.Security, limits, write)
+		if err != nil {
+			return fmt.Errorf("error transcoding events: %v", err)
+		}
+		if report.Dropped() > 0 {
+			return fmt.Errorf("could not read %s: %s", srcPath, report)
+		}
+	} else {
+		// Transcoding copies recordings of any length
+		opts := DefaultDecoderOptions()
+		opts.MaxEvents = 0
+		if err := scanEvents(reader, opts, write); err != nil {
+			return fmt.Errorf("error transcoding events: %v", err)
+		}
+	}
+
+	if err := finish(); err != nil {
+		return err
+	}
+	if err := bufWriter.Flush(); err != nil {
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, dstPath); err != nil {
+		return fmt.Errorf("failed to replace %s: %v", dstPath, err)
+	}
+	return nil
+}
+
+// localEventTypes maps the event types a recording declares to the IDs the
+// same names have in this program, which its events are decoded with
+func localEventTypes(types map[string]EventType) map[string]EventType {
+	local := make(map[string]EventType, len(types))
+	for name, recorded := range types {
+		if recorded < FirstUserEventType {
+			continue
+		}
+		if t, err := registerEventType(name); err == nil {
+			local[name] = t
+		}
+	}
+	return local
+}
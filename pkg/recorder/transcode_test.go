@@ -0,0 +1,97 @@
+package recorder
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// transcodeEvents returns events of every shape a recording holds
+func transcodeEvents() []Event {
+	now := time.Now().UTC()
+	return []Event{
+		{ID: 1, Timestamp: now, Type: FuncEntry, FuncName: "main.main", File: "main.go", Line: 10, GoroutineID: 1},
+		{ID: 2, Timestamp: now.Add(time.Millisecond), Type: VarAssignment, Details: "x = 42", FuncName: "main.main", GoroutineID: 1},
+		{ID: 2, Timestamp: now.Add(2 * time.Millisecond), Type: SnapshotEvent, Details: "Snapshot created"},
+		{ID: 3, Timestamp: now.Add(3 * time.Millisecond), Type: FuncExit, FuncName: "main.main", GoroutineID: 1},
+	}
+}
+
+// fileCompression returns the compression the file at path starts with
+func fileCompression(t *testing.T, path string) CompressionType {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	return detectCompression(bufio.NewReader(f))
+}
+
+func TestTranscodeZstdToGzip(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "chronogo.events")
+	dst := filepath.Join(dir, "chronogo.events.gz")
+	events := transcodeEvents()
+	if err := WriteEventsFile(src, events, FileRecorderOptions{CompressionType: ZstdCompression}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Transcode(src, dst, FileRecorderOptions{}, FileRecorderOptions{CompressionType: GzipCompression}); err != nil {
+		t.Fatalf("Transcode failed: %v", err)
+	}
+	if got := fileCompression(t, dst); got != GzipCompression {
+		t.Errorf("Transcoded file has compression %d, want gzip", got)
+	}
+	got, err := ReadEventsFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, events) {
+		t.Errorf("Transcoded events differ:\n got %+v\nwant %+v", got, events)
+	}
+}
+
+func TestTranscodeSecure(t *testing.T) {
+	dir := t.TempDir()
+	key := keyOptions([]byte("0123456789ABCDEF"))
+	src := filepath.Join(dir, "secure.events")
+	events := transcodeEvents()
+	recordSecure(t, src, key, ZstdCompression, events)
+
+	// Sealed again with gzip, then opened into a plain recording
+	sealed := filepath.Join(dir, "secure.events.gz")
+	if err := Transcode(src, sealed, FileRecorderOptions{Security: &key}, FileRecorderOptions{CompressionType: GzipCompression, Security: &key}); err != nil {
+		t.Fatalf("Transcode failed: %v", err)
+	}
+	if got := fileCompression(t, sealed); got != GzipCompression {
+		t.Errorf("Transcoded file has compression %d, want gzip", got)
+	}
+	plain := filepath.Join(dir, "plain.events")
+	if err := Transcode(sealed, plain, FileRecorderOptions{Security: &key}, FileRecorderOptions{CompressionType: NoCompression}); err != nil {
+		t.Fatalf("Transcode failed: %v", err)
+	}
+
+	got, err := ReadEventsFile(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, events) {
+		t.Errorf("Transcoded events differ:\n got %+v\nwant %+v", got, events)
+	}
+	if md, err := ReadMetadata(plain); err != nil || md == nil {
+		t.Errorf("Metadata wasn't carried over: %v, %v", md, err)
+	}
+
+	// The wrong key fails instead of dropping every event
+	other := keyOptions([]byte("FEDCBA9876543210"))
+	if err := Transcode(src, filepath.Join(dir, "wrong.events"), FileRecorderOptions{Security: &other}, FileRecorderOptions{}); err == nil {
+		t.Error("Transcoding with the wrong key succeeded")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "wrong.events")); !os.IsNotExist(err) {
+		t.Errorf("Failed transcode left a destination file behind: %v", err)
+	}
+}
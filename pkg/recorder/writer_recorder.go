@@ -0,0 +1,296 @@
+package recorder
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrNotSeekable is returned when reading events back from a writer that can't be rewound
+var ErrNotSeekable = errors.New("recorder output is not seekable")
+
+// WriterRecorder records events to an arbitrary io.Writer (a pipe, a network
+// connection, a test buffer) with optional compression. FileRecorder is built
+// on top of it.
+//
+// Events can only be read back with GetEvents or Events if the writer also
+// implements io.ReadSeeker; otherwise Events returns ErrNotSeekable and
+// GetEvents returns nil.
+//
+// A WriterRecorder is safe for concurrent use; each event is written as a
+// whole line.
+type WriterRecorder struct {
+	mu              sync.Mutex // Guards the writers and the event count
+	dest            io.Writer
+	counter         *countingWriter
+	writer          io.Writer
+	bufWriter       *bufio.Writer
+	compressionType CompressionType
+	eventCount      int
+	declared        map[EventType]bool // Registered event types declared in the output
+}
+
+// NewWriterRecorder creates a recorder that writes events to w
+func NewWriterRecorder(w io.Writer, options FileRecorderOptions) *WriterRecorder {
+	counter := &countingWriter{w: w}
+	bufWriter := bufio.NewWriter(counter)
+	return &WriterRecorder{
+		dest:            w,
+		counter:         counter,
+		writer:          NewCompressedWriter(bufWriter, options.CompressionType),
+		bufWriter:       bufWriter,
+		compressionType: options.CompressionType,
+		eventCount:      0,
+	}
+}
+
+// RecordEvent writes an event to the writer with compression
+func (wr *WriterRecorder) RecordEvent(e Event) error {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+
+	if err := wr.writeEvent(e); err != nil {
+		return err
+	}
+
+	// Increment event count
+	wr.eventCount++
+
+	// Check if we need to create a snapshot based on the global interval
+	if SnapshotInterval > 0 && wr.eventCount%SnapshotInterval == 0 {
+		snapshot := CreateSnapshot(e.ID)
+		// Store snapshot metadata with the event
+		// In a real implementation, we would store the actual memory state
+		if err := wr.recordSnapshotEvent(snapshot, wr.eventCount); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeEvent serializes a single event as a JSON line and flushes it. The
+// first event of a registered type not declared in the metadata is
+// preceded by a line declaring the type.
+func (wr *WriterRecorder) writeEvent(e Event) error {
+	if e.Type >= FirstUserEventType && !wr.declared[e.Type] {
+		if name, ok := registeredName(e.Type); ok {
+			if err := wr.writeRecord(eventTypesRecord{EventTypes: map[string]EventType{name: e.Type}}); err != nil {
+				return err
+			}
+			wr.declare(map[string]EventType{name: e.Type})
+		}
+	}
+	return wr.writeRecord(e)
+}
+
+// writeRecord serializes a value as a JSON line and flushes it
+func (wr *WriterRecorder) writeRecord(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	// Write the JSON data
+	if _, err := wr.writer.Write(data); err != nil {
+		return err
+	}
+
+	// Write a newline
+	if _, err := wr.writer.Write([]byte{'\n'}); err != nil {
+		return err
+	}
+
+	// Flush bufWriter to ensure data reaches the underlying writer
+	return wr.bufWriter.Flush()
+}
+
+// writeMetadata writes the metadata record and flushes it. It isn't counted
+// as an event. Callers hold wr.mu or own wr.
+func (wr *WriterRecorder) writeMetadata(md RecordingMetadata) error {
+	if err := writeMetadataLine(wr.writer, md, nil); err != nil {
+		return err
+	}
+	wr.declare(md.EventTypes)
+	return wr.bufWriter.Flush()
+}
+
+// declare notes event types as declared in the output
+func (wr *WriterRecorder) declare(types map[string]EventType) {
+	if wr.declared == nil {
+		wr.declared = make(map[EventType]bool)
+	}
+	for _, t := range types {
+		wr.declared[t] = true
+	}
+}
+
+// recordSnapshotEvent records a snapshot event to the writer
+func (wr *WriterRecorder) recordSnapshotEvent(snapshot Snapshot, eventIdx int) error {
+	// Create a special event to mark the snapshot
+	return wr.writeEvent(Event{
+		ID:        snapshot.ID,
+		Timestamp: CurrentTime(),
+		Type:      SnapshotEvent,
+		Details:   "Snapshot created",
+	})
+}
+
+// finish completes the current compressed stream and flushes all buffered
+// data. Callers hold wr.mu.
+func (wr *WriterRecorder) finish() error {
+	if err := CloseCompressedWriter(wr.writer, wr.compressionType); err != nil {
+		return err
+	}
+	return wr.bufWriter.Flush()
+}
+
+// reopen starts a new compressed stream after finish. Callers hold wr.mu.
+func (wr *WriterRecorder) reopen() {
+	wr.writer = NewCompressedWriter(wr.bufWriter, wr.compressionType)
+}
+
+// BytesWritten returns the number of bytes that have reached the underlying
+// writer, after compression
+func (wr *WriterRecorder) BytesWritten() int64 {
+	return atomic.LoadInt64(&wr.counter.n)
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+// Write writes p to the wrapped writer and counts the bytes written
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	atomic.AddInt64(&cw.n, int64(n))
+	return n, err
+}
+
+// Events reads back all events written so far. The writer must implement
+// io.ReadSeeker, otherwise ErrNotSeekable is returned.
+func (wr *WriterRecorder) Events() ([]Event, error) {
+	rs, ok := wr.dest.(io.ReadSeeker)
+	if !ok {
+		return nil, ErrNotSeekable
+	}
+
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+
+	if err := wr.finish(); err != nil {
+		return nil, err
+	}
+	defer wr.reopen()
+
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	events, err := DecodeEvents(rs, wr.compressionType)
+
+	// Position at the end again so new events are appended
+	if _, seekErr := rs.Seek(0, io.SeekEnd); seekErr != nil && err == nil {
+		err = seekErr
+	}
+	return events, err
+}
+
+// GetEvents reads back all events written so far. It returns nil if the
+// writer isn't seekable; use Events to get the error.
+func (wr *WriterRecorder) GetEvents() []Event {
+	events, err := wr.Events()
+	if err != nil {
+		return nil
+	}
+	return events
+}
+
+// Clear resets the event count. Data already written can't be taken back
+// from an arbitrary writer, so it is left in place.
+func (wr *WriterRecorder) Clear() {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+
+	if err := wr.finish(); err != nil {
+		fmt.Printf("Warning: Error closing compressed writer: %v\n", err)
+	}
+	wr.reopen()
+	wr.eventCount = 0
+}
+
+// Close completes the compressed stream and flushes all buffered data.
+// The underlying writer is not closed.
+func (wr *WriterRecorder) Close() error {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+	return wr.finish()
+}
+
+// DecodeEvents reads newline-delimited JSON events from r, decompressing if
+// necessary, within the DefaultDecoderOptions limits. Lines that can't be
+// parsed are skipped with a warning. A compressed stream that ends
+// mid-block, as it does when the recording program was killed, yields the
+// events before the cut and a warning.
+func DecodeEvents(r io.Reader, compressionType CompressionType) ([]Event, error) {
+	return DecodeEventsWithOptions(r, compressionType, DefaultDecoderOptions())
+}
+
+// DecodeEventsWithOptions reads events like DecodeEvents, within the given limits
+func DecodeEventsWithOptions(r io.Reader, compressionType CompressionType, opts DecoderOptions) ([]Event, error) {
+	reader, err := NewCompressedReader(r, compressionType)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []Event
+	err = scanEvents(reader, opts, func(e Event) error {
+		events = append(events, e)
+		return nil
+	})
+	return events, err
+}
+
+// scanEvents calls fn for each newline-delimited JSON event read from r,
+// stopping at the first error fn returns. Lines that can't be parsed are
+// skipped with a warning, and so is a truncated end of stream.
+func scanEvents(r io.Reader, opts DecoderOptions, fn func(Event) error) error {
+	dec := NewEventDecoder(r, opts)
+	count := 0
+	warned := 0
+	for {
+		event, err := dec.Next()
+		for _, lineErr := range dec.Errors()[warned:] {
+			fmt.Printf("Warning: Could not parse event on %v\n", lineErr)
+		}
+		warned = len(dec.Errors())
+
+		if err != nil {
+			if skipped := dec.Skipped(); skipped > warned {
+				fmt.Printf("Warning: Skipped %d more lines that could not be parsed\n", skipped-warned)
+			}
+			switch {
+			case errors.Is(err, io.EOF):
+				return nil
+			case errors.Is(err, io.ErrUnexpectedEOF):
+				// Everything decoded before the cut is still valid
+				fmt.Printf("Warning: Recording is truncated, recovered %d events\n", count)
+				return nil
+			case errors.Is(err, ErrTooManyEvents):
+				return fmt.Errorf("error reading events: more than %d events", opts.MaxEvents)
+			default:
+				return fmt.Errorf("error reading events: %v", err)
+			}
+		}
+
+		if err := fn(event); err != nil {
+			return err
+		}
+		count++
+	}
+}
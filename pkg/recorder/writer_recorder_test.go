@@ -0,0 +1,84 @@
+package recorder
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriterRecorderBuffer(t *testing.T) {
+	for _, compressionType := range []CompressionType{NoCompression, ZstdCompression} {
+		t.Run(compressionTypeToString(compressionType), func(t *testing.T) {
+			var buf bytes.Buffer
+			rec := NewWriterRecorder(&buf, FileRecorderOptions{CompressionType: compressionType})
+
+			testEvents := []Event{
+				{ID: 1, Timestamp: time.Now(), Type: FuncEntry, Details: "Entering main", FuncName: "main"},
+				{ID: 2, Timestamp: time.Now(), Type: FuncExit, Details: "Exiting main", FuncName: "main"},
+			}
+			for _, e := range testEvents {
+				if err := rec.RecordEvent(e); err != nil {
+					t.Fatalf("Failed to record event: %v", err)
+				}
+			}
+
+			// A bytes.Buffer can't be rewound, so events can't be read back through the recorder
+			if _, err := rec.Events(); err != ErrNotSeekable {
+				t.Errorf("Expected ErrNotSeekable, got %v", err)
+			}
+			if events := rec.GetEvents(); events != nil {
+				t.Errorf("Expected nil events from non-seekable writer, got %d", len(events))
+			}
+
+			if err := rec.Close(); err != nil {
+				t.Fatalf("Failed to close recorder: %v", err)
+			}
+
+			events, err := DecodeEvents(&buf, compressionType)
+			if err != nil {
+				t.Fatalf("Failed to decode events: %v", err)
+			}
+			if len(events) != len(testEvents) {
+				t.Fatalf("Expected %d events, got %d", len(testEvents), len(events))
+			}
+			for i, e := range events {
+				if e.ID != testEvents[i].ID || e.Details != testEvents[i].Details {
+					t.Errorf("Event %d mismatch: expected %+v, got %+v", i, testEvents[i], e)
+				}
+			}
+		})
+	}
+}
+
+func TestWriterRecorderSeekable(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "events.log"))
+	if err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	defer f.Close()
+
+	rec := NewWriterRecorder(f, DefaultFileRecorderOptions())
+	for i := 1; i <= 3; i++ {
+		if err := rec.RecordEvent(Event{ID: int64(i), Timestamp: time.Now(), Type: StatementExecution}); err != nil {
+			t.Fatalf("Failed to record event: %v", err)
+		}
+	}
+
+	events, err := rec.Events()
+	if err != nil {
+		t.Fatalf("Failed to read events: %v", err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 events, got %d", len(events))
+	}
+
+	// Recording continues after reading back
+	if err := rec.RecordEvent(Event{ID: 4, Timestamp: time.Now(), Type: StatementExecution}); err != nil {
+		t.Fatalf("Failed to record event: %v", err)
+	}
+	if events := rec.GetEvents(); len(events) != 4 {
+		t.Errorf("Expected 4 events, got %d", len(events))
+	}
+}
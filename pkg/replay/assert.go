@@ -0,0 +1,120 @@
+package replay
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+// EventMatcher describes an event expected by AssertEventSequence. Empty
+// fields match anything.
+type EventMatcher struct {
+	Type    recorder.EventType
+	AnyType bool   // Match events of every type, ignoring Type
+	Func    string // Function name, whole or after its package, e.g. processData for main.processData
+	Details string // Substring of the details
+}
+
+// Entered matches entering a function
+func Entered(funcName string) EventMatcher {
+	return EventMatcher{Type: recorder.FuncEntry, Func: funcName}
+}
+
+// Exited matches exiting a function
+func Exited(funcName string) EventMatcher {
+	return EventMatcher{Type: recorder.FuncExit, Func: funcName}
+}
+
+// Matches reports whether e matches m
+func (m EventMatcher) Matches(e recorder.Event) bool {
+	if !m.AnyType && e.Type != m.Type {
+		return false
+	}
+	if m.Func != "" && e.FuncName != m.Func && !strings.HasSuffix(e.FuncName, "."+m.Func) {
+		return false
+	}
+	return strings.Contains(e.Details, m.Details)
+}
+
+func (m EventMatcher) String() string {
+	var parts []string
+	if m.AnyType {
+		parts = append(parts, "any event")
+	} else {
+		parts = append(parts, m.Type.String())
+	}
+	if m.Func != "" {
+		parts = append(parts, "in "+m.Func)
+	}
+	if m.Details != "" {
+		parts = append(parts, fmt.Sprintf("with details containing %q", m.Details))
+	}
+	return strings.Join(parts, " ")
+}
+
+// MatchEventSequence finds the events matching want in order, allowing any
+// other events between them. It returns the index in got of each match,
+// and the index in want of the first matcher with no match after the
+// previous one, or -1 if all of them matched.
+func MatchEventSequence(got []recorder.Event, want []EventMatcher) (matched []int, missing int) {
+	next := 0
+	for i, m := range want {
+		for next < len(got) && !m.Matches(got[next]) {
+			next++
+		}
+		if next == len(got) {
+			return matched, i
+		}
+		matched = append(matched, next)
+		next++
+	}
+	return matched, -1
+}
+
+// AssertEventSequence fails the test unless got contains events matching
+// want in that order, allowing any other events between them, e.g. that A
+// was entered, then a channel send happened, then B exited:
+//
+//	replay.AssertEventSequence(t, rec.GetEvents(), []replay.EventMatcher{
+//		replay.Entered("A"),
+//		{Type: recorder.ChannelOperation, Details: "send"},
+//		replay.Exited("B"),
+//	})
+//
+// A failure names the first expected event not found, where the events
+// before it matched and, if it only happened earlier, where that was.
+func AssertEventSequence(t testing.TB, got []recorder.Event, want []EventMatcher) {
+	t.Helper()
+	matched, missing := MatchEventSequence(got, want)
+	if missing < 0 {
+		return
+	}
+
+	var msg strings.Builder
+	after := -1
+	if missing > 0 {
+		after = matched[missing-1]
+		fmt.Fprintf(&msg, "expected %s after %s at event %d", want[missing], want[missing-1], after)
+	} else {
+		fmt.Fprintf(&msg, "expected %s", want[missing])
+	}
+	earlier := -1
+	for i := 0; i <= after; i++ {
+		if want[missing].Matches(got[i]) {
+			earlier = i
+			break
+		}
+	}
+	if earlier >= 0 {
+		fmt.Fprintf(&msg, ", but it only happened before, at event %d", earlier)
+	} else {
+		msg.WriteString(", but it never happened")
+	}
+	for i, idx := range matched {
+		fmt.Fprintf(&msg, "\n  matched %s at event %d", want[i], idx)
+	}
+	fmt.Fprintf(&msg, "\n  in %d events", len(got))
+	t.Errorf("%s", msg.String())
+}
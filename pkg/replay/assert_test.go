@@ -0,0 +1,112 @@
+package replay
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+// recordingTB captures the failures of an assertion instead of failing the test
+type recordingTB struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertEventSequence(t *testing.T) {
+	events := []recorder.Event{
+		{Type: recorder.FuncEntry, FuncName: "main.main"},
+		{Type: recorder.FuncEntry, FuncName: "main.producer"},
+		{Type: recorder.ChannelOperation, FuncName: "main.producer", Details: "Channel 1: send by goroutine 2, value: 42"},
+		{Type: recorder.FuncExit, FuncName: "main.producer"},
+		{Type: recorder.FuncEntry, FuncName: "main.(*worker).consume"},
+		{Type: recorder.ChannelOperation, FuncName: "main.(*worker).consume", Details: "Channel 1: receive by goroutine 3, value: 42"},
+		{Type: recorder.FuncExit, FuncName: "main.(*worker).consume"},
+	}
+
+	tests := []struct {
+		name  string
+		want  []EventMatcher
+		error string // Substring of the failure, empty if it should pass
+	}{
+		{
+			name: "in order with gaps",
+			want: []EventMatcher{
+				Entered("producer"),
+				{Type: recorder.ChannelOperation, Details: "send"},
+				Exited("(*worker).consume"),
+			},
+		},
+		{
+			name: "any type",
+			want: []EventMatcher{{AnyType: true, Details: "receive"}, {AnyType: true}},
+		},
+		{
+			name: "whole function name",
+			want: []EventMatcher{Entered("main.main")},
+		},
+		{
+			name:  "out of order",
+			want:  []EventMatcher{Exited("producer"), {Type: recorder.ChannelOperation, Details: "send"}},
+			error: `expected ChannelOperation with details containing "send" after FunctionExit in producer at event 3, but it only happened before, at event 2`,
+		},
+		{
+			name:  "never happened",
+			want:  []EventMatcher{Entered("main"), Entered("consumer")},
+			error: "expected FunctionEntry in consumer after FunctionEntry in main at event 0, but it never happened",
+		},
+		{
+			name:  "first missing",
+			want:  []EventMatcher{{Type: recorder.ErrorEvent}},
+			error: "expected ErrorEvent, but it never happened",
+		},
+		{
+			name:  "package suffix only",
+			want:  []EventMatcher{Entered("ain")},
+			error: "expected FunctionEntry in ain",
+		},
+		{
+			name:  "matched repeated once",
+			want:  []EventMatcher{Entered("producer"), Entered("producer")},
+			error: "but it only happened before, at event 1\n  matched FunctionEntry in producer at event 1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tb := &recordingTB{TB: t}
+			AssertEventSequence(tb, events, tt.want)
+			switch {
+			case tt.error == "" && len(tb.errors) > 0:
+				t.Errorf("unexpected failure: %s", tb.errors[0])
+			case tt.error != "" && len(tb.errors) == 0:
+				t.Errorf("passed, want a failure containing %q", tt.error)
+			case tt.error != "" && !strings.Contains(tb.errors[0], tt.error):
+				t.Errorf("failure %q, want it to contain %q", tb.errors[0], tt.error)
+			}
+		})
+	}
+}
+
+func TestMatchEventSequence(t *testing.T) {
+	events := []recorder.Event{
+		{Type: recorder.FuncEntry, FuncName: "main.a"},
+		{Type: recorder.FuncEntry, FuncName: "main.b"},
+		{Type: recorder.FuncExit, FuncName: "main.b"},
+		{Type: recorder.FuncExit, FuncName: "main.a"},
+	}
+	matched, missing := MatchEventSequence(events, []EventMatcher{Entered("a"), Exited("a")})
+	if missing != -1 || fmt.Sprint(matched) != "[0 3]" {
+		t.Errorf("MatchEventSequence = %v, %d, want [0 3], -1", matched, missing)
+	}
+	matched, missing = MatchEventSequence(events, []EventMatcher{Exited("a"), Exited("b")})
+	if missing != 1 || fmt.Sprint(matched) != "[3]" {
+		t.Errorf("MatchEventSequence = %v, %d, want [3], 1", matched, missing)
+	}
+}
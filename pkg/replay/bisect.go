@@ -0,0 +1,190 @@
+package replay
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+// Predicate reports whether a condition holds at the event at idx
+type Predicate func(events []recorder.Event, idx int) bool
+
+// predicatePattern matches a comparison of a variable or an event field
+// with a value, e.g. var total > 1000 or event.func == "main.handle"
+var predicatePattern = regexp.MustCompile(`^\s*(var\s+\S+|event\.\w+)\s*(==|!=|<=|>=|<|>)\s*(.+?)\s*$`)
+
+// ParsePredicate parses a comparison of a tracked variable or a field of
+// the current event with a value:
+//
+//	var total > 1000
+//	event.func == "main.handleLargeResult"
+//
+// A variable's value at an event is the last one assigned to it at or
+// before the event, in any function; before its first assignment the
+// predicate doesn't hold. Event fields are id, type, func, file, line,
+// goroutine and details. Values that parse as numbers on both sides are
+// compared as numbers, everything else as strings. String values may be
+// quoted.
+func ParsePredicate(expr string) (Predicate, error) {
+	m := predicatePattern.FindStringSubmatch(expr)
+	if m == nil {
+		return nil, fmt.Errorf("invalid predicate %q, expected var <name> <op> <value> or event.<field> <op> <value>", expr)
+	}
+	subject, op, want := m[1], m[2], m[3]
+	if unquoted, err := strconv.Unquote(want); err == nil {
+		want = unquoted
+	}
+
+	var value func(events []recorder.Event, idx int) (string, bool)
+	if strings.HasPrefix(subject, "var") {
+		name := strings.TrimSpace(strings.TrimPrefix(subject, "var"))
+		value = func(events []recorder.Event, idx int) (string, bool) {
+			return valueAt(events, idx, name)
+		}
+	} else {
+		field := strings.TrimPrefix(subject, "event.")
+		if _, ok := eventField(recorder.Event{}, field); !ok {
+			return nil, fmt.Errorf("unknown event field %q, use id, type, func, file, line, goroutine or details", field)
+		}
+		value = func(events []recorder.Event, idx int) (string, bool) {
+			return eventField(events[idx], field)
+		}
+	}
+
+	return func(events []recorder.Event, idx int) bool {
+		if idx < 0 || idx >= len(events) {
+			return false
+		}
+		got, ok := value(events, idx)
+		return ok && compare(got, op, want)
+	}, nil
+}
+
+// valueAt returns the last value assigned to a variable at or before idx
+func valueAt(events []recorder.Event, idx int, name string) (string, bool) {
+	for i := idx; i >= 0; i-- {
+		if events[i].Type != recorder.VarAssignment {
+			continue
+		}
+		if n, v, ok := ParseAssignment(events[i].Details); ok && n == name {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// eventField returns a field of an event by its predicate name
+func eventField(e recorder.Event, field string) (string, bool) {
+	switch field {
+	case "id":
+		return strconv.FormatInt(e.ID, 10), true
+	case "type":
+		return e.Type.String(), true
+	case "func":
+		return e.FuncName, true
+	case "file":
+		return e.File, true
+	case "line":
+		return strconv.Itoa(e.Line), true
+	case "goroutine":
+		return strconv.Itoa(e.GoroutineID), true
+	case "details":
+		return e.Details, true
+	}
+	return "", false
+}
+
+// compare applies a comparison operator, numerically if both sides are numbers
+func compare(got, op, want string) bool {
+	g, gErr := strconv.ParseFloat(got, 64)
+	w, wErr := strconv.ParseFloat(want, 64)
+	if gErr != nil || wErr != nil {
+		c := strings.Compare(got, want)
+		g, w = float64(c), 0
+	}
+	switch op {
+	case "==":
+		return g == w
+	case "!=":
+		return g != w
+	case "<":
+		return g < w
+	case "<=":
+		return g <= w
+	case ">":
+		return g > w
+	case ">=":
+		return g >= w
+	}
+	return false
+}
+
+// bisectSamples is the number of evenly spaced events Bisect checks to
+// decide whether a predicate is monotonic
+const bisectSamples = 32
+
+// Bisect returns the first index at which pred holds, or -1 if it never
+// does. A predicate that stays true once it becomes true is binary
+// searched. Bisect samples the events to check that; if a sample after one
+// where pred holds is false, or pred holds at no sample at all, it scans
+// every event in order instead and reports the predicate as not monotonic
+// if it holds anywhere. Sampling can miss a predicate that
+// holds only briefly between two samples where it is true, in which case
+// the binary search may return a later match than the first.
+func Bisect(events []recorder.Event, pred Predicate) (idx int, monotonic bool) {
+	n := len(events)
+	if n == 0 {
+		return -1, true
+	}
+
+	// Sample evenly, always including the last event
+	lo, hi := -1, -1 // Last false sample before the first true one, first true sample
+	for s := 0; s < bisectSamples; s++ {
+		i := (n - 1) * s / (bisectSamples - 1)
+		if bisectSamples > n {
+			if s >= n {
+				break
+			}
+			i = s
+		}
+		if pred(events, i) {
+			if hi < 0 {
+				hi = i
+			}
+		} else if hi >= 0 {
+			// False after true: binary search could land anywhere
+			return scan(events, pred), false
+		} else {
+			lo = i
+		}
+	}
+	if hi < 0 {
+		// False at the end, but it may hold in between
+		idx := scan(events, pred)
+		return idx, idx < 0
+	}
+
+	// The first match is in (lo, hi]
+	for hi-lo > 1 {
+		mid := lo + (hi-lo)/2
+		if pred(events, mid) {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+	return hi, true
+}
+
+// scan returns the first index at which pred holds, checking every event
+func scan(events []recorder.Event, pred Predicate) int {
+	for i := range events {
+		if pred(events, i) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,48 @@
+package replay
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+func TestBisect(t *testing.T) {
+	// total grows by 10 per iteration, with statements in between
+	var events []recorder.Event
+	for i := 1; i <= 200; i++ {
+		events = append(events,
+			recorder.Event{ID: int64(2 * i), Type: recorder.StatementExecution, FuncName: "main.sum", File: "main.go", Line: 10},
+			recorder.Event{ID: int64(2*i + 1), Type: recorder.VarAssignment, FuncName: "main.sum", Details: fmt.Sprintf("total = %d", 10*i)},
+		)
+	}
+	events[250].FuncName = "main.handleLargeResult"
+
+	tests := []struct {
+		expr      string
+		want      int
+		monotonic bool
+	}{
+		{"var total > 1000", 201, true}, // total = 1010
+		{"var total >= 10", 1, true},
+		{"var total > 5000", -1, true},
+		{`event.func == "main.handleLargeResult"`, 250, false},
+		{"event.type == VariableAssignment", 1, false},
+	}
+	for _, tt := range tests {
+		pred, err := ParsePredicate(tt.expr)
+		if err != nil {
+			t.Fatalf("ParsePredicate(%q) failed: %v", tt.expr, err)
+		}
+		idx, monotonic := Bisect(events, pred)
+		if idx != tt.want || monotonic != tt.monotonic {
+			t.Errorf("Bisect(%q) = %d, %v; expected %d, %v", tt.expr, idx, monotonic, tt.want, tt.monotonic)
+		}
+	}
+
+	for _, expr := range []string{"total > 1000", "event.color == red", "var total"} {
+		if _, err := ParsePredicate(expr); err == nil {
+			t.Errorf("Expected %q to be rejected", expr)
+		}
+	}
+}
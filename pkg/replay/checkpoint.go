@@ -0,0 +1,128 @@
+package replay
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// CheckpointID identifies a checkpoint taken with BasicReplayer.Checkpoint
+type CheckpointID int
+
+// CheckpointInfo describes a checkpoint
+type CheckpointInfo struct {
+	ID      CheckpointID
+	Index   int       // Current event when the checkpoint was taken, -1 before the first
+	Created time.Time // When the checkpoint was taken
+}
+
+// replayCheckpoint holds a copy of the replay state at a position
+type replayCheckpoint struct {
+	CheckpointInfo
+	goroutines      map[int]*GoroutineState
+	channels        map[int]*ChannelState
+	activeGoroutine int
+}
+
+// Checkpoint remembers the current position and goroutine and channel state
+// so that Restore can return to it without replaying the events in between.
+// Unlike recorded SnapshotEvents, checkpoints are only kept in memory and
+// are dropped when new events are loaded.
+func (r *BasicReplayer) Checkpoint() CheckpointID {
+	r.nextCheckpoint++
+	id := CheckpointID(r.nextCheckpoint)
+	r.checkpoints = append(r.checkpoints, replayCheckpoint{
+		CheckpointInfo:  CheckpointInfo{ID: id, Index: r.currentIdx, Created: time.Now()},
+		goroutines:      copyGoroutines(r.goroutines),
+		channels:        copyChannels(r.channels),
+		activeGoroutine: r.activeGoroutine,
+	})
+	return id
+}
+
+// Restore returns to a checkpoint by copying back its state
+func (r *BasicReplayer) Restore(id CheckpointID) error {
+	for _, cp := range r.checkpoints {
+		if cp.ID != id {
+			continue
+		}
+		r.currentIdx = cp.Index
+		r.goroutines = copyGoroutines(cp.goroutines)
+		r.channels = copyChannels(cp.channels)
+		r.activeGoroutine = cp.activeGoroutine
+		return nil
+	}
+	return fmt.Errorf("no checkpoint %d", id)
+}
+
+// Checkpoints lists the checkpoints in the order they were taken
+func (r *BasicReplayer) Checkpoints() []CheckpointInfo {
+	infos := make([]CheckpointInfo, len(r.checkpoints))
+	for i, cp := range r.checkpoints {
+		infos[i] = cp.CheckpointInfo
+	}
+	return infos
+}
+
+// stateSnapshot holds a copy of the goroutine and channel state right after
+// the recorded SnapshotEvent at index
+type stateSnapshot struct {
+	index           int
+	goroutines      map[int]*GoroutineState
+	channels        map[int]*ChannelState
+	activeGoroutine int
+}
+
+// rememberSnapshot keeps a copy of the current state as of the event at idx,
+// so moving backward past it doesn't replay from the start
+func (r *BasicReplayer) rememberSnapshot(idx int) {
+	pos := sort.Search(len(r.snapshots), func(i int) bool { return r.snapshots[i].index >= idx })
+	if pos < len(r.snapshots) && r.snapshots[pos].index == idx {
+		return
+	}
+	r.snapshots = append(r.snapshots, stateSnapshot{})
+	copy(r.snapshots[pos+1:], r.snapshots[pos:])
+	r.snapshots[pos] = stateSnapshot{
+		index:           idx,
+		goroutines:      copyGoroutines(r.goroutines),
+		channels:        copyChannels(r.channels),
+		activeGoroutine: r.activeGoroutine,
+	}
+}
+
+// rewind restores the state of the nearest snapshot at or before idx, or
+// the initial state if there is none, and returns the index of the first
+// event still to be applied to reach idx
+func (r *BasicReplayer) rewind(idx int) int {
+	pos := sort.Search(len(r.snapshots), func(i int) bool { return r.snapshots[i].index > idx })
+	if pos == 0 {
+		r.resetConcurrencyState()
+		return 0
+	}
+	snap := r.snapshots[pos-1]
+	r.goroutines = copyGoroutines(snap.goroutines)
+	r.channels = copyChannels(snap.channels)
+	r.activeGoroutine = snap.activeGoroutine
+	return snap.index + 1
+}
+
+// copyGoroutines returns a deep copy of goroutine states
+func copyGoroutines(goroutines map[int]*GoroutineState) map[int]*GoroutineState {
+	copied := make(map[int]*GoroutineState, len(goroutines))
+	for id, g := range goroutines {
+		state := *g
+		copied[id] = &state
+	}
+	return copied
+}
+
+// copyChannels returns a deep copy of channel states
+func copyChannels(channels map[int]*ChannelState) map[int]*ChannelState {
+	copied := make(map[int]*ChannelState, len(channels))
+	for id, ch := range channels {
+		state := *ch
+		state.Messages = append([]interface{}(nil), ch.Messages...)
+		copied[id] = &state
+	}
+	return copied
+}
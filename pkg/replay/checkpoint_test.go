@@ -0,0 +1,79 @@
+package replay
+
+import (
+	"testing"
+	"time"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+func TestCheckpoints(t *testing.T) {
+	base := time.Now()
+	details := []string{
+		"Goroutine 2 created",
+		"Goroutine switch from 1 to 2",
+		"Channel 1: send by goroutine 2",
+		"Channel 1: closed by goroutine 2",
+		"Goroutine switch from 2 to 1",
+	}
+	types := []recorder.EventType{recorder.GoroutineSwitch, recorder.GoroutineSwitch, recorder.ChannelOperation,
+		recorder.ChannelOperation, recorder.GoroutineSwitch}
+	var events []recorder.Event
+	for i, d := range details {
+		events = append(events, recorder.Event{ID: int64(i + 1), Timestamp: base.Add(time.Duration(i) * time.Millisecond), Type: types[i], Details: d})
+	}
+
+	replayer := NewBasicReplayer()
+	if err := replayer.LoadEvents(events); err != nil {
+		t.Fatalf("Failed to load events: %v", err)
+	}
+	replayer.ReplayToEventIndex(2)
+	first := replayer.Checkpoint()
+
+	// Move on: channel 1 closes and goroutine 1 runs again
+	replayer.ReplayToEventIndex(4)
+	second := replayer.Checkpoint()
+	if replayer.ActiveGoroutine() != 1 || !replayer.channels[1].Closed {
+		t.Fatalf("Expected goroutine 1 active and channel 1 closed at the end")
+	}
+
+	if err := replayer.Restore(first); err != nil {
+		t.Fatalf("Failed to restore: %v", err)
+	}
+	if replayer.CurrentIndex() != 2 || replayer.ActiveGoroutine() != 2 || replayer.channels[1].Closed {
+		t.Errorf("Expected event 2 with goroutine 2 active and channel 1 open, got event %d, goroutine %d, closed %v",
+			replayer.CurrentIndex(), replayer.ActiveGoroutine(), replayer.channels[1].Closed)
+	}
+
+	// Replaying on from a restored checkpoint must not change it
+	replayer.ReplayToEventIndex(3)
+	if err := replayer.Restore(first); err != nil {
+		t.Fatalf("Failed to restore again: %v", err)
+	}
+	if replayer.channels[1].Closed {
+		t.Error("Expected the checkpoint's state to be unaffected by replaying after restoring it")
+	}
+
+	if err := replayer.Restore(second); err != nil || replayer.CurrentIndex() != 4 {
+		t.Errorf("Expected to restore event 4, got %d (%v)", replayer.CurrentIndex(), err)
+	}
+	if err := replayer.Restore(42); err == nil {
+		t.Error("Expected an error restoring an unknown checkpoint")
+	}
+
+	infos := replayer.Checkpoints()
+	if len(infos) != 2 || infos[0].ID != first || infos[0].Index != 2 || infos[1].Index != 4 {
+		t.Errorf("Unexpected checkpoints: %+v", infos)
+	}
+
+	// Loading new events drops them
+	if err := replayer.LoadEvents(events); err != nil {
+		t.Fatalf("Failed to load events: %v", err)
+	}
+	if len(replayer.Checkpoints()) != 0 {
+		t.Error("Expected loading events to drop the checkpoints")
+	}
+	if replayer.Checkpoint() == first {
+		t.Error("Expected checkpoint IDs not to be reused")
+	}
+}
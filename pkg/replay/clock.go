@@ -0,0 +1,68 @@
+package replay
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+// Clock serves the wall clock readings recorded by instrumentation.Now in
+// order, so time-dependent logic takes the same branches on re-execution.
+// Once the recorded readings run out, Clock falls back to the real clock and
+// reports the divergence through Diverged.
+type Clock struct {
+	mu       sync.Mutex
+	times    []time.Time
+	pos      int
+	diverged bool
+}
+
+// ClockFromEvents builds a Clock from the TimeReadEvents in events
+func ClockFromEvents(events []recorder.Event) *Clock {
+	c := &Clock{}
+	for _, e := range events {
+		if e.Type != recorder.TimeReadEvent {
+			continue
+		}
+
+		ts, err := time.Parse(time.RFC3339Nano, strings.TrimPrefix(e.Details, "Time read "))
+		if err != nil {
+			fmt.Printf("Warning: Could not parse time read from %s: %v\n", e.Details, err)
+			continue
+		}
+		c.times = append(c.times, ts)
+	}
+	return c
+}
+
+// Now returns the next recorded wall clock reading
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.pos < len(c.times) {
+		t := c.times[c.pos]
+		c.pos++
+		return t
+	}
+
+	c.diverged = true
+	return time.Now()
+}
+
+// Remaining returns the number of recorded readings not yet served
+func (c *Clock) Remaining() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.times) - c.pos
+}
+
+// Diverged reports whether the program read the clock more often than it did while recording
+func (c *Clock) Diverged() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.diverged
+}
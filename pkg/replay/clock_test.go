@@ -0,0 +1,76 @@
+package replay
+
+import (
+	"testing"
+	"time"
+
+	"github.com/willibrandon/ChronoGo/pkg/instrumentation"
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+// billingPeriod branches on the observed time, like logic that behaves
+// differently depending on when it runs
+func billingPeriod(now func() time.Time) string {
+	start := now()
+	if start.Nanosecond()%2 == 0 {
+		return "even"
+	}
+	return "odd"
+}
+
+func TestClockFromEvents(t *testing.T) {
+	rec := recorder.NewInMemoryRecorder()
+	instrumentation.InitInstrumentation(rec)
+	defer instrumentation.InitInstrumentation(nil)
+
+	// Record several runs so both branches are likely to be observed
+	var recorded []string
+	for i := 0; i < 5; i++ {
+		recorded = append(recorded, billingPeriod(instrumentation.Now))
+	}
+
+	events := rec.GetEvents()
+	if len(events) != 5 {
+		t.Fatalf("Expected 5 time read events, got %d", len(events))
+	}
+	for _, e := range events {
+		if e.Type != recorder.TimeReadEvent {
+			t.Errorf("Expected TimeReadEvent, got %s", e.Type)
+		}
+	}
+
+	// Re-execution follows the same branches
+	clock := ClockFromEvents(events)
+	for i, want := range recorded {
+		if got := billingPeriod(clock.Now); got != want {
+			t.Errorf("Run %d: expected branch %s, got %s", i, want, got)
+		}
+	}
+	if clock.Diverged() {
+		t.Error("Expected no divergence")
+	}
+	if clock.Remaining() != 0 {
+		t.Errorf("Expected all readings consumed, %d remaining", clock.Remaining())
+	}
+
+	// Reading the clock past the recording falls back to the real clock
+	if clock.Now().IsZero() {
+		t.Error("Expected fallback to the real clock")
+	}
+	if !clock.Diverged() {
+		t.Error("Expected divergence after exhausting recorded readings")
+	}
+}
+
+func TestClockServesExactTimestamps(t *testing.T) {
+	ts := time.Date(2024, 2, 29, 23, 59, 59, 999999999, time.UTC)
+	events := []recorder.Event{
+		{Type: recorder.FuncEntry, Details: "Entering main"},
+		{Type: recorder.TimeReadEvent, Details: "Time read " + ts.Format(instrumentation.TimeFormat)},
+	}
+
+	clock := ClockFromEvents(events)
+	if got := clock.Now(); !got.Equal(ts) {
+		t.Errorf("Expected %v, got %v", ts, got)
+	}
+}
@@ -0,0 +1,102 @@
+package replay
+
+import (
+	"sort"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+// ReplayContext is everything known about the current replay position.
+// Indices are into the loaded events; add Offset for indices into the
+// recording a window was loaded from.
+type ReplayContext struct {
+	Index     int              // Current event, -1 before the first
+	Offset    int              // Index in the recording of the first loaded event
+	Event     *recorder.Event  `json:",omitempty"` // Current event, nil before the first
+	Goroutine int              // Goroutine that ran the current event, 0 if unknown
+	CallStack []recorder.Event // Active calls, outermost first
+	Locals    []Local          // Variables assigned so far in the current call
+	Snapshot  int              // Last recorded SnapshotEvent at or before Index, -1 if none
+	// Indices where replay stopped on a watchpoint this session
+	WatchpointHits []int `json:",omitempty"`
+	// Enabled breakpoints and watchpoints, described by the debugger
+	// driving the replay; a replayer leaves it empty
+	Breakpoints []string `json:",omitempty"`
+}
+
+// Local is the latest value assigned to a variable in a call
+type Local struct {
+	Name     string
+	Value    string
+	EventIdx int // Index of the assignment
+}
+
+// Context returns the current replay position with its call stack, the
+// variables assigned in the current call and the nearest snapshot
+func (r *BasicReplayer) Context() ReplayContext {
+	ctx := ReplayContext{
+		Index:          r.currentIdx,
+		Snapshot:       -1,
+		WatchpointHits: r.WatchpointHits(),
+	}
+	ctx.Offset, _ = r.Window()
+	if r.currentIdx < 0 || r.currentIdx >= len(r.events) {
+		return ctx
+	}
+
+	event := r.events[r.currentIdx]
+	ctx.Event = &event
+	ctx.Goroutine = r.GoroutineAt(r.currentIdx)
+	ctx.CallStack = CallStack(r.events, r.currentIdx)
+	ctx.Locals = Locals(r.events, r.currentIdx)
+	for i := r.currentIdx; i >= 0; i-- {
+		if r.events[i].Type == recorder.SnapshotEvent {
+			ctx.Snapshot = i
+			break
+		}
+	}
+	return ctx
+}
+
+// Locals returns the latest value of each variable assigned in the call
+// running at idx, by name: the assignments made in the function of the
+// event at idx since its most recent entry, on the same goroutine
+func Locals(events []recorder.Event, idx int) []Local {
+	if idx < 0 || idx >= len(events) {
+		return nil
+	}
+	funcName := events[idx].FuncName
+	if funcName == "" {
+		stack := CallStack(events, idx)
+		if len(stack) == 0 {
+			return nil
+		}
+		funcName = stack[len(stack)-1].FuncName
+	}
+	goroutine := events[idx].GoroutineID
+
+	latest := make(map[string]Local)
+	for i := idx; i >= 0; i-- {
+		e := events[i]
+		if e.FuncName != funcName || (goroutine != 0 && e.GoroutineID != 0 && e.GoroutineID != goroutine) {
+			continue
+		}
+		if e.Type == recorder.FuncEntry {
+			break
+		}
+		if e.Type != recorder.VarAssignment {
+			continue
+		}
+		name, value, ok := ParseAssignment(e.Details)
+		if _, seen := latest[name]; ok && !seen {
+			latest[name] = Local{Name: name, Value: value, EventIdx: i}
+		}
+	}
+
+	locals := make([]Local, 0, len(latest))
+	for _, local := range latest {
+		locals = append(locals, local)
+	}
+	sort.Slice(locals, func(i, j int) bool { return locals[i].Name < locals[j].Name })
+	return locals
+}
@@ -0,0 +1,53 @@
+package replay
+
+import (
+	"testing"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+func TestReplayContext(t *testing.T) {
+	events := []recorder.Event{
+		{ID: 1, Type: recorder.FuncEntry, FuncName: "main.main", File: "main.go", Line: 5, GoroutineID: 1},
+		{ID: 2, Type: recorder.VarAssignment, FuncName: "main.main", Details: "total := 0", GoroutineID: 1},
+		{ID: 3, Type: recorder.SnapshotEvent, Details: "Snapshot created"},
+		{ID: 4, Type: recorder.FuncEntry, FuncName: "main.add", File: "main.go", Line: 12, GoroutineID: 1},
+		{ID: 5, Type: recorder.VarAssignment, FuncName: "main.add", Details: "sum = 1", GoroutineID: 1},
+		{ID: 6, Type: recorder.VarAssignment, FuncName: "main.add", Details: "sum = 3", GoroutineID: 1},
+		{ID: 7, Type: recorder.VarAssignment, FuncName: "main.add", Details: "n = 2", GoroutineID: 1},
+		{ID: 8, Type: recorder.StatementExecution, FuncName: "main.add", File: "main.go", Line: 14, GoroutineID: 1},
+	}
+	replayer := NewBasicReplayer()
+	if err := replayer.LoadEvents(events); err != nil {
+		t.Fatalf("Failed to load events: %v", err)
+	}
+
+	if ctx := replayer.Context(); ctx.Index != -1 || ctx.Event != nil || ctx.Snapshot != -1 {
+		t.Errorf("Expected no current event before the first step, got %+v", ctx)
+	}
+
+	replayer.ReplayToEventIndex(7)
+	ctx := replayer.Context()
+	if ctx.Index != 7 || ctx.Event == nil || ctx.Event.ID != 8 {
+		t.Fatalf("Expected event 7 to be current, got %+v", ctx)
+	}
+	if ctx.Goroutine != 1 {
+		t.Errorf("Expected goroutine 1, got %d", ctx.Goroutine)
+	}
+	if len(ctx.CallStack) != 2 || ctx.CallStack[0].FuncName != "main.main" || ctx.CallStack[1].FuncName != "main.add" {
+		t.Errorf("Expected main.add called from main.main, got %+v", ctx.CallStack)
+	}
+	// Only add's variables, each with its latest value
+	want := []Local{{Name: "n", Value: "2", EventIdx: 6}, {Name: "sum", Value: "3", EventIdx: 5}}
+	if len(ctx.Locals) != len(want) {
+		t.Fatalf("Expected locals %+v, got %+v", want, ctx.Locals)
+	}
+	for i := range want {
+		if ctx.Locals[i] != want[i] {
+			t.Errorf("Expected local %+v, got %+v", want[i], ctx.Locals[i])
+		}
+	}
+	if ctx.Snapshot != 2 {
+		t.Errorf("Expected the snapshot at 2, got %d", ctx.Snapshot)
+	}
+}
@@ -0,0 +1,193 @@
+package replay
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+// CoverageSummary describes a coverage profile written by a CoverageCollector
+type CoverageSummary struct {
+	Files   int // Source files in the profile
+	Skipped int // Recorded files that couldn't be read or parsed
+	Blocks  int // Statement blocks in the profile
+	Covered int // Blocks that ran
+}
+
+// CoverageCollector gathers the source lines a recording executed, so they
+// can be written as a Go coverage profile. A recording doubles as an
+// execution trace: what a production run actually executed can be seen
+// with "go tool cover -html" without rebuilding it with -cover.
+type CoverageCollector struct {
+	lines map[string]map[int]bool // Executed lines of each recorded file
+}
+
+// NewCoverageCollector creates an empty collector
+func NewCoverageCollector() *CoverageCollector {
+	return &CoverageCollector{lines: make(map[string]map[int]bool)}
+}
+
+// Add marks the line of a StatementExecution or FuncEntry event as executed
+func (c *CoverageCollector) Add(event recorder.Event) {
+	if event.Type != recorder.StatementExecution && event.Type != recorder.FuncEntry {
+		return
+	}
+	if event.File == "" || event.Line <= 0 {
+		return
+	}
+	lines := c.lines[event.File]
+	if lines == nil {
+		lines = make(map[int]bool)
+		c.lines[event.File] = lines
+	}
+	lines[event.Line] = true
+}
+
+// coverBlock is a span of source holding one statement, or the header of a
+// compound statement
+type coverBlock struct {
+	start, end token.Position
+}
+
+// WriteProfile writes a "mode: set" coverage profile. Each statement of a
+// recorded file is a block, covered if any of its lines were executed.
+// Files are named by absolute path, which go tool cover accepts. Files that
+// can't be read, e.g. because the recording came from another machine, are
+// skipped and counted.
+func (c *CoverageCollector) WriteProfile(w io.Writer) (CoverageSummary, error) {
+	var summary CoverageSummary
+	if _, err := fmt.Fprintln(w, "mode: set"); err != nil {
+		return summary, err
+	}
+
+	files := make([]string, 0, len(c.lines))
+	for file := range c.lines {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		path, err := filepath.Abs(file)
+		if err != nil {
+			summary.Skipped++
+			continue
+		}
+		blocks, err := statementBlocks(path)
+		if err != nil {
+			summary.Skipped++
+			continue
+		}
+
+		summary.Files++
+		executed := c.lines[file]
+		for _, b := range blocks {
+			count := 0
+			for line := b.start.Line; line <= b.end.Line; line++ {
+				if executed[line] {
+					count = 1
+					break
+				}
+			}
+			summary.Blocks++
+			summary.Covered += count
+			if _, err := fmt.Fprintf(w, "%s:%d.%d,%d.%d 1 %d\n", path,
+				b.start.Line, b.start.Column, b.end.Line, b.end.Column, count); err != nil {
+				return summary, err
+			}
+		}
+	}
+	return summary, nil
+}
+
+// statementBlocks parses the Go file at path and returns a block per
+// statement, in source order
+func statementBlocks(path string) ([]coverBlock, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, src, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+
+	var blocks []coverBlock
+	add := func(start, end token.Pos) {
+		blocks = append(blocks, coverBlock{start: fset.Position(start), end: fset.Position(end)})
+	}
+	var walkList func(stmts []ast.Stmt)
+	var walkStmt func(s ast.Stmt)
+	// walkFuncLits adds the statements of the function literals in n, and
+	// returns where the first one starts, or end if there is none
+	walkFuncLits := func(n ast.Node, end token.Pos) token.Pos {
+		ast.Inspect(n, func(node ast.Node) bool {
+			lit, ok := node.(*ast.FuncLit)
+			if !ok {
+				return true
+			}
+			if lit.Body.Lbrace+1 < end {
+				end = lit.Body.Lbrace + 1
+			}
+			walkList(lit.Body.List)
+			return false
+		})
+		return end
+	}
+	walkStmt = func(s ast.Stmt) {
+		switch s := s.(type) {
+		case *ast.BlockStmt:
+			walkList(s.List)
+		case *ast.LabeledStmt:
+			walkStmt(s.Stmt)
+		case *ast.IfStmt:
+			add(s.Pos(), s.Body.Lbrace+1)
+			walkList(s.Body.List)
+			if s.Else != nil {
+				walkStmt(s.Else)
+			}
+		case *ast.ForStmt:
+			add(s.Pos(), s.Body.Lbrace+1)
+			walkList(s.Body.List)
+		case *ast.RangeStmt:
+			add(s.Pos(), s.Body.Lbrace+1)
+			walkList(s.Body.List)
+		case *ast.SwitchStmt:
+			add(s.Pos(), s.Body.Lbrace+1)
+			walkList(s.Body.List)
+		case *ast.TypeSwitchStmt:
+			add(s.Pos(), s.Body.Lbrace+1)
+			walkList(s.Body.List)
+		case *ast.SelectStmt:
+			add(s.Pos(), s.Body.Lbrace+1)
+			walkList(s.Body.List)
+		case *ast.CaseClause:
+			walkList(s.Body)
+		case *ast.CommClause:
+			walkList(s.Body)
+		case *ast.EmptyStmt:
+		default:
+			add(s.Pos(), walkFuncLits(s, s.End()))
+		}
+	}
+	walkList = func(stmts []ast.Stmt) {
+		for _, s := range stmts {
+			walkStmt(s)
+		}
+	}
+
+	for _, decl := range f.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Body != nil {
+			walkList(fn.Body.List)
+		}
+	}
+	sort.SliceStable(blocks, func(i, j int) bool { return blocks[i].start.Offset < blocks[j].start.Offset })
+	return blocks, nil
+}
@@ -0,0 +1,67 @@
+package replay
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+const coverageSource = `package demo
+
+func run(n int) int {
+	total := 0
+	for i := 0; i < n; i++ {
+		total += i
+	}
+	if total > 100 {
+		return -1
+	}
+	go func() {
+		total++
+	}()
+	return total
+}
+`
+
+func TestCoverageProfile(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "demo.go")
+	if err := os.WriteFile(file, []byte(coverageSource), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	collector := NewCoverageCollector()
+	for _, line := range []int{4, 5, 6, 8, 14} {
+		collector.Add(recorder.Event{Type: recorder.StatementExecution, File: file, Line: line})
+	}
+	collector.Add(recorder.Event{Type: recorder.FuncExit, File: file, Line: 9}) // Not an execution
+	collector.Add(recorder.Event{Type: recorder.FuncEntry, File: filepath.Join(dir, "missing.go"), Line: 3})
+
+	var buf bytes.Buffer
+	summary, err := collector.WriteProfile(&buf)
+	if err != nil {
+		t.Fatalf("WriteProfile failed: %v", err)
+	}
+
+	want := []string{
+		"mode: set",
+		file + ":4.2,4.12 1 1",
+		file + ":5.2,5.26 1 1",
+		file + ":6.3,6.13 1 1",
+		file + ":8.2,8.18 1 1",
+		file + ":9.3,9.12 1 0",
+		file + ":11.2,11.13 1 0",
+		file + ":12.3,12.10 1 0",
+		file + ":14.2,14.14 1 1",
+	}
+	if got := strings.Split(strings.TrimSpace(buf.String()), "\n"); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Unexpected profile:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if summary != (CoverageSummary{Files: 1, Skipped: 1, Blocks: 8, Covered: 5}) {
+		t.Errorf("Unexpected summary: %+v", summary)
+	}
+}
@@ -0,0 +1,82 @@
+package replay
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+// flameStack is the call stack of one goroutine while building a flamegraph
+type flameStack struct {
+	frames []string
+	last   time.Time // Time of the goroutine's last entry or exit
+}
+
+// ExportFlamegraph writes the time spent in each call stack of a recording
+// in the folded stack format read by flamegraph.pl and speedscope, e.g.
+//
+//	goroutine 1;main.main;main.processData;main.helper 1234
+//
+// Stacks are rebuilt from the FuncEntry and FuncExit events of each
+// goroutine, given in replay order, and rooted at the goroutine. Each line
+// holds the self time of its innermost function in microseconds, summed
+// over every call with that stack. A recursive call is a frame of its own,
+// and an exit pops the innermost frame of its function along with any
+// frames above it that a panic unwound without recording their exit.
+// Functions still running at the end count until their goroutine's last
+// entry or exit.
+func ExportFlamegraph(events []recorder.Event, w io.Writer) error {
+	stacks := make(map[int]*flameStack)
+	selfTime := make(map[string]time.Duration)
+	for _, e := range events {
+		if e.Type != recorder.FuncEntry && e.Type != recorder.FuncExit || e.FuncName == "" {
+			continue
+		}
+		s := stacks[e.GoroutineID]
+		if s == nil {
+			s = &flameStack{}
+			stacks[e.GoroutineID] = s
+		}
+		if len(s.frames) > 0 && e.Timestamp.After(s.last) {
+			key := fmt.Sprintf("goroutine %d;%s", e.GoroutineID, strings.Join(s.frames, ";"))
+			selfTime[key] += e.Timestamp.Sub(s.last)
+		}
+		s.last = e.Timestamp
+
+		if e.Type == recorder.FuncEntry {
+			s.frames = append(s.frames, flameFrame(e.FuncName))
+			continue
+		}
+		name := flameFrame(e.FuncName)
+		for j := len(s.frames) - 1; j >= 0; j-- {
+			if s.frames[j] == name {
+				s.frames = s.frames[:j]
+				break
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(selfTime))
+	for key := range selfTime {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if micros := selfTime[key].Microseconds(); micros > 0 {
+			if _, err := fmt.Fprintf(w, "%s %d\n", key, micros); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// flameFrame returns a function name with the characters that separate
+// frames and counts in the folded format replaced
+func flameFrame(funcName string) string {
+	return strings.NewReplacer(";", ":", " ", "_").Replace(funcName)
+}
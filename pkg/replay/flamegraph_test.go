@@ -0,0 +1,66 @@
+package replay
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+func TestExportFlamegraph(t *testing.T) {
+	start := time.Unix(0, 0)
+	at := func(micros int) time.Time { return start.Add(time.Duration(micros) * time.Microsecond) }
+	events := []recorder.Event{
+		{Type: recorder.FuncEntry, FuncName: "main.main", GoroutineID: 1, Timestamp: at(0)},
+		{Type: recorder.FuncEntry, FuncName: "main.processData", GoroutineID: 1, Timestamp: at(10)},
+		{Type: recorder.FuncEntry, FuncName: "main.worker", GoroutineID: 2, Timestamp: at(15)},
+		{Type: recorder.FuncEntry, FuncName: "main.helper", GoroutineID: 1, Timestamp: at(20)},
+		{Type: recorder.StatementExecution, FuncName: "main.helper", GoroutineID: 1, Timestamp: at(25)},
+		{Type: recorder.FuncExit, FuncName: "main.helper", GoroutineID: 1, Timestamp: at(50)},
+		// Recursion
+		{Type: recorder.FuncEntry, FuncName: "main.helper", GoroutineID: 1, Timestamp: at(60)},
+		{Type: recorder.FuncEntry, FuncName: "main.helper", GoroutineID: 1, Timestamp: at(65)},
+		{Type: recorder.FuncExit, FuncName: "main.helper", GoroutineID: 1, Timestamp: at(70)},
+		{Type: recorder.FuncExit, FuncName: "main.helper", GoroutineID: 1, Timestamp: at(80)},
+		{Type: recorder.FuncExit, FuncName: "main.worker", GoroutineID: 2, Timestamp: at(95)},
+		{Type: recorder.FuncExit, FuncName: "main.processData", GoroutineID: 1, Timestamp: at(90)},
+		{Type: recorder.FuncExit, FuncName: "main.main", GoroutineID: 1, Timestamp: at(100)},
+	}
+
+	var buf bytes.Buffer
+	if err := ExportFlamegraph(events, &buf); err != nil {
+		t.Fatalf("ExportFlamegraph failed: %v", err)
+	}
+	want := "goroutine 1;main.main 20\n" +
+		"goroutine 1;main.main;main.processData 30\n" +
+		"goroutine 1;main.main;main.processData;main.helper 45\n" +
+		"goroutine 1;main.main;main.processData;main.helper;main.helper 5\n" +
+		"goroutine 2;main.worker 80\n"
+	if got := buf.String(); got != want {
+		t.Errorf("Expected folded stacks\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestExportFlamegraphUnwound(t *testing.T) {
+	start := time.Unix(0, 0)
+	events := []recorder.Event{
+		{Type: recorder.FuncEntry, FuncName: "main.main", Timestamp: start},
+		{Type: recorder.FuncEntry, FuncName: "main.risky", Timestamp: start.Add(time.Millisecond)},
+		// A panic unwinds risky without recording its exit
+		{Type: recorder.FuncExit, FuncName: "main.main", Timestamp: start.Add(3 * time.Millisecond)},
+		{Type: recorder.FuncEntry, FuncName: "main.after", Timestamp: start.Add(4 * time.Millisecond)},
+		{Type: recorder.FuncExit, FuncName: "main.after", Timestamp: start.Add(5 * time.Millisecond)},
+	}
+
+	var buf bytes.Buffer
+	if err := ExportFlamegraph(events, &buf); err != nil {
+		t.Fatalf("ExportFlamegraph failed: %v", err)
+	}
+	want := "goroutine 0;main.after 1000\n" +
+		"goroutine 0;main.main 1000\n" +
+		"goroutine 0;main.main;main.risky 2000\n"
+	if got := buf.String(); got != want {
+		t.Errorf("Expected folded stacks\n%s\ngot\n%s", want, got)
+	}
+}
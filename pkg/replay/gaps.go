@@ -0,0 +1,75 @@
+package replay
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+// Gap is a range of event IDs missing from a recording whose IDs count up
+// one at a time, which usually means an asynchronous or networked recorder
+// lost events
+type Gap struct {
+	First int64 // First missing ID
+	Last  int64 // Last missing ID
+}
+
+// Missing returns the number of IDs in the gap
+func (g Gap) Missing() int64 {
+	return g.Last - g.First + 1
+}
+
+func (g Gap) String() string {
+	if g.First == g.Last {
+		return fmt.Sprintf("ID %d", g.First)
+	}
+	return fmt.Sprintf("IDs %d-%d", g.First, g.Last)
+}
+
+// DetectGaps returns the ranges of IDs missing between the lowest and
+// highest ID in events, in order. IDs are only checked when they are
+// sequential: when at least half of the steps between consecutive IDs,
+// taken in ID order, are 1. IDs taken from the clock, as instrumentation
+// assigns them, have no meaningful gaps, so those recordings report none.
+// Repeated IDs, such as those of snapshot markers, count once.
+func DetectGaps(events []recorder.Event) []Gap {
+	ids := make([]int64, 0, len(events))
+	for _, e := range events {
+		ids = append(ids, e.ID)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	var unique []int64
+	for i, id := range ids {
+		if i == 0 || id != ids[i-1] {
+			unique = append(unique, id)
+		}
+	}
+	if len(unique) < 2 {
+		return nil
+	}
+
+	var gaps []Gap
+	consecutive := 0
+	for i := 1; i < len(unique); i++ {
+		if step := unique[i] - unique[i-1]; step == 1 {
+			consecutive++
+		} else {
+			gaps = append(gaps, Gap{First: unique[i-1] + 1, Last: unique[i] - 1})
+		}
+	}
+	if consecutive*2 < len(unique)-1 {
+		return nil
+	}
+	return gaps
+}
+
+// MissingEvents returns the number of IDs missing across gaps
+func MissingEvents(gaps []Gap) int64 {
+	var n int64
+	for _, g := range gaps {
+		n += g.Missing()
+	}
+	return n
+}
@@ -0,0 +1,54 @@
+package replay
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+// sequentialEvents returns events with IDs first to last, leaving out skip
+func sequentialEvents(first, last int64, skip func(int64) bool) []recorder.Event {
+	var events []recorder.Event
+	for id := first; id <= last; id++ {
+		if skip != nil && skip(id) {
+			continue
+		}
+		events = append(events, recorder.Event{ID: id, Type: recorder.StatementExecution})
+	}
+	return events
+}
+
+func TestDetectGaps(t *testing.T) {
+	events := sequentialEvents(1, 100, func(id int64) bool { return (id >= 50 && id <= 55) || id == 80 })
+	// Snapshot markers repeat the ID before them, and order doesn't matter
+	events = append(events, recorder.Event{ID: 20, Type: recorder.SnapshotEvent})
+	events[0], events[10] = events[10], events[0]
+
+	gaps := DetectGaps(events)
+	want := []Gap{{First: 50, Last: 55}, {First: 80, Last: 80}}
+	if !reflect.DeepEqual(gaps, want) {
+		t.Fatalf("DetectGaps = %v, want %v", gaps, want)
+	}
+	if n := MissingEvents(gaps); n != 7 {
+		t.Errorf("MissingEvents = %d, want 7", n)
+	}
+	if s := gaps[0].String() + ", " + gaps[1].String(); s != "IDs 50-55, ID 80" {
+		t.Errorf("Gaps print as %q", s)
+	}
+
+	if gaps := DetectGaps(sequentialEvents(1, 100, nil)); gaps != nil {
+		t.Errorf("Complete recording has gaps %v", gaps)
+	}
+
+	// IDs taken from the clock aren't sequential
+	var clocked []recorder.Event
+	base := time.Now().UnixNano()
+	for i := 0; i < 10; i++ {
+		clocked = append(clocked, recorder.Event{ID: base + int64(i)*1500})
+	}
+	if gaps := DetectGaps(clocked); gaps != nil {
+		t.Errorf("Clock IDs reported as gaps %v", gaps)
+	}
+}
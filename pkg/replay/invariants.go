@@ -0,0 +1,216 @@
+package replay
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+// InvariantState is the replay state invariants are checked against. It is
+// built up one event at a time, and Event is the event just applied.
+type InvariantState struct {
+	Index          int
+	Event          recorder.Event
+	MutexHolders   map[int][]int // Goroutines holding each mutex, in lock order
+	ChannelLen     map[int]int   // Values sent but not yet received, per channel
+	ClosedChannels map[int]bool
+
+	unlockMatched bool // Whether the current unlock event released a lock its goroutine held
+}
+
+// Invariant is a named property that must hold after every event. Check
+// returns an error describing the violation, or nil if the property holds.
+// Checks should only report problems caused by the current event, so a
+// violation isn't repeated for every event after it.
+type Invariant struct {
+	Name  string
+	Check func(state *InvariantState) error
+}
+
+// Violation is an invariant that failed at an event
+type Violation struct {
+	Invariant string
+	Index     int // Index of the event in replay order
+	Event     recorder.Event
+	Message   string
+}
+
+// String returns a one-line description of the violation
+func (v Violation) String() string {
+	return fmt.Sprintf("[%d] %s: %s", v.Index, v.Invariant, v.Message)
+}
+
+// CheckInvariants replays events in order and checks every invariant after
+// each event. Indices in the returned violations match the replayer's.
+func CheckInvariants(events []recorder.Event, invariants []Invariant) []Violation {
+	sorted := append([]recorder.Event(nil), events...)
+	recorder.StableSort(sorted)
+
+	state := &InvariantState{
+		MutexHolders:   make(map[int][]int),
+		ChannelLen:     make(map[int]int),
+		ClosedChannels: make(map[int]bool),
+	}
+
+	var violations []Violation
+	for i, e := range sorted {
+		state.Index = i
+		state.Event = e
+		state.apply(e)
+
+		for _, inv := range invariants {
+			if err := inv.Check(state); err != nil {
+				violations = append(violations, Violation{
+					Invariant: inv.Name,
+					Index:     i,
+					Event:     e,
+					Message:   err.Error(),
+				})
+			}
+		}
+	}
+	return violations
+}
+
+// apply updates the state with a mutex or channel event
+func (s *InvariantState) apply(e recorder.Event) {
+	switch e.Type {
+	case recorder.SyncOperation:
+		mutexID, gID, locked, ok := parseMutexEvent(e.Details)
+		if !ok {
+			return
+		}
+		if locked {
+			s.MutexHolders[mutexID] = append(s.MutexHolders[mutexID], gID)
+			return
+		}
+		holders := s.MutexHolders[mutexID]
+		s.unlockMatched = false
+		for i, holder := range holders {
+			if holder == gID {
+				s.MutexHolders[mutexID] = append(holders[:i:i], holders[i+1:]...)
+				s.unlockMatched = true
+				return
+			}
+		}
+
+	case recorder.ChannelOperation:
+		var chID, gID int
+		switch {
+		case strings.Contains(e.Details, "send by"):
+			if _, err := fmt.Sscanf(e.Details, "Channel %d: send by goroutine %d", &chID, &gID); err == nil {
+				s.ChannelLen[chID]++
+			}
+		case strings.Contains(e.Details, "receive by"):
+			if _, err := fmt.Sscanf(e.Details, "Channel %d: receive by goroutine %d", &chID, &gID); err == nil && s.ChannelLen[chID] > 0 {
+				s.ChannelLen[chID]--
+			}
+		case strings.Contains(e.Details, "closed by"):
+			if _, err := fmt.Sscanf(e.Details, "Channel %d: closed by goroutine %d", &chID, &gID); err == nil {
+				s.ClosedChannels[chID] = true
+			}
+		}
+	}
+}
+
+// parseMutexEvent extracts the mutex, goroutine and operation from the
+// details of a mutex lock or unlock event
+func parseMutexEvent(details string) (mutexID, gID int, locked bool, ok bool) {
+	if _, err := fmt.Sscanf(details, "Mutex %d: locked by goroutine %d", &mutexID, &gID); err == nil {
+		return mutexID, gID, true, true
+	}
+	if _, err := fmt.Sscanf(details, "Mutex %d: unlocked by goroutine %d", &mutexID, &gID); err == nil {
+		return mutexID, gID, false, true
+	}
+	return 0, 0, false, false
+}
+
+// MutexExclusive checks that no mutex is ever held by two goroutines at once
+func MutexExclusive() Invariant {
+	return Invariant{
+		Name: "mutex-exclusive",
+		Check: func(s *InvariantState) error {
+			mutexID, _, locked, ok := parseMutexEvent(s.Event.Details)
+			if !ok || !locked || len(s.MutexHolders[mutexID]) < 2 {
+				return nil
+			}
+			return fmt.Errorf("mutex %d held by goroutines %v", mutexID, s.MutexHolders[mutexID])
+		},
+	}
+}
+
+// MutexUnlockByHolder checks that a mutex is only unlocked by a goroutine holding it
+func MutexUnlockByHolder() Invariant {
+	return Invariant{
+		Name: "mutex-unlock-by-holder",
+		Check: func(s *InvariantState) error {
+			mutexID, gID, locked, ok := parseMutexEvent(s.Event.Details)
+			if !ok || locked || s.unlockMatched {
+				return nil
+			}
+			if holders := s.MutexHolders[mutexID]; len(holders) > 0 {
+				return fmt.Errorf("mutex %d unlocked by goroutine %d while held by %v", mutexID, gID, holders)
+			}
+			return fmt.Errorf("mutex %d unlocked by goroutine %d while not locked", mutexID, gID)
+		},
+	}
+}
+
+// NoSendOnClosedChannel checks that nothing is sent on a channel after it is closed
+func NoSendOnClosedChannel() Invariant {
+	return Invariant{
+		Name: "no-send-on-closed",
+		Check: func(s *InvariantState) error {
+			var chID, gID int
+			if s.Event.Type != recorder.ChannelOperation {
+				return nil
+			}
+			if _, err := fmt.Sscanf(s.Event.Details, "Channel %d: send by goroutine %d", &chID, &gID); err != nil {
+				return nil
+			}
+			if s.ClosedChannels[chID] {
+				return fmt.Errorf("send on closed channel %d by goroutine %d", chID, gID)
+			}
+			return nil
+		},
+	}
+}
+
+// ChannelCapacity checks that channel chID never holds more than max
+// values that were sent but not yet received
+func ChannelCapacity(chID, max int) Invariant {
+	return Invariant{
+		Name: fmt.Sprintf("channel-%d-capacity", chID),
+		Check: func(s *InvariantState) error {
+			if s.Event.Type != recorder.ChannelOperation || s.ChannelLen[chID] <= max {
+				return nil
+			}
+			var sendCh int
+			if _, err := fmt.Sscanf(s.Event.Details, "Channel %d: send by", &sendCh); err != nil || sendCh != chID {
+				return nil
+			}
+			return fmt.Errorf("channel %d buffer holds %d values, more than %d", chID, s.ChannelLen[chID], max)
+		},
+	}
+}
+
+var (
+	invariantsMu         sync.Mutex
+	registeredInvariants = []Invariant{MutexExclusive(), MutexUnlockByHolder(), NoSendOnClosedChannel()}
+)
+
+// RegisterInvariant adds a custom invariant to the ones returned by Invariants
+func RegisterInvariant(inv Invariant) {
+	invariantsMu.Lock()
+	defer invariantsMu.Unlock()
+	registeredInvariants = append(registeredInvariants, inv)
+}
+
+// Invariants returns the built-in invariants followed by the registered ones
+func Invariants() []Invariant {
+	invariantsMu.Lock()
+	defer invariantsMu.Unlock()
+	return append([]Invariant(nil), registeredInvariants...)
+}
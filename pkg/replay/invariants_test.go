@@ -0,0 +1,114 @@
+package replay
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+// concurrencyEvents builds events with increasing timestamps from details,
+// typed by their prefix
+func concurrencyEvents(details ...string) []recorder.Event {
+	base := time.Now()
+	events := make([]recorder.Event, len(details))
+	for i, d := range details {
+		eventType := recorder.ChannelOperation
+		if d[0] == 'M' {
+			eventType = recorder.SyncOperation
+		}
+		events[i] = recorder.Event{
+			ID:        int64(i + 1),
+			Timestamp: base.Add(time.Duration(i) * time.Millisecond),
+			Type:      eventType,
+			Details:   d,
+		}
+	}
+	return events
+}
+
+func TestCheckInvariantsMutex(t *testing.T) {
+	events := concurrencyEvents(
+		"Mutex 1: locked by goroutine 1",
+		"Mutex 1: unlocked by goroutine 1",
+		"Mutex 1: locked by goroutine 2",
+		"Mutex 1: locked by goroutine 3", // Goroutine 2 still holds it
+		"Mutex 1: unlocked by goroutine 2",
+		"Mutex 1: unlocked by goroutine 3",
+		"Mutex 2: unlocked by goroutine 1", // Never locked
+	)
+
+	violations := CheckInvariants(events, Invariants())
+	if len(violations) != 2 {
+		t.Fatalf("Expected 2 violations, got %v", violations)
+	}
+
+	if v := violations[0]; v.Invariant != "mutex-exclusive" || v.Index != 3 || v.Message != "mutex 1 held by goroutines [2 3]" {
+		t.Errorf("Unexpected exclusivity violation: %s", v)
+	}
+	if v := violations[1]; v.Invariant != "mutex-unlock-by-holder" || v.Index != 6 {
+		t.Errorf("Unexpected unlock violation: %s", v)
+	}
+}
+
+func TestCheckInvariantsChannels(t *testing.T) {
+	events := concurrencyEvents(
+		"Channel 3: send by goroutine 1, value: 1",
+		"Channel 3: send by goroutine 1, value: 2",
+		"Channel 3: send by goroutine 1, value: 3",
+		"Channel 3: receive by goroutine 2, value: 1",
+		"Channel 3: send by goroutine 1, value: 4",
+		"Channel 4: send by goroutine 1, value: 1", // Other channels aren't limited
+		"Channel 4: send by goroutine 1, value: 2",
+		"Channel 4: send by goroutine 1, value: 3",
+		"Channel 3: closed by goroutine 1",
+		"Channel 3: send by goroutine 2, value: 5",
+	)
+
+	violations := CheckInvariants(events, append(Invariants(), ChannelCapacity(3, 2)))
+
+	var got []string
+	for _, v := range violations {
+		got = append(got, v.String())
+	}
+	expected := []string{
+		"[2] channel-3-capacity: channel 3 buffer holds 3 values, more than 2",
+		"[4] channel-3-capacity: channel 3 buffer holds 3 values, more than 2",
+		"[9] no-send-on-closed: send on closed channel 3 by goroutine 2",
+		"[9] channel-3-capacity: channel 3 buffer holds 4 values, more than 2",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf("Expected violations %q, got %q", expected, got)
+	}
+}
+
+func TestRegisterInvariant(t *testing.T) {
+	original := Invariants()
+	defer func() {
+		invariantsMu.Lock()
+		registeredInvariants = original
+		invariantsMu.Unlock()
+	}()
+
+	// A custom invariant over the events themselves
+	RegisterInvariant(Invariant{
+		Name: "no-goroutine-7",
+		Check: func(s *InvariantState) error {
+			_, gID, _, ok := parseMutexEvent(s.Event.Details)
+			if ok && gID == 7 {
+				return fmt.Errorf("goroutine 7 touched a mutex")
+			}
+			return nil
+		},
+	})
+
+	events := concurrencyEvents(
+		"Mutex 1: locked by goroutine 7",
+		"Mutex 1: unlocked by goroutine 7",
+	)
+	violations := CheckInvariants(events, Invariants())
+	if len(violations) != 2 || violations[0].Invariant != "no-goroutine-7" {
+		t.Errorf("Expected 2 violations of the custom invariant, got %v", violations)
+	}
+}
@@ -0,0 +1,123 @@
+package replay
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+// IOStat summarizes the recorded I/O on one file or connection
+type IOStat struct {
+	Name         string // File path, or network and remote address of a connection
+	Conn         bool   // Whether this is a network connection rather than a file
+	BytesRead    int64
+	BytesWritten int64
+	Reads        int
+	Writes       int
+	LastWrite    int  // Index of the last write event, -1 if never written
+	Closed       bool // Whether the last event on it was a close
+}
+
+// ParseIO extracts the file or connection name, the operation ("read",
+// "write" or "closed") and the byte count from the details of an IOEvent,
+// e.g. "File data.txt (fd 3): write 42 bytes" or
+// "Conn tcp 127.0.0.1:5000: read 10 bytes, sha256 1f2e3d4c5b6a7988"
+func ParseIO(details string) (name string, conn bool, op string, n int64, ok bool) {
+	switch {
+	case strings.HasPrefix(details, "File "):
+	case strings.HasPrefix(details, "Conn "):
+		conn = true
+	default:
+		return "", false, "", 0, false
+	}
+	rest := details[len("File "):]
+
+	// Addresses contain colons but not ": ", so the first separator
+	// followed by an operation ends the name
+	sep := -1
+	for _, candidate := range []string{": read ", ": write ", ": closed"} {
+		if i := strings.Index(rest, candidate); i >= 0 && (sep < 0 || i < sep) {
+			sep = i
+			op = strings.TrimSpace(candidate[2:])
+		}
+	}
+	if sep < 0 {
+		return "", false, "", 0, false
+	}
+	name = rest[:sep]
+	if open := strings.LastIndex(name, " (fd "); open >= 0 && strings.HasSuffix(name, ")") {
+		name = name[:open]
+	}
+
+	if op != "closed" {
+		if _, err := fmt.Sscanf(rest[sep+2:], op+" %d bytes", &n); err != nil {
+			return "", false, "", 0, false
+		}
+	}
+	return name, conn, op, n, true
+}
+
+// IOSummary totals the recorded I/O per file and connection, ordered by name
+func (r *BasicReplayer) IOSummary() []IOStat {
+	stats := make(map[string]*IOStat)
+	for i, e := range r.events {
+		if e.Type != recorder.IOEvent {
+			continue
+		}
+		name, conn, op, n, ok := ParseIO(e.Details)
+		if !ok {
+			continue
+		}
+
+		key := fmt.Sprintf("%t %s", conn, name)
+		stat, exists := stats[key]
+		if !exists {
+			stat = &IOStat{Name: name, Conn: conn, LastWrite: -1}
+			stats[key] = stat
+		}
+		stat.Closed = false
+		switch op {
+		case "read":
+			stat.BytesRead += n
+			stat.Reads++
+		case "write":
+			stat.BytesWritten += n
+			stat.Writes++
+			stat.LastWrite = i
+		case "closed":
+			stat.Closed = true
+		}
+	}
+
+	summary := make([]IOStat, 0, len(stats))
+	for _, stat := range stats {
+		summary = append(summary, *stat)
+	}
+	sort.Slice(summary, func(i, j int) bool {
+		if summary[i].Conn != summary[j].Conn {
+			return !summary[i].Conn
+		}
+		return summary[i].Name < summary[j].Name
+	})
+	return summary
+}
+
+// LastWrite returns the index of the last recorded write to the named file
+// or connection at or before event index idx, or -1 if there is none
+func (r *BasicReplayer) LastWrite(name string, idx int) int {
+	if idx >= len(r.events) {
+		idx = len(r.events) - 1
+	}
+	for i := idx; i >= 0; i-- {
+		e := r.events[i]
+		if e.Type != recorder.IOEvent {
+			continue
+		}
+		if n, _, op, _, ok := ParseIO(e.Details); ok && op == "write" && n == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,60 @@
+package replay
+
+import (
+	"testing"
+	"time"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+func TestIOSummary(t *testing.T) {
+	base := time.Now()
+	details := []string{
+		"File data.txt (fd 3): write 10 bytes",
+		"Conn tcp 127.0.0.1:5000: write 4 bytes, sha256 1f2e3d4c5b6a7988",
+		"File data.txt (fd 3): closed",
+		"Conn tcp 127.0.0.1:5000: read 100 bytes",
+		"File data.txt (fd 4): read 10 bytes",
+		"File data.txt (fd 4): write 2 bytes, payload \"ok: read 9 bytes\"",
+		"File other.txt (fd 5): read 7 bytes",
+	}
+	events := make([]recorder.Event, len(details))
+	for i, d := range details {
+		events[i] = recorder.Event{ID: int64(i + 1), Timestamp: base.Add(time.Duration(i) * time.Millisecond),
+			Type: recorder.IOEvent, Details: d}
+	}
+
+	r := NewBasicReplayer()
+	if err := r.LoadEvents(events); err != nil {
+		t.Fatalf("Failed to load events: %v", err)
+	}
+
+	summary := r.IOSummary()
+	expected := []IOStat{
+		{Name: "data.txt", BytesRead: 10, BytesWritten: 12, Reads: 1, Writes: 2, LastWrite: 5},
+		{Name: "other.txt", BytesRead: 7, Reads: 1, LastWrite: -1},
+		{Name: "tcp 127.0.0.1:5000", Conn: true, BytesRead: 100, BytesWritten: 4, Reads: 1, Writes: 1, LastWrite: 1},
+	}
+	if len(summary) != len(expected) {
+		t.Fatalf("Expected %d entries, got %+v", len(expected), summary)
+	}
+	for i, want := range expected {
+		if summary[i] != want {
+			t.Errorf("Entry %d: expected %+v, got %+v", i, want, summary[i])
+		}
+	}
+
+	if idx := r.LastWrite("data.txt", 4); idx != 0 {
+		t.Errorf("Expected the last write before event 4 at 0, got %d", idx)
+	}
+	if idx := r.LastWrite("data.txt", 100); idx != 5 {
+		t.Errorf("Expected the last write at 5, got %d", idx)
+	}
+	if idx := r.LastWrite("other.txt", 100); idx != -1 {
+		t.Errorf("Expected no write to other.txt, got %d", idx)
+	}
+
+	if _, _, _, _, ok := ParseIO("Entering main.main"); ok {
+		t.Error("Expected non-I/O details not to parse")
+	}
+}
@@ -0,0 +1,102 @@
+package replay
+
+import (
+	"sort"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+// LocationIndex finds the events recorded at a source line, or entering and
+// leaving a function, without scanning the recording for each lookup
+type LocationIndex struct {
+	lines map[string]map[int][]int // Event indices by file and line
+	calls map[string][]int         // Indices of FuncEntry and FuncExit events by function
+}
+
+// IndexLocations indexes the locations of events
+func IndexLocations(events []recorder.Event) *LocationIndex {
+	li := &LocationIndex{
+		lines: make(map[string]map[int][]int),
+		calls: make(map[string][]int),
+	}
+	for i, e := range events {
+		if e.File != "" && e.Line > 0 {
+			byLine := li.lines[e.File]
+			if byLine == nil {
+				byLine = make(map[int][]int)
+				li.lines[e.File] = byLine
+			}
+			byLine[e.Line] = append(byLine[e.Line], i)
+		}
+		if e.FuncName != "" && (e.Type == recorder.FuncEntry || e.Type == recorder.FuncExit) {
+			li.calls[e.FuncName] = append(li.calls[e.FuncName], i)
+		}
+	}
+	return li
+}
+
+// Files returns the recorded files, in order
+func (li *LocationIndex) Files() []string {
+	files := make([]string, 0, len(li.lines))
+	for file := range li.lines {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// Functions returns the functions entered or left, in order
+func (li *LocationIndex) Functions() []string {
+	funcs := make([]string, 0, len(li.calls))
+	for fn := range li.calls {
+		funcs = append(funcs, fn)
+	}
+	sort.Strings(funcs)
+	return funcs
+}
+
+// AtLine returns the indices of the events recorded at file:line, in order.
+// file is one of Files.
+func (li *LocationIndex) AtLine(file string, line int) []int {
+	return li.lines[file][line]
+}
+
+// Calls returns the indices of the events entering and leaving funcName, in
+// order. funcName is one of Functions.
+func (li *LocationIndex) Calls(funcName string) []int {
+	return li.calls[funcName]
+}
+
+// NextAtLocation returns the index of the first event after fromIdx
+// recorded at file:line, comparing paths as HostPaths does, so
+// C:\src\main.go and "main.go" both find events in c:/src/main.go on
+// Windows. A fromIdx of -1 searches from the first event.
+func (r *BasicReplayer) NextAtLocation(file string, line int, fromIdx int) (int, bool) {
+	for i := max(fromIdx+1, 0); i < len(r.events); i++ {
+		if r.atLocation(i, file, line) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// PrevAtLocation returns the index of the last event before fromIdx
+// recorded at file:line, comparing paths as NextAtLocation does
+func (r *BasicReplayer) PrevAtLocation(file string, line int, fromIdx int) (int, bool) {
+	for i := min(fromIdx, len(r.events)) - 1; i >= 0; i-- {
+		if r.atLocation(i, file, line) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// atLocation reports whether the event at idx was recorded at file:line
+func (r *BasicReplayer) atLocation(idx int, file string, line int) bool {
+	e := r.events[idx]
+	if e.Line != line {
+		return false
+	}
+	ok, _ := HostPaths.Match(e.File, file)
+	return ok
+}
@@ -0,0 +1,83 @@
+package replay
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+func TestIndexLocations(t *testing.T) {
+	events := []recorder.Event{
+		{Type: recorder.FuncEntry, FuncName: "main.work", File: "main.go", Line: 20},
+		{Type: recorder.StatementExecution, FuncName: "main.work", File: "main.go", Line: 21},
+		{Type: recorder.StatementExecution, FuncName: "main.work", File: "main.go", Line: 21},
+		{Type: recorder.FuncExit, FuncName: "main.work", File: "main.go", Line: 23},
+		{Type: recorder.GoroutineSwitch, Details: "no location"},
+		{Type: recorder.StatementExecution, FuncName: "main.main", File: "util.go", Line: 21},
+	}
+	index := IndexLocations(events)
+
+	if got, want := index.Files(), []string{"main.go", "util.go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected files %v, got %v", want, got)
+	}
+	if got, want := index.Functions(), []string{"main.work"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected only the functions entered or left, %v, got %v", want, got)
+	}
+	if got, want := index.AtLine("main.go", 21), []int{1, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected events %v at main.go:21, got %v", want, got)
+	}
+	if got := index.AtLine("main.go", 99); len(got) != 0 {
+		t.Errorf("Expected no events at main.go:99, got %v", got)
+	}
+	if got, want := index.Calls("main.work"), []int{0, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected the entry and exit %v, got %v", want, got)
+	}
+}
+
+func TestAtLocationWindowsPaths(t *testing.T) {
+	original := HostPaths
+	HostPaths = NewPathResolver("windows")
+	defer func() { HostPaths = original }()
+
+	now := time.Now()
+	replayer := NewBasicReplayer()
+	if err := replayer.LoadEvents([]recorder.Event{
+		{ID: 1, Timestamp: now, Type: recorder.StatementExecution, File: `C:\src\app\main.go`, Line: 42},
+		{ID: 2, Timestamp: now, Type: recorder.StatementExecution, File: `C:\src\app\util.go`, Line: 42},
+		{ID: 3, Timestamp: now, Type: recorder.StatementExecution, File: `C:\src\app\main.go`, Line: 43},
+		{ID: 4, Timestamp: now, Type: recorder.StatementExecution, File: "c:/src/app/main.go", Line: 42},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		file     string
+		from     int
+		backward bool
+		idx      int
+		ok       bool
+	}{
+		{`C:\src\app\main.go`, -1, false, 0, true},
+		{"c:/SRC/app/Main.go", 0, false, 3, true},
+		{`app\main.go`, 0, false, 3, true},
+		{"main.go", 3, false, 0, false},
+		{`D:\src\app\main.go`, -1, false, 0, false},
+		{`C:\src\app\main.go`, 3, true, 0, true},
+		{"util.go", 4, true, 1, true},
+		{"main.go", 0, true, 0, false},
+	}
+	for _, tt := range tests {
+		var idx int
+		var ok bool
+		if tt.backward {
+			idx, ok = replayer.PrevAtLocation(tt.file, 42, tt.from)
+		} else {
+			idx, ok = replayer.NextAtLocation(tt.file, 42, tt.from)
+		}
+		if idx != tt.idx || ok != tt.ok {
+			t.Errorf("Finding %s:42 from %d (backward %v) = %d, %v, want %d, %v", tt.file, tt.from, tt.backward, idx, ok, tt.idx, tt.ok)
+		}
+	}
+}
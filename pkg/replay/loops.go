@@ -0,0 +1,200 @@
+package replay
+
+import "github.com/willibrandon/ChronoGo/pkg/recorder"
+
+// Loop is one execution of a loop on one goroutine, inferred from a cycle of
+// source locations recurring in the recording. Its header is the location
+// each iteration starts at.
+type Loop struct {
+	File        string // File of the header
+	Line        int    // Line of the header
+	GoroutineID int
+	Starts      []int // Event index each iteration starts at
+	End         int   // Index of the loop's last event
+}
+
+// LoopIteration places an event in the loop that most closely encloses it
+type LoopIteration struct {
+	Loop      int // Index into LoopIndex.Loops, -1 if the event isn't in a loop
+	Iteration int // 1-based iteration of that loop, 0 outside loops
+}
+
+// LoopIndex labels the events of a recording with the loop iteration they
+// ran in. It is computed from the events and never stored with them.
+type LoopIndex struct {
+	Loops  []Loop
+	labels []LoopIteration // Label of each event
+}
+
+// loopLocation is a source location loops are detected by
+type loopLocation struct {
+	file string
+	line int
+}
+
+// loopFrame is a loop, or the top level, that a goroutine is running in
+type loopFrame struct {
+	loop int                  // Index into the loops, -1 for the top level
+	iter int                  // Current iteration, 0 for the top level
+	seen map[loopLocation]int // Locations run in the current iteration, with their last event index
+	body map[loopLocation]bool
+}
+
+// label returns the label of events running directly in the frame
+func (f *loopFrame) label() LoopIteration {
+	return LoopIteration{Loop: f.loop, Iteration: f.iter}
+}
+
+// IndexLoops detects the loops in a recording. Events are followed per
+// goroutine: a location that recurs within the current iteration of the
+// innermost loop starts a new, nested loop whose header is that location,
+// and the recurrence of an enclosing loop's header ends the loops nested in
+// it and starts its next iteration. So a nested loop is a new Loop for each
+// iteration of the loop around it, and an event belongs to the innermost
+// repeating set of lines it ran in. Calls made twice within one iteration
+// look like a loop too; this is a heuristic over the recorded lines, not the
+// program's structure.
+func IndexLoops(events []recorder.Event) *LoopIndex {
+	li := &LoopIndex{labels: make([]LoopIteration, len(events))}
+	stacks := make(map[int][]*loopFrame)
+
+	for i, e := range events {
+		stack := stacks[e.GoroutineID]
+		if stack == nil {
+			stack = []*loopFrame{{loop: -1, seen: make(map[loopLocation]int)}}
+		}
+		if e.File == "" || e.Line <= 0 {
+			li.labels[i] = stack[len(stack)-1].label()
+			stacks[e.GoroutineID] = stack
+			continue
+		}
+
+		at := loopLocation{e.File, e.Line}
+		matched := false
+		for k := len(stack) - 1; k >= 0 && !matched; k-- {
+			f := stack[k]
+			if f.loop >= 0 && li.Loops[f.loop].File == at.file && li.Loops[f.loop].Line == at.line {
+				// The next iteration of an enclosing loop
+				stack = li.endLoops(events, stack, k+1, i)
+				for loc := range f.seen {
+					f.body[loc] = true
+				}
+				f.seen = map[loopLocation]int{at: i}
+				f.iter++
+				li.Loops[f.loop].Starts = append(li.Loops[f.loop].Starts, i)
+				li.labels[i] = f.label()
+				matched = true
+			} else if start, ok := f.seen[at]; ok {
+				// A new loop, whose first iteration ran from start
+				stack = li.endLoops(events, stack, k+1, i)
+				stack = append(stack, li.startLoop(events, f, start, i))
+				li.labels[i] = stack[len(stack)-1].label()
+				matched = true
+			}
+		}
+		if !matched {
+			top := stack[len(stack)-1]
+			top.seen[at] = i
+			li.labels[i] = top.label()
+		}
+		stacks[e.GoroutineID] = stack
+	}
+
+	for _, stack := range stacks {
+		li.endLoops(events, stack, 1, len(events))
+	}
+	for i, label := range li.labels {
+		if label.Loop >= 0 {
+			li.Loops[label.Loop].End = i
+		}
+	}
+	return li
+}
+
+// startLoop creates a loop nested in f whose header ran at start and again
+// at i, moving the events f ran in between into its first iteration
+func (li *LoopIndex) startLoop(events []recorder.Event, f *loopFrame, start, i int) *loopFrame {
+	e := events[i]
+	id := len(li.Loops)
+	li.Loops = append(li.Loops, Loop{File: e.File, Line: e.Line, GoroutineID: e.GoroutineID, Starts: []int{start, i}})
+
+	inner := &loopFrame{loop: id, iter: 2, seen: map[loopLocation]int{{e.File, e.Line}: i}, body: make(map[loopLocation]bool)}
+	outer := f.label()
+	for j := start; j < i; j++ {
+		if events[j].GoroutineID != e.GoroutineID || li.labels[j] != outer {
+			continue
+		}
+		li.labels[j] = LoopIteration{Loop: id, Iteration: 1}
+		if events[j].File != "" && events[j].Line > 0 {
+			inner.body[loopLocation{events[j].File, events[j].Line}] = true
+		}
+	}
+	for loc, j := range f.seen {
+		if j >= start {
+			delete(f.seen, loc)
+		}
+	}
+	return inner
+}
+
+// endLoops ends the loops of stack from depth k up, as the goroutine has left
+// them by event i, and returns the remaining stack. Events in the last
+// iteration of a loop from the first location its earlier iterations never
+// ran are after the loop, so they move to the enclosing frame.
+func (li *LoopIndex) endLoops(events []recorder.Event, stack []*loopFrame, k, i int) []*loopFrame {
+	for depth := len(stack) - 1; depth >= k; depth-- {
+		f, parent := stack[depth], stack[depth-1]
+		last := f.label()
+		after := false
+		for j := li.Loops[f.loop].Starts[len(li.Loops[f.loop].Starts)-1]; j < i; j++ {
+			if events[j].GoroutineID != li.Loops[f.loop].GoroutineID || li.labels[j] != last {
+				continue
+			}
+			at := loopLocation{events[j].File, events[j].Line}
+			if !after && at.line > 0 && !f.body[at] && !(at.file == li.Loops[f.loop].File && at.line == li.Loops[f.loop].Line) {
+				after = true
+			}
+			if after {
+				li.labels[j] = parent.label()
+				if at.line > 0 {
+					parent.seen[at] = j
+				}
+			}
+		}
+	}
+	return stack[:k]
+}
+
+// At returns the loop iteration event idx ran in
+func (li *LoopIndex) At(idx int) LoopIteration {
+	if idx < 0 || idx >= len(li.labels) {
+		return LoopIteration{Loop: -1}
+	}
+	return li.labels[idx]
+}
+
+// IterationTarget returns the event to jump to for iteration n of the loop
+// event idx ran in: the event at the same location in that iteration if it
+// ran there, otherwise the start of the iteration
+func (li *LoopIndex) IterationTarget(events []recorder.Event, idx, n int) (int, bool) {
+	label := li.At(idx)
+	if label.Loop < 0 {
+		return 0, false
+	}
+	loop := li.Loops[label.Loop]
+	if n < 1 || n > len(loop.Starts) {
+		return 0, false
+	}
+
+	end := loop.End + 1
+	if n < len(loop.Starts) {
+		end = loop.Starts[n]
+	}
+	want := LoopIteration{Loop: label.Loop, Iteration: n}
+	for j := loop.Starts[n-1]; j < end; j++ {
+		if li.labels[j] == want && events[j].File == events[idx].File && events[j].Line == events[idx].Line {
+			return j, true
+		}
+	}
+	return loop.Starts[n-1], true
+}
@@ -0,0 +1,118 @@
+package replay
+
+import (
+	"testing"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+// nestedLoopRecording records
+//
+//	9:  start()
+//	10: for i := 0; i < 3; i++ {
+//	11:     x := i
+//	12:     for j := 0; j < 4; j++ {
+//	13:         sum += j * x
+//	        }
+//	15:     print(sum)
+//	    }
+//	17: return
+//
+// along with the index of every event at line 13, by outer and inner iteration
+func nestedLoopRecording() ([]recorder.Event, [3][4]int) {
+	var events []recorder.Event
+	var inner [3][4]int
+	add := func(line int) {
+		events = append(events, recorder.Event{ID: int64(len(events) + 1), Type: recorder.StatementExecution,
+			File: "main.go", Line: line, FuncName: "main.main", GoroutineID: 1})
+	}
+	add(9)
+	for i := 0; i < 3; i++ {
+		add(10)
+		add(11)
+		for j := 0; j < 4; j++ {
+			add(12)
+			inner[i][j] = len(events)
+			add(13)
+		}
+		add(15)
+	}
+	add(17)
+	return events, inner
+}
+
+func TestIndexLoopsNested(t *testing.T) {
+	events, inner := nestedLoopRecording()
+	loops := IndexLoops(events)
+
+	// One outer loop, and an inner loop for each of its iterations
+	if len(loops.Loops) != 4 {
+		t.Fatalf("Expected 4 loops, got %d: %+v", len(loops.Loops), loops.Loops)
+	}
+	outerLabel := loops.At(1)
+	outer := loops.Loops[outerLabel.Loop]
+	if outer.Line != 10 || len(outer.Starts) != 3 {
+		t.Errorf("Expected the outer loop at line 10 with 3 iterations, got %+v", outer)
+	}
+
+	innerLoops := make(map[int]bool)
+	for i := range inner {
+		for j, idx := range inner[i] {
+			label := loops.At(idx)
+			if label.Loop < 0 || label.Iteration != j+1 {
+				t.Errorf("Expected event %d in inner iteration %d, got %+v", idx, j+1, label)
+				continue
+			}
+			loop := loops.Loops[label.Loop]
+			if loop.Line != 12 || len(loop.Starts) != 4 {
+				t.Errorf("Expected an inner loop at line 12 with 4 iterations, got %+v", loop)
+			}
+			innerLoops[label.Loop] = true
+		}
+	}
+	if len(innerLoops) != 3 {
+		t.Errorf("Expected a separate inner loop per outer iteration, got %d", len(innerLoops))
+	}
+
+	// Lines of the outer body belong to the outer loop, and the lines
+	// around the loops to none
+	for idx, e := range events {
+		label := loops.At(idx)
+		switch e.Line {
+		case 10, 11, 15:
+			if label.Loop != outerLabel.Loop {
+				t.Errorf("Expected line %d (event %d) in the outer loop, got %+v", e.Line, idx, label)
+			}
+		case 9, 17:
+			if label.Loop != -1 {
+				t.Errorf("Expected line %d outside loops, got %+v", e.Line, label)
+			}
+		}
+	}
+	if outer.End != len(events)-2 {
+		t.Errorf("Expected the outer loop to end at event %d, got %d", len(events)-2, outer.End)
+	}
+
+	// Jumping keeps to the same line in another iteration
+	if target, ok := loops.IterationTarget(events, inner[1][1], 4); !ok || target != inner[1][3] {
+		t.Errorf("Expected iteration 4 at event %d, got %d, %v", inner[1][3], target, ok)
+	}
+	if _, ok := loops.IterationTarget(events, inner[1][1], 5); ok {
+		t.Error("Expected no fifth iteration")
+	}
+}
+
+func TestIndexLoopsPerGoroutine(t *testing.T) {
+	// Two goroutines running the same line interleaved aren't a loop
+	var events []recorder.Event
+	for g := 1; g <= 2; g++ {
+		for _, line := range []int{5, 6} {
+			events = append(events, recorder.Event{Type: recorder.StatementExecution, File: "worker.go", Line: line, GoroutineID: g})
+		}
+	}
+	events[1], events[2] = events[2], events[1]
+	loops := IndexLoops(events)
+	if len(loops.Loops) != 0 {
+		t.Errorf("Expected no loops, got %+v", loops.Loops)
+	}
+}
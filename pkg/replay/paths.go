@@ -0,0 +1,84 @@
+package replay
+
+import (
+	"path"
+	"runtime"
+	"strings"
+)
+
+// PathResolver compares source paths the way the file system of an OS
+// does: on Windows and macOS, C:\Src\main.go and c:/src/main.go name the
+// same file, while on Linux case matters and a backslash is part of a name
+type PathResolver struct {
+	FoldCase       bool // Compare paths case-insensitively
+	FoldSeparators bool // Treat backslashes as separators and expand Windows path forms
+}
+
+// HostPaths compares paths for the OS ChronoGo runs on
+var HostPaths = NewPathResolver(runtime.GOOS)
+
+// NewPathResolver returns the resolver for paths on goos, e.g. runtime.GOOS
+func NewPathResolver(goos string) PathResolver {
+	switch goos {
+	case "windows", "darwin":
+		return PathResolver{FoldCase: true, FoldSeparators: true}
+	}
+	return PathResolver{}
+}
+
+// Normalize returns the form of p that compares equal for every spelling of
+// the same path. With FoldSeparators, 8.3 short names are expanded when the
+// file exists on this machine, and extended-length prefixes (\\?\C:\ and
+// \\?\UNC\) are dropped; UNC paths keep their leading //.
+func (r PathResolver) Normalize(p string) string {
+	if p == "" {
+		return ""
+	}
+	if r.FoldSeparators {
+		p = strings.ReplaceAll(longPath(p), `\`, "/")
+		if rest, ok := strings.CutPrefix(p, "//?/"); ok {
+			p = rest
+			if len(rest) >= 4 && strings.EqualFold(rest[:4], "UNC/") {
+				p = "//" + rest[4:]
+			}
+		}
+	}
+	unc := r.FoldSeparators && strings.HasPrefix(p, "//")
+	p = path.Clean(p)
+	if unc {
+		p = "/" + p
+	}
+	if r.FoldCase {
+		p = strings.ToLower(p)
+	}
+	return p
+}
+
+// Match reports whether two paths name the same source file. Paths that
+// are equal once normalized match; failing that, a path matches one that
+// ends in it after a separator, as the relative "main.go" and
+// "pkg/main.go" match "/src/app/pkg/main.go", which bySuffix reports since
+// another file with the same name would match too.
+func (r PathResolver) Match(a, b string) (ok, bySuffix bool) {
+	a, b = r.Normalize(a), r.Normalize(b)
+	if a == "" || b == "" {
+		return false, false
+	}
+	if a == b {
+		return true, false
+	}
+	if (!r.isAbs(b) && strings.HasSuffix(a, "/"+b)) || (!r.isAbs(a) && strings.HasSuffix(b, "/"+a)) {
+		return true, true
+	}
+	return false, false
+}
+
+// isAbs reports whether a normalized path is absolute, so it can't be the
+// end of another
+func (r PathResolver) isAbs(p string) bool {
+	if strings.HasPrefix(p, "/") {
+		return true
+	}
+	// A drive letter, e.g. c:/src
+	return r.FoldSeparators && len(p) >= 3 && p[1] == ':' && p[2] == '/'
+}
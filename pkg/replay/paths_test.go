@@ -0,0 +1,37 @@
+package replay
+
+import "testing"
+
+func TestPathResolverMatch(t *testing.T) {
+	tests := []struct {
+		goos     string
+		a, b     string
+		ok       bool
+		bySuffix bool
+	}{
+		{"linux", "/src/app/main.go", "/src/app/main.go", true, false},
+		{"linux", "/src/app/main.go", "/src/app/./main.go", true, false},
+		{"linux", "/src/app/Main.go", "/src/app/main.go", false, false},
+		{"linux", "/src/app/main.go", "main.go", true, true},
+		{"linux", "/src/app/main.go", "app/main.go", true, true},
+		{"linux", "/src/app/domain.go", "main.go", false, false},
+		{"linux", `/src/app/dir\main.go`, "main.go", false, false},
+		{"darwin", "/Users/me/App/main.go", "/users/me/app/main.go", true, false},
+		{"darwin", "/Users/me/app/main.go", "APP/MAIN.GO", true, true},
+		{"windows", `C:\Users\me\proj\main.go`, "c:/users/me/proj/main.go", true, false},
+		{"windows", `C:\Users\me\proj\main.go`, "main.go", true, true},
+		{"windows", `C:\Users\me\proj\main.go`, `proj/Main.go`, true, true},
+		{"windows", `C:\proj\main.go`, `D:\proj\main.go`, false, false},
+		{"windows", `\\?\C:\proj\main.go`, `C:\proj\main.go`, true, false},
+		{"windows", `\\server\share\proj\main.go`, "//server/share/proj/main.go", true, false},
+		{"windows", `\\?\UNC\server\share\main.go`, `\\server\share\main.go`, true, false},
+		{"windows", `\\server\share\main.go`, `\server\share\main.go`, false, false},
+		{"windows", "", "main.go", false, false},
+	}
+	for _, tt := range tests {
+		ok, bySuffix := NewPathResolver(tt.goos).Match(tt.a, tt.b)
+		if ok != tt.ok || bySuffix != tt.bySuffix {
+			t.Errorf("%s: Match(%q, %q) = %v, %v, want %v, %v", tt.goos, tt.a, tt.b, ok, bySuffix, tt.ok, tt.bySuffix)
+		}
+	}
+}
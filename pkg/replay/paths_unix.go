@@ -0,0 +1,9 @@
+//go:build !windows
+// +build !windows
+
+package replay
+
+// longPath returns p, as only Windows has 8.3 short names to expand
+func longPath(p string) string {
+	return p
+}
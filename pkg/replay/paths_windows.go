@@ -0,0 +1,33 @@
+//go:build windows
+// +build windows
+
+package replay
+
+import (
+	"strings"
+	"syscall"
+)
+
+// longPath expands 8.3 short names such as PROGRA~1 in p to the long names
+// they stand for, returning p unchanged if it doesn't exist
+func longPath(p string) string {
+	if !strings.Contains(p, "~") {
+		return p
+	}
+	short, err := syscall.UTF16PtrFromString(p)
+	if err != nil {
+		return p
+	}
+	buf := make([]uint16, syscall.MAX_PATH)
+	for {
+		n, err := syscall.GetLongPathName(short, &buf[0], uint32(len(buf)))
+		if err != nil || n == 0 {
+			return p
+		}
+		if n < uint32(len(buf)) {
+			return syscall.UTF16ToString(buf[:n])
+		}
+		// Too small, n is the size needed
+		buf = make([]uint16, n)
+	}
+}
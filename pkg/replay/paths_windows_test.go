@@ -0,0 +1,49 @@
+//go:build windows
+// +build windows
+
+package replay
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestUNCPathsMatch(t *testing.T) {
+	unc := `\\fileserver\builds\proj\main.go`
+	for _, file := range []string{"//fileserver/builds/proj/main.go", `\\FileServer\Builds\proj\main.go`, `\\?\UNC\fileserver\builds\proj\main.go`} {
+		if ok, bySuffix := HostPaths.Match(unc, file); !ok || bySuffix {
+			t.Errorf("Match(%q, %q) = %v, %v, want an exact match", unc, file, ok, bySuffix)
+		}
+	}
+}
+
+func TestShortPathsMatchLongPaths(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "long directory name")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	long := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(long, []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	from, err := syscall.UTF16PtrFromString(long)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]uint16, syscall.MAX_PATH)
+	n, err := syscall.GetShortPathName(from, &buf[0], uint32(len(buf)))
+	if err != nil || n == 0 {
+		t.Skipf("No short name for %s: %v", long, err)
+	}
+	short := syscall.UTF16ToString(buf[:n])
+	if short == long {
+		t.Skip("8.3 names are disabled on this volume")
+	}
+
+	if ok, bySuffix := HostPaths.Match(long, short); !ok || bySuffix {
+		t.Errorf("Match(%q, %q) = %v, %v, want an exact match", long, short, ok, bySuffix)
+	}
+}
@@ -0,0 +1,146 @@
+package replay
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+// rngRead is a single recorded read from a random number generator
+type rngRead struct {
+	isFloat  bool
+	n        int // Argument to Intn
+	intVal   int
+	floatVal float64
+}
+
+// RecordedRand serves the random values recorded by instrumentation.TracedRand
+// in order, so a re-executed program follows the same path as the recording.
+// If the program asks for a value the recording doesn't have, RecordedRand
+// falls back to a generator seeded with the recorded seed and reports the
+// divergence through Diverged.
+type RecordedRand struct {
+	mu       sync.Mutex
+	seed     int64
+	reads    []rngRead
+	pos      int
+	fallback *rand.Rand
+	diverged bool
+}
+
+// RandFromEvents builds a RecordedRand from the RngEvents in events. Only
+// one traced generator per recording is supported: RngEvents don't say which
+// TracedRand they came from, so the first seed is kept and the reads of every
+// generator are served in the order they were recorded.
+func RandFromEvents(events []recorder.Event) *RecordedRand {
+	r := &RecordedRand{}
+	seeded := false
+
+	for _, e := range events {
+		if e.Type != recorder.RngEvent {
+			continue
+		}
+
+		var seed int64
+		var n, iv int
+		var fv float64
+		if !seeded {
+			if _, err := fmt.Sscanf(e.Details, "Rand seed %d", &seed); err == nil {
+				r.seed = seed
+				seeded = true
+				continue
+			}
+		}
+		if _, err := fmt.Sscanf(e.Details, "Rand Intn(%d) = %d", &n, &iv); err == nil {
+			r.reads = append(r.reads, rngRead{n: n, intVal: iv})
+		} else if _, err := fmt.Sscanf(e.Details, "Rand Float64() = %g", &fv); err == nil {
+			r.reads = append(r.reads, rngRead{isFloat: true, floatVal: fv})
+		}
+	}
+
+	r.fallback = rand.New(rand.NewSource(r.seed))
+	return r
+}
+
+// Seed returns the recorded seed
+func (r *RecordedRand) Seed() int64 {
+	return r.seed
+}
+
+// Remaining returns the number of recorded values not yet served
+func (r *RecordedRand) Remaining() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.reads) - r.pos
+}
+
+// Diverged reports whether the program asked for a value that didn't match the recording
+func (r *RecordedRand) Diverged() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.diverged
+}
+
+// Intn returns the next recorded Intn value
+func (r *RecordedRand) Intn(n int) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.pos < len(r.reads) {
+		read := r.reads[r.pos]
+		if !read.isFloat && read.n == n {
+			r.pos++
+			return read.intVal
+		}
+	}
+
+	r.diverged = true
+	return r.fallback.Intn(n)
+}
+
+// Float64 returns the next recorded Float64 value
+func (r *RecordedRand) Float64() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.pos < len(r.reads) && r.reads[r.pos].isFloat {
+		v := r.reads[r.pos].floatVal
+		r.pos++
+		return v
+	}
+
+	r.diverged = true
+	return r.fallback.Float64()
+}
+
+// RandUsage notices, one event at a time, calls into math/rand recorded
+// without any RngEvent, which means the program drew random values
+// RandFromEvents can't replay
+type RandUsage struct {
+	calls  int
+	traced bool
+}
+
+// Add checks an event
+func (u *RandUsage) Add(event recorder.Event) {
+	switch {
+	case event.Type == recorder.RngEvent:
+		u.traced = true
+	case event.Type == recorder.FuncEntry &&
+		(strings.HasPrefix(event.FuncName, "math/rand.") || strings.HasPrefix(event.FuncName, "math/rand/v2.")):
+		u.calls++
+	}
+}
+
+// Untraced returns the number of calls into math/rand seen, or 0 if the
+// recording also has RngEvents, in which case the calls may have been made
+// through a TracedRand
+func (u *RandUsage) Untraced() int {
+	if u.traced {
+		return 0
+	}
+	return u.calls
+}
@@ -0,0 +1,76 @@
+package replay
+
+import (
+	"testing"
+
+	"github.com/willibrandon/ChronoGo/pkg/instrumentation"
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+// pickBranch mimics program logic whose path depends on random values
+func pickBranch(r instrumentation.Rand) (int, float64) {
+	sum := 0
+	for i := 0; i < 10; i++ {
+		sum += r.Intn(100)
+	}
+	return sum / 10, r.Float64()
+}
+
+func TestRandFromEvents(t *testing.T) {
+	rec := recorder.NewInMemoryRecorder()
+	instrumentation.InitInstrumentation(rec)
+	defer instrumentation.InitInstrumentation(nil)
+
+	// Record a run
+	recordedAvg, recordedFloat := pickBranch(instrumentation.NewTracedRand(12345))
+
+	events := rec.GetEvents()
+	if len(events) != 12 {
+		t.Fatalf("Expected 12 RNG events (seed, 10 Intn, 1 Float64), got %d", len(events))
+	}
+
+	// Re-execute with the recorded values
+	replayed := RandFromEvents(events)
+	if replayed.Seed() != 12345 {
+		t.Errorf("Expected seed 12345, got %d", replayed.Seed())
+	}
+
+	avg, f := pickBranch(replayed)
+	if avg != recordedAvg || f != recordedFloat {
+		t.Errorf("Replay diverged: got (%d, %v), recorded (%d, %v)", avg, f, recordedAvg, recordedFloat)
+	}
+	if replayed.Diverged() {
+		t.Error("Expected no divergence")
+	}
+	if replayed.Remaining() != 0 {
+		t.Errorf("Expected all recorded values consumed, %d remaining", replayed.Remaining())
+	}
+
+	// Asking for more values than recorded is reported as divergence
+	replayed.Intn(10)
+	if !replayed.Diverged() {
+		t.Error("Expected divergence after exhausting recorded values")
+	}
+}
+
+func TestRandUsage(t *testing.T) {
+	events := []recorder.Event{
+		{ID: 1, Type: recorder.FuncEntry, FuncName: "main.roll"},
+		{ID: 2, Type: recorder.FuncEntry, FuncName: "math/rand.Intn"},
+		{ID: 3, Type: recorder.FuncEntry, FuncName: "math/rand/v2.(*Rand).Float64"},
+		{ID: 4, Type: recorder.StatementExecution, FuncName: "math/rand.Intn"},
+	}
+
+	var usage RandUsage
+	for _, e := range events {
+		usage.Add(e)
+	}
+	if got := usage.Untraced(); got != 2 {
+		t.Errorf("Expected 2 untraced math/rand calls, got %d", got)
+	}
+
+	usage.Add(recorder.Event{ID: 5, Type: recorder.RngEvent, Details: "Rand seed 1"})
+	if got := usage.Untraced(); got != 0 {
+		t.Errorf("Expected no untraced calls once the recording has RngEvents, got %d", got)
+	}
+}
@@ -2,6 +2,7 @@ package replay
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
@@ -31,6 +32,11 @@ type Replayer interface {
 
 	// Events returns all loaded events
 	Events() []recorder.Event
+
+	// Close releases the resources held by the replayer, such as the files
+	// or connections a streaming replayer reads from. It is safe to call
+	// more than once.
+	Close() error
 }
 
 // GoroutineState tracks the state of a goroutine
@@ -53,33 +59,148 @@ type BasicReplayer struct {
 	goroutines      map[int]*GoroutineState // Track goroutine states
 	channels        map[int]*ChannelState   // Track channel states
 	activeGoroutine int                     // Currently active goroutine
+	watchpointHits  []int                   // Indices where replay stopped on a watchpoint
+	options         ReplayOptions
+	skew            ClockSkew          // Timestamps going backward in the loaded events
+	checkpoints     []replayCheckpoint // Taken interactively, see Checkpoint
+	snapshots       []stateSnapshot    // State after the recorded SnapshotEvents replayed so far, by index
+	nextCheckpoint  int                // ID of the last checkpoint taken
+	hooks           []eventHook        // Registered with OnEvent
+	nextHook        int                // ID of the last hook registered
+	start           *stateSnapshot     // State before the first event of a window, nil for a whole recording
+	offset          int                // Index in the recording of the first event of a window
+	total           int                // Events in the recording a window was loaded from
+	maxID           int64              // Largest event ID in the recording, InsertEvent numbers after it
+	printer         EventPrinter       // Renders the events ReplayUntilBreakpoint prints, nil for the built-in format
+}
+
+// EventPrinter renders the event at idx as the line replay prints for it
+type EventPrinter func(idx int, e recorder.Event) string
+
+// eventHook is a callback registered with OnEvent
+type eventHook struct {
+	id int
+	fn func(idx int, e recorder.Event)
+}
+
+// ReplayOptions controls how a BasicReplayer loads events
+type ReplayOptions struct {
+	// NormalizeTimestamps orders events by timestamp, breaking ties by ID,
+	// so that navigation by time works and durations are never negative
+	// when a recording's timestamps go backward. Without it events are
+	// replayed in the order given.
+	NormalizeTimestamps bool
+}
+
+// DefaultReplayOptions returns the default replay options
+func DefaultReplayOptions() ReplayOptions {
+	return ReplayOptions{
+		NormalizeTimestamps: true,
+	}
+}
+
+// ClockSkew describes timestamps that went backward in a recording, as
+// happens with clock adjustments or when merging recordings from several
+// goroutines or machines
+type ClockSkew struct {
+	Events int           // Events recorded with a timestamp before an earlier event's
+	Max    time.Duration // Largest step backward
 }
 
-// NewBasicReplayer creates a new BasicReplayer
+// NewBasicReplayer creates a new BasicReplayer with default options
 func NewBasicReplayer() *BasicReplayer {
+	return NewBasicReplayerWithOptions(DefaultReplayOptions())
+}
+
+// NewBasicReplayerWithOptions creates a new BasicReplayer with the given options
+func NewBasicReplayerWithOptions(options ReplayOptions) *BasicReplayer {
 	return &BasicReplayer{
 		events:          []recorder.Event{},
 		currentIdx:      -1,
 		goroutines:      make(map[int]*GoroutineState),
 		channels:        make(map[int]*ChannelState),
 		activeGoroutine: 1, // Start with main goroutine (ID 1)
+		options:         options,
 	}
 }
 
-// LoadEvents loads the given events into the replayer
+// LoadEvents loads the given events into the replayer. Any clock skew in
+// the order given is measured and reported by ClockSkew; with
+// NormalizeTimestamps the events are then ordered by timestamp. Timestamps
+// themselves are never changed, so events display as recorded.
 func (r *BasicReplayer) LoadEvents(events []recorder.Event) error {
-	r.events = events
+	r.skew = measureClockSkew(events)
+
+	// Sort a copy so the caller's slice is left untouched
+	r.events = append([]recorder.Event(nil), events...)
+	if r.options.NormalizeTimestamps {
+		recorder.StableSort(r.events)
+	}
+	r.checkpoints = nil // They point into the old events
+	r.snapshots = nil
+	r.start, r.offset, r.total = nil, 0, 0
+	r.maxID = 0
+	for _, e := range events {
+		r.maxID = max(r.maxID, e.ID)
+	}
+	r.Reset()
+
+	return nil
+}
+
+// measureClockSkew counts the events whose timestamp is before that of an
+// event given earlier
+func measureClockSkew(events []recorder.Event) ClockSkew {
+	var skew ClockSkew
+	var latest time.Time
+	for i, e := range events {
+		if i > 0 && e.Timestamp.Before(latest) {
+			skew.Events++
+			if step := latest.Sub(e.Timestamp); step > skew.Max {
+				skew.Max = step
+			}
+			continue
+		}
+		latest = e.Timestamp
+	}
+	return skew
+}
+
+// ClockSkew returns how far timestamps went backward in the loaded events
+func (r *BasicReplayer) ClockSkew() ClockSkew {
+	return r.skew
+}
+
+// Reset starts the replay over: no event is current, goroutine and channel
+// state is back to the start of the recording and watchpoint hits are
+// forgotten. Inserted events are kept.
+func (r *BasicReplayer) Reset() {
 	r.currentIdx = -1
+	r.watchpointHits = nil
+	r.resetConcurrencyState()
+}
+
+// Close does nothing, since a BasicReplayer only holds events in memory
+func (r *BasicReplayer) Close() error {
+	return nil
+}
+
+// resetConcurrencyState clears goroutine and channel tracking back to the start of the recording,
+// or of the window loaded
+func (r *BasicReplayer) resetConcurrencyState() {
+	if r.start != nil {
+		r.goroutines = copyGoroutines(r.start.goroutines)
+		r.channels = copyChannels(r.start.channels)
+		r.activeGoroutine = r.start.activeGoroutine
+		return
+	}
 
-	// Initialize concurrency tracking
 	r.goroutines = make(map[int]*GoroutineState)
 	r.channels = make(map[int]*ChannelState)
 	r.activeGoroutine = 1 // Reset to main goroutine
 
 	// Initialize the main goroutine
 	r.goroutines[1] = &GoroutineState{ID: 1, Running: true}
-
-	return nil
 }
 
 // ReplayForward replays all events from current position to the end
@@ -107,7 +228,8 @@ func (r *BasicReplayer) ReplayUntilBreakpoint(breakpointCheck func(event recorde
 		event := r.events[i]
 
 		// Process concurrency events to update goroutine and channel states
-		r.processGoroutineAndChannelEvents(event)
+		r.applyEvent(i)
+		r.notifyEvent(i)
 
 		// Check for variable changes in statements that might trigger a watchpoint
 		if event.Type == recorder.StatementExecution {
@@ -127,7 +249,9 @@ func (r *BasicReplayer) ReplayUntilBreakpoint(breakpointCheck func(event recorde
 		}
 
 		// Print event details with goroutine info for concurrency events
-		if event.Type == recorder.GoroutineSwitch ||
+		if r.printer != nil {
+			fmt.Println(r.printer(i, event))
+		} else if event.Type == recorder.GoroutineSwitch ||
 			event.Type == recorder.ChannelOperation ||
 			event.Type == recorder.SyncOperation {
 			fmt.Printf("[%s] Event %d: %s (Goroutine %d)\n",
@@ -150,6 +274,47 @@ func (r *BasicReplayer) ReplayUntilBreakpoint(breakpointCheck func(event recorde
 	return nil
 }
 
+// ReplayUntilWatchpoint replays events until watchCheck reports that a
+// watchpoint tripped or breakpointCheck reports a breakpoint hit. Either
+// check may be nil. Where a watchpoint tripped is remembered for the rest of
+// the session, see WatchpointHits.
+func (r *BasicReplayer) ReplayUntilWatchpoint(watchCheck, breakpointCheck func(event recorder.Event) bool) error {
+	tripped := false
+	err := r.ReplayUntilBreakpoint(func(event recorder.Event) bool {
+		if watchCheck != nil && watchCheck(event) {
+			tripped = true
+			return true
+		}
+		return breakpointCheck != nil && breakpointCheck(event)
+	})
+	if err != nil {
+		return err
+	}
+
+	if tripped {
+		r.addWatchpointHit(r.currentIdx)
+	}
+	return nil
+}
+
+// addWatchpointHit records a watchpoint hit at idx, keeping the hits sorted
+func (r *BasicReplayer) addWatchpointHit(idx int) {
+	pos := sort.SearchInts(r.watchpointHits, idx)
+	if pos < len(r.watchpointHits) && r.watchpointHits[pos] == idx {
+		return
+	}
+	r.watchpointHits = append(r.watchpointHits, 0)
+	copy(r.watchpointHits[pos+1:], r.watchpointHits[pos:])
+	r.watchpointHits[pos] = idx
+}
+
+// WatchpointHits returns the indices of the events where a watchpoint
+// tripped during this session, in order. They are not saved with the
+// recording.
+func (r *BasicReplayer) WatchpointHits() []int {
+	return append([]int(nil), r.watchpointHits...)
+}
+
 // processGoroutineAndChannelEvents updates the internal state based on concurrency events
 func (r *BasicReplayer) processGoroutineAndChannelEvents(event recorder.Event) {
 	switch event.Type {
@@ -242,7 +407,156 @@ func (r *BasicReplayer) ReplayToEventIndex(idx int) error {
 		return nil
 	}
 
+	r.moveTo(idx)
+	return nil
+}
+
+// moveTo makes idx the current event, keeping goroutine and channel state in
+// step. Moving forward applies the events in between; moving backward
+// rebuilds the state from the nearest snapshot before idx, or from the start
+// of the recording.
+func (r *BasicReplayer) moveTo(idx int) {
+	from := r.currentIdx
+	start := from + 1
+	if idx < from {
+		start = r.rewind(idx)
+	}
+	for i := start; i <= idx; i++ {
+		r.applyEvent(i)
+		// Events applied again only to rebuild state aren't reported
+		if i > from || i == idx {
+			r.notifyEvent(i)
+		}
+	}
 	r.currentIdx = idx
+}
+
+// applyEvent updates goroutine and channel state with the event at i,
+// remembering the state if it is a recorded snapshot
+func (r *BasicReplayer) applyEvent(i int) {
+	r.processGoroutineAndChannelEvents(r.events[i])
+	if r.events[i].Type == recorder.SnapshotEvent {
+		r.rememberSnapshot(i)
+	}
+}
+
+// OnEvent registers fn to be called with each event replay processes, as
+// ReplayForward, ReplayUntilBreakpoint and stepping pass over it. Moving
+// backward reports only the event arrived at. Callbacks run in registration
+// order; the returned func unregisters fn.
+func (r *BasicReplayer) OnEvent(fn func(idx int, e recorder.Event)) (remove func()) {
+	r.nextHook++
+	id := r.nextHook
+	r.hooks = append(r.hooks, eventHook{id: id, fn: fn})
+	return func() {
+		// A new slice, so a notification in progress keeps the old one
+		kept := make([]eventHook, 0, len(r.hooks))
+		for _, h := range r.hooks {
+			if h.id != id {
+				kept = append(kept, h)
+			}
+		}
+		r.hooks = kept
+	}
+}
+
+// SetEventPrinter renders the events ReplayForward and
+// ReplayUntilBreakpoint print with printer. nil restores the built-in format.
+func (r *BasicReplayer) SetEventPrinter(printer EventPrinter) {
+	r.printer = printer
+}
+
+// notifyEvent calls the OnEvent callbacks with the event at i
+func (r *BasicReplayer) notifyEvent(i int) {
+	for _, h := range r.hooks {
+		h.fn(i, r.events[i])
+	}
+}
+
+// SeekEnd moves to the last event, rebuilding goroutine and channel state
+// from the whole recording so replay can continue backward from the end
+func (r *BasicReplayer) SeekEnd() error {
+	if len(r.events) == 0 {
+		return fmt.Errorf("no events loaded")
+	}
+
+	r.resetConcurrencyState()
+	r.currentIdx = -1
+	r.moveTo(len(r.events) - 1)
+	return nil
+}
+
+// InsertEvent inserts a synthetic event right after the current event and
+// makes it the current event. It is used to record changes made during a
+// debugging session, such as variables set through Delve, in the timeline.
+// An event without a timestamp takes the current event's, or the first
+// event's when replay hasn't started, so timestamps stay in order; one
+// without an ID is numbered after the largest ID in the recording. Indices
+// after the inserted event, and the size of a window's recording, grow by
+// one.
+func (r *BasicReplayer) InsertEvent(event recorder.Event) error {
+	pos := r.currentIdx + 1
+	if pos < 0 || pos > len(r.events) {
+		return fmt.Errorf("invalid insert position: %d", pos)
+	}
+
+	if event.Timestamp.IsZero() {
+		switch {
+		case pos > 0:
+			event.Timestamp = r.events[pos-1].Timestamp
+		case len(r.events) > 0:
+			event.Timestamp = r.events[0].Timestamp
+		default:
+			event.Timestamp = time.Now()
+		}
+	}
+	if event.ID == 0 {
+		event.ID = r.maxID + 1
+	}
+	r.maxID = max(r.maxID, event.ID)
+	if r.start != nil {
+		r.total++
+	}
+
+	r.events = append(r.events, recorder.Event{})
+	copy(r.events[pos+1:], r.events[pos:])
+	r.events[pos] = event
+	r.currentIdx = pos
+
+	// Hits after the inserted event move with it
+	for i, hit := range r.watchpointHits {
+		if hit >= pos {
+			r.watchpointHits[i]++
+		}
+	}
+	for i := range r.checkpoints {
+		if r.checkpoints[i].Index >= pos {
+			r.checkpoints[i].Index++
+		}
+	}
+	for i := range r.snapshots {
+		if r.snapshots[i].index >= pos {
+			r.snapshots[i].index++
+		}
+	}
+	return nil
+}
+
+// AddTag tags the event at idx with label, for filtering during the rest of
+// the session. Tags are not written back to the recording.
+func (r *BasicReplayer) AddTag(idx int, label string) error {
+	if idx < 0 || idx >= len(r.events) {
+		return fmt.Errorf("event index %d out of range (0-%d)", idx, len(r.events)-1)
+	}
+	if label == "" {
+		return fmt.Errorf("empty tag")
+	}
+	if r.events[idx].HasTag(label) {
+		return nil
+	}
+	// The loaded events share their Tags arrays with the caller's slice
+	tags := append([]string(nil), r.events[idx].Tags...)
+	r.events[idx].Tags = append(tags, label)
 	return nil
 }
 
@@ -253,10 +567,27 @@ func (r *BasicReplayer) StepBackward(currentIdx int) (int, error) {
 	}
 
 	newIdx := currentIdx - 1
-	r.currentIdx = newIdx
+	r.moveTo(newIdx)
 	return newIdx, nil
 }
 
+// ActiveGoroutine returns the goroutine running at the current event
+func (r *BasicReplayer) ActiveGoroutine() int {
+	return r.activeGoroutine
+}
+
+// GoroutineAt returns the goroutine that ran the event at idx, 0 if the
+// event wasn't stamped with a GoroutineID when recorded, or -1 if idx is out
+// of range. Unstamped events aren't attributed to any goroutine: the last
+// GoroutineSwitch before them says nothing about which goroutine recorded
+// them.
+func (r *BasicReplayer) GoroutineAt(idx int) int {
+	if idx < 0 || idx >= len(r.events) {
+		return -1
+	}
+	return r.events[idx].GoroutineID
+}
+
 // CurrentIndex returns the current event index
 func (r *BasicReplayer) CurrentIndex() int {
 	return r.currentIdx
@@ -266,3 +597,211 @@ func (r *BasicReplayer) CurrentIndex() int {
 func (r *BasicReplayer) Events() []recorder.Event {
 	return r.events
 }
+
+// BucketStat summarizes a contiguous range of events for a timeline overview
+type BucketStat struct {
+	StartIdx   int                        // Index of the first event in the bucket
+	EndIdx     int                        // Index of the last event in the bucket
+	Count      int                        // Number of events in the bucket
+	TypeCounts map[recorder.EventType]int // Number of events of each type
+	Dominant   recorder.EventType         // Most frequent event type in the bucket
+}
+
+// Histogram splits the loaded events into the given number of equally sized
+// buckets and summarizes each one. If there are fewer events than buckets,
+// each event gets its own bucket.
+func (r *BasicReplayer) Histogram(buckets int) []BucketStat {
+	total := len(r.events)
+	if total == 0 || buckets <= 0 {
+		return nil
+	}
+	if buckets > total {
+		buckets = total
+	}
+
+	stats := make([]BucketStat, buckets)
+	for b := 0; b < buckets; b++ {
+		start := b * total / buckets
+		end := (b+1)*total/buckets - 1
+
+		stat := BucketStat{
+			StartIdx:   start,
+			EndIdx:     end,
+			Count:      end - start + 1,
+			TypeCounts: make(map[recorder.EventType]int),
+		}
+		for i := start; i <= end; i++ {
+			stat.TypeCounts[r.events[i].Type]++
+		}
+
+		// Pick the most frequent type, preferring the lowest type value on ties
+		best := -1
+		for t, count := range stat.TypeCounts {
+			if count > best || (count == best && t < stat.Dominant) {
+				best = count
+				stat.Dominant = t
+			}
+		}
+
+		stats[b] = stat
+	}
+
+	return stats
+}
+
+// ValueChange is a single recorded assignment to a variable
+type ValueChange struct {
+	EventIdx  int       // Index of the assignment event
+	Value     string    // Value assigned
+	Timestamp time.Time // Time of the assignment
+	FuncName  string    // Function the assignment happened in
+	Goroutine int       // Goroutine that made the assignment, 0 if unknown
+}
+
+// ParseAssignment extracts the variable name and value from the details of a
+// VarAssignment event, e.g. "x = 42", "x := 42" or "Debugger set x = 42".
+// The name is the last word before the equals sign.
+func ParseAssignment(details string) (name, value string, ok bool) {
+	eq := strings.Index(details, "=")
+	if eq <= 0 {
+		return "", "", false
+	}
+
+	lhs := strings.TrimSuffix(strings.TrimSpace(details[:eq]), ":")
+	words := strings.Fields(lhs)
+	if len(words) == 0 {
+		return "", "", false
+	}
+
+	return words[len(words)-1], strings.TrimSpace(details[eq+1:]), true
+}
+
+// ValueHistory returns every recorded assignment to varName, in recording order
+func (r *BasicReplayer) ValueHistory(varName string) []ValueChange {
+	var history []ValueChange
+	for i, e := range r.events {
+		if e.Type != recorder.VarAssignment {
+			continue
+		}
+		name, value, ok := ParseAssignment(e.Details)
+		if !ok || name != varName {
+			continue
+		}
+		history = append(history, ValueChange{
+			EventIdx:  i,
+			Value:     value,
+			Timestamp: e.Timestamp,
+			FuncName:  e.FuncName,
+			Goroutine: e.GoroutineID,
+		})
+	}
+	return history
+}
+
+// Errors returns every recorded error event, in recording order
+func (r *BasicReplayer) Errors() []recorder.Event {
+	var errs []recorder.Event
+	for _, e := range r.events {
+		if e.Type == recorder.ErrorEvent {
+			errs = append(errs, e)
+		}
+	}
+	return errs
+}
+
+// ParseError extracts the error message and dynamic type from the details of
+// an ErrorEvent, e.g. "Error in main.load: file not found (*errors.errorString)"
+// or, for a panic, "Panic in main.run: boom (string)"
+func ParseError(details string) (message, errType string, ok bool) {
+	if !strings.HasPrefix(details, "Error in ") && !strings.HasPrefix(details, "Panic in ") {
+		return "", "", false
+	}
+	sep := strings.Index(details, ": ")
+	open := strings.LastIndex(details, " (")
+	if sep < 0 || open < sep || !strings.HasSuffix(details, ")") {
+		return "", "", false
+	}
+	return details[sep+2 : open], details[open+2 : len(details)-1], true
+}
+
+// CallStack reconstructs the active call stack at event index idx from the
+// function entry and exit events leading up to it. The outermost call is first.
+//
+// A running deferred call appears as a DeferOperation frame above the
+// function that deferred it. Deferred calls run while that function unwinds,
+// so frames above it (such as the callee that panicked) are dropped when the
+// deferred call starts.
+func CallStack(events []recorder.Event, idx int) []recorder.Event {
+	if idx >= len(events) {
+		idx = len(events) - 1
+	}
+
+	var stack []recorder.Event
+	for i := 0; i <= idx; i++ {
+		e := events[i]
+		switch e.Type {
+		case recorder.FuncEntry:
+			stack = append(stack, e)
+		case recorder.FuncExit:
+			stack = popTo(stack, recorder.FuncEntry, e.FuncName)
+		case recorder.DeferOperation:
+			if strings.HasPrefix(e.Details, "Entering deferred") {
+				// Unwind to the deferring function, then run the deferred call on top of it
+				for j := len(stack) - 1; j >= 0; j-- {
+					if stack[j].Type == recorder.FuncEntry && stack[j].FuncName == e.FuncName {
+						stack = stack[:j+1]
+						break
+					}
+				}
+				stack = append(stack, e)
+			} else if strings.HasPrefix(e.Details, "Exiting deferred") {
+				stack = popTo(stack, recorder.DeferOperation, e.FuncName)
+			}
+		}
+	}
+	return stack
+}
+
+// RecoveredBy returns the index of the recover that stopped the panic
+// recorded at idx, -1 if idx isn't a panic or nothing recovered it. The
+// recover is the first one on the panicking goroutine, before it panics
+// again, in a deferred call of a function that was on the stack when it
+// panicked. Events not stamped with a GoroutineID are never matched, so an
+// unstamped panic isn't linked to any recover.
+func RecoveredBy(events []recorder.Event, idx int) int {
+	if idx < 0 || idx >= len(events) || !strings.HasPrefix(events[idx].Details, "Panic in ") {
+		return -1
+	}
+	panicking := events[idx]
+	if panicking.GoroutineID == 0 {
+		return -1
+	}
+	unwound := map[string]bool{panicking.FuncName: true}
+	for _, frame := range CallStack(events, idx) {
+		unwound[frame.FuncName] = true
+	}
+
+	for i := idx + 1; i < len(events); i++ {
+		e := events[i]
+		if e.GoroutineID != panicking.GoroutineID {
+			continue
+		}
+		if strings.HasPrefix(e.Details, "Panic in ") {
+			return -1
+		}
+		if e.Type == recorder.DeferOperation && strings.HasPrefix(e.Details, "Recovered in ") && unwound[e.FuncName] {
+			return i
+		}
+	}
+	return -1
+}
+
+// popTo pops back to and including the most recent frame of the given type for funcName
+func popTo(stack []recorder.Event, frameType recorder.EventType, funcName string) []recorder.Event {
+	for j := len(stack) - 1; j >= 0; j-- {
+		if stack[j].Type == frameType && stack[j].FuncName == funcName {
+			return stack[:j]
+		}
+	}
+	return stack
+}
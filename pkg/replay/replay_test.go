@@ -1,6 +1,10 @@
 package replay
 
 import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
@@ -270,3 +274,545 @@ func TestReplayerWithNoEvents(t *testing.T) {
 		t.Errorf("ReplayUntilBreakpoint with no events should not return error, got: %v", err)
 	}
 }
+
+func TestClose(t *testing.T) {
+	var replayer Replayer = NewBasicReplayer()
+	if err := replayer.LoadEvents([]recorder.Event{{ID: 1, Timestamp: time.Now(), Type: recorder.FuncEntry}}); err != nil {
+		t.Fatalf("Failed to load events: %v", err)
+	}
+
+	if err := replayer.Close(); err != nil {
+		t.Errorf("Close returned error: %v", err)
+	}
+	if err := replayer.Close(); err != nil {
+		t.Errorf("Second Close returned error: %v", err)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	// Create a replayer
+	replayer := NewBasicReplayer()
+
+	// Ten events: the first half mostly function entries, the second half statements
+	events := make([]recorder.Event, 10)
+	for i := range events {
+		events[i] = recorder.Event{ID: int64(i), Timestamp: time.Now(), Type: recorder.FuncEntry}
+		if i >= 4 {
+			events[i].Type = recorder.StatementExecution
+		}
+	}
+	if err := replayer.LoadEvents(events); err != nil {
+		t.Fatalf("Failed to load events: %v", err)
+	}
+
+	stats := replayer.Histogram(2)
+	if len(stats) != 2 {
+		t.Fatalf("Expected 2 buckets, got %d", len(stats))
+	}
+
+	if stats[0].StartIdx != 0 || stats[0].EndIdx != 4 || stats[0].Count != 5 {
+		t.Errorf("Unexpected first bucket range: %+v", stats[0])
+	}
+	if stats[0].Dominant != recorder.FuncEntry {
+		t.Errorf("Expected first bucket to be dominated by FuncEntry, got %s", stats[0].Dominant)
+	}
+	if stats[1].StartIdx != 5 || stats[1].EndIdx != 9 || stats[1].Count != 5 {
+		t.Errorf("Unexpected second bucket range: %+v", stats[1])
+	}
+	if stats[1].Dominant != recorder.StatementExecution {
+		t.Errorf("Expected second bucket to be dominated by StatementExecution, got %s", stats[1].Dominant)
+	}
+
+	// More buckets than events gives one bucket per event
+	if stats := replayer.Histogram(100); len(stats) != len(events) {
+		t.Errorf("Expected %d buckets, got %d", len(events), len(stats))
+	}
+
+	// No events gives no buckets
+	if err := replayer.LoadEvents(nil); err != nil {
+		t.Fatalf("Failed to load events: %v", err)
+	}
+	if stats := replayer.Histogram(10); stats != nil {
+		t.Errorf("Expected no buckets for empty replayer, got %d", len(stats))
+	}
+}
+
+func TestInsertEvent(t *testing.T) {
+	// Create a replayer
+	replayer := NewBasicReplayer()
+
+	events := []recorder.Event{
+		{ID: 1, Timestamp: time.Now(), Type: recorder.FuncEntry, Details: "Entering main"},
+		{ID: 2, Timestamp: time.Now().Add(time.Millisecond), Type: recorder.FuncExit, Details: "Exiting main"},
+	}
+	if err := replayer.LoadEvents(events); err != nil {
+		t.Fatalf("Failed to load events: %v", err)
+	}
+	if err := replayer.ReplayToEventIndex(0); err != nil {
+		t.Fatalf("Failed to move to event: %v", err)
+	}
+
+	// Insert a synthetic assignment after the current event
+	err := replayer.InsertEvent(recorder.Event{ID: 99, Type: recorder.VarAssignment, Details: "Debugger set x = 5"})
+	if err != nil {
+		t.Fatalf("Failed to insert event: %v", err)
+	}
+
+	got := replayer.Events()
+	if len(got) != 3 {
+		t.Fatalf("Expected 3 events, got %d", len(got))
+	}
+	if got[0].ID != 1 || got[1].ID != 99 || got[2].ID != 2 {
+		t.Errorf("Unexpected event order: %d, %d, %d", got[0].ID, got[1].ID, got[2].ID)
+	}
+	if replayer.CurrentIndex() != 1 {
+		t.Errorf("Expected current index 1, got %d", replayer.CurrentIndex())
+	}
+
+	// Without a timestamp or ID, the event takes the current event's
+	// timestamp and the next ID, so the timeline stays in order
+	if err := replayer.InsertEvent(recorder.Event{Type: recorder.VarAssignment, Details: "Debugger set x = 6"}); err != nil {
+		t.Fatalf("Failed to insert event: %v", err)
+	}
+	got = replayer.Events()
+	if !got[2].Timestamp.Equal(got[1].Timestamp) || got[2].ID != 100 {
+		t.Errorf("Expected the timestamp of the event before and ID 100, got %v and %d", got[2].Timestamp, got[2].ID)
+	}
+	if skew := measureClockSkew(got); skew.Events != 0 {
+		t.Errorf("Expected timestamps to stay in order, got %+v", skew)
+	}
+}
+
+func TestInsertEventInWindow(t *testing.T) {
+	window, err := ScanEventWindow(scanSlice(windowRecording(time.Now())), WindowBound{Index: 4}, WindowBound{Index: 6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayer := NewBasicReplayer()
+	if err := replayer.LoadWindow(window); err != nil {
+		t.Fatal(err)
+	}
+	if err := replayer.InsertEvent(recorder.Event{Type: recorder.VarAssignment, Details: "Debugger set x = 5"}); err != nil {
+		t.Fatalf("Failed to insert event: %v", err)
+	}
+	if offset, total := replayer.Window(); offset != 4 || total != 10 {
+		t.Errorf("Expected window at 4 of 10 events, got %d of %d", offset, total)
+	}
+	if e := replayer.Events()[0]; !e.Timestamp.Equal(replayer.Events()[1].Timestamp) || e.ID != 10 {
+		t.Errorf("Expected the first loaded event's timestamp and ID 10, got %v and %d", e.Timestamp, e.ID)
+	}
+}
+
+func TestValueHistory(t *testing.T) {
+	replayer := NewBasicReplayer()
+
+	base := time.Now()
+	at := func(ms int) time.Time { return base.Add(time.Duration(ms) * time.Millisecond) }
+	events := []recorder.Event{
+		{ID: 1, Timestamp: at(0), Type: recorder.FuncEntry, FuncName: "main", Details: "Entering main"},
+		{ID: 2, Timestamp: at(1), Type: recorder.VarAssignment, FuncName: "main", Details: "total = 0"},
+		{ID: 3, Timestamp: at(2), Type: recorder.FuncEntry, FuncName: "add", Details: "Entering add"},
+		{ID: 4, Timestamp: at(3), Type: recorder.VarAssignment, FuncName: "add", Details: "total := 5"},
+		{ID: 5, Timestamp: at(4), Type: recorder.VarAssignment, FuncName: "add", Details: "subtotal = 5"},
+		{ID: 6, Timestamp: at(5), Type: recorder.FuncExit, FuncName: "add", Details: "Exiting add"},
+		{ID: 7, Timestamp: at(6), Type: recorder.StatementExecution, FuncName: "main", Details: "total = total * 2"},
+		{ID: 8, Timestamp: at(7), Type: recorder.VarAssignment, FuncName: "double", Details: "total = 10"},
+		{ID: 9, Timestamp: at(8), Type: recorder.VarAssignment, FuncName: "main", Details: "Debugger set total = 42"},
+	}
+	if err := replayer.LoadEvents(events); err != nil {
+		t.Fatalf("Failed to load events: %v", err)
+	}
+
+	history := replayer.ValueHistory("total")
+	expected := []ValueChange{
+		{EventIdx: 1, Value: "0", Timestamp: at(1), FuncName: "main"},
+		{EventIdx: 3, Value: "5", Timestamp: at(3), FuncName: "add"},
+		{EventIdx: 7, Value: "10", Timestamp: at(7), FuncName: "double"},
+		{EventIdx: 8, Value: "42", Timestamp: at(8), FuncName: "main"},
+	}
+	if len(history) != len(expected) {
+		t.Fatalf("Expected %d changes, got %d: %+v", len(expected), len(history), history)
+	}
+	for i, change := range history {
+		if change.EventIdx != expected[i].EventIdx || change.Value != expected[i].Value ||
+			!change.Timestamp.Equal(expected[i].Timestamp) || change.FuncName != expected[i].FuncName {
+			t.Errorf("Change %d: expected %+v, got %+v", i, expected[i], change)
+		}
+	}
+
+	if history := replayer.ValueHistory("missing"); len(history) != 0 {
+		t.Errorf("Expected no history for unassigned variable, got %+v", history)
+	}
+}
+
+func TestSeekEnd(t *testing.T) {
+	replayer := NewBasicReplayer()
+
+	if err := replayer.SeekEnd(); err == nil {
+		t.Errorf("Expected error seeking to the end with no events")
+	}
+
+	events := []recorder.Event{
+		{ID: 1, Timestamp: time.Now(), Type: recorder.GoroutineSwitch, Details: "Goroutine 2 created"},
+		{ID: 2, Timestamp: time.Now().Add(time.Millisecond), Type: recorder.GoroutineSwitch, Details: "Goroutine switch from 1 to 2"},
+		{ID: 3, Timestamp: time.Now().Add(2 * time.Millisecond), Type: recorder.ChannelOperation, Details: "Channel 1: send by goroutine 2"},
+		{ID: 4, Timestamp: time.Now().Add(3 * time.Millisecond), Type: recorder.ChannelOperation, Details: "Channel 1: closed by goroutine 2"},
+		{ID: 5, Timestamp: time.Now().Add(4 * time.Millisecond), Type: recorder.FuncExit, Details: "Exiting main"},
+	}
+	if err := replayer.LoadEvents(events); err != nil {
+		t.Fatalf("Failed to load events: %v", err)
+	}
+
+	if err := replayer.SeekEnd(); err != nil {
+		t.Fatalf("Failed to seek to end: %v", err)
+	}
+	if replayer.CurrentIndex() != len(events)-1 {
+		t.Errorf("Expected current index %d, got %d", len(events)-1, replayer.CurrentIndex())
+	}
+
+	// Concurrency state reflects the whole recording
+	if replayer.activeGoroutine != 2 {
+		t.Errorf("Expected active goroutine 2, got %d", replayer.activeGoroutine)
+	}
+	if g, ok := replayer.goroutines[1]; !ok || g.Running {
+		t.Errorf("Expected goroutine 1 to be tracked and not running")
+	}
+	if ch, ok := replayer.channels[1]; !ok || !ch.Closed {
+		t.Errorf("Expected channel 1 to be tracked and closed")
+	}
+
+	// Seeking again doesn't accumulate state
+	if err := replayer.SeekEnd(); err != nil {
+		t.Fatalf("Failed to seek to end: %v", err)
+	}
+	if len(replayer.goroutines) != 2 {
+		t.Errorf("Expected 2 goroutines after seeking twice, got %d", len(replayer.goroutines))
+	}
+
+	if _, err := replayer.StepBackward(replayer.CurrentIndex()); err != nil {
+		t.Errorf("Expected to step backward from the end: %v", err)
+	}
+}
+
+func TestClockSkew(t *testing.T) {
+	base := time.Now()
+	offsets := []time.Duration{0, 10, 20, 5, 30, 12, 40, 40} // Milliseconds; 5 and 12 went backward
+	var events []recorder.Event
+	for i, offset := range offsets {
+		events = append(events, recorder.Event{ID: int64(i + 1), Timestamp: base.Add(offset * time.Millisecond),
+			Type: recorder.StatementExecution, Details: fmt.Sprintf("statement %d", i)})
+	}
+	// Shuffle the recording, as merging several recordings might
+	events[1], events[6] = events[6], events[1]
+
+	replayer := NewBasicReplayer()
+	if err := replayer.LoadEvents(events); err != nil {
+		t.Fatalf("Failed to load events: %v", err)
+	}
+
+	skew := replayer.ClockSkew()
+	if skew.Events != 5 || skew.Max != 35*time.Millisecond {
+		t.Errorf("Expected 5 events skewed by up to 35ms, got %+v", skew)
+	}
+
+	// Navigation follows timestamps, with ties in ID order
+	loaded := replayer.Events()
+	expectedIDs := []int64{1, 4, 2, 6, 3, 5, 7, 8}
+	for i, id := range expectedIDs {
+		if loaded[i].ID != id {
+			t.Fatalf("Event %d: expected ID %d, got %d", i, id, loaded[i].ID)
+		}
+		if i > 0 && loaded[i].Timestamp.Before(loaded[i-1].Timestamp) {
+			t.Errorf("Event %d: timestamp goes backward", i)
+		}
+	}
+	// Original timestamps are kept for display
+	if !loaded[1].Timestamp.Equal(base.Add(5 * time.Millisecond)) {
+		t.Errorf("Expected the original timestamp, got %v", loaded[1].Timestamp)
+	}
+
+	if err := replayer.ReplayToEventIndex(5); err != nil {
+		t.Fatalf("Failed to replay to event 5: %v", err)
+	}
+	if idx, err := replayer.StepBackward(replayer.CurrentIndex()); err != nil || idx != 4 {
+		t.Errorf("Expected to step back to event 4, got %d (%v)", idx, err)
+	}
+
+	// Without normalization events are replayed as given
+	unordered := NewBasicReplayerWithOptions(ReplayOptions{NormalizeTimestamps: false})
+	if err := unordered.LoadEvents(events); err != nil {
+		t.Fatalf("Failed to load events: %v", err)
+	}
+	if unordered.Events()[1].ID != 7 || unordered.ClockSkew().Events != 5 {
+		t.Errorf("Expected file order with the skew reported, got %+v", unordered.ClockSkew())
+	}
+}
+
+func TestCallStackDefer(t *testing.T) {
+	// process calls parse, which panics; process's deferred call recovers
+	events := []recorder.Event{
+		{ID: 1, Type: recorder.FuncEntry, FuncName: "main.process", Details: "Entering main.process"},
+		{ID: 2, Type: recorder.FuncEntry, FuncName: "main.parse", Details: "Entering main.parse"},
+		{ID: 3, Type: recorder.DeferOperation, FuncName: "main.process", Details: "Entering deferred call in main.process at demo.go:42"},
+		{ID: 4, Type: recorder.DeferOperation, FuncName: "main.process", Details: "Recovered in main.process: empty field"},
+		{ID: 5, Type: recorder.DeferOperation, FuncName: "main.process", Details: "Exiting deferred call in main.process at demo.go:43"},
+		{ID: 6, Type: recorder.FuncExit, FuncName: "main.process", Details: "Exiting main.process"},
+	}
+
+	frames := func(idx int) []string {
+		var names []string
+		for _, frame := range CallStack(events, idx) {
+			name := frame.FuncName
+			if frame.Type == recorder.DeferOperation {
+				name = "defer " + name
+			}
+			names = append(names, name)
+		}
+		return names
+	}
+
+	expected := [][]string{
+		{"main.process"},
+		{"main.process", "main.parse"},
+		// parse never recorded an exit; it was unwound by the panic
+		{"main.process", "defer main.process"},
+		{"main.process", "defer main.process"},
+		{"main.process"},
+		nil,
+	}
+	for idx, want := range expected {
+		got := frames(idx)
+		if len(got) != len(want) {
+			t.Errorf("Event %d: expected stack %v, got %v", idx, want, got)
+			continue
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("Event %d: expected stack %v, got %v", idx, want, got)
+				break
+			}
+		}
+	}
+}
+
+func TestRecoveredBy(t *testing.T) {
+	// parse panics twice: process recovers the first panic, and the second
+	// one, on goroutine 2, is never recovered
+	events := []recorder.Event{
+		{ID: 1, Type: recorder.FuncEntry, FuncName: "main.process", GoroutineID: 1},
+		{ID: 2, Type: recorder.FuncEntry, FuncName: "main.parse", GoroutineID: 1},
+		{ID: 3, Type: recorder.ErrorEvent, FuncName: "main.parse", Details: "Panic in main.parse: empty field (string)", GoroutineID: 1},
+		{ID: 4, Type: recorder.DeferOperation, FuncName: "main.other", Details: "Recovered in main.other: unrelated", GoroutineID: 1, Deferred: true},
+		{ID: 5, Type: recorder.DeferOperation, FuncName: "main.process", Details: "Recovered in main.process: empty field", GoroutineID: 2, Deferred: true},
+		{ID: 6, Type: recorder.DeferOperation, FuncName: "main.process", Details: "Recovered in main.process: empty field", GoroutineID: 1, Deferred: true},
+		{ID: 7, Type: recorder.ErrorEvent, FuncName: "main.parse", Details: "Panic in main.parse: again (string)", GoroutineID: 2},
+	}
+	if got := RecoveredBy(events, 2); got != 5 {
+		t.Errorf("Expected process's recover on the same goroutine, got %d", got)
+	}
+	if got := RecoveredBy(events, 6); got != -1 {
+		t.Errorf("Expected the second panic to be unrecovered, got %d", got)
+	}
+	if got := RecoveredBy(events, 0); got != -1 {
+		t.Errorf("Expected no recover for a function entry, got %d", got)
+	}
+
+	// Without goroutine IDs nothing says the recover ran where it panicked
+	for i := range events {
+		events[i].GoroutineID = 0
+	}
+	if got := RecoveredBy(events, 2); got != -1 {
+		t.Errorf("Expected an unstamped panic not to be linked, got %d", got)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	base := time.Now()
+	events := []recorder.Event{
+		{ID: 1, Timestamp: base, Type: recorder.FuncEntry, Details: "Entering main.load"},
+		{ID: 2, Timestamp: base.Add(1 * time.Millisecond), Type: recorder.ErrorEvent, Details: "Error in main.load: config missing (*errors.errorString)"},
+		{ID: 3, Timestamp: base.Add(2 * time.Millisecond), Type: recorder.StatementExecution, Details: "retry"},
+		{ID: 4, Timestamp: base.Add(3 * time.Millisecond), Type: recorder.ErrorEvent, Details: "Error in main.parse: bad value (x) (*main.ParseError)"},
+	}
+
+	replayer := NewBasicReplayer()
+	if err := replayer.LoadEvents(events); err != nil {
+		t.Fatalf("Failed to load events: %v", err)
+	}
+
+	errs := replayer.Errors()
+	if len(errs) != 2 || errs[0].ID != 2 || errs[1].ID != 4 {
+		t.Fatalf("Expected error events 2 and 4, got %+v", errs)
+	}
+
+	message, errType, ok := ParseError(errs[1].Details)
+	if !ok || message != "bad value (x)" || errType != "*main.ParseError" {
+		t.Errorf("Unexpected parse of %q: %q, %q, %v", errs[1].Details, message, errType, ok)
+	}
+	if _, _, ok := ParseError("Entering main.load"); ok {
+		t.Errorf("Expected non-error details not to parse")
+	}
+}
+
+func TestGoroutineAt(t *testing.T) {
+	base := time.Now()
+	events := []recorder.Event{
+		{ID: 1, Timestamp: base, Type: recorder.FuncEntry, FuncName: "main.main"},
+		{ID: 2, Timestamp: base.Add(1), Type: recorder.GoroutineSwitch, Details: "Goroutine switch from 1 to 2"},
+		{ID: 3, Timestamp: base.Add(2), Type: recorder.FuncEntry, FuncName: "main.worker"},
+		{ID: 4, Timestamp: base.Add(3), Type: recorder.VarAssignment, Details: "x = 1", GoroutineID: 1}, // Stamped
+		{ID: 5, Timestamp: base.Add(4), Type: recorder.FuncExit, FuncName: "main.worker"},
+	}
+	r := NewBasicReplayer()
+	if err := r.LoadEvents(events); err != nil {
+		t.Fatalf("Failed to load events: %v", err)
+	}
+
+	// Stamped events belong to the goroutine that recorded them; unstamped
+	// ones aren't attributed, whatever goroutine was last switched to
+	want := []int{0, 0, 0, 1, 0}
+	for idx, g := range want {
+		if got := r.GoroutineAt(idx); got != g {
+			t.Errorf("GoroutineAt(%d) = %d, want %d", idx, got, g)
+		}
+	}
+	if r.GoroutineAt(-1) != -1 || r.GoroutineAt(len(events)) != -1 {
+		t.Error("Expected -1 for indices out of range")
+	}
+}
+
+// concurrencyState describes goroutine and channel state for comparison,
+// e.g. "active=2 g1=false g2=true ch1=open ch2=closed"
+func concurrencyState(r *BasicReplayer) string {
+	var parts []string
+	for id, g := range r.goroutines {
+		parts = append(parts, fmt.Sprintf("g%d=%v", id, g.Running))
+	}
+	for id, ch := range r.channels {
+		state := "open"
+		if ch.Closed {
+			state = "closed"
+		}
+		parts = append(parts, fmt.Sprintf("ch%d=%s", id, state))
+	}
+	sort.Strings(parts)
+	return fmt.Sprintf("active=%d %s", r.activeGoroutine, strings.Join(parts, " "))
+}
+
+func TestJumpsRebuildState(t *testing.T) {
+	base := time.Now()
+	var events []recorder.Event
+	add := func(t recorder.EventType, details string) {
+		events = append(events, recorder.Event{
+			ID:        int64(len(events) + 1),
+			Timestamp: base.Add(time.Duration(len(events)) * time.Millisecond),
+			Type:      t,
+			Details:   details,
+		})
+	}
+	add(recorder.GoroutineSwitch, "Goroutine 2 created")
+	add(recorder.ChannelOperation, "Channel 1: send by goroutine 1")
+	add(recorder.GoroutineSwitch, "Goroutine switch from 1 to 2")
+	add(recorder.SnapshotEvent, "Snapshot created")
+	add(recorder.ChannelOperation, "Channel 1: receive by goroutine 2")
+	add(recorder.ChannelOperation, "Channel 1: closed by goroutine 2")
+	add(recorder.GoroutineSwitch, "Goroutine 3 created")
+	add(recorder.ChannelOperation, "Channel 2: send by goroutine 3")
+	add(recorder.GoroutineSwitch, "Goroutine switch from 2 to 3")
+	add(recorder.SnapshotEvent, "Snapshot created")
+	add(recorder.ChannelOperation, "Channel 2: closed by goroutine 3")
+	add(recorder.GoroutineSwitch, "Goroutine switch from 3 to 1")
+
+	// The state at each index, replaying every event from the start
+	want := make([]string, len(events))
+	for i := range events {
+		fresh := NewBasicReplayer()
+		if err := fresh.LoadEvents(events); err != nil {
+			t.Fatal(err)
+		}
+		fresh.resetConcurrencyState()
+		for j := 0; j <= i; j++ {
+			fresh.processGoroutineAndChannelEvents(events[j])
+		}
+		want[i] = concurrencyState(fresh)
+	}
+
+	r := NewBasicReplayer()
+	if err := r.LoadEvents(events); err != nil {
+		t.Fatal(err)
+	}
+	for _, idx := range []int{5, 11, 2, 10, 0, 7, 3, 9, 4, 11, 6, 1, 8} {
+		if err := r.ReplayToEventIndex(idx); err != nil {
+			t.Fatalf("ReplayToEventIndex(%d) failed: %v", idx, err)
+		}
+		if got := concurrencyState(r); got != want[idx] {
+			t.Errorf("After jumping to %d: got %s, want %s", idx, got, want[idx])
+		}
+	}
+
+	// Channel 1 is closed once event 5 has been replayed, and only then
+	r.ReplayToEventIndex(4)
+	if r.channels[1].Closed {
+		t.Error("Expected channel 1 to be open before its close")
+	}
+	r.ReplayToEventIndex(10)
+	if !r.channels[1].Closed || !r.channels[2].Closed {
+		t.Error("Expected both channels to be closed at the end")
+	}
+
+	// Both snapshots were passed, so jumping back rebuilds from the nearest one
+	if len(r.snapshots) != 2 {
+		t.Fatalf("Expected 2 remembered snapshots, got %d", len(r.snapshots))
+	}
+	if start := r.rewind(8); start != 4 {
+		t.Errorf("Expected rewinding to 8 to resume after the snapshot at 3, got %d", start)
+	}
+	if start := r.rewind(2); start != 0 {
+		t.Errorf("Expected rewinding to 2 to start over, got %d", start)
+	}
+}
+
+func TestOnEvent(t *testing.T) {
+	var events []recorder.Event
+	for i := 0; i < 5; i++ {
+		events = append(events, recorder.Event{ID: int64(i + 1), Timestamp: time.Now(), Type: recorder.StatementExecution, Details: fmt.Sprintf("statement %d", i)})
+	}
+	replayer := NewBasicReplayer()
+	if err := replayer.LoadEvents(events); err != nil {
+		t.Fatalf("Failed to load events: %v", err)
+	}
+
+	var seen []recorder.Event
+	var indices []int
+	removeSeen := replayer.OnEvent(func(idx int, e recorder.Event) { seen = append(seen, e) })
+	removeIndices := replayer.OnEvent(func(idx int, e recorder.Event) { indices = append(indices, idx) })
+
+	// Stepping reports each event passed over, and jumping back only the
+	// event arrived at
+	replayer.ReplayToEventIndex(2)
+	replayer.StepBackward(2)
+	if fmt.Sprint(indices) != "[0 1 2 1]" {
+		t.Errorf("Expected events 0 to 2, then 1, got %v", indices)
+	}
+
+	removeIndices()
+	replayer.Reset()
+	seen = nil
+	if err := replayer.ReplayForward(); err != nil {
+		t.Fatalf("ReplayForward failed: %v", err)
+	}
+	if !reflect.DeepEqual(seen, replayer.Events()) {
+		t.Errorf("Expected the callback to see every event in order, got %+v", seen)
+	}
+	if len(indices) != 4 {
+		t.Errorf("Expected no calls after unregistering, got %v", indices)
+	}
+
+	removeSeen()
+	replayer.Reset()
+	seen = nil
+	replayer.ReplayToEventIndex(4)
+	if len(seen) != 0 {
+		t.Errorf("Expected no callbacks left, got %d calls", len(seen))
+	}
+}
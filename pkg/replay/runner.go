@@ -0,0 +1,91 @@
+package replay
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+// DeterministicRunner re-drives an instrumented program with the
+// nondeterministic inputs captured in a recording. The program calls Now,
+// Intn, Float64 and SelectChoice where it called instrumentation.Now,
+// TracedRand and instrumentation.RecordSelect while recording, and gets the
+// recorded values back in order, reproducing the recorded execution.
+type DeterministicRunner struct {
+	clock *Clock
+	rand  *RecordedRand
+
+	mu        sync.Mutex
+	selects   []int
+	selectPos int
+	diverged  bool
+}
+
+// NewDeterministicRunner builds a runner from the nondeterministic input events in a recording
+func NewDeterministicRunner(events []recorder.Event) *DeterministicRunner {
+	r := &DeterministicRunner{
+		clock: ClockFromEvents(events),
+		rand:  RandFromEvents(events),
+	}
+
+	for _, e := range events {
+		if e.Type != recorder.SelectEvent {
+			continue
+		}
+
+		var choice, n int
+		if _, err := fmt.Sscanf(e.Details, "Select chose case %d of %d", &choice, &n); err != nil {
+			fmt.Printf("Warning: Could not parse select choice from %s: %v\n", e.Details, err)
+			continue
+		}
+		r.selects = append(r.selects, choice)
+	}
+
+	return r
+}
+
+// Now returns the next recorded wall clock reading
+func (r *DeterministicRunner) Now() time.Time {
+	return r.clock.Now()
+}
+
+// Intn returns the next recorded Intn value
+func (r *DeterministicRunner) Intn(n int) int {
+	return r.rand.Intn(n)
+}
+
+// Float64 returns the next recorded Float64 value
+func (r *DeterministicRunner) Float64() float64 {
+	return r.rand.Float64()
+}
+
+// SelectChoice returns the case the next select statement with n cases chose while recording
+func (r *DeterministicRunner) SelectChoice(n int) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.selectPos < len(r.selects) && r.selects[r.selectPos] < n {
+		choice := r.selects[r.selectPos]
+		r.selectPos++
+		return choice
+	}
+
+	r.diverged = true
+	return 0
+}
+
+// Diverged reports whether the program asked for an input the recording didn't have
+func (r *DeterministicRunner) Diverged() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.diverged || r.clock.Diverged() || r.rand.Diverged()
+}
+
+// Remaining returns the number of recorded inputs not yet consumed
+func (r *DeterministicRunner) Remaining() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.selects) - r.selectPos + r.clock.Remaining() + r.rand.Remaining()
+}
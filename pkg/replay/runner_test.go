@@ -0,0 +1,68 @@
+package replay
+
+import (
+	"testing"
+	"time"
+
+	"github.com/willibrandon/ChronoGo/pkg/instrumentation"
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+// inputs is the set of nondeterministic inputs a program under test reads
+type inputs struct {
+	now    func() time.Time
+	intn   func(n int) int
+	choose func(n int) int
+}
+
+// flakyProgram produces a result from the clock, a random value and a select choice
+func flakyProgram(in inputs) (time.Time, int, int) {
+	return in.now(), in.intn(1000), in.choose(3)
+}
+
+func TestDeterministicRunner(t *testing.T) {
+	rec := recorder.NewInMemoryRecorder()
+	instrumentation.InitInstrumentation(rec)
+	defer instrumentation.InitInstrumentation(nil)
+
+	// Record a run
+	rnd := instrumentation.NewTracedRand(time.Now().UnixNano())
+	recordedTime, recordedInt, recordedChoice := flakyProgram(inputs{
+		now:  instrumentation.Now,
+		intn: rnd.Intn,
+		choose: func(n int) int {
+			instrumentation.RecordSelect(2, n)
+			return 2
+		},
+	})
+
+	// Re-execute with the runner
+	runner := NewDeterministicRunner(rec.GetEvents())
+	gotTime, gotInt, gotChoice := flakyProgram(inputs{
+		now:    runner.Now,
+		intn:   runner.Intn,
+		choose: runner.SelectChoice,
+	})
+
+	if !gotTime.Equal(recordedTime) {
+		t.Errorf("Expected time %v, got %v", recordedTime, gotTime)
+	}
+	if gotInt != recordedInt {
+		t.Errorf("Expected random value %d, got %d", recordedInt, gotInt)
+	}
+	if gotChoice != recordedChoice {
+		t.Errorf("Expected select choice %d, got %d", recordedChoice, gotChoice)
+	}
+	if runner.Diverged() {
+		t.Error("Expected no divergence")
+	}
+	if runner.Remaining() != 0 {
+		t.Errorf("Expected all inputs consumed, %d remaining", runner.Remaining())
+	}
+
+	// Reading more inputs than were recorded is divergence
+	runner.SelectChoice(3)
+	if !runner.Diverged() {
+		t.Error("Expected divergence after exhausting recorded select choices")
+	}
+}
@@ -0,0 +1,304 @@
+package replay
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+// OtherFunctions is the name events are counted under once
+// StatsOptions.MaxFunctions distinct functions have been seen
+const OtherFunctions = "(other)"
+
+// maxOpenCalls bounds the unmatched entries remembered per function
+const maxOpenCalls = 1024
+
+// StatsOptions configures the aggregation done by a StatsCollector
+type StatsOptions struct {
+	BucketWidth  time.Duration // Width of the time buckets event types are counted in
+	MaxFunctions int           // Functions counted by name, 0 for no limit; later ones go to OtherFunctions
+}
+
+// DefaultStatsOptions returns one-second buckets and up to 1000 functions
+func DefaultStatsOptions() StatsOptions {
+	return StatsOptions{
+		BucketWidth:  time.Second,
+		MaxFunctions: 1000,
+	}
+}
+
+// FunctionStats aggregates the events of one function. Durations come from
+// pairing each FunctionExit with the latest unmatched FunctionEntry of the
+// function, so calls interleaved across goroutines are approximate.
+type FunctionStats struct {
+	Name          string
+	Calls         int           // FunctionEntry events
+	Events        int           // Events of any type recorded in the function
+	Completed     int           // Calls with a matching FunctionExit
+	TotalDuration time.Duration // Summed duration of completed calls
+	MaxDuration   time.Duration // Longest completed call
+}
+
+// MeanDuration returns the average duration of completed calls
+func (f FunctionStats) MeanDuration() time.Duration {
+	if f.Completed == 0 {
+		return 0
+	}
+	return f.TotalDuration / time.Duration(f.Completed)
+}
+
+// TimeBucket counts the events of each type in a span of the recording
+type TimeBucket struct {
+	Start      time.Time
+	TypeCounts map[recorder.EventType]int
+}
+
+// Stats summarizes a recording
+type Stats struct {
+	Events      int
+	Start, End  time.Time // Earliest and latest event timestamps
+	BucketWidth time.Duration
+	Functions   []FunctionStats // Most events first, OtherFunctions last
+	Buckets     []TimeBucket    // In time order; spans without events are left out
+}
+
+// StatsCollector aggregates events one at a time, so a recording can be
+// summarized while it is streamed from disk. Memory is bounded by the number
+// of functions, MaxFunctions, and the number of non-empty time buckets.
+type StatsCollector struct {
+	opts      StatsOptions
+	stats     Stats
+	functions map[string]*FunctionStats
+	entries   map[string][]time.Time // Unmatched entry times per function
+	buckets   map[int64]map[recorder.EventType]int
+}
+
+// NewStatsCollector creates a collector with the given options
+func NewStatsCollector(opts StatsOptions) *StatsCollector {
+	if opts.BucketWidth <= 0 {
+		opts.BucketWidth = DefaultStatsOptions().BucketWidth
+	}
+	return &StatsCollector{
+		opts:      opts,
+		stats:     Stats{BucketWidth: opts.BucketWidth},
+		functions: make(map[string]*FunctionStats),
+		entries:   make(map[string][]time.Time),
+		buckets:   make(map[int64]map[recorder.EventType]int),
+	}
+}
+
+// Add counts an event
+func (c *StatsCollector) Add(event recorder.Event) {
+	c.stats.Events++
+	if c.stats.Events == 1 || event.Timestamp.Before(c.stats.Start) {
+		c.stats.Start = event.Timestamp
+	}
+	if event.Timestamp.After(c.stats.End) {
+		c.stats.End = event.Timestamp
+	}
+
+	// Buckets are aligned to the bucket width, so they don't depend on
+	// which event came first
+	key := event.Timestamp.UnixNano() / int64(c.opts.BucketWidth)
+	counts := c.buckets[key]
+	if counts == nil {
+		counts = make(map[recorder.EventType]int)
+		c.buckets[key] = counts
+	}
+	counts[event.Type]++
+
+	if event.FuncName == "" {
+		return
+	}
+	fn := c.function(event.FuncName)
+	fn.Events++
+	switch event.Type {
+	case recorder.FuncEntry:
+		fn.Calls++
+		if fn.Name == OtherFunctions {
+			return
+		}
+		open := c.entries[fn.Name]
+		if len(open) == maxOpenCalls {
+			// Calls that never exited, e.g. panicked, are forgotten
+			open = append(open[:0], open[1:]...)
+		}
+		c.entries[fn.Name] = append(open, event.Timestamp)
+	case recorder.FuncExit:
+		open := c.entries[fn.Name]
+		if len(open) == 0 {
+			return
+		}
+		d := event.Timestamp.Sub(open[len(open)-1])
+		c.entries[fn.Name] = open[:len(open)-1]
+		fn.Completed++
+		fn.TotalDuration += d
+		if d > fn.MaxDuration {
+			fn.MaxDuration = d
+		}
+	}
+}
+
+// function returns the stats name is counted in
+func (c *StatsCollector) function(name string) *FunctionStats {
+	if fn, ok := c.functions[name]; ok {
+		return fn
+	}
+	if c.opts.MaxFunctions > 0 && len(c.functions) >= c.opts.MaxFunctions {
+		name = OtherFunctions
+		if fn, ok := c.functions[name]; ok {
+			return fn
+		}
+	}
+	fn := &FunctionStats{Name: name}
+	c.functions[name] = fn
+	return fn
+}
+
+// Stats returns the aggregates of the events added so far
+func (c *StatsCollector) Stats() Stats {
+	stats := c.stats
+
+	stats.Functions = make([]FunctionStats, 0, len(c.functions))
+	for _, fn := range c.functions {
+		stats.Functions = append(stats.Functions, *fn)
+	}
+	sort.Slice(stats.Functions, func(i, j int) bool {
+		a, b := stats.Functions[i], stats.Functions[j]
+		if (a.Name == OtherFunctions) != (b.Name == OtherFunctions) {
+			return b.Name == OtherFunctions
+		}
+		if a.Events != b.Events {
+			return a.Events > b.Events
+		}
+		return a.Name < b.Name
+	})
+
+	keys := make([]int64, 0, len(c.buckets))
+	for key := range c.buckets {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	stats.Buckets = make([]TimeBucket, len(keys))
+	for i, key := range keys {
+		counts := make(map[recorder.EventType]int, len(c.buckets[key]))
+		for t, n := range c.buckets[key] {
+			counts[t] = n
+		}
+		stats.Buckets[i] = TimeBucket{
+			Start:      time.Unix(0, key*int64(c.opts.BucketWidth)).UTC(),
+			TypeCounts: counts,
+		}
+	}
+	return stats
+}
+
+// ComputeStats aggregates a slice of events
+func ComputeStats(events []recorder.Event, opts StatsOptions) Stats {
+	c := NewStatsCollector(opts)
+	for _, event := range events {
+		c.Add(event)
+	}
+	return c.Stats()
+}
+
+// WriteStatsCSV writes stats as one CSV table, with a row per function and a
+// row per event type in each time bucket. The section column tells them
+// apart, e.g. to load each into its own DataFrame. Durations are in
+// nanoseconds.
+func WriteStatsCSV(w io.Writer, stats Stats) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"section", "name", "bucket_start", "calls", "events", "total_ns", "mean_ns", "max_ns", "count"})
+	for _, fn := range stats.Functions {
+		cw.Write([]string{"function", fn.Name, "",
+			strconv.Itoa(fn.Calls), strconv.Itoa(fn.Events),
+			strconv.FormatInt(int64(fn.TotalDuration), 10),
+			strconv.FormatInt(int64(fn.MeanDuration()), 10),
+			strconv.FormatInt(int64(fn.MaxDuration), 10), ""})
+	}
+	for _, bucket := range stats.Buckets {
+		start := bucket.Start.Format(time.RFC3339Nano)
+		for _, t := range sortedTypes(bucket.TypeCounts) {
+			cw.Write([]string{"event_type", t.String(), start, "", "", "", "", "", strconv.Itoa(bucket.TypeCounts[t])})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// sortedTypes returns the event types counted in order
+func sortedTypes(counts map[recorder.EventType]int) []recorder.EventType {
+	types := make([]recorder.EventType, 0, len(counts))
+	for t := range counts {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
+
+// statsJSON is the JSON form of Stats
+type statsJSON struct {
+	Events        int              `json:"events"`
+	Start         time.Time        `json:"start"`
+	End           time.Time        `json:"end"`
+	BucketWidthNs int64            `json:"bucket_width_ns"`
+	Functions     []functionJSON   `json:"functions"`
+	Buckets       []timeBucketJSON `json:"buckets"`
+}
+
+type functionJSON struct {
+	Name    string `json:"name"`
+	Calls   int    `json:"calls"`
+	Events  int    `json:"events"`
+	TotalNs int64  `json:"total_ns"`
+	MeanNs  int64  `json:"mean_ns"`
+	MaxNs   int64  `json:"max_ns"`
+}
+
+type timeBucketJSON struct {
+	Start  time.Time      `json:"start"`
+	Counts map[string]int `json:"counts"`
+}
+
+// WriteStatsJSON writes stats as an indented JSON document. Durations are
+// in nanoseconds.
+func WriteStatsJSON(w io.Writer, stats Stats) error {
+	doc := statsJSON{
+		Events:        stats.Events,
+		Start:         stats.Start.UTC(),
+		End:           stats.End.UTC(),
+		BucketWidthNs: int64(stats.BucketWidth),
+		Functions:     make([]functionJSON, len(stats.Functions)),
+		Buckets:       make([]timeBucketJSON, len(stats.Buckets)),
+	}
+	for i, fn := range stats.Functions {
+		doc.Functions[i] = functionJSON{
+			Name:    fn.Name,
+			Calls:   fn.Calls,
+			Events:  fn.Events,
+			TotalNs: int64(fn.TotalDuration),
+			MeanNs:  int64(fn.MeanDuration()),
+			MaxNs:   int64(fn.MaxDuration),
+		}
+	}
+	for i, bucket := range stats.Buckets {
+		counts := make(map[string]int, len(bucket.TypeCounts))
+		for t, n := range bucket.TypeCounts {
+			counts[t.String()] = n
+		}
+		doc.Buckets[i] = timeBucketJSON{Start: bucket.Start, Counts: counts}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("failed to encode stats: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package replay
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+var updateGolden = flag.Bool("update", false, "Rewrite the golden files in testdata")
+
+// statsEvents returns a synthetic recording spanning three 10ms buckets
+func statsEvents() []recorder.Event {
+	base := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(ms int) time.Time { return base.Add(time.Duration(ms) * time.Millisecond) }
+	return []recorder.Event{
+		{ID: 1, Timestamp: at(0), Type: recorder.FuncEntry, FuncName: "main.main"},
+		{ID: 2, Timestamp: at(1), Type: recorder.FuncEntry, FuncName: "main.work"},
+		{ID: 3, Timestamp: at(2), Type: recorder.VarAssignment, FuncName: "main.work", Details: "x = 1"},
+		{ID: 4, Timestamp: at(5), Type: recorder.FuncExit, FuncName: "main.work"},
+		{ID: 5, Timestamp: at(12), Type: recorder.FuncEntry, FuncName: "main.work"},
+		{ID: 6, Timestamp: at(13), Type: recorder.FuncEntry, FuncName: "main.helper"}, // Beyond MaxFunctions
+		{ID: 7, Timestamp: at(14), Type: recorder.FuncExit, FuncName: "main.helper"},
+		{ID: 8, Timestamp: at(21), Type: recorder.FuncExit, FuncName: "main.work"},
+		{ID: 9, Timestamp: at(25), Type: recorder.GoroutineSwitch, Details: "Goroutine 2 created"},
+		{ID: 10, Timestamp: at(29), Type: recorder.FuncExit, FuncName: "main.main"},
+	}
+}
+
+// checkGolden compares got with testdata/name, rewriting it with -update
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *updateGolden {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatalf("Failed to update %s: %v", path, err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs from the output, rerun with -update if the change is intended.\nGot:\n%s\nWant:\n%s", path, got, want)
+	}
+}
+
+func TestStatsGolden(t *testing.T) {
+	stats := ComputeStats(statsEvents(), StatsOptions{BucketWidth: 10 * time.Millisecond, MaxFunctions: 2})
+
+	if len(stats.Functions) != 3 || stats.Functions[2].Name != OtherFunctions {
+		t.Fatalf("Expected two named functions and %s, got %+v", OtherFunctions, stats.Functions)
+	}
+	if work := stats.Functions[0]; work.Name != "main.work" || work.Calls != 2 ||
+		work.MeanDuration() != 6500*time.Microsecond || work.MaxDuration != 9*time.Millisecond {
+		t.Errorf("Unexpected stats for main.work: %+v", work)
+	}
+
+	var csvOut, jsonOut bytes.Buffer
+	if err := WriteStatsCSV(&csvOut, stats); err != nil {
+		t.Fatalf("Failed to write CSV: %v", err)
+	}
+	if err := WriteStatsJSON(&jsonOut, stats); err != nil {
+		t.Fatalf("Failed to write JSON: %v", err)
+	}
+	checkGolden(t, "stats.golden.csv", csvOut.Bytes())
+	checkGolden(t, "stats.golden.json", jsonOut.Bytes())
+}
+
+func TestStatsCollectorStreaming(t *testing.T) {
+	// Adding events one at a time gives the same result as a slice
+	c := NewStatsCollector(DefaultStatsOptions())
+	for _, event := range statsEvents() {
+		c.Add(event)
+	}
+	streamed := c.Stats()
+	if whole := ComputeStats(statsEvents(), DefaultStatsOptions()); streamed.Events != whole.Events ||
+		len(streamed.Functions) != len(whole.Functions) || len(streamed.Buckets) != 1 {
+		t.Errorf("Expected the same stats in one bucket, got %+v", streamed)
+	}
+}
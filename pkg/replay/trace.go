@@ -0,0 +1,83 @@
+package replay
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// UntracedName is shown for the group of events without a TraceID
+const UntracedName = "(untraced)"
+
+// TraceGroup summarizes the events of one request or transaction
+type TraceGroup struct {
+	TraceID   string // Empty for events without a TraceID
+	First     int    // Index of the first event
+	Last      int    // Index of the last event
+	Start     time.Time
+	End       time.Time
+	Events    int
+	Functions []string // Functions the events occurred in, sorted
+	Panicked  bool     // Whether a panic was recovered or recorded inside the request
+}
+
+// Name returns the trace ID, or UntracedName for untraced events
+func (g TraceGroup) Name() string {
+	if g.TraceID == "" {
+		return UntracedName
+	}
+	return g.TraceID
+}
+
+// TraceGroups groups the events by TraceID, ordered by each group's first
+// event. Events of concurrent requests may interleave, so a group spans
+// from its first to its last event but doesn't hold every event in between.
+func (r *BasicReplayer) TraceGroups() []TraceGroup {
+	var groups []TraceGroup
+	byID := make(map[string]int)
+	functions := make(map[string]map[string]bool)
+
+	for i, e := range r.events {
+		gi, ok := byID[e.TraceID]
+		if !ok {
+			gi = len(groups)
+			byID[e.TraceID] = gi
+			groups = append(groups, TraceGroup{TraceID: e.TraceID, First: i, Start: e.Timestamp})
+			functions[e.TraceID] = make(map[string]bool)
+		}
+
+		g := &groups[gi]
+		g.Last = i
+		g.End = e.Timestamp
+		g.Events++
+		if e.FuncName != "" {
+			functions[e.TraceID][e.FuncName] = true
+		}
+		if strings.HasPrefix(e.Details, "Recovered in ") || strings.HasPrefix(e.Details, "Panic in ") {
+			g.Panicked = true
+		}
+	}
+
+	for i := range groups {
+		for name := range functions[groups[i].TraceID] {
+			groups[i].Functions = append(groups[i].Functions, name)
+		}
+		sort.Strings(groups[i].Functions)
+	}
+	return groups
+}
+
+// TraceIndices returns the indices of the events with the given TraceID,
+// or of untraced events if traceID is UntracedName
+func (r *BasicReplayer) TraceIndices(traceID string) []int {
+	if traceID == UntracedName {
+		traceID = ""
+	}
+	var indices []int
+	for i, e := range r.events {
+		if e.TraceID == traceID {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
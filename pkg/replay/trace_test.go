@@ -0,0 +1,91 @@
+package replay
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+// tracedEvents returns three requests served by two goroutines, interleaved,
+// with untraced startup and shutdown events around them
+func tracedEvents() []recorder.Event {
+	steps := []struct {
+		eventType recorder.EventType
+		traceID   string
+		funcName  string
+		details   string
+	}{
+		{recorder.FuncEntry, "", "main.main", "Entering main.main"},
+		{recorder.GoroutineSwitch, "", "", "Switched to goroutine 2"},
+		{recorder.FuncEntry, "req-a", "main.handle", "Entering main.handle"},
+		{recorder.GoroutineSwitch, "", "", "Switched to goroutine 3"},
+		{recorder.FuncEntry, "req-b", "main.handle", "Entering main.handle"},
+		{recorder.FuncEntry, "req-b", "main.load", "Entering main.load"},
+		{recorder.GoroutineSwitch, "", "", "Switched to goroutine 2"},
+		{recorder.FuncExit, "req-a", "main.handle", "Exiting main.handle"},
+		{recorder.FuncEntry, "req-c", "main.handle", "Entering main.handle"},
+		{recorder.GoroutineSwitch, "", "", "Switched to goroutine 3"},
+		{recorder.DeferOperation, "req-b", "main.load", "Recovered in main.load: index out of range"},
+		{recorder.FuncExit, "req-b", "main.handle", "Exiting main.handle"},
+		{recorder.GoroutineSwitch, "", "", "Switched to goroutine 2"},
+		{recorder.FuncExit, "req-c", "main.handle", "Exiting main.handle"},
+		{recorder.FuncExit, "", "main.main", "Exiting main.main"},
+	}
+
+	base := time.Now()
+	events := make([]recorder.Event, len(steps))
+	for i, s := range steps {
+		events[i] = recorder.Event{
+			ID:        int64(i + 1),
+			Timestamp: base.Add(time.Duration(i) * time.Millisecond),
+			Type:      s.eventType,
+			Details:   s.details,
+			FuncName:  s.funcName,
+			TraceID:   s.traceID,
+		}
+	}
+	return events
+}
+
+func TestTraceGroups(t *testing.T) {
+	r := NewBasicReplayer()
+	if err := r.LoadEvents(tracedEvents()); err != nil {
+		t.Fatalf("Failed to load events: %v", err)
+	}
+
+	groups := r.TraceGroups()
+	expected := []struct {
+		name        string
+		first, last int
+		events      int
+		functions   string
+		panicked    bool
+	}{
+		{UntracedName, 0, 14, 7, "[main.main]", false},
+		{"req-a", 2, 7, 2, "[main.handle]", false},
+		{"req-b", 4, 11, 4, "[main.handle main.load]", true},
+		{"req-c", 8, 13, 2, "[main.handle]", false},
+	}
+	if len(groups) != len(expected) {
+		t.Fatalf("Expected %d groups, got %+v", len(expected), groups)
+	}
+	for i, want := range expected {
+		g := groups[i]
+		if g.Name() != want.name || g.First != want.first || g.Last != want.last || g.Events != want.events ||
+			fmt.Sprint(g.Functions) != want.functions || g.Panicked != want.panicked {
+			t.Errorf("Group %d: expected %+v, got %+v", i, want, g)
+		}
+	}
+	if !groups[1].End.After(groups[1].Start) {
+		t.Errorf("Expected req-a to span time, got %v to %v", groups[1].Start, groups[1].End)
+	}
+
+	if indices := r.TraceIndices("req-b"); fmt.Sprint(indices) != "[4 5 10 11]" {
+		t.Errorf("Expected req-b at [4 5 10 11], got %v", indices)
+	}
+	if indices := r.TraceIndices(UntracedName); len(indices) != 7 {
+		t.Errorf("Expected 7 untraced events, got %v", indices)
+	}
+}
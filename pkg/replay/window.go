@@ -0,0 +1,144 @@
+package replay
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+// WindowBound is one end of an EventWindow: an event index in file order,
+// or a time
+type WindowBound struct {
+	Index int       // Event index, used when Time is zero; -1 for the end of the recording
+	Time  time.Time // Bounds the window by time instead of index
+}
+
+// Unbounded is a window end that runs to the end of the recording
+var Unbounded = WindowBound{Index: -1}
+
+// ParseWindowBound parses an event index, e.g. "100000", or an RFC 3339
+// timestamp, e.g. "2025-03-01T12:00:00Z"
+func ParseWindowBound(s string) (WindowBound, error) {
+	if n, err := strconv.Atoi(s); err == nil {
+		if n < 0 {
+			return WindowBound{}, fmt.Errorf("invalid event index: %s", s)
+		}
+		return WindowBound{Index: n}, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return WindowBound{}, fmt.Errorf("invalid window bound %q: want an event index or an RFC 3339 time", s)
+	}
+	return WindowBound{Time: t}, nil
+}
+
+// String returns the bound as ParseWindowBound accepts it
+func (b WindowBound) String() string {
+	if !b.Time.IsZero() {
+		return b.Time.Format(time.RFC3339Nano)
+	}
+	if b.Index < 0 {
+		return "end"
+	}
+	return strconv.Itoa(b.Index)
+}
+
+// EventWindow is a slice of a recording, loaded in place of the whole of it
+// to replay huge recordings in bounded memory
+type EventWindow struct {
+	Events []recorder.Event // Events in the window, in file order
+	Offset int              // Index in the recording of the first event
+	Total  int              // Events in the whole recording
+	MaxID  int64            // Largest event ID in the whole recording
+	start  stateSnapshot    // Goroutine and channel state before the first event
+}
+
+// ScanEventWindow reads the events from from to to, inclusive, as scan
+// yields them, e.g. with recorder.ScanEventsFile. A time from starts at the
+// first event recorded at or after it and a time to ends before the first
+// later event recorded after it. Only the window is kept: the goroutine and
+// channel state the events before it build up is replayed as they stream
+// past and kept in place of them, since recorded SnapshotEvents carry no
+// state to seed it from, and the events after it are only counted.
+func ScanEventWindow(scan func(fn func(recorder.Event) error) error, from, to WindowBound) (*EventWindow, error) {
+	w := &EventWindow{Offset: -1}
+	seed := NewBasicReplayer()
+	ended := false
+
+	err := scan(func(e recorder.Event) error {
+		idx := w.Total
+		w.Total++
+		w.MaxID = max(w.MaxID, e.ID)
+		switch {
+		case ended:
+			return nil
+		case w.Offset < 0 && !from.reached(idx, e):
+			seed.processGoroutineAndChannelEvents(e)
+			return nil
+		case w.Offset >= 0 && to.passed(idx, e):
+			ended = true
+			return nil
+		}
+		if w.Offset < 0 {
+			w.Offset = idx
+		}
+		w.Events = append(w.Events, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(w.Events) == 0 {
+		return nil, fmt.Errorf("no events from %s to %s in a recording of %d events", from, to, w.Total)
+	}
+
+	w.start = stateSnapshot{
+		index:           -1,
+		goroutines:      seed.goroutines,
+		channels:        seed.channels,
+		activeGoroutine: seed.activeGoroutine,
+	}
+	return w, nil
+}
+
+// reached reports whether the event at idx is at or after a window start
+func (b WindowBound) reached(idx int, e recorder.Event) bool {
+	if !b.Time.IsZero() {
+		return !e.Timestamp.Before(b.Time)
+	}
+	return idx >= b.Index
+}
+
+// passed reports whether the event at idx is after a window end
+func (b WindowBound) passed(idx int, e recorder.Event) bool {
+	if !b.Time.IsZero() {
+		return e.Timestamp.After(b.Time)
+	}
+	return b.Index >= 0 && idx > b.Index
+}
+
+// LoadWindow loads the events of w like LoadEvents, replaying from the state
+// the events before the window left. Indices stay relative to the loaded
+// events; Window relates them to the recording. With NormalizeTimestamps
+// only the events within the window are ordered.
+func (r *BasicReplayer) LoadWindow(w *EventWindow) error {
+	if err := r.LoadEvents(w.Events); err != nil {
+		return err
+	}
+	r.offset, r.total, r.maxID = w.Offset, w.Total, w.MaxID
+	r.start = &w.start
+	r.Reset()
+	return nil
+}
+
+// Window returns the index in the recording of the first loaded event and
+// the number of events in the recording. Without LoadWindow it is 0 and the
+// number of events loaded.
+func (r *BasicReplayer) Window() (offset, total int) {
+	if r.start == nil {
+		return 0, len(r.events)
+	}
+	return r.offset, r.total
+}
@@ -0,0 +1,115 @@
+package replay
+
+import (
+	"testing"
+	"time"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
+)
+
+// windowRecording records goroutine 2 taking over and closing channel 1
+// before the window of statements at indices 4-6 starts
+func windowRecording(base time.Time) []recorder.Event {
+	events := []recorder.Event{
+		{Type: recorder.GoroutineSwitch, Details: "Goroutine 2 created"},
+		{Type: recorder.GoroutineSwitch, Details: "Goroutine switch from 1 to 2"},
+		{Type: recorder.ChannelOperation, Details: "Channel 1: send by goroutine 2, value: 7"},
+		{Type: recorder.ChannelOperation, Details: "Channel 1: closed by goroutine 2"},
+	}
+	for i := 0; i < 5; i++ {
+		events = append(events, recorder.Event{Type: recorder.StatementExecution, Details: "step", Line: 10 + i})
+	}
+	for i := range events {
+		events[i].ID = int64(i + 1)
+		events[i].Timestamp = base.Add(time.Duration(i) * time.Second)
+	}
+	return events
+}
+
+// scanSlice yields events the way recorder.ScanEventsFile yields a file's
+func scanSlice(events []recorder.Event) func(fn func(recorder.Event) error) error {
+	return func(fn func(recorder.Event) error) error {
+		for _, e := range events {
+			if err := fn(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func TestScanEventWindow(t *testing.T) {
+	base := time.Now()
+	events := windowRecording(base)
+
+	window, err := ScanEventWindow(scanSlice(events), WindowBound{Index: 4}, WindowBound{Index: 6})
+	if err != nil {
+		t.Fatalf("ScanEventWindow failed: %v", err)
+	}
+	if window.Offset != 4 || window.Total != len(events) || len(window.Events) != 3 || window.Events[0].Line != 10 {
+		t.Fatalf("Expected events 4-6 of %d, got offset %d, total %d, events %+v", len(events), window.Offset, window.Total, window.Events)
+	}
+
+	// Timestamps select the same window
+	byTime, err := ScanEventWindow(scanSlice(events), WindowBound{Time: base.Add(3500 * time.Millisecond)}, WindowBound{Time: base.Add(6 * time.Second)})
+	if err != nil || byTime.Offset != 4 || len(byTime.Events) != 3 {
+		t.Errorf("Expected the time bounds to select events 4-6, got %+v, %v", byTime, err)
+	}
+
+	if _, err := ScanEventWindow(scanSlice(events), WindowBound{Index: 20}, Unbounded); err == nil {
+		t.Error("Expected an error for a window past the end of the recording")
+	}
+}
+
+func TestLoadWindow(t *testing.T) {
+	events := windowRecording(time.Now())
+	window, err := ScanEventWindow(scanSlice(events), WindowBound{Index: 4}, Unbounded)
+	if err != nil {
+		t.Fatalf("ScanEventWindow failed: %v", err)
+	}
+
+	replayer := NewBasicReplayer()
+	if err := replayer.LoadWindow(window); err != nil {
+		t.Fatalf("LoadWindow failed: %v", err)
+	}
+	if offset, total := replayer.Window(); offset != 4 || total != len(events) {
+		t.Errorf("Expected window 4 of %d, got %d of %d", len(events), offset, total)
+	}
+
+	// The state the events before the window left holds from its start,
+	// and again after rebuilding it to move backward
+	seeded := func(when string) {
+		if replayer.ActiveGoroutine() != 2 || replayer.goroutines[2] == nil || replayer.channels[1] == nil || !replayer.channels[1].Closed {
+			t.Errorf("Expected goroutine 2 active and channel 1 closed %s, got goroutine %d, channels %+v",
+				when, replayer.ActiveGoroutine(), replayer.channels)
+		}
+	}
+	seeded("before replay")
+	replayer.ReplayToEventIndex(4)
+	if _, err := replayer.StepBackward(4); err != nil {
+		t.Fatalf("StepBackward failed: %v", err)
+	}
+	seeded("after stepping back")
+
+	// Loading a whole recording forgets the window
+	if err := replayer.LoadEvents(events); err != nil {
+		t.Fatalf("LoadEvents failed: %v", err)
+	}
+	if offset, total := replayer.Window(); offset != 0 || total != len(events) || replayer.ActiveGoroutine() != 1 {
+		t.Errorf("Expected the whole recording from its start, got window %d of %d, goroutine %d", offset, total, replayer.ActiveGoroutine())
+	}
+}
+
+func TestParseWindowBound(t *testing.T) {
+	if b, err := ParseWindowBound("100000"); err != nil || b.Index != 100000 || !b.Time.IsZero() {
+		t.Errorf("Expected index 100000, got %+v, %v", b, err)
+	}
+	if b, err := ParseWindowBound("2025-03-01T12:00:00Z"); err != nil || b.Time.IsZero() || b.String() != "2025-03-01T12:00:00Z" {
+		t.Errorf("Expected a time bound, got %+v, %v", b, err)
+	}
+	for _, bad := range []string{"-1", "yesterday"} {
+		if _, err := ParseWindowBound(bad); err == nil {
+			t.Errorf("Expected an error for %q", bad)
+		}
+	}
+}
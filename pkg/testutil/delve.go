@@ -0,0 +1,387 @@
+// Package testutil holds test doubles shared by ChronoGo's unit tests.
+//
+// FakeDelveClient stands in for a Delve RPC connection, so debugger code can
+// be tested without building binaries or running dlv:
+//
+//	client := testutil.NewFakeDelveClient()
+//	client.Variables["x"] = &api.Variable{Name: "x", Kind: reflect.Int, Value: "42"}
+//	dbg := debugger.NewDelveDebuggerWithClient("prog", client)
+package testutil
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/go-delve/delve/service/api"
+)
+
+// FakeDelveClient is a scripted Delve RPC client. The target is stopped at
+// State until Continue runs to the most recently created breakpoint, and
+// Next and StepOut advance one line. Variables are looked up by name.
+type FakeDelveClient struct {
+	mu sync.Mutex
+
+	State       *api.DebuggerState       // Where the target is stopped
+	Variables   map[string]*api.Variable // Variables in scope, by name
+	Goroutines  []*api.Goroutine
+	Breakpoints map[int]*api.Breakpoint // Breakpoints set in the target, by ID
+	Sources     []string                // Source files in the target
+	Functions   []string                // Functions in the target
+
+	// Breakpoints at these locations fail to be created, keyed by
+	// "file:line" or function name
+	InvalidLocations map[string]bool
+
+	// Functions the target enters, in order. If set, Continue runs to the
+	// next one with an enabled function breakpoint instead.
+	Entered []string
+
+	Calls []string // Methods called, in order
+
+	nextID  int
+	entered int // Position in Entered
+}
+
+// NewFakeDelveClient returns a client stopped in main.main on goroutine 1
+func NewFakeDelveClient() *FakeDelveClient {
+	return &FakeDelveClient{
+		State: &api.DebuggerState{
+			CurrentThread: &api.Thread{
+				ID:          1,
+				GoroutineID: 1,
+				File:        "main.go",
+				Line:        1,
+				Function:    &api.Function{Name_: "main.main"},
+			},
+		},
+		Variables:        make(map[string]*api.Variable),
+		Breakpoints:      make(map[int]*api.Breakpoint),
+		InvalidLocations: make(map[string]bool),
+		nextID:           1,
+	}
+}
+
+// record notes a call; the lock must be held
+func (f *FakeDelveClient) record(method string) {
+	f.Calls = append(f.Calls, method)
+}
+
+// Called reports how many times a method was called
+func (f *FakeDelveClient) Called(method string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n := 0
+	for _, call := range f.Calls {
+		if call == method {
+			n++
+		}
+	}
+	return n
+}
+
+// state returns a copy of the current state; the lock must be held
+func (f *FakeDelveClient) state() *api.DebuggerState {
+	state := *f.State
+	if f.State.CurrentThread != nil {
+		thread := *f.State.CurrentThread
+		state.CurrentThread = &thread
+	}
+	return &state
+}
+
+// GetState returns where the target is stopped
+func (f *FakeDelveClient) GetState() (*api.DebuggerState, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("GetState")
+	return f.state(), nil
+}
+
+// Continue stops at the most recently created enabled breakpoint, or at
+// the next function in Entered with one if it is set, or reports that the
+// target exited if there is none
+func (f *FakeDelveClient) Continue() <-chan *api.DebuggerState {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("Continue")
+
+	ch := make(chan *api.DebuggerState, 1)
+	var target *api.Breakpoint
+	if f.Entered != nil {
+		for target == nil && f.entered < len(f.Entered) {
+			fn := f.Entered[f.entered]
+			f.entered++
+			for _, bp := range f.Breakpoints {
+				if !bp.Disabled && bp.FunctionName == fn {
+					target = bp
+				}
+			}
+		}
+	} else {
+		for _, bp := range f.Breakpoints {
+			if !bp.Disabled && (target == nil || bp.ID > target.ID) {
+				target = bp
+			}
+		}
+	}
+	if target == nil {
+		ch <- &api.DebuggerState{Exited: true}
+		return ch
+	}
+
+	thread := f.State.CurrentThread
+	if thread == nil {
+		thread = &api.Thread{ID: 1, GoroutineID: 1}
+		f.State.CurrentThread = thread
+	}
+	thread.File, thread.Line = target.File, target.Line
+	if target.FunctionName != "" {
+		thread.Function = &api.Function{Name_: target.FunctionName}
+	}
+	thread.Breakpoint = target
+	ch <- f.state()
+	return ch
+}
+
+// step moves the current thread to the next line; the lock must be held
+func (f *FakeDelveClient) step(method string) (*api.DebuggerState, error) {
+	f.record(method)
+	if f.State.CurrentThread == nil {
+		return nil, fmt.Errorf("no current thread")
+	}
+	f.State.CurrentThread.Line++
+	f.State.CurrentThread.Breakpoint = nil
+	return f.state(), nil
+}
+
+// Next steps to the next line
+func (f *FakeDelveClient) Next() (*api.DebuggerState, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.step("Next")
+}
+
+// StepOut steps to the next line, standing in for the caller's
+func (f *FakeDelveClient) StepOut() (*api.DebuggerState, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.step("StepOut")
+}
+
+// Halt returns where the target is stopped
+func (f *FakeDelveClient) Halt() (*api.DebuggerState, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("Halt")
+	return f.state(), nil
+}
+
+// SwitchGoroutine makes the goroutine current, at its recorded location
+func (f *FakeDelveClient) SwitchGoroutine(goroutineID int64) (*api.DebuggerState, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("SwitchGoroutine")
+
+	for _, g := range f.Goroutines {
+		if g.ID != goroutineID {
+			continue
+		}
+		f.State.CurrentThread = &api.Thread{
+			ID:          1,
+			GoroutineID: g.ID,
+			File:        g.CurrentLoc.File,
+			Line:        g.CurrentLoc.Line,
+			Function:    g.CurrentLoc.Function,
+		}
+		return f.state(), nil
+	}
+	return nil, fmt.Errorf("unknown goroutine %d", goroutineID)
+}
+
+// CreateBreakpoint adds a breakpoint unless its location is invalid
+func (f *FakeDelveClient) CreateBreakpoint(bp *api.Breakpoint) (*api.Breakpoint, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("CreateBreakpoint")
+
+	if bp.FunctionName != "" {
+		if f.InvalidLocations[bp.FunctionName] {
+			return nil, fmt.Errorf("could not find function %s", bp.FunctionName)
+		}
+	} else if f.InvalidLocations[fmt.Sprintf("%s:%d", bp.File, bp.Line)] {
+		return nil, fmt.Errorf("could not find statement at %s:%d", bp.File, bp.Line)
+	}
+
+	created := *bp
+	created.ID = f.nextID
+	f.nextID++
+	f.Breakpoints[created.ID] = &created
+	result := created
+	return &result, nil
+}
+
+// AmendBreakpoint replaces an existing breakpoint
+func (f *FakeDelveClient) AmendBreakpoint(bp *api.Breakpoint) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("AmendBreakpoint")
+
+	if _, ok := f.Breakpoints[bp.ID]; !ok {
+		return fmt.Errorf("no breakpoint with id %d", bp.ID)
+	}
+	amended := *bp
+	f.Breakpoints[bp.ID] = &amended
+	return nil
+}
+
+// ClearBreakpoint removes a breakpoint
+func (f *FakeDelveClient) ClearBreakpoint(id int) (*api.Breakpoint, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("ClearBreakpoint")
+
+	bp, ok := f.Breakpoints[id]
+	if !ok {
+		return nil, fmt.Errorf("no breakpoint with id %d", id)
+	}
+	delete(f.Breakpoints, id)
+	return bp, nil
+}
+
+// GetBreakpoint returns a copy of a breakpoint
+func (f *FakeDelveClient) GetBreakpoint(id int) (*api.Breakpoint, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("GetBreakpoint")
+
+	bp, ok := f.Breakpoints[id]
+	if !ok {
+		return nil, fmt.Errorf("no breakpoint with id %d", id)
+	}
+	copied := *bp
+	return &copied, nil
+}
+
+// ListBreakpoints returns copies of all breakpoints, ordered by ID
+func (f *FakeDelveClient) ListBreakpoints(all bool) ([]*api.Breakpoint, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("ListBreakpoints")
+
+	breakpoints := make([]*api.Breakpoint, 0, len(f.Breakpoints))
+	for _, bp := range f.Breakpoints {
+		copied := *bp
+		breakpoints = append(breakpoints, &copied)
+	}
+	sort.Slice(breakpoints, func(i, j int) bool {
+		return breakpoints[i].ID < breakpoints[j].ID
+	})
+	return breakpoints, nil
+}
+
+// ListSources returns the target's source files
+func (f *FakeDelveClient) ListSources(filter string) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("ListSources")
+	return append([]string(nil), f.Sources...), nil
+}
+
+// ListFunctions returns the target's functions
+func (f *FakeDelveClient) ListFunctions(filter string, traceFollow int) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("ListFunctions")
+	return append([]string(nil), f.Functions...), nil
+}
+
+// variable returns a copy of a variable with strings cut to the load
+// config's limit, as Delve does; the lock must be held
+func (f *FakeDelveClient) variable(v *api.Variable, cfg api.LoadConfig) api.Variable {
+	copied := *v
+	if cfg.MaxStringLen > 0 && len(copied.Value) > cfg.MaxStringLen {
+		copied.Value = copied.Value[:cfg.MaxStringLen]
+	}
+	return copied
+}
+
+// EvalVariable looks up a variable by name
+func (f *FakeDelveClient) EvalVariable(scope api.EvalScope, expr string, cfg api.LoadConfig) (*api.Variable, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("EvalVariable")
+
+	v, ok := f.Variables[expr]
+	if !ok {
+		return nil, fmt.Errorf("could not find symbol value for %s", expr)
+	}
+	loaded := f.variable(v, cfg)
+	return &loaded, nil
+}
+
+// ListLocalVariables returns all variables, ordered by name
+func (f *FakeDelveClient) ListLocalVariables(scope api.EvalScope, cfg api.LoadConfig) ([]api.Variable, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("ListLocalVariables")
+
+	names := make([]string, 0, len(f.Variables))
+	for name := range f.Variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	vars := make([]api.Variable, 0, len(names))
+	for _, name := range names {
+		vars = append(vars, f.variable(f.Variables[name], cfg))
+	}
+	return vars, nil
+}
+
+// ListFunctionArgs returns no arguments; scripted variables are all locals
+func (f *FakeDelveClient) ListFunctionArgs(scope api.EvalScope, cfg api.LoadConfig) ([]api.Variable, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("ListFunctionArgs")
+	return nil, nil
+}
+
+// SetVariable changes the value of an existing variable
+func (f *FakeDelveClient) SetVariable(scope api.EvalScope, symbol, value string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("SetVariable")
+
+	v, ok := f.Variables[symbol]
+	if !ok {
+		return fmt.Errorf("could not find symbol value for %s", symbol)
+	}
+	v.Value = value
+	return nil
+}
+
+// ListGoroutines returns all goroutines
+func (f *FakeDelveClient) ListGoroutines(start, count int) ([]*api.Goroutine, int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("ListGoroutines")
+	return append([]*api.Goroutine(nil), f.Goroutines...), 0, nil
+}
+
+// Detach records that the client detached from the target
+func (f *FakeDelveClient) Detach(kill bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("Detach")
+	return nil
+}
+
+// Disconnect records that the client was closed
+func (f *FakeDelveClient) Disconnect(cont bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("Disconnect")
+	return nil
+}
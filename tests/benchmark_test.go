@@ -7,131 +7,30 @@ import (
 	"testing"
 	"time"
 
+	"github.com/willibrandon/ChronoGo/pkg/bench"
 	"github.com/willibrandon/ChronoGo/pkg/instrumentation"
 	"github.com/willibrandon/ChronoGo/pkg/recorder"
 )
 
 // BenchmarkAdvancedInstrumentation measures the overhead of instrumentation with advanced settings
 func BenchmarkAdvancedInstrumentation(b *testing.B) {
-	// Create in-memory recorder
-	r := recorder.NewInMemoryRecorder()
-
-	// Initialize instrumentation
-	instrumentation.InitInstrumentation(r)
-
-	// Reset timer to exclude setup time
-	b.ResetTimer()
-
-	for i := 0; i < b.N; i++ {
-		// Simulate a function call that would be instrumented
-		instrumentation.FuncEntry("benchmarkAdvancedFunction", "bench_test.go", 10)
-		simulateAdvancedInstrumentedFunction()
-		instrumentation.FuncExit("benchmarkAdvancedFunction", "bench_test.go", 12)
-	}
-
-	// Report the number of events recorded
-	b.ReportMetric(float64(len(r.GetEvents())), "events")
+	bench.InstrumentedCalls(b)
 }
 
 // BenchmarkAdvancedNoInstrumentation provides a baseline without instrumentation
 func BenchmarkAdvancedNoInstrumentation(b *testing.B) {
-	for i := 0; i < b.N; i++ {
-		// Same function but without instrumentation
-		simulateAdvancedUninstrumentedFunction()
-	}
+	bench.Baseline(b)
 }
 
 // BenchmarkAdvancedFileRecording measures the overhead of recording to a file
 func BenchmarkAdvancedFileRecording(b *testing.B) {
-	// Create a temporary file
-	tempFile, err := os.CreateTemp("", "benchmark-advanced-recording")
-	if err != nil {
-		b.Fatalf("Failed to create temp file: %v", err)
-	}
-	tempFile.Close()
-	tempFilePath := tempFile.Name()
-	defer os.Remove(tempFilePath)
-
-	// Create file recorder
-	r, err := recorder.NewFileRecorder(tempFilePath)
-	if err != nil {
-		b.Fatalf("Failed to create file recorder: %v", err)
-	}
-	defer r.Close()
-
-	// Initialize instrumentation
-	instrumentation.InitInstrumentation(r)
-
-	// Reset timer to exclude setup time
-	b.ResetTimer()
-
-	for i := 0; i < b.N; i++ {
-		// Simulate a function call that would be instrumented
-		instrumentation.FuncEntry("benchmarkAdvancedFunction", "bench_test.go", 10)
-		simulateAdvancedInstrumentedFunction()
-		instrumentation.FuncExit("benchmarkAdvancedFunction", "bench_test.go", 12)
-	}
-
-	// Get file size after recording
-	fileInfo, err := os.Stat(tempFilePath)
-	if err == nil {
-		b.ReportMetric(float64(fileInfo.Size()), "bytes")
-	}
+	bench.FileRecording(recorder.DefaultCompression)(b)
 }
 
 // BenchmarkAdvancedCompression measures the impact of compression on recording
 func BenchmarkAdvancedCompression(b *testing.B) {
-	compressionTypes := []struct {
-		name            string
-		compressionType recorder.CompressionType
-	}{
-		{"NoCompression", recorder.NoCompression},
-		{"DefaultCompression", recorder.DefaultCompression},
-	}
-
-	for _, ct := range compressionTypes {
-		b.Run(ct.name, func(b *testing.B) {
-			// Create a temporary file
-			tempFile, err := os.CreateTemp("", "benchmark-advanced-compression")
-			if err != nil {
-				b.Fatalf("Failed to create temp file: %v", err)
-			}
-			tempFile.Close()
-			tempFilePath := tempFile.Name()
-			defer os.Remove(tempFilePath)
-
-			// Create recorder with specified compression
-			options := recorder.FileRecorderOptions{
-				CompressionType: ct.compressionType,
-			}
-			fileRecorder, err := recorder.NewFileRecorderWithOptions(tempFilePath, options)
-			if err != nil {
-				b.Fatalf("Failed to create file recorder: %v", err)
-			}
-			defer fileRecorder.Close()
-
-			// Initialize instrumentation
-			instrumentation.InitInstrumentation(fileRecorder)
-
-			// Run the benchmark
-			b.ResetTimer()
-			for i := 0; i < b.N; i++ {
-				instrumentation.FuncEntry("benchmarkAdvancedFunction", "bench_test.go", 10)
-				simulateAdvancedInstrumentedFunction()
-				instrumentation.FuncExit("benchmarkAdvancedFunction", "bench_test.go", 12)
-			}
-			b.StopTimer()
-
-			// Close to ensure all data is flushed
-			fileRecorder.Close()
-
-			// Report file size
-			fileInfo, err := os.Stat(tempFilePath)
-			if err == nil {
-				b.ReportMetric(float64(fileInfo.Size()), "file_bytes")
-			}
-		})
-	}
+	b.Run("NoCompression", bench.FileRecording(recorder.NoCompression))
+	b.Run("DefaultCompression", bench.FileRecording(recorder.DefaultCompression))
 }
 
 // BenchmarkAdvancedConcurrentInstrumentation measures the performance with concurrent goroutines
@@ -250,19 +149,6 @@ func simulateAdvancedInstrumentedFunction() {
 	_ = result
 }
 
-// simulateAdvancedUninstrumentedFunction provides the same functionality without instrumentation
-func simulateAdvancedUninstrumentedFunction() {
-	// Generate some random data
-	data := rand.Intn(100)
-
-	// Perform a simple operation
-	result := data * 2
-
-	// Simulate some work (very small to minimize overhead)
-	time.Sleep(100 * time.Nanosecond)
-	_ = result
-}
-
 // simulateAdvancedSecureFunction simulates a function with sensitive data
 func simulateAdvancedSecureFunction() {
 	// Generate a "password" (this should be redacted by the recorder)
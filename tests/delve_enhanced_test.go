@@ -164,6 +164,9 @@ func TestDelveVariableInspection(t *testing.T) {
 		t.Fatalf("Failed to prepare test environment: %v", err)
 	}
 
+	// Clean up dlv processes left behind by earlier runs that crashed
+	cleanupOrphans(t)
+
 	// Clean up binary after test completes
 	defer func() {
 		t.Logf("Cleaning up debug binary: %s", binaryPath)
@@ -185,6 +188,7 @@ func TestDelveVariableInspection(t *testing.T) {
 	if err := cmd.Start(); err != nil {
 		t.Fatalf("Failed to start debug binary: %v", err)
 	}
+	debugger.RegisterProcess(cmd.Process.Pid)
 
 	t.Logf("Started debug binary with PID: %d", cmd.Process.Pid)
 
@@ -193,19 +197,7 @@ func TestDelveVariableInspection(t *testing.T) {
 
 	// Cleanup on exit
 	defer func() {
-		if cmd.Process != nil {
-			t.Logf("Killing debug process (PID: %d)", cmd.Process.Pid)
-			err := cmd.Process.Kill()
-			if err != nil {
-				t.Logf("Warning: Failed to kill debug process: %v", err)
-
-				// On Windows, try taskkill as a fallback
-				if runtime.GOOS == "windows" {
-					_ = exec.Command("taskkill", "/F", "/PID", fmt.Sprintf("%d", cmd.Process.Pid)).Run()
-				}
-			}
-			_, _ = cmd.Process.Wait()
-		}
+		killTestProcess(t, "debug", cmd)
 
 		// If we didn't successfully attach to the process, the test has failed
 		if !dlvAttached {
@@ -213,18 +205,6 @@ func TestDelveVariableInspection(t *testing.T) {
 		}
 	}()
 
-	// Also add cleanup for any other debug binaries that might be left behind
-	defer func() {
-		// Look for any debug bin processes that might be left over
-		if runtime.GOOS == "windows" {
-			_ = exec.Command("taskkill", "/F", "/IM", "__debug_bin*.exe").Run()
-		} else {
-			// On Unix-like systems, we could use pkill but it's less reliable
-			// without exact process names
-			_ = exec.Command("pkill", "-f", "__debug_bin").Run()
-		}
-	}()
-
 	// Give the process a moment to start
 	time.Sleep(2 * time.Second)
 
@@ -261,22 +241,11 @@ func TestDelveVariableInspection(t *testing.T) {
 	if err := dlvCmd.Start(); err != nil {
 		t.Fatalf("Failed to start dlv attach: %v", err)
 	}
+	debugger.RegisterProcess(dlvCmd.Process.Pid)
 
 	// Cleanup dlv
 	defer func() {
-		if dlvCmd.Process != nil {
-			t.Logf("Killing dlv process (PID: %d)", dlvCmd.Process.Pid)
-			err := dlvCmd.Process.Kill()
-			if err != nil {
-				t.Logf("Warning: Failed to kill dlv process: %v", err)
-
-				// On Windows, try taskkill as a fallback
-				if runtime.GOOS == "windows" {
-					_ = exec.Command("taskkill", "/F", "/PID", fmt.Sprintf("%d", dlvCmd.Process.Pid)).Run()
-				}
-			}
-			_, _ = dlvCmd.Process.Wait()
-		}
+		killTestProcess(t, "dlv", dlvCmd)
 
 		// Read and log the output
 		output, err := os.ReadFile(outputFile)
@@ -310,6 +279,29 @@ func TestDelveVariableInspection(t *testing.T) {
 	// to inspect variables, but that would require additional client implementation
 }
 
+// cleanupOrphans kills registered processes whose test run is gone
+func cleanupOrphans(t *testing.T) {
+	killed, err := debugger.CleanupOrphans()
+	if err != nil {
+		t.Logf("Warning: Failed to clean up orphaned processes: %v", err)
+	} else if killed > 0 {
+		t.Logf("Killed %d orphaned processes", killed)
+	}
+}
+
+// killTestProcess kills a process started by a test, along with its
+// children, and waits for it to exit
+func killTestProcess(t *testing.T, name string, cmd *exec.Cmd) {
+	if cmd.Process == nil {
+		return
+	}
+	t.Logf("Killing %s process (PID: %d)", name, cmd.Process.Pid)
+	if err := debugger.KillProcess(cmd.Process.Pid); err != nil {
+		t.Logf("Warning: Failed to kill %s process: %v", name, err)
+	}
+	_, _ = cmd.Process.Wait()
+}
+
 // Helper function to prepare the test binary with debug mode enabled
 func prepareTestBinaryWithDebug(t *testing.T) (string, string, error) {
 	projectRoot, err := findProjectRoot()
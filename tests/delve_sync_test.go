@@ -5,11 +5,11 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
-	"runtime"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/willibrandon/ChronoGo/pkg/debugger"
 	"github.com/willibrandon/ChronoGo/pkg/recorder"
 	"github.com/willibrandon/ChronoGo/pkg/replay"
 )
@@ -22,17 +22,8 @@ func TestDelveReplayerSynchronization(t *testing.T) {
 		t.Fatalf("Failed to prepare test environment: %v", err)
 	}
 
-	// Add global cleanup for any dlv processes at the end, in case something goes wrong
-	defer func() {
-		if runtime.GOOS == "windows" {
-			t.Log("Killing any leftover dlv processes")
-			killOutput, _ := exec.Command("taskkill", "/F", "/IM", "dlv.exe").CombinedOutput()
-			t.Logf("Taskkill output: %s", string(killOutput))
-		} else {
-			// Use pkill on Unix
-			_ = exec.Command("pkill", "-f", "dlv").Run()
-		}
-	}()
+	// Clean up dlv processes left behind by earlier runs that crashed
+	cleanupOrphans(t)
 
 	// Track whether we've successfully attached to the debug process
 	var dlvAttached bool
@@ -59,24 +50,13 @@ func TestDelveReplayerSynchronization(t *testing.T) {
 	if err := cmd.Start(); err != nil {
 		t.Fatalf("Failed to start debug binary: %v", err)
 	}
+	debugger.RegisterProcess(cmd.Process.Pid)
 
 	t.Logf("Started debug binary with PID: %d", cmd.Process.Pid)
 
 	// Cleanup on exit
 	defer func() {
-		if cmd.Process != nil {
-			t.Logf("Killing debug process (PID: %d)", cmd.Process.Pid)
-			err := cmd.Process.Kill()
-			if err != nil {
-				t.Logf("Warning: Failed to kill debug process: %v", err)
-
-				// On Windows, try taskkill as a fallback
-				if runtime.GOOS == "windows" {
-					_ = exec.Command("taskkill", "/F", "/PID", fmt.Sprintf("%d", cmd.Process.Pid)).Run()
-				}
-			}
-			_, _ = cmd.Process.Wait()
-		}
+		killTestProcess(t, "debug", cmd)
 	}()
 
 	// Give the process a moment to start and reach the debug helper
@@ -144,22 +124,11 @@ func TestDelveReplayerSynchronization(t *testing.T) {
 			t.Errorf("Failed to start dlv attach: %v", err)
 			return
 		}
+		debugger.RegisterProcess(dlvCmd.Process.Pid)
 
 		// Cleanup dlv
 		defer func() {
-			if dlvCmd.Process != nil {
-				t.Logf("Killing dlv process (PID: %d)", dlvCmd.Process.Pid)
-				err := dlvCmd.Process.Kill()
-				if err != nil {
-					t.Logf("Warning: Failed to kill dlv process: %v", err)
-
-					// On Windows, try taskkill as a fallback
-					if runtime.GOOS == "windows" {
-						_ = exec.Command("taskkill", "/F", "/PID", fmt.Sprintf("%d", dlvCmd.Process.Pid)).Run()
-					}
-				}
-				_, _ = dlvCmd.Process.Wait()
-			}
+			killTestProcess(t, "dlv", dlvCmd)
 
 			// Read and log the output
 			output, err := os.ReadFile(outputFile)
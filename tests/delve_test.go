@@ -5,7 +5,9 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/willibrandon/ChronoGo/pkg/debugger"
 )
@@ -210,3 +212,133 @@ func TestDelveDebugger(t *testing.T) {
 
 	t.Logf("Basic Delve integration test completed successfully")
 }
+
+func TestDelveLoadConfig(t *testing.T) {
+	if _, err := debugger.DelveAvailable(); err != nil {
+		t.Skipf("dlv unavailable: %v", err)
+	}
+
+	// A program holding a string much longer than the default limit
+	dir := t.TempDir()
+	source := filepath.Join(dir, "main.go")
+	program := `package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+func main() {
+	long := strings.Repeat("x", 500)
+	fmt.Println(len(long))
+}
+`
+	if err := os.WriteFile(source, []byte(program), 0644); err != nil {
+		t.Fatalf("Failed to write test program: %v", err)
+	}
+	binaryPath := filepath.Join(dir, "longstring")
+	if runtime.GOOS == "windows" {
+		binaryPath += ".exe"
+	}
+	build := exec.Command("go", "build", "-gcflags", "all=-N -l", "-o", binaryPath, source)
+	if output, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("Failed to build test program: %v\nOutput: %s", err, output)
+	}
+
+	dbg, err := debugger.NewDelveDebugger(binaryPath)
+	if err != nil {
+		t.Fatalf("Failed to create Delve debugger: %v", err)
+	}
+	defer dbg.Close()
+
+	if _, err := dbg.SetBreakpoint(source, 10); err != nil {
+		t.Fatalf("Failed to set breakpoint: %v", err)
+	}
+	if _, err := dbg.Continue(); err != nil {
+		t.Fatalf("Error during continue: %v", err)
+	}
+
+	v, err := dbg.GetVariable("long")
+	if err != nil {
+		t.Fatalf("Failed to get variable: %v", err)
+	}
+	if len(v.Value) != 64 {
+		t.Errorf("Expected the default limit to load 64 bytes, got %d", len(v.Value))
+	}
+
+	cfg := dbg.LoadConfig()
+	cfg.MaxStringLen = 1024
+	dbg.SetLoadConfig(cfg)
+
+	v, err = dbg.GetVariable("long")
+	if err != nil {
+		t.Fatalf("Failed to get variable: %v", err)
+	}
+	if v.Value != strings.Repeat("x", 500) {
+		t.Errorf("Expected the whole string after raising the limit, got %d bytes", len(v.Value))
+	}
+}
+
+func TestDelveAttach(t *testing.T) {
+	if _, err := debugger.DelveAvailable(); err != nil {
+		t.Skipf("dlv unavailable: %v", err)
+	}
+
+	// A child that sleeps until it's killed
+	dir := t.TempDir()
+	source := filepath.Join(dir, "main.go")
+	program := `package main
+
+import "time"
+
+func main() {
+	for {
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+`
+	if err := os.WriteFile(source, []byte(program), 0644); err != nil {
+		t.Fatalf("Failed to write test program: %v", err)
+	}
+	binaryPath := filepath.Join(dir, "sleeper")
+	if runtime.GOOS == "windows" {
+		binaryPath += ".exe"
+	}
+	build := exec.Command("go", "build", "-gcflags", "all=-N -l", "-o", binaryPath, source)
+	if output, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("Failed to build test program: %v\nOutput: %s", err, output)
+	}
+
+	cmd := exec.Command(binaryPath)
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Failed to start test program: %v", err)
+	}
+	debugger.RegisterProcess(cmd.Process.Pid)
+	exited := make(chan struct{})
+	go func() {
+		_ = cmd.Wait()
+		close(exited)
+	}()
+	defer func() {
+		_ = debugger.KillProcess(cmd.Process.Pid)
+		<-exited
+	}()
+
+	dbg, err := debugger.NewDelveDebuggerAttach(cmd.Process.Pid)
+	if err != nil {
+		t.Fatalf("Failed to attach Delve: %v", err)
+	}
+	if _, err := dbg.ListGoroutines(); err != nil {
+		t.Errorf("Failed to list goroutines of the attached process: %v", err)
+	}
+	if err := dbg.Close(); err != nil {
+		t.Fatalf("Failed to detach: %v", err)
+	}
+
+	// Detaching leaves the process running
+	select {
+	case <-exited:
+		t.Fatal("Expected the process to keep running after detaching")
+	case <-time.After(time.Second):
+	}
+}
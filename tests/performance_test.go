@@ -1,92 +1,36 @@
 package tests
 
 import (
-	"os"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/willibrandon/ChronoGo/pkg/bench"
 	"github.com/willibrandon/ChronoGo/pkg/instrumentation"
 	"github.com/willibrandon/ChronoGo/pkg/recorder"
 )
 
-// BenchmarkInstrumentation measures the performance overhead of instrumentation
-func BenchmarkInstrumentation(b *testing.B) {
-	// Create a temporary file for event recording
-	tempFile, err := os.CreateTemp("", "bench_test")
-	if err != nil {
-		b.Fatalf("Failed to create temp file: %v", err)
-	}
-	tempFile.Close()
-	tempFilePath := tempFile.Name()
-	defer os.Remove(tempFilePath)
-
-	// Create an in-memory recorder for benchmarking
-	memRecorder := recorder.NewInMemoryRecorder()
-
-	// Initialize instrumentation
-	instrumentation.InitInstrumentation(memRecorder)
-
-	// Run the benchmark
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		// Simulate a function call with instrumentation
-		instrumentation.FuncEntry("benchmarkFunction", "bench.go", 10)
-		dummyFunction(5) // Do some work
-		instrumentation.FuncExit("benchmarkFunction", "bench.go", 12)
+// BenchmarkOverhead runs the same workloads as `chrono bench`, so CI and the
+// command report comparable numbers
+func BenchmarkOverhead(b *testing.B) {
+	for _, w := range bench.Workloads() {
+		b.Run(w.Name, w.Bench)
 	}
-	b.StopTimer()
+}
 
-	// Report number of events recorded
-	b.ReportMetric(float64(len(memRecorder.GetEvents())), "events")
+// BenchmarkInstrumentation measures the performance overhead of instrumentation
+func BenchmarkInstrumentation(b *testing.B) {
+	bench.InstrumentedCalls(b)
 }
 
 // BenchmarkNoInstrumentation provides a baseline for comparison
 func BenchmarkNoInstrumentation(b *testing.B) {
-	// Run the benchmark without any instrumentation
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		// Same function call without instrumentation
-		dummyFunction(5)
-	}
+	bench.Baseline(b)
 }
 
 // BenchmarkFileRecording measures the overhead of recording to a file
 func BenchmarkFileRecording(b *testing.B) {
-	// Create a temporary file for event recording
-	tempFile, err := os.CreateTemp("", "bench_file_test")
-	if err != nil {
-		b.Fatalf("Failed to create temp file: %v", err)
-	}
-	tempFile.Close()
-	tempFilePath := tempFile.Name()
-	defer os.Remove(tempFilePath)
-
-	// Create a file recorder
-	fileRecorder, err := recorder.NewFileRecorder(tempFilePath)
-	if err != nil {
-		b.Fatalf("Failed to create file recorder: %v", err)
-	}
-	defer fileRecorder.Close()
-
-	// Initialize instrumentation
-	instrumentation.InitInstrumentation(fileRecorder)
-
-	// Run the benchmark
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		// Simulate a function call with instrumentation
-		instrumentation.FuncEntry("benchmarkFunction", "bench.go", 10)
-		dummyFunction(5) // Do some work
-		instrumentation.FuncExit("benchmarkFunction", "bench.go", 12)
-	}
-	b.StopTimer()
-
-	// Report file size
-	fileInfo, err := os.Stat(tempFilePath)
-	if err == nil {
-		b.ReportMetric(float64(fileInfo.Size()), "file_bytes")
-	}
+	bench.FileRecording(recorder.DefaultCompression)(b)
 }
 
 // BenchmarkConcurrentInstrumentation measures the performance with concurrent goroutines
@@ -130,57 +74,8 @@ func BenchmarkConcurrentInstrumentation(b *testing.B) {
 
 // BenchmarkCompression measures the impact of compression on recording
 func BenchmarkCompression(b *testing.B) {
-	compressionOptions := []struct {
-		name            string
-		compressionType recorder.CompressionType
-	}{
-		{"NoCompression", recorder.NoCompression},
-		{"DefaultCompression", recorder.DefaultCompression},
-	}
-
-	for _, opt := range compressionOptions {
-		b.Run(opt.name, func(b *testing.B) {
-			// Create a temporary file
-			tempFile, err := os.CreateTemp("", "bench_compression_"+opt.name)
-			if err != nil {
-				b.Fatalf("Failed to create temp file: %v", err)
-			}
-			tempFile.Close()
-			tempFilePath := tempFile.Name()
-			defer os.Remove(tempFilePath)
-
-			// Create recorder with specified compression
-			recorderOpts := recorder.FileRecorderOptions{
-				CompressionType: opt.compressionType,
-			}
-			fileRecorder, err := recorder.NewFileRecorderWithOptions(tempFilePath, recorderOpts)
-			if err != nil {
-				b.Fatalf("Failed to create file recorder: %v", err)
-			}
-			defer fileRecorder.Close()
-
-			// Initialize instrumentation
-			instrumentation.InitInstrumentation(fileRecorder)
-
-			// Run the benchmark
-			b.ResetTimer()
-			for i := 0; i < b.N; i++ {
-				instrumentation.FuncEntry("benchmarkFunction", "bench.go", 10)
-				dummyFunction(5)
-				instrumentation.FuncExit("benchmarkFunction", "bench.go", 12)
-			}
-			b.StopTimer()
-
-			// Close to ensure all data is flushed
-			fileRecorder.Close()
-
-			// Report file size
-			fileInfo, err := os.Stat(tempFilePath)
-			if err == nil {
-				b.ReportMetric(float64(fileInfo.Size()), "file_bytes")
-			}
-		})
-	}
+	b.Run("NoCompression", bench.FileRecording(recorder.NoCompression))
+	b.Run("DefaultCompression", bench.FileRecording(recorder.DefaultCompression))
 }
 
 // dummyFunction is a helper that does some simple work
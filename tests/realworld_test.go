@@ -2,14 +2,17 @@ package tests
 
 import (
 	"bytes"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"testing"
+
+	"github.com/willibrandon/ChronoGo/pkg/recorder"
 )
 
 // TestRealWorldUsage demonstrates a real-world use case of ChronoGo
@@ -509,58 +512,33 @@ func replayAndDebug(dir string, eventsFile string) error {
 	return nil
 }
 
-// Simple helper to read the events file
+// Simple helper to read the events file. Every line must decode: a line the
+// recorder wrote but can't read back is a bug, not something to skip.
 func readEventsFile(filePath string) ([]string, error) {
-	content, err := os.ReadFile(filePath)
+	f, err := os.Open(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read events file: %w", err)
 	}
+	defer f.Close()
 
-	fmt.Printf("Read %d bytes from events file\n", len(content))
-
-	// Split the content by lines
-	lines := strings.Split(string(content), "\n")
 	var events []string
-
-	for _, line := range lines {
-		trimmedLine := strings.TrimSpace(line)
-		if len(trimmedLine) > 0 {
-			// Try to parse the JSON to verify it's valid
-			var event map[string]interface{}
-			if err := json.Unmarshal([]byte(trimmedLine), &event); err != nil {
-				fmt.Printf("Warning: failed to parse event JSON: %v\n", err)
-				fmt.Printf("  Line content: %s\n", trimmedLine)
-				continue
-			}
-
-			// For our test purposes, convert the event to a string representation
-			// that includes the key information we're looking for
-			typeVal, ok := event["Type"].(float64)
-			if !ok {
-				fmt.Printf("Warning: Type field is not a number: %v\n", event["Type"])
-				continue
-			}
-
-			var typeStr string
-			switch int(typeVal) {
-			case 0:
-				typeStr = "FunctionEntry"
-			case 1:
-				typeStr = "FunctionExit"
-			case 2:
-				typeStr = "VariableAssignment"
-			default:
-				typeStr = fmt.Sprintf("EventType(%d)", int(typeVal))
-			}
-
-			funcName, _ := event["FuncName"].(string)
-			details, _ := event["Details"].(string)
-
-			eventStr := fmt.Sprintf("Type=%s FuncName=%s Details=%s",
-				typeStr, funcName, details)
-
-			events = append(events, eventStr)
+	dec := recorder.NewEventDecoder(f, recorder.DefaultDecoderOptions())
+	for {
+		event, err := dec.Next()
+		if errors.Is(err, io.EOF) {
+			break
 		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode events file: %w", err)
+		}
+
+		// For our test purposes, convert the event to a string representation
+		// that includes the key information we're looking for
+		events = append(events, fmt.Sprintf("Type=%s FuncName=%s Details=%s",
+			event.Type, event.FuncName, event.Details))
+	}
+	if lineErrs := dec.Errors(); len(lineErrs) > 0 {
+		return nil, fmt.Errorf("events file has %d malformed lines, first at %v", dec.Skipped(), lineErrs[0])
 	}
 
 	fmt.Printf("Parsed %d events from the file\n", len(events))